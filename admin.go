@@ -19,11 +19,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
+	"github.com/aamsur/beego/realtime"
 	"github.com/aamsur/beego/toolbox"
-	"github.com/aamsur/beego/utils"
 )
 
 // BeeAdminApp is the default adminApp used by admin module.
@@ -57,6 +59,11 @@ func init() {
 	beeAdminApp.Route("/healthcheck", healthcheck)
 	beeAdminApp.Route("/task", taskStatus)
 	beeAdminApp.Route("/listconf", listConf)
+	beeAdminApp.Route("/ready", readyCheck)
+	beeAdminApp.Route("/maintenance", maintenanceSwitch)
+	beeAdminApp.Route("/filterchain", filterChain)
+	beeAdminApp.Route("/routes", routeTable)
+	beeAdminApp.Route("/realtime", realtimeMetrics)
 	FilterMonitorFunc = func(string, string, time.Duration) bool { return true }
 }
 
@@ -67,9 +74,73 @@ func adminIndex(rw http.ResponseWriter, r *http.Request) {
 	tmpl = template.Must(tmpl.Parse(indexTpl))
 	tmpl = template.Must(tmpl.Parse(defaultScriptsTpl))
 	data := make(map[interface{}]interface{})
+	data["Charts"] = buildCharts(toolbox.TimeSeriesData.Samples())
 	tmpl.Execute(rw, data)
 }
 
+// chart is a single time series drawn as svg polyline on the admin dashboard.
+type chart struct {
+	Title  string
+	Last   string
+	Points string
+}
+
+const (
+	chartWidth  = 600
+	chartHeight = 120
+)
+
+// buildCharts converts the sampled time series into qps, latency and memory charts.
+func buildCharts(samples []toolbox.Sample) []chart {
+	qps := make([]float64, len(samples))
+	latency := make([]float64, len(samples))
+	mem := make([]float64, len(samples))
+	for i, s := range samples {
+		qps[i] = s.QPS
+		latency[i] = float64(s.AvgLatency) / float64(time.Millisecond)
+		mem[i] = float64(s.MemAlloc) / (1 << 20)
+	}
+	charts := []chart{
+		{Title: "QPS", Points: chartPoints(qps)},
+		{Title: "Avg latency (ms)", Points: chartPoints(latency)},
+		{Title: "Memory alloc (MB)", Points: chartPoints(mem)},
+	}
+	if n := len(samples); n > 0 {
+		charts[0].Last = fmt.Sprintf("%.2f", qps[n-1])
+		charts[1].Last = fmt.Sprintf("%.2f", latency[n-1])
+		charts[2].Last = fmt.Sprintf("%.2f", mem[n-1])
+	}
+	return charts
+}
+
+// chartPoints scales values into the svg polyline points attribute.
+func chartPoints(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	step := float64(chartWidth)
+	if len(values) > 1 {
+		step = float64(chartWidth) / float64(len(values)-1)
+	}
+	var buf bytes.Buffer
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%.1f,%.1f", float64(i)*step, chartHeight-v/max*chartHeight)
+	}
+	return buf.String()
+}
+
 // QpsIndex is the http.Handler for writing qbs statistics map result info in http.ResponseWriter.
 // it's registered with url pattern "/qbs" in admin module.
 func qpsIndex(rw http.ResponseWriter, r *http.Request) {
@@ -119,11 +190,17 @@ func listConf(rw http.ResponseWriter, r *http.Request) {
 			m["EnableGzip"] = EnableGzip
 			m["DirectoryIndex"] = DirectoryIndex
 			m["HttpServerTimeOut"] = HttpServerTimeOut
+			m["HttpReadHeaderTimeout"] = HttpReadHeaderTimeout
+			m["HttpIdleTimeout"] = HttpIdleTimeout
+			m["HttpMaxHeaderBytes"] = HttpMaxHeaderBytes
+			m["HttpMaxConns"] = HttpMaxConns
+			m["HttpMaxConnsPerIP"] = HttpMaxConnsPerIP
 			m["ErrorsShow"] = ErrorsShow
 			m["XSRFKEY"] = XSRFKEY
 			m["EnableXSRF"] = EnableXSRF
 			m["XSRFExpire"] = XSRFExpire
 			m["CopyRequestBody"] = CopyRequestBody
+			m["DefaultFormat"] = DefaultFormat
 			m["TemplateLeft"] = TemplateLeft
 			m["TemplateRight"] = TemplateRight
 			m["BeegoServerName"] = BeegoServerName
@@ -151,7 +228,7 @@ func listConf(rw http.ResponseWriter, r *http.Request) {
 
 			methods := []string{}
 			methodsData := make(map[string]interface{})
-			for method, t := range BeeApp.Handlers.routers {
+			for method, t := range BeeApp.Handlers.table().routers {
 
 				resultList := new([][]string)
 
@@ -176,74 +253,28 @@ func listConf(rw http.ResponseWriter, r *http.Request) {
 			var fields = []string{
 				fmt.Sprintf("Router Pattern"),
 				fmt.Sprintf("Filter Function"),
+				fmt.Sprintf("Name"),
+				fmt.Sprintf("Priority"),
 			}
 			content["Fields"] = fields
 
 			filterTypes := []string{}
 			filterTypeData := make(map[string]interface{})
 
-			if BeeApp.Handlers.enableFilter {
-				var filterType string
-
-				if bf, ok := BeeApp.Handlers.filters[BeforeRouter]; ok {
-					filterType = "Before Router"
-					filterTypes = append(filterTypes, filterType)
-					resultList := new([][]string)
-					for _, f := range bf {
-
-						var result = []string{
-							fmt.Sprintf("%s", f.pattern),
-							fmt.Sprintf("%s", utils.GetFuncName(f.filterFunc)),
-						}
-						*resultList = append(*resultList, result)
-					}
-					filterTypeData[filterType] = resultList
-				}
-
-				if bf, ok := BeeApp.Handlers.filters[BeforeExec]; ok {
-					filterType = "Before Exec"
-					filterTypes = append(filterTypes, filterType)
-					resultList := new([][]string)
-					for _, f := range bf {
-
-						var result = []string{
-							fmt.Sprintf("%s", f.pattern),
-							fmt.Sprintf("%s", utils.GetFuncName(f.filterFunc)),
-						}
-						*resultList = append(*resultList, result)
-					}
-					filterTypeData[filterType] = resultList
+			for _, f := range BeeApp.Handlers.Filters() {
+				resultList, ok := filterTypeData[f.Position].(*[][]string)
+				if !ok {
+					resultList = new([][]string)
+					filterTypes = append(filterTypes, f.Position)
+					filterTypeData[f.Position] = resultList
 				}
-
-				if bf, ok := BeeApp.Handlers.filters[AfterExec]; ok {
-					filterType = "After Exec"
-					filterTypes = append(filterTypes, filterType)
-					resultList := new([][]string)
-					for _, f := range bf {
-
-						var result = []string{
-							fmt.Sprintf("%s", f.pattern),
-							fmt.Sprintf("%s", utils.GetFuncName(f.filterFunc)),
-						}
-						*resultList = append(*resultList, result)
-					}
-					filterTypeData[filterType] = resultList
-				}
-
-				if bf, ok := BeeApp.Handlers.filters[FinishRouter]; ok {
-					filterType = "Finish Router"
-					filterTypes = append(filterTypes, filterType)
-					resultList := new([][]string)
-					for _, f := range bf {
-
-						var result = []string{
-							fmt.Sprintf("%s", f.pattern),
-							fmt.Sprintf("%s", utils.GetFuncName(f.filterFunc)),
-						}
-						*resultList = append(*resultList, result)
-					}
-					filterTypeData[filterType] = resultList
+				var result = []string{
+					fmt.Sprintf("%s", f.Pattern),
+					fmt.Sprintf("%s", f.Func),
+					fmt.Sprintf("%s", f.Name),
+					fmt.Sprintf("%d", f.Priority),
 				}
+				*resultList = append(*resultList, result)
 			}
 
 			content["Data"] = filterTypeData
@@ -263,6 +294,35 @@ func listConf(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// filterChain writes the filters as json in execution order.
+// with ?url=/path only the filters running for that url are listed.
+// it's registered with url pattern "/filterchain" in admin module.
+func filterChain(rw http.ResponseWriter, r *http.Request) {
+	var filters []FilterInfo
+	if url := r.FormValue("url"); url != "" {
+		filters = BeeApp.Handlers.FilterChain(url)
+	} else {
+		filters = BeeApp.Handlers.Filters()
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(rw).Encode(filters)
+}
+
+// routeTable writes the routes as json, with ?method=GET only the ones
+// of that http method are listed.
+// it's registered with url pattern "/routes" in admin module.
+func routeTable(rw http.ResponseWriter, r *http.Request) {
+	routes := []RouteInfo{}
+	method := strings.ToUpper(r.FormValue("method"))
+	for _, route := range BeeApp.Handlers.Routes() {
+		if method == "" || route.Method == method {
+			routes = append(routes, route)
+		}
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(rw).Encode(routes)
+}
+
 func printTree(resultList *[][]string, t *Tree) {
 	for _, tr := range t.fixrouters {
 		printTree(resultList, tr)
@@ -382,6 +442,65 @@ func healthcheck(rw http.ResponseWriter, req *http.Request) {
 
 }
 
+// ReadyCheck is a http.Handler combining health checks, readiness checks and the maintenance flag.
+// it responds 200 when everything passes and 503 otherwise, suitable for load balancer checks.
+// it's in "/ready" pattern in admin module.
+func readyCheck(rw http.ResponseWriter, req *http.Request) {
+	ready, result := toolbox.CheckReadiness()
+	data := map[string]interface{}{
+		"ready":  ready,
+		"checks": result,
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if ready {
+		rw.WriteHeader(http.StatusOK)
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	rw.Write(dataJson)
+}
+
+// MaintenanceSwitch turns the maintenance flag on or off via a POST of
+// "enable=true|false" and writes the current state.
+// the GET requests only read it, so the prefetchers and crawlers can't switch it.
+// it's in "/maintenance" pattern in admin module.
+func maintenanceSwitch(rw http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	if enable := req.Form.Get("enable"); enable != "" {
+		if req.Method != "POST" {
+			rw.Header().Set("Allow", "POST")
+			http.Error(rw, "the maintenance flag is switched by POST", http.StatusMethodNotAllowed)
+			return
+		}
+		on, err := strconv.ParseBool(enable)
+		if err != nil {
+			http.Error(rw, "enable should be true or false", http.StatusBadRequest)
+			return
+		}
+		toolbox.SetMaintenance(on)
+	}
+	rw.Write([]byte(fmt.Sprintf("maintenance: %t", toolbox.InMaintenance())))
+}
+
+// RealtimeMetrics is a http.Handler writing the metrics of the send queues of
+// the websocket and realtime connections as json: connections, messages
+// queued, dropped, slow clients disconnected and queue depths.
+// it's in "/realtime" pattern in admin module.
+func realtimeMetrics(rw http.ResponseWriter, req *http.Request) {
+	dataJson, err := json.Marshal(realtime.DefaultMetrics.Snapshot())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(dataJson)
+}
+
 // TaskStatus is a http.Handler with running task status (task name, status and the last execution).
 // it's in "/task" pattern in admin module.
 func taskStatus(rw http.ResponseWriter, req *http.Request) {
@@ -446,10 +565,12 @@ func (admin *adminApp) Route(pattern string, f http.HandlerFunc) {
 
 // Run adminApp http server.
 // Its addr is defined in configuration file as adminhttpaddr and adminhttpport.
+// https and client certificates are set in the [admin] section like for a Listener.
 func (admin *adminApp) Run() {
 	if len(toolbox.AdminTaskList) > 0 {
 		toolbox.StartTask()
 	}
+	toolbox.TimeSeriesData.Start()
 	addr := AdminHttpAddr
 
 	if AdminHttpPort != 0 {
@@ -458,8 +579,29 @@ func (admin *adminApp) Run() {
 	for p, f := range admin.routers {
 		http.Handle(p, f)
 	}
+	config, err := listenConfig("admin")
+	if err != nil {
+		BeeLogger.Critical("Admin ListenAndServe: ", err)
+		return
+	}
+	srv := &http.Server{Addr: addr}
+	configureServer(srv)
+	// cpu profiles take longer than HttpServerTimeOut
+	srv.WriteTimeout = 0
+	srv.TLSConfig, err = certConfig(nil, config.CertFile, config.KeyFile)
+	if err == nil {
+		srv.TLSConfig, err = clientAuthConfig(srv.TLSConfig, config.ClientCAFile, config.ClientAuth, config.ClientCRLFile)
+	}
+	if err != nil {
+		BeeLogger.Critical("Admin ListenAndServe: ", err)
+		return
+	}
 	BeeLogger.Info("Admin server Running on %s", addr)
-	err := http.ListenAndServe(addr, nil)
+	if config.CertFile != "" && config.KeyFile != "" {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if err != nil {
 		BeeLogger.Critical("Admin ListenAndServe: ", err)
 	}
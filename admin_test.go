@@ -0,0 +1,50 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/toolbox"
+)
+
+func TestMaintenanceSwitch(t *testing.T) {
+	defer toolbox.SetMaintenance(false)
+
+	r, _ := http.NewRequest("GET", "/maintenance?enable=true", nil)
+	w := httptest.NewRecorder()
+	maintenanceSwitch(w, r)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" || toolbox.InMaintenance() {
+		t.Errorf("a GET should not switch the maintenance flag, got %d", w.Code)
+	}
+
+	r, _ = http.NewRequest("POST", "/maintenance", strings.NewReader("enable=true"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	maintenanceSwitch(w, r)
+	if w.Body.String() != "maintenance: true" || !toolbox.InMaintenance() {
+		t.Errorf("a POST should switch the maintenance flag, got %d %s", w.Code, w.Body.String())
+	}
+
+	r, _ = http.NewRequest("GET", "/maintenance", nil)
+	w = httptest.NewRecorder()
+	maintenanceSwitch(w, r)
+	if w.Body.String() != "maintenance: true" {
+		t.Errorf("a GET should read the maintenance flag, got %s", w.Body.String())
+	}
+}
@@ -26,6 +26,21 @@ For detail usage please check our document:
 <p>
 <a target="_blank" href="http://beego.me/docs/advantage/monitor.md">Live Monitor</a>
 </p>
+<h2>Last 30 minutes</h2>
+{{range .Charts}}
+<div class="panel panel-default">
+<div class="panel-heading"><strong>{{.Title}}</strong> <span class="pull-right">{{.Last}}</span></div>
+<div class="panel-body">
+{{if .Points}}
+<svg width="600" height="120" viewBox="0 0 600 120" preserveAspectRatio="none">
+<polyline fill="none" stroke="#337ab7" stroke-width="2" points="{{.Points}}"/>
+</svg>
+{{else}}
+<p>no samples collected yet</p>
+{{end}}
+</div>
+</div>
+{{end}}
 {{.Content}}
 {{end}}`
 
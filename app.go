@@ -20,8 +20,14 @@ import (
 	"net/http"
 	"net/http/fcgi"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/aamsur/beego/grace"
+	"github.com/aamsur/beego/toolbox"
+	"github.com/aamsur/beego/tracing"
 	"github.com/aamsur/beego/utils"
 )
 
@@ -29,8 +35,17 @@ import (
 type App struct {
 	Handlers *ControllerRegistor
 	Server   *http.Server
+	// HttpHandler, if set, serves the plain http requests while https is enabled,
+	// for example to answer ACME challenges and redirect to https.
+	HttpHandler http.Handler
+
+	middlewares []MiddleWare
 }
 
+// MiddleWare wraps an http.Handler, as the net/http middlewares do,
+// e.g. the ones of gorilla/handlers or nosurf.
+type MiddleWare func(http.Handler) http.Handler
+
 // NewApp returns a new beego application.
 func NewApp() *App {
 	cr := NewControllerRegister()
@@ -38,11 +53,30 @@ func NewApp() *App {
 	return app
 }
 
+// Use adds middlewares wrapping the handlers of the app, before the filters
+// and the routing. the first middleware is the outermost one.
+// usage:
+//	app.Use(handlers.CompressHandler, nosurf.NewPure)
+func (app *App) Use(middleware ...MiddleWare) *App {
+	app.middlewares = append(app.middlewares, middleware...)
+	return app
+}
+
+// Handler returns the handlers of the app wrapped by its middlewares.
+func (app *App) Handler() http.Handler {
+	var h http.Handler = app.Handlers
+	for i := len(app.middlewares) - 1; i >= 0; i-- {
+		h = app.middlewares[i](h)
+	}
+	return h
+}
+
 // Run beego application.
 func (app *App) Run() {
 	addr := HttpAddr
+	socket, isUnix := unixSocket(HttpAddr)
 
-	if HttpPort != 0 {
+	if HttpPort != 0 && !isUnix {
 		addr = fmt.Sprintf("%s:%d", HttpAddr, HttpPort)
 	}
 
@@ -54,7 +88,7 @@ func (app *App) Run() {
 
 	if UseFcgi {
 		if UseStdIo {
-			err = fcgi.Serve(nil, app.Handlers) // standard I/O
+			err = fcgi.Serve(nil, app.Handler()) // standard I/O
 			if err == nil {
 				BeeLogger.Info("Use FCGI via standard I/O")
 			} else {
@@ -73,22 +107,26 @@ func (app *App) Run() {
 			if err != nil {
 				BeeLogger.Critical("Listen: ", err)
 			}
-			err = fcgi.Serve(l, app.Handlers)
+			err = fcgi.Serve(l, app.Handler())
 		}
+	} else if err = app.configureTLS(); err != nil {
+		BeeLogger.Critical("TLS config: ", err)
+		return
+	} else if Graceful || grace.SocketActivated() {
+		app.runGraceful(addr, endRunning)
 	} else {
 		app.Server.Addr = addr
-		app.Server.Handler = app.Handlers
-		app.Server.ReadTimeout = time.Duration(HttpServerTimeOut) * time.Second
-		app.Server.WriteTimeout = time.Duration(HttpServerTimeOut) * time.Second
+		app.Server.Handler = app.handler()
+		configureServer(app.Server)
 
 		if EnableHttpTLS {
 			go func() {
 				time.Sleep(20 * time.Microsecond)
 				if HttpsPort != 0 {
-					app.Server.Addr = fmt.Sprintf("%s:%d", HttpAddr, HttpsPort)
+					app.Server.Addr = fmt.Sprintf("%s:%d", tcpHost(), HttpsPort)
 				}
 				BeeLogger.Info("https server Running on %s", app.Server.Addr)
-				err := app.Server.ListenAndServeTLS(HttpCertFile, HttpKeyFile)
+				err := app.Server.ListenAndServeTLS("", "")
 				if err != nil {
 					BeeLogger.Critical("ListenAndServeTLS: ", err)
 					time.Sleep(100 * time.Microsecond)
@@ -99,6 +137,11 @@ func (app *App) Run() {
 
 		if EnableHttpListen {
 			go func() {
+				if isUnix {
+					app.serveUnix(socket)
+					endRunning <- true
+					return
+				}
 				app.Server.Addr = addr
 				BeeLogger.Info("http server Running on %s", app.Server.Addr)
 				if ListenTCP4 && HttpAddr == "" {
@@ -129,4 +172,112 @@ func (app *App) Run() {
 	}
 
 	<-endRunning
+	tracing.Shutdown()
+}
+
+// unixSocket returns the socket path of an address like unix:/var/run/app.sock.
+func unixSocket(addr string) (string, bool) {
+	if strings.HasPrefix(addr, "unix:") {
+		return addr[len("unix:"):], true
+	}
+	return "", false
+}
+
+// tcpHost returns the host of the tcp listeners, https is never on the unix socket.
+func tcpHost() string {
+	if _, ok := unixSocket(HttpAddr); ok {
+		return ""
+	}
+	return HttpAddr
+}
+
+// serveUnix serves http on the unix socket until SIGINT or SIGTERM,
+// then the socket file is removed.
+func (app *App) serveUnix(socket string) {
+	ln, err := grace.ListenUnix(socket, HttpSocketMode)
+	if err != nil {
+		BeeLogger.Critical("ListenAndServe: ", err)
+		time.Sleep(100 * time.Microsecond)
+		return
+	}
+	BeeLogger.Info("http server Running on %s", HttpAddr)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		toolbox.SetDraining(true)
+		app.Server.Close()
+	}()
+	if err := app.Server.Serve(ln); err != nil && err != http.ErrServerClosed {
+		BeeLogger.Critical("ListenAndServe: ", err)
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+// handler returns the handler of the servers, sending the plain http
+// requests to HttpHandler when it's set.
+func (app *App) handler() http.Handler {
+	h := app.Handler()
+	if app.HttpHandler == nil || !EnableHttpTLS {
+		return h
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			app.HttpHandler.ServeHTTP(rw, r)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// runGraceful serves with the grace module, so the app restarts on SIGUSR2
+// without dropping connections and drains the running requests on SIGTERM.
+// it's used with systemd socket activation too, the sockets named http and https
+// or with the same address are taken.
+func (app *App) runGraceful(addr string, endRunning chan bool) {
+	newServer := func(name, addr string) *grace.Server {
+		srv := grace.NewServer(addr, app.handler())
+		srv.Name = name
+		configureServer(srv.Server)
+		srv.TLSConfig = app.Server.TLSConfig
+		srv.ReusePort = GracefulReusePort
+		srv.Timeout = time.Duration(GracefulTimeout) * time.Second
+		grace.ShutdownDelay = time.Duration(GracefulShutdownDelay) * time.Second
+		if ListenTCP4 && HttpAddr == "" {
+			srv.Network = "tcp4"
+		}
+		return srv
+	}
+
+	if EnableHttpTLS {
+		go func() {
+			httpsAddr := addr
+			if HttpsPort != 0 {
+				httpsAddr = fmt.Sprintf("%s:%d", tcpHost(), HttpsPort)
+			}
+			BeeLogger.Info("https server Running on %s, pid %d", httpsAddr, os.Getpid())
+			if err := newServer("https", httpsAddr).ListenAndServeTLS("", ""); err != nil {
+				BeeLogger.Critical("ListenAndServeTLS: ", err)
+				time.Sleep(100 * time.Microsecond)
+			}
+			endRunning <- true
+		}()
+	}
+
+	if EnableHttpListen {
+		go func() {
+			srv := newServer("http", addr)
+			if socket, ok := unixSocket(HttpAddr); ok {
+				srv.Network = "unix"
+				srv.Addr = socket
+				srv.SocketMode = HttpSocketMode
+			}
+			BeeLogger.Info("http server Running on %s, pid %d", addr, os.Getpid())
+			if err := srv.ListenAndServe(); err != nil {
+				BeeLogger.Critical("ListenAndServe: ", err)
+				time.Sleep(100 * time.Microsecond)
+			}
+			endRunning <- true
+		}()
+	}
 }
@@ -0,0 +1,65 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+func TestAppUse(t *testing.T) {
+	app := NewApp()
+	app.Handlers.Get("/", func(ctx *context.Context) {
+		ctx.WriteString(ctx.ResponseWriter.Header().Get("X-Trace"))
+	})
+	trace := func(name string) MiddleWare {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Deny") != "" {
+				http.Error(w, "denied", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	app.Use(trace("outer"), trace("inner")).Use(deny)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	app.handler().ServeHTTP(w, r)
+	if got := w.HeaderMap["X-Trace"]; len(got) != 2 || got[0] != "outer" || got[1] != "inner" {
+		t.Errorf("the middlewares should run in order, got %v", got)
+	}
+	if w.Body.String() != "outer" {
+		t.Errorf("the handlers should run after the middlewares, got %q", w.Body.String())
+	}
+
+	r.Header.Set("X-Deny", "1")
+	w = httptest.NewRecorder()
+	app.Handler().ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("a middleware should end the request, code is %d", w.Code)
+	}
+}
@@ -26,14 +26,20 @@
 package beego
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/metrics"
 	"github.com/aamsur/beego/session"
+	"github.com/aamsur/beego/toolbox"
 )
 
 // beego web framework version.
@@ -42,6 +48,8 @@ const VERSION = "1.4.3"
 type hookfunc func() error //hook function to run
 var hooks []hookfunc       //hook function slice to store the hookfunc
 
+var startupHooks []func() error // run by Run before the servers accept requests
+
 // Router adds a patterned controller handler to BeeApp.
 // it's an alias method of App.Router.
 // usage:
@@ -58,8 +66,28 @@ var hooks []hookfunc       //hook function slice to store the hookfunc
 //  beego.Router("/api/create",&RestController{},"post:CreateFood")
 //  beego.Router("/api/update",&RestController{},"put:UpdateFood")
 //  beego.Router("/api/delete",&RestController{},"delete:DeleteFood")
-func Router(rootpath string, c ControllerInterface, mappingMethods ...string) *App {
-	BeeApp.Handlers.Add(rootpath, c, mappingMethods...)
+func Router(rootpath string, c ControllerInterface, options ...interface{}) *App {
+	BeeApp.Handlers.Add(rootpath, c, options...)
+	return BeeApp
+}
+
+// Use adds net/http middlewares wrapping BeeApp, the first one being
+// the outermost. it's same to App.Use.
+// usage:
+//  beego.Use(handlers.ProxyHeaders, handlers.CompressHandler)
+func Use(middleware ...MiddleWare) *App {
+	return BeeApp.Use(middleware...)
+}
+
+// RouterHost adds a patterned controller handler to BeeApp like Router,
+// only matching the requests to host.
+// the "*" and ":name" labels of host match any subdomain label,
+// captured as the :subdomain and :name params.
+// usage:
+//  beego.RouterHost("api.example.com", "/user/:id", &controllers.UserController{})
+//  beego.RouterHost("*.tenant.example.com", "/", &controllers.TenantController{})
+func RouterHost(host, rootpath string, c ControllerInterface, options ...interface{}) *App {
+	BeeApp.Handlers.AddHost(host, rootpath, c, options...)
 	return BeeApp
 }
 
@@ -104,6 +132,18 @@ func RESTRouter(rootpath string, c ControllerInterface) *App {
 	return BeeApp
 }
 
+// RESTNestedRouter adds a restful controller handler of the resources of
+// child nested in the ones of parent to BeeApp, visited at
+// "parent/:parentId/child" and "parent/:parentId/child/:objectId".
+// parent may be nested itself, its params keep their names.
+// usage:
+//	beego.RESTRouter("/user", &UserController{})
+//	beego.RESTNestedRouter("/user", "post", &PostController{})
+//	beego.RESTNestedRouter("/user/:userId/post", "comment", &CommentController{})
+func RESTNestedRouter(parent, child string, c ControllerInterface) *App {
+	return RESTRouter(path.Join(parent, ":parentId", child), c)
+}
+
 // AutoRouter adds defined controller handler to BeeApp.
 // it's same to App.AutoRouter.
 // if beego.AddAuto(&MainContorlller{}) and MainController has methods List and Page,
@@ -127,8 +167,8 @@ func AutoPrefix(prefix string, c ControllerInterface) *App {
 //    beego.Get("/", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Get(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Get(rootpath, f)
+func Get(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Get(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -137,8 +177,8 @@ func Get(rootpath string, f FilterFunc) *App {
 //    beego.Post("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Post(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Post(rootpath, f)
+func Post(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Post(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -147,8 +187,8 @@ func Post(rootpath string, f FilterFunc) *App {
 //    beego.Delete("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Delete(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Delete(rootpath, f)
+func Delete(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Delete(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -157,8 +197,8 @@ func Delete(rootpath string, f FilterFunc) *App {
 //    beego.Put("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Put(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Put(rootpath, f)
+func Put(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Put(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -167,8 +207,8 @@ func Put(rootpath string, f FilterFunc) *App {
 //    beego.Head("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Head(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Head(rootpath, f)
+func Head(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Head(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -177,8 +217,8 @@ func Head(rootpath string, f FilterFunc) *App {
 //    beego.Options("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Options(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Options(rootpath, f)
+func Options(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Options(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -187,8 +227,8 @@ func Options(rootpath string, f FilterFunc) *App {
 //    beego.Patch("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Patch(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Patch(rootpath, f)
+func Patch(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Patch(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -197,8 +237,8 @@ func Patch(rootpath string, f FilterFunc) *App {
 //    beego.Any("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func Any(rootpath string, f FilterFunc) *App {
-	BeeApp.Handlers.Any(rootpath, f)
+func Any(rootpath string, f FilterFunc, options ...RouteOption) *App {
+	BeeApp.Handlers.Any(rootpath, f, options...)
 	return BeeApp
 }
 
@@ -248,12 +288,44 @@ func InsertFilter(pattern string, pos int, filter FilterFunc, params ...bool) *A
 	return BeeApp
 }
 
+// InsertNamedFilter adds a named filter with a priority to BeeApp.
+// it's an alias method of ControllerRegistor.InsertNamedFilter.
+func InsertNamedFilter(name string, priority int, pattern string, pos int, filter FilterFunc, params ...bool) error {
+	return BeeApp.Handlers.InsertNamedFilter(name, priority, pattern, pos, filter, params...)
+}
+
+// RemoveFilter removes a named filter of BeeApp at runtime.
+func RemoveFilter(name string) bool {
+	return BeeApp.Handlers.RemoveFilter(name)
+}
+
+// ReplaceFilter replaces the function of a named filter of BeeApp at runtime.
+func ReplaceFilter(name string, filter FilterFunc) error {
+	return BeeApp.Handlers.ReplaceFilter(name, filter)
+}
+
+// SkipFilter registers exemptions for a named filter of BeeApp.
+func SkipFilter(name string, conds ...FilterCondition) error {
+	return BeeApp.Handlers.SkipFilter(name, conds...)
+}
+
 // The hookfunc will run in beego.Run()
 // such as sessionInit, middlerware start, buildtemplate, admin start
 func AddAPPStartHook(hf hookfunc) {
 	hooks = append(hooks, hf)
 }
 
+// OnStartup adds a hook run in order by Run after the app is initialized and
+// before the servers accept requests, e.g. to run migrations or warm up caches.
+// the admin server is already running and /ready reports the app as not ready
+// until all hooks are done. an error aborts the startup.
+//	beego.OnStartup(func() error {
+//		return migration.Upgrade(0)
+//	})
+func OnStartup(fn func() error) {
+	startupHooks = append(startupHooks, fn)
+}
+
 // Run beego application.
 // beego.Run() default run on HttpPort
 // beego.Run(":8089")
@@ -269,12 +341,86 @@ func Run(params ...string) {
 		}
 	}
 	initBeforeHttpRun()
+	if err := runExtraServers(); err != nil {
+		panic(err)
+	}
+
+	BeeApp.Run()
+}
+
+// RunWithServer runs beego on srv, built by the caller to control its TLSConfig,
+// ConnState and other settings. when srv.Handler is nil BeeApp serves the requests,
+// else it should wrap BeeApp.Handler(). https is served if srv.TLSConfig has
+// certificates. it returns the error of srv.ListenAndServe.
+//	srv := &http.Server{Addr: ":8080", ConnState: trackConn}
+//	srv.Handler = requestLogger(beego.BeeApp.Handler())
+//	log.Fatal(beego.RunWithServer(srv))
+func RunWithServer(srv *http.Server) error {
+	initBeforeHttpRun()
+	if err := runExtraServers(); err != nil {
+		return err
+	}
+
+	if srv.Handler == nil {
+		srv.Handler = BeeApp.Handler()
+	}
+	BeeApp.Server = srv
+	BeeLogger.Info("http server Running on %s", srv.Addr)
+	if srv.TLSConfig != nil && (len(srv.TLSConfig.Certificates) > 0 || srv.TLSConfig.GetCertificate != nil) {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+// RunWithListener runs beego on l, e.g. a listener created by a supervisor
+// or a tls.Listener. BeeApp.Server can be set up before.
+func RunWithListener(l net.Listener) error {
+	initBeforeHttpRun()
+	if err := runExtraServers(); err != nil {
+		return err
+	}
+
+	if BeeApp.Server.Handler == nil {
+		BeeApp.Server.Handler = BeeApp.handler()
+	}
+	configureServer(BeeApp.Server)
+	BeeLogger.Info("http server Running on %s", l.Addr())
+	return BeeApp.Server.Serve(l)
+}
 
+// runExtraServers starts the admin server, runs the startup hooks,
+// then starts the named listeners and the reload on SIGHUP.
+func runExtraServers() error {
 	if EnableAdmin {
 		go beeAdminApp.Run()
 	}
+	if err := runStartupHooks(); err != nil {
+		return err
+	}
+	if EnableReload {
+		watchReload()
+	}
+	runListeners()
+	return nil
+}
 
-	BeeApp.Run()
+// runStartupHooks runs the hooks added by OnStartup, the app is not ready meanwhile.
+func runStartupHooks() error {
+	if len(startupHooks) == 0 {
+		toolbox.SetStarted(true)
+		return nil
+	}
+	toolbox.SetStarting(true)
+	start := time.Now()
+	for i, hook := range startupHooks {
+		if err := hook(); err != nil {
+			return fmt.Errorf("startup hook %d: %v", i+1, err)
+		}
+	}
+	toolbox.SetStarting(false)
+	toolbox.SetStarted(true)
+	BeeLogger.Info("startup hooks done in %s", time.Since(start))
+	return nil
 }
 
 func initBeforeHttpRun() {
@@ -318,18 +464,67 @@ func initBeforeHttpRun() {
 		go GlobalSessions.GC()
 	}
 
-	err := BuildTemplate(ViewsPath)
-	if err != nil {
-		if RunMode == "dev" {
-			Warn(err)
+	// the templates are compiled once, a broken one stops a prod app from starting
+	if err := BuildTemplate(ViewsPath); err != nil {
+		if RunMode == "prod" {
+			panic(err)
 		}
+		Warn(err)
+	}
+	if RunMode == "dev" {
+		go watchTemplates(ViewsPath)
 	}
 
 	registerDefaultErrorHandler()
 
+	if err := context.SetTrustedProxies(TrustedProxies); err != nil {
+		panic(err)
+	}
+
+	if err := initTracing(); err != nil {
+		panic(err)
+	}
+
+	if EnableProbes {
+		mountProbes()
+	}
+
+	if EnableMetrics {
+		startInternalMetrics()
+		if MetricsOnAdmin {
+			beeAdminApp.Route(MetricsPath, metrics.Handler().ServeHTTP)
+		} else {
+			Handler(MetricsPath, metrics.Handler())
+		}
+	}
+
+	if err := initI18n(); err != nil {
+		panic(err)
+	}
+
+	if RequestTimeout > 0 {
+		InsertFilter("*", BeforeRouter, Timeout(time.Duration(RequestTimeout)*time.Second, RequestTimeoutBody))
+	}
+
+	if EnableSecureHeaders {
+		InsertFilter("*", BeforeStatic, SecurityHeadersFilter(securityHeadersFromConfig()))
+	}
+
 	if EnableDocs {
 		Get("/docs", serverDocs)
 		Get("/docs/*", serverDocs)
+		if DocsUI != "" {
+			Get(DocsUIPath, serverDocsUI)
+			Get(strings.TrimSuffix(DocsUIPath, "/")+"/assets/*", serverDocsUIAssets)
+		}
+		if DocsAuth != nil || DocsUser != "" {
+			if DocsAuth == nil && DocsPassword == "" {
+				Error("docs: DocsUser is set without DocsPassword, the docs are refused")
+			}
+			for _, pattern := range []string{"/docs", "/docs/*", DocsUIPath, strings.TrimSuffix(DocsUIPath, "/") + "/assets/*"} {
+				InsertFilter(pattern, BeforeStatic, docsAccess)
+			}
+		}
 	}
 }
 
@@ -0,0 +1,98 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/toolbox"
+)
+
+func TestRunWithListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Get("/run-with-listener", func(ctx *context.Context) {
+		ctx.WriteString("ok")
+	})
+	var connections int
+	BeeApp.Server.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections++
+		}
+	}
+	served := make(chan error, 1)
+	go func() { served <- RunWithListener(ln) }()
+	defer func() {
+		BeeApp.Server.Close()
+		<-served
+		BeeApp.Server = &http.Server{}
+	}()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/run-with-listener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("BeeApp should serve on the listener, got %q", body)
+	}
+	if connections != 1 {
+		t.Errorf("caller settings of BeeApp.Server should be kept, got %d connections", connections)
+	}
+}
+
+func TestOnStartup(t *testing.T) {
+	defer func() { startupHooks = nil }()
+	var ran []int
+	OnStartup(func() error {
+		if !toolbox.IsStarting() {
+			t.Error("the app should not be ready while the hooks run")
+		}
+		ran = append(ran, 1)
+		return nil
+	})
+	OnStartup(func() error {
+		ran = append(ran, 2)
+		return errors.New("migration failed")
+	})
+	OnStartup(func() error {
+		ran = append(ran, 3)
+		return nil
+	})
+	defer toolbox.SetStarting(false)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := RunWithListener(ln); err == nil || err.Error() != "startup hook 2: migration failed" {
+		t.Errorf("a failed hook should abort the startup, got %v", err)
+	}
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 {
+		t.Errorf("hooks should run in order until one fails, got %v", ran)
+	}
+	if ready, _ := toolbox.CheckReadiness(); ready {
+		t.Error("the app should not be ready after a failed startup")
+	}
+}
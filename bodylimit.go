@@ -0,0 +1,132 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aamsur/beego/context"
+)
+
+// ErrBodyTooLarge is returned when reading more than the allowed request body.
+var ErrBodyTooLarge = errors.New("http: request body too large")
+
+// limitedBody reads at most limit bytes of the request body and
+// remembers whether the client sent more.
+type limitedBody struct {
+	rc        io.ReadCloser
+	limit     int64
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.rc.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.exceeded = true
+	return n, ErrBodyTooLarge
+}
+
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
+
+// BodyLimit returns a filter limiting the request body to size bytes.
+// it overrides MaxRequestBodySize and must run before the form is parsed:
+//	beego.InsertFilter("/upload", beego.BeforeStatic, beego.BodyLimit(100<<20))
+// in a namespace use NSBodyLimit.
+func BodyLimit(size int64) FilterFunc {
+	return func(ctx *context.Context) {
+		limitRequestBody(ctx, size)
+	}
+}
+
+// limitRequestBody wraps the request body, replacing a limit set before.
+// requests announcing a larger Content-Length are answered with 413 at once.
+func limitRequestBody(ctx *context.Context, size int64) {
+	r := ctx.Request
+	if r.Body == nil || size <= 0 {
+		return
+	}
+	if r.ContentLength > size {
+		bodyTooLarge(ctx, size)
+		return
+	}
+	rc := r.Body
+	if lb, ok := rc.(*limitedBody); ok {
+		rc = lb.rc
+	}
+	r.Body = &limitedBody{rc: rc, limit: size, remaining: size}
+}
+
+// bodyTooLarge answers 413 as JSON for API clients and with the 413 error page otherwise.
+func bodyTooLarge(ctx *context.Context, size int64) {
+	if strings.Contains(ctx.Input.Header("Accept"), "application/json") || ctx.Input.IsAjax() {
+		ctx.Output.SetStatus(http.StatusRequestEntityTooLarge)
+		ctx.Output.Json(map[string]interface{}{
+			"error": "request body too large",
+			"limit": size,
+		}, false, false)
+		return
+	}
+	exception("413", ctx)
+}
+
+// routeMatch is the route found for a request, kept so the router looks it
+// up once when the body settings need it before routing.
+type routeMatch struct {
+	done     bool
+	method   string
+	route    *controllerInfo
+	redirect string
+	params   Params
+}
+
+// matchOnce matches the request for method, reusing the match already made
+// for the same method.
+func (p *ControllerRegistor) matchOnce(ctx *context.Context, m *routeMatch, method, urlPath string) (*controllerInfo, string) {
+	if m.done && m.method == method {
+		return m.route, m.redirect
+	}
+	m.params = m.params[:0]
+	m.route, m.redirect = p.matchRoute(ctx, method, urlPath, &m.params)
+	m.method = method
+	m.done = true
+	return m.route, m.redirect
+}
+
+// bodyRoute returns the route matching a request with a body, for its body
+// limit and copy settings, nil when it has no body or no route matches.
+func (p *ControllerRegistor) bodyRoute(ctx *context.Context, urlPath string, m *routeMatch) *controllerInfo {
+	if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+		return nil
+	}
+	route, _ := p.matchOnce(ctx, m, ctx.Request.Method, urlPath)
+	return route
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+func TestBodyLimit(t *testing.T) {
+	defer func(size int64) { MaxRequestBodySize = size }(MaxRequestBodySize)
+	MaxRequestBodySize = 10
+
+	echo := func(ctx *context.Context) {
+		ctx.Output.Body([]byte(ctx.Input.Query("name")))
+	}
+	ns := NewNamespace("/limit", NSBodyLimit(1000))
+	ns.Post("/big", echo)
+	AddNamespace(ns)
+	BeeApp.Handlers.Post("/limit_small", echo)
+
+	post := func(url, body string, chunked bool) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", url, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", "application/json")
+		if chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		BeeApp.Handlers.ServeHTTP(w, r)
+		return w
+	}
+
+	body := "name=" + strings.Repeat("a", 50)
+	if w := post("/limit_small", body, false); w.Code != http.StatusRequestEntityTooLarge ||
+		!strings.Contains(w.Body.String(), `"limit":10`) {
+		t.Errorf("body over the global limit should get 413, got %d %s", w.Code, w.Body.String())
+	}
+	if w := post("/limit_small", body, true); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunked body over the limit should get 413, got %d", w.Code)
+	}
+	if w := post("/limit_small", "name=ok", false); w.Body.String() != "ok" {
+		t.Errorf("small body should pass, got %d %s", w.Code, w.Body.String())
+	}
+	if w := post("/limit/big", body, true); w.Body.String() != strings.Repeat("a", 50) {
+		t.Errorf("namespace limit should override the global one, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestWithBodyLimit(t *testing.T) {
+	defer func(size int64, copyBody bool) {
+		MaxRequestBodySize, CopyRequestBody = size, copyBody
+	}(MaxRequestBodySize, CopyRequestBody)
+	MaxRequestBodySize = 10
+	CopyRequestBody = true
+
+	handler := NewControllerRegister()
+	stream := func(ctx *context.Context) {
+		n, err := io.Copy(ioutil.Discard, ctx.Input.Body())
+		ctx.Output.Body([]byte(fmt.Sprint(n, err)))
+	}
+	handler.Post("/upload", stream, WithBodyLimit(100))
+	handler.Post("/small", stream)
+	post := func(url string, size int) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", url, strings.NewReader(strings.Repeat("a", size)))
+		r.Header.Set("Content-Type", "application/octet-stream")
+		r.ContentLength = -1
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	if w := post("/upload", 50); w.Body.String() != "50 <nil>" {
+		t.Errorf("the route limit should override the global one, got %d %s", w.Code, w.Body.String())
+	}
+	if w := post("/upload", 150); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the route limit should get 413, got %d %s", w.Code, w.Body.String())
+	}
+	if w := post("/small", 50); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the global limit should get 413, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBodyRouteMatch(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.InsertFilter("/users/:name/*", BeforeRouter, func(ctx *context.Context) {})
+	handler.Post("/users/:id/posts", func(ctx *context.Context) {
+		ctx.Output.Body([]byte(ctx.Input.Param(":id") + " " + ctx.Input.Query("a")))
+	}, WithBodyLimit(10))
+
+	tests := []struct {
+		body string
+		code int
+		want string
+	}{
+		{"a=b", http.StatusOK, "7 b"},
+		{"a=" + strings.Repeat("b", 50), http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("POST", "/users/7/posts", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.code || tt.want != "" && w.Body.String() != tt.want {
+			t.Errorf("%q: got %d %s, want %d %s", tt.body, w.Code, w.Body.String(), tt.code, tt.want)
+		}
+	}
+}
+
+func TestWithCopyBody(t *testing.T) {
+	defer func(copyBody bool) { CopyRequestBody = copyBody }(CopyRequestBody)
+	CopyRequestBody = false
+
+	handler := NewControllerRegister()
+	copied := func(ctx *context.Context) {
+		ctx.Output.Body([]byte(fmt.Sprintf("%q", ctx.Input.RequestBody)))
+	}
+	handler.Post("/copied", copied, WithCopyBody(true))
+	handler.Post("/form", copied, WithCopyBody(true))
+	handler.Post("/default", copied)
+	handler.InsertFilter("/lazy", BeforeRouter, func(ctx *context.Context) {
+		var v map[string]string
+		ctx.Input.BindJSON(&v)
+	})
+	handler.Post("/lazy", copied, WithCopyBody(true))
+	ns := NewNamespace("/ns", NSCopyBody(true))
+	ns.Post("/copied", copied)
+	ns.Post("/streamed", copied, WithCopyBody(false))
+	mergeNamespace(handler, ns)
+
+	tests := []struct {
+		url, contentType, want string
+	}{
+		{"/copied", "application/json", `"{\"a\":\"b\"}"`},
+		{"/form", "application/x-www-form-urlencoded", `"{\"a\":\"b\"}"`},
+		{"/default", "application/json", `""`},
+		{"/lazy", "application/json", `"{\"a\":\"b\"}"`},
+		{"/ns/copied", "application/json", `"{\"a\":\"b\"}"`},
+		{"/ns/streamed", "application/json", `""`},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("POST", tt.url, strings.NewReader(`{"a":"b"}`))
+		r.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.url, w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestWithStreamedUpload(t *testing.T) {
+	handler := NewControllerRegister()
+	upload := func(ctx *context.Context) {
+		var got []string
+		err := ctx.Input.EachFile(func(p *context.FilePart) error {
+			b, err := ioutil.ReadAll(p)
+			got = append(got, p.FileName()+":"+string(b))
+			return err
+		})
+		ctx.Output.Body([]byte(fmt.Sprintf("%v %s %v", got, ctx.Input.Query("title"), err)))
+	}
+	handler.Post("/stream", upload, WithStreamedUpload())
+	handler.Post("/parsed", upload)
+	post := func(url string) string {
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		w.WriteField("title", "holidays")
+		f, _ := w.CreateFormFile("video", "a.mp4")
+		f.Write([]byte("data"))
+		w.Close()
+		r, _ := http.NewRequest("POST", url, &b)
+		r.Header.Set("Content-Type", w.FormDataContentType())
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		return rw.Body.String()
+	}
+	if got := post("/stream"); got != "[a.mp4:data] holidays <nil>" {
+		t.Errorf("the streamed route got %q", got)
+	}
+	if got := post("/parsed"); got != "[] holidays "+context.ErrMultipartParsed.Error() {
+		t.Errorf("the parsed route got %q", got)
+	}
+}
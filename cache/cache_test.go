@@ -15,9 +15,14 @@
 package cache
 
 import (
+	"bytes"
+	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/aamsur/beego/tracing"
 )
 
 func TestCache(t *testing.T) {
@@ -115,3 +120,35 @@ func TestFileCache(t *testing.T) {
 	}
 	os.RemoveAll("cache")
 }
+
+func TestCacheWithContext(t *testing.T) {
+	var buf bytes.Buffer
+	tracing.Configure(tracing.Config{Exporter: tracing.NewWriterExporter(&buf), SampleRatio: 1})
+	defer tracing.Shutdown()
+
+	stats := &tracing.RequestStats{}
+	ctx, span := tracing.Start(tracing.ContextWithStats(context.Background(), stats), "GET /", tracing.SpanKindServer)
+	bm, _ := NewCache("memory", `{"interval":20}`)
+	bm = WithContext(ctx, bm)
+	if err := bm.Put("traced", 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if v := bm.Get("traced"); v.(int) != 1 {
+		t.Error("get err")
+	}
+	if v := bm.Get("untraced"); v != nil {
+		t.Error("get err")
+	}
+	if hits, misses := stats.CacheLookups(); hits != 1 || misses != 1 {
+		t.Error("cache lookups:", hits, misses)
+	}
+	span.End()
+	tracing.Flush()
+
+	out := buf.String()
+	for _, s := range []string{`"name":"cache Put"`, `"name":"cache Get"`, `"key":"cache.hit"`, `"parentSpanId":"` + span.SpanContext().SpanID.String() + `"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("no %s in %s", s, out)
+		}
+	}
+}
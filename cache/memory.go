@@ -186,23 +186,35 @@ func (bc *MemoryCache) StartAndGC(config string) error {
 	if err != nil {
 		return err
 	}
+	bc.lock.Lock()
 	bc.Every = cf["interval"]
 	bc.dur = dur
+	bc.lock.Unlock()
 	go bc.vaccuum()
 	return nil
 }
 
 // check expiration.
 func (bc *MemoryCache) vaccuum() {
-	if bc.Every < 1 {
+	bc.lock.RLock()
+	every, dur := bc.Every, bc.dur
+	bc.lock.RUnlock()
+	if every < 1 {
 		return
 	}
 	for {
-		<-time.After(bc.dur)
+		<-time.After(dur)
+		bc.lock.RLock()
 		if bc.items == nil {
+			bc.lock.RUnlock()
 			return
 		}
-		for name, _ := range bc.items {
+		names := make([]string, 0, len(bc.items))
+		for name := range bc.items {
+			names = append(names, name)
+		}
+		bc.lock.RUnlock()
+		for _, name := range names {
 			bc.item_expired(name)
 		}
 	}
@@ -0,0 +1,106 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+
+	"github.com/aamsur/beego/tracing"
+)
+
+// tracedCache records a span per operation of a cache and counts the hits and
+// misses of Get in the request stats.
+type tracedCache struct {
+	Cache
+	ctx context.Context
+}
+
+// WithContext returns c recording the spans of its operations as children of
+// the span of ctx, like the request span:
+//
+//	bm := cache.WithContext(c.Context(), bm)
+//	bm.Get("user:1")
+func WithContext(ctx context.Context, c Cache) Cache {
+	if t, ok := c.(*tracedCache); ok {
+		c = t.Cache
+	}
+	return &tracedCache{Cache: c, ctx: ctx}
+}
+
+func (c *tracedCache) start(op, key string) *tracing.Span {
+	_, span := tracing.Start(c.ctx, "cache "+op, tracing.SpanKindClient)
+	span.SetAttribute("cache.operation", op)
+	if key != "" {
+		span.SetAttribute("cache.key", key)
+	}
+	return span
+}
+
+func (c *tracedCache) end(span *tracing.Span, err error) {
+	span.RecordError(err)
+	span.End()
+}
+
+func (c *tracedCache) Get(key string) interface{} {
+	span := c.start("Get", key)
+	v := c.Cache.Get(key)
+	span.SetAttribute("cache.hit", v != nil)
+	tracing.StatsFromContext(c.ctx).AddCacheLookup(v != nil)
+	c.end(span, nil)
+	return v
+}
+
+func (c *tracedCache) Put(key string, val interface{}, timeout int64) error {
+	span := c.start("Put", key)
+	err := c.Cache.Put(key, val, timeout)
+	c.end(span, err)
+	return err
+}
+
+func (c *tracedCache) Delete(key string) error {
+	span := c.start("Delete", key)
+	err := c.Cache.Delete(key)
+	c.end(span, err)
+	return err
+}
+
+func (c *tracedCache) Incr(key string) error {
+	span := c.start("Incr", key)
+	err := c.Cache.Incr(key)
+	c.end(span, err)
+	return err
+}
+
+func (c *tracedCache) Decr(key string) error {
+	span := c.start("Decr", key)
+	err := c.Cache.Decr(key)
+	c.end(span, err)
+	return err
+}
+
+func (c *tracedCache) IsExist(key string) bool {
+	span := c.start("IsExist", key)
+	ok := c.Cache.IsExist(key)
+	span.SetAttribute("cache.hit", ok)
+	c.end(span, nil)
+	return ok
+}
+
+func (c *tracedCache) ClearAll() error {
+	span := c.start("ClearAll", "")
+	err := c.Cache.ClearAll()
+	c.end(span, err)
+	return err
+}
@@ -0,0 +1,55 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command beerouter generates the commentsRouter files of the annotation
+// routers of controller packages at build time, so that beego.Include
+// doesn't parse the sources when the app starts. Run it from the routers
+// package of the app:
+//
+//	//go:generate go run github.com/aamsur/beego/cmd/beerouter github.com/me/app/controllers
+//
+// and set RouterScan = false in the app.conf of production.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/build"
+	"log"
+	"os"
+
+	"github.com/aamsur/beego"
+)
+
+func main() {
+	out := flag.String("o", ".", "directory the commentsRouter files are written to")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: beerouter [-o dir] package...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	for _, path := range flag.Args() {
+		pkg, err := build.Import(path, ".", build.FindOnly)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := beego.GenerateRouters(pkg.Dir, pkg.ImportPath, *out); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
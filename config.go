@@ -20,10 +20,14 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/aamsur/beego/config"
+	"github.com/aamsur/beego/context"
 	"github.com/aamsur/beego/logs"
+	"github.com/aamsur/beego/realtime"
 	"github.com/aamsur/beego/session"
 	"github.com/aamsur/beego/utils"
 )
@@ -32,22 +36,32 @@ var (
 	BeeApp                 *App // beego application
 	AppName                string
 	AppPath                string
+	BaseURL                string   // scheme://host of the absolute urls of AbsUrlFor, e.g. https://example.com.
+	TrustedProxies         []string // ips and CIDR ranges of the proxies whose X-Forwarded-Proto, -Port and -For are trusted.
 	workPath               string
 	AppConfigPath          string
 	StaticDir              map[string]string
 	TemplateCache          map[string]*template.Template // template caching map
 	StaticExtensionsToGzip []string                      // files with should be compressed with gzip (.js,.css,etc)
 	EnableHttpListen       bool
-	HttpAddr               string
+	HttpAddr               string      // unix:/path/app.sock listens on a unix socket, unix:@name on a linux abstract socket.
+	HttpSocketMode         os.FileMode // file mode of the unix socket, e.g. 0660. 0 keeps the umask default.
 	HttpPort               int
 	ListenTCP4             bool
 	EnableHttpTLS          bool
 	HttpsPort              int
 	HttpCertFile           string
 	HttpKeyFile            string
-	RecoverPanic           bool // flag of auto recover panic
-	AutoRender             bool // flag of render template automatically
+	HttpClientCAFile       string // CA certificates verifying the client certificates of mutual tls.
+	HttpClientAuth         string // client certificate mode: none, request, require, verify or require_and_verify.
+	HttpClientCRLFile      string // revoked client certificates.
+	RecoverPanic           bool   // flag of auto recover panic
+	AutoRender             bool   // flag of render template automatically
 	ViewsPath              string
+	I18nPath               string // directory of the locale files like en-US.json, see the i18n module.
+	I18nDefault            string // locale used when a message or the locale of a request is missing, default is en-US.
+	I18nQuery              string // query parameter switching the locale of the user, default is lang, off to disable.
+	I18nCookie             string // cookie keeping the locale of the user, default is lang, off to disable.
 	AppConfig              *beegoAppConfig
 	RunMode                string           // run mode, "dev" or "prod"
 	GlobalSessions         *session.Manager // global session mananger
@@ -62,29 +76,76 @@ var (
 	UseFcgi                bool
 	UseStdIo               bool
 	MaxMemory              int64
-	EnableGzip             bool // flag of enable gzip
-	DirectoryIndex         bool // flag of display directory index. default is false.
+	MaxRequestBodySize     int64 // max size of request body in bytes, 0 means no limit. see BodyLimit.
+	EnableGzip             bool  // flag of enable gzip
+	DirectoryIndex         bool  // flag of display directory index. default is false.
 	HttpServerTimeOut      int64
+	HttpReadHeaderTimeout  int64  // seconds to read the request headers, default is 10 in prod runmode.
+	HttpIdleTimeout        int64  // seconds a keep-alive connection waits for the next request, default is 120 in prod runmode.
+	HttpMaxHeaderBytes     int    // max size of the request headers, default is 64KB in prod runmode, 1MB otherwise.
+	HttpMaxConns           int    // max open connections of a server, the new ones are closed. 0 means no limit.
+	HttpMaxConnsPerIP      int    // max open connections of a client ip. 0 means no limit.
+	Graceful               bool   // restart on SIGUSR2 without dropping connections, see the grace module.
+	GracefulReusePort      bool   // the new process binds the ports with SO_REUSEPORT instead of inheriting them.
+	GracefulTimeout        int64  // seconds the running requests get to finish on shutdown.
+	GracefulShutdownDelay  int64  // seconds a shutdown keeps accepting while /readyz fails, for the load balancers to notice. default is 0.
+	EnableReload           bool   // reload the config, templates, log files and certificates on SIGHUP, default is false. see Reload.
+	RequestTimeout         int64  // deadline of a request in seconds, answered with 503 when exceeded. 0 means no deadline.
+	RequestTimeoutBody     string // body of the 503 response sent when RequestTimeout is exceeded.
+	WebSocketMaxMessage    int64  // largest message read by a WebSocketController in bytes, default is 64KB.
+	WebSocketPingPeriod    int64  // seconds between the pings of a WebSocketController, a connection silent for longer is closed. default is 30.
+	WebSocketWriteTimeout  int64  // seconds a write to a websocket connection may take, default is 10.
+	WebSocketSendQueue     int    // messages queued by WebSocketController.Send per connection, default is 256.
+	WebSocketOverflow      string // what a full send queue does: drop the message, dropoldest or disconnect the client. default is drop.
 	ErrorsShow             bool   // flag of show errors in page. if true, show error and trace info in page rendered with error template.
 	XSRFKEY                string // xsrf hash salt string.
 	EnableXSRF             bool   // flag of enable xsrf.
 	XSRFExpire             int    // the expiry of xsrf value.
+	XSRFMode               string // "token" checks the _xsrf secure cookie, "cookie" the stateless double submit cookie. default is "token".
+	XSRFCookieName         string // cookie of the double submit token.
+	XSRFHeaderName         string // header echoing the double submit token.
 	CopyRequestBody        bool   // flag of copy raw request body in context.
+	DefaultFormat          string // format of ServeFormatted when the Accept header asks none of the registered ones, default is json.
 	TemplateLeft           string
 	TemplateRight          string
+	TemplateStrict         bool   // refuse the templates bypassing the html escaping, see AuditTemplates.
 	BeegoServerName        string // beego server name exported in response header.
 	EnableAdmin            bool   // flag of enable admin module to log every request info.
 	AdminHttpAddr          string // http server configurations for admin module.
 	AdminHttpPort          int
+	EnableMetrics          bool   // record the request count, duration, in flight and response size metrics of the routes.
+	MetricsPath            string // path serving the metrics in the Prometheus format, default is /metrics.
+	MetricsOnAdmin         bool   // serve the metrics on the admin server instead of the app, EnableAdmin must be set.
+	MetricsInterval        int64  // seconds between the collections of the runtime, session and template metrics, default is 15.
+	EnableProbes           bool   // serve the /livez, /readyz and /startupz probes, see probes.go.
+	ProbesOnAdmin          bool   // serve the probes on the admin server instead of the app, EnableAdmin must be set.
 	FlashName              string // name of the flash variable found in response header and cookie
 	FlashSeperator         string // used to seperate flash key:value
 	AppConfigProvider      string // config provider
 	EnableDocs             bool   // enable generate docs & server docs API Swagger
+	DocsFormat             string // openapi for the OpenAPI 3.0 docs of the controller comments, or swagger for the GlobalDocApi generated by bee
+	DocsUI                 string // swagger for Swagger UI or redoc for ReDoc, served at DocsUIPath when EnableDocs, none by default
+	DocsUIPath             string // path of the docs ui, default is /swagger
+	DocsUIAssets           string // url the scripts and styles of the docs ui are loaded from, a cdn by default or the ones embedded by swagger/ui
+	DocsUser               string // user of the basic auth protecting the docs and the docs ui, no auth when empty
+	DocsPassword           string // password of the docs basic auth, required with DocsUser
 	RouterCaseSensitive    bool   // router case sensitive default is true
+	RouterTrailingSlash    string // policy for the trailing slashes of the paths differing from the routes: rewrite, the default, strict or redirect
+	RouterPathCase         string // policy for the paths differing from the routes by the case, strict when RouterCaseSensitive by default, else rewrite
+	RouterScan             bool   // parse the sources of the controllers of Include in dev mode to generate the annotation routers, default is true
 	AccessLogs             bool   // print access logs, default is false
+	RequestEvents          bool   // emit the canonical log line of every request, see RequestEvent. default is false.
+	EnableSecureHeaders    bool   // send HSTS, CSP and other security headers, default is true in prod runmode
+
+	TracingExporter    string  // where the spans go: otlp or stdout. empty, the default, turns tracing off.
+	TracingEndpoint    string  // OTLP/HTTP collector of the otlp exporter, default is http://localhost:4318.
+	TracingServiceName string  // service.name of the spans, default is AppName.
+	TracingSampleRatio float64 // ratio of the traces started by the app that are recorded, default is 1.
 )
 
+// beegoAppConfig is AppConfig, its container is replaced by Reload.
 type beegoAppConfig struct {
+	lock        sync.RWMutex
 	innerConfig config.ConfigContainer
 }
 
@@ -93,96 +154,117 @@ func newAppConfig(AppConfigProvider, AppConfigPath string) (*beegoAppConfig, err
 	if err != nil {
 		return nil, err
 	}
-	rac := &beegoAppConfig{ac}
+	rac := &beegoAppConfig{innerConfig: ac}
 	return rac, nil
 }
 
+// inner returns the current container.
+func (b *beegoAppConfig) inner() config.ConfigContainer {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.innerConfig
+}
+
+// swap replaces the container by the one of a reloaded config.
+func (b *beegoAppConfig) swap(ac *beegoAppConfig) {
+	c := ac.inner()
+	b.lock.Lock()
+	b.innerConfig = c
+	b.lock.Unlock()
+}
+
 func (b *beegoAppConfig) Set(key, val string) error {
-	return b.innerConfig.Set(key, val)
+	return b.inner().Set(key, val)
 }
 
 func (b *beegoAppConfig) String(key string) string {
-	v := b.innerConfig.String(RunMode + "::" + key)
+	c := b.inner()
+	v := c.String(RunMode + "::" + key)
 	if v == "" {
-		return b.innerConfig.String(key)
+		return c.String(key)
 	}
 	return v
 }
 
 func (b *beegoAppConfig) Strings(key string) []string {
-	v := b.innerConfig.Strings(RunMode + "::" + key)
+	c := b.inner()
+	v := c.Strings(RunMode + "::" + key)
 	if v[0] == "" {
-		return b.innerConfig.Strings(key)
+		return c.Strings(key)
 	}
 	return v
 }
 
 func (b *beegoAppConfig) Int(key string) (int, error) {
-	v, err := b.innerConfig.Int(RunMode + "::" + key)
+	c := b.inner()
+	v, err := c.Int(RunMode + "::" + key)
 	if err != nil {
-		return b.innerConfig.Int(key)
+		return c.Int(key)
 	}
 	return v, nil
 }
 
 func (b *beegoAppConfig) Int64(key string) (int64, error) {
-	v, err := b.innerConfig.Int64(RunMode + "::" + key)
+	c := b.inner()
+	v, err := c.Int64(RunMode + "::" + key)
 	if err != nil {
-		return b.innerConfig.Int64(key)
+		return c.Int64(key)
 	}
 	return v, nil
 }
 
 func (b *beegoAppConfig) Bool(key string) (bool, error) {
-	v, err := b.innerConfig.Bool(RunMode + "::" + key)
+	c := b.inner()
+	v, err := c.Bool(RunMode + "::" + key)
 	if err != nil {
-		return b.innerConfig.Bool(key)
+		return c.Bool(key)
 	}
 	return v, nil
 }
 
 func (b *beegoAppConfig) Float(key string) (float64, error) {
-	v, err := b.innerConfig.Float(RunMode + "::" + key)
+	c := b.inner()
+	v, err := c.Float(RunMode + "::" + key)
 	if err != nil {
-		return b.innerConfig.Float(key)
+		return c.Float(key)
 	}
 	return v, nil
 }
 
 func (b *beegoAppConfig) DefaultString(key string, defaultval string) string {
-	return b.innerConfig.DefaultString(key, defaultval)
+	return b.inner().DefaultString(key, defaultval)
 }
 
 func (b *beegoAppConfig) DefaultStrings(key string, defaultval []string) []string {
-	return b.innerConfig.DefaultStrings(key, defaultval)
+	return b.inner().DefaultStrings(key, defaultval)
 }
 
 func (b *beegoAppConfig) DefaultInt(key string, defaultval int) int {
-	return b.innerConfig.DefaultInt(key, defaultval)
+	return b.inner().DefaultInt(key, defaultval)
 }
 
 func (b *beegoAppConfig) DefaultInt64(key string, defaultval int64) int64 {
-	return b.innerConfig.DefaultInt64(key, defaultval)
+	return b.inner().DefaultInt64(key, defaultval)
 }
 
 func (b *beegoAppConfig) DefaultBool(key string, defaultval bool) bool {
-	return b.innerConfig.DefaultBool(key, defaultval)
+	return b.inner().DefaultBool(key, defaultval)
 }
 
 func (b *beegoAppConfig) DefaultFloat(key string, defaultval float64) float64 {
-	return b.innerConfig.DefaultFloat(key, defaultval)
+	return b.inner().DefaultFloat(key, defaultval)
 }
 
 func (b *beegoAppConfig) DIY(key string) (interface{}, error) {
-	return b.innerConfig.DIY(key)
+	return b.inner().DIY(key)
 }
 
 func (b *beegoAppConfig) GetSection(section string) (map[string]string, error) {
-	return b.innerConfig.GetSection(section)
+	return b.inner().GetSection(section)
 }
 
 func (b *beegoAppConfig) SaveConfigFile(filename string) error {
-	return b.innerConfig.SaveConfigFile(filename)
+	return b.inner().SaveConfigFile(filename)
 }
 
 func init() {
@@ -231,6 +313,11 @@ func init() {
 
 	ViewsPath = "views"
 
+	I18nPath = filepath.Join("conf", "locale")
+	I18nDefault = "en-US"
+	I18nQuery = "lang"
+	I18nCookie = "lang"
+
 	SessionOn = false
 	SessionProvider = "memory"
 	SessionName = "beegosessionID"
@@ -247,14 +334,40 @@ func init() {
 	EnableGzip = false
 
 	HttpServerTimeOut = 0
+	HttpReadHeaderTimeout = 0
+	HttpIdleTimeout = 0
+	HttpMaxHeaderBytes = 0
+	HttpMaxConns = 0
+	HttpMaxConnsPerIP = 0
+
+	Graceful = false
+	GracefulReusePort = false
+	GracefulTimeout = 30
+
+	EnableReload = false
+
+	WebSocketMaxMessage = 64 << 10
+	WebSocketPingPeriod = 30
+	WebSocketWriteTimeout = 10
+	WebSocketSendQueue = 256
+	WebSocketOverflow = "drop"
+
+	TracingEndpoint = "http://localhost:4318"
+	TracingSampleRatio = 1
 
 	ErrorsShow = true
 
+	DefaultFormat = "json"
+
 	XSRFKEY = "beegoxsrf"
 	XSRFExpire = 0
+	XSRFMode = "token"
+	XSRFCookieName = "XSRF-TOKEN"
+	XSRFHeaderName = "X-XSRF-TOKEN"
 
 	TemplateLeft = "{{"
 	TemplateRight = "}}"
+	TemplateStrict = false
 
 	BeegoServerName = "beegoServer:" + VERSION
 
@@ -262,10 +375,18 @@ func init() {
 	AdminHttpAddr = "127.0.0.1"
 	AdminHttpPort = 8088
 
+	MetricsPath = "/metrics"
+	MetricsInterval = 15
+
 	FlashName = "BEEGO_FLASH"
 	FlashSeperator = "BEEGOFLASH"
 
 	RouterCaseSensitive = true
+	RouterTrailingSlash = PolicyRewrite
+	RouterScan = true
+
+	DocsFormat = "openapi"
+	DocsUIPath = "/swagger"
 
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
@@ -281,7 +402,7 @@ func init() {
 	if err != nil && os.IsNotExist(err) {
 		// for init if doesn't have app.conf will not panic
 		ac := config.NewFakeConfig()
-		AppConfig = &beegoAppConfig{ac}
+		AppConfig = &beegoAppConfig{innerConfig: ac}
 		Warning(err)
 	}
 }
@@ -289,10 +410,11 @@ func init() {
 // ParseConfig parsed default config file.
 // now only support ini, next will support json.
 func ParseConfig() (err error) {
-	AppConfig, err = newAppConfig(AppConfigProvider, AppConfigPath)
+	ac, err := newAppConfig(AppConfigProvider, AppConfigPath)
 	if err != nil {
 		return err
 	}
+	AppConfig = ac
 	envRunMode := os.Getenv("BEEGO_RUNMODE")
 	// set the runmode first
 	if envRunMode != "" {
@@ -307,6 +429,20 @@ func ParseConfig() (err error) {
 		HttpPort = v
 	}
 
+	for _, name := range AppConfig.Strings("Listeners") {
+		if name != "" {
+			Listener(name)
+		}
+	}
+
+	if v := AppConfig.String("HttpSocketMode"); v != "" {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return fmt.Errorf("HttpSocketMode %q is not an octal file mode", v)
+		}
+		HttpSocketMode = os.FileMode(mode)
+	}
+
 	if v, err := AppConfig.Bool("ListenTCP4"); err == nil {
 		ListenTCP4 = v
 	}
@@ -319,10 +455,68 @@ func ParseConfig() (err error) {
 		MaxMemory = maxmemory
 	}
 
+	if maxbodysize, err := AppConfig.Int64("MaxRequestBodySize"); err == nil {
+		MaxRequestBodySize = maxbodysize
+	}
+
+	if timeout, err := AppConfig.Int64("RequestTimeout"); err == nil {
+		RequestTimeout = timeout
+	}
+
+	if body := AppConfig.String("RequestTimeoutBody"); body != "" {
+		RequestTimeoutBody = body
+	}
+
+	if maxmessage, err := AppConfig.Int64("WebSocketMaxMessage"); err == nil {
+		WebSocketMaxMessage = maxmessage
+	}
+
+	if pingperiod, err := AppConfig.Int64("WebSocketPingPeriod"); err == nil {
+		WebSocketPingPeriod = pingperiod
+	}
+
+	if writetimeout, err := AppConfig.Int64("WebSocketWriteTimeout"); err == nil {
+		WebSocketWriteTimeout = writetimeout
+	}
+
+	if sendqueue, err := AppConfig.Int("WebSocketSendQueue"); err == nil {
+		WebSocketSendQueue = sendqueue
+	}
+
+	if overflow := AppConfig.String("WebSocketOverflow"); overflow != "" {
+		if _, ok := realtime.ParseOverflow(overflow); !ok {
+			return fmt.Errorf("WebSocketOverflow %q is not drop, dropoldest or disconnect", overflow)
+		}
+		WebSocketOverflow = overflow
+	}
+
+	if exporter := AppConfig.String("TracingExporter"); exporter != "" {
+		if exporter != "otlp" && exporter != "stdout" {
+			return fmt.Errorf("TracingExporter %q is not otlp or stdout", exporter)
+		}
+		TracingExporter = exporter
+	}
+
+	if endpoint := AppConfig.String("TracingEndpoint"); endpoint != "" {
+		TracingEndpoint = endpoint
+	}
+
+	if servicename := AppConfig.String("TracingServiceName"); servicename != "" {
+		TracingServiceName = servicename
+	}
+
+	if ratio, err := AppConfig.Float("TracingSampleRatio"); err == nil {
+		TracingSampleRatio = ratio
+	}
+
 	if appname := AppConfig.String("AppName"); appname != "" {
 		AppName = appname
 	}
 
+	if baseurl := AppConfig.String("BaseURL"); baseurl != "" {
+		BaseURL = strings.TrimSuffix(baseurl, "/")
+	}
+
 	if autorender, err := AppConfig.Bool("AutoRender"); err == nil {
 		AutoRender = autorender
 	}
@@ -335,6 +529,26 @@ func ParseConfig() (err error) {
 		ViewsPath = views
 	}
 
+	if i18npath := AppConfig.String("I18nPath"); i18npath != "" {
+		I18nPath = i18npath
+	}
+
+	if i18ndefault := AppConfig.String("I18nDefault"); i18ndefault != "" {
+		I18nDefault = i18ndefault
+	}
+
+	if i18nquery := AppConfig.String("I18nQuery"); i18nquery == "off" {
+		I18nQuery = ""
+	} else if i18nquery != "" {
+		I18nQuery = i18nquery
+	}
+
+	if i18ncookie := AppConfig.String("I18nCookie"); i18ncookie == "off" {
+		I18nCookie = ""
+	} else if i18ncookie != "" {
+		I18nCookie = i18ncookie
+	}
+
 	if sessionon, err := AppConfig.Bool("SessionOn"); err == nil {
 		SessionOn = sessionon
 	}
@@ -375,6 +589,34 @@ func ParseConfig() (err error) {
 		HttpServerTimeOut = timeout
 	}
 
+	if maxconns, err := AppConfig.Int("HttpMaxConns"); err == nil {
+		HttpMaxConns = maxconns
+	}
+
+	if maxconns, err := AppConfig.Int("HttpMaxConnsPerIP"); err == nil {
+		HttpMaxConnsPerIP = maxconns
+	}
+
+	if graceful, err := AppConfig.Bool("Graceful"); err == nil {
+		Graceful = graceful
+	}
+
+	if reuseport, err := AppConfig.Bool("GracefulReusePort"); err == nil {
+		GracefulReusePort = reuseport
+	}
+
+	if timeout, err := AppConfig.Int64("GracefulTimeout"); err == nil {
+		GracefulTimeout = timeout
+	}
+
+	if delay, err := AppConfig.Int64("GracefulShutdownDelay"); err == nil {
+		GracefulShutdownDelay = delay
+	}
+
+	if reload, err := AppConfig.Bool("EnableReload"); err == nil {
+		EnableReload = reload
+	}
+
 	if errorsshow, err := AppConfig.Bool("ErrorsShow"); err == nil {
 		ErrorsShow = errorsshow
 	}
@@ -383,6 +625,17 @@ func ParseConfig() (err error) {
 		CopyRequestBody = copyrequestbody
 	}
 
+	if proxies := AppConfig.String("TrustedProxies"); proxies != "" {
+		TrustedProxies = strings.Split(proxies, ";")
+	}
+
+	if format := AppConfig.String("DefaultFormat"); format != "" {
+		if context.FormatOf(context.FormatMediaTypes(format)[0]) != format {
+			return fmt.Errorf("DefaultFormat %q is not a registered format", format)
+		}
+		DefaultFormat = format
+	}
+
 	if xsrfkey := AppConfig.String("XSRFKEY"); xsrfkey != "" {
 		XSRFKEY = xsrfkey
 	}
@@ -395,6 +648,21 @@ func ParseConfig() (err error) {
 		XSRFExpire = expire
 	}
 
+	if mode := AppConfig.String("XSRFMode"); mode != "" {
+		if mode != "token" && mode != "cookie" {
+			return fmt.Errorf("XSRFMode %q is not token or cookie", mode)
+		}
+		XSRFMode = mode
+	}
+
+	if name := AppConfig.String("XSRFCookieName"); name != "" {
+		XSRFCookieName = name
+	}
+
+	if name := AppConfig.String("XSRFHeaderName"); name != "" {
+		XSRFHeaderName = name
+	}
+
 	if tplleft := AppConfig.String("TemplateLeft"); tplleft != "" {
 		TemplateLeft = tplleft
 	}
@@ -403,6 +671,10 @@ func ParseConfig() (err error) {
 		TemplateRight = tplright
 	}
 
+	if strict, err := AppConfig.Bool("TemplateStrict"); err == nil {
+		TemplateStrict = strict
+	}
+
 	if httptls, err := AppConfig.Bool("EnableHttpTLS"); err == nil {
 		EnableHttpTLS = httptls
 	}
@@ -419,6 +691,18 @@ func ParseConfig() (err error) {
 		HttpKeyFile = keyfile
 	}
 
+	if cafile := AppConfig.String("HttpClientCAFile"); cafile != "" {
+		HttpClientCAFile = cafile
+	}
+
+	if clientauth := AppConfig.String("HttpClientAuth"); clientauth != "" {
+		HttpClientAuth = clientauth
+	}
+
+	if crlfile := AppConfig.String("HttpClientCRLFile"); crlfile != "" {
+		HttpClientCRLFile = crlfile
+	}
+
 	if serverName := AppConfig.String("BeegoServerName"); serverName != "" {
 		BeegoServerName = serverName
 	}
@@ -474,12 +758,106 @@ func ParseConfig() (err error) {
 		AdminHttpPort = adminhttpport
 	}
 
+	if enablemetrics, err := AppConfig.Bool("EnableMetrics"); err == nil {
+		EnableMetrics = enablemetrics
+	}
+
+	if metricspath := AppConfig.String("MetricsPath"); metricspath != "" {
+		MetricsPath = metricspath
+	}
+
+	if metricsonadmin, err := AppConfig.Bool("MetricsOnAdmin"); err == nil {
+		MetricsOnAdmin = metricsonadmin
+	}
+
+	if metricsinterval, err := AppConfig.Int64("MetricsInterval"); err == nil {
+		MetricsInterval = metricsinterval
+	}
+
+	if enableprobes, err := AppConfig.Bool("EnableProbes"); err == nil {
+		EnableProbes = enableprobes
+	}
+
+	if probesonadmin, err := AppConfig.Bool("ProbesOnAdmin"); err == nil {
+		ProbesOnAdmin = probesonadmin
+	}
+
+	if requestevents, err := AppConfig.Bool("RequestEvents"); err == nil {
+		RequestEvents = requestevents
+	}
+
 	if enabledocs, err := AppConfig.Bool("EnableDocs"); err == nil {
 		EnableDocs = enabledocs
 	}
 
+	if docsformat := AppConfig.String("DocsFormat"); docsformat != "" {
+		DocsFormat = docsformat
+	}
+
+	if docsui := AppConfig.String("DocsUI"); docsui != "" {
+		DocsUI = docsui
+	}
+
+	if docsuipath := AppConfig.String("DocsUIPath"); docsuipath != "" {
+		DocsUIPath = docsuipath
+	}
+
+	if docsuiassets := AppConfig.String("DocsUIAssets"); docsuiassets != "" {
+		DocsUIAssets = docsuiassets
+	}
+
+	if docsuser := AppConfig.String("DocsUser"); docsuser != "" {
+		DocsUser = docsuser
+	}
+
+	if docspassword := AppConfig.String("DocsPassword"); docspassword != "" {
+		DocsPassword = docspassword
+	}
+	if DocsUser != "" && DocsPassword == "" {
+		return fmt.Errorf("DocsUser is set without DocsPassword")
+	}
+
 	if casesensitive, err := AppConfig.Bool("RouterCaseSensitive"); err == nil {
 		RouterCaseSensitive = casesensitive
 	}
+
+	if routerscan, err := AppConfig.Bool("RouterScan"); err == nil {
+		RouterScan = routerscan
+	}
+
+	if policy := AppConfig.String("RouterTrailingSlash"); policy != "" {
+		if !validPolicy(policy) {
+			return fmt.Errorf("RouterTrailingSlash %q is not rewrite, strict or redirect", policy)
+		}
+		RouterTrailingSlash = policy
+	}
+
+	if policy := AppConfig.String("RouterPathCase"); policy != "" {
+		if !validPolicy(policy) {
+			return fmt.Errorf("RouterPathCase %q is not rewrite, strict or redirect", policy)
+		}
+		RouterPathCase = policy
+	}
+
+	EnableSecureHeaders = RunMode == "prod"
+	if enablesecure, err := AppConfig.Bool("EnableSecureHeaders"); err == nil {
+		EnableSecureHeaders = enablesecure
+	}
+
+	// slow clients can't hold the connections of a prod server
+	if RunMode == "prod" {
+		HttpReadHeaderTimeout = 10
+		HttpIdleTimeout = 120
+		HttpMaxHeaderBytes = 1 << 16
+	}
+	if timeout, err := AppConfig.Int64("HttpReadHeaderTimeout"); err == nil {
+		HttpReadHeaderTimeout = timeout
+	}
+	if timeout, err := AppConfig.Int64("HttpIdleTimeout"); err == nil {
+		HttpIdleTimeout = timeout
+	}
+	if maxheader, err := AppConfig.Int("HttpMaxHeaderBytes"); err == nil {
+		HttpMaxHeaderBytes = maxheader
+	}
 	return nil
 }
@@ -15,6 +15,10 @@
 package beego
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -27,3 +31,48 @@ func TestDefaults(t *testing.T) {
 		t.Errorf("FlashName was not set to default.")
 	}
 }
+
+func TestParseConfigXSRFMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path, addr, mode string) {
+		AppConfigPath, HttpAddr, XSRFMode = path, addr, mode
+	}(AppConfigPath, HttpAddr, XSRFMode)
+	defer func(c *beegoAppConfig) { AppConfig = c }(AppConfig)
+	AppConfigPath = filepath.Join(dir, "app.conf")
+
+	ioutil.WriteFile(AppConfigPath, []byte("XSRFMode = cookie\n"), 0600)
+	if err := ParseConfig(); err != nil || XSRFMode != "cookie" {
+		t.Errorf("cookie mode should be set, got %q %v", XSRFMode, err)
+	}
+	ioutil.WriteFile(AppConfigPath, []byte("XSRFMode = cookies\n"), 0600)
+	if err := ParseConfig(); err == nil || !strings.Contains(err.Error(), "XSRFMode") {
+		t.Errorf("an unknown XSRFMode should be rejected, got %v", err)
+	}
+}
+
+func TestParseConfigDocsPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path, addr, user, password string) {
+		AppConfigPath, HttpAddr, DocsUser, DocsPassword = path, addr, user, password
+	}(AppConfigPath, HttpAddr, DocsUser, DocsPassword)
+	defer func(c *beegoAppConfig) { AppConfig = c }(AppConfig)
+	AppConfigPath = filepath.Join(dir, "app.conf")
+
+	ioutil.WriteFile(AppConfigPath, []byte("DocsUser = admin\nDocsPassword = secret\n"), 0600)
+	if err := ParseConfig(); err != nil || DocsUser != "admin" || DocsPassword != "secret" {
+		t.Errorf("the docs basic auth should be set, got %q %q %v", DocsUser, DocsPassword, err)
+	}
+	DocsUser, DocsPassword = "", ""
+	ioutil.WriteFile(AppConfigPath, []byte("DocsUser = admin\n"), 0600)
+	if err := ParseConfig(); err == nil || !strings.Contains(err.Error(), "DocsPassword") {
+		t.Errorf("a DocsUser without DocsPassword should be rejected, got %v", err)
+	}
+}
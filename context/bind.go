@@ -0,0 +1,580 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BindError is the error of a field failing to bind, Field is the path of
+// the field, like address.zip, and Type its expected type. Value is the
+// value given, the kind of value for the json, like number.
+type BindError struct {
+	Field string
+	Value string
+	Type  string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	msg := "beego: cannot bind " + e.Field
+	if e.Type != "" {
+		msg += " of type " + e.Type
+	}
+	if e.Value != "" {
+		msg += " from " + strconv.Quote(e.Value)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors are the errors of the values failing to bind, sorted by field.
+type BindErrors []*BindError
+
+func (e BindErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, be := range e {
+		msgs[i] = strings.TrimPrefix(be.Error(), "beego: cannot bind ")
+	}
+	return "beego: cannot bind " + strings.Join(msgs, "; ")
+}
+
+func (e BindErrors) Len() int           { return len(e) }
+func (e BindErrors) Less(i, j int) bool { return e[i].Field < e[j].Field }
+func (e BindErrors) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// TimeLayouts are the layouts tried in order to parse a time.Time bound
+// from the request, when its field has no layout.
+var TimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isTextType reports whether the values of t are bound from a single text
+// value despite their kind, time.Time and the encoding.TextUnmarshalers.
+func isTextType(t reflect.Type) bool {
+	return t == timeType || t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// BindForm populates the struct dest points to from the query string and
+// the form values. the fields are named by their form tag, or by their
+// field name without tag, a tag "-" skips the field. the fields of a
+// nested struct are named with the prefix of the struct, the embedded
+// structs are flattened. the slices take the repeated values, name or
+// name[], the pointers are allocated when there are values for them and
+// time.Time is parsed with the layout following the name, with TimeLayouts
+// by default. the types implementing encoding.TextUnmarshaler, like
+// net.IP, are bound with their UnmarshalText. the values of a slice tagged
+// with split, or of all the slices with the SplitValues option, are split
+// at the commas, or at the separator of the option. the slices of structs
+// take the fields of their elements, items[0].qty or items.0.qty. all the
+// values failing to bind are returned as BindErrors, the other fields are
+// still bound.
+//	type Filter struct {
+//		Name  string    `form:"name"`
+//		Tags  []string  `form:"tag"`
+//		IDs   []int     `form:"ids,split"`
+//		Since time.Time `form:"since,2006-01-02"`
+//		Page  *struct {
+//			Size int `form:"size"`
+//		} `form:"page"`
+//	}
+//	/?name=a&tag=x&tag=y&ids=1,2,3&since=2015-01-02&page.size=20
+func (input *BeegoInput) BindForm(dest interface{}, options ...FormOption) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("beego: BindForm needs a non-nil struct pointer")
+	}
+	if input.Request.Form == nil {
+		if err := input.Request.ParseForm(); err != nil {
+			return err
+		}
+	}
+	b := formBinder{form: input.Request.Form}
+	for _, o := range options {
+		o(&b.formOptions)
+	}
+	b.bindStruct(v.Elem(), "")
+	if len(b.errs) > 0 {
+		sort.Sort(b.errs)
+		return b.errs
+	}
+	return nil
+}
+
+// FormOption is an option of BindForm and Bind.
+type FormOption func(*formOptions)
+
+type formOptions struct {
+	sep string // of SplitValues
+}
+
+// SplitValues splits the values of the slices at sep, to bind ?ids=1,2,3
+// like ?ids=1&ids=2&ids=3 for the clients encoding the arrays so. the
+// empty values between the separators are skipped.
+func SplitValues(sep string) FormOption {
+	return func(o *formOptions) {
+		o.sep = sep
+	}
+}
+
+// split returns the values of vals split at sep, vals without sep.
+func split(vals []string, sep string) []string {
+	if sep == "" {
+		return vals
+	}
+	var all []string
+	for _, val := range vals {
+		for _, v := range strings.Split(val, sep) {
+			if v != "" {
+				all = append(all, v)
+			}
+		}
+	}
+	return all
+}
+
+// JSONOption is an option of BindJSON.
+type JSONOption func(*json.Decoder)
+
+var (
+	// DisallowUnknownFields makes BindJSON fail on the json fields not
+	// matching a field of dest.
+	DisallowUnknownFields JSONOption = (*json.Decoder).DisallowUnknownFields
+	// UseNumber makes BindJSON decode the numbers of the interface{} fields
+	// as json.Number instead of float64.
+	UseNumber JSONOption = (*json.Decoder).UseNumber
+)
+
+// BindJSON decodes the json request body into dest, from RequestBody when
+// it's copied. a request with a Content-Type other than json fails with
+// ErrUnsupportedMediaType, the errors of a field are a *BindError, the malformed json
+// a *json.SyntaxError or io.ErrUnexpectedEOF.
+//	var user User
+//	if err := ctx.Input.BindJSON(&user, context.DisallowUnknownFields); err != nil {
+//		ctx.Output.SetStatus(400)
+//	}
+func (input *BeegoInput) BindJSON(dest interface{}, options ...JSONOption) error {
+	body, err := input.body("json")
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(body)
+	for _, o := range options {
+		o(dec)
+	}
+	if err := dec.Decode(dest); err != nil {
+		return jsonError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("beego: unexpected data after the json value")
+	}
+	return nil
+}
+
+// BindXML decodes the xml request body into dest like BindJSON, the
+// errors are the ones of encoding/xml.
+func (input *BeegoInput) BindXML(dest interface{}) error {
+	body, err := input.body("xml")
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(body)
+	if err := dec.Decode(dest); err != nil {
+		if err == io.EOF {
+			return errEmptyBody
+		}
+		return err
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.Comment, xml.ProcInst:
+			continue
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+		}
+		return errors.New("beego: unexpected data after the xml document")
+	}
+}
+
+// ErrUnsupportedMediaType is returned by the binding of a body which
+// Content-Type is not the one expected.
+var ErrUnsupportedMediaType = errors.New("beego: unsupported media type")
+
+var errEmptyBody = errors.New("beego: empty request body")
+
+// body returns the request body of the format, json or xml, checking its
+// Content-Type when there's one, like application/json or
+// application/problem+json.
+func (input *BeegoInput) body(format string) (io.Reader, error) {
+	if ct := input.Header("Content-Type"); ct != "" {
+		mediatype, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, ErrUnsupportedMediaType
+		}
+		if mediatype != "application/"+format && mediatype != "text/"+format && !strings.HasSuffix(mediatype, "+"+format) {
+			return nil, ErrUnsupportedMediaType
+		}
+	}
+	if input.RequestBody == nil && input.Request.Body == nil {
+		return nil, errEmptyBody
+	}
+	return input.Body(), nil
+}
+
+// jsonError converts the errors of the json decoder to BindErrors.
+func jsonError(err error) error {
+	switch e := err.(type) {
+	case *json.UnmarshalTypeError:
+		return &BindError{Field: e.Field, Value: e.Value, Type: e.Type.String(), Err: err}
+	case *json.SyntaxError, *json.InvalidUnmarshalError:
+		return err
+	}
+	if err == io.EOF {
+		return errEmptyBody
+	}
+	// the decoder has no error type for the unknown fields
+	if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+		field, _ := strconv.Unquote(strings.TrimPrefix(msg, "json: unknown field "))
+		return &BindError{Field: field, Err: errors.New("unknown field")}
+	}
+	return err
+}
+
+// formBinder binds the form values to the struct fields, collecting the
+// errors of the values failing to bind.
+type formBinder struct {
+	form map[string][]string
+	formOptions
+	errs BindErrors
+}
+
+// values returns the values of name, name[] included.
+func (b *formBinder) values(name string) []string {
+	vals := b.form[name]
+	if more := b.form[name+"[]"]; len(more) > 0 {
+		vals = append(vals[:len(vals):len(vals)], more...)
+	}
+	return vals
+}
+
+// has reports if there are values for name or the fields under it.
+func (b *formBinder) has(name string) bool {
+	if len(b.values(name)) > 0 {
+		return true
+	}
+	for k := range b.form {
+		if strings.HasPrefix(k, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *formBinder) bindStruct(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		tag := field.Tag.Get("form")
+		if tag == "-" || !fv.CanSet() && !field.Anonymous {
+			continue
+		}
+		name, layout, sep := tag, "", b.sep
+		if n := strings.Index(tag, ","); n >= 0 {
+			name, layout = tag[:n], tag[n+1:]
+		}
+		if layout == "split" || strings.HasPrefix(layout, "split,") {
+			layout, sep = strings.TrimPrefix(layout[len("split"):], ","), ","
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !isTextType(ft) {
+				if fv.Kind() == reflect.Ptr {
+					if !fv.CanSet() {
+						continue
+					}
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				b.bindStruct(fv, prefix)
+				continue
+			}
+			if !fv.CanSet() {
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		b.bindField(fv, prefix+name, layout, sep)
+	}
+}
+
+func (b *formBinder) bindField(v reflect.Value, name, layout, sep string) {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Ptr:
+		if !b.has(name) {
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		b.bindField(v.Elem(), name, layout, sep)
+		return
+	case t.Kind() == reflect.Struct && !isTextType(t):
+		b.bindStruct(v, name+".")
+		return
+	case t.Kind() == reflect.Slice && isStructType(t.Elem()):
+		b.bindStructs(v, name)
+		return
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !isTextType(t):
+		vals := split(b.values(name), sep)
+		if len(vals) == 0 {
+			return
+		}
+		s := reflect.MakeSlice(t, len(vals), len(vals))
+		for i, val := range vals {
+			if err := bindText(s.Index(i), val, layout); err != nil {
+				b.errs = append(b.errs, &BindError{Field: name, Value: val, Type: t.String(), Err: err})
+				return
+			}
+		}
+		v.Set(s)
+		return
+	}
+	vals := b.values(name)
+	if len(vals) == 0 || vals[0] == "" {
+		return
+	}
+	if err := bindText(v, vals[0], layout); err != nil {
+		b.errs = append(b.errs, &BindError{Field: name, Value: vals[0], Type: t.String(), Err: err})
+	}
+}
+
+// maxFormIndex bounds the index of the slices of structs bound from a form,
+// a larger index fails instead of allocating the slice.
+const maxFormIndex = 10000
+
+// bindStructs binds the slice of structs v from the fields of its elements,
+// name[0].field or name.0.field.
+func (b *formBinder) bindStructs(v reflect.Value, name string) {
+	t := v.Type()
+	prefixes := make(map[string]int)
+	last := -1
+	for key := range b.form {
+		index, prefix, ok := elementPrefix(key, name)
+		if !ok {
+			continue
+		}
+		if _, ok := prefixes[prefix]; ok {
+			continue
+		}
+		prefixes[prefix] = index
+		if index >= maxFormIndex {
+			b.errs = append(b.errs, &BindError{Field: strings.TrimSuffix(prefix, "."), Type: t.String(), Err: errors.New("index out of range")})
+			continue
+		}
+		if index > last {
+			last = index
+		}
+	}
+	if last < 0 {
+		return
+	}
+	s := reflect.MakeSlice(t, last+1, last+1)
+	for prefix, index := range prefixes {
+		if index >= maxFormIndex {
+			continue
+		}
+		el := s.Index(index)
+		if el.Kind() == reflect.Ptr {
+			if el.IsNil() {
+				el.Set(reflect.New(t.Elem().Elem()))
+			}
+			el = el.Elem()
+		}
+		b.bindStruct(el, prefix)
+	}
+	v.Set(s)
+}
+
+// elementPrefix returns the index and the field prefix of the slice element
+// named by key, items[0]. or items.0. for the keys items[0].qty and items.0.qty.
+func elementPrefix(key, name string) (int, string, bool) {
+	var end int
+	switch {
+	case strings.HasPrefix(key, name+"["):
+		n := strings.Index(key[len(name):], "].")
+		if n < 0 {
+			return 0, "", false
+		}
+		end = len(name) + n + 2
+		key = key[:end]
+		n, err := strconv.Atoi(key[len(name)+1 : end-2])
+		return n, key, err == nil && n >= 0
+	case strings.HasPrefix(key, name+"."):
+		n := strings.Index(key[len(name)+1:], ".")
+		if n < 0 {
+			return 0, "", false
+		}
+		end = len(name) + 1 + n + 1
+		key = key[:end]
+		n, err := strconv.Atoi(key[len(name)+1 : end-1])
+		return n, key, err == nil && n >= 0
+	}
+	return 0, "", false
+}
+
+// isStructType reports whether t, or the type t points to, is a struct
+// bound field by field.
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isTextType(t)
+}
+
+// bindText sets v from the text s, a time.Time with layout.
+func bindText(v reflect.Value, s, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		x, err := parseTime(s, layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(x))
+		return nil
+	}
+	if isTextType(v.Type()) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "on", "yes":
+			v.SetBool(true)
+		case "off", "no":
+			v.SetBool(false)
+		default:
+			x, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(x)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		x, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return errors.New("unsupported type")
+		}
+		v.SetBytes([]byte(s))
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return errors.New("unsupported type")
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return errors.New("unsupported type")
+	}
+	return nil
+}
+
+// parseTime parses s with layout, or with the first of TimeLayouts that
+// matches without layout.
+func parseTime(s, layout string) (t time.Time, err error) {
+	if layout != "" {
+		return time.Parse(layout, s)
+	}
+	for _, layout := range TimeLayouts {
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if err == nil {
+		err = errors.New("no time layout")
+	}
+	return t, err
+}
@@ -0,0 +1,255 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindPage struct {
+	Size int `form:"size"`
+}
+
+type bindAudit struct {
+	By string `form:"by"`
+}
+
+type bindFilter struct {
+	bindAudit
+	Name    string        `form:"name"`
+	Tags    []string      `form:"tag"`
+	IDs     []int64       `form:"id"`
+	Since   time.Time     `form:"since,2006-01-02"`
+	Until   *time.Time    `form:"until"`
+	Active  *bool         `form:"active"`
+	Limit   *int          `form:"limit"`
+	Page    *bindPage     `form:"page"`
+	Sort    bindPage      `form:"sort"`
+	Wait    time.Duration `form:"wait"`
+	Day     *time.Time    `form:"day"`
+	From    net.IP        `form:"from"`
+	Title   string
+	Ignored string `form:"-"`
+	private string
+}
+
+func TestBindForm(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/?name=a&tag=x&tag=y&id[]=1&id[]=2&since=2015-01-02"+
+		"&until=2015-02-03T04:05:06Z&active=on&page.size=20&wait=1s&day=2015-03-04&from=10.0.0.1"+
+		"&Title=t&Ignored=i&private=p&by=me",
+		strings.NewReader("tag=z"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var f bindFilter
+	if err := NewInput(r).BindForm(&f); err != nil {
+		t.Fatal(err)
+	}
+	until := time.Date(2015, 2, 3, 4, 5, 6, 0, time.UTC)
+	day := time.Date(2015, 3, 4, 0, 0, 0, 0, time.UTC)
+	active := true
+	want := bindFilter{
+		bindAudit: bindAudit{By: "me"},
+		Name:      "a",
+		Tags:      []string{"z", "x", "y"},
+		IDs:       []int64{1, 2},
+		Since:     time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC),
+		Until:     &until,
+		Active:    &active,
+		Page:      &bindPage{Size: 20},
+		Wait:      time.Second,
+		Day:       &day,
+		From:      net.ParseIP("10.0.0.1"),
+		Title:     "t",
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("got %+v, want %+v", f, want)
+	}
+}
+
+func TestBindFormSplit(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?ids=1,2&ids=3&tags=a,b&days=2015-01-02,2015-01-03", nil)
+	var f struct {
+		IDs  []int       `form:"ids,split"`
+		Tags []string    `form:"tags"`
+		Days []time.Time `form:"days,split,2006-01-02"`
+	}
+	if err := NewInput(r).BindForm(&f); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.IDs, []int{1, 2, 3}) || !reflect.DeepEqual(f.Tags, []string{"a,b"}) ||
+		len(f.Days) != 2 || f.Days[1].Day() != 3 {
+		t.Errorf("got %+v, want the tagged slices split", f)
+	}
+	if err := NewInput(r).BindForm(&f, SplitValues(",")); err != nil || !reflect.DeepEqual(f.Tags, []string{"a", "b"}) {
+		t.Errorf("got %v %v, want every slice split", f.Tags, err)
+	}
+}
+
+func TestBindFormErrors(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?page.size=big", nil)
+	var f bindFilter
+	err := NewInput(r).BindForm(&f)
+	errs, ok := err.(BindErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("got %v, want BindErrors", err)
+	}
+	if be := errs[0]; be.Field != "page.size" || be.Value != "big" || be.Type != "int" {
+		t.Errorf("wrong error: %+v", be)
+	}
+
+	r, _ = http.NewRequest("GET", "/?name=a&limit=x&page.size=big&wait=long", nil)
+	f = bindFilter{}
+	errs, _ = NewInput(r).BindForm(&f).(BindErrors)
+	if len(errs) != 3 || errs[0].Field != "limit" || errs[1].Field != "page.size" || errs[2].Field != "wait" {
+		t.Errorf("got %v, want the errors of every field sorted", errs)
+	}
+	if f.Name != "a" {
+		t.Error("the other fields should still be bound")
+	}
+	if err := NewInput(r).BindForm(f); err == nil {
+		t.Error("a non-pointer should fail")
+	}
+}
+
+func TestBindFormStructSlices(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?order=7&items[0].sku=a&items[0].qty=1&items.1.sku=b&items.1.qty=2"+
+		"&items[3].qty=4&extras[1].sku=c&items[2].qty=many", nil)
+	type item struct {
+		SKU string `form:"sku"`
+		Qty int    `form:"qty"`
+	}
+	var f struct {
+		Order  int     `form:"order"`
+		Items  []item  `form:"items"`
+		Extras []*item `form:"extras"`
+	}
+	err := NewInput(r).BindForm(&f)
+	errs, ok := err.(BindErrors)
+	if !ok || len(errs) != 1 || errs[0].Field != "items[2].qty" {
+		t.Errorf("got %v, want the error of items[2].qty", err)
+	}
+	want := []item{{"a", 1}, {"b", 2}, {}, {"", 4}}
+	if f.Order != 7 || !reflect.DeepEqual(f.Items, want) {
+		t.Errorf("got %+v, want the items by index", f)
+	}
+	if len(f.Extras) != 2 || f.Extras[0] != nil || f.Extras[1].SKU != "c" {
+		t.Errorf("got %+v, want the pointers allocated for their indexes", f.Extras)
+	}
+
+	r, _ = http.NewRequest("GET", "/?items[99999999].qty=1", nil)
+	f.Items = nil
+	if err := NewInput(r).BindForm(&f); err == nil || f.Items != nil {
+		t.Errorf("got %v, want a huge index refused", err)
+	}
+}
+
+type bindUser struct {
+	Name    string `json:"name"`
+	Address struct {
+		Zip int `json:"zip"`
+	} `json:"address"`
+}
+
+func TestBindJSON(t *testing.T) {
+	newInput := func(body string) *BeegoInput {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		return NewInput(r)
+	}
+	var u bindUser
+	if err := newInput(`{"name":"a","address":{"zip":1},"extra":1}`).BindJSON(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "a" || u.Address.Zip != 1 {
+		t.Errorf("wrong user: %+v", u)
+	}
+	input := newInput("")
+	input.RequestBody = []byte(`{"name":"copied"}`)
+	if err := input.BindJSON(&u); err != nil || u.Name != "copied" {
+		t.Errorf("got %+v %v, want the copied body", u, err)
+	}
+
+	err := newInput(`{"address":{"zip":"75001"}}`).BindJSON(&u)
+	if be, ok := err.(*BindError); !ok || be.Field != "address.zip" || be.Type != "int" || be.Value != "string" {
+		t.Errorf("got %#v, want a BindError of address.zip", err)
+	}
+	err = newInput(`{"name":"a","extra":1}`).BindJSON(&u, DisallowUnknownFields)
+	if be, ok := err.(*BindError); !ok || be.Field != "extra" {
+		t.Errorf("got %#v, want a BindError of the unknown field", err)
+	}
+	if err := newInput(`{"name":`).BindJSON(&u); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if _, ok := newInput(`{"name":]`).BindJSON(&u).(*json.SyntaxError); !ok {
+		t.Error("want a syntax error")
+	}
+	if err := newInput(`{} {}`).BindJSON(&u); err == nil {
+		t.Error("the data after the json value should fail")
+	}
+	if err := newInput("").BindJSON(&u); err != errEmptyBody {
+		t.Errorf("got %v, want errEmptyBody", err)
+	}
+	input = newInput(`{}`)
+	input.Request.Header.Set("Content-Type", "text/plain")
+	if err := input.BindJSON(&u); err != ErrUnsupportedMediaType {
+		t.Errorf("got %v, want ErrUnsupportedMediaType", err)
+	}
+	input = newInput(`{"name":"problem"}`)
+	input.Request.Header.Set("Content-Type", "application/problem+json; charset=utf-8")
+	if err := input.BindJSON(&u); err != nil || u.Name != "problem" {
+		t.Errorf("got %+v %v, want a +json body bound", u, err)
+	}
+}
+
+type bindOrder struct {
+	ID    int      `xml:"id,attr"`
+	Items []string `xml:"item"`
+}
+
+func TestBindXML(t *testing.T) {
+	newInput := func(contentType, body string) *BeegoInput {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return NewInput(r)
+	}
+	var o bindOrder
+	input := newInput("text/xml; charset=utf-8", `<?xml version="1.0"?><order id="7"><item>a</item><item>b</item></order>
+<!-- end -->`)
+	if err := input.BindXML(&o); err != nil {
+		t.Fatal(err)
+	}
+	if o.ID != 7 || !reflect.DeepEqual(o.Items, []string{"a", "b"}) {
+		t.Errorf("wrong order: %+v", o)
+	}
+	input = newInput("application/soap+xml", "")
+	input.RequestBody = []byte(`<order id="8"></order>`)
+	if err := input.BindXML(&o); err != nil || o.ID != 8 {
+		t.Errorf("got %+v %v, want the copied body", o, err)
+	}
+	if err := newInput("application/json", `<order/>`).BindXML(&o); err != ErrUnsupportedMediaType {
+		t.Errorf("got %v, want ErrUnsupportedMediaType", err)
+	}
+	if err := newInput("application/xml", `<order id="x"/>`).BindXML(&o); err == nil {
+		t.Error("a bad attribute should fail")
+	}
+	if err := newInput("application/xml", `<order/><order/>`).BindXML(&o); err == nil {
+		t.Error("the data after the document should fail")
+	}
+}
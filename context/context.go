@@ -22,9 +22,13 @@
 package context
 
 import (
+	gocontext "context"
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -42,6 +46,57 @@ type Context struct {
 	Request        *http.Request
 	ResponseWriter http.ResponseWriter
 	_xsrf_token    string
+	defers         []func()
+}
+
+// NewContext returns a context with its input and output, made for a request
+// by Reset.
+func NewContext() *Context {
+	return &Context{Input: NewInput(nil), Output: NewOutput()}
+}
+
+// Reset makes ctx the context of the request r answered by rw.
+func (ctx *Context) Reset(rw http.ResponseWriter, r *http.Request) {
+	ctx.Request = r
+	ctx.ResponseWriter = rw
+	ctx.Input.Reset(r)
+	ctx.Output.Reset(ctx)
+	ctx._xsrf_token = ""
+	ctx.defers = ctx.defers[:0]
+}
+
+// Context returns the context of the request, canceled when the client
+// goes away, the request times out or the request is handled. the long
+// running work of the handlers should stop with it:
+//	o := orm.NewOrmWithContext(ctx.Context())
+//	httplib.Get(url).WithContext(ctx.Context()).String()
+func (ctx *Context) Context() gocontext.Context {
+	if ctx.Request == nil {
+		return gocontext.Background()
+	}
+	return ctx.Request.Context()
+}
+
+// SetContext replaces the context of the request, of ctx.Request and
+// ctx.Input.Request, like for a deadline or the values of a filter.
+func (ctx *Context) SetContext(c gocontext.Context) {
+	ctx.Request = ctx.Request.WithContext(c)
+	ctx.Input.Request = ctx.Request
+}
+
+// Defer registers f to run when the request has been handled, also after a panic.
+// like the defer statement, the functions run in reverse order.
+func (ctx *Context) Defer(f func()) {
+	ctx.defers = append(ctx.defers, f)
+}
+
+// RunDefers runs the functions registered by Defer, it is called by the router.
+func (ctx *Context) RunDefers() {
+	for len(ctx.defers) > 0 {
+		f := ctx.defers[len(ctx.defers)-1]
+		ctx.defers = ctx.defers[:len(ctx.defers)-1]
+		f()
+	}
 }
 
 // Redirect does redirection to localurl with http header status code.
@@ -148,3 +203,64 @@ func (ctx *Context) CheckXsrfCookie() bool {
 	}
 	return true
 }
+
+// DoubleSubmitToken returns the csrf token of the double submit cookie.
+// when the cookie is missing or not signed with key, a new token is set in
+// a cookie readable by javascript, so single page apps can echo it in a header.
+func (ctx *Context) DoubleSubmitToken(key, cookieName string, expire int64) string {
+	if ctx._xsrf_token == "" {
+		token := ctx.Input.Cookie(cookieName)
+		if !validDoubleSubmit(key, token) {
+			token = signDoubleSubmit(key, string(utils.RandomCreateBytes(32)))
+			ctx.Output.Cookie(cookieName, token, expire, "/", "", ctx.Input.IsSecure(), false)
+		}
+		ctx._xsrf_token = token
+	}
+	return ctx._xsrf_token
+}
+
+// CheckDoubleSubmit checks the token sent in header headerName, or in form
+// field "_xsrf", equals the signed double submit cookie. it keeps no server side state.
+func (ctx *Context) CheckDoubleSubmit(key, cookieName, headerName string) bool {
+	token := ctx.Request.Header.Get(headerName)
+	if token == "" {
+		token = ctx.Input.Query("_xsrf")
+	}
+	if token == "" {
+		ctx.Abort(403, "'"+headerName+"' header missing")
+		return false
+	}
+	cookie := ctx.Input.Cookie(cookieName)
+	if !validDoubleSubmit(key, cookie) || subtle.ConstantTimeCompare([]byte(cookie), []byte(token)) != 1 {
+		ctx.Abort(403, "XSRF cookie does not match header")
+		return false
+	}
+	return true
+}
+
+func signDoubleSubmit(key, token string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(token))
+	return token + "." + hex.EncodeToString(h.Sum(nil))
+}
+
+func validDoubleSubmit(key, value string) bool {
+	i := strings.LastIndex(value, ".")
+	if i <= 0 {
+		return false
+	}
+	return hmac.Equal([]byte(value), []byte(signDoubleSubmit(key, value[:i])))
+}
+
+// userKey is the Input.Data key of the authenticated user.
+const userKey = "_beego_user"
+
+// User returns the authenticated user set by an auth filter, or nil.
+func (ctx *Context) User() interface{} {
+	return ctx.Input.GetData(userKey)
+}
+
+// SetUser stores the authenticated user of this request.
+func (ctx *Context) SetUser(user interface{}) {
+	ctx.Input.SetData(userKey, user)
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagMode is the kind of the ETags computed by Body, see BeegoOutput.ETag.
+type ETagMode int
+
+const (
+	// NoETag computes no ETag, the default.
+	NoETag ETagMode = iota
+	// StrongETag computes an ETag of the bytes of the body.
+	StrongETag
+	// WeakETag computes a weak ETag, W/"...", for the caches allowed to
+	// transform the body.
+	WeakETag
+)
+
+// notModified sets the ETag of the json, xml or html body content of a GET
+// or HEAD request, unless it's set already, and answers 304 without body
+// when the request has it already, by If-None-Match, or without
+// If-None-Match by If-Modified-Since and the Last-Modified header. it
+// reports whether the 304 was sent.
+func (output *BeegoOutput) notModified(content []byte) bool {
+	if output.ETag == NoETag || output.Status != 0 && output.Status != http.StatusOK {
+		return false
+	}
+	if method := output.Context.Input.Method(); method != "GET" && method != "HEAD" {
+		return false
+	}
+	h := output.Context.ResponseWriter.Header()
+	if !etagType(h.Get("Content-Type")) {
+		return false
+	}
+	etag := h.Get("ETag")
+	if etag == "" {
+		etag = BodyETag(content)
+		// the strong ETags differ by encoding
+		if encoding := h.Get("Content-Encoding"); encoding != "" {
+			etag = etag[:len(etag)-1] + "-" + encoding + `"`
+		}
+		if output.ETag == WeakETag {
+			etag = "W/" + etag
+		}
+		h.Set("ETag", etag)
+	}
+	if !notModified(output.Context.Input, etag, h.Get("Last-Modified")) {
+		return false
+	}
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	output.Context.ResponseWriter.WriteHeader(http.StatusNotModified)
+	output.Status = 0
+	return true
+}
+
+// etagType reports whether the responses of the content type ct get an
+// ETag, the json, the xml and the html.
+func etagType(ct string) bool {
+	t, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return t == "text/html" || strings.HasSuffix(t, "/json") || strings.HasSuffix(t, "+json") ||
+		strings.HasSuffix(t, "/xml") || strings.HasSuffix(t, "+xml")
+}
+
+// notModified reports whether the request has the response of etag and
+// lastModified already.
+func notModified(input *BeegoInput, etag, lastModified string) bool {
+	if inm := input.Header("If-None-Match"); inm != "" {
+		return ETagMatch(inm, etag)
+	}
+	ims := input.Header("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	return err == nil && !modified.Truncate(time.Second).After(since)
+}
+
+// BodyETag returns the strong ETag of body, quoted.
+func BodyETag(body []byte) string {
+	sum := sha1.Sum(body)
+	return `"` + hex.EncodeToString(sum[:10]) + `"`
+}
+
+// ETagMatch compares If-None-Match with the ETag weakly, as RFC 7232 asks
+// for GET. an empty header or ETag never matches.
+func ETagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
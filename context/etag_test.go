@@ -0,0 +1,69 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	body := func(method, ct string, status int, mode ETagMode, header ...string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "/", nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		ctx := NewContext()
+		ctx.Reset(w, r)
+		ctx.Output.ETag = mode
+		ctx.Output.Status = status
+		ctx.Output.Header("Content-Type", ct)
+		ctx.Output.Body([]byte("<p>hello</p>"))
+		return w
+	}
+	etag := body("GET", "text/html; charset=utf-8", 0, StrongETag).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("the html response should get an ETag")
+	}
+	cases := []struct {
+		method, ct string
+		status     int
+		mode       ETagMode
+		etag       bool
+	}{
+		{"GET", "application/problem+json", 0, StrongETag, true},
+		{"HEAD", "text/xml", http.StatusOK, WeakETag, true},
+		{"GET", "text/html", 0, NoETag, false},
+		{"POST", "text/html", 0, StrongETag, false},
+		{"GET", "text/html", http.StatusNotFound, StrongETag, false},
+		{"GET", "image/png", 0, StrongETag, false},
+	}
+	for _, c := range cases {
+		w := body(c.method, c.ct, c.status, c.mode)
+		if got := w.Header().Get("ETag") != ""; got != c.etag {
+			t.Errorf("%s %s %d: got the ETag %v, want %v", c.method, c.ct, c.status, got, c.etag)
+		}
+	}
+
+	w := body("GET", "text/html", 0, StrongETag, "If-None-Match", "W/"+etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Errorf("got %d %q, want 304 without body", w.Code, w.Body.String())
+	}
+	if w := body("GET", "text/html", 0, StrongETag, "If-None-Match", "*"); w.Code != http.StatusNotModified {
+		t.Errorf("got %d, want 304 for If-None-Match *", w.Code)
+	}
+}
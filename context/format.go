@@ -0,0 +1,250 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Format is a serialization of the data bound by Context.Bind and served by
+// BeegoOutput.Format, the first media type is the Content-Type of the
+// responses.
+type Format struct {
+	MediaTypes []string
+	Marshal    func(v interface{}) ([]byte, error)
+	Unmarshal  func(data []byte, v interface{}) error
+}
+
+var (
+	formatLock  sync.RWMutex
+	formats     = make(map[string]Format)
+	formatNames []string
+)
+
+func init() {
+	RegisterFormat("json", Format{
+		MediaTypes: []string{"application/json", "text/json"},
+		Marshal:    json.Marshal,
+		Unmarshal:  json.Unmarshal,
+	})
+	RegisterFormat("xml", Format{
+		MediaTypes: []string{"application/xml", "text/xml"},
+		Marshal:    xml.Marshal,
+		Unmarshal:  xml.Unmarshal,
+	})
+}
+
+// RegisterFormat adds the format name, or replaces it. json and xml are
+// registered, yaml can be with the yaml package of the app:
+//	context.RegisterFormat("yaml", context.Format{
+//		MediaTypes: []string{"application/yaml", "application/x-yaml", "text/yaml"},
+//		Marshal:    yaml.Marshal,
+//		Unmarshal:  yaml.Unmarshal,
+//	})
+func RegisterFormat(name string, f Format) {
+	if len(f.MediaTypes) == 0 || f.Marshal == nil || f.Unmarshal == nil {
+		panic("format " + name + " needs media types, Marshal and Unmarshal")
+	}
+	formatLock.Lock()
+	defer formatLock.Unlock()
+	if _, ok := formats[name]; !ok {
+		formatNames = append(formatNames, name)
+	}
+	formats[name] = f
+}
+
+// FormatOf returns the name of the format of the media type, a type with
+// the suffix of a format included, like application/problem+json. it's
+// empty for the unknown types.
+func FormatOf(mediatype string) string {
+	formatLock.RLock()
+	defer formatLock.RUnlock()
+	for _, name := range formatNames {
+		for _, t := range formats[name].MediaTypes {
+			if t == mediatype {
+				return name
+			}
+		}
+	}
+	if n := strings.LastIndex(mediatype, "+"); n >= 0 {
+		if _, ok := formats[mediatype[n+1:]]; ok {
+			return mediatype[n+1:]
+		}
+	}
+	return ""
+}
+
+// FormatMediaTypes returns the media types of the formats for Negotiate,
+// the ones of the format first before the others, in the order of
+// registration.
+func FormatMediaTypes(first string) []string {
+	formatLock.RLock()
+	defer formatLock.RUnlock()
+	types := append([]string(nil), formats[first].MediaTypes...)
+	for _, name := range formatNames {
+		if name != first {
+			types = append(types, formats[name].MediaTypes...)
+		}
+	}
+	return types
+}
+
+// qValue is an item of the headers weighted by quality values, like Accept.
+type qValue struct {
+	value string
+	q     float64
+}
+
+// parseQValues parses the items of h in their order, without their
+// parameters but q. the malformed qualities are 0.
+func parseQValues(h string) []qValue {
+	var values []qValue
+	for _, item := range strings.Split(h, ",") {
+		params := strings.Split(item, ";")
+		v := qValue{value: strings.TrimSpace(params[0]), q: 1}
+		if v.value == "" {
+			continue
+		}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") || strings.HasPrefix(p, "Q=") {
+				q, err := strconv.ParseFloat(p[2:], 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				v.q = q
+			}
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// Negotiate returns the media type of offers preferred by the Accept
+// header, the first one of the best quality, the most specific range of
+// the header giving the quality of an offer. it's the first offer without
+// Accept header and empty when none is acceptable.
+//	ctx.Input.Negotiate("application/json", "text/html")
+func (input *BeegoInput) Negotiate(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accept := input.Header("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+	ranges := parseQValues(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := mediaRangeMatch(strings.ToLower(r.value), offer)
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaRangeMatch returns the specificity of the media range r matching t,
+// -1 when it doesn't.
+func mediaRangeMatch(r, t string) int {
+	t = strings.ToLower(t)
+	switch {
+	case r == t:
+		return 2
+	case r == "*/*":
+		return 0
+	case strings.HasSuffix(r, "/*") && strings.HasPrefix(t, r[:len(r)-1]):
+		return 1
+	}
+	return -1
+}
+
+// Bind binds the request to obj by its Content-Type: the form and query
+// values without body or for the forms, see BeegoInput.BindForm, and the
+// body of the registered formats else, see BindJSON and BindXML. the other
+// types fail with ErrUnsupportedMediaType.
+func (ctx *Context) Bind(obj interface{}) error {
+	ct := ctx.Input.Header("Content-Type")
+	if ct == "" {
+		return ctx.Input.BindForm(obj)
+	}
+	mediatype, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ErrUnsupportedMediaType
+	}
+	switch mediatype {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return ctx.Input.BindForm(obj)
+	}
+	switch name := FormatOf(mediatype); name {
+	case "json":
+		return ctx.Input.BindJSON(obj)
+	case "xml":
+		return ctx.Input.BindXML(obj)
+	case "":
+		return ErrUnsupportedMediaType
+	default:
+		formatLock.RLock()
+		f := formats[name]
+		formatLock.RUnlock()
+		data, err := ioutil.ReadAll(ctx.Input.Body())
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return errEmptyBody
+		}
+		return f.Unmarshal(data, obj)
+	}
+}
+
+// Format writes data in the format name to the response body, json and xml
+// are written by Json and Xml.
+func (output *BeegoOutput) Format(name string, data interface{}, hasIndent bool) error {
+	switch name {
+	case "json":
+		return output.Json(data, hasIndent, false)
+	case "xml":
+		return output.Xml(data, hasIndent)
+	}
+	formatLock.RLock()
+	f, ok := formats[name]
+	formatLock.RUnlock()
+	if !ok {
+		return errors.New("beego: unknown format " + name)
+	}
+	content, err := f.Marshal(data)
+	if err != nil {
+		http.Error(output.Context.ResponseWriter, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	output.Header("Content-Type", f.MediaTypes[0]+"; charset=utf-8")
+	output.Body(content)
+	return nil
+}
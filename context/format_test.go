@@ -0,0 +1,121 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", []string{"application/json", "text/html"}, "application/json"},
+		{"*/*", []string{"application/json", "text/html"}, "application/json"},
+		{"text/html,application/xml;q=0.9,*/*;q=0.8", []string{"application/json", "application/xml", "text/html"}, "text/html"},
+		{"application/xml;q=0.5, application/json", []string{"application/xml", "application/json"}, "application/json"},
+		{"application/*;q=0.5, text/xml", []string{"application/json", "text/xml"}, "text/xml"},
+		{"application/*, application/json;q=0", []string{"application/json", "application/xml"}, "application/xml"},
+		{"image/png", []string{"application/json"}, ""},
+		{"text/html;level=1;q=0.2, application/json;q=bad", []string{"application/json", "text/html"}, "text/html"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := NewInput(r).Negotiate(tt.offers...); got != tt.want {
+			t.Errorf("Negotiate(%q, %v) = %q, want %q", tt.accept, tt.offers, got, tt.want)
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for mediatype, want := range map[string]string{
+		"application/json":         "json",
+		"text/xml":                 "xml",
+		"application/problem+json": "json",
+		"application/atom+xml":     "xml",
+		"text/plain":               "",
+	} {
+		if got := FormatOf(mediatype); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", mediatype, got, want)
+		}
+	}
+	types := FormatMediaTypes("xml")
+	if types[0] != "application/xml" || types[2] != "application/json" {
+		t.Errorf("wrong media types: %v", types)
+	}
+}
+
+func TestContextBind(t *testing.T) {
+	RegisterFormat("csv", Format{
+		MediaTypes: []string{"text/csv"},
+		Marshal: func(v interface{}) ([]byte, error) {
+			return []byte(strings.Join(v.([]string), ",")), nil
+		},
+		Unmarshal: func(data []byte, v interface{}) error {
+			*v.(*[]string) = strings.Split(string(data), ",")
+			return nil
+		},
+	})
+	defer func() {
+		delete(formats, "csv")
+		formatNames = formatNames[:len(formatNames)-1]
+	}()
+	newContext := func(contentType, body string) *Context {
+		r, _ := http.NewRequest("POST", "/?Name=query", strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		ctx := NewContext()
+		ctx.Reset(httptest.NewRecorder(), r)
+		return ctx
+	}
+	var u bindUser
+	for contentType, body := range map[string]string{
+		"":                                  "",
+		"application/x-www-form-urlencoded": "Name=form",
+		"application/json; charset=utf-8":   `{"name":"json"}`,
+	} {
+		u = bindUser{}
+		if err := newContext(contentType, body).Bind(&u); err != nil || u.Name == "" {
+			t.Errorf("%q: got %+v %v", contentType, u, err)
+		}
+	}
+	var o bindOrder
+	if err := newContext("application/xml", `<order id="3"/>`).Bind(&o); err != nil || o.ID != 3 {
+		t.Errorf("xml: got %+v %v", o, err)
+	}
+	var fields []string
+	if err := newContext("text/csv", "a,b").Bind(&fields); err != nil || len(fields) != 2 {
+		t.Errorf("csv: got %v %v", fields, err)
+	}
+	if err := newContext("text/plain", "a").Bind(&u); err != ErrUnsupportedMediaType {
+		t.Errorf("got %v, want ErrUnsupportedMediaType", err)
+	}
+
+	ctx := newContext("", "")
+	w := ctx.ResponseWriter.(*httptest.ResponseRecorder)
+	if err := ctx.Output.Format("csv", []string{"x", "y"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.String() != "x,y" || w.HeaderMap.Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Errorf("got %q %q", w.Body.String(), w.HeaderMap.Get("Content-Type"))
+	}
+}
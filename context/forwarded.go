@@ -0,0 +1,154 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	proxyLock      sync.RWMutex
+	trustedProxies []*net.IPNet
+)
+
+// SetTrustedProxies sets the proxies whose X-Forwarded-Proto,
+// X-Forwarded-Port and X-Forwarded-For headers are used by Scheme, Port and
+// ClientIP, ips or CIDR ranges. the headers are ignored for the other
+// clients, which could spoof them.
+func SetTrustedProxies(addrs []string) error {
+	var list []string
+	for _, a := range addrs {
+		if a = strings.TrimSpace(a); a != "" {
+			list = append(list, a)
+		}
+	}
+	nets, err := ParseNetworks(list)
+	if err != nil {
+		return err
+	}
+	proxyLock.Lock()
+	trustedProxies = nets
+	proxyLock.Unlock()
+	return nil
+}
+
+// ParseNetworks parses ips and CIDR ranges, a single ip is a network of one address.
+func ParseNetworks(addrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(addrs))
+	for _, a := range addrs {
+		a = strings.TrimSpace(a)
+		if !strings.Contains(a, "/") {
+			if strings.Contains(a, ":") {
+				a += "/128"
+			} else {
+				a += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// FromTrustedProxy reports if the request comes from a trusted proxy, see
+// SetTrustedProxies.
+func (input *BeegoInput) FromTrustedProxy() bool {
+	return TrustedProxy(input.Request.RemoteAddr)
+}
+
+// TrustedProxy reports if addr, a host or host:port, is a trusted proxy,
+// for the code handling a plain *http.Request. see SetTrustedProxies.
+func TrustedProxy(addr string) bool {
+	ip := remoteIP(addr)
+	return ip != nil && trustedIP(ip)
+}
+
+// ClientIP returns the ip of the client of r. X-Forwarded-For is read from
+// right to left as long as the hops are trusted proxies, so clients can't
+// spoof it. it's nil when RemoteAddr is not an ip.
+func ClientIP(r *http.Request) net.IP {
+	ip := remoteIP(r.RemoteAddr)
+	if ip == nil || !trustedIP(ip) {
+		return ip
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trustedIP(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// ClientIP returns the ip of the client resolved through the trusted
+// proxies, see ClientIP. unlike IP it can't be spoofed by the clients.
+func (input *BeegoInput) ClientIP() string {
+	if ip := ClientIP(input.Request); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+func remoteIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+func trustedIP(ip net.IP) bool {
+	proxyLock.RLock()
+	defer proxyLock.RUnlock()
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwarded returns the value of the header set by the trusted proxies for
+// the client. the proxies append their value, so it's read from right to
+// left like X-Forwarded-For by ClientIP: one value per trusted hop, the
+// values left of them were sent by the client and are ignored.
+func (input *BeegoInput) forwarded(header string) string {
+	v := input.Header(header)
+	if v == "" || !input.FromTrustedProxy() {
+		return ""
+	}
+	values := strings.Split(v, ",")
+	i := len(values) - 1
+	hops := strings.Split(input.Header("X-Forwarded-For"), ",")
+	for j := len(hops) - 1; j >= 0 && i > 0; j-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[j]))
+		if hop == nil || !trustedIP(hop) {
+			break
+		}
+		i--
+	}
+	return strings.TrimSpace(values[i])
+}
@@ -0,0 +1,139 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestForwardedSchemeAndPort(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "::1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies(nil)
+	tests := []struct {
+		remote, host, proto, port string
+		tls                       bool
+		scheme                    string
+		wantPort                  int
+		site                      string
+	}{
+		{"1.2.3.4:5000", "example.com", "", "", false, "http", 80, "http://example.com"},
+		{"1.2.3.4:5000", "example.com", "", "", true, "https", 443, "https://example.com"},
+		{"1.2.3.4:5000", "example.com:8080", "", "", false, "http", 8080, "http://example.com:8080"},
+		// the headers of the untrusted clients are ignored
+		{"1.2.3.4:5000", "example.com", "https", "8443", false, "http", 80, "http://example.com"},
+		{"10.0.0.2:5000", "example.com", "https", "", false, "https", 443, "https://example.com"},
+		{"10.0.0.2:5000", "example.com:80", "https", "443", false, "https", 443, "https://example.com"},
+		// the values sent by the client left of the one of the proxy are ignored
+		{"[::1]:5000", "example.com", "http, HTTPS", "80, 8443", false, "https", 8443, "https://example.com:8443"},
+		{"10.0.0.2:5000", "example.com", "gopher", "x", false, "http", 80, "http://example.com"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.URL.Scheme = ""
+		r.RemoteAddr = tt.remote
+		r.Host = tt.host
+		if tt.proto != "" {
+			r.Header.Set("X-Forwarded-Proto", tt.proto)
+		}
+		if tt.port != "" {
+			r.Header.Set("X-Forwarded-Port", tt.port)
+		}
+		if tt.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		input := NewInput(r)
+		if s := input.Scheme(); s != tt.scheme {
+			t.Errorf("%+v: got scheme %q, want %q", tt, s, tt.scheme)
+		}
+		if p := input.Port(); p != tt.wantPort {
+			t.Errorf("%+v: got port %d, want %d", tt, p, tt.wantPort)
+		}
+		if s := input.Site(); s != tt.site {
+			t.Errorf("%+v: got site %q, want %q", tt, s, tt.site)
+		}
+		if input.IsSecure() != (tt.scheme == "https") {
+			t.Errorf("%+v: wrong IsSecure", tt)
+		}
+	}
+	if err := SetTrustedProxies([]string{"10.0.0.300"}); err == nil {
+		t.Error("a bad address should fail")
+	}
+	if nets, err := ParseNetworks([]string{"10.0.0.1", "::1", "192.168.0.0/16"}); err != nil || len(nets) != 3 || nets[0].String() != "10.0.0.1/32" || nets[1].String() != "::1/128" {
+		t.Errorf("the single ips should be networks of one address, got %v %v", nets, err)
+	}
+	if _, err := ParseNetworks([]string{""}); err == nil {
+		t.Error("an empty address should fail")
+	}
+}
+
+func TestForwardedChain(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies(nil)
+	tests := []struct {
+		forwardedFor, proto, want string
+	}{
+		// the client 1.1.1.1 reached 10.0.0.2 by https, which forwarded by http
+		{"1.1.1.1, 10.0.0.2", "https, http", "https"},
+		// the value spoofed by the client is skipped
+		{"1.1.1.1, 10.0.0.2", "http, https, http", "https"},
+		// a single value of a proxy overwriting the header
+		{"1.1.1.1, 10.0.0.2", "https", "https"},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.URL.Scheme = ""
+		r.RemoteAddr = "10.0.0.1:5000"
+		r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		r.Header.Set("X-Forwarded-Proto", tt.proto)
+		if s := NewInput(r).Scheme(); s != tt.want {
+			t.Errorf("%+v: got scheme %q", tt, s)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies(nil)
+	tests := []struct {
+		remote, forwardedFor, want string
+	}{
+		{"8.8.8.8:80", "", "8.8.8.8"},
+		// the header of an untrusted client is ignored
+		{"8.8.8.8:80", "192.168.1.5", "8.8.8.8"},
+		{"10.0.0.1:80", "192.168.1.5", "192.168.1.5"},
+		// read from the right, the first untrusted hop is the client
+		{"10.0.0.1:80", "1.1.1.1, 192.168.1.5, 10.0.0.2", "192.168.1.5"},
+		{"10.0.0.1:80", "bad, 10.0.0.2", "10.0.0.2"},
+		{"not an ip", "", ""},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		if ip := NewInput(r).ClientIP(); ip != tt.want {
+			t.Errorf("%+v: got %q", tt, ip)
+		}
+	}
+}
@@ -16,11 +16,14 @@ package context
 
 import (
 	"bytes"
+	"crypto/x509"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -30,14 +33,41 @@ import (
 // BeegoInput operates the http request header, data, cookie and body.
 // it also contains router params and current session.
 type BeegoInput struct {
-	CruSession    session.SessionStore
-	Params        map[string]string
-	Data          map[interface{}]interface{} // store some values in this context when calling context in filter or controller.
+	CruSession session.SessionStore
+	// the router params, a new map is made for each match.
+	Params map[string]string
+	// Data stores some values in this context when calling context in filter
+	// or controller, it's the Data of the controller and of its templates.
+	// the keys are strings by convention:
+	//   - the values of the app, given to the templates, are named like
+	//     template fields, e.g. Lang or CSPNonce;
+	//   - the plugins export their keys as constants prefixed by the plugin
+	//     name, e.g. jwt.ClaimsKey is jwt_claims;
+	//   - the internal keys are prefixed by an underscore and the package
+	//     name, e.g. _beego_timeout, and must not be used by the others.
+	Data          map[interface{}]interface{}
 	Request       *http.Request
 	RequestBody   []byte
 	RunController reflect.Type
 	RunMethod     string
-}
+	// the pattern and the metadata of the matched route, the metadata is
+	// shared by the requests of the route and must not be changed.
+	// the pattern, like /user/:id, is set from the BeforeExec filters on, to
+	// aggregate the logs, the metrics or the rate limits by route rather
+	// than by url.
+	RoutePattern string
+	RouteMeta    map[string]interface{}
+	// LazyCopyBody is set by the router for the routes copying their body,
+	// it's copied to RequestBody when it's first read by CopyBody, Body or
+	// the binders, and before the controller runs.
+	LazyCopyBody bool
+	// the manager and the writer of the session started lazily
+	sessions      *session.Manager
+	sessionWriter http.ResponseWriter
+}
+
+// TagsMeta is the key of the tags of a route in its metadata.
+const TagsMeta = "tags"
 
 // NewInput return BeegoInput generated by http.Request.
 func NewInput(req *http.Request) *BeegoInput {
@@ -48,6 +78,27 @@ func NewInput(req *http.Request) *BeegoInput {
 	}
 }
 
+// Reset makes the input of req. the maps of the last request are left to
+// their holders.
+func (input *BeegoInput) Reset(req *http.Request) {
+	input.CruSession = nil
+	input.sessions = nil
+	input.sessionWriter = nil
+	input.Request = req
+	input.RequestBody = nil
+	input.RunController = nil
+	input.RunMethod = ""
+	input.RoutePattern = ""
+	input.RouteMeta = nil
+	input.LazyCopyBody = false
+	input.ResetParams()
+	// the data may be held by the controllers of the last request, an empty
+	// map carries nothing of it and is kept.
+	if input.Data == nil || len(input.Data) > 0 {
+		input.Data = make(map[interface{}]interface{})
+	}
+}
+
 // Protocol returns request protocol name, such as HTTP/1.1 .
 func (input *BeegoInput) Protocol() string {
 	return input.Request.Proto
@@ -63,22 +114,39 @@ func (input *BeegoInput) Url() string {
 	return input.Request.URL.Path
 }
 
-// Site returns base site url as scheme://domain type.
+// Site returns base site url as scheme://domain type, with the port when
+// it's not the default one of the scheme.
 func (input *BeegoInput) Site() string {
-	return input.Scheme() + "://" + input.Domain()
+	scheme := input.Scheme()
+	site := scheme + "://" + input.Domain()
+	if port := input.Port(); port != defaultPort(scheme) {
+		site += ":" + strconv.Itoa(port)
+	}
+	return site
 }
 
-// Scheme returns request scheme as "http" or "https".
+// Scheme returns request scheme as "http" or "https", the one of the
+// X-Forwarded-Proto header of a trusted proxy, see SetTrustedProxies.
 func (input *BeegoInput) Scheme() string {
 	if input.Request.URL.Scheme != "" {
 		return input.Request.URL.Scheme
 	}
+	if proto := strings.ToLower(input.forwarded("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		return proto
+	}
 	if input.Request.TLS == nil {
 		return "http"
 	}
 	return "https"
 }
 
+func defaultPort(scheme string) int {
+	if scheme == "https" {
+		return 443
+	}
+	return 80
+}
+
 // Domain returns host name.
 // Alias of Host method.
 func (input *BeegoInput) Domain() string {
@@ -148,11 +216,25 @@ func (input *BeegoInput) IsAjax() bool {
 	return input.Header("X-Requested-With") == "XMLHttpRequest"
 }
 
+// IsHtmx returns boolean of this request is sent by htmx.
+func (input *BeegoInput) IsHtmx() bool {
+	return input.Header("HX-Request") == "true"
+}
+
 // IsSecure returns boolean of this request is in https.
 func (input *BeegoInput) IsSecure() bool {
 	return input.Scheme() == "https"
 }
 
+// ClientCert returns the verified client certificate of a mutual tls request,
+// or nil if the client didn't send one or it wasn't verified.
+func (input *BeegoInput) ClientCert() *x509.Certificate {
+	if input.Request.TLS == nil || len(input.Request.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	return input.Request.TLS.VerifiedChains[0][0]
+}
+
 // IsWebsocket returns boolean of this request is in webSocket.
 func (input *BeegoInput) IsWebsocket() bool {
 	return input.Header("Upgrade") == "websocket"
@@ -209,15 +291,20 @@ func (input *BeegoInput) SubDomains() string {
 	return ""
 }
 
-// Port returns request client port.
-// when error or empty, return 80.
+// Port returns the port the request was sent to, the one of the
+// X-Forwarded-Port header of a trusted proxy or of the Host header, else
+// the default one of the scheme, 80 or 443.
 func (input *BeegoInput) Port() int {
+	if port, err := strconv.Atoi(input.forwarded("X-Forwarded-Port")); err == nil && port > 0 {
+		return port
+	}
 	parts := strings.Split(input.Request.Host, ":")
 	if len(parts) == 2 {
-		port, _ := strconv.Atoi(parts[1])
-		return port
+		if port, err := strconv.Atoi(parts[1]); err == nil {
+			return port
+		}
 	}
-	return 80
+	return defaultPort(input.Scheme())
 }
 
 // UserAgent returns request client user agent string.
@@ -233,6 +320,78 @@ func (input *BeegoInput) Param(key string) string {
 	return ""
 }
 
+// ParamInt returns the router param key as an int,
+// or the default value when it's empty.
+func (input *BeegoInput) ParamInt(key string, def ...int) (int, error) {
+	if v := input.Param(key); v != "" {
+		return strconv.Atoi(v)
+	}
+	if len(def) > 0 {
+		return def[0], nil
+	}
+	return 0, nil
+}
+
+// ParamInt64 returns the router param key as an int64,
+// or the default value when it's empty.
+func (input *BeegoInput) ParamInt64(key string, def ...int64) (int64, error) {
+	if v := input.Param(key); v != "" {
+		return strconv.ParseInt(v, 10, 64)
+	}
+	if len(def) > 0 {
+		return def[0], nil
+	}
+	return 0, nil
+}
+
+// ParamBool returns the router param key as a bool,
+// or the default value when it's empty.
+func (input *BeegoInput) ParamBool(key string, def ...bool) (bool, error) {
+	if v := input.Param(key); v != "" {
+		return strconv.ParseBool(v)
+	}
+	if len(def) > 0 {
+		return def[0], nil
+	}
+	return false, nil
+}
+
+// SetParam sets the router param key.
+func (input *BeegoInput) SetParam(key, val string) {
+	if input.Params == nil {
+		input.Params = make(map[string]string)
+	}
+	input.Params[key] = val
+}
+
+// ResetParams drops the router params, the old map is left to its holders.
+func (input *BeegoInput) ResetParams() {
+	if len(input.Params) > 0 {
+		input.Params = make(map[string]string)
+	}
+}
+
+// Meta returns the metadata key of the matched route, nil if it has none.
+func (input *BeegoInput) Meta(key string) interface{} {
+	return input.RouteMeta[key]
+}
+
+// HasTag reports whether the matched route has the tag.
+func (input *BeegoInput) HasTag(tag string) bool {
+	tags, _ := input.RouteMeta[TagsMeta].([]string)
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ParamsLen returns the number of the router params.
+func (input *BeegoInput) ParamsLen() int {
+	return len(input.Params)
+}
+
 // Query returns input data item string by a given string.
 func (input *BeegoInput) Query(key string) string {
 	if val := input.Param(key); val != "" {
@@ -260,14 +419,47 @@ func (input *BeegoInput) Cookie(key string) string {
 	return ck.Value
 }
 
+// SetSessionManager makes the session of the request started by manager
+// when it's first used, see StartSession, it's called by the router when
+// beego.SessionOn is set. w gets the cookie of a new session.
+func (input *BeegoInput) SetSessionManager(manager *session.Manager, w http.ResponseWriter) {
+	input.sessions = manager
+	input.sessionWriter = w
+}
+
+// StartSession returns the session of the request, loaded or created on the
+// first call only: the requests not using the session don't reach the
+// session store. it's nil without session manager. a new session sets its
+// cookie, it must be started before the response is written.
+func (input *BeegoInput) StartSession() (session.SessionStore, error) {
+	if input.CruSession != nil || input.sessions == nil {
+		return input.CruSession, nil
+	}
+	sess, err := input.sessions.SessionStart(input.sessionWriter, input.Request)
+	if err != nil {
+		return nil, err
+	}
+	input.CruSession = sess
+	return sess, nil
+}
+
 // Session returns current session item value by a given key.
-// if non-existed, return empty string.
+// if non-existed, or without session, return nil.
 func (input *BeegoInput) Session(key interface{}) interface{} {
-	return input.CruSession.Get(key)
+	sess, err := input.StartSession()
+	if err != nil || sess == nil {
+		return nil
+	}
+	return sess.Get(key)
 }
 
-// CopyBody returns the raw request body data as bytes.
+// CopyBody returns the raw request body data as bytes, read once and kept
+// in RequestBody. the body is read up to the limit of the request, see
+// beego.MaxRequestBodySize.
 func (input *BeegoInput) CopyBody() []byte {
+	if input.RequestBody != nil {
+		return input.RequestBody
+	}
 	requestbody, _ := ioutil.ReadAll(input.Request.Body)
 	input.Request.Body.Close()
 	bf := bytes.NewBuffer(requestbody)
@@ -276,6 +468,22 @@ func (input *BeegoInput) CopyBody() []byte {
 	return requestbody
 }
 
+// Body returns the request body to be read as a stream, without buffering
+// it, or the copied RequestBody. it's empty once the body has been read,
+// like by a form parsed from the body.
+func (input *BeegoInput) Body() io.ReadCloser {
+	if input.RequestBody == nil && input.LazyCopyBody {
+		input.CopyBody()
+	}
+	if input.RequestBody != nil {
+		return ioutil.NopCloser(bytes.NewReader(input.RequestBody))
+	}
+	if input.Request.Body == nil {
+		return http.NoBody
+	}
+	return input.Request.Body
+}
+
 // GetData returns the stored data in this context.
 func (input *BeegoInput) GetData(key interface{}) interface{} {
 	if v, ok := input.Data[key]; ok {
@@ -287,9 +495,67 @@ func (input *BeegoInput) GetData(key interface{}) interface{} {
 // SetData stores data with given key in this context.
 // This data are only available in this context.
 func (input *BeegoInput) SetData(key, val interface{}) {
+	if input.Data == nil {
+		input.Data = make(map[interface{}]interface{})
+	}
 	input.Data[key] = val
 }
 
+// GetDataString returns the string stored with key, or def, "" by default,
+// when there's none.
+func (input *BeegoInput) GetDataString(key interface{}, def ...string) string {
+	if v, ok := input.Data[key].(string); ok {
+		return v
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return ""
+}
+
+// GetDataInt returns the integer stored with key, of any int type, or def,
+// 0 by default, when there's none.
+func (input *BeegoInput) GetDataInt(key interface{}, def ...int) int {
+	switch v := input.Data[key].(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case uint:
+		return int(v)
+	case uint8:
+		return int(v)
+	case uint16:
+		return int(v)
+	case uint32:
+		return int(v)
+	case uint64:
+		return int(v)
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return 0
+}
+
+// GetDataBool returns the bool stored with key, or def, false by default,
+// when there's none.
+func (input *BeegoInput) GetDataBool(key interface{}, def ...bool) bool {
+	if v, ok := input.Data[key].(bool); ok {
+		return v
+	}
+	if len(def) > 0 {
+		return def[0]
+	}
+	return false
+}
+
 // parseForm or parseMultiForm based on Content-type
 func (input *BeegoInput) ParseFormOrMulitForm(maxMemory int64) error {
 	// Parse the body depending on the content type.
@@ -311,7 +577,15 @@ func (input *BeegoInput) ParseFormOrMulitForm(maxMemory int64) error {
 // ol := make([]int, 0, 2)  beegoInput.Bind(&ol, "ol")  ol ==[1 2]
 // ul := make([]string, 0, 2)  beegoInput.Bind(&ul, "ul")  ul ==[str array]
 // user struct{Name}  beegoInput.Bind(&user, "user")  user == {Name:"astaxie"}
-func (input *BeegoInput) Bind(dest interface{}, key string) error {
+// the nested structs, user.Address.City, and the slices of structs,
+// items[0].Qty, are bound too, the embedded structs are flattened.
+// time.Time is parsed with TimeLayouts, time.Duration with time.ParseDuration
+// and the types implementing encoding.TextUnmarshaler with UnmarshalText.
+// the values failing to convert are left zero and reported together by the
+// BindErrors returned, dest has the other values bound.
+// the slices take the repeated values too, ?ids=1&ids=2, and the values
+// split at the separator of the SplitValues option, ?ids=1,2.
+func (input *BeegoInput) Bind(dest interface{}, key string, options ...FormOption) error {
 	value := reflect.ValueOf(dest)
 	if value.Kind() != reflect.Ptr {
 		return errors.New("beego: non-pointer passed to Bind: " + key)
@@ -320,138 +594,95 @@ func (input *BeegoInput) Bind(dest interface{}, key string) error {
 	if !value.CanSet() {
 		return errors.New("beego: non-settable variable passed to Bind: " + key)
 	}
-	rv := input.bind(key, value.Type())
+	if input.Request.Form == nil {
+		input.Request.ParseForm()
+	}
+	var st bindState
+	for _, o := range options {
+		o(&st.formOptions)
+	}
+	rv := input.bind(key, value.Type(), &st)
 	if !rv.IsValid() {
 		return errors.New("beego: reflect value is empty")
 	}
 	value.Set(rv)
+	if len(st.errs) > 0 {
+		sort.Sort(st.errs)
+		return st.errs
+	}
 	return nil
 }
 
-func (input *BeegoInput) bind(key string, typ reflect.Type) reflect.Value {
-	rv := reflect.Zero(reflect.TypeOf(0))
+// bindState is the state of a Bind, its options and its errors.
+type bindState struct {
+	formOptions
+	errs BindErrors
+}
+
+func (input *BeegoInput) bind(key string, typ reflect.Type, st *bindState) reflect.Value {
 	switch typ.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		val := input.Query(key)
-		if len(val) == 0 {
-			return rv
-		}
-		rv = input.bindInt(val, typ)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		val := input.Query(key)
-		if len(val) == 0 {
-			return rv
-		}
-		rv = input.bindUint(val, typ)
-	case reflect.Float32, reflect.Float64:
-		val := input.Query(key)
-		if len(val) == 0 {
-			return rv
-		}
-		rv = input.bindFloat(val, typ)
-	case reflect.String:
-		val := input.Query(key)
-		if len(val) == 0 {
-			return rv
-		}
-		rv = input.bindString(val, typ)
-	case reflect.Bool:
-		val := input.Query(key)
-		if len(val) == 0 {
-			return rv
-		}
-		rv = input.bindBool(val, typ)
 	case reflect.Slice:
-		rv = input.bindSlice(&input.Request.Form, key, typ)
+		if !isTextType(typ) {
+			return input.bindSlice(&input.Request.Form, key, typ, st)
+		}
 	case reflect.Struct:
-		rv = input.bindStruct(&input.Request.Form, key, typ)
+		if !isTextType(typ) {
+			return input.bindStruct(&input.Request.Form, key, typ, st)
+		}
 	case reflect.Ptr:
-		rv = input.bindPoint(key, typ)
+		return input.bindPoint(key, typ, st)
 	case reflect.Map:
-		rv = input.bindMap(&input.Request.Form, key, typ)
+		return input.bindMap(&input.Request.Form, key, typ, st)
 	}
-	return rv
+	val := input.Query(key)
+	if len(val) == 0 {
+		return reflect.Zero(typ)
+	}
+	return input.bindValue(key, val, typ, st)
 }
 
-func (input *BeegoInput) bindValue(val string, typ reflect.Type) reflect.Value {
-	rv := reflect.Zero(reflect.TypeOf(0))
+// bindValue binds the value val of key, adding a BindError to the errors
+// of st when it doesn't convert to typ.
+func (input *BeegoInput) bindValue(key, val string, typ reflect.Type, st *bindState) reflect.Value {
 	switch typ.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		rv = input.bindInt(val, typ)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		rv = input.bindUint(val, typ)
-	case reflect.Float32, reflect.Float64:
-		rv = input.bindFloat(val, typ)
-	case reflect.String:
-		rv = input.bindString(val, typ)
-	case reflect.Bool:
-		rv = input.bindBool(val, typ)
 	case reflect.Slice:
-		rv = input.bindSlice(&url.Values{"": {val}}, "", typ)
+		if !isTextType(typ) && typ.Elem().Kind() != reflect.Uint8 {
+			return input.bindSlice(&url.Values{"": {val}}, "", typ, st)
+		}
 	case reflect.Struct:
-		rv = input.bindStruct(&url.Values{"": {val}}, "", typ)
+		if !isTextType(typ) {
+			return input.bindStruct(&url.Values{"": {val}}, "", typ, st)
+		}
 	case reflect.Ptr:
-		rv = input.bindPoint(val, typ)
+		if !isTextType(typ.Elem()) {
+			pValue := reflect.New(typ.Elem())
+			pValue.Elem().Set(input.bindValue(key, val, typ.Elem(), st))
+			return pValue
+		}
 	case reflect.Map:
-		rv = input.bindMap(&url.Values{"": {val}}, "", typ)
-	}
-	return rv
-}
-
-func (input *BeegoInput) bindInt(val string, typ reflect.Type) reflect.Value {
-	intValue, err := strconv.ParseInt(val, 10, 64)
-	if err != nil {
-		return reflect.Zero(typ)
-	}
-	pValue := reflect.New(typ)
-	pValue.Elem().SetInt(intValue)
-	return pValue.Elem()
-}
-
-func (input *BeegoInput) bindUint(val string, typ reflect.Type) reflect.Value {
-	uintValue, err := strconv.ParseUint(val, 10, 64)
-	if err != nil {
-		return reflect.Zero(typ)
+		return input.bindMap(&url.Values{"": {val}}, "", typ, st)
 	}
 	pValue := reflect.New(typ)
-	pValue.Elem().SetUint(uintValue)
-	return pValue.Elem()
-}
-
-func (input *BeegoInput) bindFloat(val string, typ reflect.Type) reflect.Value {
-	floatValue, err := strconv.ParseFloat(val, 64)
-	if err != nil {
+	if err := bindText(pValue.Elem(), val, ""); err != nil {
+		st.errs = append(st.errs, &BindError{Field: key, Value: val, Type: typ.String(), Err: err})
 		return reflect.Zero(typ)
 	}
-	pValue := reflect.New(typ)
-	pValue.Elem().SetFloat(floatValue)
 	return pValue.Elem()
 }
 
-func (input *BeegoInput) bindString(val string, typ reflect.Type) reflect.Value {
-	return reflect.ValueOf(val)
-}
-
-func (input *BeegoInput) bindBool(val string, typ reflect.Type) reflect.Value {
-	val = strings.TrimSpace(strings.ToLower(val))
-	switch val {
-	case "true", "on", "1":
-		return reflect.ValueOf(true)
-	}
-	return reflect.ValueOf(false)
-}
-
 type sliceValue struct {
 	index int           // Index extracted from brackets.  If -1, no index was provided.
 	value reflect.Value // the bound value for this slice element.
 }
 
-func (input *BeegoInput) bindSlice(params *url.Values, key string, typ reflect.Type) reflect.Value {
+func (input *BeegoInput) bindSlice(params *url.Values, key string, typ reflect.Type, st *bindState) reflect.Value {
 	maxIndex := -1
 	numNoIndex := 0
 	sliceValues := []sliceValue{}
+	bound := make(map[int]bool)
 	for reqKey, vals := range *params {
-		if !strings.HasPrefix(reqKey, key+"[") {
+		// the repeated values, key=1&key=2, are un-indexed elements
+		if reqKey != key && !strings.HasPrefix(reqKey, key+"[") {
 			continue
 		}
 		// Extract the index, and the index where a sub-key starts. (e.g. field[0].subkey)
@@ -464,23 +695,29 @@ func (input *BeegoInput) bindSlice(params *url.Values, key string, typ reflect.T
 
 		// Handle the indexed case.
 		if index > -1 {
+			// the sub-keys of a struct element are bound at once
+			if bound[index] {
+				continue
+			}
+			bound[index] = true
 			if index > maxIndex {
 				maxIndex = index
 			}
 			sliceValues = append(sliceValues, sliceValue{
 				index: index,
-				value: input.bind(reqKey[:subKeyIndex], typ.Elem()),
+				value: input.bind(reqKey[:subKeyIndex], typ.Elem(), st),
 			})
 			continue
 		}
 
-		// It's an un-indexed element.  (e.g. element[])
+		// It's an un-indexed element.  (e.g. element[] or element)
+		vals = split(vals, st.sep)
 		numNoIndex += len(vals)
 		for _, val := range vals {
 			// Unindexed values can only be direct-bound.
 			sliceValues = append(sliceValues, sliceValue{
 				index: -1,
-				value: input.bindValue(val, typ.Elem()),
+				value: input.bindValue(reqKey, val, typ.Elem(), st),
 			})
 		}
 	}
@@ -495,39 +732,66 @@ func (input *BeegoInput) bindSlice(params *url.Values, key string, typ reflect.T
 	return resultArray
 }
 
-func (input *BeegoInput) bindStruct(params *url.Values, key string, typ reflect.Type) reflect.Value {
+func (input *BeegoInput) bindStruct(params *url.Values, key string, typ reflect.Type, st *bindState) reflect.Value {
 	result := reflect.New(typ).Elem()
-	fieldValues := make(map[string]reflect.Value)
-	for reqKey, val := range *params {
+	fieldValues := make(map[string]bool)
+	for reqKey := range *params {
 		if !strings.HasPrefix(reqKey, key+".") {
 			continue
 		}
 
+		// the field of user.Address.City or items[0].Qty is Address or items
 		fieldName := reqKey[len(key)+1:]
+		if n := strings.IndexAny(fieldName, ".["); n >= 0 {
+			fieldName = fieldName[:n]
+		}
 
-		if _, ok := fieldValues[fieldName]; !ok {
+		if !fieldValues[fieldName] {
+			fieldValues[fieldName] = true
 			// Time to bind this field.  Get it and make sure we can set it.
-			fieldValue := result.FieldByName(fieldName)
+			fieldValue := fieldByName(result, fieldName)
 			if !fieldValue.IsValid() {
 				continue
 			}
 			if !fieldValue.CanSet() {
 				continue
 			}
-			boundVal := input.bindValue(val[0], fieldValue.Type())
-			fieldValue.Set(boundVal)
-			fieldValues[fieldName] = boundVal
+			fieldValue.Set(input.bind(key+"."+fieldName, fieldValue.Type(), st))
 		}
 	}
 
 	return result
 }
 
-func (input *BeegoInput) bindPoint(key string, typ reflect.Type) reflect.Value {
-	return input.bind(key, typ.Elem()).Addr()
+// fieldByName returns the field name of the struct v, a field of its
+// embedded structs included, allocating the embedded pointers to reach it.
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	field, ok := v.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}
+	}
+	for i, x := range field.Index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func (input *BeegoInput) bindPoint(key string, typ reflect.Type, st *bindState) reflect.Value {
+	pValue := reflect.New(typ.Elem())
+	pValue.Elem().Set(input.bind(key, typ.Elem(), st))
+	return pValue
 }
 
-func (input *BeegoInput) bindMap(params *url.Values, key string, typ reflect.Type) reflect.Value {
+func (input *BeegoInput) bindMap(params *url.Values, key string, typ reflect.Type, st *bindState) reflect.Value {
 	var (
 		result    = reflect.MakeMap(typ)
 		keyType   = typ.Key()
@@ -539,7 +803,7 @@ func (input *BeegoInput) bindMap(params *url.Values, key string, typ reflect.Typ
 		}
 
 		key := paramName[len(key)+1 : len(paramName)-1]
-		result.SetMapIndex(input.bindValue(key, keyType), input.bindValue(values[0], valueType))
+		result.SetMapIndex(input.bindValue(paramName, key, keyType, st), input.bindValue(paramName, values[0], valueType, st))
 	}
 	return result
 }
@@ -16,8 +16,12 @@ package context
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParse(t *testing.T) {
@@ -71,6 +75,120 @@ func TestParse(t *testing.T) {
 	fmt.Println(user)
 }
 
+func TestBindText(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?since=2015-01-02&at=2015-01-02T03:04:05Z&wait=1m30s"+
+		"&ip=10.0.0.1&days[]=2015-01-02&days[]=2015-01-03&event.At=2015-01-02 03:04:05&bad=soon", nil)
+	input := NewInput(r)
+	input.ParseFormOrMulitForm(1 << 20)
+
+	var since, at time.Time
+	if err := input.Bind(&since, "since"); err != nil || !since.Equal(time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %v %v, want the date", since, err)
+	}
+	if err := input.Bind(&at, "at"); err != nil || !at.Equal(time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("got %v %v, want the RFC3339 time", at, err)
+	}
+	var wait time.Duration
+	if err := input.Bind(&wait, "wait"); err != nil || wait != 90*time.Second {
+		t.Errorf("got %v %v, want 1m30s", wait, err)
+	}
+	var ip net.IP
+	if err := input.Bind(&ip, "ip"); err != nil || !ip.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("got %v %v, want the ip", ip, err)
+	}
+	var days []time.Time
+	if err := input.Bind(&days, "days"); err != nil || len(days) != 2 || days[1].Day() != 3 {
+		t.Errorf("got %v %v, want two days", days, err)
+	}
+	var event struct{ At time.Time }
+	if err := input.Bind(&event, "event"); err != nil || event.At.Hour() != 3 {
+		t.Errorf("got %v %v, want the time of the event", event, err)
+	}
+	var bad time.Time
+	if err, ok := input.Bind(&bad, "bad").(BindErrors); !ok || len(err) != 1 || err[0].Field != "bad" || !bad.IsZero() {
+		t.Errorf("got %v %v, want a zero time and the error of an invalid one", bad, err)
+	}
+}
+
+func TestBindArrays(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?ids=1&ids=2&csv=1,2,,3&user.Tags=a|b", nil)
+	input := NewInput(r)
+	var ids []int
+	if err := input.Bind(&ids, "ids"); err != nil || len(ids) != 2 || ids[0]+ids[1] != 3 {
+		t.Errorf("got %v %v, want the repeated values", ids, err)
+	}
+	var csv []int
+	if err := input.Bind(&csv, "csv", SplitValues(",")); err != nil || len(csv) != 3 || csv[0]+csv[1]+csv[2] != 6 {
+		t.Errorf("got %v %v, want the split values", csv, err)
+	}
+	if err := input.Bind(&csv, "csv"); err == nil {
+		t.Error("the values should not be split without SplitValues")
+	}
+	var user struct{ Tags []string }
+	if err := input.Bind(&user, "user", SplitValues("|")); err != nil || len(user.Tags) != 2 {
+		t.Errorf("got %v %v, want the split values of the field", user, err)
+	}
+}
+
+type bindAddress struct {
+	City string
+	Zip  int
+}
+
+type bindBase struct {
+	ID int
+}
+
+type bindItem struct {
+	Name string
+	Qty  int
+}
+
+type bindPurchase struct {
+	bindBase
+	User struct {
+		Name    string
+		Address bindAddress
+	}
+	Items []bindItem
+	Note  *string
+}
+
+func TestBindNested(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?order.ID=7&order.User.Name=astaxie&order.User.Address.City=Shanghai"+
+		"&order.User.Address.Zip=200000&order.Items[0].Name=pen&order.Items[0].Qty=2"+
+		"&order.Items[1].Name=ink&order.Items[1].Qty=3&order.Note=fast", nil)
+	input := NewInput(r)
+	var order bindPurchase
+	if err := input.Bind(&order, "order"); err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 7 || order.User.Name != "astaxie" ||
+		order.User.Address != (bindAddress{"Shanghai", 200000}) || order.Note == nil || *order.Note != "fast" {
+		t.Errorf("got %+v", order)
+	}
+	if len(order.Items) != 2 || order.Items[0] != (bindItem{"pen", 2}) || order.Items[1] != (bindItem{"ink", 3}) {
+		t.Errorf("got the items %+v", order.Items)
+	}
+
+	r, _ = http.NewRequest("GET", "/?order.ID=x&order.User.Name=astaxie&order.User.Address.Zip=big"+
+		"&order.Items[0].Name=pen&order.Items[0].Qty=many", nil)
+	order = bindPurchase{}
+	err := NewInput(r).Bind(&order, "order")
+	errs, ok := err.(BindErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("got %v, want the errors of the three fields", err)
+	}
+	for i, field := range []string{"order.ID", "order.Items[0].Qty", "order.User.Address.Zip"} {
+		if errs[i].Field != field {
+			t.Errorf("got the error %v, want one of %s", errs[i], field)
+		}
+	}
+	if order.User.Name != "astaxie" || order.Items[0].Name != "pen" {
+		t.Errorf("the valid fields should be bound, got %+v", order)
+	}
+}
+
 func TestSubDomain(t *testing.T) {
 	r, _ := http.NewRequest("GET", "http://www.example.com/?id=123&isok=true&ft=1.2&ol[0]=1&ol[1]=2&ul[]=str&ul[]=array&user.Name=astaxie", nil)
 	beegoInput := NewInput(r)
@@ -112,3 +230,109 @@ func TestSubDomain(t *testing.T) {
 		t.Fatal("Subdomain parse error, got " + beegoInput.SubDomains())
 	}
 }
+
+func TestParams(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/users/42", nil)
+	input := NewInput(r)
+	input.SetParam(":id", "42")
+	input.SetParam(":name", "astaxie")
+	input.SetParam(":id", "43")
+	if input.Param(":id") != "43" || input.Param(":name") != "astaxie" || input.Param(":missing") != "" || input.ParamsLen() != 2 {
+		t.Fatal("unexpected params", input.Params)
+	}
+	input.Params[":name"] = "slene"
+	if input.Param(":name") != "slene" {
+		t.Fatal("the writes to Params should be seen by Param", input.Params)
+	}
+	input.ResetParams()
+	if input.ParamsLen() != 0 || input.Param(":id") != "" {
+		t.Fatal("params should be reset", input.Params)
+	}
+}
+
+func TestTypedParams(t *testing.T) {
+	input := NewInput(nil)
+	input.SetParam(":id", "42")
+	input.SetParam(":big", "9000000000")
+	input.SetParam(":on", "true")
+	input.SetParam(":bad", "x")
+	if v, err := input.ParamInt(":id"); v != 42 || err != nil {
+		t.Error("ParamInt :id should be 42", v, err)
+	}
+	if v, err := input.ParamInt(":missing", 7); v != 7 || err != nil {
+		t.Error("ParamInt :missing should default to 7", v, err)
+	}
+	if _, err := input.ParamInt(":bad", 7); err == nil {
+		t.Error("ParamInt :bad should fail")
+	}
+	if v, err := input.ParamInt64(":big"); v != 9000000000 || err != nil {
+		t.Error("ParamInt64 :big should be 9000000000", v, err)
+	}
+	if v, err := input.ParamBool(":on"); !v || err != nil {
+		t.Error("ParamBool :on should be true", v, err)
+	}
+	if v, err := input.ParamBool(":missing", true); !v || err != nil {
+		t.Error("ParamBool :missing should default to true", v, err)
+	}
+}
+
+func TestContextReset(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/users/42", nil)
+	ctx := NewContext()
+	ctx.Reset(nil, r)
+	ctx.Input.SetParam(":id", "42")
+	ctx.Input.SetData("user", "astaxie")
+	ctx.Input.RunMethod = "Get"
+	ctx.Output.Status = 404
+	data := ctx.Input.Data
+
+	r2, _ := http.NewRequest("GET", "/", nil)
+	ctx.Reset(nil, r2)
+	if ctx.Request != r2 || ctx.Input.Request != r2 || ctx.Output.Context != ctx {
+		t.Fatal("the context should be the one of the new request")
+	}
+	if ctx.Input.ParamsLen() != 0 || ctx.Input.GetData("user") != nil || ctx.Input.RunMethod != "" || ctx.Output.Status != 0 {
+		t.Fatal("the context should forget the last request")
+	}
+	if data["user"] != "astaxie" {
+		t.Fatal("the data of the last request should be left to its holders")
+	}
+}
+
+func TestBody(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("streamed"))
+	input := NewInput(r)
+	if b, _ := ioutil.ReadAll(input.Body()); string(b) != "streamed" {
+		t.Errorf("got %q, want the request body", b)
+	}
+	input.RequestBody = []byte("copied")
+	if b, _ := ioutil.ReadAll(input.Body()); string(b) != "copied" {
+		t.Errorf("got %q, want the copied body", b)
+	}
+	r, _ = http.NewRequest("GET", "/", nil)
+	if b, _ := ioutil.ReadAll(NewInput(r).Body()); len(b) != 0 {
+		t.Errorf("got %q, want no body", b)
+	}
+}
+
+func TestTypedData(t *testing.T) {
+	input := NewInput(nil)
+	if input.GetDataString("missing") != "" || input.GetDataInt("missing", 7) != 7 || input.GetDataBool("missing") {
+		t.Error("missing data should get the defaults")
+	}
+	input.SetData("auth_user", "astaxie")
+	input.SetData("page", int64(3))
+	input.SetData("admin", true)
+	if v := input.GetDataString("auth_user"); v != "astaxie" {
+		t.Errorf("got %q, want astaxie", v)
+	}
+	if v := input.GetDataInt("page"); v != 3 {
+		t.Errorf("got %d, want 3", v)
+	}
+	if !input.GetDataBool("admin") {
+		t.Error("admin should be true")
+	}
+	if v := input.GetDataString("page", "none"); v != "none" {
+		t.Errorf("a value of another type should get the default, got %q", v)
+	}
+}
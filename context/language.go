@@ -0,0 +1,84 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"sort"
+	"strings"
+)
+
+// Locale is a language range of the Accept-Language header, like en-US, en
+// or *, with its quality.
+type Locale struct {
+	Tag string
+	Q   float64
+}
+
+// AcceptsLanguages returns the locales of the Accept-Language header by
+// decreasing quality, in the order of the header for the same quality.
+// the ones of quality 0, refused, are left out.
+func (input *BeegoInput) AcceptsLanguages() []Locale {
+	var locales []Locale
+	for _, v := range parseQValues(input.Header("Accept-Language")) {
+		if v.q > 0 {
+			locales = append(locales, Locale{Tag: v.value, Q: v.q})
+		}
+	}
+	sort.SliceStable(locales, func(i, j int) bool { return locales[i].Q > locales[j].Q })
+	return locales
+}
+
+// NegotiateLanguage returns the locale of supported best matching the
+// Accept-Language header, the first one without match. a locale of the
+// header matches the supported ones case-insensitively, en matching
+// en-US, then by its base language, en-GB matching en.
+//	lang := ctx.Input.NegotiateLanguage("en-US", "fr", "pt-BR")
+func (input *BeegoInput) NegotiateLanguage(supported ...string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+	for _, l := range input.AcceptsLanguages() {
+		if l.Tag == "*" {
+			return supported[0]
+		}
+		if s, ok := matchLanguage(l.Tag, supported); ok {
+			return s
+		}
+	}
+	return supported[0]
+}
+
+// matchLanguage returns the supported locale matching tag: the same one,
+// one of its region, or one of its base language.
+func matchLanguage(tag string, supported []string) (string, bool) {
+	for _, s := range supported {
+		if strings.EqualFold(s, tag) {
+			return s, true
+		}
+	}
+	for _, s := range supported {
+		if len(s) > len(tag) && strings.EqualFold(s[:len(tag)], tag) && (s[len(tag)] == '-' || s[len(tag)] == '_') {
+			return s, true
+		}
+	}
+	base := tag
+	if i := strings.IndexAny(tag, "-_"); i > 0 {
+		base = tag[:i]
+	}
+	if base == tag {
+		return "", false
+	}
+	return matchLanguage(base, supported)
+}
@@ -0,0 +1,52 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAcceptsLanguages(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "fr;q=0.5, en-US, de;q=0, en;q=0.8, *;q=0.1, pt-BR;q=0.8")
+	want := []Locale{{"en-US", 1}, {"en", 0.8}, {"pt-BR", 0.8}, {"fr", 0.5}, {"*", 0.1}}
+	if got := NewInput(r).AcceptsLanguages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	supported := []string{"en-US", "fr", "pt-BR"}
+	for accept, want := range map[string]string{
+		"":                       "en-US",
+		"fr-CA, en;q=0.5":        "fr",
+		"EN-us":                  "en-US",
+		"pt":                     "pt-BR",
+		"en-GB":                  "en-US",
+		"de, fr;q=0.1":           "fr",
+		"de":                     "en-US",
+		"de, *;q=0.5":            "en-US",
+		"fr;q=0, pt-pt;q=0.9":    "pt-BR",
+		"pt_BR;q=0.2, fr;q=0.1,": "pt-BR",
+	} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", accept)
+		if got := NewInput(r).NegotiateLanguage(supported...); got != want {
+			t.Errorf("NegotiateLanguage(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
@@ -36,6 +36,9 @@ type BeegoOutput struct {
 	Context    *Context
 	Status     int
 	EnableGzip bool
+	// ETag makes Body set the ETag of the json, xml and html responses to
+	// GET, answering 304 without body to the requests having it already.
+	ETag ETagMode
 }
 
 // NewOutput returns new BeegoOutput.
@@ -44,6 +47,14 @@ func NewOutput() *BeegoOutput {
 	return &BeegoOutput{}
 }
 
+// Reset makes the output of ctx.
+func (output *BeegoOutput) Reset(ctx *Context) {
+	output.Context = ctx
+	output.Status = 0
+	output.EnableGzip = false
+	output.ETag = NoETag
+}
+
 // Header sets response header item string via given key.
 func (output *BeegoOutput) Header(key, val string) {
 	output.Context.ResponseWriter.Header().Set(key, val)
@@ -76,6 +87,9 @@ func (output *BeegoOutput) Body(content []byte) {
 	} else {
 		output.Header("Content-Length", strconv.Itoa(len(content)))
 	}
+	if output.notModified(content) {
+		return
+	}
 
 	// Write status code if it has been set manually
 	// Set it to 0 afterwards to prevent "multiple response.WriteHeader calls"
@@ -355,6 +369,9 @@ func stringsToJson(str string) string {
 }
 
 // Sessions sets session item value with given key.
+// it does nothing without session.
 func (output *BeegoOutput) Session(name interface{}, value interface{}) {
-	output.Context.Input.CruSession.Set(name, value)
+	if sess, err := output.Context.Input.StartSession(); err == nil && sess != nil {
+		sess.Set(name, value)
+	}
 }
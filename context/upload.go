@@ -0,0 +1,299 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrFileTooLarge is returned by the reads of a FilePart past its size limit.
+	ErrFileTooLarge = errors.New("beego: uploaded file too large")
+	// ErrFileType is returned by EachFile for a file of an extension or a
+	// content type not allowed.
+	ErrFileType = errors.New("beego: uploaded file type not allowed")
+	// ErrTooManyFiles is returned by EachFile for the files past MaxFiles.
+	ErrTooManyFiles = errors.New("beego: too many uploaded files")
+	// ErrMultipartParsed is returned by EachFile when the body was parsed by
+	// ParseMultipartForm before, see beego.WithStreamedUpload.
+	ErrMultipartParsed = errors.New("beego: multipart body already parsed")
+)
+
+const (
+	// maxFormValue is the size limit of the form values of a streamed
+	// multipart body.
+	maxFormValue = 10 << 20
+	// sniffLen is the size of the start of a file sniffed for its content
+	// type, as used by http.DetectContentType.
+	sniffLen = 512
+)
+
+// FilePart is a file of a multipart body streamed by EachFile, reading it
+// fails with ErrFileTooLarge past its size limit.
+type FilePart struct {
+	*multipart.Part
+	// ContentType is sniffed from the first bytes of the file, unlike the
+	// Content-Type header sent by the client.
+	ContentType string
+	r           io.Reader
+	remaining   int64 // -1 without limit
+}
+
+func (p *FilePart) Read(b []byte) (int, error) {
+	if p.remaining < 0 {
+		return p.r.Read(b)
+	}
+	if int64(len(b)) > p.remaining+1 {
+		b = b[:p.remaining+1]
+	}
+	n, err := p.r.Read(b)
+	if int64(n) > p.remaining {
+		n = int(p.remaining)
+		p.remaining = 0
+		return n, fmt.Errorf("%s: %w", p.FileName(), ErrFileTooLarge)
+	}
+	p.remaining -= int64(n)
+	return n, err
+}
+
+// FileOption is a limit of the files of EachFile.
+type FileOption func(*fileLimits)
+
+type fileLimits struct {
+	maxSize      int64
+	maxFiles     int
+	extensions   []string
+	contentTypes []string
+}
+
+// MaxFileSize limits the size of every file, in bytes.
+func MaxFileSize(size int64) FileOption {
+	return func(l *fileLimits) {
+		l.maxSize = size
+	}
+}
+
+// MaxFiles limits the number of files.
+func MaxFiles(n int) FileOption {
+	return func(l *fileLimits) {
+		l.maxFiles = n
+	}
+}
+
+// AllowExtensions allows the files of the extensions only, like .png,
+// case-insensitively.
+func AllowExtensions(exts ...string) FileOption {
+	return func(l *fileLimits) {
+		l.extensions = append(l.extensions, exts...)
+	}
+}
+
+// AllowContentTypes allows the files of the content types only, like
+// image/png or image/*. the content type is sniffed from the first bytes of
+// the file by http.DetectContentType, the Content-Type header sent by the
+// client isn't trusted.
+func AllowContentTypes(types ...string) FileOption {
+	return func(l *fileLimits) {
+		l.contentTypes = append(l.contentTypes, types...)
+	}
+}
+
+// EachFile reads the multipart request body as a stream, calling fn for
+// every file in their order, without buffering them in memory or temporary
+// files. the file is read by fn, like to copy it to its storage, the error
+// of fn stops the iteration. the form values of the body are added to the
+// form of the request as they're read.
+// the route must not have its body parsed before, see beego.WithStreamedUpload.
+//	err := ctx.Input.EachFile(func(p *context.FilePart) error {
+//		w := bucket.NewWriter(p.FileName())
+//		if _, err := io.Copy(w, p); err != nil {
+//			return err
+//		}
+//		return w.Close()
+//	}, context.MaxFileSize(1<<30), context.AllowContentTypes("video/*"))
+func (input *BeegoInput) EachFile(fn func(*FilePart) error, options ...FileOption) error {
+	var limits fileLimits
+	for _, o := range options {
+		o(&limits)
+	}
+	r := input.Request
+	if r.MultipartForm != nil {
+		return ErrMultipartParsed
+	}
+	if r.Form == nil {
+		// the query only, the form of a multipart body isn't parsed
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+	}
+	if r.PostForm == nil {
+		r.PostForm = make(url.Values)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	files := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+		if part.FileName() == "" {
+			var b bytes.Buffer
+			n, err := io.CopyN(&b, part, maxFormValue+1)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if n > maxFormValue {
+				return errors.New("beego: multipart form value " + name + " too large")
+			}
+			r.Form.Add(name, b.String())
+			r.PostForm.Add(name, b.String())
+			continue
+		}
+		files++
+		if limits.maxFiles > 0 && files > limits.maxFiles {
+			return ErrTooManyFiles
+		}
+		br := bufio.NewReaderSize(part, sniffLen)
+		head, err := br.Peek(sniffLen)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		fp := &FilePart{Part: part, ContentType: http.DetectContentType(head), r: br, remaining: -1}
+		if !limits.allowed(part.FileName(), fp.ContentType) {
+			return fmt.Errorf("%s: %w", part.FileName(), ErrFileType)
+		}
+		if limits.maxSize > 0 {
+			fp.remaining = limits.maxSize
+		}
+		if err := fn(fp); err != nil {
+			return err
+		}
+		part.Close()
+	}
+}
+
+// Files returns the uploaded files of the form field key, checking them
+// against the limits of options like EachFile does. it returns
+// http.ErrMissingFile if there's no file.
+func (input *BeegoInput) Files(key string, options ...FileOption) ([]*multipart.FileHeader, error) {
+	r := input.Request
+	if r.MultipartForm == nil {
+		// the memory of http.Request.FormFile
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+	}
+	files := r.MultipartForm.File[key]
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	var limits fileLimits
+	for _, o := range options {
+		o(&limits)
+	}
+	if limits.maxFiles > 0 && len(files) > limits.maxFiles {
+		return nil, ErrTooManyFiles
+	}
+	for _, fh := range files {
+		if err := limits.check(fh); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// CheckFile checks the uploaded file fh against the limits of options, its
+// size, its extension and its content type sniffed from its first bytes.
+func CheckFile(fh *multipart.FileHeader, options ...FileOption) error {
+	var limits fileLimits
+	for _, o := range options {
+		o(&limits)
+	}
+	return limits.check(fh)
+}
+
+func (l *fileLimits) check(fh *multipart.FileHeader) error {
+	if l.maxSize > 0 && fh.Size > l.maxSize {
+		return fmt.Errorf("%s: %w", fh.Filename, ErrFileTooLarge)
+	}
+	if len(l.extensions) == 0 && len(l.contentTypes) == 0 {
+		return nil
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if !l.allowed(fh.Filename, http.DetectContentType(head[:n])) {
+		return fmt.Errorf("%s: %w", fh.Filename, ErrFileType)
+	}
+	return nil
+}
+
+// allowed reports whether the extension of the file name and the sniffed
+// content type ct are allowed.
+func (l *fileLimits) allowed(name, ct string) bool {
+	if len(l.extensions) > 0 {
+		ext := filepath.Ext(name)
+		ok := false
+		for _, e := range l.extensions {
+			if strings.EqualFold(e, ext) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(l.contentTypes) == 0 {
+		return true
+	}
+	ct = strings.ToLower(ct)
+	if n := strings.Index(ct, ";"); n >= 0 {
+		ct = strings.TrimSpace(ct[:n])
+	}
+	for _, t := range l.contentTypes {
+		if mediaRangeMatch(strings.ToLower(t), ct) >= 0 {
+			return true
+		}
+	}
+	return false
+}
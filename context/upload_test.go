@@ -0,0 +1,128 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// the magic bytes of the files, to be sniffed
+const (
+	mp4Header = "\x00\x00\x00\x0cftypmp42"
+	pngHeader = "\x89PNG\r\n\x1a\n"
+	pdfHeader = "%PDF-1.4\n"
+)
+
+type testFile struct {
+	name, filename, contentType, data string
+}
+
+func multipartRequest(values map[string]string, files ...testFile) *http.Request {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for k, v := range values {
+		w.WriteField(k, v)
+	}
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="`+f.name+`"; filename="`+f.filename+`"`)
+		h.Set("Content-Type", f.contentType)
+		p, _ := w.CreatePart(h)
+		p.Write([]byte(f.data))
+	}
+	w.Close()
+	r, _ := http.NewRequest("POST", "/upload?dir=videos", &b)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	return r
+}
+
+func TestEachFile(t *testing.T) {
+	r := multipartRequest(map[string]string{"title": "holidays"},
+		testFile{"video", "a.mp4", "video/mp4", mp4Header + "first"},
+		testFile{"video", "b.MP4", "video/mp4", mp4Header + "second"})
+	input := NewInput(r)
+	var got []string
+	err := input.EachFile(func(p *FilePart) error {
+		b, err := ioutil.ReadAll(p)
+		got = append(got, p.FormName()+":"+p.FileName()+":"+p.ContentType+":"+strings.TrimPrefix(string(b), mp4Header))
+		return err
+	}, MaxFileSize(18), MaxFiles(2), AllowExtensions(".mp4"), AllowContentTypes("video/*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "video:a.mp4:video/mp4:first,video:b.MP4:video/mp4:second" {
+		t.Errorf("got the files %v", got)
+	}
+	if input.Query("title") != "holidays" || input.Query("dir") != "videos" {
+		t.Errorf("got the form %v, want the body values and the query", r.Form)
+	}
+}
+
+func TestEachFileLimits(t *testing.T) {
+	files := []testFile{
+		{"doc", "a.png", "image/png", pngHeader + "data"},
+		{"doc", "b.pdf", "application/pdf", pdfHeader + "data"},
+	}
+	read := func(p *FilePart) error {
+		_, err := ioutil.ReadAll(p)
+		return err
+	}
+	cases := []struct {
+		options []FileOption
+		err     error
+	}{
+		{[]FileOption{MaxFileSize(12)}, ErrFileTooLarge},
+		{[]FileOption{MaxFileSize(13)}, nil},
+		{[]FileOption{MaxFiles(1)}, ErrTooManyFiles},
+		{[]FileOption{AllowExtensions(".png")}, ErrFileType},
+		{[]FileOption{AllowExtensions(".PNG", ".pdf")}, nil},
+		{[]FileOption{AllowContentTypes("image/*")}, ErrFileType},
+		{[]FileOption{AllowContentTypes("image/png", "application/pdf")}, nil},
+		{[]FileOption{AllowContentTypes("image/*", "application/*")}, nil},
+	}
+	for i, c := range cases {
+		err := NewInput(multipartRequest(nil, files...)).EachFile(read, c.options...)
+		if !errors.Is(err, c.err) {
+			t.Errorf("case %d: got %v, want %v", i, err, c.err)
+		}
+		_, err = NewInput(multipartRequest(nil, files...)).Files("doc", c.options...)
+		if !errors.Is(err, c.err) {
+			t.Errorf("case %d: Files got %v, want %v", i, err, c.err)
+		}
+	}
+
+	// the type sent by the client isn't trusted
+	spoofed := testFile{"doc", "a.png", "image/png", "<html><script>"}
+	err := NewInput(multipartRequest(nil, spoofed)).EachFile(read, AllowContentTypes("image/png"))
+	if !errors.Is(err, ErrFileType) {
+		t.Errorf("got %v, want ErrFileType for a spoofed file", err)
+	}
+	if _, err := NewInput(multipartRequest(nil)).Files("doc"); err != http.ErrMissingFile {
+		t.Errorf("got %v, want http.ErrMissingFile without files", err)
+	}
+
+	r := multipartRequest(nil, files...)
+	r.ParseMultipartForm(1 << 20)
+	if err := NewInput(r).EachFile(read); err != ErrMultipartParsed {
+		t.Errorf("got %v, want ErrMultipartParsed for a parsed body", err)
+	}
+}
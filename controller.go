@@ -16,6 +16,7 @@ package beego
 
 import (
 	"bytes"
+	gocontext "context"
 	"errors"
 	"html/template"
 	"io"
@@ -24,12 +25,15 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 
 	"github.com/aamsur/beego/context"
 	"github.com/aamsur/beego/session"
+	"github.com/aamsur/beego/utils/markdown"
 )
 
 //commonly used mime-types
@@ -37,6 +41,7 @@ const (
 	applicationJson = "application/json"
 	applicationXml  = "application/xml"
 	textXml         = "text/xml"
+	textHtml        = "text/html"
 )
 
 var (
@@ -63,6 +68,7 @@ type Controller struct {
 	TplNames       string
 	Layout         string
 	LayoutSections map[string]string // the key is the section name and the value is the template name
+	Fragment       string            // the block of TplNames rendered without the layout for htmx requests
 	TplExt         string
 	_xsrf_token    string
 	gotofunc       string
@@ -97,6 +103,7 @@ type ControllerInterface interface {
 func (c *Controller) Init(ctx *context.Context, controllerName, actionName string, app interface{}) {
 	c.Layout = ""
 	c.TplNames = ""
+	c.Fragment = ""
 	c.controllerName = controllerName
 	c.actionName = actionName
 	c.Ctx = ctx
@@ -172,10 +179,17 @@ func (c *Controller) Mapping(method string, fn func()) {
 }
 
 // Render sends the response with rendered template bytes as text/html type.
+// if Fragment is set, htmx requests get only that block.
 func (c *Controller) Render() error {
 	if !c.EnableRender {
 		return nil
 	}
+	if c.Fragment != "" {
+		c.Ctx.Output.Header("Vary", "HX-Request")
+		if c.Ctx.Input.IsHtmx() {
+			return c.RenderFragment(c.Fragment)
+		}
+	}
 	rb, err := c.RenderBytes()
 
 	if err != nil {
@@ -187,6 +201,74 @@ func (c *Controller) Render() error {
 	return nil
 }
 
+// RenderFragment sends only the named block of the template, without the layout.
+//	// {{define "rows"}}...{{end}} in user/list.tpl
+//	if c.Ctx.Input.IsHtmx() {
+//		c.RenderFragment("rows")
+//	}
+func (c *Controller) RenderFragment(name string) error {
+	rb, err := c.RenderFragmentBytes(name)
+	if err != nil {
+		return err
+	}
+	c.Ctx.Output.Header("Content-Type", "text/html; charset=utf-8")
+	c.Ctx.Output.Body(rb)
+	return nil
+}
+
+// RenderFragmentBytes returns the bytes of the named block of the template. Do not send out response.
+func (c *Controller) RenderFragmentBytes(name string) ([]byte, error) {
+	if c.TplNames == "" {
+		c.TplNames = strings.ToLower(c.controllerName) + "/" + strings.ToLower(c.actionName) + "." + c.TplExt
+	}
+	tpl, ok := lookupTemplate(c.TplNames)
+	if !ok {
+		panic("can't find templatefile in the path:" + c.TplNames)
+	}
+	if tpl.Lookup(name) == nil {
+		return nil, errors.New("template " + c.TplNames + " has no block " + name)
+	}
+	ibytes := bytes.NewBufferString("")
+	if err := tpl.ExecuteTemplate(ibytes, name, c.Data); err != nil {
+		Trace("template Execute err:", err)
+		return nil, err
+	}
+	return ibytes.Bytes(), nil
+}
+
+// RenderMarkdown sends a markdown file rendered to sanitized html,
+// as LayoutContent of the Layout when it's set.
+//	c.Layout = "docs/layout.tpl"
+//	c.RenderMarkdown("docs/changelog.md")
+func (c *Controller) RenderMarkdown(file string) error {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return c.RenderMarkdownData(src)
+}
+
+// RenderMarkdownData is RenderMarkdown with the markdown source.
+func (c *Controller) RenderMarkdownData(src []byte) error {
+	content := markdown.Render(src)
+	if c.Layout != "" {
+		c.Data["LayoutContent"] = template.HTML(content)
+		layout, ok := lookupTemplate(c.Layout)
+		if !ok {
+			panic("can't find templatefile in the path:" + c.Layout)
+		}
+		ibytes := bytes.NewBufferString("")
+		if err := layout.ExecuteTemplate(ibytes, c.Layout, c.Data); err != nil {
+			Trace("template Execute err:", err)
+			return err
+		}
+		content = ibytes.Bytes()
+	}
+	c.Ctx.Output.Header("Content-Type", "text/html; charset=utf-8")
+	c.Ctx.Output.Body(content)
+	return nil
+}
+
 // RenderString returns the rendered template string. Do not send out response.
 func (c *Controller) RenderString() (string, error) {
 	b, e := c.RenderBytes()
@@ -200,14 +282,12 @@ func (c *Controller) RenderBytes() ([]byte, error) {
 		if c.TplNames == "" {
 			c.TplNames = strings.ToLower(c.controllerName) + "/" + strings.ToLower(c.actionName) + "." + c.TplExt
 		}
-		if RunMode == "dev" {
-			BuildTemplate(ViewsPath)
-		}
 		newbytes := bytes.NewBufferString("")
-		if _, ok := BeeTemplates[c.TplNames]; !ok {
+		tpl, ok := lookupTemplate(c.TplNames)
+		if !ok {
 			panic("can't find templatefile in the path:" + c.TplNames)
 		}
-		err := BeeTemplates[c.TplNames].ExecuteTemplate(newbytes, c.TplNames, c.Data)
+		err := tpl.ExecuteTemplate(newbytes, c.TplNames, c.Data)
 		if err != nil {
 			Trace("template Execute err:", err)
 			return nil, err
@@ -223,7 +303,11 @@ func (c *Controller) RenderBytes() ([]byte, error) {
 				}
 
 				sectionBytes := bytes.NewBufferString("")
-				err = BeeTemplates[sectionTpl].ExecuteTemplate(sectionBytes, sectionTpl, c.Data)
+				tpl, ok := lookupTemplate(sectionTpl)
+				if !ok {
+					panic("can't find templatefile in the path:" + sectionTpl)
+				}
+				err = tpl.ExecuteTemplate(sectionBytes, sectionTpl, c.Data)
 				if err != nil {
 					Trace("template Execute err:", err)
 					return nil, err
@@ -234,7 +318,11 @@ func (c *Controller) RenderBytes() ([]byte, error) {
 		}
 
 		ibytes := bytes.NewBufferString("")
-		err = BeeTemplates[c.Layout].ExecuteTemplate(ibytes, c.Layout, c.Data)
+		layout, ok := lookupTemplate(c.Layout)
+		if !ok {
+			panic("can't find templatefile in the path:" + c.Layout)
+		}
+		err = layout.ExecuteTemplate(ibytes, c.Layout, c.Data)
 		if err != nil {
 			Trace("template Execute err:", err)
 			return nil, err
@@ -245,14 +333,12 @@ func (c *Controller) RenderBytes() ([]byte, error) {
 		if c.TplNames == "" {
 			c.TplNames = strings.ToLower(c.controllerName) + "/" + strings.ToLower(c.actionName) + "." + c.TplExt
 		}
-		if RunMode == "dev" {
-			BuildTemplate(ViewsPath)
-		}
 		ibytes := bytes.NewBufferString("")
-		if _, ok := BeeTemplates[c.TplNames]; !ok {
+		tpl, ok := lookupTemplate(c.TplNames)
+		if !ok {
 			panic("can't find templatefile in the path:" + c.TplNames)
 		}
-		err := BeeTemplates[c.TplNames].ExecuteTemplate(ibytes, c.TplNames, c.Data)
+		err := tpl.ExecuteTemplate(ibytes, c.TplNames, c.Data)
 		if err != nil {
 			Trace("template Execute err:", err)
 			return nil, err
@@ -277,7 +363,16 @@ func (c *Controller) Abort(code string) {
 }
 
 // CustomAbort stops controller handler and show the error data, it's similar Aborts, but support status code and body.
+// the clients accepting json get the HTTPError of status, unless the app
+// registered an error handler for status or body.
 func (c *Controller) CustomAbort(status int, body string) {
+	if status >= 400 && wantsJSON(c.Ctx) && !appErrorHandler(strconv.Itoa(status)) && !appErrorHandler(body) {
+		message := body
+		if _, ok := ErrorMaps[body]; ok || body == strconv.Itoa(status) {
+			message = ""
+		}
+		panic(NewHTTPError(status, "", message))
+	}
 	c.Ctx.ResponseWriter.WriteHeader(status)
 	// first panic from ErrorMaps, is is user defined error functions.
 	if _, ok := ErrorMaps[body]; ok {
@@ -288,6 +383,13 @@ func (c *Controller) CustomAbort(status int, body string) {
 	panic(USERSTOPRUN)
 }
 
+// AbortWithError stops controller handler and sends err as its HTTPError, see
+// MapError. the errors not mapped are sent as internal errors.
+func (c *Controller) AbortWithError(err error) {
+	RenderError(c.Ctx, err)
+	panic(USERSTOPRUN)
+}
+
 // StopRun makes panic of USERSTOPRUN error and go to recover function if defined.
 func (c *Controller) StopRun() {
 	panic(USERSTOPRUN)
@@ -306,6 +408,19 @@ func (c *Controller) UrlFor(endpoint string, values ...interface{}) string {
 	}
 }
 
+// AbsUrlFor returns the UrlFor url prefixed by BaseURL, or by the site of
+// this request when BaseURL isn't set, see context.BeegoInput.Site.
+func (c *Controller) AbsUrlFor(endpoint string, values ...interface{}) string {
+	u := c.UrlFor(endpoint, values...)
+	if u == "" {
+		return ""
+	}
+	if BaseURL != "" {
+		return BaseURL + u
+	}
+	return c.Ctx.Input.Site() + u
+}
+
 // ServeJson sends a json response with encoding charset.
 func (c *Controller) ServeJson(encoding ...bool) {
 	var hasIndent bool
@@ -343,19 +458,50 @@ func (c *Controller) ServeXml() {
 	c.Ctx.Output.Xml(c.Data["xml"], hasIndent)
 }
 
-// ServeFormatted serve Xml OR Json, depending on the value of the Accept header
+// ServeFormatted sends c.Data in the format preferred by the Accept header,
+// DefaultFormat when none is acceptable. the formats are the registered
+// ones, see context.RegisterFormat, and html rendering the template when
+// TplNames is set. the data is c.Data[format], c.Data["json"] when unset.
 func (c *Controller) ServeFormatted() {
-	accept := c.Ctx.Input.Header("Accept")
-	switch accept {
-	case applicationJson:
+	offers := context.FormatMediaTypes(DefaultFormat)
+	if c.TplNames != "" {
+		offers = append(offers, textHtml)
+	}
+	format := DefaultFormat
+	if mediatype := c.Ctx.Input.Negotiate(offers...); mediatype == textHtml {
+		format = "html"
+	} else if mediatype != "" {
+		format = context.FormatOf(mediatype)
+	}
+	c.Ctx.Output.Header("Vary", "Accept")
+	switch format {
+	case "html":
+		c.Render()
+	case "json":
 		c.ServeJson()
-	case applicationXml, textXml:
+	case "xml":
+		if _, ok := c.Data["xml"]; !ok {
+			c.Data["xml"] = c.Data["json"]
+		}
 		c.ServeXml()
 	default:
-		c.ServeJson()
+		data, ok := c.Data[format]
+		if !ok {
+			data = c.Data["json"]
+		}
+		c.Ctx.Output.Format(format, data, RunMode != "prod")
 	}
 }
 
+// Context returns the context of the request, canceled when the client goes
+// away or the request times out, see context.Context.Context. the work of
+// the long running actions should be done with it:
+//	o := orm.NewOrmWithContext(c.Context())
+//	body, err := httplib.Get(url).WithContext(c.Context()).String()
+func (c *Controller) Context() gocontext.Context {
+	return c.Ctx.Context()
+}
+
 // Input returns the input data map from POST or PUT request body and query string.
 func (c *Controller) Input() url.Values {
 	if c.Ctx.Request.Form == nil {
@@ -369,6 +515,22 @@ func (c *Controller) ParseForm(obj interface{}) error {
 	return ParseForm(c.Input(), obj)
 }
 
+// BindForm populates the struct obj points to from the query string and the
+// form values, see context.BeegoInput.BindForm.
+func (c *Controller) BindForm(obj interface{}, options ...context.FormOption) error {
+	return c.Ctx.Input.BindForm(obj, options...)
+}
+
+// BindJSON decodes the json request body into obj, see context.BeegoInput.BindJSON.
+func (c *Controller) BindJSON(obj interface{}, options ...context.JSONOption) error {
+	return c.Ctx.Input.BindJSON(obj, options...)
+}
+
+// BindXML decodes the xml request body into obj, see context.BeegoInput.BindXML.
+func (c *Controller) BindXML(obj interface{}) error {
+	return c.Ctx.Input.BindXML(obj)
+}
+
 // GetString returns the input value by key string or the default value while it's present and input is blank
 func (c *Controller) GetString(key string, def ...string) string {
 	var defv string
@@ -515,27 +677,113 @@ func (c *Controller) GetFile(key string) (multipart.File, *multipart.FileHeader,
 	return c.Ctx.Request.FormFile(key)
 }
 
+// GetFiles returns the uploaded files of the form field key, checked against
+// the limits of options, see context.FileOption.
+//	files, err := c.GetFiles("photos", context.MaxFiles(10),
+//		context.AllowContentTypes("image/png", "image/jpeg"))
+func (c *Controller) GetFiles(key string, options ...context.FileOption) ([]*multipart.FileHeader, error) {
+	return c.Ctx.Input.Files(key, options...)
+}
+
 // SaveToFile saves uploaded file to new path.
-// it only operates the first one of mutil-upload form file field.
-func (c *Controller) SaveToFile(fromfile, tofile string) error {
-	file, _, err := c.Ctx.Request.FormFile(fromfile)
-	if err != nil {
+// it only operates the first one of mutil-upload form file field, unless
+// tofile is a directory: every file of the field is saved in it, see SaveToDir.
+// the file is checked against the limits of options first.
+func (c *Controller) SaveToFile(fromfile, tofile string, options ...context.FileOption) error {
+	if fi, err := os.Stat(tofile); err == nil && fi.IsDir() {
+		_, err = c.SaveToDir(fromfile, tofile, options...)
 		return err
 	}
-	defer file.Close()
 	f, err := os.OpenFile(tofile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	io.Copy(f, file)
-	return nil
+	if _, err = c.SaveToWriter(fromfile, f, options...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SaveToWriter copies the first uploaded file of the form field fromfile to
+// w, like to a storage bucket, after checking it against the limits of
+// options.
+func (c *Controller) SaveToWriter(fromfile string, w io.Writer, options ...context.FileOption) (int64, error) {
+	files, err := c.GetFiles(fromfile, options...)
+	if err != nil {
+		return 0, err
+	}
+	return copyFile(w, files[0])
+}
+
+// SaveToDir saves every uploaded file of the form field fromfile in the
+// directory dir, after checking them against the limits of options. the
+// files keep the base of their client names, suffixed by -1, -2... to not
+// overwrite an existing file. it returns the paths of the saved files.
+func (c *Controller) SaveToDir(fromfile, dir string, options ...context.FileOption) ([]string, error) {
+	files, err := c.GetFiles(fromfile, options...)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files))
+	for _, fh := range files {
+		f, err := createUnique(dir, fh.Filename)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, f.Name())
+		_, err = copyFile(f, fh)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, err
+		}
+	}
+	return paths, nil
+}
+
+func copyFile(w io.Writer, fh *multipart.FileHeader) (int64, error) {
+	file, err := fh.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(w, file)
+}
+
+// createUnique creates a new file of the base of the client file name name
+// in dir, suffixing the name by -1, -2... when the file exists.
+func createUnique(dir, name string) (*os.File, error) {
+	// the client name may be a windows path, or try to leave dir
+	name = path.Base(strings.Replace(name, "\\", "/", -1))
+	if name == "." || name == "/" || name == ".." {
+		name = "file"
+	}
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 0; ; i++ {
+		if i > 0 {
+			name = stem + "-" + strconv.Itoa(i) + ext
+		}
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) || i == 10000 {
+			return f, err
+		}
+	}
 }
 
 // StartSession starts session and load old session data info this controller.
+// the session is started when it's first used, a failing session store
+// aborts with 503.
 func (c *Controller) StartSession() session.SessionStore {
 	if c.CruSession == nil {
-		c.CruSession = c.Ctx.Input.CruSession
+		sess, err := c.Ctx.Input.StartSession()
+		if err != nil {
+			Error(err)
+			c.Abort("503")
+		}
+		c.CruSession = sess
 	}
 	return c.CruSession
 }
@@ -576,7 +824,7 @@ func (c *Controller) SessionRegenerateID() {
 
 // DestroySession cleans session data and session cookie.
 func (c *Controller) DestroySession() {
-	c.Ctx.Input.CruSession.Flush()
+	c.StartSession().Flush()
 	GlobalSessions.SessionDestroy(c.Ctx.ResponseWriter, c.Ctx.Request)
 }
 
@@ -604,7 +852,11 @@ func (c *Controller) XsrfToken() string {
 		} else {
 			expire = int64(XSRFExpire)
 		}
-		c._xsrf_token = c.Ctx.XsrfToken(XSRFKEY, expire)
+		if XSRFMode == "cookie" {
+			c._xsrf_token = c.Ctx.DoubleSubmitToken(XSRFKEY, XSRFCookieName, expire)
+		} else {
+			c._xsrf_token = c.Ctx.XsrfToken(XSRFKEY, expire)
+		}
 	}
 	return c._xsrf_token
 }
@@ -612,10 +864,14 @@ func (c *Controller) XsrfToken() string {
 // CheckXsrfCookie checks xsrf token in this request is valid or not.
 // the token can provided in request header "X-Xsrftoken" and "X-CsrfToken"
 // or in form field value named as "_xsrf".
+// in "cookie" XSRFMode the token is read from header XSRFHeaderName.
 func (c *Controller) CheckXsrfCookie() bool {
 	if !c.EnableXSRF {
 		return true
 	}
+	if XSRFMode == "cookie" {
+		return c.Ctx.CheckDoubleSubmit(XSRFKEY, XSRFCookieName, XSRFHeaderName)
+	}
 	return c.Ctx.CheckXsrfCookie()
 }
 
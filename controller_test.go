@@ -15,7 +15,20 @@
 package beego
 
 import (
+	"bytes"
+	gocontext "context"
+	"encoding/xml"
 	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
 	"github.com/aamsur/beego/context"
 )
 
@@ -73,3 +86,120 @@ func ExampleGetInt64() {
 	fmt.Printf("%T", val)
 	//Output: int64
 }
+
+type formattedUser struct {
+	XMLName xml.Name `xml:"user"`
+	Name    string   `xml:"name"`
+}
+
+type formattedController struct {
+	Controller
+}
+
+func (c *formattedController) Get() {
+	c.Data["json"] = map[string]string{"name": "beego"}
+	c.Data["xml"] = formattedUser{Name: "beego"}
+	c.ServeFormatted()
+}
+
+func TestServeFormatted(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Add("/formatted", &formattedController{})
+	tests := []struct {
+		accept, contentType, body string
+	}{
+		{"", "application/json", `"name": "beego"`},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", "application/xml", "<name>beego</name>"},
+		{"application/xml;q=0.5, text/json", "application/json", `"name": "beego"`},
+		{"image/png", "application/json", `"name": "beego"`},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/formatted", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if ct := w.HeaderMap.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+			t.Errorf("%q: got Content-Type %q, want %q", tt.accept, ct, tt.contentType)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%q: got %q, want %q", tt.accept, w.Body.String(), tt.body)
+		}
+		if w.HeaderMap.Get("Vary") != "Accept" {
+			t.Errorf("%q: Vary should be Accept", tt.accept)
+		}
+	}
+}
+
+type contextController struct {
+	Controller
+}
+
+func (c *contextController) Get() {
+	c.Ctx.WriteString(c.Context().Value(contextKey{}).(string))
+}
+
+type contextKey struct{}
+
+func TestControllerContext(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Add("/context", &contextController{})
+	handler.InsertFilter("/context", BeforeExec, func(ctx *context.Context) {
+		ctx.SetContext(gocontext.WithValue(ctx.Context(), contextKey{}, "value"))
+	})
+
+	r, _ := http.NewRequest("GET", "/context", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "value" {
+		t.Errorf("got %q, want the value of the filter context", w.Body.String())
+	}
+
+	reqctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r.WithContext(reqctx))
+	if w.Body.Len() != 0 {
+		t.Errorf("the controller of a gone client should not run, got %q", w.Body.String())
+	}
+}
+
+func TestSaveToDir(t *testing.T) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for _, name := range []string{"a.png", `C:\photos\a.png`, "../../b.png"} {
+		f, _ := w.CreateFormFile("photos", name)
+		f.Write([]byte("\x89PNG\r\n\x1a\n" + name))
+	}
+	w.Close()
+	r, _ := http.NewRequest("POST", "/", &b)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	c := &Controller{Ctx: &context.Context{Input: context.NewInput(r), Request: r}}
+
+	dir, err := ioutil.TempDir("", "beego-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := c.SaveToDir("photos", dir, context.AllowContentTypes("image/jpeg")); err == nil {
+		t.Error("png files should not be saved as jpeg ones")
+	}
+	paths, err := c.SaveToDir("photos", dir, context.AllowContentTypes("image/png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	var got []string
+	for _, p := range paths {
+		data, _ := ioutil.ReadFile(p)
+		got = append(got, filepath.Base(p)+":"+strings.TrimPrefix(string(data), "\x89PNG\r\n\x1a\n"))
+	}
+	want := `a-1.png:C:\photos\a.png,a.png:a.png,b.png:../../b.png`
+	if strings.Join(got, ",") != want || filepath.Dir(paths[2]) != dir {
+		t.Errorf("got the files %v, want %s in the directory", got, want)
+	}
+
+	var buf bytes.Buffer
+	if _, err := c.SaveToWriter("photos", &buf); err != nil || !strings.HasSuffix(buf.String(), "a.png") {
+		t.Errorf("got %q %v, want the first file", buf.String(), err)
+	}
+}
@@ -16,12 +16,120 @@ package beego
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/swagger/clientgen"
+	"github.com/aamsur/beego/swagger/openapi"
 )
 
 var GlobalDocApi map[string]interface{}
 
+// GlobalControllerDocs are the docs of the controller methods parsed from their
+// comments, keyed by pkgpath:controller.method.
+var GlobalControllerDocs = make(map[string]OperationDoc)
+
+// OperationDoc documents a controller method, from its comments:
+//
+//	// @Title CreateUser
+//	// @Summary create a user
+//	// @Description the login must be unique
+//	// @Tags users
+//	// @Param body body models.User true "the user"
+//	// @Param notify query bool false "mail the user"
+//	// @Success 201 {object} models.User
+//	// @Failure 422 the user is invalid
+//	// @Security bearer
+//	// @Callback created {$request.body#/callback} post models.User "the user created"
+//	// @Example request {"login": "gopher"}
+//	// @Example 201 {"id": 1, "login": "gopher"}
+//	// @Deprecated
+//	// @router / [post]
+//
+// the types are basic types like string, int or bool, []T, A|B for oneOf, or the
+// models registered by DocModel.
+type OperationDoc struct {
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	Request     interface{} // the model of the request, its json body or its query for GET, HEAD and DELETE
+	Example     interface{} // the example of the request body
+	Params      []ParamDoc
+	Responses   []ResponseDoc
+	Security    []SecurityDoc
+	Callbacks   []CallbackDoc
+}
+
+// ParamDoc is a @Param of an OperationDoc.
+type ParamDoc struct {
+	Name        string
+	In          string // query, path, header, cookie, form or body
+	Type        string
+	Required    bool
+	Description string
+}
+
+// ResponseDoc is a @Success or @Failure of an OperationDoc.
+type ResponseDoc struct {
+	Code        string
+	Type        string
+	Description string
+	Model       interface{} // the response model, instead of Type
+	Example     interface{} // the example of the response body
+}
+
+// SecurityDoc is a @Security of an OperationDoc, the name of a security scheme.
+type SecurityDoc struct {
+	Name   string
+	Scopes []string
+}
+
+// CallbackDoc is a @Callback of an OperationDoc.
+type CallbackDoc struct {
+	Name        string
+	Expression  string
+	Method      string
+	Type        string
+	Description string
+}
+
+var (
+	docModels = make(map[string]reflect.Type)
+	docsHooks []func(*openapi.Document)
+)
+
+// DocModel registers the types of models for the docs, named by their
+// package and type name like models.User.
+//
+//	beego.DocModel(models.User{}, models.Order{})
+func DocModel(models ...interface{}) {
+	for _, m := range models {
+		t := reflect.TypeOf(m)
+		docModels[openapi.SchemaName(t)] = t
+	}
+}
+
+// OnDocs registers a function completing the OpenAPI docs, with the servers,
+// the security schemes or the info.
+//
+//	beego.OnDocs(func(doc *openapi.Document) {
+//		doc.Components.SecuritySchemes["bearer"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer"}
+//	})
+func OnDocs(fn func(doc *openapi.Document)) {
+	docsHooks = append(docsHooks, fn)
+}
+
 func init() {
 	if EnableDocs {
 		GlobalDocApi = make(map[string]interface{})
@@ -30,7 +138,11 @@ func init() {
 
 func serverDocs(ctx *context.Context) {
 	var obj interface{}
-	if splat := ctx.Input.Param(":splat"); splat == "" {
+	if DocsFormat != "swagger" {
+		if ctx.Input.Param(":splat") == "" {
+			obj = OpenAPI()
+		}
+	} else if splat := ctx.Input.Param(":splat"); splat == "" {
 		obj = GlobalDocApi["Root"]
 	} else {
 		if v, ok := GlobalDocApi[splat]; ok {
@@ -50,3 +162,387 @@ func serverDocs(ctx *context.Context) {
 	}
 	ctx.Output.SetStatus(404)
 }
+
+// OpenAPI returns the OpenAPI 3.0 docs of the routes of BeeApp, built from the
+// router at runtime with the docs of the controller methods.
+func OpenAPI() *openapi.Document {
+	doc := openapi.NewDocument(AppName, "1.0.0")
+	addDocPaths(doc, BeeApp.Handlers)
+	for _, fn := range docsHooks {
+		fn(doc)
+	}
+	return doc
+}
+
+// GenerateClient writes the clients of the routes of BeeApp to dir: a Go package
+// named pkg in client.go and, when typescript is true, a TypeScript module in
+// client.ts. it's called once the routes are registered:
+//
+//	if *genClient {
+//		beego.GenerateClient("clients/userapi", "userapi", true)
+//		return
+//	}
+//	beego.Run()
+func GenerateClient(dir, pkg string, typescript bool) error {
+	doc := OpenAPI()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	src, err := clientgen.Go(doc, pkg)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "client.go"), src, 0644); err != nil {
+		return err
+	}
+	if !typescript {
+		return nil
+	}
+	if src, err = clientgen.TypeScript(doc); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "client.ts"), src, 0644)
+}
+
+// Document sets the doc of a controller method, instead of its comments:
+//
+//	beego.Document(&UserController{}, "Post", beego.OperationDoc{
+//		Summary:   "create a user",
+//		Request:   models.User{},
+//		Responses: []beego.ResponseDoc{{Code: "201", Model: models.User{}}},
+//	})
+func Document(c ControllerInterface, method string, doc OperationDoc) {
+	t := reflect.Indirect(reflect.ValueOf(c)).Type()
+	GlobalControllerDocs[t.PkgPath()+":"+t.Name()+"."+method] = doc
+}
+
+var controllerType = reflect.TypeOf(Controller{})
+
+// addDocPaths adds the routes of p to doc:
+//   - the controller methods mapped to http methods
+//   - the Get, Post... methods of the controllers routed without mapping,
+//     unless they're the ones of the embedded Controller
+//   - the functions routed for an http method
+//   - the controller methods mapped to * with a doc
+//
+// the handlers and the functions routed for * can't be documented.
+func addDocPaths(doc *openapi.Document, p *ControllerRegistor) {
+	routers := p.table().routers
+	methods := make([]string, 0, len(routers))
+	for method := range routers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		walkRoutes(routers[method], func(route *controllerInfo) {
+			var op *openapi.Operation
+			switch route.routerType {
+			case routerTypeBeego:
+				op = controllerOperation(doc.Components, route, method)
+			case routerTypeRESTFul:
+				if len(route.methods) == 1 && !isDocsRoute(route.runfunction) {
+					op = &openapi.Operation{Responses: map[string]*openapi.Response{"200": {Description: "OK"}}}
+				}
+			}
+			if op == nil {
+				return
+			}
+			path, params := openapiPath(route.pattern)
+			item := doc.Paths[path]
+			if item == nil {
+				item = &openapi.PathItem{}
+				doc.Paths[path] = item
+			}
+			addPathParams(op, params)
+			item.SetOperation(method, op)
+		})
+	}
+}
+
+// controllerOperation returns the operation of a controller route for an http method, or nil.
+func controllerOperation(components *openapi.Components, route *controllerInfo, method string) *openapi.Operation {
+	t := route.controllerType
+	name, mapped := route.methods[method]
+	any := false
+	if !mapped {
+		name, any = route.methods["*"]
+	}
+	if !mapped && !any {
+		if len(route.methods) > 0 || t == controllerType {
+			return nil
+		}
+		name = strings.Title(strings.ToLower(method))
+		if !declaresMethod(t, name) {
+			return nil
+		}
+	}
+	opDoc, ok := GlobalControllerDocs[t.PkgPath()+":"+t.Name()+"."+name]
+	if !ok && any {
+		return nil
+	}
+	return opDoc.operation(components, t.Name(), name, method)
+}
+
+// declaresMethod returns whether the controller type t declares the method name,
+// it's not promoted from the embedded Controller.
+func declaresMethod(t reflect.Type, name string) bool {
+	m, ok := reflect.PtrTo(t).MethodByName(name)
+	if !ok {
+		return false
+	}
+	pc := m.Func.Pointer()
+	file, _ := runtime.FuncForPC(pc).FileLine(pc)
+	return file != "<autogenerated>"
+}
+
+func walkRoutes(t *Tree, fn func(*controllerInfo)) {
+	keys := make([]string, 0, len(t.fixrouters))
+	for k := range t.fixrouters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		walkRoutes(t.fixrouters[k], fn)
+	}
+	if t.wildcard != nil {
+		walkRoutes(t.wildcard, fn)
+	}
+	for _, l := range t.leaves {
+		if route, ok := l.runObject.(*controllerInfo); ok {
+			fn(route)
+		}
+	}
+}
+
+var routeParamRegexp = regexp.MustCompile(`:(\w+)(?::\w+|\([^)]*\))?|\*\.\*|\*`)
+
+// openapiPath returns the OpenAPI path of a route pattern and its parameters:
+// /user/:id:int is /user/{id}, * is {splat} and *.* is {path}.{ext}.
+func openapiPath(pattern string) (string, []string) {
+	var params []string
+	path := routeParamRegexp.ReplaceAllStringFunc(pattern, func(m string) string {
+		switch m {
+		case "*":
+			params = append(params, "splat")
+			return "{splat}"
+		case "*.*":
+			params = append(params, "path", "ext")
+			return "{path}.{ext}"
+		}
+		name := routeParamRegexp.FindStringSubmatch(m)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+// addPathParams adds the parameters of the path not documented by op.
+func addPathParams(op *openapi.Operation, params []string) {
+	for _, name := range params {
+		documented := false
+		for _, p := range op.Parameters {
+			if p.In == "path" && p.Name == name {
+				documented = true
+			}
+		}
+		if !documented {
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name: name, In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+	}
+}
+
+func (d OperationDoc) operation(components *openapi.Components, controller, method, httpMethod string) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: d.OperationID,
+		Summary:     d.Summary,
+		Description: d.Description,
+		Tags:        d.Tags,
+		Deprecated:  d.Deprecated,
+		Responses:   make(map[string]*openapi.Response),
+	}
+	if op.OperationID == "" {
+		op.OperationID = controller + "." + method
+	}
+	if len(op.Tags) == 0 {
+		op.Tags = []string{strings.TrimSuffix(controller, "Controller")}
+	}
+
+	for _, p := range d.Params {
+		switch p.In {
+		case "body":
+			op.RequestBody = &openapi.RequestBody{
+				Description: p.Description,
+				Required:    p.Required,
+				Content:     map[string]*openapi.MediaType{"application/json": {Schema: docSchema(components, p.Type), Example: d.Example}},
+			}
+		case "form", "formData":
+			contentType := "application/x-www-form-urlencoded"
+			if p.Type == "file" {
+				contentType = "multipart/form-data"
+			}
+			form := formSchema(op, contentType)
+			form.Properties[p.Name] = docSchema(components, p.Type)
+			form.Properties[p.Name].Description = p.Description
+			if p.Required {
+				form.Required = append(form.Required, p.Name)
+			}
+		default:
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:        p.Name,
+				In:          p.In,
+				Description: p.Description,
+				Required:    p.Required || p.In == "path",
+				Schema:      docSchema(components, p.Type),
+			})
+		}
+	}
+
+	if d.Request != nil {
+		t := reflect.TypeOf(d.Request)
+		if httpMethod == "GET" || httpMethod == "HEAD" || httpMethod == "DELETE" {
+			op.Parameters = append(op.Parameters, queryParams(components, t)...)
+		} else {
+			op.RequestBody = &openapi.RequestBody{
+				Required: true,
+				Content:  map[string]*openapi.MediaType{"application/json": {Schema: components.SchemaOf(t), Example: d.Example}},
+			}
+		}
+	}
+
+	for _, r := range d.Responses {
+		resp := &openapi.Response{Description: r.Description}
+		if resp.Description == "" {
+			code, _ := strconv.Atoi(r.Code)
+			resp.Description = http.StatusText(code)
+		}
+		if r.Model != nil {
+			resp.Content = map[string]*openapi.MediaType{"application/json": {Schema: components.SchemaOf(reflect.TypeOf(r.Model)), Example: r.Example}}
+		} else if r.Type != "" {
+			resp.Content = map[string]*openapi.MediaType{"application/json": {Schema: docSchema(components, r.Type), Example: r.Example}}
+		}
+		op.Responses[r.Code] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &openapi.Response{Description: "OK"}
+	}
+
+	for _, s := range d.Security {
+		scopes := s.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		op.Security = append(op.Security, openapi.SecurityRequirement{s.Name: scopes})
+	}
+
+	for _, c := range d.Callbacks {
+		if op.Callbacks == nil {
+			op.Callbacks = make(map[string]map[string]*openapi.PathItem)
+		}
+		callback := &openapi.Operation{Responses: map[string]*openapi.Response{"200": {Description: "OK"}}}
+		if c.Type != "" {
+			callback.RequestBody = &openapi.RequestBody{
+				Description: c.Description,
+				Content:     map[string]*openapi.MediaType{"application/json": {Schema: docSchema(components, c.Type)}},
+			}
+		}
+		if op.Callbacks[c.Name] == nil {
+			op.Callbacks[c.Name] = make(map[string]*openapi.PathItem)
+		}
+		item := op.Callbacks[c.Name][c.Expression]
+		if item == nil {
+			item = &openapi.PathItem{}
+			op.Callbacks[c.Name][c.Expression] = item
+		}
+		item.SetOperation(strings.ToUpper(c.Method), callback)
+	}
+	return op
+}
+
+// queryParams returns the query parameters of the fields of a struct request,
+// named by their form tags like ParseForm.
+func queryParams(components *openapi.Components, t reflect.Type) []*openapi.Parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []*openapi.Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("form"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = strings.Split(tag, ",")[0]
+		}
+		schema, required := components.FieldSchema(f)
+		params = append(params, &openapi.Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+	return params
+}
+
+// formSchema returns the schema of the form request body of op.
+func formSchema(op *openapi.Operation, contentType string) *openapi.Schema {
+	if op.RequestBody == nil {
+		op.RequestBody = &openapi.RequestBody{Content: make(map[string]*openapi.MediaType)}
+	}
+	// a file turns an urlencoded form into a multipart one
+	if media, ok := op.RequestBody.Content["application/x-www-form-urlencoded"]; ok && contentType == "multipart/form-data" {
+		delete(op.RequestBody.Content, "application/x-www-form-urlencoded")
+		op.RequestBody.Content[contentType] = media
+	} else if _, ok := op.RequestBody.Content["multipart/form-data"]; ok {
+		contentType = "multipart/form-data"
+	}
+	media := op.RequestBody.Content[contentType]
+	if media == nil {
+		media = &openapi.MediaType{Schema: &openapi.Schema{Type: "object", Properties: make(map[string]*openapi.Schema)}}
+		op.RequestBody.Content[contentType] = media
+	}
+	return media.Schema
+}
+
+var docTypes = map[string]reflect.Kind{
+	"string":  reflect.String,
+	"bool":    reflect.Bool,
+	"boolean": reflect.Bool,
+	"int":     reflect.Int,
+	"int64":   reflect.Int64,
+	"integer": reflect.Int,
+	"int32":   reflect.Int32,
+	"float":   reflect.Float32,
+	"float32": reflect.Float32,
+	"float64": reflect.Float64,
+	"number":  reflect.Float64,
+}
+
+// docSchema returns the schema of a doc type.
+func docSchema(components *openapi.Components, typ string) *openapi.Schema {
+	switch {
+	case strings.Contains(typ, "|"):
+		s := &openapi.Schema{}
+		for _, t := range strings.Split(typ, "|") {
+			s.OneOf = append(s.OneOf, docSchema(components, strings.TrimSpace(t)))
+		}
+		return s
+	case strings.HasPrefix(typ, "[]"):
+		return &openapi.Schema{Type: "array", Items: docSchema(components, typ[2:])}
+	case typ == "file":
+		return &openapi.Schema{Type: "string", Format: "binary"}
+	case typ == "object" || typ == "":
+		return &openapi.Schema{Type: "object"}
+	}
+	if kind, ok := docTypes[typ]; ok {
+		return openapi.TypeSchema(kind)
+	}
+	if t, ok := docModels[strings.TrimPrefix(typ, "*")]; ok {
+		return components.SchemaOf(t)
+	}
+	Warn("docs: the model " + typ + " is not registered by DocModel")
+	return &openapi.Schema{Type: "object", Description: typ}
+}
@@ -0,0 +1,399 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/swagger/openapi"
+)
+
+const docSource = `package controllers
+
+// @Title CreateUser
+// @Summary create a user
+// @Description the login must be unique
+// @Param body body models.User true "the user to create"
+// @Param notify query bool false
+// @Success 201 {object} models.User
+// @Failure 422 the user is invalid
+// @Security oauth users:write
+// @Callback created {$request.body#/callback} post models.User "the user created"
+// @router / [post]
+func (c *UserController) Post() {}
+`
+
+func TestParserDocComments(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "user.go", docSource, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, ok := parserDocComments(f.Decls[0].(*ast.FuncDecl).Doc)
+	if !ok {
+		t.Fatal("the comments should have a doc")
+	}
+	want := OperationDoc{
+		OperationID: "CreateUser",
+		Summary:     "create a user",
+		Description: "the login must be unique",
+		Params: []ParamDoc{
+			{"body", "body", "models.User", true, "the user to create"},
+			{"notify", "query", "bool", false, ""},
+		},
+		Responses: []ResponseDoc{{Code: "201", Type: "models.User"}, {Code: "422", Description: "the user is invalid"}},
+		Security:  []SecurityDoc{{"oauth", []string{"users:write"}}},
+		Callbacks: []CallbackDoc{{"created", "{$request.body#/callback}", "post", "models.User", "the user created"}},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("unexpected doc\n%#v\nwant\n%#v", doc, want)
+	}
+	// the generated code compiles to the same doc
+	if code := fmt.Sprintf("%#v", doc); !strings.HasPrefix(code, "beego.OperationDoc{") {
+		t.Errorf("unexpected generated code %s", code)
+	}
+}
+
+func TestGenerateRouters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-controllers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := docSource + `
+// @router /:id [get,delete] [:id:int]
+func (c *UserController) Get() {}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "user.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "routers")
+	if err := GenerateRouters(dir, "example.com/app/controllers", out); err != nil {
+		t.Fatal(err)
+	}
+	code, err := ioutil.ReadFile(filepath.Join(out, "commentsRouter_example_com_app_controllers.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "routers.go", code, 0); err != nil {
+		t.Fatalf("the generated code should parse: %v\n%s", err, code)
+	}
+	for _, want := range []string{
+		`beego.GlobalControllerRouter["example.com/app/controllers:UserController"]`,
+		"\"Get\",\n\t\t\t`/:id`,\n\t\t\t[]string{\"get\", \"delete\"},\n\t\t\t[]map[string]string{map[string]string{\":id\": \"int\"}}",
+		`beego.GlobalControllerDocs["example.com/app/controllers:UserController.Post"]`,
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("the generated code should contain %q\n%s", want, code)
+		}
+	}
+}
+
+type docUser struct {
+	Id    int64  `json:"id"`
+	Login string `json:"login" valid:"Required;MaxSize(20)"`
+}
+
+type DocUserController struct {
+	Controller
+}
+
+func (c *DocUserController) Get()    {}
+func (c *DocUserController) Post()   {}
+func (c *DocUserController) Remove() {}
+
+func TestOpenAPI(t *testing.T) {
+	DocModel(docUser{})
+	key := reflect.TypeOf(DocUserController{}).PkgPath() + ":DocUserController."
+	GlobalControllerDocs[key+"Get"] = OperationDoc{
+		Summary:   "get a user",
+		Responses: []ResponseDoc{{Code: "200", Type: "beego.docUser"}},
+	}
+	GlobalControllerDocs[key+"Remove"] = OperationDoc{
+		Params:    []ParamDoc{{Name: "hard", In: "query", Type: "bool"}},
+		Responses: []ResponseDoc{{Code: "200", Type: "beego.docUser|string"}},
+	}
+	GlobalControllerDocs[key+"Post"] = OperationDoc{
+		Params: []ParamDoc{{Name: "login", In: "form", Type: "string", Required: true}, {Name: "avatar", In: "form", Type: "file"}},
+	}
+	defer func() {
+		for _, m := range []string{"Get", "Post", "Remove"} {
+			delete(GlobalControllerDocs, key+m)
+		}
+	}()
+
+	handlers := NewControllerRegister()
+	handlers.Add("/users/:id:int", &DocUserController{})
+	handlers.Add("/users/:id([0-9]+)/remove", &DocUserController{}, "delete:Remove")
+	handlers.Add("/undocumented", &Controller{})
+	doc := openapi.NewDocument("api", "1.0")
+	addDocPaths(doc, handlers)
+
+	b, _ := json.Marshal(doc)
+	got := string(b)
+	for _, want := range []string{
+		`"paths":{"/users/{id}":{"get":{"tags":["DocUser"],"summary":"get a user","operationId":"DocUserController.Get",` +
+			`"parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"string"}}],` +
+			`"responses":{"200":{"description":"OK","content":{"application/json":{"schema":{"$ref":"#/components/schemas/beego.docUser"}}}}}}`,
+		`"post":{"tags":["DocUser"],"operationId":"DocUserController.Post","parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"string"}}],` +
+			`"requestBody":{"content":{"multipart/form-data":{"schema":{"type":"object","properties":{"avatar":{"type":"string","format":"binary"},"login":{"type":"string"}},"required":["login"]}}}}`,
+		`"/users/{id}/remove":{"delete":`,
+		`"schema":{"oneOf":[{"$ref":"#/components/schemas/beego.docUser"},{"type":"string"}]}`,
+		`"components":{"schemas":{"beego.docUser":{"type":"object","properties":{"id":{"type":"integer","format":"int64"},"login":{"type":"string","maxLength":20}},"required":["login"]}}}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the docs should contain\n%s\ngot\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "undocumented") {
+		t.Error("the methods of Controller should not be documented")
+	}
+}
+
+type docSearch struct {
+	Query string `form:"q" valid:"Required"`
+	Page  int    `form:"page" valid:"Min(1)"`
+}
+
+type DocOrderController struct {
+	Controller
+}
+
+func (c *DocOrderController) Get()    {}
+func (c *DocOrderController) Search() {}
+func (c *DocOrderController) Create() {}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	Document(&DocOrderController{}, "Search", OperationDoc{
+		Request:   docSearch{},
+		Responses: []ResponseDoc{{Code: "200", Model: []docUser{}}},
+	})
+	Document(&DocOrderController{}, "Create", OperationDoc{Request: &docUser{}})
+	key := reflect.TypeOf(DocOrderController{}).PkgPath() + ":DocOrderController."
+	defer func() {
+		delete(GlobalControllerDocs, key+"Search")
+		delete(GlobalControllerDocs, key+"Create")
+	}()
+
+	handlers := NewControllerRegister()
+	handlers.Add("/orders", &DocOrderController{})
+	handlers.Add("/orders/search", &DocOrderController{}, "get:Search;post:Create")
+	handlers.Add("/orders/any", &DocOrderController{}, "*:Search")
+	handlers.AddMethod("get", "/health", func(ctx *context.Context) {})
+	handlers.AddMethod("*", "/ping", func(ctx *context.Context) {})
+	handlers.Handler("/static", http.NotFoundHandler())
+	doc := openapi.NewDocument("api", "1.0")
+	addDocPaths(doc, handlers)
+
+	var paths []string
+	for path, item := range doc.Paths {
+		for _, m := range []string{"GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS"} {
+			if item.Operation(m) != nil {
+				paths = append(paths, m+" "+path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	want := "DELETE /orders/any,GET /health,GET /orders,GET /orders/any,GET /orders/search," +
+		"HEAD /orders/any,OPTIONS /orders/any,PATCH /orders/any,POST /orders/any,POST /orders/search,PUT /orders/any"
+	if strings.Join(paths, ",") != want {
+		t.Errorf("documented routes should be %s, got %s", want, strings.Join(paths, ","))
+	}
+
+	b, _ := json.Marshal(doc.Paths["/orders/search"])
+	for _, want := range []string{
+		`"parameters":[{"name":"q","in":"query","required":true,"schema":{"type":"string"}},{"name":"page","in":"query","schema":{"type":"integer","format":"int64","minimum":1}}]`,
+		`"responses":{"200":{"description":"OK","content":{"application/json":{"schema":{"type":"array","items":{"$ref":"#/components/schemas/beego.docUser"}}}}}}`,
+		`"post":{"tags":["DocOrder"],"operationId":"DocOrderController.Create","requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/beego.docUser"}}},"required":true}`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("the docs should contain\n%s\ngot\n%s", want, b)
+		}
+	}
+}
+
+func TestDocsUI(t *testing.T) {
+	defer func(ui, assets, user, password string) {
+		DocsUI, DocsUIAssets, DocsUser, DocsPassword = ui, assets, user, password
+	}(DocsUI, DocsUIAssets, DocsUser, DocsPassword)
+	DocsUser, DocsPassword = "admin", "secret"
+	RegisterDocsUIAssets("swagger", "1.0", fstest.MapFS{"swagger-ui.css": {Data: []byte("body{}")}})
+	defer delete(docsUIEmbedded, "swagger")
+
+	handlers := NewControllerRegister()
+	handlers.Get("/swagger", serverDocsUI)
+	handlers.Get("/swagger/assets/*", serverDocsUIAssets)
+	handlers.InsertFilter("/swagger", BeforeStatic, docsAccess)
+
+	for ui, test := range map[string][3]string{
+		"swagger": {"", `SwaggerUIBundle({url: "/docs"`, "/swagger/assets/1.0/swagger-ui-bundle.js"},
+		"redoc":   {"https://docs.example.com/redoc/", `<redoc spec-url="/docs">`, "https://docs.example.com/redoc/redoc.standalone.js"},
+	} {
+		DocsUI, DocsUIAssets = ui, test[0]
+		want := test[1]
+		r, _ := http.NewRequest("GET", "/swagger", nil)
+		w := httptest.NewRecorder()
+		handlers.ServeHTTP(w, r)
+		if w.Code != 401 || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: the docs ui should require the basic auth, got %d", ui, w.Code)
+		}
+
+		r.SetBasicAuth("admin", "secret")
+		w = httptest.NewRecorder()
+		handlers.ServeHTTP(w, r)
+		if w.Code != 200 || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: the docs ui page should contain %s, got %d %s", ui, want, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `src="`+test[2]+`"`) {
+			t.Errorf("%s: the page should load %s, got %s", ui, test[2], w.Body.String())
+		}
+		if csp := w.Header().Get("Content-Security-Policy"); (test[0] != "") != strings.Contains(csp, "https://docs.example.com") {
+			t.Errorf("%s: the policy should allow the assets, got %s", ui, csp)
+		}
+	}
+
+	DocsUI, DocsUIAssets = "swagger", ""
+	r, _ := http.NewRequest("GET", "/swagger/assets/1.0/swagger-ui.css", nil)
+	w := httptest.NewRecorder()
+	handlers.ServeHTTP(w, r)
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") || w.Body.Len() == 0 {
+		t.Errorf("the embedded assets should be served, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, path := range []string{"/swagger/assets/5.0.0/swagger-ui.css", "/swagger/assets/1.0/missing.js"} {
+		r, _ = http.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		handlers.ServeHTTP(w, r)
+		if w.Code != 404 {
+			t.Errorf("%s should not be found, got %d", path, w.Code)
+		}
+	}
+	DocsUI = "redoc"
+	r, _ = http.NewRequest("GET", "/swagger", nil)
+	r.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	handlers.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `src="https://unpkg.com/redoc@2.1.5/bundles/redoc.standalone.js"`) ||
+		!strings.Contains(w.Header().Get("Content-Security-Policy"), "https://unpkg.com") {
+		t.Errorf("the assets not embedded should be loaded from the cdn, got %s", w.Body.String())
+	}
+	r, _ = http.NewRequest("GET", "/swagger/assets/1.0/swagger-ui.css", nil)
+	w = httptest.NewRecorder()
+	handlers.ServeHTTP(w, r)
+	if w.Code != 404 {
+		t.Errorf("the assets of another ui should not be served, got %d", w.Code)
+	}
+	DocsPassword = ""
+	r, _ = http.NewRequest("GET", "/swagger", nil)
+	r.SetBasicAuth("admin", "")
+	w = httptest.NewRecorder()
+	handlers.ServeHTTP(w, r)
+	if w.Code != 401 {
+		t.Errorf("an empty DocsPassword should refuse the requests, got %d", w.Code)
+	}
+
+	handlers = NewControllerRegister()
+	handlers.Get("/swagger", serverDocsUI)
+	handlers.Get("/docs", serverDocs)
+	doc := openapi.NewDocument("api", "1.0")
+	addDocPaths(doc, handlers)
+	if len(doc.Paths) != 0 {
+		t.Errorf("the docs routes should not be documented, got %v", doc.Paths)
+	}
+}
+
+type docProfile struct {
+	Login string   `json:"login" example:"gopher"`
+	Age   int      `json:"age" example:"12"`
+	Admin bool     `json:"admin" example:"true"`
+	Tags  []string `json:"tags" example:"admin,dev"`
+	Owner docOwner `json:"owner"`
+}
+
+type docOwner struct {
+	Name string `json:"name"`
+}
+
+func (docOwner) Example() interface{} {
+	return docOwner{Name: "gopher"}
+}
+
+func TestDocExamples(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "profile.go", `package controllers
+
+// @Success 201 {object} models.Profile
+// @Example request {"login": "gopher"}
+// @Example 201 {"id": 1}
+// @Example 404 not found
+func (c *ProfileController) Post() {}
+`, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := parserDocComments(f.Decls[0].(*ast.FuncDecl).Doc)
+	want := OperationDoc{
+		Example: map[string]interface{}{"login": "gopher"},
+		Responses: []ResponseDoc{
+			{Code: "201", Type: "models.Profile", Example: map[string]interface{}{"id": float64(1)}},
+			{Code: "404", Example: "not found"},
+		},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("unexpected doc\n%#v\nwant\n%#v", doc, want)
+	}
+
+	components := &openapi.Components{}
+	op := OperationDoc{
+		Request:   docProfile{},
+		Example:   map[string]interface{}{"login": "gopher"},
+		Responses: []ResponseDoc{{Code: "200", Model: docProfile{}, Example: []int{1}}},
+	}.operation(components, "DocProfileController", "Post", "POST")
+	b, _ := json.Marshal(op)
+	got := string(b)
+	for _, want := range []string{
+		`"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/beego.docProfile"},"example":{"login":"gopher"}}},"required":true}`,
+		`"example":[1]`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the operation should contain\n%s\ngot\n%s", want, got)
+		}
+	}
+	b, _ = json.Marshal(components)
+	got = string(b)
+	for _, want := range []string{
+		`"admin":{"type":"boolean","example":true}`,
+		`"age":{"type":"integer","format":"int64","example":12}`,
+		`"login":{"type":"string","example":"gopher"}`,
+		`"tags":{"type":"array","items":{"type":"string"},"example":["admin","dev"]}`,
+		`"beego.docOwner":{"type":"object","properties":{"name":{"type":"string"}},"example":{"name":"gopher"}}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the schemas should contain\n%s\ngot\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/aamsur/beego/context"
+)
+
+// DocsAuth guards the docs and the docs ui when set, instead of the
+// DocsUser and DocsPassword basic auth:
+//
+//	beego.DocsAuth = jwt.Filter(jwt.Options{...})
+var DocsAuth FilterFunc
+
+// the default assets of the docs uis, DocsUIAssets points to a self hosted
+// copy of the dist files instead.
+var docsUIDefaultAssets = map[string]string{
+	"swagger": "https://unpkg.com/swagger-ui-dist@5.18.2",
+	"redoc":   "https://unpkg.com/redoc@2.1.5/bundles",
+}
+
+// docsUIFiles are the assets of a docs ui embedded by its package.
+type docsUIFiles struct {
+	version string
+	files   fs.FS
+}
+
+// the embedded assets of the docs uis, registered by their packages.
+var docsUIEmbedded = make(map[string]docsUIFiles)
+
+// RegisterDocsUIAssets serves the assets of the docs ui named ui from files,
+// with the page of the ui, when DocsUIAssets is empty. the version is part of
+// their url so the browsers can cache them. it's called in the init of the
+// packages embedding the assets, like swagger/ui:
+//	import _ "github.com/aamsur/beego/swagger/ui"
+func RegisterDocsUIAssets(ui, version string, files fs.FS) {
+	docsUIEmbedded[ui] = docsUIFiles{version: version, files: files}
+}
+
+var docsUITemplates = map[string]*template.Template{
+	"swagger": template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script nonce="{{.Nonce}}" src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script nonce="{{.Nonce}}">
+window.ui = SwaggerUIBundle({url: {{.Spec}}, dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`)),
+	"redoc": template.Must(template.New("redoc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<redoc spec-url="{{.Spec}}"></redoc>
+<script nonce="{{.Nonce}}" src="{{.Assets}}/redoc.standalone.js"></script>
+</body>
+</html>
+`)),
+}
+
+// serverDocsUI serves the DocsUI page showing the docs of /docs.
+func serverDocsUI(ctx *context.Context) {
+	tpl, ok := docsUITemplates[DocsUI]
+	if !ok {
+		Warn("docs ui:", DocsUI, "is not swagger or redoc")
+		ctx.Output.SetStatus(404)
+		return
+	}
+	assets := strings.TrimSuffix(DocsUIAssets, "/")
+	if assets == "" {
+		if embedded, ok := docsUIEmbedded[DocsUI]; ok {
+			assets = strings.TrimSuffix(DocsUIPath, "/") + "/assets/" + embedded.version
+		} else {
+			assets = docsUIDefaultAssets[DocsUI]
+		}
+	}
+	nonce := cspNonce()
+	var b bytes.Buffer
+	err := tpl.Execute(&b, map[string]string{
+		"Title":  AppName,
+		"Assets": assets,
+		"Spec":   "/docs",
+		"Nonce":  nonce,
+	})
+	if err != nil {
+		ctx.Output.SetStatus(500)
+		return
+	}
+	// the ui loads its assets and styles itself, which the default policy of
+	// the security headers refuses
+	origin := "'self'"
+	if u, err := url.Parse(assets); err == nil && u.Host != "" {
+		origin += " " + u.Scheme + "://" + u.Host
+	}
+	ctx.Output.Header("Content-Security-Policy", "default-src 'self'; script-src 'nonce-"+nonce+"' "+origin+
+		"; style-src 'unsafe-inline' "+origin+"; img-src data: "+origin+"; worker-src blob:; object-src 'none'; base-uri 'self'")
+	ctx.Output.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Output.Body(b.Bytes())
+}
+
+// serverDocsUIAssets serves the embedded assets of the DocsUI page, at
+// DocsUIPath/assets/<version>/<file>.
+func serverDocsUIAssets(ctx *context.Context) {
+	embedded, ok := docsUIEmbedded[DocsUI]
+	version, file := path.Split(ctx.Input.Param(":splat"))
+	if !ok || version != embedded.version+"/" {
+		ctx.Output.SetStatus(404)
+		return
+	}
+	b, err := fs.ReadFile(embedded.files, file)
+	if err != nil {
+		ctx.Output.SetStatus(404)
+		return
+	}
+	ctx.Output.Header("Content-Type", mime.TypeByExtension(path.Ext(file)))
+	ctx.Output.Header("Cache-Control", "public, max-age=31536000, immutable")
+	ctx.Output.Body(b)
+}
+
+// docsAccess is the filter of the docs and the docs ui, running DocsAuth or
+// checking the DocsUser and DocsPassword basic auth. an empty DocsPassword
+// matches no request.
+func docsAccess(ctx *context.Context) {
+	if DocsAuth != nil {
+		DocsAuth(ctx)
+		return
+	}
+	user, password, ok := ctx.Request.BasicAuth()
+	if ok && DocsPassword != "" && subtle.ConstantTimeCompare([]byte(user), []byte(DocsUser)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(DocsPassword)) == 1 {
+		return
+	}
+	ctx.Output.Header("WWW-Authenticate", `Basic realm="docs"`)
+	ctx.ResponseWriter.WriteHeader(401)
+	ctx.ResponseWriter.Write([]byte("401 Unauthorized\n"))
+}
+
+// isDocsRoute reports whether fn serves the docs or the docs ui, which are not documented.
+func isDocsRoute(fn FilterFunc) bool {
+	p := reflect.ValueOf(fn).Pointer()
+	return p == reflect.ValueOf(serverDocs).Pointer() || p == reflect.ValueOf(serverDocsUI).Pointer() ||
+		p == reflect.ValueOf(serverDocsUIAssets).Pointer()
+}
@@ -0,0 +1,249 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import "strings"
+
+// routingTable holds the route trees of a ControllerRegistor. It is
+// mutated in place while the app is set up and replaced as a whole by
+// Update once it serves.
+type routingTable struct {
+	routers  map[string]*Tree
+	hosts    []*hostRouters
+	versions []*Versions
+	errors   []*errorRoute
+}
+
+// table returns the current routing table.
+func (p *ControllerRegistor) table() *routingTable {
+	return p.routes.Load().(*routingTable)
+}
+
+// clone copies the trees so routes can be added or removed without
+// touching the ones requests are served from, the leaves are shared.
+func (t *routingTable) clone() *routingTable {
+	c := &routingTable{
+		routers:  cloneRouters(t.routers),
+		versions: append([]*Versions(nil), t.versions...),
+		errors:   append([]*errorRoute(nil), t.errors...),
+	}
+	for _, h := range t.hosts {
+		hc := *h
+		hc.routers = cloneRouters(h.routers)
+		c.hosts = append(c.hosts, &hc)
+	}
+	return c
+}
+
+func cloneRouters(routers map[string]*Tree) map[string]*Tree {
+	c := make(map[string]*Tree, len(routers))
+	for method, t := range routers {
+		c[method] = t.clone()
+	}
+	return c
+}
+
+func (t *Tree) clone() *Tree {
+	c := &Tree{
+		fixrouters: make(map[string]*Tree, len(t.fixrouters)),
+		leaves:     append([]*leafInfo(nil), t.leaves...),
+	}
+	for seg, sub := range t.fixrouters {
+		c.fixrouters[seg] = sub.clone()
+	}
+	if t.wildcard != nil {
+		c.wildcard = t.wildcard.clone()
+	}
+	return c
+}
+
+// removeLeaves drops the leaves whose run object matches and returns how many were removed.
+func (t *Tree) removeLeaves(match func(runObject interface{}) bool) int {
+	n := 0
+	for _, sub := range t.fixrouters {
+		n += sub.removeLeaves(match)
+	}
+	if t.wildcard != nil {
+		n += t.wildcard.removeLeaves(match)
+	}
+	leaves := t.leaves[:0]
+	for _, l := range t.leaves {
+		if match(l.runObject) {
+			n++
+		} else {
+			leaves = append(leaves, l)
+		}
+	}
+	for i := len(leaves); i < len(t.leaves); i++ {
+		t.leaves[i] = nil
+	}
+	t.leaves = leaves
+	return n
+}
+
+// Update changes the routes while the app is serving. fn gets a copy of
+// the routing table to register or remove routes, namespaces and versions
+// on with the usual methods, the copy replaces the served one when fn
+// returns, so requests see either all or none of the changes.
+// Filters inserted by fn are added to p before the routes are served, and
+// nothing changes when one of their names is already registered.
+// usage:
+//	BeeApp.Handlers.Update(func(r *ControllerRegistor) {
+//		r.RemoveRoute("/hooks/old")
+//		r.Add("/hooks/:id", &HookController{})
+//	})
+func (p *ControllerRegistor) Update(fn func(*ControllerRegistor)) error {
+	p.updateLock.Lock()
+	defer p.updateLock.Unlock()
+	next := &ControllerRegistor{filters: make(map[int][]*FilterRouter)}
+	next.routes.Store(p.table().clone())
+	fn(next)
+	if err := p.insertFilterRouters(next.filters); err != nil {
+		return err
+	}
+	p.routes.Store(next.table())
+	return nil
+}
+
+// RemoveRoute removes the routes registered with pattern for methods, or
+// for every method if none is given, on every host. It reports whether
+// any route was removed. Once the app serves call it inside Update.
+func (p *ControllerRegistor) RemoveRoute(pattern string, methods ...string) bool {
+	return p.removeRoutes(func(c *controllerInfo) bool {
+		return c.pattern == pattern
+	}, methods...)
+}
+
+// removeRoutes removes the routes matching for methods, or for every method, on every host.
+func (p *ControllerRegistor) removeRoutes(matchRoute func(*controllerInfo) bool, methods ...string) bool {
+	match := func(runObject interface{}) bool {
+		c, ok := runObject.(*controllerInfo)
+		return ok && matchRoute(c)
+	}
+	remove := func(routers map[string]*Tree) int {
+		n := 0
+		for method, t := range routers {
+			if len(methods) == 0 || containsMethod(methods, method) {
+				n += t.removeLeaves(match)
+			}
+		}
+		return n
+	}
+	table := p.table()
+	n := remove(table.routers)
+	for _, h := range table.hosts {
+		n += remove(h.routers)
+	}
+	return n > 0
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateRoutes changes the routes of BeeApp while it is serving, see ControllerRegistor.Update.
+func UpdateRoutes(fn func(*ControllerRegistor)) error {
+	return BeeApp.Handlers.Update(fn)
+}
+
+// RemoveRoute removes the routes of BeeApp registered with pattern for
+// methods, or for every method if none is given. It is safe while serving.
+func RemoveRoute(pattern string, methods ...string) bool {
+	removed := false
+	BeeApp.Handlers.Update(func(p *ControllerRegistor) {
+		removed = p.RemoveRoute(pattern, methods...)
+	})
+	return removed
+}
+
+// RouteCond tells whether the routes of RouterIf are served.
+type RouteCond func() bool
+
+// ConfigFlag returns a RouteCond holding when the bool key of AppConfig is true.
+func ConfigFlag(key string) RouteCond {
+	return func() bool {
+		return AppConfig.DefaultBool(key, false)
+	}
+}
+
+// condRoute is a route of AddIf, with the routes it added while on.
+type condRoute struct {
+	cond     RouteCond
+	register func(*ControllerRegistor)
+	on       bool
+	infos    map[*controllerInfo]bool
+}
+
+// RouterIf adds the route of Router to BeeApp when cond holds. cond is
+// evaluated now and again by Reload, which adds or removes the route when
+// its result changed, so that an endpoint can be shipped dark behind a flag.
+// usage:
+//	beego.RouterIf(beego.ConfigFlag("EnableBeta"), "/beta/search", &SearchController{})
+func RouterIf(cond RouteCond, rootpath string, c ControllerInterface, options ...interface{}) *App {
+	BeeApp.Handlers.AddIf(cond, rootpath, c, options...)
+	return BeeApp
+}
+
+// AddIf adds the route of Add when cond holds, see RouterIf.
+func (p *ControllerRegistor) AddIf(cond RouteCond, pattern string, c ControllerInterface, options ...interface{}) {
+	r := &condRoute{cond: cond}
+	track := RouteOption(func(info *controllerInfo) {
+		r.infos[info] = true
+	})
+	r.register = func(p *ControllerRegistor) {
+		r.infos = make(map[*controllerInfo]bool)
+		p.Add(pattern, c, append(options[:len(options):len(options)], track)...)
+	}
+	p.condLock.Lock()
+	defer p.condLock.Unlock()
+	p.condRoutes = append(p.condRoutes, r)
+	if r.on = cond(); r.on {
+		r.register(p)
+	}
+}
+
+// updateCondRoutes evaluates the conditions of AddIf again, adding the
+// routes whose condition now holds and removing the other ones.
+func (p *ControllerRegistor) updateCondRoutes() {
+	p.condLock.Lock()
+	defer p.condLock.Unlock()
+	var changed []*condRoute
+	for _, r := range p.condRoutes {
+		if r.cond() != r.on {
+			changed = append(changed, r)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	p.Update(func(next *ControllerRegistor) {
+		for _, r := range changed {
+			if r.on {
+				infos := r.infos
+				next.removeRoutes(func(c *controllerInfo) bool {
+					return infos[c]
+				})
+			} else {
+				r.register(next)
+			}
+			r.on = !r.on
+		}
+	})
+}
@@ -0,0 +1,204 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aamsur/beego/config"
+	"github.com/aamsur/beego/context"
+)
+
+func serveStatus(handler *ControllerRegistor, method, url string) (int, string) {
+	r, _ := http.NewRequest(method, url, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func TestUpdateRoutes(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Get("/hooks/a", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("a"))
+	})
+	handler.Get("/hooks/b", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("b"))
+	})
+	old := handler.table()
+
+	err := handler.Update(func(r *ControllerRegistor) {
+		if !r.RemoveRoute("/hooks/a") {
+			t.Error("/hooks/a should be removed")
+		}
+		if r.RemoveRoute("/hooks/missing") {
+			t.Error("/hooks/missing is not a route")
+		}
+		r.Post("/webhooks/:id", func(ctx *context.Context) {
+			ctx.Output.Body([]byte("post " + ctx.Input.Param(":id")))
+		})
+		r.InsertFilter("/webhooks/*", BeforeRouter, func(ctx *context.Context) {
+			ctx.Output.Header("X-Hook", "1")
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj, _ := old.routers["GET"].Match("/hooks/a"); obj == nil {
+		t.Error("the served table should not be changed by Update")
+	}
+	if code, _ := serveStatus(handler, "GET", "/hooks/a"); code != http.StatusNotFound {
+		t.Errorf("removed route should be 404, got %d", code)
+	}
+	if _, body := serveStatus(handler, "GET", "/hooks/b"); body != "b" {
+		t.Errorf("kept route should serve, got %q", body)
+	}
+	r, _ := http.NewRequest("POST", "/webhooks/42", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "post 42" || w.Header().Get("X-Hook") != "1" {
+		t.Errorf("added route and filter should serve, got %q %q", w.Body.String(), w.Header().Get("X-Hook"))
+	}
+}
+
+func TestUpdateFilterConflict(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.InsertNamedFilter("auth", DefaultFilterPriority, "/admin", BeforeRouter, func(ctx *context.Context) {})
+
+	err := handler.Update(func(r *ControllerRegistor) {
+		r.Get("/admin/users", func(ctx *context.Context) {
+			ctx.Output.Body([]byte("users"))
+		})
+		r.InsertFilter("/admin/*", BeforeRouter, func(ctx *context.Context) {
+			ctx.Output.Header("X-Audit", "1")
+		})
+		r.InsertNamedFilter("auth", DefaultFilterPriority, "/admin/*", BeforeRouter, func(ctx *context.Context) {})
+	})
+	if err == nil {
+		t.Fatal("a filter name registered twice should fail the update")
+	}
+	if code, _ := serveStatus(handler, "GET", "/admin/users"); code != http.StatusNotFound {
+		t.Errorf("the routes of a failed update should not be served, got %d", code)
+	}
+	if l := handler.getFilters(BeforeRouter); len(l) != 1 {
+		t.Errorf("the filters of a failed update should not be added, got %d filters", len(l))
+	}
+}
+
+func TestRemoveRouteMethods(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Add("/user", &TestController{}, "get:List;post:Post")
+	handler.AddHost("api.example.com", "/user", &TestController{}, "get:List")
+	if !handler.RemoveRoute("/user", "post") {
+		t.Fatal("/user should be removed for POST")
+	}
+	if code, _ := serveStatus(handler, "POST", "/user"); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /user should be 405, got %d", code)
+	}
+	if _, body := serveStatus(handler, "GET", "/user"); body != "i am list" {
+		t.Errorf("GET /user should be kept, got %q", body)
+	}
+	handler.RemoveRoute("/user")
+	r, _ := http.NewRequest("GET", "/user", nil)
+	r.Host = "api.example.com"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("host route should be removed too, got %d", w.Code)
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Get("/static", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("static"))
+	})
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, body := serveStatus(handler, "GET", "/static"); body != "static" {
+					t.Errorf("/static should always serve, got %q", body)
+					return
+				}
+				serveStatus(handler, "GET", "/dynamic")
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if i%10 == 0 {
+			handler.InsertNamedFilter("noop"+strconv.Itoa(i), DefaultFilterPriority, "*", BeforeRouter, func(ctx *context.Context) {})
+		}
+		handler.Update(func(r *ControllerRegistor) {
+			if i%2 == 0 {
+				r.Get("/dynamic", func(ctx *context.Context) {
+					ctx.Output.Body([]byte("dynamic"))
+				})
+			} else {
+				r.RemoveRoute("/dynamic")
+			}
+		})
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestAddIf(t *testing.T) {
+	beta := false
+	handler := NewControllerRegister()
+	handler.AddIf(func() bool { return beta }, "/search", &TestController{}, "get:List")
+	handler.Add("/search", &TestController{}, "post:Post")
+
+	if code, _ := serveStatus(handler, "GET", "/search"); code != http.StatusMethodNotAllowed {
+		t.Errorf("the route should be off, got %d", code)
+	}
+	beta = true
+	handler.updateCondRoutes()
+	if _, body := serveStatus(handler, "GET", "/search"); body != "i am list" {
+		t.Errorf("the route should be added once its condition holds, got %q", body)
+	}
+	beta = false
+	handler.updateCondRoutes()
+	if code, _ := serveStatus(handler, "GET", "/search"); code != http.StatusMethodNotAllowed {
+		t.Errorf("the route should be removed again, got %d", code)
+	}
+	if code, _ := serveStatus(handler, "POST", "/search"); code != http.StatusOK {
+		t.Errorf("the other routes of the pattern should be kept, got %d", code)
+	}
+}
+
+func TestConfigFlag(t *testing.T) {
+	defer func(ac *beegoAppConfig) { AppConfig = ac }(AppConfig)
+	AppConfig = &beegoAppConfig{innerConfig: config.NewFakeConfig()}
+	flag := ConfigFlag("testconfigflag")
+	if flag() {
+		t.Error("a missing flag should be off")
+	}
+	AppConfig.Set("testconfigflag", "true")
+	if !flag() {
+		t.Error("the flag should be on")
+	}
+}
@@ -201,6 +201,7 @@ type errorInfo struct {
 	handler        http.HandlerFunc
 	method         string
 	errorType      int
+	builtin        bool // registered by registerDefaultErrorHandler
 }
 
 // map of http handlers for each error string.
@@ -288,6 +289,21 @@ func methodNotAllowed(rw http.ResponseWriter, r *http.Request) {
 	t.Execute(rw, data)
 }
 
+// show 413 Request Entity Too Large
+func requestEntityTooLarge(rw http.ResponseWriter, r *http.Request) {
+	t, _ := template.New("beegoerrortemp").Parse(errtpl)
+	data := make(map[string]interface{})
+	data["Title"] = "Request Entity Too Large"
+	data["Content"] = template.HTML("<br>The request you have sent is too large." +
+		"<br>Perhaps you are here because:" +
+		"<br><br><ul>" +
+		"<br>The uploaded file is bigger than the server accepts" +
+		"<br>The form contains too much data" +
+		"</ul>")
+	data["BeegoVersion"] = VERSION
+	t.Execute(rw, data)
+}
+
 // show 500 internal server error.
 func internalServerError(rw http.ResponseWriter, r *http.Request) {
 	t, _ := template.New("beegoerrortemp").Parse(errtpl)
@@ -361,44 +377,61 @@ func gatewayTimeout(rw http.ResponseWriter, r *http.Request) {
 // register default error http handlers, 404,401,403,500 and 503.
 func registerDefaultErrorHandler() {
 	if _, ok := ErrorMaps["401"]; !ok {
-		Errorhandler("401", unauthorized)
+		defaultErrorhandler("401", unauthorized)
 	}
 
 	if _, ok := ErrorMaps["402"]; !ok {
-		Errorhandler("402", paymentRequired)
+		defaultErrorhandler("402", paymentRequired)
 	}
 
 	if _, ok := ErrorMaps["403"]; !ok {
-		Errorhandler("403", forbidden)
+		defaultErrorhandler("403", forbidden)
 	}
 
 	if _, ok := ErrorMaps["404"]; !ok {
-		Errorhandler("404", notFound)
+		defaultErrorhandler("404", notFound)
 	}
 
 	if _, ok := ErrorMaps["405"]; !ok {
-		Errorhandler("405", methodNotAllowed)
+		defaultErrorhandler("405", methodNotAllowed)
+	}
+
+	if _, ok := ErrorMaps["413"]; !ok {
+		defaultErrorhandler("413", requestEntityTooLarge)
 	}
 
 	if _, ok := ErrorMaps["500"]; !ok {
-		Errorhandler("500", internalServerError)
+		defaultErrorhandler("500", internalServerError)
 	}
 	if _, ok := ErrorMaps["501"]; !ok {
-		Errorhandler("501", notImplemented)
+		defaultErrorhandler("501", notImplemented)
 	}
 	if _, ok := ErrorMaps["502"]; !ok {
-		Errorhandler("502", badGateway)
+		defaultErrorhandler("502", badGateway)
 	}
 
 	if _, ok := ErrorMaps["503"]; !ok {
-		Errorhandler("503", serviceUnavailable)
+		defaultErrorhandler("503", serviceUnavailable)
 	}
 
 	if _, ok := ErrorMaps["504"]; !ok {
-		Errorhandler("504", gatewayTimeout)
+		defaultErrorhandler("504", gatewayTimeout)
 	}
 }
 
+// defaultErrorhandler registers a builtin error page, the app handlers replace it.
+func defaultErrorhandler(code string, h http.HandlerFunc) {
+	Errorhandler(code, h)
+	ErrorMaps[code].builtin = true
+}
+
+// appErrorHandler reports whether the app registered the handler of errcode
+// with Errorhandler or ErrorController.
+func appErrorHandler(errcode string) bool {
+	h, ok := ErrorMaps[errcode]
+	return ok && !h.builtin
+}
+
 // ErrorHandler registers http.HandlerFunc to each http err code string.
 // usage:
 // 	beego.ErrorHandler("404",NotFound)
@@ -439,6 +472,11 @@ func exception(errcode string, ctx *context.Context) {
 	if err != nil {
 		code = 503
 	}
+	// the error pages of the app are kept for all clients
+	if wantsJSON(ctx) && !appErrorHandler(errcode) {
+		writeHTTPError(ctx, NewHTTPError(code, "", ""))
+		return
+	}
 	ctx.ResponseWriter.WriteHeader(code)
 	if h, ok := ErrorMaps[errcode]; ok {
 		executeError(h, ctx)
@@ -451,6 +489,60 @@ func exception(errcode string, ctx *context.Context) {
 	}
 }
 
+// errorRoute is the handler of an error code for the requests under the
+// prefix of a namespace.
+type errorRoute struct {
+	code    string
+	prefix  string
+	host    *hostRouters // nil for any host
+	handler http.HandlerFunc
+}
+
+// under reports whether urlPath is prefix or a path under it.
+func (e *errorRoute) under(urlPath string) bool {
+	prefix := strings.TrimSuffix(e.prefix, "/")
+	if len(urlPath) < len(prefix) || len(urlPath) > len(prefix) && urlPath[len(prefix)] != '/' {
+		return false
+	}
+	if RouterCaseSensitive {
+		return urlPath[:len(prefix)] == prefix
+	}
+	return strings.EqualFold(urlPath[:len(prefix)], prefix)
+}
+
+// setErrorHandler sets the handler of errcode for the requests under prefix.
+func (p *ControllerRegistor) setErrorHandler(errcode, prefix string, host *hostRouters, h http.HandlerFunc) {
+	t := p.table()
+	for _, e := range t.errors {
+		if e.code == errcode && e.prefix == prefix && (e.host == nil) == (host == nil) && (host == nil || e.host.host == host.host) {
+			e.handler = h
+			return
+		}
+	}
+	t.errors = append(t.errors, &errorRoute{code: errcode, prefix: prefix, host: host, handler: h})
+}
+
+// exception writes the error errcode for the request of urlPath with the
+// handler of the innermost namespace having one, else with the global one.
+func (p *ControllerRegistor) exception(errcode string, ctx *context.Context, urlPath string) {
+	var found *errorRoute
+	var params Params
+	host := strings.ToLower(ctx.Input.Host())
+	for _, e := range p.table().errors {
+		if e.code != errcode || !e.under(urlPath) || e.host != nil && !e.host.match(host, &params) {
+			continue
+		}
+		if found == nil || len(e.prefix) > len(found.prefix) || len(e.prefix) == len(found.prefix) && found.host == nil {
+			found = e
+		}
+	}
+	if found == nil {
+		exception(errcode, ctx)
+		return
+	}
+	found.handler(ctx.ResponseWriter, ctx.Request)
+}
+
 func executeError(err *errorInfo, ctx *context.Context) {
 	if err.errorType == errorTypeHandler {
 		err.handler(ctx.ResponseWriter, ctx.Request)
@@ -488,3 +580,76 @@ func executeError(err *errorInfo, ctx *context.Context) {
 		execController.Finish()
 	}
 }
+
+// PanicHook receives every recovered panic with its stack,
+// for example to report it to an error tracker or count it in metrics.
+type PanicHook func(ctx *context.Context, err interface{}, stack string)
+
+// PanicRenderer writes the response of a recovered panic.
+type PanicRenderer func(ctx *context.Context, err interface{}, stack string)
+
+type panicRendererEntry struct {
+	contentType string
+	render      PanicRenderer
+}
+
+var (
+	panicHooks     []PanicHook
+	panicRenderers []panicRendererEntry
+)
+
+// AddPanicHook registers a hook called when RecoverPanic recovers a panic.
+// usage:
+//	beego.AddPanicHook(func(ctx *context.Context, err interface{}, stack string) {
+//		sentry.CaptureMessage(fmt.Sprint(err))
+//	})
+func AddPanicHook(h PanicHook) *App {
+	panicHooks = append(panicHooks, h)
+	return BeeApp
+}
+
+// SetPanicRenderer renders the 500 response for requests accepting contentType,
+// instead of the default error page. "*" matches all other requests.
+// usage:
+//	beego.SetPanicRenderer("application/json", func(ctx *context.Context, err interface{}, stack string) {
+//		ctx.Output.SetStatus(500)
+//		ctx.Output.Json(map[string]string{"error": "internal error"}, false, false)
+//	})
+func SetPanicRenderer(contentType string, r PanicRenderer) *App {
+	for i, e := range panicRenderers {
+		if e.contentType == contentType {
+			panicRenderers[i].render = r
+			return BeeApp
+		}
+	}
+	panicRenderers = append(panicRenderers, panicRendererEntry{contentType, r})
+	return BeeApp
+}
+
+// runPanicHooks calls the hooks, a panicking hook doesn't stop the others.
+func runPanicHooks(ctx *context.Context, err interface{}, stack string) {
+	for _, h := range panicHooks {
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					Error("panic hook crashed with error", e)
+				}
+			}()
+			h(ctx, err, stack)
+		}()
+	}
+}
+
+// panicRendererFor returns the renderer matching the Accept header of the request.
+func panicRendererFor(ctx *context.Context) PanicRenderer {
+	accept := ctx.Input.Header("Accept")
+	var fallback PanicRenderer
+	for _, e := range panicRenderers {
+		if e.contentType == "*" {
+			fallback = e.render
+		} else if strings.Contains(accept, e.contentType) {
+			return e.render
+		}
+	}
+	return fallback
+}
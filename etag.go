@@ -0,0 +1,36 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"github.com/aamsur/beego/context"
+)
+
+// AutoETag returns a filter making the json, xml and html responses of the
+// routes carry an ETag of their body, weak or strong, see
+// context.BeegoOutput.ETag: the clients polling them get 304 without body
+// while the body doesn't change. it must run before the response is
+// written:
+//	beego.InsertFilter("/api/*", beego.BeforeRouter, beego.AutoETag(false))
+//	beego.Get("/feed", feed, beego.WithFilters(beego.AutoETag(true)))
+func AutoETag(weak bool) FilterFunc {
+	mode := context.StrongETag
+	if weak {
+		mode = context.WeakETag
+	}
+	return func(ctx *context.Context) {
+		ctx.Output.ETag = mode
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego/context"
+)
+
+func TestAutoETag(t *testing.T) {
+	handler := NewControllerRegister()
+	modified := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	handler.Get("/poll", func(ctx *context.Context) {
+		ctx.Output.Header("Last-Modified", modified.Format(http.TimeFormat))
+		ctx.Output.Json(map[string]int{"count": 1}, false, false)
+	}, WithFilters(AutoETag(false)))
+	handler.Get("/weak", func(ctx *context.Context) {
+		ctx.Output.Json(map[string]int{"count": 1}, false, false)
+	}, WithFilters(AutoETag(true)))
+	handler.Get("/plain", func(ctx *context.Context) {
+		ctx.Output.Json(map[string]int{"count": 1}, false, false)
+	})
+	get := func(url string, header ...string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", url, nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/poll")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || len(etag) < 3 || etag[0] != '"' || w.Body.String() != `{"count":1}` {
+		t.Fatalf("got %d %q with the ETag %q", w.Code, w.Body.String(), etag)
+	}
+	if w := get("/poll", "If-None-Match", `"other", `+etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("a matching If-None-Match should get 304 without body, got %d %q", w.Code, w.Body.String())
+	}
+	if w := get("/poll", "If-None-Match", `"other"`); w.Code != http.StatusOK {
+		t.Errorf("another ETag should get the body, got %d", w.Code)
+	}
+	if w := get("/poll", "If-Modified-Since", modified.Format(http.TimeFormat)); w.Code != http.StatusNotModified {
+		t.Errorf("an unmodified response should get 304, got %d", w.Code)
+	}
+	if w := get("/poll", "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)); w.Code != http.StatusOK {
+		t.Errorf("a modified response should get the body, got %d", w.Code)
+	}
+
+	w = get("/weak")
+	if weak := w.Header().Get("ETag"); weak != "W/"+etag {
+		t.Errorf("got the ETag %q, want the weak one of the same body", weak)
+	}
+	if w := get("/weak", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("the weak comparison should match, got %d", w.Code)
+	}
+	if w := get("/plain"); w.Header().Get("ETag") != "" {
+		t.Errorf("the routes without the filter should get no ETag, got %q", w.Header().Get("ETag"))
+	}
+}
@@ -12,6 +12,6 @@ import (
 
 func main() {
 	beego.Router("/", &controllers.MainController{})
-	beego.Router("/ws", &controllers.WSController{})
+	beego.WebSocketRouter("/ws", &controllers.WSController{})
 	beego.Run()
 }
@@ -14,11 +14,40 @@
 
 package beego
 
-import "github.com/aamsur/beego/context"
+import (
+	"strings"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/utils"
+)
+
+// DefaultFilterPriority is the priority of filters inserted without one.
+const DefaultFilterPriority = 0
+
+// filter positions in execution order.
+var filterPositions = []int{BeforeStatic, BeforeRouter, BeforeExec, AfterExec, FinishRouter}
+
+var filterPositionNames = map[int]string{
+	BeforeStatic: "BeforeStatic",
+	BeforeRouter: "BeforeRouter",
+	BeforeExec:   "BeforeExec",
+	AfterExec:    "AfterExec",
+	FinishRouter: "FinishRouter",
+}
 
 // FilterFunc defines filter function type.
 type FilterFunc func(*context.Context)
 
+// FilterInfo describes a registered filter.
+type FilterInfo struct {
+	Name           string `json:"name,omitempty"`
+	Pattern        string `json:"pattern"`
+	Position       string `json:"position"`
+	Priority       int    `json:"priority"`
+	Func           string `json:"func"`
+	ReturnOnOutput bool   `json:"returnOnOutput"`
+}
+
 // FilterRouter defines filter operation before controller handler execution.
 // it can match patterned url and do filter function when action arrives.
 type FilterRouter struct {
@@ -26,18 +55,121 @@ type FilterRouter struct {
 	tree           *Tree
 	pattern        string
 	returnOnOutput bool
+	name           string
+	priority       int
+	skips          []FilterCondition
+	host           string // the host of the namespace of the filter
+}
+
+func (f *FilterRouter) info(pos int) FilterInfo {
+	return FilterInfo{
+		Name:           f.name,
+		Pattern:        f.pattern,
+		Position:       filterPositionNames[pos],
+		Priority:       f.priority,
+		Func:           utils.GetFuncName(f.filterFunc),
+		ReturnOnOutput: f.returnOnOutput,
+	}
 }
 
 // ValidRouter check current request is valid for this filter.
 // if matched, returns parsed params in this request by defined filter router pattern.
 func (f *FilterRouter) ValidRouter(router string) (bool, map[string]string) {
-	isok, params := f.tree.Match(router)
-	if isok == nil {
+	var params Params
+	if !f.matchParams(router, &params) {
 		return false, nil
 	}
-	if isok, ok := isok.(bool); ok {
-		return isok, params
-	} else {
-		return false, nil
+	return true, params.Map()
+}
+
+// matchParams is ValidRouter adding the params to params.
+func (f *FilterRouter) matchParams(router string, params *Params) bool {
+	n := len(*params)
+	isok, _ := f.tree.MatchParams(router, params).(bool)
+	if !isok {
+		*params = (*params)[:n]
+	}
+	return isok
+}
+
+// FilterCondition reports whether a filter condition holds for the request.
+type FilterCondition func(*context.Context) bool
+
+// FilterUnless returns a filter running filter unless one of conds holds.
+// usage:
+//	beego.InsertFilter("*", beego.BeforeRouter, beego.FilterUnless(auth,
+//		beego.SkipPaths("/healthz", "/static/*"),
+//		beego.SkipMethods("OPTIONS"),
+//	))
+func FilterUnless(filter FilterFunc, conds ...FilterCondition) FilterFunc {
+	return func(ctx *context.Context) {
+		if !anyCondition(ctx, conds) {
+			filter(ctx)
+		}
+	}
+}
+
+// FilterWhen returns a filter running filter only when all conds hold.
+func FilterWhen(filter FilterFunc, conds ...FilterCondition) FilterFunc {
+	return func(ctx *context.Context) {
+		for _, cond := range conds {
+			if !cond(ctx) {
+				return
+			}
+		}
+		filter(ctx)
+	}
+}
+
+// SkipPaths holds for urls matching one of the patterns, using the router pattern syntax.
+func SkipPaths(patterns ...string) FilterCondition {
+	t := NewTree()
+	for _, pattern := range patterns {
+		if !RouterCaseSensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		t.AddRouter(pattern, true)
+	}
+	return func(ctx *context.Context) bool {
+		url := ctx.Input.Url()
+		if !RouterCaseSensitive {
+			url = strings.ToLower(url)
+		}
+		ok, _ := t.Match(url)
+		return ok != nil
+	}
+}
+
+// SkipMethods holds for requests with one of the http methods.
+func SkipMethods(methods ...string) FilterCondition {
+	return func(ctx *context.Context) bool {
+		for _, m := range methods {
+			if strings.EqualFold(ctx.Input.Method(), m) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SkipHeader holds for requests sending header name, with value prefix when given.
+// for example CSRF checks can be skipped for token authenticated requests:
+//	beego.SkipHeader("Authorization", "Bearer ")
+func SkipHeader(name string, prefix ...string) FilterCondition {
+	return func(ctx *context.Context) bool {
+		v := ctx.Input.Header(name)
+		if len(prefix) > 0 {
+			return v != "" && strings.HasPrefix(v, prefix[0])
+		}
+		return v != ""
+	}
+}
+
+func anyCondition(ctx *context.Context, conds []FilterCondition) bool {
+	for _, cond := range conds {
+		if cond(ctx) {
+			return true
+		}
 	}
+	return false
 }
@@ -66,3 +66,80 @@ func TestPatternThree(t *testing.T) {
 		t.Errorf("filter /admin/astaxie can't run")
 	}
 }
+
+func TestNamedFilters(t *testing.T) {
+	handler := NewControllerRegister()
+	write := func(s string) FilterFunc {
+		return func(ctx *context.Context) {
+			ctx.WriteString(s)
+		}
+	}
+	handler.InsertFilter("*", BeforeRouter, write("b"), false)
+	handler.InsertNamedFilter("first", -10, "*", BeforeRouter, write("a"), false)
+	handler.InsertNamedFilter("last", 10, "/chain/*", BeforeRouter, write("c"), false)
+	if err := handler.InsertNamedFilter("last", 0, "*", BeforeRouter, write("x")); err == nil {
+		t.Errorf("duplicated filter name should be rejected")
+	}
+	handler.Get("/chain/x", func(ctx *context.Context) {})
+
+	serve := func() string {
+		r, _ := http.NewRequest("GET", "/chain/x", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	if body := serve(); body != "abc" {
+		t.Errorf("filters should run by priority, got %s", body)
+	}
+
+	chain := handler.FilterChain("/chain/x")
+	if len(chain) != 3 || chain[0].Name != "first" || chain[2].Position != "BeforeRouter" {
+		t.Errorf("unexpected filter chain %+v", chain)
+	}
+	if len(handler.FilterChain("/other")) != 2 {
+		t.Errorf("filter chain should only list matching filters")
+	}
+
+	handler.ReplaceFilter("first", write("A"))
+	handler.RemoveFilter("last")
+	if body := serve(); body != "Ab" {
+		t.Errorf("filters should be replaced and removed at runtime, got %s", body)
+	}
+}
+
+func TestFilterSkips(t *testing.T) {
+	deny := func(ctx *context.Context) {
+		ctx.Output.SetStatus(401)
+		ctx.Output.Body([]byte("denied"))
+	}
+	handler := NewControllerRegister()
+	handler.InsertNamedFilter("auth", 0, "*", BeforeRouter, deny)
+	handler.InsertFilter("/api/*", BeforeRouter, FilterUnless(deny, SkipHeader("Authorization", "Bearer ")))
+	handler.SkipFilter("auth", SkipPaths("/healthz", "/api/*"), SkipMethods("OPTIONS"))
+	ok := func(ctx *context.Context) { ctx.WriteString("ok") }
+	handler.Get("/healthz", ok)
+	handler.Get("/private", ok)
+	handler.Options("/private", ok)
+	handler.Get("/api/user", ok)
+
+	for _, c := range []struct {
+		method, url, auth string
+		code             int
+	}{
+		{"GET", "/healthz", "", 200},
+		{"GET", "/private", "", 401},
+		{"OPTIONS", "/private", "", 200},
+		{"GET", "/api/user", "", 401},
+		{"GET", "/api/user", "Bearer t", 200},
+	} {
+		r, _ := http.NewRequest(c.method, c.url, nil)
+		if c.auth != "" {
+			r.Header.Set("Authorization", c.auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s %s should get %d, got %d", c.method, c.url, c.code, w.Code)
+		}
+	}
+}
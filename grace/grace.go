@@ -0,0 +1,342 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grace provides http servers restarting without dropping connections.
+//
+// on SIGUSR2 the running binary is started again and gets the listening sockets
+// as inherited file descriptors. once the new process serves on all of them it
+// sends SIGTERM to the old one, which stops accepting and drains the running
+// requests. with ReusePort the new process binds the ports itself with SO_REUSEPORT
+// instead, so it can also be started by the deploy tool.
+// SIGTERM, SIGINT and SIGQUIT shut the servers down gracefully.
+// sockets passed by systemd socket activation are used too, see SocketActivated.
+//
+// Usage
+//	import(
+//		"net/http"
+//		"github.com/aamsur/beego/grace"
+//	)
+//
+//	func main() {
+//		mux := http.NewServeMux()
+//		mux.HandleFunc("/", handler)
+//		if err := grace.ListenAndServe(":8080", mux); err != nil {
+//			log.Println(err)
+//		}
+//	}
+//
+// with beego set Graceful = true in app.conf.
+package grace
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envListeners holds the addresses of the inherited listeners, comma separated,
+	// the first one is file descriptor 3.
+	envListeners = "BEEGO_GRACE_LISTENERS"
+	// envParent holds the pid of the process to stop once the new one serves.
+	envParent = "BEEGO_GRACE_PARENT"
+	// envServers holds the number of servers of the former process, the new
+	// one stops it when as many are listening, ReusePort ones included.
+	envServers = "BEEGO_GRACE_SERVERS"
+)
+
+// DefaultTimeout is how long a shutdown waits for the running requests.
+var DefaultTimeout = 30 * time.Second
+
+// ShutdownDelay is how long a shutdown waits before the servers stop accepting,
+// so the load balancers see the readiness probe fail and stop sending requests.
+var ShutdownDelay time.Duration
+
+var (
+	lock         sync.Mutex
+	servers      []*Server
+	inherited    map[string]*os.File
+	inheritCount int
+	serverCount  int
+	claimed      int
+	parent       int
+
+	inheritOnce  sync.Once
+	signalOnce   sync.Once
+	parentOnce   sync.Once
+	shutdownOnce sync.Once
+	shuttingDown bool
+	done         = make(chan struct{})
+
+	shutdownHooks []func()
+)
+
+// Server is a http server which can be restarted and shut down gracefully.
+type Server struct {
+	*http.Server
+	// Name matches the FileDescriptorName of a systemd socket.
+	Name string
+	// Network to listen on, default is "tcp". with "unix" Addr is the socket path.
+	Network string
+	// SocketMode is the file mode of a unix socket.
+	SocketMode os.FileMode
+	// ReusePort binds with SO_REUSEPORT, instead of passing the socket on restart.
+	ReusePort bool
+	// Timeout for the running requests on shutdown, default is DefaultTimeout.
+	Timeout time.Duration
+
+	ln     net.Listener
+	served chan struct{}
+}
+
+// NewServer returns a graceful server for addr and handler.
+func NewServer(addr string, handler http.Handler) *Server {
+	return &Server{Server: &http.Server{Addr: addr, Handler: handler}}
+}
+
+// ListenAndServe listens on addr and serves handler gracefully.
+func ListenAndServe(addr string, handler http.Handler) error {
+	return NewServer(addr, handler).ListenAndServe()
+}
+
+// ListenAndServeTLS listens on addr and serves handler over https gracefully.
+func ListenAndServeTLS(addr, certFile, keyFile string, handler http.Handler) error {
+	return NewServer(addr, handler).ListenAndServeTLS(certFile, keyFile)
+}
+
+// ListenAndServe listens on srv.Addr, or takes the listener inherited from the
+// former process, and serves until the server is shut down.
+// after a graceful shutdown it returns nil once all requests are done.
+func (srv *Server) ListenAndServe() error {
+	if srv.Addr == "" {
+		srv.Addr = ":http"
+	}
+	ln, err := srv.listen()
+	if err != nil {
+		return err
+	}
+	return srv.wait(srv.Serve(ln))
+}
+
+// ListenAndServeTLS is ListenAndServe for https.
+func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if srv.Addr == "" {
+		srv.Addr = ":https"
+	}
+	ln, err := srv.listen()
+	if err != nil {
+		return err
+	}
+	return srv.wait(srv.ServeTLS(ln, certFile, keyFile))
+}
+
+func (srv *Server) network() string {
+	if srv.Network == "" {
+		return "tcp"
+	}
+	return srv.Network
+}
+
+func (srv *Server) timeout() time.Duration {
+	if srv.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return srv.Timeout
+}
+
+func (srv *Server) listen() (net.Listener, error) {
+	inheritOnce.Do(inherit)
+	signalOnce.Do(handleSignals)
+
+	lock.Lock()
+	var (
+		ln  net.Listener
+		err error
+	)
+	if f, ok := inherited[srv.Addr]; ok {
+		delete(inherited, srv.Addr)
+		ln, err = net.FileListener(f)
+		f.Close()
+		claimed++
+	} else if ln = takeActivated(srv); ln != nil {
+		// passed by systemd socket activation
+	} else if srv.network() == "unix" {
+		ln, err = ListenUnix(srv.Addr, srv.SocketMode)
+	} else if srv.ReusePort {
+		lc := net.ListenConfig{Control: reusePort}
+		ln, err = lc.Listen(context.Background(), srv.network(), srv.Addr)
+	} else {
+		ln, err = net.Listen(srv.network(), srv.Addr)
+	}
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	srv.ln = ln
+	srv.served = make(chan struct{})
+	servers = append(servers, srv)
+	ready := allListening()
+	lock.Unlock()
+
+	if ready && parent != 0 {
+		parentOnce.Do(func() {
+			if err := stopProcess(parent); err != nil {
+				log.Println("grace: stop former process:", err)
+			}
+		})
+	}
+	return ln, nil
+}
+
+// allListening reports whether the servers of the former process are all
+// listening again in this one, it's called with lock held.
+func allListening() bool {
+	return claimed >= inheritCount && len(servers) >= serverCount
+}
+
+// wait marks srv as served and turns the error of a shut down server into nil.
+// during Shutdown it returns after the shutdown finished, a server shut down
+// by the user directly returns at once.
+func (srv *Server) wait(err error) error {
+	close(srv.served)
+	if err != http.ErrServerClosed {
+		return err
+	}
+	lock.Lock()
+	stopping := shuttingDown
+	lock.Unlock()
+	if stopping {
+		<-done
+	}
+	return nil
+}
+
+// inherit reads the listeners passed by the former process.
+func inherit() {
+	inherited = make(map[string]*os.File)
+	if addrs := os.Getenv(envListeners); addrs != "" {
+		for i, addr := range strings.Split(addrs, ",") {
+			inherited[addr] = os.NewFile(uintptr(3+i), addr)
+		}
+		inheritCount = len(inherited)
+	}
+	parent, _ = strconv.Atoi(os.Getenv(envParent))
+	serverCount, _ = strconv.Atoi(os.Getenv(envServers))
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envParent)
+	os.Unsetenv(envServers)
+	systemdListeners()
+}
+
+// Restart starts the binary again with the listeners of the running servers.
+// the new process stops this one when it is ready, if it fails this one keeps serving.
+func Restart() error {
+	lock.Lock()
+	defer lock.Unlock()
+	var (
+		addrs []string
+		files []*os.File
+	)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, srv := range servers {
+		if srv.ReusePort && srv.network() != "unix" {
+			continue
+		}
+		fl, ok := srv.ln.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return errors.New("grace: listener of " + srv.Addr + " can't be passed on")
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		if ul, ok := srv.ln.(*net.UnixListener); ok {
+			// the socket file is used by the new process now
+			ul.SetUnlinkOnClose(false)
+		}
+		addrs = append(addrs, srv.Addr)
+		files = append(files, f)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		envListeners+"="+strings.Join(addrs, ","),
+		envParent+"="+strconv.Itoa(os.Getpid()),
+		envServers+"="+strconv.Itoa(len(servers)),
+	)
+	return cmd.Start()
+}
+
+// OnShutdown adds f to the functions run when a shutdown starts, before ShutdownDelay.
+func OnShutdown(f func()) {
+	lock.Lock()
+	defer lock.Unlock()
+	shutdownHooks = append(shutdownHooks, f)
+}
+
+// Shutdown stops all servers from accepting and waits for the running requests,
+// up to the server Timeout, then ListenAndServe returns.
+func Shutdown() {
+	shutdownOnce.Do(func() {
+		lock.Lock()
+		shuttingDown = true
+		list := append([]*Server(nil), servers...)
+		hooks := append([]func(){}, shutdownHooks...)
+		lock.Unlock()
+
+		for _, f := range hooks {
+			f()
+		}
+		if ShutdownDelay > 0 {
+			time.Sleep(ShutdownDelay)
+		}
+
+		var wg sync.WaitGroup
+		for _, srv := range list {
+			wg.Add(1)
+			go func(srv *Server) {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), srv.timeout())
+				defer cancel()
+				if err := srv.Server.Shutdown(ctx); err != nil {
+					log.Println("grace: shutdown", srv.Addr, err)
+					srv.Server.Close()
+				}
+				<-srv.served
+			}(srv)
+		}
+		wg.Wait()
+		close(done)
+	})
+}
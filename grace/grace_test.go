@@ -0,0 +1,230 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grace
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReusePort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not supported")
+	}
+	lc := net.ListenConfig{Control: reusePort}
+	ln, err := lc.Listen(nil, "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ln2, err := lc.Listen(nil, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal("second listener on the same port should succeed:", err)
+	}
+	ln2.Close()
+}
+
+func TestServerShutdown(t *testing.T) {
+	srv := NewServer("127.0.0.1:0", http.NotFoundHandler())
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe() }()
+
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		ln := srv.ln
+		lock.Unlock()
+		if ln != nil {
+			break
+		}
+	}
+	if err := srv.Server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("a shut down server should return nil, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ListenAndServe should return when the http.Server is shut down directly")
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	started := make(chan bool)
+	release := make(chan bool)
+	srv := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+		w.Write([]byte("done"))
+	}))
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe() }()
+
+	var addr string
+	for i := 0; i < 100 && addr == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		if srv.ln != nil {
+			addr = srv.ln.Addr().String()
+		}
+		lock.Unlock()
+	}
+	if addr == "" {
+		t.Fatal("server didn't start")
+	}
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		body <- string(b)
+	}()
+	<-started
+	hooked := make(chan bool, 1)
+	OnShutdown(func() { hooked <- true })
+	go Shutdown()
+
+	select {
+	case err := <-served:
+		t.Fatal("server returned before the running request finished:", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("server should stop accepting during shutdown")
+	}
+	close(release)
+	if b := <-body; b != "done" {
+		t.Errorf("running request should complete, got %q", b)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("graceful shutdown should return nil, got %v", err)
+	}
+	select {
+	case <-hooked:
+	default:
+		t.Error("the shutdown hooks should run")
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	dir, err := ioutil.TempDir("", "grace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.sock")
+
+	ln, err := ListenUnix(path, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0660 {
+		t.Errorf("socket should have mode 0660, got %v %v", fi.Mode(), err)
+	}
+	if _, err := ListenUnix(path, 0); err == nil {
+		t.Error("socket in use should not be replaced")
+	}
+	ln.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("closing the listener should remove the socket file")
+	}
+
+	// a stale socket left by a crashed process
+	ln, _ = ListenUnix(path, 0)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if ln, err = ListenUnix(path, 0); err != nil {
+		t.Fatal("stale socket should be removed:", err)
+	}
+	ln.Close()
+
+	if runtime.GOOS == "linux" {
+		ln, err := ListenUnix("@beego-grace-test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln.Close()
+	}
+}
+
+func TestSystemdMatch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	activated = []*activatedListener{{name: "web", ln: ln}}
+	defer func() { activated = nil }()
+
+	if takeActivated(&Server{Server: &http.Server{Addr: "127.0.0.1:1"}}) != nil {
+		t.Error("socket on another port should not match")
+	}
+	if takeActivated(&Server{Server: &http.Server{Addr: ":" + strconv.Itoa(port)}}) != ln {
+		t.Error("socket should match the port of a wildcard address")
+	}
+	activated = []*activatedListener{{name: "web", ln: ln}}
+	if takeActivated(&Server{Name: "web", Server: &http.Server{Addr: ":http"}}) != ln {
+		t.Error("socket should match the server name")
+	}
+	if len(activated) != 0 {
+		t.Error("taken socket should not be used again")
+	}
+}
+
+func TestAllListening(t *testing.T) {
+	lock.Lock()
+	defer lock.Unlock()
+	defer func(list []*Server, count, inheritedCount, claimedCount int) {
+		servers, serverCount, inheritCount, claimed = list, count, inheritedCount, claimedCount
+	}(servers, serverCount, inheritCount, claimed)
+
+	// a former process with an inherited http listener and a ReusePort admin server
+	servers, serverCount, inheritCount, claimed = nil, 2, 1, 0
+	servers = append(servers, &Server{ReusePort: true})
+	if allListening() {
+		t.Error("the inherited listener isn't claimed yet")
+	}
+	servers = append(servers, &Server{})
+	claimed = 1
+	if !allListening() {
+		t.Error("all servers are listening")
+	}
+
+	// only ReusePort servers, nothing to inherit
+	servers, serverCount, inheritCount, claimed = nil, 2, 0, 0
+	servers = append(servers, &Server{ReusePort: true})
+	if allListening() {
+		t.Error("the former process should run until the second server listens")
+	}
+}
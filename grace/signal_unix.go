@@ -0,0 +1,46 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package grace
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func handleSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR2, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	go func() {
+		for sig := range ch {
+			switch sig {
+			case syscall.SIGUSR2:
+				if err := Restart(); err != nil {
+					log.Println("grace: restart:", err)
+				}
+			default:
+				go Shutdown()
+			}
+		}
+	}()
+}
+
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,111 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grace
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd.
+const listenFdsStart = 3
+
+// activatedListener is a socket passed by systemd socket activation.
+type activatedListener struct {
+	name string // FileDescriptorName of the socket unit
+	ln   net.Listener
+}
+
+var (
+	activated      []*activatedListener
+	activatedCount int
+)
+
+// SocketActivated reports whether the process got listeners from systemd.
+// the servers take them by their Name, matching FileDescriptorName= of the
+// socket unit, or by their address:
+//	# app.socket
+//	[Socket]
+//	ListenStream=80
+//	FileDescriptorName=http
+func SocketActivated() bool {
+	inheritOnce.Do(inherit)
+	return activatedCount > 0
+}
+
+// systemdListeners reads the sockets passed with LISTEN_FDS, it's called by inherit.
+func systemdListeners() {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid != os.Getpid() {
+		return
+	}
+	for i := 0; i < n; i++ {
+		f := os.NewFile(uintptr(listenFdsStart+i), "systemd")
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			// not a stream socket
+			continue
+		}
+		a := &activatedListener{ln: ln}
+		if i < len(names) {
+			a.name = names[i]
+		}
+		activated = append(activated, a)
+	}
+	activatedCount = len(activated)
+}
+
+// takeActivated returns the systemd socket for srv, or nil.
+func takeActivated(srv *Server) net.Listener {
+	for i, a := range activated {
+		if (srv.Name != "" && a.name == srv.Name) || sameAddr(a.ln.Addr(), srv.network(), srv.Addr) {
+			activated = append(activated[:i], activated[i+1:]...)
+			return a.ln
+		}
+	}
+	return nil
+}
+
+// sameAddr reports whether the listening address a serves addr,
+// an empty or unspecified host matches any ip.
+func sameAddr(a net.Addr, network, addr string) bool {
+	if network == "unix" {
+		return a.Network() == "unix" && a.String() == addr
+	}
+	ta, ok := a.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if p, err := net.LookupPort("tcp", port); err != nil || p != ta.Port {
+		return false
+	}
+	if host == "" || ta.IP.IsUnspecified() {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.Equal(ta.IP)
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grace
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// ListenUnix listens on the unix socket path and sets its file mode, unless mode is 0.
+// a socket file left by a crashed process is removed first, a live one is an error.
+// a path starting with "@" is a linux abstract socket, which has no file.
+// the socket file is removed when the listener is closed.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	abstract := strings.HasPrefix(path, "@")
+	if !abstract {
+		if fi, err := os.Stat(path); err == nil {
+			if fi.Mode()&os.ModeSocket == 0 {
+				return nil, errors.New("grace: " + path + " exists and is not a socket")
+			}
+			if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+				conn.Close()
+				return nil, errors.New("grace: " + path + " is in use by another process")
+			}
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 && !abstract {
+		if err := os.Chmod(path, mode); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
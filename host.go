@@ -0,0 +1,147 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"regexp"
+	"strings"
+
+	beecontext "github.com/aamsur/beego/context"
+)
+
+// hostRouters are the routers of the routes bound to a host.
+// the host pattern is a host name, without port, whose labels can be
+// "*", capturing the label as the :subdomain param, or ":name",
+// capturing the label as the :name param.
+// "api.example.com", "*.tenant.example.com", ":user.example.com"
+type hostRouters struct {
+	host    string
+	reg     *regexp.Regexp // nil if host has no params
+	params  []string
+	routers map[string]*Tree
+}
+
+func newHostRouters(host string) *hostRouters {
+	host = strings.ToLower(host)
+	h := &hostRouters{host: host, routers: make(map[string]*Tree)}
+	labels := strings.Split(host, ".")
+	for i, l := range labels {
+		switch {
+		case l == "*":
+			h.params = append(h.params, ":subdomain")
+		case strings.HasPrefix(l, ":") && len(l) > 1:
+			h.params = append(h.params, l)
+		default:
+			labels[i] = regexp.QuoteMeta(l)
+			continue
+		}
+		labels[i] = `([^.]+)`
+	}
+	if len(h.params) > 0 {
+		h.reg = regexp.MustCompile("^" + strings.Join(labels, `\.`) + "$")
+	}
+	return h
+}
+
+// match reports whether host matches the pattern, with the params
+// captured from host.
+func (h *hostRouters) match(host string, params *Params) bool {
+	if h.reg == nil {
+		return h.host == host
+	}
+	m := h.reg.FindStringSubmatch(host)
+	if m == nil {
+		return false
+	}
+	for i, p := range h.params {
+		params.Set(p, m[i+1])
+	}
+	return true
+}
+
+// hostRouters returns the routers of host, adding them if needed.
+// the fixed hosts are matched before the ones with params.
+func (p *ControllerRegistor) hostRouters(host string) *hostRouters {
+	host = strings.ToLower(host)
+	t := p.table()
+	for _, h := range t.hosts {
+		if h.host == host {
+			return h
+		}
+	}
+	h := newHostRouters(host)
+	i := len(t.hosts)
+	if h.reg == nil {
+		for i = 0; i < len(t.hosts) && t.hosts[i].reg == nil; i++ {
+		}
+	}
+	t.hosts = append(t.hosts, nil)
+	copy(t.hosts[i+1:], t.hosts[i:])
+	t.hosts[i] = h
+	return h
+}
+
+// AddHost adds controller handler and pattern rules like Add, the route
+// only matching the requests to host.
+// usage:
+//	AddHost("api.example.com", "/user", &UserController{})
+//	AddHost("*.tenant.example.com", "/", &TenantController{})
+func (p *ControllerRegistor) AddHost(host, pattern string, c ControllerInterface, options ...interface{}) {
+	cr := &ControllerRegistor{}
+	cr.routes.Store(&routingTable{routers: p.hostRouters(host).routers})
+	cr.Add(pattern, c, options...)
+}
+
+// lookupRoute finds the route of urlPath for method, in the routers of
+// the hosts matching the request host, then in the ones of any host.
+// the params of the route and host are added to params.
+func (p *ControllerRegistor) lookupRoute(ctx *beecontext.Context, method, urlPath string, params *Params) *controllerInfo {
+	table := p.table()
+	n := len(*params)
+	if len(table.hosts) > 0 {
+		host := strings.ToLower(ctx.Input.Host())
+		for _, h := range table.hosts {
+			t, ok := h.routers[method]
+			if !ok || !h.match(host, params) {
+				continue
+			}
+			if route, ok := t.MatchParams(urlPath, params).(*controllerInfo); ok {
+				return route
+			}
+			*params = (*params)[:n]
+		}
+	}
+	if t, ok := table.routers[method]; ok {
+		if route, ok := t.MatchParams(urlPath, params).(*controllerInfo); ok {
+			return route
+		}
+		*params = (*params)[:n]
+	}
+	return nil
+}
+
+// bindFilterHost makes mr run only for the requests to host, unless
+// it's bound to a host already.
+func bindFilterHost(mr *FilterRouter, host string) {
+	if mr.host != "" {
+		return
+	}
+	mr.host = host
+	h := newHostRouters(host)
+	mr.skips = append(mr.skips, func(ctx *beecontext.Context) bool {
+		var params Params
+		return !h.match(strings.ToLower(ctx.Input.Host()), &params)
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+type hostController struct {
+	Controller
+}
+
+func (c *hostController) Api() {
+	c.Ctx.WriteString("api " + c.Ctx.Input.Param(":id"))
+}
+
+func (c *hostController) Tenant() {
+	c.Ctx.WriteString("tenant " + c.Ctx.Input.Param(":subdomain"))
+}
+
+func (c *hostController) User() {
+	c.Ctx.WriteString("user " + c.Ctx.Input.Param(":user"))
+}
+
+func (c *hostController) Default() {
+	c.Ctx.WriteString("default")
+}
+
+func TestAddHost(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.AddHost("*.tenant.example.com", "/user/:id", &hostController{}, "get:Tenant")
+	handler.AddHost("API.example.com", "/user/:id", &hostController{}, "get:Api")
+	handler.AddHost(":user.example.com", "/", &hostController{}, "get:User")
+	handler.Add("/user/:id", &hostController{}, "get:Default")
+
+	for _, c := range []struct{ host, url, body string }{
+		{"api.example.com", "/user/1", "api 1"},
+		{"Api.Example.com:8080", "/user/2", "api 2"},
+		{"acme.tenant.example.com", "/user/1", "tenant acme"},
+		{"a.b.tenant.example.com", "/user/1", "default"},
+		{"astaxie.example.com", "/", "user astaxie"},
+		{"api.example.com", "/", "user api"},
+		{"example.com", "/user/1", "default"},
+		{"example.com", "/", ""},
+	} {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		r.Host = c.host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if c.body == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s%s: code is %d, want 404", c.host, c.url, w.Code)
+			}
+		} else if w.Body.String() != c.body {
+			t.Errorf("%s%s: got %q, want %q", c.host, c.url, w.Body.String(), c.body)
+		}
+	}
+}
+
+func TestAddHostOrder(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.AddHost("*.example.com", "/", &hostController{}, "get:Tenant")
+	handler.AddHost("api.example.com", "/", &hostController{}, "get:Api")
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Host = "api.example.com"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "api " {
+		t.Errorf("fixed hosts should match first, got %q", w.Body.String())
+	}
+}
+
+func TestNamespaceHost(t *testing.T) {
+	var filtered []string
+	ns := NewNamespace("/hosted",
+		NSHost("*.hosted.example.com"),
+		NSBefore(func(ctx *context.Context) {
+			filtered = append(filtered, ctx.Input.Param(":subdomain"))
+		}),
+		NSGet("/info", func(ctx *context.Context) {
+			ctx.Output.Body([]byte("info " + ctx.Input.Param(":subdomain")))
+		}),
+		NSNamespace("/admin",
+			NSHost("admin.example.com"),
+			NSGet("/info", func(ctx *context.Context) {
+				ctx.Output.Body([]byte("admin"))
+			}),
+		),
+	)
+	AddNamespace(ns)
+
+	for _, c := range []struct{ host, url, body string }{
+		{"acme.hosted.example.com", "/hosted/info", "info acme"},
+		{"example.com", "/hosted/info", ""},
+		{"admin.example.com", "/hosted/admin/info", "admin"},
+		{"acme.hosted.example.com", "/hosted/admin/info", ""},
+	} {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		r.Host = c.host
+		w := httptest.NewRecorder()
+		BeeApp.Handlers.ServeHTTP(w, r)
+		if c.body == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s%s: code is %d, want 404", c.host, c.url, w.Code)
+			}
+		} else if w.Body.String() != c.body {
+			t.Errorf("%s%s: got %q, want %q", c.host, c.url, w.Body.String(), c.body)
+		}
+	}
+	if len(filtered) != 2 {
+		t.Errorf("the namespace filter should only run for its host, ran for %v", filtered)
+	}
+}
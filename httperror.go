@@ -0,0 +1,200 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aamsur/beego/context"
+)
+
+// HTTPError is an error with the response it's sent as. the handlers return
+// or panic with it, or with an error mapped to it by MapError, and the
+// recovery sends it as json to the clients accepting json, as the error page
+// of its status otherwise.
+//
+//	var ErrNoStock = beego.NewHTTPError(409, "no_stock", "the item is sold out")
+//
+//	func (c *CartController) Add() error {
+//		if item.Stock == 0 {
+//			return ErrNoStock
+//		}
+//		...
+//	}
+type HTTPError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Err is the cause, logged but not sent.
+	Err error `json:"-"`
+}
+
+// NewHTTPError returns the error sent with status, code and message. the code
+// defaults to the status text in snake case, the message to the status text.
+func NewHTTPError(status int, code, message string) *HTTPError {
+	if code == "" {
+		code = strings.Replace(strings.ToLower(http.StatusText(status)), " ", "_", -1)
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+// Error returns the message and the cause.
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the cause.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// ErrorMapper returns the HTTPError of err, or nil when it doesn't know it.
+type ErrorMapper func(err error) *HTTPError
+
+var errorMappers []ErrorMapper
+
+// AddErrorMapper adds f to the mappers of the errors, tried in order after
+// the HTTPError in the chain of the error.
+func AddErrorMapper(f ErrorMapper) *App {
+	errorMappers = append(errorMappers, f)
+	return BeeApp
+}
+
+// MapError sends target, and the errors wrapping it, as status:
+//
+//	beego.MapError(sql.ErrNoRows, 404, "not_found", "")
+func MapError(target error, status int, code, message string) *App {
+	he := NewHTTPError(status, code, message)
+	return AddErrorMapper(func(err error) *HTTPError {
+		if errors.Is(err, target) {
+			return he.Wrap(err)
+		}
+		return nil
+	})
+}
+
+// MapErrorType sends the errors of the type of sample as status:
+//
+//	beego.MapErrorType(&validation.Error{}, 422, "invalid", "")
+func MapErrorType(sample error, status int, code, message string) *App {
+	he := NewHTTPError(status, code, message)
+	typ := reflect.TypeOf(sample)
+	return AddErrorMapper(func(err error) *HTTPError {
+		for ; err != nil; err = errors.Unwrap(err) {
+			if reflect.TypeOf(err) == typ {
+				return he.Wrap(err)
+			}
+		}
+		return nil
+	})
+}
+
+// statusError is an error knowing its status, like orm.ErrNoRows.
+type statusError interface {
+	error
+	HTTPStatus() int
+}
+
+// AsHTTPError returns the HTTPError of err: the HTTPError in its chain, the
+// one of the error mappers, or the status of an error knowing it. it's nil
+// for the errors not mapped, which are internal errors.
+func AsHTTPError(err error) *HTTPError {
+	if err == nil {
+		return nil
+	}
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he
+	}
+	for _, f := range errorMappers {
+		if he := f(err); he != nil {
+			return he
+		}
+	}
+	var se statusError
+	if errors.As(err, &se) {
+		return NewHTTPError(se.HTTPStatus(), "", "").Wrap(err)
+	}
+	return nil
+}
+
+// wantsJSON reports whether the client of ctx accepts json rather than html.
+func wantsJSON(ctx *context.Context) bool {
+	accept := ctx.Input.Header("Accept")
+	return strings.Contains(accept, "json") && !strings.Contains(accept, "text/html")
+}
+
+// RenderError sends err as its HTTPError, an internal error when it's not
+// mapped: json to the clients accepting json, the error page of the status
+// registered by Errorhandler or ErrorController otherwise.
+func RenderError(ctx *context.Context, err error) {
+	he := AsHTTPError(err)
+	if he == nil {
+		he = NewHTTPError(http.StatusInternalServerError, "", "").Wrap(err)
+	}
+	if he.Status >= 500 && he.Err != nil {
+		Error("the request url is", ctx.Input.Url(), "error:", he.Error())
+	}
+	writeHTTPError(ctx, he)
+}
+
+func writeHTTPError(ctx *context.Context, he *HTTPError) {
+	if wantsJSON(ctx) {
+		body, _ := json.Marshal(he)
+		ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+		ctx.ResponseWriter.WriteHeader(he.Status)
+		ctx.ResponseWriter.Write(body)
+		return
+	}
+	ctx.ResponseWriter.WriteHeader(he.Status)
+	if h, ok := ErrorMaps[strconv.Itoa(he.Status)]; ok {
+		executeError(h, ctx)
+		return
+	}
+	ctx.Output.Header("Content-Type", "text/plain; charset=utf-8")
+	ctx.WriteString(he.Message)
+}
+
+// returnedError returns the error returned by a controller method, the last
+// of its results.
+func returnedError(out []reflect.Value) error {
+	if len(out) == 0 {
+		return nil
+	}
+	last := out[len(out)-1]
+	if last.Kind() != reflect.Interface || last.IsNil() {
+		return nil
+	}
+	err, _ := last.Interface().(error)
+	return err
+}
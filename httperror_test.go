@@ -0,0 +1,170 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+var errNoStock = errors.New("no stock")
+
+type validationErr struct{ field string }
+
+func (e *validationErr) Error() string { return e.field + " is invalid" }
+
+type notFoundErr struct{}
+
+func (notFoundErr) Error() string   { return "not found" }
+func (notFoundErr) HTTPStatus() int { return 404 }
+
+type errorController struct {
+	Controller
+}
+
+func (c *errorController) Stock() error {
+	return fmt.Errorf("cart: %w", errNoStock)
+}
+
+func (c *errorController) Invalid() error {
+	return &validationErr{"name"}
+}
+
+func (c *errorController) Crash() error {
+	return errors.New("db down")
+}
+
+func (c *errorController) Gone() {
+	c.CustomAbort(410, "the item was removed")
+}
+
+func (c *errorController) Fine() error {
+	c.Ctx.WriteString("ok")
+	return nil
+}
+
+func TestAsHTTPError(t *testing.T) {
+	defer func(m []ErrorMapper) { errorMappers = m }(errorMappers)
+	MapError(errNoStock, 409, "no_stock", "")
+
+	he := NewHTTPError(http.StatusUnprocessableEntity, "", "")
+	if he.Code != "unprocessable_entity" || he.Message != "Unprocessable Entity" {
+		t.Fatal("defaults:", he.Code, he.Message)
+	}
+	if got := AsHTTPError(fmt.Errorf("wrapped: %w", he)); got != he {
+		t.Fatal("wrapped HTTPError:", got)
+	}
+	if got := AsHTTPError(fmt.Errorf("cart: %w", errNoStock)); got == nil || got.Status != 409 || !errors.Is(got, errNoStock) {
+		t.Fatal("mapped error:", got)
+	}
+	if got := AsHTTPError(notFoundErr{}); got == nil || got.Status != 404 || got.Code != "not_found" {
+		t.Fatal("error with its status:", got)
+	}
+	if got := AsHTTPError(errors.New("oops")); got != nil {
+		t.Fatal("error not mapped:", got)
+	}
+}
+
+func TestHTTPErrorResponses(t *testing.T) {
+	defer func(m []ErrorMapper) { errorMappers = m }(errorMappers)
+	MapError(errNoStock, 409, "no_stock", "the item is sold out")
+	MapErrorType(&validationErr{}, 422, "invalid", "")
+
+	handler := NewControllerRegister()
+	handler.Add("/stock", &errorController{}, "get:Stock")
+	handler.Add("/invalid", &errorController{}, "get:Invalid")
+	handler.Add("/crash", &errorController{}, "get:Crash")
+	handler.Add("/gone", &errorController{}, "get:Gone")
+	handler.Add("/fine", &errorController{}, "get:Fine")
+	handler.InsertFilter("/locked", BeforeRouter, func(ctx *context.Context) {
+		panic(NewHTTPError(423, "locked", "the cart is locked"))
+	})
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		r.Header.Set("Accept", accept)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) HTTPError {
+		var he HTTPError
+		if err := json.Unmarshal(w.Body.Bytes(), &he); err != nil {
+			t.Fatal("json body:", w.Body.String(), err)
+		}
+		return he
+	}
+
+	w := do("/stock", "application/json")
+	if he := decode(w); w.Code != 409 || he.Status != 409 || he.Code != "no_stock" || he.Message != "the item is sold out" {
+		t.Fatal("mapped error as json:", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatal("content type:", ct)
+	}
+	if w := do("/stock", "text/html,application/json"); w.Code != 409 || w.Body.String() != "the item is sold out" {
+		t.Fatal("mapped error as html:", w.Code, w.Body.String())
+	}
+	if w := do("/invalid", "application/json"); w.Code != 422 || decode(w).Code != "invalid" {
+		t.Fatal("mapped error type:", w.Code, w.Body.String())
+	}
+	w = do("/crash", "application/json")
+	if he := decode(w); w.Code != 500 || he.Code != "internal_server_error" || strings.Contains(w.Body.String(), "db down") {
+		t.Fatal("error not mapped:", w.Code, w.Body.String())
+	}
+	if w := do("/locked", "application/json"); w.Code != 423 || decode(w).Code != "locked" {
+		t.Fatal("HTTPError panic in a filter:", w.Code, w.Body.String())
+	}
+	if w := do("/gone", "application/json"); w.Code != 410 || decode(w).Message != "the item was removed" {
+		t.Fatal("CustomAbort as json:", w.Code, w.Body.String())
+	}
+	if w := do("/gone", "text/html"); w.Code != 410 || w.Body.String() != "the item was removed" {
+		t.Fatal("CustomAbort as html:", w.Code, w.Body.String())
+	}
+	if w := do("/missing", "application/json"); w.Code != 404 || decode(w).Code != "not_found" {
+		t.Fatal("not found as json:", w.Code, w.Body.String())
+	}
+	if w := do("/fine", "application/json"); w.Code != 200 || w.Body.String() != "ok" {
+		t.Fatal("nil error:", w.Code, w.Body.String())
+	}
+
+	// the error pages of the app are kept for the json clients
+	for _, code := range []string{"404", "410"} {
+		defer func(code string, h *errorInfo) {
+			if h == nil {
+				delete(ErrorMaps, code)
+			} else {
+				ErrorMaps[code] = h
+			}
+		}(code, ErrorMaps[code])
+		body := `{"app":"` + code + `"}`
+		Errorhandler(code, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+	if w := do("/missing", "application/json"); w.Code != 404 || w.Body.String() != `{"app":"404"}` {
+		t.Fatal("not found with an app handler:", w.Code, w.Body.String())
+	}
+	if w := do("/gone", "application/json"); w.Code != 410 || w.Body.String() != "the item was removed" {
+		t.Fatal("CustomAbort with an app handler:", w.Code, w.Body.String())
+	}
+}
@@ -32,6 +32,7 @@ package httplib
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
@@ -48,6 +49,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/aamsur/beego/tracing"
 )
 
 var defaultSetting = BeegoHttpSettings{false, "beegoServer", 60 * time.Second, 60 * time.Second, nil, nil, nil, false}
@@ -147,6 +150,14 @@ func (b *BeegoHttpRequest) SetBasicAuth(username, password string) *BeegoHttpReq
 	return b
 }
 
+// WithContext sets the context of the request, it cancels the request and
+// its span is a child of the span of ctx. the traceparent header carries the
+// trace to the server.
+func (b *BeegoHttpRequest) WithContext(ctx context.Context) *BeegoHttpRequest {
+	b.req = b.req.WithContext(ctx)
+	return b
+}
+
 // SetEnableCookie sets enable/disable cookiejar
 func (b *BeegoHttpRequest) SetEnableCookie(enable bool) *BeegoHttpRequest {
 	b.setting.EnableCookie = enable
@@ -372,6 +383,15 @@ func (b *BeegoHttpRequest) getResponse() (*http.Response, error) {
 		b.req.Header.Set("User-Agent", b.setting.UserAgent)
 	}
 
+	ctx, span := tracing.Start(b.req.Context(), "HTTP "+b.req.Method, tracing.SpanKindClient)
+	if span != nil {
+		span.SetAttribute("http.request.method", b.req.Method)
+		span.SetAttribute("url.full", b.req.URL.String())
+		span.SetAttribute("server.address", b.req.URL.Host)
+		tracing.Inject(ctx, b.req.Header)
+		defer span.End()
+	}
+
 	if b.setting.ShowDebug {
 		dump, err := httputil.DumpRequest(b.req, true)
 		if err != nil {
@@ -382,8 +402,13 @@ func (b *BeegoHttpRequest) getResponse() (*http.Response, error) {
 
 	resp, err := client.Do(b.req)
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= 400 {
+		span.SetStatus(tracing.StatusError, resp.Status)
+	}
 	b.resp = resp
 	return resp, nil
 }
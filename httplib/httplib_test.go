@@ -15,10 +15,16 @@
 package httplib
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/aamsur/beego/tracing"
 )
 
 func TestResponse(t *testing.T) {
@@ -214,3 +220,30 @@ func TestHeader(t *testing.T) {
 	}
 	t.Log(str)
 }
+
+func TestTraceParent(t *testing.T) {
+	var buf bytes.Buffer
+	tracing.Configure(tracing.Config{Exporter: tracing.NewWriterExporter(&buf), SampleRatio: 1})
+	defer tracing.Shutdown()
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+	}))
+	defer ts.Close()
+
+	ctx, span := tracing.Start(context.Background(), "GET /", tracing.SpanKindServer)
+	if _, err := Get(ts.URL).WithContext(ctx).String(); err != nil {
+		t.Fatal(err)
+	}
+	span.End()
+	tracing.Flush()
+
+	sc, ok := tracing.ParseTraceParent(traceparent)
+	if !ok || sc.TraceID != span.SpanContext().TraceID || sc.SpanID == span.SpanContext().SpanID {
+		t.Fatal("traceparent:", traceparent)
+	}
+	if !strings.Contains(buf.String(), `"name":"HTTP GET"`) || !strings.Contains(buf.String(), `"spanId":"`+sc.SpanID.String()+`"`) {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/i18n"
+	"github.com/aamsur/beego/utils"
+)
+
+// initI18n loads the locale files of I18nPath, if it exists, and selects
+// the locale of the requests.
+func initI18n() error {
+	if !utils.FileExists(I18nPath) {
+		return nil
+	}
+	i18n.SetDefault(I18nDefault)
+	if err := i18n.LoadDir(I18nPath); err != nil {
+		return err
+	}
+	InsertFilter("*", BeforeRouter, localeFilter)
+	return nil
+}
+
+// localeFilter sets Data["Lang"] to the locale of the request, unless a filter
+// before already set it, e.g. from the user settings: the locale of the
+// I18nQuery parameter, kept in the I18nCookie cookie, else the one of the
+// cookie, else the one matching the Accept-Language header.
+func localeFilter(ctx *context.Context) {
+	if _, ok := ctx.Input.Data["Lang"]; !ok {
+		ctx.Input.Data["Lang"] = requestLocale(ctx)
+	}
+}
+
+func requestLocale(ctx *context.Context) string {
+	// the url query only, parsing the form would read the body
+	if query := ctx.Request.URL.Query().Get(I18nQuery); I18nQuery != "" && query != "" {
+		if lang, ok := i18n.MatchLocale(query); ok {
+			if I18nCookie != "" && ctx.Input.Cookie(I18nCookie) != lang {
+				ctx.Output.Cookie(I18nCookie, lang, 365*24*3600, "/")
+			}
+			return lang
+		}
+	}
+	if cookie := ctx.Input.Cookie(I18nCookie); I18nCookie != "" && cookie != "" {
+		if lang, ok := i18n.MatchLocale(cookie); ok {
+			return lang
+		}
+	}
+	for _, l := range ctx.Input.AcceptsLanguages() {
+		if lang, ok := i18n.MatchLocale(l.Tag); ok {
+			return lang
+		}
+	}
+	return i18n.Default()
+}
+
+// Tr translates key in the locale of the request, see i18n.Tr.
+func (c *Controller) Tr(key string, args ...interface{}) string {
+	lang, _ := c.Data["Lang"].(string)
+	return i18n.Tr(lang, key, args...)
+}
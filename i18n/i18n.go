@@ -0,0 +1,388 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package i18n translates messages with per-locale json or toml files and
+// plural forms.
+//
+// conf/locale/en-US.json:
+//
+//	{
+//		"paginator": {"first_page": "First"},
+//		"cart.items": {"zero": "Your cart is empty", "one": "%d item", "other": "%d items"}
+//	}
+//
+// or conf/locale/en-US.toml:
+//
+//	[paginator]
+//	first_page = "First"
+//
+//	[cart.items]
+//	zero = "Your cart is empty"
+//	one = "%d item"
+//	other = "%d items"
+//
+// usage:
+//
+//	i18n.LoadDir("conf/locale")
+//	i18n.Tr("en-US", "paginator.first_page")
+//	i18n.Tr("en-US", "cart.items", 3) // "3 items"
+//
+// beego loads I18nPath at startup, selects the locale of every request from
+// its lang query, its lang cookie or its Accept-Language header into .Lang and
+// adds the i18n template function:
+//
+//	{{i18n .Lang "cart.items" .Count}}
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// message is a translation, with its plural forms if it has some.
+type message struct {
+	text   string
+	plural map[string]string // keyed by zero, one, two, few, many and other
+}
+
+var (
+	lock          sync.RWMutex
+	locales       = make(map[string]map[string]*message)
+	fallbacks     = make(map[string][]string)
+	defaultLocale = "en-US"
+)
+
+// pluralForms are the keys of a plural message.
+var pluralForms = map[string]bool{"zero": true, "one": true, "two": true, "few": true, "many": true, "other": true}
+
+// LoadDir loads the locale files of dir, named by their locale like en-US.json
+// or en-US.toml. it replaces the locales loaded before.
+func LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.*"))
+	if err != nil {
+		return err
+	}
+	loaded := make(map[string]map[string]*message)
+	for _, file := range files {
+		ext := filepath.Ext(file)
+		decode, ok := decoders[ext]
+		if !ok {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		messages, err := parse(data, decode)
+		if err != nil {
+			return fmt.Errorf("i18n: %s: %v", file, err)
+		}
+		locale := strings.TrimSuffix(filepath.Base(file), ext)
+		if loaded[locale] == nil {
+			loaded[locale] = messages
+			continue
+		}
+		for key, m := range messages {
+			loaded[locale][key] = m
+		}
+	}
+	lock.Lock()
+	locales = loaded
+	lock.Unlock()
+	return nil
+}
+
+// decoders decode the locale files by extension.
+var decoders = map[string]func([]byte) (map[string]interface{}, error){
+	".json": decodeJSON,
+	".toml": parseTOML,
+}
+
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	err := json.Unmarshal(data, &tree)
+	return tree, err
+}
+
+// Load adds the messages of a locale from json data.
+func Load(locale string, data []byte) error {
+	return load(locale, data, decodeJSON)
+}
+
+// LoadTOML adds the messages of a locale from toml data.
+func LoadTOML(locale string, data []byte) error {
+	return load(locale, data, parseTOML)
+}
+
+func load(locale string, data []byte, decode func([]byte) (map[string]interface{}, error)) error {
+	messages, err := parse(data, decode)
+	if err != nil {
+		return fmt.Errorf("i18n: %s: %v", locale, err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if locales[locale] == nil {
+		locales[locale] = messages
+		return nil
+	}
+	for key, m := range messages {
+		locales[locale][key] = m
+	}
+	return nil
+}
+
+// parse flattens the nested objects of data into dotted keys,
+// the objects with plural form keys only are plural messages.
+func parse(data []byte, decode func([]byte) (map[string]interface{}, error)) (map[string]*message, error) {
+	tree, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	messages := make(map[string]*message)
+	var walk func(prefix string, tree map[string]interface{}) error
+	walk = func(prefix string, tree map[string]interface{}) error {
+		for key, value := range tree {
+			switch v := value.(type) {
+			case string:
+				messages[prefix+key] = &message{text: v}
+			case map[string]interface{}:
+				if m, ok := pluralMessage(v); ok {
+					messages[prefix+key] = m
+				} else if err := walk(prefix+key+".", v); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%s%s is not a string or an object", prefix, key)
+			}
+		}
+		return nil
+	}
+	return messages, walk("", tree)
+}
+
+func pluralMessage(tree map[string]interface{}) (*message, bool) {
+	m := &message{plural: make(map[string]string)}
+	for key, value := range tree {
+		text, ok := value.(string)
+		if !ok || !pluralForms[key] {
+			return nil, false
+		}
+		m.plural[key] = text
+	}
+	m.text = m.plural["other"]
+	return m, len(m.plural) > 0
+}
+
+// SetDefault sets the locale used when a message is missing in the locale
+// and its fallbacks, en-US by default.
+func SetDefault(locale string) {
+	lock.Lock()
+	defer lock.Unlock()
+	defaultLocale = locale
+}
+
+// Default returns the default locale, see SetDefault.
+func Default() string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return defaultLocale
+}
+
+// SetFallback sets the locales searched for a message missing in locale,
+// before its base language and the default locale.
+//	i18n.SetFallback("pt-BR", "pt-PT")
+func SetFallback(locale string, fallback ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+	fallbacks[locale] = fallback
+}
+
+// Locales returns the loaded locales, sorted.
+func Locales() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsExist returns whether the locale is loaded.
+func IsExist(locale string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	_, ok := locales[locale]
+	return ok
+}
+
+// chain returns the locales searched for a message of locale:
+// locale, its fallbacks, its base language and the default locale.
+func chain(locale string) []string {
+	c := append([]string{locale}, fallbacks[locale]...)
+	if base := language(locale); base != locale {
+		c = append(c, base)
+	}
+	return append(c, defaultLocale)
+}
+
+// Tr translates key in locale. when the first arg is a number, it selects the
+// plural form of the message. the args format the message like fmt.Sprintf.
+// a missing message is returned as its key.
+func Tr(locale, key string, args ...interface{}) string {
+	lock.RLock()
+	var m *message
+	for _, l := range chain(locale) {
+		if m = locales[l][key]; m != nil {
+			locale = l
+			break
+		}
+	}
+	lock.RUnlock()
+	if m == nil {
+		return key
+	}
+
+	text := m.text
+	if m.plural != nil && len(args) > 0 {
+		if n, ok := count(args[0]); ok {
+			text = m.pluralText(locale, n)
+		}
+	}
+	// a plural form like "no items" doesn't use the count
+	if len(args) == 0 || !strings.Contains(text, "%") {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+func (m *message) form(form string) string {
+	if text, ok := m.plural[form]; ok {
+		return text
+	}
+	return m.text
+}
+
+// count returns the number of a plural message arg.
+func count(arg interface{}) (int64, bool) {
+	switch v := arg.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Match returns the loaded locale best matching an Accept-Language header,
+// or the default locale.
+func Match(acceptLanguage string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{name: strings.TrimSpace(fields[0]), q: 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					t.q = q
+				}
+			}
+		}
+		if t.name != "" && t.name != "*" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if name, ok := MatchLocale(t.name); ok {
+			return name
+		}
+	}
+	lock.RLock()
+	defer lock.RUnlock()
+	return defaultLocale
+}
+
+// MatchLocale returns the loaded locale matching name, like en-US for en-us
+// or en, and whether there's one.
+func MatchLocale(name string) (string, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	for l := range locales {
+		if strings.EqualFold(l, name) {
+			return l, true
+		}
+	}
+	// en matches en-US, en-GB matches en, the default locale first
+	return matchBase(name)
+}
+
+// matchBase returns the loaded locale with the same base language as name.
+func matchBase(name string) (string, bool) {
+	base := strings.ToLower(language(name))
+	if strings.ToLower(language(defaultLocale)) == base && locales[defaultLocale] != nil {
+		return defaultLocale, true
+	}
+	var found []string
+	for l := range locales {
+		if strings.ToLower(language(l)) == base {
+			found = append(found, l)
+		}
+	}
+	if len(found) == 0 {
+		return "", false
+	}
+	sort.Strings(found)
+	return found[0], true
+}
+
+// language returns the base language of a locale, en of en-US.
+func language(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		return locale[:i]
+	}
+	return locale
+}
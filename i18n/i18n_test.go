@@ -0,0 +1,168 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testLocales = map[string]string{
+	"en-US.json": `{
+		"paginator": {"first_page": "First", "last_page": "Last"},
+		"cart.items": {"zero": "Your cart is empty", "one": "%d item", "other": "%d items"},
+		"hello": "Hello %s"
+	}`,
+	"ru.json": `{
+		"paginator": {"first_page": "Первая"},
+		"cart.items": {"one": "%d товар", "few": "%d товара", "many": "%d товаров"}
+	}`,
+	"pt-PT.json": `{"paginator": {"first_page": "Primeira"}}`,
+	"pt-PT.toml": "[paginator]\nlast_page = \"Última\"\n",
+}
+
+func TestTr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range testLocales {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+	}
+	if err := LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	SetFallback("pt-BR", "pt-PT")
+	defer delete(fallbacks, "pt-BR")
+
+	tests := []struct {
+		locale, key string
+		args        []interface{}
+		want        string
+	}{
+		{"en-US", "paginator.first_page", nil, "First"},
+		{"en-US", "hello", []interface{}{"beego"}, "Hello beego"},
+		{"en-US", "cart.items", []interface{}{0}, "Your cart is empty"},
+		{"en-US", "cart.items", []interface{}{1}, "1 item"},
+		{"en-US", "cart.items", []interface{}{5}, "5 items"},
+		{"ru-RU", "cart.items", []interface{}{21}, "21 товар"},
+		{"ru-RU", "cart.items", []interface{}{3}, "3 товара"},
+		{"ru-RU", "cart.items", []interface{}{11}, "11 товаров"},
+		{"ru-RU", "paginator.last_page", nil, "Last"},
+		{"pt-BR", "paginator.first_page", nil, "Primeira"},
+		{"pt-BR", "paginator.last_page", nil, "Última"},
+		{"en-US", "missing.key", nil, "missing.key"},
+	}
+	for _, test := range tests {
+		if got := Tr(test.locale, test.key, test.args...); got != test.want {
+			t.Errorf("Tr(%q, %q, %v) = %q, want %q", test.locale, test.key, test.args, got, test.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	defer func(l map[string]map[string]*message) { locales = l }(locales)
+	locales = map[string]map[string]*message{"en-US": {}, "ru": {}, "pt-BR": {}, "pt-PT": {}}
+
+	tests := map[string]string{
+		"ru-RU,ru;q=0.9,en;q=0.8": "ru",
+		"de;q=0.9,pt;q=0.5":       "pt-BR",
+		"fr, en;q=0.1":            "en-US",
+		"pt-pt":                   "pt-PT",
+		"de":                      "en-US",
+		"":                        "en-US",
+		"ru;q=0, pt-PT":           "pt-PT",
+	}
+	for header, want := range tests {
+		if got := Match(header); got != want {
+			t.Errorf("Match(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	defer func(l map[string]map[string]*message) { locales = l }(locales)
+	locales = make(map[string]map[string]*message)
+
+	err := LoadTOML("fr", []byte(`
+# les messages du panier
+greeting = "Bonjour %s" # inline comment
+"quoted.key" = 'C:\chemin'
+
+[paginator]
+first_page = "Premier"
+last.page = "Dernier"
+
+[cart.items]
+one = "%d article"
+other = "%d articles"
+
+[help]
+text = """
+Ligne un
+Ligne "deux" \t\u00e9""""
+inline = { zero = "Aucun", other = "%d" }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		args []interface{}
+		want string
+	}{
+		{"greeting", []interface{}{"beego"}, "Bonjour beego"},
+		{"quoted.key", nil, `C:\chemin`},
+		{"paginator.first_page", nil, "Premier"},
+		{"paginator.last.page", nil, "Dernier"},
+		{"cart.items", []interface{}{0}, "0 article"},
+		{"cart.items", []interface{}{2}, "2 articles"},
+		{"help.text", nil, "Ligne un\nLigne \"deux\" \té\""},
+		{"help.inline", []interface{}{0}, "Aucun"},
+	}
+	for _, test := range tests {
+		if got := Tr("fr", test.key, test.args...); got != test.want {
+			t.Errorf("Tr(fr, %q, %v) = %q, want %q", test.key, test.args, got, test.want)
+		}
+	}
+
+	for _, data := range []string{
+		"key = 1",
+		"key = \"open",
+		"[table",
+		"key \"value\"",
+		"a = \"x\"\na = \"y\"",
+		"a = \"x\"\n[a]",
+	} {
+		if err := LoadTOML("fr", []byte(data)); err == nil {
+			t.Errorf("LoadTOML(%q) should fail", data)
+		}
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	defer func(l map[string]map[string]*message) { locales = l }(locales)
+	locales = map[string]map[string]*message{"en-US": {}, "pt-BR": {}}
+
+	tests := map[string]string{"en-us": "en-US", "en": "en-US", "pt-PT": "pt-BR", "de": "", "": ""}
+	for name, want := range tests {
+		if got, ok := MatchLocale(name); got != want || ok != (want != "") {
+			t.Errorf("MatchLocale(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+}
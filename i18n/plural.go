@@ -0,0 +1,123 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+import (
+	"strings"
+	"sync"
+)
+
+// PluralRule returns the plural form of n: zero, one, two, few, many or other.
+type PluralRule func(n int64) string
+
+var (
+	rulesLock sync.RWMutex
+	rules     = map[string]PluralRule{
+		"fr": oneUpToOne,
+		"pt": oneUpToOne,
+		"ja": otherOnly,
+		"ko": otherOnly,
+		"zh": otherOnly,
+		"vi": otherOnly,
+		"th": otherOnly,
+		"id": otherOnly,
+		"ru": slavic,
+		"uk": slavic,
+		"be": slavic,
+		"pl": polish,
+		"cs": czech,
+		"sk": czech,
+	}
+)
+
+// SetPluralRule sets the plural rule of a locale or a language,
+// the languages without one use the english rule.
+func SetPluralRule(locale string, rule PluralRule) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+	rules[locale] = rule
+}
+
+// PluralForm returns the plural form of n in locale. zero is returned for 0
+// and used when the message has it, else the form of the rule.
+func PluralForm(locale string, n int64) string {
+	rulesLock.RLock()
+	rule, ok := rules[locale]
+	if !ok {
+		rule, ok = rules[strings.ToLower(language(locale))]
+	}
+	rulesLock.RUnlock()
+	if !ok {
+		rule = english
+	}
+	return rule(n)
+}
+
+func (m *message) pluralText(locale string, n int64) string {
+	if n == 0 {
+		if text, ok := m.plural["zero"]; ok {
+			return text
+		}
+	}
+	return m.form(PluralForm(locale, n))
+}
+
+func english(n int64) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+func oneUpToOne(n int64) string {
+	if n == 0 || n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+func otherOnly(n int64) string {
+	return "other"
+}
+
+func slavic(n int64) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return "one"
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "few"
+	}
+	return "many"
+}
+
+func polish(n int64) string {
+	switch {
+	case n == 1:
+		return "one"
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "few"
+	}
+	return "many"
+}
+
+func czech(n int64) string {
+	switch {
+	case n == 1:
+		return "one"
+	case n >= 2 && n <= 4:
+		return "few"
+	}
+	return "other"
+}
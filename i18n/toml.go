@@ -0,0 +1,284 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML decodes the toml subset of the locale files: tables, dotted and
+// quoted keys, basic, literal and multi-line strings and inline tables.
+//
+//	[paginator]
+//	first_page = "First"
+//
+//	[cart.items]
+//	zero = "Your cart is empty"
+//	one = "%d item"
+//	other = "%d items"
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	table := tree
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	n := 0
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		n++
+		return scanner.Text(), true
+	}
+	for {
+		line, ok := next()
+		if !ok {
+			break
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.LastIndex(line, "]")
+			if end < 0 || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table %s", n, line)
+			}
+			keys, rest, err := parseKey(line[1:end])
+			if err != nil || strings.TrimSpace(rest) != "" {
+				return nil, fmt.Errorf("line %d: invalid table %s", n, line)
+			}
+			if table, err = subTable(tree, keys); err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != '#' {
+				return nil, fmt.Errorf("line %d: unexpected %s", n, rest)
+			}
+			continue
+		}
+
+		// a multi-line string continues on the next lines
+		if i := strings.Index(line, `"""`); i >= 0 && strings.Count(line, `"""`) == 1 {
+			for {
+				more, ok := next()
+				if !ok {
+					return nil, fmt.Errorf("line %d: unterminated string", n)
+				}
+				line += "\n" + more
+				if strings.Contains(more, `"""`) {
+					break
+				}
+			}
+		}
+		if err := parseKeyValue(table, line); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	return tree, scanner.Err()
+}
+
+func parseKeyValue(table map[string]interface{}, s string) error {
+	keys, rest, err := parseKey(s)
+	if err != nil {
+		return err
+	}
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, "=") {
+		return fmt.Errorf("missing = after %s", strings.Join(keys, "."))
+	}
+	value, rest, err := parseValue(strings.TrimSpace(rest[1:]))
+	if err != nil {
+		return err
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return fmt.Errorf("unexpected %s", rest)
+	}
+	parent, err := subTable(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	key := keys[len(keys)-1]
+	if _, ok := parent[key]; ok {
+		return fmt.Errorf("duplicate key %s", strings.Join(keys, "."))
+	}
+	parent[key] = value
+	return nil
+}
+
+// parseKey returns the dotted parts of the key at the start of s.
+func parseKey(s string) (keys []string, rest string, err error) {
+	for {
+		s = strings.TrimSpace(s)
+		var key string
+		switch {
+		case s == "":
+			return nil, "", fmt.Errorf("missing key")
+		case s[0] == '"' || s[0] == '\'':
+			if key, s, err = parseString(s); err != nil {
+				return nil, "", err
+			}
+		default:
+			i := 0
+			for i < len(s) && isBareKey(s[i]) {
+				i++
+			}
+			if i == 0 {
+				return nil, "", fmt.Errorf("invalid key %s", s)
+			}
+			key, s = s[:i], s[i:]
+		}
+		keys = append(keys, key)
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, ".") {
+			return keys, s, nil
+		}
+		s = s[1:]
+	}
+}
+
+func isBareKey(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue returns the string or the inline table at the start of s.
+func parseValue(s string) (interface{}, string, error) {
+	if !strings.HasPrefix(s, "{") {
+		return parseString(s)
+	}
+	table := make(map[string]interface{})
+	s = strings.TrimSpace(s[1:])
+	if strings.HasPrefix(s, "}") {
+		return table, s[1:], nil
+	}
+	for {
+		keys, rest, err := parseKey(s)
+		if err != nil {
+			return nil, "", err
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "=") {
+			return nil, "", fmt.Errorf("missing = after %s", strings.Join(keys, "."))
+		}
+		value, rest, err := parseValue(strings.TrimSpace(rest[1:]))
+		if err != nil {
+			return nil, "", err
+		}
+		parent, err := subTable(table, keys[:len(keys)-1])
+		if err != nil {
+			return nil, "", err
+		}
+		parent[keys[len(keys)-1]] = value
+		rest = strings.TrimSpace(rest)
+		switch {
+		case strings.HasPrefix(rest, ","):
+			s = strings.TrimSpace(rest[1:])
+		case strings.HasPrefix(rest, "}"):
+			return table, rest[1:], nil
+		default:
+			return nil, "", fmt.Errorf("unterminated inline table")
+		}
+	}
+}
+
+// parseString returns the string at the start of s and what follows it.
+func parseString(s string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"""`):
+		for i := 3; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if !strings.HasPrefix(s[i:], `"""`) {
+				continue
+			}
+			// up to two quotes end the string before the closing ones
+			for j := 0; j < 2 && strings.HasPrefix(s[i+1:], `"""`); j++ {
+				i++
+			}
+			// the newline right after the opening quotes is trimmed
+			v, err := unquote(strings.TrimPrefix(s[3:i], "\n"))
+			return v, s[i+3:], err
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := unquote(s[1:i])
+				return v, s[i+1:], err
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : 1+end], s[2+end:], nil
+	}
+	return "", "", fmt.Errorf("invalid value %s, messages are strings", s)
+}
+
+// unquote replaces the escapes of a basic string.
+func unquote(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	// the quotes and newlines of multi-line strings aren't escaped
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 == len(s) {
+				return "", fmt.Errorf("invalid string %q", s)
+			}
+			b.WriteString(s[i : i+2])
+			i++
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	v, err := strconv.Unquote(`"` + b.String() + `"`)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %q", s)
+	}
+	return v, nil
+}
+
+// subTable returns the table of keys in tree, created when it's missing.
+func subTable(tree map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for i, key := range keys {
+		switch v := tree[key].(type) {
+		case nil:
+			t := make(map[string]interface{})
+			tree[key] = t
+			tree = t
+		case map[string]interface{}:
+			tree = v
+		default:
+			return nil, fmt.Errorf("%s is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/i18n"
+)
+
+func TestLocaleFilter(t *testing.T) {
+	i18n.Load("en-US", []byte(`{"hello": "Hello"}`))
+	i18n.Load("fr", []byte(`{"hello": "Bonjour"}`))
+	i18n.Load("de-DE", []byte(`{"hello": "Hallo"}`))
+
+	detect := func(url, cookie, accept string) (string, string) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", url, nil)
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "lang", Value: cookie})
+		}
+		r.Header.Set("Accept-Language", accept)
+		ctx := &context.Context{Input: context.NewInput(r), Output: context.NewOutput(), Request: r, ResponseWriter: w}
+		ctx.Output.Context = ctx
+		localeFilter(ctx)
+		return ctx.Input.Data["Lang"].(string), w.Header().Get("Set-Cookie")
+	}
+
+	if lang, set := detect("/?lang=fr", "de-DE", "de"); lang != "fr" || !strings.HasPrefix(set, "lang=fr;") {
+		t.Fatal("query:", lang, set)
+	}
+	if lang, set := detect("/?lang=fr", "fr", "de"); lang != "fr" || set != "" {
+		t.Fatal("query kept in the cookie:", lang, set)
+	}
+	if lang, _ := detect("/?lang=xx", "de", "fr"); lang != "de-DE" {
+		t.Fatal("cookie:", lang)
+	}
+	if lang, _ := detect("/", "xx", "fr-CA,en;q=0.5"); lang != "fr" {
+		t.Fatal("Accept-Language:", lang)
+	}
+	if lang, _ := detect("/", "", ""); lang != "en-US" {
+		t.Fatal("default:", lang)
+	}
+}
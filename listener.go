@@ -0,0 +1,180 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aamsur/beego/grace"
+)
+
+// ListenConfig describes where and how a listener serves its App.
+type ListenConfig struct {
+	Addr          string      // host:port or unix:/path/app.sock
+	SocketMode    os.FileMode // file mode of a unix socket
+	CertFile      string      // https is served when CertFile and KeyFile are set
+	KeyFile       string
+	ClientCAFile  string // mutual tls, same as HttpClientCAFile
+	ClientAuth    string // same as HttpClientAuth
+	ClientCRLFile string // same as HttpClientCRLFile
+}
+
+// listenerApp is an App served on its own address besides BeeApp.
+type listenerApp struct {
+	name   string
+	app    *App
+	config *ListenConfig
+}
+
+var (
+	listenerLock sync.Mutex
+	listeners    []*listenerApp
+)
+
+// Listener returns the App of the named listener, created on first use.
+// it has its own routes and filters and is served by Run on the address
+// and tls settings of the app.conf section with the same name:
+//	[internal]
+//	Addr = 10.0.0.5:9000
+//	CertFile = conf/internal.crt
+//	KeyFile = conf/internal.key
+//	ClientCAFile = conf/services-ca.pem
+//
+//	internal := beego.Listener("internal")
+//	internal.Handlers.Add("/stats", &StatsController{})
+//	internal.Handlers.InsertFilter("*", beego.BeforeRouter, beego.RequireClientCert())
+//
+// listeners named in "Listeners = internal;metrics" are created by ParseConfig.
+func Listener(name string) *App {
+	listenerLock.Lock()
+	defer listenerLock.Unlock()
+	for _, l := range listeners {
+		if l.name == name {
+			return l.app
+		}
+	}
+	l := &listenerApp{name: name, app: NewApp()}
+	listeners = append(listeners, l)
+	return l.app
+}
+
+// SetListenConfig sets the address and tls settings of the named listener,
+// instead of reading them from app.conf.
+func SetListenConfig(name string, config *ListenConfig) {
+	Listener(name)
+	listenerLock.Lock()
+	defer listenerLock.Unlock()
+	for _, l := range listeners {
+		if l.name == name {
+			l.config = config
+		}
+	}
+}
+
+// listenConfig reads the ListenConfig of the app.conf section name.
+func listenConfig(name string) (*ListenConfig, error) {
+	c := &ListenConfig{
+		Addr:          AppConfig.String(name + "::Addr"),
+		CertFile:      AppConfig.String(name + "::CertFile"),
+		KeyFile:       AppConfig.String(name + "::KeyFile"),
+		ClientCAFile:  AppConfig.String(name + "::ClientCAFile"),
+		ClientAuth:    AppConfig.String(name + "::ClientAuth"),
+		ClientCRLFile: AppConfig.String(name + "::ClientCRLFile"),
+	}
+	if v := AppConfig.String(name + "::SocketMode"); v != "" {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return nil, errors.New(name + "::SocketMode is not an octal file mode")
+		}
+		c.SocketMode = os.FileMode(mode)
+	}
+	return c, nil
+}
+
+// runListeners starts the named listeners.
+func runListeners() {
+	listenerLock.Lock()
+	defer listenerLock.Unlock()
+	for _, l := range listeners {
+		go l.run()
+	}
+}
+
+// run serves the listener until it fails or is shut down.
+func (l *listenerApp) run() {
+	config := l.config
+	if config == nil {
+		var err error
+		if config, err = listenConfig(l.name); err != nil {
+			BeeLogger.Critical("listener ", l.name, ": ", err)
+			return
+		}
+	}
+	if config.Addr == "" && !grace.SocketActivated() {
+		BeeLogger.Critical("listener ", l.name, " has no Addr")
+		return
+	}
+	tlsConfig, err := certConfig(l.app.Server.TLSConfig, config.CertFile, config.KeyFile)
+	if err == nil {
+		tlsConfig, err = clientAuthConfig(tlsConfig, config.ClientCAFile, config.ClientAuth, config.ClientCRLFile)
+	}
+	if err != nil {
+		BeeLogger.Critical("listener ", l.name, ": ", err)
+		return
+	}
+	useTLS := tlsConfig != nil && (len(tlsConfig.Certificates) > 0 || tlsConfig.GetCertificate != nil)
+
+	srv := l.app.Server
+	srv.Handler = l.app.Handler()
+	srv.TLSConfig = tlsConfig
+	configureServer(srv)
+	BeeLogger.Info("%s server Running on %s", l.name, config.Addr)
+
+	if Graceful || grace.SocketActivated() {
+		gs := &grace.Server{Server: srv, Name: l.name, SocketMode: config.SocketMode, ReusePort: GracefulReusePort, Timeout: time.Duration(GracefulTimeout) * time.Second}
+		gs.Addr = config.Addr
+		if socket, ok := unixSocket(config.Addr); ok {
+			gs.Network, gs.Addr = "unix", socket
+		}
+		if useTLS {
+			err = gs.ListenAndServeTLS("", "")
+		} else {
+			err = gs.ListenAndServe()
+		}
+	} else {
+		var ln net.Listener
+		if socket, ok := unixSocket(config.Addr); ok {
+			ln, err = grace.ListenUnix(socket, config.SocketMode)
+		} else {
+			ln, err = net.Listen("tcp", config.Addr)
+		}
+		if err == nil {
+			if useTLS {
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				err = srv.Serve(ln)
+			}
+		}
+	}
+	if err != nil && err != http.ErrServerClosed {
+		BeeLogger.Critical("listener ", l.name, ": ", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	gocontext "context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego/context"
+)
+
+func TestListener(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	dir, err := ioutil.TempDir("", "beego-listener")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "internal.sock")
+
+	internal := Listener("internal-test")
+	if Listener("internal-test") != internal {
+		t.Fatal("Listener should return the same app for a name")
+	}
+	SetListenConfig("internal-test", &ListenConfig{Addr: "unix:" + socket})
+	internal.Handlers.InsertFilter("*", BeforeRouter, func(ctx *context.Context) {
+		ctx.Output.Header("X-Listener", "internal")
+	})
+	internal.Handlers.Get("/stats", func(ctx *context.Context) {
+		ctx.WriteString("stats")
+	})
+	internal.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "internal")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	for _, l := range listeners {
+		if l.app == internal {
+			go l.run()
+		}
+	}
+	defer internal.Server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx gocontext.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		if resp, err = client.Get("http://internal/stats"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "stats" || resp.Header.Get("X-Listener") != "internal" || resp.Header.Get("X-Middleware") != "internal" {
+		t.Errorf("listener should serve its own routes, filters and middlewares, got %q", body)
+	}
+	if tree, ok := BeeApp.Handlers.table().routers["GET"]; ok {
+		if obj, _ := tree.Match("/stats"); obj != nil {
+			t.Error("listener routes should not be added to BeeApp")
+		}
+	}
+}
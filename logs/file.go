@@ -266,6 +266,15 @@ func (w *FileLogWriter) deleteOldLog() {
 	})
 }
 
+// Reopen opens the log file again, e.g. after logrotate moved it.
+func (w *FileLogWriter) Reopen() error {
+	w.startLock.Lock()
+	defer w.startLock.Unlock()
+	w.mw.Lock()
+	defer w.mw.Unlock()
+	return w.startLogger()
+}
+
 // destroy file logger, close file writer.
 func (w *FileLogWriter) Destroy() {
 	w.mw.fd.Close()
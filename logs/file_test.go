@@ -17,8 +17,10 @@ package logs
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -112,6 +114,30 @@ func TestFileRotate(t *testing.T) {
 	os.Remove("test3.log")
 }
 
+func TestFileReopen(t *testing.T) {
+	w := NewFileWriter()
+	if err := w.Init(`{"filename":"test5.log"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("test5.log")
+	defer os.Remove("test5.log.1")
+	w.WriteMsg("before", LevelInformational)
+	// what logrotate does
+	if err := os.Rename("test5.log", "test5.log.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.(*FileLogWriter).Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteMsg("after", LevelInformational)
+	w.Destroy()
+
+	b, err := ioutil.ReadFile("test5.log")
+	if err != nil || !strings.Contains(string(b), "after") || strings.Contains(string(b), "before") {
+		t.Errorf("messages should be written to the reopened file, got %q %v", b, err)
+	}
+}
+
 func exists(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if err == nil {
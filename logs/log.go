@@ -134,6 +134,22 @@ func (bl *BeeLogger) SetLogger(adaptername string, config string) error {
 	return nil
 }
 
+// Reopen reopens the files of the adapters writing to files, like the file adapter.
+func (bl *BeeLogger) Reopen() error {
+	bl.lock.Lock()
+	defer bl.lock.Unlock()
+	for name, l := range bl.outputs {
+		if r, ok := l.(interface {
+			Reopen() error
+		}); ok {
+			if err := r.Reopen(); err != nil {
+				return fmt.Errorf("logs: reopen %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
 // remove a logger adapter in BeeLogger.
 func (bl *BeeLogger) DelLogger(adaptername string) error {
 	bl.lock.Lock()
@@ -0,0 +1,236 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// SendGridSender sends the messages with the v3 api of SendGrid.
+type SendGridSender struct {
+	APIKey   string       `json:"apikey"`
+	Endpoint string       `json:"endpoint"` // https://api.sendgrid.com/v3/mail/send by default
+	Client   *http.Client `json:"-"`
+}
+
+// MailgunSender sends the messages with the api of Mailgun.
+type MailgunSender struct {
+	Domain   string       `json:"domain"`
+	APIKey   string       `json:"apikey"`
+	Endpoint string       `json:"endpoint"` // https://api.mailgun.net/v3 by default, https://api.eu.mailgun.net/v3 in the eu
+	Client   *http.Client `json:"-"`
+}
+
+func init() {
+	Register("sendgrid", func(config string) (Sender, error) {
+		s := &SendGridSender{}
+		return s, json.Unmarshal([]byte(config), s)
+	})
+	Register("mailgun", func(config string) (Sender, error) {
+		s := &MailgunSender{}
+		return s, json.Unmarshal([]byte(config), s)
+	})
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to"`
+	Cc  []sendGridAddress `json:"cc,omitempty"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridMessage struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	ReplyTo          *sendGridAddress          `json:"reply_to,omitempty"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+}
+
+func sendGridAddresses(list []string) []sendGridAddress {
+	var addrs []sendGridAddress
+	for _, s := range list {
+		// validated by Send
+		a, _ := mail.ParseAddress(s)
+		addrs = append(addrs, sendGridAddress{Email: a.Address, Name: a.Name})
+	}
+	return addrs
+}
+
+// Send sends m.
+func (s *SendGridSender) Send(m *Message) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	msg := sendGridMessage{
+		Personalizations: []sendGridPersonalization{{
+			To:  sendGridAddresses(m.To),
+			Cc:  sendGridAddresses(m.Cc),
+			Bcc: sendGridAddresses(m.Bcc),
+		}},
+		From:    sendGridAddresses([]string{m.From})[0],
+		Subject: m.Subject,
+	}
+	if m.ReplyTo != "" {
+		if a, err := mail.ParseAddress(m.ReplyTo); err == nil {
+			msg.ReplyTo = &sendGridAddress{Email: a.Address, Name: a.Name}
+		}
+	}
+	if m.Text != "" || m.HTML == "" {
+		msg.Content = append(msg.Content, sendGridContent{"text/plain", m.Text})
+	}
+	if m.HTML != "" {
+		msg.Content = append(msg.Content, sendGridContent{"text/html", m.HTML})
+	}
+	for _, a := range m.Attachments {
+		att := sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(a.Content),
+			Type:        a.ContentType,
+			Filename:    a.Filename,
+			Disposition: "attachment",
+		}
+		if a.ContentID != "" {
+			att.Disposition, att.ContentID = "inline", a.ContentID
+		}
+		msg.Attachments = append(msg.Attachments, att)
+	}
+	if len(m.Headers) > 0 {
+		msg.Headers = make(map[string]string)
+		for k := range m.Headers {
+			msg.Headers[k] = m.Headers.Get(k)
+		}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.sendgrid.com/v3/mail/send"
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	return doAPI(s.Client, req)
+}
+
+// Send sends m.
+func (s *MailgunSender) Send(m *Message) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fields := [][2]string{{"from", m.From}, {"subject", m.Subject}, {"text", m.Text}, {"html", m.HTML}, {"h:Reply-To", m.ReplyTo}}
+	for _, to := range m.To {
+		fields = append(fields, [2]string{"to", to})
+	}
+	for _, cc := range m.Cc {
+		fields = append(fields, [2]string{"cc", cc})
+	}
+	for _, bcc := range m.Bcc {
+		fields = append(fields, [2]string{"bcc", bcc})
+	}
+	for k := range m.Headers {
+		fields = append(fields, [2]string{"h:" + k, m.Headers.Get(k)})
+	}
+	for _, f := range fields {
+		if f[1] != "" {
+			w.WriteField(f[0], f[1])
+		}
+	}
+	for _, a := range m.Attachments {
+		field, name := "attachment", a.Filename
+		if a.ContentID != "" {
+			// mailgun names the inline files by their content id
+			field, name = "inline", a.ContentID
+		}
+		part, err := w.CreateFormFile(field, name)
+		if err != nil {
+			return err
+		}
+		part.Write(a.Content)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.mailgun.net/v3"
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(endpoint, "/")+"/"+s.Domain+"/messages", &body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("api", s.APIKey)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return doAPI(s.Client, req)
+}
+
+// doAPI sends req, the errors of the 4xx responses but 429 are permanent.
+func doAPI(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("mail: %s %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,361 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mail sends mails with html and text bodies and attachments through
+// smtp or the http api of a mail service, now or from a queue retrying them.
+//
+// Usage:
+//
+//	sender, err := mail.NewSender("smtp", `{"host":"smtp.example.com","port":587,"username":"bot","password":"secret"}`)
+//
+//	m := mail.NewMessage()
+//	m.From = "Shop <bot@example.com>"
+//	m.To = []string{user.Email}
+//	m.Render("mail/welcome.tpl", user) // subject, text and html of the views
+//	m.AttachFile("static/terms.pdf")
+//	err = sender.Send(m)
+//
+// or delivered in the background, retried on the temporary failures:
+//
+//	queue := mail.NewQueue(sender, 100)
+//	queue.Start(2)
+//	queue.Send(m)
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is a mail.
+type Message struct {
+	From        string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	ReplyTo     string
+	Subject     string
+	Text        string // plain text body, optional
+	HTML        string // html body, optional
+	Headers     textproto.MIMEHeader
+	Attachments []*Attachment
+}
+
+// Attachment is a file of a message, inline when it has a content id, shown
+// in the html body by <img src="cid:ID">.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Content     []byte
+}
+
+// NewMessage returns an empty message.
+func NewMessage() *Message {
+	return &Message{Headers: textproto.MIMEHeader{}}
+}
+
+// Attach adds the content of r as the file filename. the content type
+// defaults to the one of its extension.
+func (m *Message) Attach(r io.Reader, filename, contentType string) (*Attachment, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	a := &Attachment{Filename: filename, ContentType: contentType, Content: content}
+	m.Attachments = append(m.Attachments, a)
+	return a, nil
+}
+
+// AttachFile adds the file of path.
+func (m *Message) AttachFile(path string) (*Attachment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return m.Attach(f, filepath.Base(path), "")
+}
+
+// Embed adds the file of path inline with the content id id.
+func (m *Message) Embed(path, id string) (*Attachment, error) {
+	a, err := m.AttachFile(path)
+	if err != nil {
+		return nil, err
+	}
+	a.ContentID = id
+	return a, nil
+}
+
+// Recipients returns the addresses of To, Cc and Bcc.
+func (m *Message) Recipients() ([]string, error) {
+	var to []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, addr := range list {
+			a, err := mail.ParseAddress(addr)
+			if err != nil {
+				return nil, fmt.Errorf("mail: invalid recipient %q: %v", addr, err)
+			}
+			to = append(to, a.Address)
+		}
+	}
+	return to, nil
+}
+
+// parseAddress returns the address of "Name <address>".
+func parseAddress(s string) (string, error) {
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return a.Address, nil
+}
+
+// validate checks the addresses of the message.
+func (m *Message) validate() error {
+	if m.From == "" {
+		return Permanent(errors.New("mail: missing From address"))
+	}
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return Permanent(fmt.Errorf("mail: invalid From address %q: %v", m.From, err))
+	}
+	to, err := m.Recipients()
+	if err != nil {
+		return Permanent(err)
+	}
+	if len(to) == 0 {
+		return Permanent(errors.New("mail: missing recipient"))
+	}
+	return nil
+}
+
+// Bytes returns the message in the mime format sent by smtp, without the Bcc
+// header.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	header := textproto.MIMEHeader{}
+	for k, v := range m.Headers {
+		header[k] = v
+	}
+	header.Set("From", m.From)
+	header.Set("To", strings.Join(m.To, ", "))
+	if len(m.Cc) > 0 {
+		header.Set("Cc", strings.Join(m.Cc, ", "))
+	}
+	if m.ReplyTo != "" {
+		header.Set("Reply-To", m.ReplyTo)
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	if header.Get("Date") == "" {
+		header.Set("Date", time.Now().Format(time.RFC1123Z))
+	}
+	if header.Get("Message-Id") == "" {
+		header.Set("Message-Id", messageID(m.From))
+	}
+	header.Set("MIME-Version", "1.0")
+
+	var attachments, inline []*Attachment
+	for _, a := range m.Attachments {
+		if a.ContentID != "" {
+			inline = append(inline, a)
+		} else {
+			attachments = append(attachments, a)
+		}
+	}
+
+	// multipart/mixed of the body and the attachments, the body being
+	// multipart/alternative of the text and multipart/related of the html and
+	// its inline files.
+	if len(attachments) == 0 {
+		return writeRoot(&buf, header, func(w *multipart.Writer) (string, error) {
+			return "multipart/alternative", writeAlternative(w, m.Text, m.HTML, inline)
+		})
+	}
+	return writeRoot(&buf, header, func(w *multipart.Writer) (string, error) {
+		alternative, err := nested(w, "multipart/alternative")
+		if err != nil {
+			return "", err
+		}
+		if err := writeAlternative(alternative, m.Text, m.HTML, inline); err != nil {
+			return "", err
+		}
+		if err := alternative.Close(); err != nil {
+			return "", err
+		}
+		for _, a := range attachments {
+			if err := writeAttachment(w, a); err != nil {
+				return "", err
+			}
+		}
+		return "multipart/mixed", nil
+	})
+}
+
+// writeRoot writes the header and the multipart body written by f, which
+// returns its content type.
+func writeRoot(buf *bytes.Buffer, header textproto.MIMEHeader, f func(w *multipart.Writer) (string, error)) ([]byte, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	contentType, err := f(w)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	header.Set("Content-Type", contentType+"; boundary="+w.Boundary())
+	writeHeader(buf, header)
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// nested creates a multipart part of w, returning its writer.
+func nested(w *multipart.Writer, contentType string) (*multipart.Writer, error) {
+	boundary := multipart.NewWriter(nil).Boundary()
+	part, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType + "; boundary=" + boundary}})
+	if err != nil {
+		return nil, err
+	}
+	sub := multipart.NewWriter(part)
+	sub.SetBoundary(boundary)
+	return sub, nil
+}
+
+func writeAlternative(w *multipart.Writer, text, html string, inline []*Attachment) error {
+	if text != "" || html == "" {
+		if err := writeText(w, "text/plain", text); err != nil {
+			return err
+		}
+	}
+	if html != "" {
+		if len(inline) == 0 {
+			if err := writeText(w, "text/html", html); err != nil {
+				return err
+			}
+		} else {
+			related, err := nested(w, "multipart/related")
+			if err != nil {
+				return err
+			}
+			if err := writeText(related, "text/html", html); err != nil {
+				return err
+			}
+			for _, a := range inline {
+				if err := writeAttachment(related, a); err != nil {
+					return err
+				}
+			}
+			return related.Close()
+		}
+	}
+	return nil
+}
+
+func writeText(w *multipart.Writer, contentType, text string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, text); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeAttachment(w *multipart.Writer, a *Attachment) error {
+	header := textproto.MIMEHeader{
+		"Content-Type":              {a.ContentType},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	disposition := "attachment"
+	if a.ContentID != "" {
+		disposition = "inline"
+		header.Set("Content-Id", "<"+a.ContentID+">")
+	}
+	// the filename is quoted, or encoded as in RFC 2231 when it isn't ascii
+	if d := mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}); d != "" {
+		disposition = d
+	}
+	header.Set("Content-Disposition", disposition)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	// base64 lines of 76 characters
+	encoded := base64.StdEncoding.EncodeToString(a.Content)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(part, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = io.WriteString(part, encoded+"\r\n")
+	return err
+}
+
+// writeHeader writes the header sorted by key.
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			// the values can't break the header
+			v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+}
+
+// messageID returns a unique Message-Id in the domain of from.
+func messageID(from string) string {
+	domain := "localhost"
+	if a, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(a.Address, "@"); i >= 0 {
+			domain = a.Address[i+1:]
+		}
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,352 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego"
+)
+
+func testMessage() *Message {
+	m := NewMessage()
+	m.From = "Shop <bot@example.com>"
+	m.To = []string{"Tom <tom@example.com>"}
+	m.Bcc = []string{"audit@example.com"}
+	m.Subject = "Your order é"
+	m.Text = "Thanks"
+	m.HTML = `<p>Thanks <img src="cid:logo"></p>`
+	m.Attach(strings.NewReader("id,total\n1,10\n"), "order.csv", "")
+	a, _ := m.Attach(strings.NewReader("PNG"), "logo.png", "")
+	a.ContentID = "logo"
+	return m
+}
+
+func TestBytes(t *testing.T) {
+	raw, err := testMessage().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Your order é" {
+		t.Error("subject:", subject)
+	}
+	if msg.Header.Get("Bcc") != "" || !strings.HasSuffix(msg.Header.Get("Message-Id"), "@example.com>") {
+		t.Error("headers:", msg.Header)
+	}
+
+	// mixed: alternative (text, related (html, logo)), order.csv
+	var parts []string
+	var walk func(contentType string, body []byte)
+	walk = func(contentType string, body []byte) {
+		mediaType, params, _ := mime.ParseMediaType(contentType)
+		parts = append(parts, mediaType)
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			return
+		}
+		r := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err != nil {
+				return
+			}
+			b, _ := ioutil.ReadAll(p)
+			walk(p.Header.Get("Content-Type"), b)
+		}
+	}
+	body, _ := ioutil.ReadAll(msg.Body)
+	walk(msg.Header.Get("Content-Type"), body)
+	want := "multipart/mixed multipart/alternative text/plain multipart/related text/html image/png text/csv"
+	if got := strings.Join(parts, " "); got != want {
+		t.Errorf("parts %q, want %q", got, want)
+	}
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	for _, name := range []string{"order.csv", `a".pdf`, `back\slash.txt`, "reçu été.pdf"} {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		if err := writeAttachment(w, &Attachment{Filename: name, ContentType: "text/plain", Content: []byte("x")}); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		p, err := multipart.NewReader(&buf, w.Boundary()).NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		disposition, params, err := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+		if err != nil || disposition != "attachment" || params["filename"] != name {
+			t.Errorf("%q: got %q %v %v", name, p.Header.Get("Content-Disposition"), params, err)
+		}
+	}
+}
+
+func TestSendGridSender(t *testing.T) {
+	var got sendGridMessage
+	status := 202
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Error("authorization:", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	s, err := NewSender("sendgrid", `{"apikey":"key","endpoint":"`+ts.URL+`"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if got.From.Email != "bot@example.com" || got.From.Name != "Shop" || got.Personalizations[0].Bcc[0].Email != "audit@example.com" ||
+		len(got.Content) != 2 || len(got.Attachments) != 2 || got.Attachments[1].Disposition != "inline" {
+		t.Errorf("unexpected message %+v", got)
+	}
+
+	status = 400
+	if err := s.Send(testMessage()); !IsPermanent(err) {
+		t.Error("a 400 should be permanent:", err)
+	}
+	status = 503
+	if err := s.Send(testMessage()); err == nil || IsPermanent(err) {
+		t.Error("a 503 should be temporary:", err)
+	}
+}
+
+func TestMailgunSender(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/example.com/messages" {
+			t.Error("path:", r.URL.Path)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "api" || pass != "key" {
+			t.Error("auth:", user, pass)
+		}
+		r.ParseMultipartForm(1 << 20)
+		if r.FormValue("to") != "Tom <tom@example.com>" || r.FormValue("bcc") != "audit@example.com" ||
+			len(r.MultipartForm.File["attachment"]) != 1 || len(r.MultipartForm.File["inline"]) != 1 {
+			t.Errorf("unexpected form %v", r.MultipartForm)
+		}
+	}))
+	defer ts.Close()
+
+	s, _ := NewSender("mailgun", `{"domain":"example.com","apikey":"key","endpoint":"`+ts.URL+`/v3"}`)
+	if err := s.Send(testMessage()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeSMTP serves one smtp session, rejecting the recipient reject@example.com.
+func fakeSMTP(t *testing.T, data chan<- string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost\r\n250 8BITMIME")
+			case strings.HasPrefix(cmd, "RCPT") && strings.Contains(cmd, "REJECT@"):
+				reply("550 no such user")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, _ := r.ReadString('\n')
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				data <- b.String()
+				reply("250 ok")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSMTPSender(t *testing.T) {
+	data := make(chan string, 1)
+	host, port, _ := net.SplitHostPort(fakeSMTP(t, data))
+	s, err := NewSender("smtp", `{"host":"`+host+`","port":`+port+`,"security":"none"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-data; !strings.Contains(body, "From: Shop <bot@example.com>") {
+		t.Error("unexpected data", body)
+	}
+
+	host, port, _ = net.SplitHostPort(fakeSMTP(t, data))
+	s, _ = NewSender("smtp", `{"host":"`+host+`","port":`+port+`,"security":"none"}`)
+	m := testMessage()
+	m.To = append(m.To, "reject@example.com")
+	if err := s.Send(m); !IsPermanent(err) {
+		t.Error("a rejected recipient should be permanent:", err)
+	}
+
+	host, port, _ = net.SplitHostPort(fakeSMTP(t, data))
+	s, _ = NewSender("smtp", `{"host":"`+host+`","port":`+port+`}`)
+	if err := s.Send(testMessage()); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Error("starttls should be required:", err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	var lock sync.Mutex
+	calls := map[string]int{}
+	sender := SenderFunc(func(m *Message) error {
+		lock.Lock()
+		defer lock.Unlock()
+		calls[m.Subject]++
+		switch m.Subject {
+		case "flaky":
+			if calls[m.Subject] < 3 {
+				return errors.New("timeout")
+			}
+		case "down":
+			return errors.New("timeout")
+		case "rejected":
+			return Permanent(errors.New("550 no such user"))
+		}
+		return nil
+	})
+	failed := map[string]error{}
+	onFailure := func(m *Message, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		failed[m.Subject] = err
+	}
+	count := func(subject string) int {
+		lock.Lock()
+		defer lock.Unlock()
+		return calls[subject]
+	}
+	q := NewQueue(sender, 10)
+	q.Backoff = time.Millisecond
+	q.MaxRetries = 3
+	q.OnFailure = onFailure
+	q.Start(2)
+
+	for _, subject := range []string{"ok", "flaky", "down", "rejected"} {
+		m := testMessage()
+		m.Subject = subject
+		if err := q.Send(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Send(NewMessage()); !IsPermanent(err) {
+		t.Error("a message without address should be rejected:", err)
+	}
+	for deadline := time.Now().Add(time.Second); count("flaky") < 3 || count("down") < 4; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the retries should be done", calls)
+		}
+	}
+	q.Close()
+
+	if calls["ok"] != 1 || calls["flaky"] != 3 || calls["down"] != 4 || calls["rejected"] != 1 {
+		t.Error("unexpected calls", calls)
+	}
+	if len(failed) != 2 || failed["down"] == nil || failed["rejected"] == nil {
+		t.Error("unexpected failures", failed)
+	}
+	if err := q.Send(testMessage()); err != ErrQueueClosed {
+		t.Error("send after close:", err)
+	}
+
+	// Close retries the messages waiting for a retry once
+	calls = map[string]int{}
+	failed = map[string]error{}
+	q = NewQueue(sender, 10)
+	q.OnFailure = onFailure
+	q.Start(1)
+	m := testMessage()
+	m.Subject = "down"
+	q.Send(m)
+	for q.Len() != 1 || count("down") != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	q.Close()
+	if calls["down"] != 2 || failed["down"] == nil {
+		t.Error("unexpected calls after close", calls, failed)
+	}
+
+	full := NewQueue(sender, 1)
+	full.Send(testMessage())
+	if err := full.Send(testMessage()); err != ErrQueueFull {
+		t.Error("send to a full queue:", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "beego-mail")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "welcome.tpl"), []byte(
+		`{{define "subject"}}Welcome {{.}}{{end}}{{define "text"}}Hello {{.}}{{end}}<p>Hello {{.}}</p>`), 0666)
+	if err := beego.BuildTemplate(dir); err != nil {
+		t.Fatal(err)
+	}
+	m := NewMessage()
+	if err := m.Render("welcome.tpl", "Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "Welcome Tom" || m.Text != "Hello Tom" || m.HTML != "<p>Hello Tom</p>" {
+		t.Errorf("unexpected message %q %q %q", m.Subject, m.Text, m.HTML)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by Queue.Send when the queue is full.
+	ErrQueueFull = errors.New("mail: queue is full")
+	// ErrQueueClosed is returned by Queue.Send after Close.
+	ErrQueueClosed = errors.New("mail: queue is closed")
+)
+
+// Queue delivers the messages in the background, retrying the temporary
+// failures with an exponential backoff.
+type Queue struct {
+	MaxRetries int           // retries of a message, 5 by default
+	Backoff    time.Duration // wait before the first retry, doubled after every retry, 30s by default
+	MaxBackoff time.Duration // longest wait before a retry, 1h by default
+	// OnFailure is called with the messages dropped, after their last retry
+	// or at once on a permanent error.
+	OnFailure func(m *Message, err error)
+
+	sender  Sender
+	jobs    chan *job
+	quit    chan struct{}
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+
+	lock    sync.Mutex
+	closed  bool
+	retries map[*job]*time.Timer
+}
+
+type job struct {
+	m       *Message
+	attempt int
+}
+
+// NewQueue returns a queue of size messages sent by sender, started by Start.
+func NewQueue(sender Sender, size int) *Queue {
+	return &Queue{
+		MaxRetries: 5,
+		Backoff:    30 * time.Second,
+		MaxBackoff: time.Hour,
+		sender:     sender,
+		jobs:       make(chan *job, size),
+		quit:       make(chan struct{}),
+		retries:    make(map[*job]*time.Timer),
+	}
+}
+
+// Start starts workers goroutines sending the messages.
+func (q *Queue) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+}
+
+// Send queues m, it returns ErrQueueFull rather than waiting when the queue
+// is full. the addresses are checked now, the other errors are given to
+// OnFailure.
+func (q *Queue) Send(m *Message) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	q.pending.Add(1)
+	select {
+	case q.jobs <- &job{m: m}:
+		return nil
+	default:
+		q.pending.Done()
+		return ErrQueueFull
+	}
+}
+
+// Len returns the number of messages waiting to be sent or retried.
+func (q *Queue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.jobs) + len(q.retries)
+}
+
+// Close stops accepting messages and waits for the messages queued to be
+// sent. the messages waiting for a retry are retried at once, for the last
+// time.
+func (q *Queue) Close() {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return
+	}
+	q.closed = true
+	var retries []*job
+	for j, t := range q.retries {
+		if t.Stop() {
+			delete(q.retries, j)
+			retries = append(retries, j)
+		}
+	}
+	q.lock.Unlock()
+	for _, j := range retries {
+		q.jobs <- j
+	}
+	q.pending.Wait()
+	close(q.quit)
+	q.workers.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for {
+		select {
+		case j := <-q.jobs:
+			q.deliver(j)
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+func (q *Queue) deliver(j *job) {
+	err := q.sender.Send(j.m)
+	if err == nil {
+		q.pending.Done()
+		return
+	}
+	q.lock.Lock()
+	if IsPermanent(err) || j.attempt >= q.MaxRetries || q.closed {
+		q.lock.Unlock()
+		if q.OnFailure != nil {
+			q.OnFailure(j.m, err)
+		}
+		q.pending.Done()
+		return
+	}
+	j.attempt++
+	q.retries[j] = time.AfterFunc(q.backoff(j.attempt), func() { q.retry(j) })
+	q.lock.Unlock()
+}
+
+func (q *Queue) retry(j *job) {
+	q.lock.Lock()
+	if _, ok := q.retries[j]; !ok {
+		// Close took it
+		q.lock.Unlock()
+		return
+	}
+	delete(q.retries, j)
+	if q.closed {
+		q.lock.Unlock()
+		q.jobs <- j
+		return
+	}
+	defer q.lock.Unlock()
+	select {
+	case q.jobs <- j:
+	default:
+		// the queue is full of new messages, wait some more
+		q.retries[j] = time.AfterFunc(q.backoff(j.attempt), func() { q.retry(j) })
+	}
+}
+
+// backoff returns the wait before the retry attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.Backoff
+	for i := 1; i < attempt && d < q.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.MaxBackoff {
+		d = q.MaxBackoff
+	}
+	return d
+}
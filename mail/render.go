@@ -0,0 +1,41 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"github.com/aamsur/beego"
+)
+
+// Render renders a template of the views into the html body of m, and its
+// "subject" and "text" blocks, when the template defines them, into the
+// subject and the plain text body.
+//
+//	{{define "subject"}}Welcome {{.Name}}{{end}}
+//	{{define "text"}}Hello {{.Name}}, ...{{end}}
+//	<p>Hello <b>{{.Name}}</b>, ...</p>
+func (m *Message) Render(name string, data interface{}) error {
+	subject, text, html, err := beego.RenderMailTemplate(name, data)
+	if err != nil {
+		return err
+	}
+	m.HTML = html
+	if subject != "" {
+		m.Subject = subject
+	}
+	if text != "" {
+		m.Text = text
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sender sends the messages.
+type Sender interface {
+	Send(m *Message) error
+}
+
+// SenderFunc is a func sending the messages, e.g. in tests.
+type SenderFunc func(m *Message) error
+
+// Send calls f(m).
+func (f SenderFunc) Send(m *Message) error {
+	return f(m)
+}
+
+// Instance returns a sender configured by the json config.
+type Instance func(config string) (Sender, error)
+
+var adapters = make(map[string]Instance)
+
+// Register makes a sender available by the name, like "smtp" or "sendgrid".
+func Register(name string, adapter Instance) {
+	if adapter == nil {
+		panic("mail: Register adapter is nil")
+	}
+	if _, ok := adapters[name]; ok {
+		panic("mail: Register called twice for adapter " + name)
+	}
+	adapters[name] = adapter
+}
+
+// NewSender returns the sender of the adapter name configured by the json
+// config, see SMTPSender, SendGridSender and MailgunSender for their fields.
+func NewSender(adapterName, config string) (Sender, error) {
+	adapter, ok := adapters[adapterName]
+	if !ok {
+		return nil, fmt.Errorf("mail: unknown adapter name %q (forgot to import?)", adapterName)
+	}
+	return adapter(config)
+}
+
+// permanentError is a failure retrying won't fix, like a rejected recipient.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure retrying won't fix, the queue drops the
+// message at once.
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether err is a failure retrying won't fix.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,168 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mail
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// smtp security of the connections
+const (
+	SMTPStartTLS = "starttls" // plain connection upgraded by STARTTLS, required
+	SMTPTLS      = "tls"      // implicit tls, usually on port 465
+	SMTPNone     = "none"     // STARTTLS when the server offers it
+)
+
+// SMTPSender sends the messages to an smtp server.
+type SMTPSender struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Identity string `json:"identity"`
+	// Security is starttls, tls or none, it defaults to tls on port 465 and
+	// to starttls on the others.
+	Security  string        `json:"security"`
+	LocalName string        `json:"localname"` // name sent by HELO, localhost by default
+	Timeout   time.Duration `json:"timeout"`   // dial and send timeout in seconds, 30 by default
+	TLSConfig *tls.Config   `json:"-"`
+	Auth      smtp.Auth     `json:"-"` // PLAIN auth of Username by default
+}
+
+func init() {
+	Register("smtp", func(config string) (Sender, error) {
+		s := &SMTPSender{}
+		if err := json.Unmarshal([]byte(config), s); err != nil {
+			return nil, err
+		}
+		s.Timeout *= time.Second
+		return s, nil
+	})
+}
+
+// Send sends m. the errors of the 5xx replies of the server are permanent.
+func (s *SMTPSender) Send(m *Message) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	to, _ := m.Recipients()
+	from, _ := parseAddress(m.From)
+	raw, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	err = s.send(from, to, raw)
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) && tpErr.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+func (s *SMTPSender) send(from string, to []string, raw []byte) error {
+	port := s.Port
+	if port == 0 {
+		port = 587
+	}
+	security := s.Security
+	if security == "" {
+		security = SMTPStartTLS
+		if port == 465 {
+			security = SMTPTLS
+		}
+	}
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	tlsConfig := s.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: s.Host}
+	}
+
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if security == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.LocalName != "" {
+		if err := c.Hello(s.LocalName); err != nil {
+			return err
+		}
+	}
+	if security != SMTPTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if security == SMTPStartTLS {
+			return errors.New("mail: " + s.Host + " doesn't support STARTTLS")
+		}
+	}
+	auth := s.Auth
+	if auth == nil && s.Username != "" {
+		auth = smtp.PlainAuth(s.Identity, s.Username, s.Password, s.Host)
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("mail: " + s.Host + " doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
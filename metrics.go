@@ -0,0 +1,106 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	beecontext "github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/metrics"
+)
+
+// the http metrics recorded when EnableMetrics is set, labeled by the route
+// pattern so the urls with ids don't make a series each.
+var (
+	httpRequests = metrics.NewCounterVec("beego_http_requests_total",
+		"Requests served.", "method", "route", "status")
+	httpDuration = metrics.NewHistogramVec("beego_http_request_duration_seconds",
+		"Duration of the requests in seconds.", nil, "method", "route", "status")
+	httpResponseSize = metrics.NewHistogramVec("beego_http_response_size_bytes",
+		"Size of the response bodies in bytes.", []float64{100, 1e3, 1e4, 1e5, 1e6, 1e7}, "method", "route", "status")
+	httpInFlight = metrics.NewGaugeVec("beego_http_requests_in_flight",
+		"Requests being served.")
+	filterDuration = metrics.NewHistogramVec("beego_filter_duration_seconds",
+		"Duration of the filters of a position in seconds.", []float64{1e-5, 1e-4, 1e-3, 1e-2, 0.1, 1}, "position")
+
+	// the framework internals, updated every MetricsInterval.
+	sessionsActive = metrics.NewGaugeVec("beego_sessions_active",
+		"Sessions of the session provider.")
+	templatesCached = metrics.NewGaugeVec("beego_templates",
+		"Templates compiled.")
+
+	internalMetricsOnce sync.Once
+)
+
+func init() {
+	metrics.MustRegister(httpRequests, httpDuration, httpResponseSize, httpInFlight, filterDuration,
+		sessionsActive, templatesCached)
+}
+
+// startInternalMetrics starts collecting the runtime and framework metrics
+// every MetricsInterval. the pool stats of the orm databases are registered
+// by the orm.
+func startInternalMetrics() {
+	internalMetricsOnce.Do(func() {
+		metrics.RegisterRuntime()
+		metrics.Collect(collectFrameworkMetrics)
+		interval := time.Duration(MetricsInterval) * time.Second
+		if interval <= 0 {
+			interval = 15 * time.Second
+		}
+		metrics.StartCollector(interval)
+	})
+}
+
+func collectFrameworkMetrics() {
+	if GlobalSessions != nil {
+		sessionsActive.Set(float64(GlobalSessions.GetActiveSession()))
+	}
+	templatesLock.RLock()
+	templatesCached.Set(float64(len(BeeTemplates)))
+	templatesLock.RUnlock()
+}
+
+// timeFilters records the time of the filters of a position since start, in
+// the request event and the filter metrics.
+func timeFilters(ev *RequestEvent, pos int, start time.Time) {
+	d := time.Since(start)
+	ev.addFilters(d)
+	if EnableMetrics {
+		filterDuration.Observe(d.Seconds(), filterPositionNames[pos])
+	}
+}
+
+// recordRequestMetrics records the request of ctx, served by route or by
+// the static files when found without a route.
+func recordRequestMetrics(ctx *beecontext.Context, w *responseWriter, route *controllerInfo, found bool, start time.Time) {
+	httpInFlight.Dec()
+	method := ctx.Request.Method
+	if _, ok := HTTPMETHOD[method]; !ok {
+		method = "other"
+	}
+	pattern := "unmatched"
+	if route != nil {
+		pattern = route.pattern
+	} else if found {
+		pattern = "static"
+	}
+	status := strconv.Itoa(responseStatus(ctx, w))
+	httpRequests.Inc(method, pattern, status)
+	httpDuration.Observe(time.Since(start).Seconds(), method, pattern, status)
+	httpResponseSize.Observe(float64(w.size), method, pattern, status)
+}
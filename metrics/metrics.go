@@ -0,0 +1,442 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text format.
+//
+// beego records its http metrics when EnableMetrics is set, and serves the
+// metrics at MetricsPath. the metrics of the app are registered beside them:
+//
+//	var jobs = metrics.NewCounterVec("app_jobs_total", "Jobs run.", "queue")
+//
+//	func init() {
+//		metrics.MustRegister(jobs)
+//	}
+//
+//	jobs.Inc("mail")
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default buckets of the histograms of durations in
+// seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metric is a metric written by a registry.
+type Metric interface {
+	// Name returns the name of the metric, unique in a registry.
+	Name() string
+	// Write writes the metric in the Prometheus text format.
+	Write(w io.Writer) error
+}
+
+// desc is the name, help and label names of a metric.
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (d *desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, kind)
+}
+
+// sample writes a line of the metric, extra is a label added to the label
+// values, like the le of the buckets.
+func (d *desc) sample(w io.Writer, suffix string, values []string, extra string, v float64) {
+	io.WriteString(w, d.name+suffix)
+	if len(values) > 0 || extra != "" {
+		io.WriteString(w, "{")
+		for i, l := range d.labels {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			io.WriteString(w, l+`="`+escapeLabel(values[i])+`"`)
+		}
+		if extra != "" {
+			if len(values) > 0 {
+				io.WriteString(w, ",")
+			}
+			io.WriteString(w, extra)
+		}
+		io.WriteString(w, "}")
+	}
+	io.WriteString(w, " "+formatFloat(v)+"\n")
+}
+
+// series is the value of a set of label values.
+type series struct {
+	values []string
+	value  float64
+}
+
+// vec keeps the series of a counter or a gauge.
+type vec struct {
+	desc
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+func (v *vec) add(delta float64, values []string) {
+	key := v.key(values)
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	s.value += delta
+}
+
+func (v *vec) set(value float64, values []string) {
+	key := v.key(values)
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	s.value = value
+}
+
+func (v *vec) get(values []string) float64 {
+	key := v.key(values)
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *vec) write(w io.Writer, kind string) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.header(w, kind)
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.series[key]
+		v.sample(w, "", s.values, "", s.value)
+	}
+	return nil
+}
+
+// CounterVec is a counter per set of label values, it only goes up.
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec returns a counter with the label names labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec{desc: desc{name, help, labels}, series: make(map[string]*series)}}
+}
+
+// Inc adds 1 to the counter of the label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.add(1, values)
+}
+
+// Add adds delta, which can't be negative, to the counter of the label values.
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.name + " can't decrease")
+	}
+	c.add(delta, values)
+}
+
+// Value returns the counter of the label values.
+func (c *CounterVec) Value(values ...string) float64 {
+	return c.get(values)
+}
+
+// Write writes the counter.
+func (c *CounterVec) Write(w io.Writer) error {
+	return c.write(w, "counter")
+}
+
+// GaugeVec is a value per set of label values that goes up and down.
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec returns a gauge with the label names labels.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{vec{desc: desc{name, help, labels}, series: make(map[string]*series)}}
+}
+
+// Set sets the gauge of the label values.
+func (g *GaugeVec) Set(value float64, values ...string) {
+	g.set(value, values)
+}
+
+// Add adds delta to the gauge of the label values.
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	g.add(delta, values)
+}
+
+// Inc adds 1 to the gauge of the label values.
+func (g *GaugeVec) Inc(values ...string) {
+	g.add(1, values)
+}
+
+// Dec subtracts 1 from the gauge of the label values.
+func (g *GaugeVec) Dec(values ...string) {
+	g.add(-1, values)
+}
+
+// Value returns the gauge of the label values.
+func (g *GaugeVec) Value(values ...string) float64 {
+	return g.get(values)
+}
+
+// Write writes the gauge.
+func (g *GaugeVec) Write(w io.Writer) error {
+	return g.write(w, "gauge")
+}
+
+// GaugeFunc is a gauge read when the metrics are written.
+type GaugeFunc struct {
+	desc
+	f func() float64
+}
+
+// NewGaugeFunc returns a gauge of the values of f.
+func NewGaugeFunc(name, help string, f func() float64) *GaugeFunc {
+	return &GaugeFunc{desc{name: name, help: help}, f}
+}
+
+// Write writes the gauge.
+func (g *GaugeFunc) Write(w io.Writer) error {
+	g.header(w, "gauge")
+	g.sample(w, "", nil, "", g.f())
+	return nil
+}
+
+// histogram is the buckets of a set of label values.
+type histogram struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec counts the observed values in buckets per set of label values.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	lock    sync.Mutex
+	series  map[string]*histogram
+}
+
+// NewHistogramVec returns a histogram with the upper bounds buckets, DefBuckets
+// when nil, and the label names labels.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{desc: desc{name, help, labels}, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+// Observe adds v to the histogram of the label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.key(values)
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of values observed for the label values.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	key := h.key(values)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// Write writes the histogram.
+func (h *HistogramVec) Write(w io.Writer) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.header(w, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, b := range h.buckets {
+			cumulative += s.counts[i]
+			h.sample(w, "_bucket", s.values, `le="`+formatFloat(b)+`"`, float64(cumulative))
+		}
+		h.sample(w, "_bucket", s.values, `le="+Inf"`, float64(s.count))
+		h.sample(w, "_sum", s.values, "", s.sum)
+		h.sample(w, "_count", s.values, "", float64(s.count))
+	}
+	return nil
+}
+
+// Registry keeps the metrics served together.
+type Registry struct {
+	lock    sync.RWMutex
+	metrics map[string]Metric
+}
+
+// DefaultRegistry is the registry of beego and of the package functions.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]Metric)}
+}
+
+// Register adds m, its name must be new.
+func (r *Registry) Register(m Metric) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.metrics[m.Name()]; ok {
+		return fmt.Errorf("metrics: %s registered twice", m.Name())
+	}
+	r.metrics[m.Name()] = m
+	return nil
+}
+
+// MustRegister adds the metrics, it panics when one is registered twice.
+func (r *Registry) MustRegister(metrics ...Metric) {
+	for _, m := range metrics {
+		if err := r.Register(m); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister removes the metric named name.
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.metrics, name)
+}
+
+// WriteText writes the metrics sorted by name in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.lock.RUnlock()
+	sort.Strings(names)
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		r.lock.RLock()
+		m, ok := r.metrics[name]
+		r.lock.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := m.Write(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape.
+func (r *Registry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(rw)
+}
+
+// Register adds m to the default registry.
+func Register(m Metric) error {
+	return DefaultRegistry.Register(m)
+}
+
+// MustRegister adds the metrics to the default registry.
+func MustRegister(metrics ...Metric) {
+	DefaultRegistry.MustRegister(metrics...)
+}
+
+// Handler returns the handler serving the default registry.
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	requests := NewCounterVec("requests_total", "Requests\nserved.", "method", "route")
+	inflight := NewGaugeVec("in_flight", "Requests running.")
+	latency := NewHistogramVec("duration_seconds", "Request duration.", []float64{1, 0.1}, "route")
+	r.MustRegister(requests, inflight, latency, NewGaugeFunc("up", "Up.", func() float64 { return 1 }))
+	if err := r.Register(NewGaugeVec("up", "")); err == nil {
+		t.Fatal("registered a name twice")
+	}
+
+	requests.Inc("GET", `/say/"hi"`)
+	requests.Add(2, "GET", "/")
+	inflight.Inc()
+	inflight.Inc()
+	inflight.Dec()
+	latency.Observe(0.05, "/")
+	latency.Observe(0.5, "/")
+	latency.Observe(3, "/")
+	if requests.Value("GET", "/") != 2 || inflight.Value() != 1 || latency.Count("/") != 3 {
+		t.Fatal("values")
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP duration_seconds Request duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/",le="0.1"} 1
+duration_seconds_bucket{route="/",le="1"} 2
+duration_seconds_bucket{route="/",le="+Inf"} 3
+duration_seconds_sum{route="/"} 3.55
+duration_seconds_count{route="/"} 3
+# HELP in_flight Requests running.
+# TYPE in_flight gauge
+in_flight 1
+# HELP requests_total Requests\nserved.
+# TYPE requests_total counter
+requests_total{method="GET",route="/"} 2
+requests_total{method="GET",route="/say/\"hi\""} 1
+# HELP up Up.
+# TYPE up gauge
+up 1
+`
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") || w.Body.String() != want {
+		t.Fatal("handler:", w.Header(), w.Body.String())
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic on a wrong label count")
+		}
+	}()
+	NewCounterVec("c", "", "a").Inc()
+}
+
+func TestRuntime(t *testing.T) {
+	RegisterRuntime()
+	RegisterRuntime()
+	runtime.GC()
+	stop := StartCollector(time.Hour)
+	defer stop()
+	if goGoroutines.Value() < 1 || goHeapAlloc.Value() <= 0 || goGCCycles.Value() < 1 || goGCPauses.Count() < 1 {
+		t.Fatal("runtime metrics not collected")
+	}
+	var buf bytes.Buffer
+	DefaultRegistry.WriteText(&buf)
+	if !strings.Contains(buf.String(), `go_info{version="`+runtime.Version()+`"} 1`) {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// the collect functions update the gauges of the values too costly to read
+// on every scrape, like the memory stats stopping the world.
+var (
+	collectLock sync.Mutex
+	collectors  []func()
+)
+
+// Collect adds f to the functions run by the collector.
+func Collect(f func()) {
+	collectLock.Lock()
+	defer collectLock.Unlock()
+	collectors = append(collectors, f)
+}
+
+// CollectNow runs the collect functions.
+func CollectNow() {
+	collectLock.Lock()
+	list := append([]func(){}, collectors...)
+	collectLock.Unlock()
+	for _, f := range list {
+		f()
+	}
+}
+
+// StartCollector runs the collect functions now and every interval, until
+// stop is called.
+func StartCollector(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	CollectNow()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				CollectNow()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// the go runtime metrics, with the names of the Prometheus go client.
+var (
+	goInfo       = NewGaugeVec("go_info", "Version of the go runtime.", "version")
+	goGoroutines = NewGaugeVec("go_goroutines", "Goroutines running.")
+	goHeapAlloc  = NewGaugeVec("go_memstats_heap_alloc_bytes", "Heap bytes allocated and in use.")
+	goHeapInuse  = NewGaugeVec("go_memstats_heap_inuse_bytes", "Heap bytes in in-use spans.")
+	goHeapObjs   = NewGaugeVec("go_memstats_heap_objects", "Objects allocated on the heap.")
+	goSys        = NewGaugeVec("go_memstats_sys_bytes", "Bytes obtained from the system.")
+	goNextGC     = NewGaugeVec("go_memstats_next_gc_bytes", "Heap size of the next garbage collection.")
+	goGCCycles   = NewCounterVec("go_gc_cycles_total", "Garbage collections done.")
+	goGCPauses   = NewHistogramVec("go_gc_pause_seconds", "Stop the world pauses of the garbage collections.",
+		[]float64{1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 1e-2, 5e-2, 1e-1})
+
+	runtimeOnce sync.Once
+	runtimeLock sync.Mutex
+	lastNumGC   uint32
+)
+
+// RegisterRuntime adds the go runtime metrics to the default registry, the
+// collector updates them.
+func RegisterRuntime() {
+	runtimeOnce.Do(func() {
+		goInfo.Set(1, runtime.Version())
+		MustRegister(goInfo, goGoroutines, goHeapAlloc, goHeapInuse, goHeapObjs, goSys, goNextGC, goGCCycles, goGCPauses)
+		Collect(collectRuntime)
+	})
+}
+
+func collectRuntime() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	goGoroutines.Set(float64(runtime.NumGoroutine()))
+	goHeapAlloc.Set(float64(m.HeapAlloc))
+	goHeapInuse.Set(float64(m.HeapInuse))
+	goHeapObjs.Set(float64(m.HeapObjects))
+	goSys.Set(float64(m.Sys))
+	goNextGC.Set(float64(m.NextGC))
+
+	// the pauses of the gcs since the last collection, the last 256 are kept.
+	runtimeLock.Lock()
+	defer runtimeLock.Unlock()
+	n := m.NumGC - lastNumGC
+	if n > uint32(len(m.PauseNs)) {
+		n = uint32(len(m.PauseNs))
+	}
+	for i := uint32(0); i < n; i++ {
+		pause := m.PauseNs[(m.NumGC-i+255)%256]
+		goGCPauses.Observe(float64(pause) / 1e9)
+	}
+	goGCCycles.Add(float64(m.NumGC - lastNumGC))
+	lastNumGC = m.NumGC
+}
@@ -0,0 +1,90 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/metrics"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	EnableMetrics = true
+	defer func() { EnableMetrics = false }()
+
+	handler := NewControllerRegister()
+	handler.InsertFilter("/order/*", BeforeRouter, func(ctx *context.Context) {})
+	handler.Get("/order/:id", func(ctx *context.Context) {
+		if httpInFlight.Value() != 1 {
+			t.Error("request not in flight")
+		}
+		ctx.Output.Body([]byte("order"))
+	})
+	handler.Handler("/metrics", metrics.Handler())
+	for _, path := range []string{"/order/1", "/order/2", "/nothing"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if v := httpRequests.Value("GET", "/order/:id", "200"); v != 2 {
+		t.Fatal("requests of the route:", v)
+	}
+	if v := httpRequests.Value("GET", "unmatched", "404"); v != 1 {
+		t.Fatal("unmatched requests:", v)
+	}
+	if httpDuration.Count("GET", "/order/:id", "200") != 2 || httpInFlight.Value() != 0 {
+		t.Fatal("duration or in flight")
+	}
+	if filterDuration.Count("BeforeRouter") < 2 {
+		t.Fatal("filter durations not recorded")
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	handler.ServeHTTP(w, r)
+	body := w.Body.String()
+	for _, s := range []string{
+		`beego_http_requests_total{method="GET",route="/order/:id",status="200"} 2`,
+		`beego_http_response_size_bytes_bucket{method="GET",route="/order/:id",status="200",le="100"} 2`,
+		"# TYPE beego_http_request_duration_seconds histogram",
+		// the scrape is in flight while it's served.
+		"beego_http_requests_in_flight 1",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("no %s in\n%s", s, body)
+		}
+	}
+}
+
+func TestFrameworkMetrics(t *testing.T) {
+	templatesLock.Lock()
+	saved := BeeTemplates
+	BeeTemplates = map[string]*template.Template{"a.tpl": nil, "b.tpl": nil}
+	templatesLock.Unlock()
+	defer func() {
+		templatesLock.Lock()
+		BeeTemplates = saved
+		templatesLock.Unlock()
+	}()
+	collectFrameworkMetrics()
+	if templatesCached.Value() != 2 {
+		t.Fatal("templates:", templatesCached.Value())
+	}
+}
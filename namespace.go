@@ -17,6 +17,7 @@ package beego
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	beecontext "github.com/aamsur/beego/context"
 )
@@ -27,8 +28,14 @@ type innnerNamespace func(*Namespace)
 
 // Namespace is store all the info
 type Namespace struct {
-	prefix   string
-	handlers *ControllerRegistor
+	prefix      string
+	handlers    *ControllerRegistor
+	middlewares []FilterFunc
+	skips       map[string]bool
+	host        string
+	slash       string // trailing slash policy
+	pathCase    string // path case policy
+	copyBody    int    // body copy of the routes, see WithCopyBody
 }
 
 // get new Namespace
@@ -52,23 +59,57 @@ func NewNamespace(prefix string, params ...innnerNamespace) *Namespace {
 //       }
 //       return false
 //   })
-// Cond as the first filter
+// Cond as the first filter of the namespace, after the global filters
 func (n *Namespace) Cond(cond namespaceCond) *Namespace {
 	fn := func(ctx *beecontext.Context) {
 		if !cond(ctx) {
 			exception("405", ctx)
 		}
 	}
-	if v, ok := n.handlers.filters[BeforeRouter]; ok {
-		mr := new(FilterRouter)
-		mr.tree = NewTree()
-		mr.pattern = "*"
-		mr.filterFunc = fn
-		mr.tree.AddRouter("*", true)
-		n.handlers.filters[BeforeRouter] = append([]*FilterRouter{mr}, v...)
-	} else {
-		n.handlers.InsertFilter("*", BeforeRouter, fn)
+	mr := newFilterRouter("*", fn)
+	h := n.handlers
+	h.filterLock.Lock()
+	defer h.filterLock.Unlock()
+	l := h.filters[BeforeRouter]
+	i := 0
+	for i < len(l) && l[i].priority < mr.priority {
+		i++
 	}
+	h.filters[BeforeRouter] = append(l[:i:i], append([]*FilterRouter{mr}, l[i:]...)...)
+	return n
+}
+
+// bind the routes and the filters of the Namespace to the requests to host,
+// "*" and ":name" labels of host capture the :subdomain and :name params.
+// the routes of a nested Namespace bound to another host keep it.
+// usage:
+// beego.NewNamespace("/",
+//     beego.NSHost("*.tenant.example.com"),
+//     beego.NSRouter("/dashboard", &DashboardController{}),
+// )
+func (n *Namespace) Host(host string) *Namespace {
+	n.host = host
+	return n
+}
+
+// set the handler of the requests under the Namespace matching no route,
+// used instead of the 404 one of Errorhandler. h writes the status code.
+// usage:
+// ns.NotFound(func(rw http.ResponseWriter, r *http.Request) {
+//       rw.Header().Set("Content-Type", "application/json")
+//       rw.WriteHeader(404)
+//       rw.Write([]byte(`{"error":"not found"}`))
+//   })
+func (n *Namespace) NotFound(h http.HandlerFunc) *Namespace {
+	n.handlers.setErrorHandler("404", "", nil, h)
+	return n
+}
+
+// set the handler of the requests under the Namespace matching a route for
+// other methods only, used instead of the 405 one of Errorhandler. the
+// Allow header is set before h, which writes the status code.
+func (n *Namespace) MethodNotAllowed(h http.HandlerFunc) *Namespace {
+	n.handlers.setErrorHandler("405", "", nil, h)
 	return n
 }
 
@@ -95,10 +136,163 @@ func (n *Namespace) Filter(action string, filter ...FilterFunc) *Namespace {
 	return n
 }
 
+// add middlewares running before the handlers of the routes of the Namespace
+// and of its nested Namespaces, after the BeforeExec filters. the middlewares
+// of the outer Namespaces run first, then the ones of a Namespace in the
+// order of Use. a middleware writing the response ends the request.
+// the middlewares apply to the routes once the Namespace is added by
+// AddNamespace or nested.
+// usage:
+// beego.NewNamespace("/v1",
+//     beego.NSUse(auth, audit),
+//     beego.NSRouter("/login", &LoginController{}),
+//     beego.NSSkip("/login"),
+//     beego.NSNamespace("/shop", beego.NSUse(shopAccess), ...),
+// )
+func (n *Namespace) Use(middleware ...FilterFunc) *Namespace {
+	n.middlewares = append(n.middlewares, middleware...)
+	return n
+}
+
+// make the routes of rootpath in the Namespace skip its middlewares,
+// the ones of the outer Namespaces still run.
+func (n *Namespace) Skip(rootpath ...string) *Namespace {
+	if n.skips == nil {
+		n.skips = make(map[string]bool)
+	}
+	for _, p := range rootpath {
+		n.skips[p] = true
+	}
+	return n
+}
+
+// set the policy for the trailing slashes of the request paths differing
+// from the routes of the Namespace, overriding RouterTrailingSlash.
+// policy is PolicyRewrite, PolicyStrict or PolicyRedirect.
+func (n *Namespace) TrailingSlash(policy string) *Namespace {
+	if !validPolicy(policy) {
+		panic("invalid router policy: " + policy)
+	}
+	n.slash = policy
+	return n
+}
+
+// set the policy for the request paths differing from the routes of the
+// Namespace by the case, overriding RouterPathCase.
+// policy is PolicyRewrite, PolicyStrict or PolicyRedirect.
+// usage:
+// beego.NewNamespace("/shop",
+//     beego.NSPathCase(beego.PolicyRedirect),
+//     beego.NSTrailingSlash(beego.PolicyRedirect),
+//     ...
+// )
+func (n *Namespace) PathCase(policy string) *Namespace {
+	if !validPolicy(policy) {
+		panic("invalid router policy: " + policy)
+	}
+	n.pathCase = policy
+	return n
+}
+
+// set whether the request bodies of the routes of the Namespace are copied
+// to Ctx.Input.RequestBody, overriding CopyRequestBody like WithCopyBody.
+func (n *Namespace) CopyBody(enabled bool) *Namespace {
+	n.copyBody = -1
+	if enabled {
+		n.copyBody = 1
+	}
+	return n
+}
+
+// applyMiddlewares adds the middlewares of n to its routes, before the ones
+// of the nested Namespaces.
+func (n *Namespace) applyMiddlewares() {
+	if len(n.middlewares) == 0 {
+		return
+	}
+	n.walkRoutes(func(c *controllerInfo) {
+		if !n.skips[c.pattern] {
+			c.middlewares = append(append([]FilterFunc{}, n.middlewares...), c.middlewares...)
+		}
+	})
+}
+
+// applyPolicies sets the policies of n to its routes having none, the
+// ones of the nested Namespaces being set first.
+func (n *Namespace) applyPolicies() {
+	if n.slash == "" && n.pathCase == "" && n.copyBody == 0 {
+		return
+	}
+	n.walkRoutes(func(c *controllerInfo) {
+		if c.trailingSlash == "" {
+			c.trailingSlash = n.slash
+		}
+		if c.pathCase == "" {
+			c.pathCase = n.pathCase
+		}
+		if c.copyBody == 0 {
+			c.copyBody = n.copyBody
+		}
+	})
+}
+
+// walkRoutes calls f once for every route of n.
+func (n *Namespace) walkRoutes(f func(*controllerInfo)) {
+	seen := make(map[*controllerInfo]bool)
+	walk := func(c *controllerInfo) {
+		if !seen[c] {
+			seen[c] = true
+			f(c)
+		}
+	}
+	table := n.handlers.table()
+	for _, t := range table.routers {
+		walkControllerInfos(t, walk)
+	}
+	for _, h := range table.hosts {
+		for _, t := range h.routers {
+			walkControllerInfos(t, walk)
+		}
+	}
+}
+
+func walkControllerInfos(t *Tree, f func(*controllerInfo)) {
+	for _, v := range t.fixrouters {
+		walkControllerInfos(v, f)
+	}
+	if t.wildcard != nil {
+		walkControllerInfos(t.wildcard, f)
+	}
+	for _, l := range t.leaves {
+		if c, ok := l.runObject.(*controllerInfo); ok {
+			f(c)
+		}
+	}
+}
+
+// run the requests of the routes of the Namespace under a deadline of d,
+// like WithTimeout. the timeouts of nested Namespaces and routes replace it.
+func (n *Namespace) Timeout(d time.Duration) *Namespace {
+	return n.Use(Timeout(d, RequestTimeoutBody))
+}
+
+// limit the request body of the namespace to size bytes,
+// overriding MaxRequestBodySize.
+func (n *Namespace) BodyLimit(size int64) *Namespace {
+	n.handlers.InsertFilter("*", BeforeStatic, BodyLimit(size))
+	return n
+}
+
+// same as beego.WebSocketRouter
+func (n *Namespace) WebSocket(rootpath string, c WebSocketEndpoint, options ...RouteOption) *Namespace {
+	n.handlers.AddWebSocket(rootpath, c, options...)
+	return n
+}
+
 // same as beego.Rourer
 // refer: https://godoc.org/github.com/aamsur/beego#Router
-func (n *Namespace) Router(rootpath string, c ControllerInterface, mappingMethods ...string) *Namespace {
-	n.handlers.Add(rootpath, c, mappingMethods...)
+func (n *Namespace) Router(rootpath string, c ControllerInterface, options ...interface{}) *Namespace {
+	n.handlers.Add(rootpath, c, options...)
 	return n
 }
 
@@ -118,57 +312,57 @@ func (n *Namespace) AutoPrefix(prefix string, c ControllerInterface) *Namespace
 
 // same as beego.Get
 // refer: https://godoc.org/github.com/aamsur/beego#Get
-func (n *Namespace) Get(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Get(rootpath, f)
+func (n *Namespace) Get(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Get(rootpath, f, options...)
 	return n
 }
 
 // same as beego.Post
 // refer: https://godoc.org/github.com/aamsur/beego#Post
-func (n *Namespace) Post(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Post(rootpath, f)
+func (n *Namespace) Post(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Post(rootpath, f, options...)
 	return n
 }
 
 // same as beego.Delete
 // refer: https://godoc.org/github.com/aamsur/beego#Delete
-func (n *Namespace) Delete(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Delete(rootpath, f)
+func (n *Namespace) Delete(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Delete(rootpath, f, options...)
 	return n
 }
 
 // same as beego.Put
 // refer: https://godoc.org/github.com/aamsur/beego#Put
-func (n *Namespace) Put(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Put(rootpath, f)
+func (n *Namespace) Put(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Put(rootpath, f, options...)
 	return n
 }
 
 // same as beego.Head
 // refer: https://godoc.org/github.com/aamsur/beego#Head
-func (n *Namespace) Head(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Head(rootpath, f)
+func (n *Namespace) Head(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Head(rootpath, f, options...)
 	return n
 }
 
 // same as beego.Options
 // refer: https://godoc.org/github.com/aamsur/beego#Options
-func (n *Namespace) Options(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Options(rootpath, f)
+func (n *Namespace) Options(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Options(rootpath, f, options...)
 	return n
 }
 
 // same as beego.Patch
 // refer: https://godoc.org/github.com/aamsur/beego#Patch
-func (n *Namespace) Patch(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Patch(rootpath, f)
+func (n *Namespace) Patch(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Patch(rootpath, f, options...)
 	return n
 }
 
 // same as beego.Any
 // refer: https://godoc.org/github.com/aamsur/beego#Any
-func (n *Namespace) Any(rootpath string, f FilterFunc) *Namespace {
-	n.handlers.Any(rootpath, f)
+func (n *Namespace) Any(rootpath string, f FilterFunc, options ...RouteOption) *Namespace {
+	n.handlers.Any(rootpath, f, options...)
 	return n
 }
 
@@ -205,27 +399,7 @@ func (n *Namespace) Include(cList ...ControllerInterface) *Namespace {
 //)
 func (n *Namespace) Namespace(ns ...*Namespace) *Namespace {
 	for _, ni := range ns {
-		for k, v := range ni.handlers.routers {
-			if t, ok := n.handlers.routers[k]; ok {
-				addPrefix(v, ni.prefix)
-				n.handlers.routers[k].AddTree(ni.prefix, v)
-			} else {
-				t = NewTree()
-				t.AddTree(ni.prefix, v)
-				addPrefix(t, ni.prefix)
-				n.handlers.routers[k] = t
-			}
-		}
-		if ni.handlers.enableFilter {
-			for pos, filterList := range ni.handlers.filters {
-				for _, mr := range filterList {
-					t := NewTree()
-					t.AddTree(ni.prefix, mr.tree)
-					mr.tree = t
-					n.handlers.insertFilterRouter(pos, mr)
-				}
-			}
-		}
+		mergeNamespace(n.handlers, ni)
 	}
 	return n
 }
@@ -234,30 +408,60 @@ func (n *Namespace) Namespace(ns ...*Namespace) *Namespace {
 // support multi Namespace
 func AddNamespace(nl ...*Namespace) {
 	for _, n := range nl {
-		for k, v := range n.handlers.routers {
-			if t, ok := BeeApp.Handlers.routers[k]; ok {
-				addPrefix(v, n.prefix)
-				BeeApp.Handlers.routers[k].AddTree(n.prefix, v)
-			} else {
-				t = NewTree()
-				t.AddTree(n.prefix, v)
-				addPrefix(t, n.prefix)
-				BeeApp.Handlers.routers[k] = t
-			}
+		mergeNamespace(BeeApp.Handlers, n)
+	}
+}
+
+// mergeNamespace adds the routes and the filters of n to p under the prefix
+// of n, the ones of a Namespace bound to a host only matching its requests.
+func mergeNamespace(p *ControllerRegistor, n *Namespace) {
+	n.applyMiddlewares()
+	n.applyPolicies()
+	routers := p.table().routers
+	if n.host != "" {
+		routers = p.hostRouters(n.host).routers
+	}
+	table := n.handlers.table()
+	mergeRouters(routers, n.prefix, table.routers)
+	for _, h := range table.hosts {
+		mergeRouters(p.hostRouters(h.host).routers, n.prefix, h.routers)
+	}
+	for _, e := range table.errors {
+		host := e.host
+		if host == nil && n.host != "" {
+			host = newHostRouters(n.host)
 		}
-		if n.handlers.enableFilter {
-			for pos, filterList := range n.handlers.filters {
-				for _, mr := range filterList {
-					t := NewTree()
-					t.AddTree(n.prefix, mr.tree)
-					mr.tree = t
-					BeeApp.Handlers.insertFilterRouter(pos, mr)
-				}
+		p.setErrorHandler(e.code, n.prefix+e.prefix, host, e.handler)
+	}
+	for pos, filterList := range n.handlers.filters {
+		for _, mr := range filterList {
+			t := NewTree()
+			t.AddTree(n.prefix, mr.tree)
+			mr.tree = t
+			if n.host != "" {
+				bindFilterHost(mr, n.host)
+			}
+			if err := p.insertFilterRouter(pos, mr); err != nil {
+				panic("namespace " + n.prefix + ": " + err.Error())
 			}
 		}
 	}
 }
 
+func mergeRouters(dst map[string]*Tree, prefix string, src map[string]*Tree) {
+	for k, v := range src {
+		if t, ok := dst[k]; ok {
+			addPrefix(v, prefix)
+			t.AddTree(prefix, v)
+		} else {
+			t = NewTree()
+			t.AddTree(prefix, v)
+			addPrefix(t, prefix)
+			dst[k] = t
+		}
+	}
+}
+
 func addPrefix(t *Tree, prefix string) {
 	for _, v := range t.fixrouters {
 		addPrefix(v, prefix)
@@ -282,6 +486,55 @@ func NSCond(cond namespaceCond) innnerNamespace {
 	}
 }
 
+// Namespace Host
+func NSHost(host string) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.Host(host)
+	}
+}
+
+// Namespace not found handler
+func NSNotFound(h http.HandlerFunc) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.NotFound(h)
+	}
+}
+
+// Namespace method not allowed handler
+func NSMethodNotAllowed(h http.HandlerFunc) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.MethodNotAllowed(h)
+	}
+}
+
+// Namespace trailing slash policy
+func NSTrailingSlash(policy string) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.TrailingSlash(policy)
+	}
+}
+
+// Namespace path case policy
+func NSPathCase(policy string) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.PathCase(policy)
+	}
+}
+
+// Namespace request body copy
+func NSCopyBody(enabled bool) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.CopyBody(enabled)
+	}
+}
+
+// Namespace request timeout
+func NSTimeout(d time.Duration) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.Timeout(d)
+	}
+}
+
 // Namespace BeforeRouter filter
 func NSBefore(filiterList ...FilterFunc) innnerNamespace {
 	return func(ns *Namespace) {
@@ -289,6 +542,27 @@ func NSBefore(filiterList ...FilterFunc) innnerNamespace {
 	}
 }
 
+// Namespace middlewares
+func NSUse(middleware ...FilterFunc) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.Use(middleware...)
+	}
+}
+
+// Namespace routes skipping the middlewares
+func NSSkip(rootpath ...string) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.Skip(rootpath...)
+	}
+}
+
+// Namespace request body limit
+func NSBodyLimit(size int64) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.BodyLimit(size)
+	}
+}
+
 // Namespace FinishRouter filter
 func NSAfter(filiterList ...FilterFunc) innnerNamespace {
 	return func(ns *Namespace) {
@@ -304,65 +578,72 @@ func NSInclude(cList ...ControllerInterface) innnerNamespace {
 }
 
 // Namespace Router
-func NSRouter(rootpath string, c ControllerInterface, mappingMethods ...string) innnerNamespace {
+func NSRouter(rootpath string, c ControllerInterface, options ...interface{}) innnerNamespace {
+	return func(ns *Namespace) {
+		ns.Router(rootpath, c, options...)
+	}
+}
+
+// Namespace websocket endpoint
+func NSWebSocket(rootpath string, c WebSocketEndpoint, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Router(rootpath, c, mappingMethods...)
+		ns.WebSocket(rootpath, c, options...)
 	}
 }
 
 // Namespace Get
-func NSGet(rootpath string, f FilterFunc) innnerNamespace {
+func NSGet(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Get(rootpath, f)
+		ns.Get(rootpath, f, options...)
 	}
 }
 
 // Namespace Post
-func NSPost(rootpath string, f FilterFunc) innnerNamespace {
+func NSPost(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Post(rootpath, f)
+		ns.Post(rootpath, f, options...)
 	}
 }
 
 // Namespace Head
-func NSHead(rootpath string, f FilterFunc) innnerNamespace {
+func NSHead(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Head(rootpath, f)
+		ns.Head(rootpath, f, options...)
 	}
 }
 
 // Namespace Put
-func NSPut(rootpath string, f FilterFunc) innnerNamespace {
+func NSPut(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Put(rootpath, f)
+		ns.Put(rootpath, f, options...)
 	}
 }
 
 // Namespace Delete
-func NSDelete(rootpath string, f FilterFunc) innnerNamespace {
+func NSDelete(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Delete(rootpath, f)
+		ns.Delete(rootpath, f, options...)
 	}
 }
 
 // Namespace Any
-func NSAny(rootpath string, f FilterFunc) innnerNamespace {
+func NSAny(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Any(rootpath, f)
+		ns.Any(rootpath, f, options...)
 	}
 }
 
 // Namespace Options
-func NSOptions(rootpath string, f FilterFunc) innnerNamespace {
+func NSOptions(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Options(rootpath, f)
+		ns.Options(rootpath, f, options...)
 	}
 }
 
 // Namespace Patch
-func NSPatch(rootpath string, f FilterFunc) innnerNamespace {
+func NSPatch(rootpath string, f FilterFunc, options ...RouteOption) innnerNamespace {
 	return func(ns *Namespace) {
-		ns.Patch(rootpath, f)
+		ns.Patch(rootpath, f, options...)
 	}
 }
 
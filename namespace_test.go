@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/aamsur/beego/context"
@@ -152,6 +153,42 @@ func TestNamespaceCond(t *testing.T) {
 	}
 }
 
+func TestNamespaceCondOrder(t *testing.T) {
+	handler := NewControllerRegister()
+	var order []string
+	handler.InsertFilter("*", BeforeRouter, func(ctx *context.Context) {
+		order = append(order, "global")
+	})
+	ns := NewNamespace("/cond",
+		NSBefore(func(ctx *context.Context) {
+			order = append(order, "namespace")
+		}),
+		NSCond(func(ctx *context.Context) bool {
+			order = append(order, "cond")
+			return false
+		}),
+		NSGet("/list", func(ctx *context.Context) {}),
+	)
+	mergeNamespace(handler, ns)
+
+	r, _ := http.NewRequest("GET", "/cond/list", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 405 || strings.Join(order, ",") != "global,cond" {
+		t.Errorf("Cond should run after the global filters and first in the namespace, got %d %v", w.Code, order)
+	}
+
+	dup := NewNamespace("/dup", NSGet("/list", func(ctx *context.Context) {}))
+	dup.handlers.InsertNamedFilter("audit", DefaultFilterPriority, "*", BeforeRouter, func(ctx *context.Context) {})
+	handler.InsertNamedFilter("audit", DefaultFilterPriority, "/other", BeforeRouter, func(ctx *context.Context) {})
+	defer func() {
+		if recover() == nil {
+			t.Error("a filter name registered twice should panic")
+		}
+	}()
+	mergeNamespace(handler, dup)
+}
+
 func TestNamespaceInside(t *testing.T) {
 	r, _ := http.NewRequest("GET", "/v3/shop/order/123", nil)
 	w := httptest.NewRecorder()
@@ -169,3 +206,108 @@ func TestNamespaceInside(t *testing.T) {
 		t.Errorf("TestNamespaceInside can't run, get the response is " + w.Body.String())
 	}
 }
+
+func TestNamespaceUse(t *testing.T) {
+	mark := func(name string) FilterFunc {
+		return func(ctx *context.Context) {
+			trace, _ := ctx.Input.GetData("trace").(string)
+			ctx.Input.SetData("trace", trace+name+",")
+		}
+	}
+	handler := func(ctx *context.Context) {
+		trace, _ := ctx.Input.GetData("trace").(string)
+		ctx.Output.Body([]byte(trace + "handler"))
+	}
+	// the nested namespace and the route come before NSUse on purpose
+	ns := NewNamespace("/mw",
+		NSNamespace("/shop",
+			NSGet("/list", handler),
+			NSGet("/open", handler),
+			NSUse(mark("shop")),
+			NSSkip("/open"),
+		),
+		NSGet("/login", handler),
+		NSGet("/denied", handler),
+		NSUse(mark("auth"), mark("audit")),
+		NSUse(func(ctx *context.Context) {
+			if ctx.Input.Url() == "/mw/denied" {
+				ctx.Output.SetStatus(403)
+				ctx.Output.Body([]byte("denied"))
+			}
+		}),
+		NSSkip("/login"),
+	)
+	AddNamespace(ns)
+
+	tests := map[string]string{
+		"/mw/shop/list": "auth,audit,shop,handler",
+		"/mw/shop/open": "auth,audit,handler",
+		"/mw/login":     "handler",
+		"/mw/denied":    "denied",
+	}
+	for url, want := range tests {
+		r, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		BeeApp.Handlers.ServeHTTP(w, r)
+		if w.Body.String() != want {
+			t.Errorf("%s should answer %q, got %q", url, want, w.Body.String())
+		}
+	}
+}
+
+func TestNamespaceErrorHandlers(t *testing.T) {
+	jsonError := func(code int, msg string) http.HandlerFunc {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(code)
+			rw.Write([]byte(`{"error":"` + msg + `"}`))
+		}
+	}
+	handler := NewControllerRegister()
+	handler.Get("/page", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("page"))
+	})
+	mergeNamespace(handler, NewNamespace("/api",
+		NSGet("/users", func(ctx *context.Context) {
+			ctx.Output.Body([]byte("users"))
+		}),
+		NSNotFound(jsonError(404, "api")),
+		NSMethodNotAllowed(jsonError(405, "method")),
+		NSNamespace("/v2",
+			NSNotFound(jsonError(404, "v2")),
+		),
+	))
+
+	for _, c := range []struct {
+		method, url string
+		code        int
+		body        string
+	}{
+		{"GET", "/api/missing", 404, `{"error":"api"}`},
+		{"GET", "/API/missing", 404, ""},
+		{"GET", "/api", 404, `{"error":"api"}`},
+		{"GET", "/api/v2/missing", 404, `{"error":"v2"}`},
+		{"POST", "/api/users", 405, `{"error":"method"}`},
+		{"GET", "/apis", 404, ""},
+		{"GET", "/missing", 404, ""},
+	} {
+		r, _ := http.NewRequest(c.method, c.url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s %s: status %d, want %d", c.method, c.url, w.Code, c.code)
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Errorf("%s %s: body %q, want %q", c.method, c.url, w.Body.String(), c.body)
+		}
+		if c.body == "" && w.Header().Get("Content-Type") == "application/json" {
+			t.Errorf("%s %s should use the global handler", c.method, c.url)
+		}
+	}
+	r, _ := http.NewRequest("POST", "/api/users", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Allow") != "GET, OPTIONS" {
+		t.Errorf("Allow should be set for the handler, got %q", w.Header().Get("Allow"))
+	}
+}
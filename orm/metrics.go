@@ -0,0 +1,70 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"io"
+	"sort"
+
+	"github.com/aamsur/beego/metrics"
+)
+
+// poolMetrics writes the connection pool stats of the registered databases,
+// labeled by alias name.
+type poolMetrics struct{}
+
+func (poolMetrics) Name() string {
+	return "beego_db"
+}
+
+func (poolMetrics) Write(w io.Writer) error {
+	open := metrics.NewGaugeVec("beego_db_open_connections", "Connections open, in use and idle.", "db")
+	inUse := metrics.NewGaugeVec("beego_db_in_use_connections", "Connections in use.", "db")
+	idle := metrics.NewGaugeVec("beego_db_idle_connections", "Connections idle.", "db")
+	maxOpen := metrics.NewGaugeVec("beego_db_max_open_connections", "Limit of the open connections, 0 is no limit.", "db")
+	waits := metrics.NewCounterVec("beego_db_wait_count_total", "Waits for a free connection.", "db")
+	waited := metrics.NewCounterVec("beego_db_wait_duration_seconds_total", "Time waited for a free connection.", "db")
+
+	dataBaseCache.mux.RLock()
+	names := make([]string, 0, len(dataBaseCache.cache))
+	for name := range dataBaseCache.cache {
+		names = append(names, name)
+	}
+	dataBaseCache.mux.RUnlock()
+	sort.Strings(names)
+	for _, name := range names {
+		al, ok := dataBaseCache.get(name)
+		if !ok || al.DB == nil {
+			continue
+		}
+		st := al.DB.Stats()
+		open.Set(float64(st.OpenConnections), name)
+		inUse.Set(float64(st.InUse), name)
+		idle.Set(float64(st.Idle), name)
+		maxOpen.Set(float64(st.MaxOpenConnections), name)
+		waits.Add(float64(st.WaitCount), name)
+		waited.Add(st.WaitDuration.Seconds(), name)
+	}
+	for _, m := range []metrics.Metric{open, inUse, idle, maxOpen, waits, waited} {
+		if err := m.Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	metrics.MustRegister(poolMetrics{})
+}
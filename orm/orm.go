@@ -51,6 +51,7 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -73,21 +74,46 @@ var (
 	ErrTxHasBegan    = errors.New("<Ormer.Begin> transaction already begin")
 	ErrTxDone        = errors.New("<Ormer.Commit/Rollback> transaction not begin")
 	ErrMultiRows     = errors.New("<QuerySeter> return multi rows")
-	ErrNoRows        = errors.New("<QuerySeter> no row found")
 	ErrStmtClosed    = errors.New("<QuerySeter> stmt already closed")
 	ErrArgs          = errors.New("<Ormer> args error may be empty")
 	ErrNotImplement  = errors.New("have not implement")
 )
 
+// ErrNoRows is returned by the queries of one row finding none, beego sends
+// it as 404.
+var ErrNoRows error = &statusError{"<QuerySeter> no row found", 404}
+
+// statusError is an error with the http status it's sent as by beego.
+type statusError struct {
+	msg    string
+	status int
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// HTTPStatus returns the http status of the error.
+func (e *statusError) HTTPStatus() int {
+	return e.status
+}
+
 type Params map[string]interface{}
 type ParamsList []interface{}
 
 type orm struct {
 	alias *alias
+	ctx   context.Context
 	db    dbQuerier
 	isTx  bool
 }
 
+// logged reports whether the queries go through dbQueryLog, to be logged
+// or traced.
+func (o *orm) logged() bool {
+	return Debug || o.ctx != nil
+}
+
 var _ Ormer = new(orm)
 
 // get model info and model reflect value
@@ -419,8 +445,8 @@ func (o *orm) Using(name string) error {
 	}
 	if al, ok := dataBaseCache.get(name); ok {
 		o.alias = al
-		if Debug {
-			o.db = newDbQueryLog(al, al.DB)
+		if o.logged() {
+			o.db = newDbQueryLog(al, o.ctx, al.DB)
 		} else {
 			o.db = al.DB
 		}
@@ -441,7 +467,7 @@ func (o *orm) Begin() error {
 		return err
 	}
 	o.isTx = true
-	if Debug {
+	if o.logged() {
 		o.db.(*dbQueryLog).SetDB(tx)
 	} else {
 		o.db = tx
@@ -501,6 +527,22 @@ func NewOrm() Ormer {
 	return o
 }
 
+// create new orm recording the spans of its queries as children of the span
+// of ctx, like the request span:
+//
+//	o := orm.NewOrmWithContext(c.Context())
+func NewOrmWithContext(ctx context.Context) Ormer {
+	BootStrap() // execute only once
+
+	o := new(orm)
+	o.ctx = ctx
+	err := o.Using("default")
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
 // create a new ormer object with specify *sql.DB for query
 func NewOrmWithDB(driverName, aliasName string, db *sql.DB) (Ormer, error) {
 	var al *alias
@@ -519,8 +561,8 @@ func NewOrmWithDB(driverName, aliasName string, db *sql.DB) (Ormer, error) {
 	o := new(orm)
 	o.alias = al
 
-	if Debug {
-		o.db = newDbQueryLog(o.alias, db)
+	if o.logged() {
+		o.db = newDbQueryLog(o.alias, o.ctx, db)
 	} else {
 		o.db = db
 	}
@@ -15,12 +15,15 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
 	"log"
 	"strings"
 	"time"
+
+	"github.com/aamsur/beego/tracing"
 )
 
 type Log struct {
@@ -55,10 +58,59 @@ func debugLogQueies(alias *alias, operaton, query string, t time.Time, err error
 	DebugLog.Println(con)
 }
 
+// logQuery logs the query when Debug, counts it in the request stats and
+// records its span when ctx is set.
+func logQuery(ctx context.Context, alias *alias, operaton, query string, t time.Time, err error, args ...interface{}) {
+	if Debug {
+		debugLogQueies(alias, operaton, query, t, err, args...)
+	}
+	if ctx != nil {
+		tracing.StatsFromContext(ctx).AddQuery(time.Since(t))
+		traceQuery(ctx, alias, operaton, query, t, err)
+	}
+}
+
+// traceQuery records the span of a query started at t, named by its first
+// sql word.
+func traceQuery(ctx context.Context, alias *alias, operaton, query string, t time.Time, err error) {
+	name := query
+	if i := strings.IndexByte(name, ' '); i > 0 {
+		name = name[:i]
+	}
+	name = strings.ToUpper(name)
+	_, span := tracing.StartAt(ctx, name, tracing.SpanKindClient, t)
+	if span == nil {
+		return
+	}
+	span.SetAttribute("db.system", dbSystem(alias))
+	span.SetAttribute("db.name", alias.Name)
+	span.SetAttribute("db.operation", name)
+	span.SetAttribute("db.statement", query)
+	span.SetAttribute("orm.call", operaton)
+	span.RecordError(err)
+	span.End()
+}
+
+// dbSystem returns the db.system of the driver of alias.
+func dbSystem(alias *alias) string {
+	switch alias.Driver {
+	case DR_MySQL:
+		return "mysql"
+	case DR_Sqlite:
+		return "sqlite"
+	case DR_Oracle:
+		return "oracle"
+	case DR_Postgres:
+		return "postgresql"
+	}
+	return alias.DriverName
+}
+
 // statement query logger struct.
-// if dev mode, use stmtQueryLog, or use stmtQuerier.
+// if dev mode or traced, use stmtQueryLog, or use stmtQuerier.
 type stmtQueryLog struct {
 	alias *alias
+	ctx   context.Context
 	query string
 	stmt  stmtQuerier
 }
@@ -68,43 +120,45 @@ var _ stmtQuerier = new(stmtQueryLog)
 func (d *stmtQueryLog) Close() error {
 	a := time.Now()
 	err := d.stmt.Close()
-	debugLogQueies(d.alias, "st.Close", d.query, a, err)
+	logQuery(d.ctx, d.alias, "st.Close", d.query, a, err)
 	return err
 }
 
 func (d *stmtQueryLog) Exec(args ...interface{}) (sql.Result, error) {
 	a := time.Now()
 	res, err := d.stmt.Exec(args...)
-	debugLogQueies(d.alias, "st.Exec", d.query, a, err, args...)
+	logQuery(d.ctx, d.alias, "st.Exec", d.query, a, err, args...)
 	return res, err
 }
 
 func (d *stmtQueryLog) Query(args ...interface{}) (*sql.Rows, error) {
 	a := time.Now()
 	res, err := d.stmt.Query(args...)
-	debugLogQueies(d.alias, "st.Query", d.query, a, err, args...)
+	logQuery(d.ctx, d.alias, "st.Query", d.query, a, err, args...)
 	return res, err
 }
 
 func (d *stmtQueryLog) QueryRow(args ...interface{}) *sql.Row {
 	a := time.Now()
 	res := d.stmt.QueryRow(args...)
-	debugLogQueies(d.alias, "st.QueryRow", d.query, a, nil, args...)
+	logQuery(d.ctx, d.alias, "st.QueryRow", d.query, a, nil, args...)
 	return res
 }
 
-func newStmtQueryLog(alias *alias, stmt stmtQuerier, query string) stmtQuerier {
+func newStmtQueryLog(alias *alias, ctx context.Context, stmt stmtQuerier, query string) stmtQuerier {
 	d := new(stmtQueryLog)
 	d.stmt = stmt
 	d.alias = alias
+	d.ctx = ctx
 	d.query = query
 	return d
 }
 
 // database query logger struct.
-// if dev mode, use dbQueryLog, or use dbQuerier.
+// if dev mode or traced, use dbQueryLog, or use dbQuerier.
 type dbQueryLog struct {
 	alias *alias
+	ctx   context.Context
 	db    dbQuerier
 	tx    txer
 	txe   txEnder
@@ -117,49 +171,49 @@ var _ txEnder = new(dbQueryLog)
 func (d *dbQueryLog) Prepare(query string) (*sql.Stmt, error) {
 	a := time.Now()
 	stmt, err := d.db.Prepare(query)
-	debugLogQueies(d.alias, "db.Prepare", query, a, err)
+	logQuery(d.ctx, d.alias, "db.Prepare", query, a, err)
 	return stmt, err
 }
 
 func (d *dbQueryLog) Exec(query string, args ...interface{}) (sql.Result, error) {
 	a := time.Now()
 	res, err := d.db.Exec(query, args...)
-	debugLogQueies(d.alias, "db.Exec", query, a, err, args...)
+	logQuery(d.ctx, d.alias, "db.Exec", query, a, err, args...)
 	return res, err
 }
 
 func (d *dbQueryLog) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	a := time.Now()
 	res, err := d.db.Query(query, args...)
-	debugLogQueies(d.alias, "db.Query", query, a, err, args...)
+	logQuery(d.ctx, d.alias, "db.Query", query, a, err, args...)
 	return res, err
 }
 
 func (d *dbQueryLog) QueryRow(query string, args ...interface{}) *sql.Row {
 	a := time.Now()
 	res := d.db.QueryRow(query, args...)
-	debugLogQueies(d.alias, "db.QueryRow", query, a, nil, args...)
+	logQuery(d.ctx, d.alias, "db.QueryRow", query, a, nil, args...)
 	return res
 }
 
 func (d *dbQueryLog) Begin() (*sql.Tx, error) {
 	a := time.Now()
 	tx, err := d.db.(txer).Begin()
-	debugLogQueies(d.alias, "db.Begin", "START TRANSACTION", a, err)
+	logQuery(d.ctx, d.alias, "db.Begin", "START TRANSACTION", a, err)
 	return tx, err
 }
 
 func (d *dbQueryLog) Commit() error {
 	a := time.Now()
 	err := d.db.(txEnder).Commit()
-	debugLogQueies(d.alias, "tx.Commit", "COMMIT", a, err)
+	logQuery(d.ctx, d.alias, "tx.Commit", "COMMIT", a, err)
 	return err
 }
 
 func (d *dbQueryLog) Rollback() error {
 	a := time.Now()
 	err := d.db.(txEnder).Rollback()
-	debugLogQueies(d.alias, "tx.Rollback", "ROLLBACK", a, err)
+	logQuery(d.ctx, d.alias, "tx.Rollback", "ROLLBACK", a, err)
 	return err
 }
 
@@ -167,9 +221,10 @@ func (d *dbQueryLog) SetDB(db dbQuerier) {
 	d.db = db
 }
 
-func newDbQueryLog(alias *alias, db dbQuerier) dbQuerier {
+func newDbQueryLog(alias *alias, ctx context.Context, db dbQuerier) dbQuerier {
 	d := new(dbQueryLog)
 	d.alias = alias
+	d.ctx = ctx
 	d.db = db
 	return d
 }
@@ -78,8 +78,8 @@ func newInsertSet(orm *orm, mi *modelInfo) (Inserter, error) {
 	if err != nil {
 		return nil, err
 	}
-	if Debug {
-		bi.stmt = newStmtQueryLog(orm.alias, st, query)
+	if orm.logged() {
+		bi.stmt = newStmtQueryLog(orm.alias, orm.ctx, st, query)
 	} else {
 		bi.stmt = st
 	}
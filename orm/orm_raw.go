@@ -51,8 +51,8 @@ func newRawPreparer(rs *rawSet) (RawPreparer, error) {
 	if err != nil {
 		return nil, err
 	}
-	if Debug {
-		o.stmt = newStmtQueryLog(rs.orm.alias, st, query)
+	if rs.orm.logged() {
+		o.stmt = newStmtQueryLog(rs.orm.alias, rs.orm.ctx, st, query)
 	} else {
 		o.stmt = st
 	}
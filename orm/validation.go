@@ -0,0 +1,64 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aamsur/beego/validation"
+)
+
+// ValidationLookup returns a validation.Lookup querying with o,
+// for the Unique and Exists validation functions.
+//	validation.DefaultLookup = orm.ValidationLookup(orm.NewOrm())
+// a query outliving its context is not canceled, its result is dropped.
+func ValidationLookup(o Ormer) validation.Lookup {
+	return &validationLookup{o}
+}
+
+type validationLookup struct {
+	o Ormer
+}
+
+func (l *validationLookup) Existing(ctx context.Context, table, column string, values []interface{}) (map[string]bool, error) {
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)", column, table, column, marks)
+
+	type result struct {
+		list ParamsList
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var list ParamsList
+		_, err := l.o.Raw(query, values...).ValuesFlat(&list)
+		done <- result{list, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		found := make(map[string]bool, len(r.list))
+		for _, value := range r.list {
+			found[fmt.Sprint(value)] = true
+		}
+		return found, nil
+	}
+}
@@ -19,17 +19,22 @@ import (
 	"errors"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
 	"go/token"
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/aamsur/beego/utils"
 )
 
-var globalRouterTemplate = `package routers
+var globalRouterTemplate = `// Code generated by beego from the controller comments. DO NOT EDIT.
+
+package routers
 
 import (
 	"github.com/aamsur/beego"
@@ -45,6 +50,7 @@ var (
 	commentFilename    string
 	pkgLastupdate      map[string]int64
 	genInfoList        map[string][]ControllerComments
+	genDocList         map[string]OperationDoc
 )
 
 const COMMENTFL = "commentsRouter_"
@@ -54,13 +60,42 @@ func init() {
 }
 
 func parserPkg(pkgRealpath, pkgpath string) error {
-	rep := strings.NewReplacer("/", "_", ".", "_")
-	commentFilename = COMMENTFL + rep.Replace(pkgpath) + ".go"
+	commentFilename = commentsRouterFile(pkgpath)
 	if !compareFile(pkgRealpath) {
 		Info(pkgRealpath + " has not changed, not reloading")
 		return nil
 	}
+	if err := parserAnnotations(pkgRealpath, pkgpath); err != nil {
+		return err
+	}
+	if err := genRouterCode(path.Join(workPath, "routers"), commentFilename); err != nil {
+		return err
+	}
+	savetoFile(pkgRealpath)
+	return nil
+}
+
+// GenerateRouters parses the comments of the controllers of the package
+// pkgpath, whose sources are in pkgRealpath, and writes their commentsRouter
+// file to dir as Include does in dev mode. it's meant for go:generate, see
+// cmd/beerouter, with RouterScan off in production.
+func GenerateRouters(pkgRealpath, pkgpath, dir string) error {
+	if err := parserAnnotations(pkgRealpath, pkgpath); err != nil {
+		return err
+	}
+	return genRouterCode(dir, commentsRouterFile(pkgpath))
+}
+
+// commentsRouterFile returns the name of the commentsRouter file of pkgpath.
+func commentsRouterFile(pkgpath string) string {
+	rep := strings.NewReplacer("/", "_", ".", "_")
+	return COMMENTFL + rep.Replace(pkgpath) + ".go"
+}
+
+// parserAnnotations fills genInfoList and genDocList from the sources of pkgpath.
+func parserAnnotations(pkgRealpath, pkgpath string) error {
 	genInfoList = make(map[string][]ControllerComments)
+	genDocList = make(map[string]OperationDoc)
 	fileSet := token.NewFileSet()
 	astPkgs, err := parser.ParseDir(fileSet, pkgRealpath, func(info os.FileInfo) bool {
 		name := info.Name()
@@ -82,12 +117,13 @@ func parserPkg(pkgRealpath, pkgpath string) error {
 			}
 		}
 	}
-	genRouterCode()
-	savetoFile(pkgRealpath)
 	return nil
 }
 
 func parserComments(comments *ast.CommentGroup, funcName, controllerName, pkgpath string) error {
+	if doc, ok := parserDocComments(comments); ok {
+		genDocList[pkgpath+":"+controllerName+"."+funcName] = doc
+	}
 	if comments != nil && comments.List != nil {
 		for _, c := range comments.List {
 			t := strings.TrimSpace(strings.TrimLeft(c.Text, "//"))
@@ -125,12 +161,163 @@ func parserComments(comments *ast.CommentGroup, funcName, controllerName, pkgpat
 	return nil
 }
 
-func genRouterCode() {
-	os.Mkdir(path.Join(workPath, "routers"), 0755)
+// docExample decodes the json of an @Example, or returns its text.
+func docExample(text string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return text
+	}
+	return v
+}
+
+// parserDocComments returns the OperationDoc of the comments of a controller method.
+func parserDocComments(comments *ast.CommentGroup) (doc OperationDoc, ok bool) {
+	if comments == nil {
+		return doc, false
+	}
+	for _, c := range comments.List {
+		t := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(t, "@") {
+			continue
+		}
+		annotation := t
+		rest := ""
+		if i := strings.IndexAny(t, " \t"); i > 0 {
+			annotation, rest = t[:i], strings.TrimSpace(t[i+1:])
+		}
+		fields := docFields(rest)
+		switch annotation {
+		case "@Title":
+			doc.OperationID = rest
+		case "@Summary":
+			doc.Summary = rest
+		case "@Description":
+			if doc.Description != "" {
+				doc.Description += "\n"
+			}
+			doc.Description += rest
+		case "@Tags":
+			for _, tag := range strings.Split(rest, ",") {
+				doc.Tags = append(doc.Tags, strings.TrimSpace(tag))
+			}
+		case "@Deprecated":
+			doc.Deprecated = true
+		case "@Param":
+			// @Param name in type required "description"
+			if len(fields) < 3 {
+				continue
+			}
+			p := ParamDoc{Name: fields[0], In: fields[1], Type: fields[2]}
+			if len(fields) > 3 {
+				p.Required, _ = strconv.ParseBool(fields[3])
+			}
+			if len(fields) > 4 {
+				p.Description = strings.Join(fields[4:], " ")
+			}
+			doc.Params = append(doc.Params, p)
+		case "@Success", "@Failure":
+			// @Success 200 {object} models.User "description", or @Failure 404 description
+			if len(fields) == 0 {
+				continue
+			}
+			r := ResponseDoc{Code: fields[0]}
+			fields = fields[1:]
+			if len(fields) > 1 && strings.HasPrefix(fields[0], "{") {
+				r.Type = fields[1]
+				if fields[0] == "{array}" && !strings.HasPrefix(r.Type, "[]") {
+					r.Type = "[]" + r.Type
+				}
+				fields = fields[2:]
+			}
+			r.Description = strings.Join(fields, " ")
+			doc.Responses = append(doc.Responses, r)
+		case "@Security":
+			// @Security oauth read,write
+			if len(fields) == 0 {
+				continue
+			}
+			s := SecurityDoc{Name: fields[0]}
+			if len(fields) > 1 {
+				s.Scopes = strings.Split(fields[1], ",")
+			}
+			doc.Security = append(doc.Security, s)
+		case "@Callback":
+			// @Callback name expression method type "description"
+			if len(fields) < 3 {
+				continue
+			}
+			cb := CallbackDoc{Name: fields[0], Expression: fields[1], Method: fields[2]}
+			if len(fields) > 3 {
+				cb.Type = fields[3]
+			}
+			if len(fields) > 4 {
+				cb.Description = strings.Join(fields[4:], " ")
+			}
+			doc.Callbacks = append(doc.Callbacks, cb)
+		case "@Example":
+			// @Example request {"login":"gopher"}, or @Example 201 {"id":1}
+			i := strings.IndexAny(rest, " \t")
+			if i < 0 {
+				continue
+			}
+			target, example := rest[:i], docExample(strings.TrimSpace(rest[i+1:]))
+			if target == "request" {
+				doc.Example = example
+				break
+			}
+			found := false
+			for j := range doc.Responses {
+				if doc.Responses[j].Code == target {
+					doc.Responses[j].Example = example
+					found = true
+				}
+			}
+			if !found {
+				doc.Responses = append(doc.Responses, ResponseDoc{Code: target, Example: example})
+			}
+		default:
+			continue
+		}
+		ok = true
+	}
+	return doc, ok
+}
+
+// docFields splits s by spaces, a quoted field keeps its spaces.
+func docFields(s string) []string {
+	var fields []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			if end := strings.Index(s[1:], "\""); end >= 0 {
+				fields = append(fields, s[1:end+1])
+				s = s[end+2:]
+				continue
+			}
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		fields = append(fields, s[:end])
+		s = s[end:]
+	}
+	return fields
+}
+
+// genRouterCode writes the routers of genInfoList and the docs of genDocList to the file filename of dir.
+func genRouterCode(dir, filename string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
 	Info("generate router from comments")
 	var globalinfo string
-	for k, cList := range genInfoList {
-		for _, c := range cList {
+	controllers := make([]string, 0, len(genInfoList))
+	for k := range genInfoList {
+		controllers = append(controllers, k)
+	}
+	sort.Strings(controllers)
+	for _, k := range controllers {
+		for _, c := range genInfoList[k] {
 			allmethod := "nil"
 			if len(c.AllowHTTPMethods) > 0 {
 				allmethod = "[]string{"
@@ -144,7 +331,7 @@ func genRouterCode() {
 				params = "[]map[string]string{"
 				for _, p := range c.Params {
 					for k, v := range p {
-						params = params + `map[string]string{` + k + `:"` + v + `"},`
+						params = params + `map[string]string{` + strconv.Quote(k) + `:` + strconv.Quote(v) + `},`
 					}
 				}
 				params = strings.TrimRight(params, ",") + "}"
@@ -159,14 +346,24 @@ func genRouterCode() {
 `
 		}
 	}
-	if globalinfo != "" {
-		f, err := os.Create(path.Join(workPath, "routers", commentFilename))
-		if err != nil {
-			panic(err)
-		}
-		defer f.Close()
-		f.WriteString(strings.Replace(globalRouterTemplate, "{{.globalinfo}}", globalinfo, -1))
+	keys := make([]string, 0, len(genDocList))
+	for k := range genDocList {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		globalinfo = globalinfo + `
+	beego.GlobalControllerDocs["` + k + `"] = ` + fmt.Sprintf("%#v", genDocList[k]) + `
+`
+	}
+	if globalinfo == "" {
+		return nil
+	}
+	src := []byte(strings.Replace(globalRouterTemplate, "{{.globalinfo}}", globalinfo, -1))
+	if formatted, err := format.Source(src); err == nil {
+		src = formatted
 	}
+	return ioutil.WriteFile(path.Join(dir, filename), src, 0644)
 }
 
 func compareFile(pkgRealpath string) bool {
@@ -0,0 +1,328 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apispec validates the requests, and the responses in dev runmode,
+// against the OpenAPI docs of the app, so the code and its docs don't drift.
+// the parameters and the json bodies are checked, form bodies are not.
+// Usage
+//
+//	import (
+//		"github.com/aamsur/beego"
+//		"github.com/aamsur/beego/plugins/apispec"
+//	)
+//
+//	func main() {
+//		// invalid requests get a 400 application/problem+json response,
+//		// the responses not matching their docs are logged
+//		beego.InsertFilter("/api/*", beego.BeforeRouter, apispec.Filter(nil))
+//		beego.Run()
+//	}
+package apispec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/swagger/openapi"
+	"github.com/aamsur/beego/utils"
+	"github.com/aamsur/beego/validation"
+)
+
+// Options represents the spec validation options.
+type Options struct {
+	// Document is the spec validated against, beego.OpenAPI() built at the
+	// first request by default.
+	Document *openapi.Document
+	// ValidateResponses checks the json responses too, it's true in dev
+	// runmode when the options are nil. the response is sent as is.
+	ValidateResponses bool
+	// MaxResponseSize is the largest response checked in bytes, default is 1MB.
+	MaxResponseSize int
+	// OnRequestError writes the response of an invalid request,
+	// a 400 application/problem+json document by default.
+	OnRequestError func(ctx *context.Context, errs []*openapi.ValidationError)
+	// OnResponseError reports the mismatches of a response, logged by default.
+	OnResponseError func(ctx *context.Context, errs []*openapi.ValidationError)
+}
+
+// route is a path of the spec.
+type route struct {
+	re      *regexp.Regexp
+	names   []string
+	item    *openapi.PathItem
+	literal int // the length of its text out of the params
+}
+
+type validator struct {
+	opts   *Options
+	once   sync.Once
+	doc    *openapi.Document
+	routes []*route
+}
+
+// Filter returns the filter validating the requests against the spec, it
+// should run before the router.
+func Filter(opts *Options) beego.FilterFunc {
+	if opts == nil {
+		opts = &Options{ValidateResponses: beego.RunMode == "dev"}
+	}
+	if opts.MaxResponseSize <= 0 {
+		opts.MaxResponseSize = 1 << 20
+	}
+	if opts.OnRequestError == nil {
+		opts.OnRequestError = badRequest
+	}
+	if opts.OnResponseError == nil {
+		opts.OnResponseError = logMismatches
+	}
+	v := &validator{opts: opts}
+	return v.filter
+}
+
+func (v *validator) filter(ctx *context.Context) {
+	v.once.Do(v.compile)
+	item, params := v.match(ctx.Request.URL.Path)
+	if item == nil {
+		return
+	}
+	op := item.Operation(ctx.Input.Method())
+	if op == nil {
+		return
+	}
+	if errs := v.validateRequest(ctx, item, op, params); len(errs) > 0 {
+		v.opts.OnRequestError(ctx, errs)
+		return
+	}
+	if !v.opts.ValidateResponses {
+		return
+	}
+	rec := utils.NewResponseRecorder(ctx.ResponseWriter, v.opts.MaxResponseSize)
+	ctx.ResponseWriter = rec
+	ctx.Defer(func() {
+		if rec.Overflow {
+			return
+		}
+		if errs := v.validateResponse(op, rec); len(errs) > 0 {
+			v.opts.OnResponseError(ctx, errs)
+		}
+	})
+}
+
+var paramRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+// compile makes the routes of the spec, the ones with the most text first.
+func (v *validator) compile() {
+	v.doc = v.opts.Document
+	if v.doc == nil {
+		v.doc = beego.OpenAPI()
+	}
+	for path, item := range v.doc.Paths {
+		r := &route{item: item}
+		expr := "^"
+		last := 0
+		for _, m := range paramRegexp.FindAllStringSubmatchIndex(path, -1) {
+			expr += regexp.QuoteMeta(path[last:m[0]])
+			r.literal += m[0] - last
+			name := path[m[2]:m[3]]
+			switch name {
+			case "splat":
+				expr += "(.*)"
+			case "path":
+				expr += "(.+)"
+			default:
+				expr += "([^/]+)"
+			}
+			r.names = append(r.names, name)
+			last = m[1]
+		}
+		r.literal += len(path) - last
+		r.re = regexp.MustCompile(expr + regexp.QuoteMeta(path[last:]) + "/?$")
+		v.routes = append(v.routes, r)
+	}
+	sort.SliceStable(v.routes, func(i, j int) bool {
+		if v.routes[i].literal != v.routes[j].literal {
+			return v.routes[i].literal > v.routes[j].literal
+		}
+		return v.routes[i].re.String() < v.routes[j].re.String()
+	})
+}
+
+// match returns the path item of path and its path params.
+func (v *validator) match(path string) (*openapi.PathItem, map[string]string) {
+	for _, r := range v.routes {
+		m := r.re.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+		params := make(map[string]string, len(r.names))
+		for i, name := range r.names {
+			params[name] = m[i+1]
+		}
+		return r.item, params
+	}
+	return nil, nil
+}
+
+func (v *validator) validateRequest(ctx *context.Context, item *openapi.PathItem, op *openapi.Operation, params map[string]string) []*openapi.ValidationError {
+	var errs []*openapi.ValidationError
+	// the parameters of the operation override the ones of the path
+	parameters := make(map[string]*openapi.Parameter)
+	for _, p := range append(append([]*openapi.Parameter{}, item.Parameters...), op.Parameters...) {
+		parameters[p.In+":"+p.Name] = p
+	}
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p := parameters[key]
+		var values []string
+		switch p.In {
+		case "path":
+			if value, ok := params[p.Name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = ctx.Request.URL.Query()[p.Name]
+		case "header":
+			values = ctx.Request.Header[http.CanonicalHeaderKey(p.Name)]
+		case "cookie":
+			if c, err := ctx.Request.Cookie(p.Name); err == nil {
+				values = []string{c.Value}
+			}
+		}
+		path := p.In + "." + p.Name
+		if len(values) == 0 {
+			if p.Required {
+				errs = append(errs, &openapi.ValidationError{Path: path, Rule: "required", Message: "is required"})
+			}
+			continue
+		}
+		errs = append(errs, v.doc.ValidateValue(p.Schema, v.doc.ParamValue(p.Schema, values), path)...)
+	}
+
+	if op.RequestBody != nil {
+		errs = append(errs, v.validateBody(ctx, op.RequestBody)...)
+	}
+	return errs
+}
+
+func (v *validator) validateBody(ctx *context.Context, body *openapi.RequestBody) []*openapi.ValidationError {
+	data := ctx.Input.RequestBody
+	if data == nil && ctx.Request.Body != nil {
+		var err error
+		if data, err = ioutil.ReadAll(ctx.Request.Body); err != nil {
+			return []*openapi.ValidationError{{Path: "body", Rule: "body", Message: err.Error()}}
+		}
+		// the body is read again by the controller
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return []*openapi.ValidationError{{Path: "body", Rule: "required", Message: "is required"}}
+		}
+		return nil
+	}
+	contentType, _, _ := mime.ParseMediaType(ctx.Input.Header("Content-Type"))
+	media, ok := body.Content[contentType]
+	if !ok {
+		accepted := make([]string, 0, len(body.Content))
+		for t := range body.Content {
+			accepted = append(accepted, t)
+		}
+		sort.Strings(accepted)
+		return []*openapi.ValidationError{{Path: "body", Rule: "contentType",
+			Message: fmt.Sprintf("content type %q is not %s", contentType, strings.Join(accepted, " or "))}}
+	}
+	if !isJSON(contentType) || media.Schema == nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []*openapi.ValidationError{{Path: "body", Rule: "json", Message: "is not valid json: " + err.Error()}}
+	}
+	return v.doc.ValidateValue(media.Schema, value, "body")
+}
+
+func (v *validator) validateResponse(op *openapi.Operation, rec *utils.ResponseRecorder) []*openapi.ValidationError {
+	status := rec.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	code := strconv.Itoa(status)
+	resp, ok := op.Responses[code]
+	if !ok {
+		resp, ok = op.Responses[code[:1]+"XX"]
+	}
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return []*openapi.ValidationError{{Path: "response", Rule: "status", Message: "status " + code + " is not documented"}}
+	}
+	contentType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	media, ok := resp.Content[contentType]
+	if !ok || media.Schema == nil || !isJSON(contentType) || rec.Body.Len() == 0 {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &value); err != nil {
+		return []*openapi.ValidationError{{Path: "response", Rule: "json", Message: "is not valid json: " + err.Error()}}
+	}
+	return v.doc.ValidateValue(media.Schema, value, "response")
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// badRequest writes the 400 application/problem+json response of errs.
+func badRequest(ctx *context.Context, errs []*openapi.ValidationError) {
+	problem := &validation.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusBadRequest),
+		Status:   http.StatusBadRequest,
+		Detail:   "the request does not match the api spec",
+		Instance: ctx.Request.URL.Path,
+	}
+	for _, e := range errs {
+		problem.Errors = append(problem.Errors, validation.FieldError{Field: e.Path, Rule: e.Rule, Message: e.Message})
+	}
+	body, err := json.Marshal(problem)
+	if err != nil {
+		http.Error(ctx.ResponseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx.Output.Header("Content-Type", "application/problem+json")
+	ctx.ResponseWriter.WriteHeader(problem.Status)
+	ctx.ResponseWriter.Write(body)
+}
+
+func logMismatches(ctx *context.Context, errs []*openapi.ValidationError) {
+	for _, e := range errs {
+		beego.Warn("apispec:", ctx.Input.Method(), ctx.Request.URL.Path, "does not match the api spec,", e)
+	}
+}
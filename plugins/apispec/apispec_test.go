@@ -0,0 +1,130 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apispec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/swagger/openapi"
+)
+
+func testDocument() *openapi.Document {
+	one, ten := float64(1), 10
+	doc := openapi.NewDocument("api", "1.0")
+	doc.Components.Schemas["models.User"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"id":    {Type: "integer", Format: "int64"},
+			"login": {Type: "string", MaxLength: &ten},
+		},
+		Required: []string{"login"},
+	}
+	user := &openapi.Schema{Ref: "#/components/schemas/models.User"}
+	doc.Paths["/users/{id}"] = &openapi.PathItem{
+		Get: &openapi.Operation{
+			Parameters: []*openapi.Parameter{
+				{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Minimum: &one}},
+				{Name: "fields", In: "query", Schema: &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string", Enum: []interface{}{"id", "login"}}}},
+			},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "OK", Content: map[string]*openapi.MediaType{"application/json": {Schema: user}}},
+			},
+		},
+	}
+	doc.Paths["/users"] = &openapi.PathItem{
+		Post: &openapi.Operation{
+			RequestBody: &openapi.RequestBody{
+				Required: true,
+				Content:  map[string]*openapi.MediaType{"application/json": {Schema: user}},
+			},
+			Responses: map[string]*openapi.Response{"201": {Description: "Created"}},
+		},
+	}
+	return doc
+}
+
+func TestFilter(t *testing.T) {
+	var mismatches []string
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, Filter(&Options{
+		Document:          testDocument(),
+		ValidateResponses: true,
+		OnResponseError: func(ctx *context.Context, errs []*openapi.ValidationError) {
+			for _, e := range errs {
+				mismatches = append(mismatches, e.Error())
+			}
+		},
+	}))
+	handler.Get("/users/:id", func(ctx *context.Context) {
+		ctx.Output.Header("Content-Type", "application/json")
+		if login := ctx.Input.Query("login"); login != "" {
+			ctx.WriteString(`{"id": 1, "login": "` + login + `"}`)
+		} else {
+			ctx.WriteString(`{"id": 1}`)
+		}
+	})
+	handler.Post("/users", func(ctx *context.Context) {
+		ctx.ResponseWriter.WriteHeader(201)
+		ctx.WriteString(string(ctx.Input.CopyBody()))
+	})
+
+	serve := func(method, url, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, url, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, c := range []struct {
+		method, url, body string
+		code              int
+		errs              []string
+	}{
+		{"GET", "/users/2?fields=id,login&login=gopher", "", 200, nil},
+		{"GET", "/users/0?fields=name", "", 400, []string{`"field":"path.id","rule":"minimum"`, `"field":"query.fields[0]","rule":"enum"`}},
+		{"GET", "/users/abc", "", 400, []string{`"field":"path.id","rule":"type"`}},
+		{"POST", "/users", `{"login": "gopher"}`, 201, nil},
+		{"POST", "/users", "", 400, []string{`"field":"body","rule":"required"`}},
+		{"POST", "/users", `{"id": 1.5, "login": "a long login name"}`, 400, []string{`"field":"body.id","rule":"type"`, `"field":"body.login","rule":"maxLength"`}},
+		{"POST", "/users", `{"id": 1`, 400, []string{`"field":"body","rule":"json"`}},
+	} {
+		w := serve(c.method, c.url, c.body)
+		if w.Code != c.code {
+			t.Errorf("%s %s: the status should be %d, got %d %s", c.method, c.url, c.code, w.Code, w.Body.String())
+		}
+		for _, e := range c.errs {
+			if !strings.Contains(w.Body.String(), e) {
+				t.Errorf("%s %s: the problem should contain %s, got %s", c.method, c.url, e, w.Body.String())
+			}
+		}
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("the valid responses should not be reported, got %v", mismatches)
+	}
+
+	// the login is missing from the response
+	serve("GET", "/users/2", "")
+	if len(mismatches) != 1 || mismatches[0] != "response.login: is required" {
+		t.Errorf("the invalid response should be reported, got %v", mismatches)
+	}
+}
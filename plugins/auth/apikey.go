@@ -0,0 +1,85 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"net/http"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+)
+
+// KeyLookup returns the identity owning the api key and whether the key is valid.
+// the identity ends up in the request logs, it must not be the key itself.
+type KeyLookup func(key string) (identity string, ok bool)
+
+// APIKeyOptions represents the api key filter options.
+type APIKeyOptions struct {
+	// Header carrying the key. default is "X-API-Key".
+	Header string
+	// Query parameter carrying the key, checked when the header is empty. disabled if empty.
+	Query string
+	// Lookup validates the key, for example against a database.
+	Lookup KeyLookup
+	// Keys maps the names of the clients to their valid keys, used when Lookup is nil.
+	// the name is the identity of the request, the key itself is never exposed.
+	Keys map[string]string
+}
+
+// APIKey returns a filter requiring a valid api key in a header or query parameter.
+// usage:
+//	beego.InsertFilter("/api/*", beego.BeforeRouter, auth.APIKey(&auth.APIKeyOptions{
+//		Query:  "api_key",
+//		Lookup: func(key string) (string, bool) { return models.AppByKey(key) },
+//	}))
+func APIKey(opts *APIKeyOptions) beego.FilterFunc {
+	header := opts.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	lookup := opts.Lookup
+	if lookup == nil {
+		keys := opts.Keys
+		lookup = func(key string) (string, bool) {
+			identity, valid := "", false
+			for name, k := range keys {
+				// check all keys so the time doesn't tell which one matched
+				if SecureCompare(key, k) {
+					identity, valid = name, true
+				}
+			}
+			return identity, valid
+		}
+	}
+	return func(ctx *context.Context) {
+		key := ctx.Input.Header(header)
+		if key == "" && opts.Query != "" {
+			key = ctx.Input.Query(opts.Query)
+		}
+		if key == "" {
+			ctx.Output.SetStatus(http.StatusUnauthorized)
+			ctx.Output.Body([]byte("401 Unauthorized: missing api key\n"))
+			return
+		}
+		identity, ok := lookup(key)
+		if !ok {
+			ctx.Output.SetStatus(http.StatusUnauthorized)
+			ctx.Output.Body([]byte("401 Unauthorized: invalid api key\n"))
+			return
+		}
+		ctx.Input.SetData(UserKey, identity)
+		ctx.SetUser(identity)
+	}
+}
@@ -33,9 +33,21 @@
 //	}
 //	authPlugin := auth.NewBasicAuthenticator(SecretAuth, "Authorization Required")
 //	beego.InsertFilter("*", beego.BeforeRouter,authPlugin)
+//
+// Protect a namespace only:
+//
+//	ns := beego.NewNamespace("/internal",
+//		beego.NSBefore(auth.Basic("username", "secretpassword")),
+//		beego.NSRouter("/stats", &StatsController{}),
+//	)
+//	beego.AddNamespace(ns)
+//
+// the authenticated user name is stored in ctx.Input.Data under auth.UserKey
+// and is also returned by ctx.User().
 package auth
 
 import (
+	"crypto/subtle"
 	"encoding/base64"
 	"net/http"
 	"strings"
@@ -46,22 +58,51 @@ import (
 
 var defaultRealm = "Authorization Required"
 
+// UserKey is the ctx.Input.Data key of the authenticated user name or api key identity.
+const UserKey = "auth_user"
+
+// Basic returns a filter accepting one username and password pair.
+// credentials are compared in constant time, the password is compared
+// even when the username is wrong.
 func Basic(username string, password string) beego.FilterFunc {
 	secrets := func(user, pass string) bool {
-		return user == username && pass == password
+		return secureEq(user, username)&secureEq(pass, password) == 1
 	}
 	return NewBasicAuthenticator(secrets, defaultRealm)
 }
 
+// NewBasicAuthenticator returns a filter checking credentials with secrets.
 func NewBasicAuthenticator(secrets SecretProvider, Realm string) beego.FilterFunc {
+	if Realm == "" {
+		Realm = defaultRealm
+	}
 	return func(ctx *context.Context) {
 		a := &BasicAuth{Secrets: secrets, Realm: Realm}
 		if username := a.CheckAuth(ctx.Request); username == "" {
 			a.RequireAuth(ctx.ResponseWriter, ctx.Request)
+		} else {
+			ctx.Input.SetData(UserKey, username)
+			ctx.SetUser(username)
 		}
 	}
 }
 
+// SecureCompare compares two strings in constant time.
+func SecureCompare(given, actual string) bool {
+	return secureEq(given, actual) == 1
+}
+
+// secureEq returns 1 when given equals actual and 0 otherwise, in constant time.
+var secureEq = func(given, actual string) int {
+	// ConstantTimeCompare returns early on different lengths,
+	// so compare against itself to keep the time independent of the length.
+	if subtle.ConstantTimeEq(int32(len(given)), int32(len(actual))) == 1 {
+		return subtle.ConstantTimeCompare([]byte(given), []byte(actual))
+	}
+	subtle.ConstantTimeCompare([]byte(actual), []byte(actual))
+	return 0
+}
+
 type SecretProvider func(user, pass string) bool
 
 type BasicAuth struct {
@@ -97,7 +138,7 @@ func (a *BasicAuth) CheckAuth(r *http.Request) string {
 //http.Handler for BasicAuth which initiates the authentication process
 //(or requires reauthentication).
 func (a *BasicAuth) RequireAuth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("WWW-Authenticate", `Basic realm="`+a.Realm+`"`)
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+strings.Replace(a.Realm, `"`, `'`, -1)+`"`)
 	w.WriteHeader(401)
 	w.Write([]byte("401 Unauthorized\n"))
 }
@@ -0,0 +1,92 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+)
+
+func newHandler(filter beego.FilterFunc) *beego.ControllerRegistor {
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, filter)
+	handler.Get("/foo", func(ctx *context.Context) {
+		ctx.WriteString("hello " + ctx.Input.GetData(UserKey).(string))
+	})
+	return handler
+}
+
+func TestBasic(t *testing.T) {
+	handler := newHandler(Basic("astaxie", "beego"))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("request without credentials should be challenged, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.SetBasicAuth("astaxie", "beego")
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "hello astaxie" {
+		t.Errorf("valid credentials should pass, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBasicComparesBoth(t *testing.T) {
+	defer func(eq func(string, string) int) { secureEq = eq }(secureEq)
+	var compared []string
+	eq := secureEq
+	secureEq = func(given, actual string) int {
+		compared = append(compared, actual)
+		return eq(given, actual)
+	}
+	handler := newHandler(Basic("astaxie", "beego"))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	r.SetBasicAuth("nobody", "beego")
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong username should be challenged, got %d", w.Code)
+	}
+	if len(compared) != 2 || compared[0] != "astaxie" || compared[1] != "beego" {
+		t.Errorf("the password should be compared even for a wrong username, compared %v", compared)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	handler := newHandler(APIKey(&APIKeyOptions{Query: "api_key", Keys: map[string]string{"app1": "k1", "app2": "k2"}}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo?api_key=k2", nil)
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "hello app2" {
+		t.Errorf("valid query key should pass, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/foo", nil)
+	r.Header.Set("X-API-Key", "bad")
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("invalid key should be rejected, got %d", w.Code)
+	}
+}
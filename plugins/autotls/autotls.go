@@ -0,0 +1,150 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package autotls serves https with certificates obtained and renewed
+// automatically from Let's Encrypt or another ACME server.
+//
+// depend on golang.org/x/crypto/acme/autocert, v0.48.0 or later, the last
+// release supporting go 1.24
+//
+// go get golang.org/x/crypto@v0.48.0
+//
+// Usage
+//	import(
+//		"github.com/aamsur/beego"
+//		_ "github.com/aamsur/beego/plugins/autotls"
+//	)
+//
+// and in app.conf
+//	AutoTLSDomains = example.com;www.example.com
+//	AutoTLSEmail = ops@example.com
+//	HttpPort = 80
+//	HttpsPort = 443
+//
+// the certificates are validated with TLS-ALPN-01 on the https port and HTTP-01
+// on the http port, which redirects all other requests to https.
+// they are stored in the AutoTLSCacheDir directory, default "autocert", or in a
+// cache module adapter shared by all instances:
+//	AutoTLSCacheAdapter = redis
+//	AutoTLSCacheConfig = {"conn":"127.0.0.1:6379"}
+package autotls
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/cache"
+)
+
+// CacheTimeout is the lifetime in seconds of the entries put in a cache module adapter.
+// certificates are renewed long before it's over.
+var CacheTimeout int64 = 86400 * 365
+
+func init() {
+	beego.AddAPPStartHook(setup)
+}
+
+// setup enables autotls when AutoTLSDomains is set in app.conf.
+func setup() error {
+	domains := beego.AppConfig.Strings("AutoTLSDomains")
+	if len(domains) == 0 || domains[0] == "" {
+		return nil
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Email:      beego.AppConfig.String("AutoTLSEmail"),
+	}
+	if url := beego.AppConfig.String("AutoTLSDirectoryURL"); url != "" {
+		m.Client = &acme.Client{DirectoryURL: url}
+	}
+	if adapter := beego.AppConfig.String("AutoTLSCacheAdapter"); adapter != "" {
+		c, err := cache.NewCache(adapter, beego.AppConfig.String("AutoTLSCacheConfig"))
+		if err != nil {
+			return err
+		}
+		m.Cache = Cache(c)
+	} else {
+		m.Cache = autocert.DirCache(beego.AppConfig.DefaultString("AutoTLSCacheDir", "autocert"))
+	}
+	Use(beego.BeeApp, m)
+	return nil
+}
+
+// Use serves app over https with the certificates of m.
+// the http listener answers the HTTP-01 challenges and redirects to https.
+func Use(app *beego.App, m *autocert.Manager) {
+	beego.EnableHttpTLS = true
+	beego.HttpCertFile = ""
+	beego.HttpKeyFile = ""
+	app.Server.TLSConfig = m.TLSConfig()
+	app.HttpHandler = m.HTTPHandler(RedirectHandler(beego.HttpsPort))
+}
+
+// RedirectHandler redirects GET and HEAD requests to https on port,
+// other methods get 400 as they can't be redirected safely.
+func RedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "Use HTTPS", http.StatusBadRequest)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 0 && port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// Cache stores the certificates in a cache module adapter, e.g. redis,
+// so they are shared by all instances of the app.
+func Cache(c cache.Cache) autocert.Cache {
+	return &adapterCache{c}
+}
+
+type adapterCache struct {
+	c cache.Cache
+}
+
+func (a *adapterCache) Get(ctx context.Context, key string) ([]byte, error) {
+	switch v := a.c.Get(cacheKey(key)).(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, autocert.ErrCacheMiss
+}
+
+func (a *adapterCache) Put(ctx context.Context, key string, data []byte) error {
+	return a.c.Put(cacheKey(key), string(data), CacheTimeout)
+}
+
+func (a *adapterCache) Delete(ctx context.Context, key string) error {
+	return a.c.Delete(cacheKey(key))
+}
+
+func cacheKey(key string) string {
+	return "autotls:" + key
+}
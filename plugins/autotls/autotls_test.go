@@ -0,0 +1,69 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autotls
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/aamsur/beego/cache"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	cases := []struct {
+		method, host, url string
+		port              int
+		code              int
+		location          string
+	}{
+		{"GET", "example.com", "/a?b=1", 443, http.StatusMovedPermanently, "https://example.com/a?b=1"},
+		{"GET", "example.com:8080", "/", 10443, http.StatusMovedPermanently, "https://example.com:10443/"},
+		{"POST", "example.com", "/", 443, http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest(c.method, "http://"+c.host+c.url, nil)
+		w := httptest.NewRecorder()
+		RedirectHandler(c.port).ServeHTTP(w, r)
+		if w.Code != c.code || w.HeaderMap.Get("Location") != c.location {
+			t.Errorf("%s %s: got %d %q", c.method, c.host+c.url, w.Code, w.HeaderMap.Get("Location"))
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	bm, err := cache.NewCache("memory", `{"interval":60}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Cache(bm)
+	ctx := context.Background()
+	if _, err := c.Get(ctx, "example.com"); err != autocert.ErrCacheMiss {
+		t.Errorf("missing key should be ErrCacheMiss, got %v", err)
+	}
+	if err := c.Put(ctx, "example.com", []byte("cert")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := c.Get(ctx, "example.com"); err != nil || string(b) != "cert" {
+		t.Errorf("got %q %v", b, err)
+	}
+	c.Delete(ctx, "example.com")
+	if _, err := c.Get(ctx, "example.com"); err != autocert.ErrCacheMiss {
+		t.Error("deleted key should be gone")
+	}
+}
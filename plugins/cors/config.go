@@ -0,0 +1,70 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cors
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aamsur/beego"
+)
+
+// NewOptionsFromConfig reads the CORS options from app.conf.
+// list values are separated by ";" like other beego settings.
+// CORSAllowOrigins = * allows all origins, but without credentials.
+//
+//	EnableCORS = true
+//	CORSAllowOrigins = https://*.foo.com;https://bar.com
+//	CORSAllowMethods = GET;POST;PUT
+//	CORSAllowHeaders = Origin;Content-Type;Authorization
+//	CORSExposeHeaders = Content-Length
+//	CORSAllowCredentials = true
+//	CORSMaxAge = 600
+func NewOptionsFromConfig() *Options {
+	opts := &Options{
+		AllowOrigins:     configStrings("CORSAllowOrigins"),
+		AllowMethods:     configStrings("CORSAllowMethods"),
+		AllowHeaders:     configStrings("CORSAllowHeaders"),
+		ExposeHeaders:    configStrings("CORSExposeHeaders"),
+		AllowCredentials: beego.AppConfig.DefaultBool("CORSAllowCredentials", false),
+		MaxAge:           time.Duration(beego.AppConfig.DefaultInt64("CORSMaxAge", 0)) * time.Second,
+	}
+	if len(opts.AllowMethods) == 0 {
+		opts.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	}
+	return opts
+}
+
+func configStrings(key string) []string {
+	var result []string
+	for _, v := range beego.AppConfig.Strings(key) {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// when EnableCORS is set in app.conf, the filter is inserted for all urls on beego.Run.
+// usage:
+//	import _ "github.com/aamsur/beego/plugins/cors"
+func init() {
+	beego.AddAPPStartHook(func() error {
+		if beego.AppConfig.DefaultBool("EnableCORS", false) {
+			beego.InsertFilter("*", beego.BeforeRouter, Allow(NewOptionsFromConfig()))
+		}
+		return nil
+	})
+}
@@ -33,6 +33,8 @@
 //		}))
 //		beego.Run()
 //	}
+//
+// The filter can also be set up from app.conf, see NewOptionsFromConfig.
 package cors
 
 import (
@@ -61,13 +63,12 @@ const (
 
 var (
 	defaultAllowHeaders = []string{"Origin", "Accept", "Content-Type", "Authorization"}
-	// Regex patterns are generated from AllowOrigins. These are used and generated internally.
-	allowOriginPatterns = []string{}
 )
 
 // Options represents Access Control options.
 type Options struct {
-	// If set, all origins are allowed.
+	// If set, all origins are allowed with "*". Browsers don't send credentials
+	// to "*", credentialed requests need the origins listed in AllowOrigins.
 	AllowAllOrigins bool
 	// A list of allowed origins. Wild cards and FQDNs are supported.
 	AllowOrigins []string
@@ -81,6 +82,9 @@ type Options struct {
 	ExposeHeaders []string
 	// Max age of the CORS headers.
 	MaxAge time.Duration
+
+	// Regex patterns are generated from AllowOrigins. These are used and generated internally.
+	allowOriginPatterns []*regexp.Regexp
 }
 
 // Header converts options into CORS headers.
@@ -93,11 +97,7 @@ func (o *Options) Header(origin string) (headers map[string]string) {
 	}
 
 	// add allow origin
-	if o.AllowAllOrigins {
-		headers[headerAllowOrigin] = "*"
-	} else {
-		headers[headerAllowOrigin] = origin
-	}
+	headers[headerAllowOrigin] = o.allowOrigin(origin)
 
 	// add allow credentials
 	headers[headerAllowCredentials] = strconv.FormatBool(o.AllowCredentials)
@@ -152,11 +152,7 @@ func (o *Options) PreflightHeader(origin, rMethod, rHeaders string) (headers map
 
 	headers[headerAllowCredentials] = strconv.FormatBool(o.AllowCredentials)
 	// add allow origin
-	if o.AllowAllOrigins {
-		headers[headerAllowOrigin] = "*"
-	} else {
-		headers[headerAllowOrigin] = origin
-	}
+	headers[headerAllowOrigin] = o.allowOrigin(origin)
 
 	// add allowed headers
 	if len(allowed) > 0 {
@@ -174,30 +170,50 @@ func (o *Options) PreflightHeader(origin, rMethod, rHeaders string) (headers map
 	return
 }
 
+// allowOrigin returns the value of the Access-Control-Allow-Origin header.
+// the origin is never echoed for AllowAllOrigins: with AllowCredentials that
+// would let any site make credentialed reads.
+func (o *Options) allowOrigin(origin string) string {
+	if o.AllowAllOrigins {
+		return "*"
+	}
+	return origin
+}
+
 // IsOriginAllowed looks up if the origin matches one of the patterns
 // generated from Options.AllowOrigins patterns.
 func (o *Options) IsOriginAllowed(origin string) (allowed bool) {
-	for _, pattern := range allowOriginPatterns {
-		allowed, _ = regexp.MatchString(pattern, origin)
-		if allowed {
-			return
+	for _, pattern := range o.allowOriginPatterns {
+		if pattern.MatchString(origin) {
+			return true
 		}
 	}
 	return
 }
 
 // Allow enables CORS for requests those match the provided options.
-func Allow(opts *Options) beego.FilterFunc {
+// the options are copied, they can be shared by several filters.
+func Allow(options *Options) beego.FilterFunc {
+	o := *options
+	opts := &o
 	// Allow default headers if nothing is specified.
 	if len(opts.AllowHeaders) == 0 {
 		opts.AllowHeaders = defaultAllowHeaders
 	}
 
+	opts.allowOriginPatterns = nil
 	for _, origin := range opts.AllowOrigins {
+		if origin == "*" {
+			opts.AllowAllOrigins = true
+			continue
+		}
 		pattern := regexp.QuoteMeta(origin)
 		pattern = strings.Replace(pattern, "\\*", ".*", -1)
 		pattern = strings.Replace(pattern, "\\?", ".", -1)
-		allowOriginPatterns = append(allowOriginPatterns, "^"+pattern+"$")
+		opts.allowOriginPatterns = append(opts.allowOriginPatterns, regexp.MustCompile("^"+pattern+"$"))
+	}
+	if opts.AllowAllOrigins && opts.AllowCredentials {
+		beego.Warn(`cors: all origins are allowed with "*", browsers won't send credentials. list the origins to allow credentials`)
 	}
 
 	return func(ctx *context.Context) {
@@ -210,6 +226,11 @@ func Allow(opts *Options) beego.FilterFunc {
 			headers map[string]string
 		)
 
+		// the response differs per origin, caches must not share it
+		if !opts.AllowAllOrigins {
+			ctx.ResponseWriter.Header().Add("Vary", headerOrigin)
+		}
+
 		if ctx.Input.Method() == "OPTIONS" &&
 			(requestedMethod != "" || requestedHeaders != "") {
 			headers = opts.PreflightHeader(origin, requestedMethod, requestedHeaders)
@@ -217,7 +238,7 @@ func Allow(opts *Options) beego.FilterFunc {
 				ctx.Output.Header(key, value)
 			}
 			ctx.Output.SetStatus(http.StatusOK)
-			ctx.WriteString("")
+			ctx.Output.Body([]byte{})
 			return
 		}
 		headers = opts.Header(origin)
@@ -250,3 +250,83 @@ func Benchmark_WithCORS(b *testing.B) {
 		handler.ServeHTTP(recorder, r)
 	}
 }
+
+func Test_AllowAllOriginsWithCredentials(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, Allow(&Options{
+		AllowAllOrigins:  true,
+		AllowCredentials: true,
+	}))
+	handler.Any("/foo", func(ctx *context.Context) {
+		ctx.Output.SetStatus(500)
+	})
+	origin := "https://bar.foo.com"
+	r, _ := http.NewRequest("PUT", "/foo", nil)
+	r.Header.Add("Origin", origin)
+	handler.ServeHTTP(recorder, r)
+
+	// echoing the origin would let any site make credentialed reads
+	if headerValue := recorder.HeaderMap.Get(headerAllowOrigin); headerValue != "*" {
+		t.Errorf("Allow-Origin header should be *, found %v", headerValue)
+	}
+}
+
+func Test_SharedOptions(t *testing.T) {
+	opts := &Options{AllowOrigins: []string{"*"}}
+	all := Allow(opts)
+	opts.AllowOrigins = []string{"https://*.foo.com"}
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("/all", beego.BeforeRouter, all)
+	handler.InsertFilter("/foo", beego.BeforeRouter, Allow(opts))
+	handler.Any("/*", func(ctx *context.Context) {})
+
+	if opts.AllowAllOrigins || len(opts.AllowHeaders) != 0 {
+		t.Errorf("Allow should not change the options of the caller")
+	}
+	for path, want := range map[string]string{"/all": "*", "/foo": ""} {
+		recorder := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		r.Header.Add("Origin", "https://bar.com")
+		handler.ServeHTTP(recorder, r)
+		if got := recorder.HeaderMap.Get(headerAllowOrigin); got != want {
+			t.Errorf("%s: Allow-Origin header should be %q, found %q", path, want, got)
+		}
+	}
+}
+
+func Test_OptionsFromConfig(t *testing.T) {
+	beego.AppConfig.Set("CORSAllowOrigins", "https://*.foo.com; https://bar.com")
+	beego.AppConfig.Set("CORSAllowMethods", "GET;PUT")
+	beego.AppConfig.Set("CORSAllowCredentials", "true")
+	beego.AppConfig.Set("CORSMaxAge", "600")
+	opts := NewOptionsFromConfig()
+
+	recorder := NewRecorder()
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, Allow(opts))
+	handler.Any("/foo", func(ctx *context.Context) {
+		ctx.Output.SetStatus(500)
+	})
+	r, _ := http.NewRequest("OPTIONS", "/foo", nil)
+	r.Header.Add(headerOrigin, "https://bar.com")
+	r.Header.Add(headerRequestMethod, "PUT")
+	handler.ServeHTTP(recorder, r)
+
+	headers := recorder.Header()
+	if v := headers.Get(headerAllowOrigin); v != "https://bar.com" {
+		t.Errorf("Allow-Origin is expected to be https://bar.com, found %v", v)
+	}
+	if v := headers.Get(headerAllowMethods); v != "GET,PUT" {
+		t.Errorf("Allow-Methods is expected to be GET,PUT, found %v", v)
+	}
+	if v := headers.Get(headerAllowCredentials); v != "true" {
+		t.Errorf("Allow-Credentials is expected to be true, found %v", v)
+	}
+	if v := headers.Get(headerMaxAge); v != "600" {
+		t.Errorf("Max-Age is expected to be 600, found %v", v)
+	}
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Status code is expected to be 200, found %d", recorder.Code)
+	}
+}
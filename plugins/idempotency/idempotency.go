@@ -0,0 +1,264 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package idempotency provides a filter replaying the stored response of
+// requests retried with the same Idempotency-Key header.
+// Usage
+//	import(
+//		"github.com/aamsur/beego"
+//		"github.com/aamsur/beego/cache"
+//		"github.com/aamsur/beego/plugins/idempotency"
+//	)
+//
+//	func main(){
+//		bm, _ := cache.NewCache("memory", `{"interval":60}`)
+//		beego.InsertFilter("/payments/*", beego.BeforeRouter, idempotency.New(&idempotency.Options{
+//			Cache: bm,
+//			TTL:   24 * time.Hour,
+//		}))
+//		beego.Run()
+//	}
+//
+// the keys are scoped by client, see Options.Scope.
+// retries with the same key and payload get the first response with the header
+// "Idempotent-Replayed: true". a retry while the first request is running gets 409,
+// the same key with another payload gets 422. 5xx responses and the ones over
+// MaxSize are not stored, so the client can retry them.
+// the files of multipart requests are part of the payload, keys are refused with
+// 400 on the routes streaming their uploads.
+// the cache has no atomic insert, two requests arriving at the very same time
+// may both run.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/cache"
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/utils"
+)
+
+// Options represents the idempotency filter options.
+type Options struct {
+	// Cache stores the responses, it's required.
+	Cache cache.Cache
+	// TTL is how long a response is replayed. default is 24 hours.
+	TTL time.Duration
+	// Header carrying the key. default is "Idempotency-Key".
+	Header string
+	// Methods using the filter. default is POST and PATCH.
+	Methods []string
+	// Scope returns a string separating the keys of different clients, for example the user id,
+	// so a client can't replay the response of another one. default is DefaultScope.
+	Scope func(ctx *context.Context) string
+	// MaxSize is the largest body stored in bytes. default is 1MB.
+	MaxSize int
+}
+
+// entry is the stored state of a key.
+type entry struct {
+	InProgress  bool        `json:"inProgress,omitempty"`
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// New returns the idempotency filter.
+func New(opts *Options) beego.FilterFunc {
+	if opts.Cache == nil {
+		panic("idempotency: Options.Cache is required")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.Header == "" {
+		opts.Header = "Idempotency-Key"
+	}
+	if len(opts.Methods) == 0 {
+		opts.Methods = []string{"POST", "PATCH"}
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 1 << 20
+	}
+	if opts.Scope == nil {
+		opts.Scope = DefaultScope
+	}
+	ttl := int64(opts.TTL / time.Second)
+
+	return func(ctx *context.Context) {
+		key := ctx.Input.Header(opts.Header)
+		if key == "" || !opts.handles(ctx.Input.Method()) {
+			return
+		}
+		if len(key) > 255 {
+			fail(ctx, http.StatusBadRequest, "idempotency key is too long")
+			return
+		}
+		cacheKey := "idempotency:" + ctx.Input.Method() + ":" + ctx.Input.Url() + ":" + key + ":" + opts.Scope(ctx)
+		fingerprint, ok := fingerprint(ctx)
+		if !ok {
+			fail(ctx, http.StatusBadRequest, "idempotency keys are not supported on streamed uploads")
+			return
+		}
+
+		if e, ok := load(opts.Cache, cacheKey); ok {
+			switch {
+			case e.Fingerprint != fingerprint:
+				fail(ctx, http.StatusUnprocessableEntity, "idempotency key was used with another request")
+			case e.InProgress:
+				fail(ctx, http.StatusConflict, "a request with this idempotency key is in progress")
+			default:
+				replay(ctx, e)
+			}
+			return
+		}
+
+		store(opts.Cache, cacheKey, &entry{InProgress: true, Fingerprint: fingerprint}, ttl)
+		rec := utils.NewResponseRecorder(ctx.ResponseWriter, opts.MaxSize)
+		ctx.ResponseWriter = rec
+		ctx.Defer(func() {
+			status := rec.Status
+			if status == 0 {
+				status = ctx.Output.Status
+			}
+			if status == 0 || status >= 500 || rec.Overflow {
+				opts.Cache.Delete(cacheKey)
+				return
+			}
+			header := make(http.Header)
+			for k, v := range rec.Header() {
+				if k != "Set-Cookie" {
+					header[k] = v
+				}
+			}
+			store(opts.Cache, cacheKey, &entry{
+				Fingerprint: fingerprint,
+				Status:      status,
+				Header:      header,
+				Body:        rec.Body.Bytes(),
+			}, ttl)
+		})
+	}
+}
+
+// DefaultScope separates the keys by the credentials of the request, its
+// Authorization header, else its session cookie, else its client ip.
+// they're hashed, the cache doesn't hold them.
+func DefaultScope(ctx *context.Context) string {
+	id := ctx.Input.Header("Authorization")
+	if id == "" && beego.SessionName != "" {
+		id = ctx.Input.Cookie(beego.SessionName)
+	}
+	if id == "" {
+		if ip := context.ClientIP(ctx.Request); ip != nil {
+			id = ip.String()
+		}
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func (opts *Options) handles(method string) bool {
+	for _, m := range opts.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprint identifies the payload, so a key can't be reused for another request.
+// it's false for the uploads streamed by the handler, they can't be read before.
+func fingerprint(ctx *context.Context) (string, bool) {
+	h := sha256.New()
+	switch {
+	case len(ctx.Input.RequestBody) > 0:
+		h.Write(ctx.Input.RequestBody)
+	case ctx.Input.IsUpload():
+		form := ctx.Request.MultipartForm
+		if form == nil {
+			return "", false
+		}
+		h.Write([]byte(ctx.Request.PostForm.Encode()))
+		names := make([]string, 0, len(form.File))
+		for name := range form.File {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, fh := range form.File[name] {
+				h.Write([]byte("\x00" + name + "\x00" + fh.Filename + "\x00"))
+				if f, err := fh.Open(); err == nil {
+					io.Copy(h, f)
+					f.Close()
+				}
+			}
+		}
+	case len(ctx.Request.PostForm) > 0:
+		h.Write([]byte(ctx.Request.PostForm.Encode()))
+	case ctx.Request.Body != nil:
+		// read up to the limit of the request, the router answers 413 over it
+		h.Write(ctx.Input.CopyBody())
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func load(c cache.Cache, key string) (*entry, bool) {
+	var b []byte
+	switch v := c.Get(key).(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return nil, false
+	}
+	e := new(entry)
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, false
+	}
+	return e, true
+}
+
+func store(c cache.Cache, key string, e *entry, ttl int64) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := c.Put(key, string(b), ttl); err != nil {
+		beego.Error("idempotency: store response:", err)
+	}
+}
+
+func replay(ctx *context.Context, e *entry) {
+	for k, v := range e.Header {
+		ctx.ResponseWriter.Header()[k] = v
+	}
+	ctx.Output.Header("Idempotent-Replayed", "true")
+	ctx.ResponseWriter.WriteHeader(e.Status)
+	ctx.ResponseWriter.Write(e.Body)
+}
+
+func fail(ctx *context.Context, status int, msg string) {
+	ctx.Output.SetStatus(status)
+	ctx.Output.Json(map[string]string{"error": msg}, false, false)
+}
@@ -0,0 +1,208 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idempotency
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/cache"
+	"github.com/aamsur/beego/context"
+)
+
+func TestReplay(t *testing.T) {
+	bm, err := cache.NewCache("memory", `{"interval":60}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	charges := 0
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, New(&Options{Cache: bm}))
+	handler.Post("/charge", func(ctx *context.Context) {
+		charges++
+		ctx.Output.Header("X-Charge", strconv.Itoa(charges))
+		ctx.Output.SetStatus(http.StatusCreated)
+		ctx.Output.Body([]byte("charge " + strconv.Itoa(charges)))
+	})
+	handler.Post("/fail", func(ctx *context.Context) {
+		charges++
+		ctx.Output.SetStatus(http.StatusInternalServerError)
+		ctx.Output.Body([]byte("down"))
+	})
+
+	post := func(path, key, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post("/charge", "k1", `{"amount":10}`)
+	if w.Code != http.StatusCreated || w.Body.String() != "charge 1" {
+		t.Fatalf("first request: %d %q", w.Code, w.Body.String())
+	}
+	w = post("/charge", "k1", `{"amount":10}`)
+	if w.Code != http.StatusCreated || w.Body.String() != "charge 1" || w.HeaderMap.Get("X-Charge") != "1" {
+		t.Errorf("retry should replay the first response, got %d %q", w.Code, w.Body.String())
+	}
+	if w.HeaderMap.Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response should be marked")
+	}
+	if charges != 1 {
+		t.Errorf("handler should run once, ran %d times", charges)
+	}
+	if w = post("/charge", "k1", `{"amount":99}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused with another payload should be 422, got %d", w.Code)
+	}
+	if w = post("/charge", "", `{"amount":10}`); w.Body.String() != "charge 2" {
+		t.Errorf("request without key should run, got %q", w.Body.String())
+	}
+
+	post("/fail", "k2", "{}")
+	post("/fail", "k2", "{}")
+	if charges != 4 {
+		t.Errorf("5xx responses should not be stored, handler ran %d times", charges)
+	}
+}
+
+func TestScope(t *testing.T) {
+	bm, _ := cache.NewCache("memory", `{"interval":60}`)
+	charges := 0
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, New(&Options{Cache: bm}))
+	handler.Post("/charge", func(ctx *context.Context) {
+		charges++
+		ctx.WriteString("charge " + strconv.Itoa(charges) + " of " + ctx.Input.Header("Authorization"))
+	})
+	post := func(auth string) string {
+		r, _ := http.NewRequest("POST", "/charge", strings.NewReader(`{"amount":10}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", auth)
+		r.Header.Set("Idempotency-Key", "shared")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	if body := post("Bearer alice"); body != "charge 1 of Bearer alice" {
+		t.Fatalf("first request: %q", body)
+	}
+	if body := post("Bearer mallory"); body != "charge 2 of Bearer mallory" {
+		t.Errorf("another client reusing the key should not get the stored response, got %q", body)
+	}
+	if body := post("Bearer alice"); body != "charge 1 of Bearer alice" {
+		t.Errorf("the client should get its stored response, got %q", body)
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	bm, _ := cache.NewCache("memory", `{"interval":60}`)
+	filter := New(&Options{Cache: bm})
+	var inner *httptest.ResponseRecorder
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, filter)
+	handler.Post("/slow", func(ctx *context.Context) {
+		// a retry arriving while the first request still runs
+		r, _ := http.NewRequest("POST", "/slow", strings.NewReader("a=1"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Idempotency-Key", "k")
+		inner = httptest.NewRecorder()
+		handler.ServeHTTP(inner, r)
+		ctx.WriteString("done")
+	})
+	r, _ := http.NewRequest("POST", "/slow", strings.NewReader("a=1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Idempotency-Key", "k")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if inner.Code != http.StatusConflict {
+		t.Errorf("retry during the first request should be 409, got %d", inner.Code)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	defer func(size int64) { beego.MaxRequestBodySize = size }(beego.MaxRequestBodySize)
+	beego.MaxRequestBodySize = 1000
+
+	bm, _ := cache.NewCache("memory", `{"interval":60}`)
+	runs := 0
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, New(&Options{Cache: bm, MaxSize: 10}))
+	handler.Post("/echo", func(ctx *context.Context) {
+		runs++
+		ctx.Output.Body(ctx.Input.CopyBody())
+	})
+	handler.Post("/export", func(ctx *context.Context) {
+		runs++
+		ctx.Output.Body([]byte(strings.Repeat("x", 20)))
+	})
+	handler.Post("/upload", func(ctx *context.Context) {
+		runs++
+		ctx.WriteString("uploaded")
+	})
+	handler.Post("/stream", func(ctx *context.Context) {
+		ctx.WriteString("streamed")
+	}, beego.WithStreamedUpload())
+
+	post := func(path, key, contentType string, body []byte) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	upload := func(content string) (string, []byte) {
+		var b bytes.Buffer
+		mw := multipart.NewWriter(&b)
+		mw.WriteField("title", "report")
+		f, _ := mw.CreateFormFile("file", "a.txt")
+		f.Write([]byte(content))
+		mw.Close()
+		return mw.FormDataContentType(), b.Bytes()
+	}
+
+	if w := post("/echo", "k1", "application/json", []byte(strings.Repeat("a", 1500))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("a body over the request limit should get 413, got %d %d bytes", w.Code, w.Body.Len())
+	}
+	if w := post("/echo", "k2", "application/json", []byte(`{"a":1}`)); w.Body.String() != `{"a":1}` {
+		t.Errorf("the handler should get the whole body, got %q", w.Body.String())
+	}
+
+	post("/export", "k3", "application/json", nil)
+	post("/export", "k3", "application/json", nil)
+	if runs != 3 {
+		t.Errorf("a response over MaxSize should not be stored, the handlers ran %d times", runs)
+	}
+
+	contentType, body := upload("first")
+	post("/upload", "k4", contentType, body)
+	contentType, body = upload("other")
+	if w := post("/upload", "k4", contentType, body); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("a key reused with another file should be 422, got %d", w.Code)
+	}
+	if w := post("/stream", "k5", contentType, body); w.Code != http.StatusBadRequest {
+		t.Errorf("a key on a streamed upload should be 400, got %d", w.Code)
+	}
+}
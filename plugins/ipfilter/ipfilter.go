@@ -0,0 +1,100 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ipfilter provides a filter allowing or blocking requests by client ip.
+// Usage
+//	import(
+//		"github.com/aamsur/beego"
+//		"github.com/aamsur/beego/plugins/ipfilter"
+//	)
+//
+//	func main(){
+//		// only the office network and the load balancer health checks reach the admin area,
+//		// the clients behind the TrustedProxies of app.conf are resolved by X-Forwarded-For
+//		beego.InsertFilter("/admin/*", beego.BeforeRouter, ipfilter.New(&ipfilter.Options{
+//			Allow: []string{"10.0.0.0/8", "192.168.1.7"},
+//		}))
+//
+//		// or for a namespace
+//		ns := beego.NewNamespace("/internal",
+//			beego.NSBefore(ipfilter.New(&ipfilter.Options{Allow: []string{"127.0.0.1", "::1"}})),
+//		)
+//		beego.Run()
+//	}
+package ipfilter
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+)
+
+// Options represents the ip filter options.
+// addresses are single ips or CIDR ranges, ipv4 and ipv6.
+type Options struct {
+	// if set, only matching clients are allowed.
+	Allow []string
+	// matching clients are blocked, even if they are allowed.
+	Deny []string
+	// response status for blocked clients, default is 403.
+	Status int
+}
+
+// New returns a filter answering requests of clients not allowed by opts.
+// it panics if an address can't be parsed.
+func New(opts *Options) beego.FilterFunc {
+	allow := mustParse(opts.Allow)
+	deny := mustParse(opts.Deny)
+	status := opts.Status
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+	return func(ctx *context.Context) {
+		ip := context.ClientIP(ctx.Request)
+		if ip == nil || contains(deny, ip) || (len(allow) > 0 && !contains(allow, ip)) {
+			beego.Info("ipfilter: blocked", ip, ctx.Input.Url())
+			ctx.Output.SetStatus(status)
+			ctx.Output.Body([]byte(http.StatusText(status)))
+		}
+	}
+}
+
+// Allow returns a filter allowing only the given addresses.
+func Allow(addrs ...string) beego.FilterFunc {
+	return New(&Options{Allow: addrs})
+}
+
+// Deny returns a filter blocking the given addresses.
+func Deny(addrs ...string) beego.FilterFunc {
+	return New(&Options{Deny: addrs})
+}
+
+func mustParse(addrs []string) []*net.IPNet {
+	nets, err := context.ParseNetworks(addrs)
+	if err != nil {
+		panic("ipfilter: " + err.Error())
+	}
+	return nets
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
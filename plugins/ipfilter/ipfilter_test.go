@@ -0,0 +1,68 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+)
+
+func serve(opts *Options, remoteAddr, forwardedFor string) int {
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, New(opts))
+	handler.Get("/admin", func(ctx *context.Context) {
+		ctx.WriteString("ok")
+	})
+	r, _ := http.NewRequest("GET", "/admin", nil)
+	r.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestAllowDeny(t *testing.T) {
+	opts := &Options{Allow: []string{"10.0.0.0/8", "::1"}, Deny: []string{"10.0.0.66"}}
+	if code := serve(opts, "10.1.2.3:5000", ""); code != http.StatusOK {
+		t.Errorf("ip in allowed range should pass, got %d", code)
+	}
+	if code := serve(opts, "[::1]:5000", ""); code != http.StatusOK {
+		t.Errorf("allowed ipv6 should pass, got %d", code)
+	}
+	if code := serve(opts, "10.0.0.66:5000", ""); code != http.StatusForbidden {
+		t.Errorf("denied ip should be blocked, got %d", code)
+	}
+	if code := serve(opts, "8.8.8.8:5000", ""); code != http.StatusForbidden {
+		t.Errorf("ip outside the allowed ranges should be blocked, got %d", code)
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	context.SetTrustedProxies([]string{"10.0.0.1"})
+	defer context.SetTrustedProxies(nil)
+	opts := &Options{Allow: []string{"192.168.0.0/16"}}
+	if code := serve(opts, "10.0.0.1:80", "8.8.8.8, 192.168.1.5"); code != http.StatusOK {
+		t.Errorf("client behind trusted proxy should be resolved, got %d", code)
+	}
+	if code := serve(opts, "8.8.8.8:80", "192.168.1.5"); code != http.StatusForbidden {
+		t.Errorf("X-Forwarded-For from untrusted client must be ignored, got %d", code)
+	}
+}
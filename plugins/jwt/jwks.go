@@ -0,0 +1,247 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minimal interval between two fetches triggered by an unknown "kid".
+const jwksRefreshInterval = time.Minute
+
+// the interval before fetching again after a failure, doubled at each
+// failure up to jwksRefreshInterval.
+const jwksRetryInterval = time.Second
+
+// JWKS fetches and caches the keys of a JSON Web Key Set url.
+type JWKS struct {
+	URL    string
+	TTL    time.Duration
+	Client *http.Client
+
+	lock     sync.Mutex
+	keys     map[string]interface{} // replaced by the fetches, never changed
+	fetched  time.Time
+	err      error // of the last fetch
+	failures int
+	retry    time.Time
+	fetching chan struct{} // closed at the end of the running fetch
+}
+
+// NewJWKS returns a key set loaded lazily from url and cached for ttl.
+func NewJWKS(url string, ttl time.Duration) *JWKS {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &JWKS{
+		URL:    url,
+		TTL:    ttl,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// KeyFunc returns the key matching the "kid" of the token header.
+// the key set is fetched again when it is expired or the kid is unknown.
+func (j *JWKS) KeyFunc(header map[string]interface{}) (interface{}, error) {
+	kid, _ := header["kid"].(string)
+	keys, err := j.keySet(false)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := lookupKey(keys, kid); ok {
+		return key, nil
+	}
+	if keys, err = j.keySet(true); err != nil {
+		return nil, err
+	}
+	if key, ok := lookupKey(keys, kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no key found for kid %q", kid)
+}
+
+// keySet returns the keys, fetched again when they're expired, or when
+// refetch is set and they're older than jwksRefreshInterval.
+// the fetch runs outside the lock, the concurrent callers wait for it rather
+// than fetching too, and it's not retried before the backoff of its failures.
+// the last keys are returned while the fetches fail.
+func (j *JWKS) keySet(refetch bool) (map[string]interface{}, error) {
+	j.lock.Lock()
+	if done := j.fetching; done != nil {
+		j.lock.Unlock()
+		<-done
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		return j.result()
+	}
+	now := time.Now()
+	expired := j.keys == nil || now.Sub(j.fetched) > j.TTL
+	if (!expired && !(refetch && now.Sub(j.fetched) > jwksRefreshInterval)) || (j.failures > 0 && now.Before(j.retry)) {
+		defer j.lock.Unlock()
+		return j.result()
+	}
+	done := make(chan struct{})
+	j.fetching = done
+	j.lock.Unlock()
+
+	keys, err := j.fetch()
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if err == nil {
+		j.keys, j.fetched, j.failures = keys, time.Now(), 0
+	} else {
+		j.failures++
+		backoff := jwksRefreshInterval
+		if j.failures < 7 {
+			backoff = jwksRetryInterval << uint(j.failures-1)
+		}
+		if backoff > jwksRefreshInterval {
+			backoff = jwksRefreshInterval
+		}
+		j.retry = time.Now().Add(backoff)
+	}
+	j.err = err
+	j.fetching = nil
+	close(done)
+	return j.result()
+}
+
+// result returns the keys, or the error of the last fetch when there are
+// none, with the lock held.
+func (j *JWKS) result() (map[string]interface{}, error) {
+	if j.keys == nil {
+		return nil, j.err
+	}
+	return j.keys, nil
+}
+
+func lookupKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+	k, ok := keys[kid]
+	return k, ok
+}
+
+func (j *JWKS) fetch() (map[string]interface{}, error) {
+	resp, err := j.Client.Get(j.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks %s: %s", j.URL, resp.Status)
+	}
+	return ParseJWKS(resp.Body)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS decodes a JSON Web Key Set into keys by "kid".
+// keys not meant for signatures or of unknown type are skipped.
+func ParseJWKS(r io.Reader) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(r).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwks contains no usable key")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported curve " + k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, errors.New("unsupported key type " + k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
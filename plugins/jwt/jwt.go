@@ -0,0 +1,395 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jwt provides a filter validating Bearer JSON Web Tokens.
+// Usage
+//	import(
+//		"github.com/aamsur/beego"
+//		"github.com/aamsur/beego/plugins/jwt"
+//	)
+//
+//	func main(){
+//		// HMAC signed tokens
+//		beego.InsertFilter("/api/*", beego.BeforeRouter, jwt.Validate(&jwt.Options{
+//			Key:        []byte("secret"),
+//			Algorithms: []string{"HS256"},
+//		}))
+//
+//		// keys published by the identity provider
+//		beego.InsertFilter("/api/*", beego.BeforeRouter, jwt.Validate(&jwt.Options{
+//			JWKSURL:  "https://example.com/.well-known/jwks.json",
+//			Issuer:   "https://example.com/",
+//			Audience: "my-api",
+//		}))
+//		beego.Run()
+//	}
+//
+// the validated claims are stored in ctx.Input.Data under jwt.ClaimsKey:
+//	claims := ctx.Input.GetData(jwt.ClaimsKey).(jwt.Claims)
+// ctx.User() returns the same claims.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+)
+
+// ClaimsKey is the ctx.Input.Data key of the validated claims.
+const ClaimsKey = "jwt_claims"
+
+var (
+	ErrTokenMalformed    = errors.New("token is malformed")
+	ErrTokenUnverifiable = errors.New("token signature is invalid")
+	ErrTokenExpired      = errors.New("token is expired")
+	ErrTokenNotValidYet  = errors.New("token is not valid yet")
+	ErrAlgorithm         = errors.New("token signing algorithm is not allowed")
+	ErrIssuer            = errors.New("token issuer is invalid")
+	ErrAudience          = errors.New("token audience is invalid")
+	ErrNoKey             = errors.New("no key to verify the token")
+)
+
+// Claims is the decoded JWT payload.
+type Claims map[string]interface{}
+
+// String returns the claim value as string.
+func (c Claims) String(name string) string {
+	if v, ok := c[name].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Audience returns the "aud" claim, which can be a string or a string array.
+func (c Claims) Audience() []string {
+	switch v := c["aud"].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		aud := make([]string, 0, len(v))
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				aud = append(aud, s)
+			}
+		}
+		return aud
+	}
+	return nil
+}
+
+// Scopes returns the space separated "scope" claim.
+func (c Claims) Scopes() []string {
+	return strings.Fields(c.String("scope"))
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	switch v := c[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		i, err := v.Int64()
+		return time.Unix(i, 0), err == nil
+	}
+	return time.Time{}, false
+}
+
+// KeyFunc returns the verification key for the token with given header.
+type KeyFunc func(header map[string]interface{}) (interface{}, error)
+
+// Options represents the JWT validation options.
+type Options struct {
+	// Key verifies all tokens: []byte for HS*, *rsa.PublicKey for RS*, *ecdsa.PublicKey for ES*.
+	Key interface{}
+	// KeyFunc picks the key per token, for example by "kid". It overrides Key.
+	KeyFunc KeyFunc
+	// JWKSURL loads the keys from a JSON Web Key Set, used when Key and KeyFunc are empty.
+	JWKSURL string
+	// JWKSCacheTTL is how long the fetched key set is cached. default is one hour.
+	JWKSCacheTTL time.Duration
+	// Algorithms lists the accepted "alg" values. default accepts the algorithms matching the key type.
+	Algorithms []string
+	// Issuer, if set, must equal the "iss" claim.
+	Issuer string
+	// Audience, if set, must be contained in the "aud" claim.
+	Audience string
+	// Scopes, if set, must all be granted in the "scope" claim.
+	Scopes []string
+	// Leeway tolerates clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+	// Realm is sent in the WWW-Authenticate challenge.
+	Realm string
+	// Optional lets requests without token through, invalid tokens are still rejected.
+	Optional bool
+}
+
+// Validate returns a filter validating the Bearer token of the request.
+// failures are answered following RFC 6750.
+func Validate(opts *Options) beego.FilterFunc {
+	if opts.KeyFunc == nil && opts.Key == nil && opts.JWKSURL != "" {
+		opts.KeyFunc = NewJWKS(opts.JWKSURL, opts.JWKSCacheTTL).KeyFunc
+	}
+	return func(ctx *context.Context) {
+		token, err := bearerToken(ctx.Request)
+		if err != nil {
+			challenge(ctx, opts, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		if token == "" {
+			if !opts.Optional {
+				challenge(ctx, opts, http.StatusUnauthorized, "", "")
+			}
+			return
+		}
+		claims, err := opts.Parse(token)
+		if err != nil {
+			challenge(ctx, opts, http.StatusUnauthorized, "invalid_token", err.Error())
+			return
+		}
+		if len(opts.Scopes) > 0 {
+			granted := make(map[string]bool)
+			for _, s := range claims.Scopes() {
+				granted[s] = true
+			}
+			for _, s := range opts.Scopes {
+				if !granted[s] {
+					challenge(ctx, opts, http.StatusForbidden, "insufficient_scope", "the token does not grant scope "+s)
+					return
+				}
+			}
+		}
+		ctx.Input.SetData(ClaimsKey, claims)
+		ctx.SetUser(claims)
+	}
+}
+
+// bearerToken returns the token from the Bearer Authorization header or the
+// access_token query parameter. another Authorization scheme, like the Basic
+// of a proxy, leaves the query parameter. the body is never read.
+func bearerToken(r *http.Request) (string, error) {
+	var token string
+	if s := strings.SplitN(r.Header.Get("Authorization"), " ", 2); len(s) == 2 && strings.EqualFold(s[0], "Bearer") {
+		token = strings.TrimSpace(s[1])
+	}
+	if t := r.URL.Query().Get("access_token"); t != "" {
+		if token != "" {
+			return "", errors.New("multiple methods used to include the access token")
+		}
+		token = t
+	}
+	return token, nil
+}
+
+// challenge writes the WWW-Authenticate header and the error response.
+func challenge(ctx *context.Context, opts *Options, status int, code, description string) {
+	ctx.Output.Header("WWW-Authenticate", opts.challenge(code, description))
+	ctx.Output.SetStatus(status)
+	if code == "" {
+		code = "unauthorized"
+	}
+	ctx.Output.Json(map[string]string{"error": code, "error_description": description}, false, false)
+}
+
+// challenge returns the WWW-Authenticate header of an error following RFC 6750.
+func (opts *Options) challenge(code, description string) string {
+	realm := opts.Realm
+	if realm == "" {
+		realm = "beego"
+	}
+	value := fmt.Sprintf(`Bearer realm="%s"`, realm)
+	if code != "" {
+		value += fmt.Sprintf(`, error="%s"`, code)
+	}
+	if description != "" {
+		value += fmt.Sprintf(`, error_description="%s"`, strings.Replace(description, `"`, `'`, -1))
+	}
+	if len(opts.Scopes) > 0 && code == "insufficient_scope" {
+		value += fmt.Sprintf(`, scope="%s"`, strings.Join(opts.Scopes, " "))
+	}
+	return value
+}
+
+// Parse verifies the token signature and registered claims and returns its claims.
+func (opts *Options) Parse(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header map[string]interface{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	alg, _ := header["alg"].(string)
+	if !opts.allowed(alg) {
+		return nil, ErrAlgorithm
+	}
+	var key interface{}
+	var err error
+	if opts.KeyFunc != nil {
+		key, err = opts.KeyFunc(header)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		key = opts.Key
+	}
+	if key == nil {
+		return nil, ErrNoKey
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := Verify(alg, parts[0]+"."+parts[1], signature, key); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	now := time.Now()
+	if exp, ok := claims.time("exp"); ok && now.After(exp.Add(opts.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(opts.Leeway).Before(nbf) {
+		return nil, ErrTokenNotValidYet
+	}
+	if opts.Issuer != "" && claims.String("iss") != opts.Issuer {
+		return nil, ErrIssuer
+	}
+	if opts.Audience != "" {
+		found := false
+		for _, a := range claims.Audience() {
+			if a == opts.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrAudience
+		}
+	}
+	return claims, nil
+}
+
+func (opts *Options) allowed(alg string) bool {
+	if alg == "" || alg == "none" {
+		return false
+	}
+	if len(opts.Algorithms) > 0 {
+		for _, a := range opts.Algorithms {
+			if a == alg {
+				return true
+			}
+		}
+		return false
+	}
+	// without explicit list only accept the family matching a static key,
+	// so an RSA public key can never be used as HMAC secret.
+	switch opts.Key.(type) {
+	case []byte:
+		return strings.HasPrefix(alg, "HS")
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	}
+	return !strings.HasPrefix(alg, "HS")
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// Verify checks the signature of signingInput with alg and key.
+func Verify(alg, signingInput string, signature []byte, key interface{}) error {
+	if len(alg) != 5 {
+		return ErrAlgorithm
+	}
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return ErrAlgorithm
+	}
+	switch alg[:2] {
+	case "HS":
+		k, ok := key.([]byte)
+		if !ok {
+			return ErrNoKey
+		}
+		mac := hmac.New(hash.New, k)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrTokenUnverifiable
+		}
+		return nil
+	case "RS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrNoKey
+		}
+		h := hash.New()
+		h.Write([]byte(signingInput))
+		if rsa.VerifyPKCS1v15(k, hash, h.Sum(nil), signature) != nil {
+			return ErrTokenUnverifiable
+		}
+		return nil
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrNoKey
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrTokenUnverifiable
+		}
+		h := hash.New()
+		h.Write([]byte(signingInput))
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, h.Sum(nil), r, s) {
+			return ErrTokenUnverifiable
+		}
+		return nil
+	}
+	return ErrAlgorithm
+}
@@ -0,0 +1,286 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+)
+
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sign(t *testing.T, header map[string]interface{}, claims Claims, key interface{}) string {
+	input := encode(header) + "." + encode(claims)
+	h := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, h[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, h[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func serve(opts *Options, token string) (*httptest.ResponseRecorder, Claims) {
+	var claims Claims
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, Validate(opts))
+	handler.Get("/api", func(ctx *context.Context) {
+		claims, _ = ctx.Input.GetData(ClaimsKey).(Claims)
+		ctx.WriteString("ok")
+	})
+	r, _ := http.NewRequest("GET", "/api", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, claims
+}
+
+func TestHS256(t *testing.T) {
+	key := []byte("secret")
+	token := sign(t, map[string]interface{}{"alg": "HS256", "typ": "JWT"},
+		Claims{"sub": "astaxie", "exp": time.Now().Add(time.Hour).Unix()}, key)
+
+	w, claims := serve(&Options{Key: key}, token)
+	if w.Code != http.StatusOK || claims.Subject() != "astaxie" {
+		t.Errorf("valid token should pass, got %d %s", w.Code, w.Body.String())
+	}
+
+	w, _ = serve(&Options{Key: []byte("other")}, token)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Errorf("wrong key should be rejected with invalid_token, got %d %s", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestMissingAndExpired(t *testing.T) {
+	key := []byte("secret")
+	w, _ := serve(&Options{Key: key, Realm: "api"}, "")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="api"` {
+		t.Errorf("missing token should get a bare challenge, got %d %s", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	token := sign(t, map[string]interface{}{"alg": "HS256"},
+		Claims{"exp": time.Now().Add(-time.Hour).Unix()}, key)
+	w, _ = serve(&Options{Key: key}, token)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expired token should be rejected, got %d", w.Code)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	for _, test := range []struct {
+		auth, query, body string
+		token             string
+		err               bool
+	}{
+		{auth: "Bearer abc", token: "abc"},
+		{auth: "bearer abc", token: "abc"},
+		{query: "access_token=abc", token: "abc"},
+		{auth: "Basic dXNlcjpwYXNz", query: "access_token=abc", token: "abc"},
+		{auth: "Basic dXNlcjpwYXNz"},
+		{auth: "Bearer abc", query: "access_token=abc", err: true},
+		{body: "access_token=abc"},
+	} {
+		r, _ := http.NewRequest("POST", "/api?"+test.query, strings.NewReader(test.body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if test.auth != "" {
+			r.Header.Set("Authorization", test.auth)
+		}
+		token, err := bearerToken(r)
+		if token != test.token || (err != nil) != test.err {
+			t.Errorf("%+v: got %q %v", test, token, err)
+		}
+		if b, _ := ioutil.ReadAll(r.Body); string(b) != test.body {
+			t.Errorf("%+v: the body should not be read, left %q", test, b)
+		}
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := sign(t, map[string]interface{}{"alg": "HS256"}, Claims{"sub": "x"}, []byte("whatever"))
+	if _, err := (&Options{Key: &priv.PublicKey}).Parse(token); err != ErrAlgorithm {
+		t.Errorf("HS256 token must not be accepted with an RSA key, got %v", err)
+	}
+}
+
+func TestRS256AndES256(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := sign(t, map[string]interface{}{"alg": "RS256"}, Claims{"sub": "rsa", "aud": []string{"api"}}, rsaKey)
+	claims, err := (&Options{Key: &rsaKey.PublicKey, Audience: "api"}).Parse(token)
+	if err != nil || claims.Subject() != "rsa" {
+		t.Errorf("RS256 token should be valid, got %v", err)
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	token = sign(t, map[string]interface{}{"alg": "ES256"}, Claims{"sub": "ec", "iss": "me"}, ecKey)
+	if _, err := (&Options{Key: &ecKey.PublicKey, Issuer: "me"}).Parse(token); err != nil {
+		t.Errorf("ES256 token should be valid, got %v", err)
+	}
+	if _, err := (&Options{Key: &ecKey.PublicKey, Issuer: "you"}).Parse(token); err != ErrIssuer {
+		t.Errorf("wrong issuer should be rejected, got %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"k1","use":"sig","n":"%s","e":"%s"}]}`,
+			base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()))
+	}))
+	defer ts.Close()
+
+	opts := &Options{JWKSURL: ts.URL, Scopes: []string{"read"}}
+	token := sign(t, map[string]interface{}{"alg": "RS256", "kid": "k1"}, Claims{"sub": "jwks", "scope": "read write"}, rsaKey)
+	w, claims := serve(opts, token)
+	if w.Code != http.StatusOK || claims.Subject() != "jwks" {
+		t.Errorf("token signed by jwks key should pass, got %d %s", w.Code, w.Body.String())
+	}
+	serve(opts, token)
+	if fetches != 1 {
+		t.Errorf("jwks should be cached, fetched %d times", fetches)
+	}
+
+	token = sign(t, map[string]interface{}{"alg": "RS256", "kid": "k1"}, Claims{"scope": "write"}, rsaKey)
+	w, _ = serve(opts, token)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Header().Get("WWW-Authenticate"), "insufficient_scope") {
+		t.Errorf("missing scope should be rejected with 403, got %d", w.Code)
+	}
+}
+
+func TestJWKSFetch(t *testing.T) {
+	var lock sync.Mutex
+	fetches, fail := 0, true
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		lock.Lock()
+		defer lock.Unlock()
+		fetches++
+		if fail {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"keys":[{"kty":"oct","kid":"k1","k":"c2VjcmV0"}]}`)
+	}))
+	defer ts.Close()
+
+	jwks := NewJWKS(ts.URL, time.Hour)
+	header := map[string]interface{}{"kid": "k1"}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := jwks.KeyFunc(header); err == nil {
+				t.Error("a failed fetch should be an error")
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if _, err := jwks.KeyFunc(header); err == nil {
+		t.Error("the failure should be kept until the retry")
+	}
+	lock.Lock()
+	if fetches != 1 {
+		t.Errorf("the concurrent and the backed off calls should share one fetch, fetched %d times", fetches)
+	}
+	fail = false
+	lock.Unlock()
+
+	jwks.lock.Lock()
+	jwks.retry = time.Now()
+	jwks.lock.Unlock()
+	if key, err := jwks.KeyFunc(header); err != nil || string(key.([]byte)) != "secret" {
+		t.Errorf("the fetch should be retried after the backoff, got %v %v", key, err)
+	}
+	if _, err := jwks.KeyFunc(map[string]interface{}{"kid": "k2"}); err == nil {
+		t.Error("an unknown kid should be an error")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if fetches != 2 {
+		t.Errorf("an unknown kid should not be fetched again right away, fetched %d times", fetches)
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	key := []byte("secret")
+	auth := WebSocketAuth(&Options{Key: key, Scopes: []string{"chat"}})
+	call := func(query string) (*httptest.ResponseRecorder, interface{}, error) {
+		r, _ := http.NewRequest("GET", "/ws"+query, nil)
+		w := httptest.NewRecorder()
+		ctx := &context.Context{Input: context.NewInput(r), Output: context.NewOutput(), Request: r, ResponseWriter: w}
+		ctx.Output.Context = ctx
+		identity, err := auth(ctx)
+		return w, identity, err
+	}
+
+	if w, _, err := call(""); err == nil || err.(*beego.WebSocketAuthError).Status != 401 || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("an upgrade without token should be refused with 401, got %v", err)
+	}
+	token := sign(t, map[string]interface{}{"alg": "HS256"}, Claims{"sub": "astaxie"}, key)
+	if _, _, err := call("?access_token=" + token); err == nil || err.(*beego.WebSocketAuthError).Status != 403 {
+		t.Errorf("a token without the scope should be refused with 403, got %v", err)
+	}
+	token = sign(t, map[string]interface{}{"alg": "HS256"}, Claims{"sub": "astaxie", "scope": "chat"}, key)
+	if _, identity, err := call("?access_token=" + token); err != nil || identity.(Claims).Subject() != "astaxie" {
+		t.Errorf("a valid token should return its claims, got %v %v", identity, err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jwt
+
+import (
+	"net/http"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+)
+
+// WebSocketAuth authenticates the websocket upgrades with a token of their
+// Authorization header or of their access_token query parameter, the
+// browsers can't set the headers of a websocket. the claims are the identity
+// of the connection.
+//
+//	beego.WebSocketAuth = jwt.WebSocketAuth(&jwt.Options{Key: []byte("secret")})
+func WebSocketAuth(opts *Options) beego.WebSocketAuthFunc {
+	if opts.KeyFunc == nil && opts.Key == nil && opts.JWKSURL != "" {
+		opts.KeyFunc = NewJWKS(opts.JWKSURL, opts.JWKSCacheTTL).KeyFunc
+	}
+	return func(ctx *context.Context) (interface{}, error) {
+		token := beego.WebSocketToken(ctx.Request)
+		if token == "" {
+			ctx.Output.Header("WWW-Authenticate", opts.challenge("", ""))
+			return nil, &beego.WebSocketAuthError{Status: http.StatusUnauthorized, Message: "no token"}
+		}
+		claims, err := opts.Parse(token)
+		if err != nil {
+			ctx.Output.Header("WWW-Authenticate", opts.challenge("invalid_token", err.Error()))
+			return nil, &beego.WebSocketAuthError{Status: http.StatusUnauthorized, Message: err.Error()}
+		}
+		granted := make(map[string]bool)
+		for _, s := range claims.Scopes() {
+			granted[s] = true
+		}
+		for _, s := range opts.Scopes {
+			if !granted[s] {
+				description := "the token does not grant scope " + s
+				ctx.Output.Header("WWW-Authenticate", opts.challenge("insufficient_scope", description))
+				return nil, &beego.WebSocketAuthError{Status: http.StatusForbidden, Message: description}
+			}
+		}
+		ctx.Input.SetData(ClaimsKey, claims)
+		return claims, nil
+	}
+}
@@ -0,0 +1,509 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidc provides an OpenID Connect relying party for beego.
+// It redirects users to the identity provider, handles the callback,
+// validates the ID token and keeps the identity in the beego session.
+// Usage
+//	import(
+//		"github.com/aamsur/beego"
+//		"github.com/aamsur/beego/plugins/oidc"
+//	)
+//
+//	func main(){
+//		beego.SessionOn = true
+//		p := oidc.New("https://accounts.example.com", "client-id", "secret", "https://myapp.com/auth/callback")
+//		// serves /auth/login, /auth/callback and /auth/logout,
+//		// and sets ctx.User() for every request of a logged in user.
+//		beego.InsertFilter("*", beego.BeforeRouter, p.Filter())
+//		// anonymous users are sent to the login page
+//		beego.InsertFilter("/admin/*", beego.BeforeRouter, p.Require())
+//		beego.Run()
+//	}
+//
+// in controllers the identity is available with:
+//	user := oidc.CurrentIdentity(this.Ctx)
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/plugins/jwt"
+	"github.com/aamsur/beego/session"
+)
+
+// session keys used during the login flow.
+const (
+	sessionIdentity = "oidc_identity"
+	sessionState    = "oidc_state"
+	sessionNonce    = "oidc_nonce"
+	sessionVerifier = "oidc_verifier"
+	sessionReturn   = "oidc_return"
+)
+
+var (
+	ErrNoSession = errors.New("oidc: sessions must be enabled")
+	ErrState     = errors.New("oidc: state mismatch")
+	ErrNonce     = errors.New("oidc: nonce mismatch")
+	ErrNoIDToken = errors.New("oidc: token response has no id_token")
+)
+
+func init() {
+	gob.Register(Identity{})
+}
+
+// Identity is the logged in user as asserted by the ID token.
+type Identity struct {
+	Subject string
+	Email   string
+	Name    string
+	IDToken string
+	Claims  map[string]interface{}
+}
+
+// Provider is an OpenID Connect relying party.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes requested at login. default is openid, profile and email.
+	Scopes []string
+	// paths served by Filter. defaults are /auth/login, /auth/callback and /auth/logout.
+	LoginPath    string
+	CallbackPath string
+	LogoutPath   string
+	// where to go after login when no page was requested, and after logout. default is "/".
+	// AfterLogoutURL must be absolute to come back from the logout page of the identity provider.
+	AfterLoginURL  string
+	AfterLogoutURL string
+	// Leeway tolerates clock skew when checking the ID token.
+	Leeway time.Duration
+	Client *http.Client
+
+	lock      sync.Mutex
+	discovery *discovery
+	verifier  *jwt.Options
+	err       error // of the last discovery
+	failures  int
+	retry     time.Time
+	fetching  chan struct{} // closed at the end of the running discovery
+}
+
+// the interval before discovering again after a failure, doubled at each
+// failure up to discoveryMaxRetry.
+const (
+	discoveryRetry    = time.Second
+	discoveryMaxRetry = time.Minute
+)
+
+// discovery is the subset of the provider metadata used here.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// New returns a provider with default paths and scopes.
+// the provider metadata is discovered from issuer on first use.
+func New(issuer, clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		Issuer:         issuer,
+		ClientID:       clientID,
+		ClientSecret:   clientSecret,
+		RedirectURL:    redirectURL,
+		Scopes:         []string{"openid", "profile", "email"},
+		LoginPath:      "/auth/login",
+		CallbackPath:   "/auth/callback",
+		LogoutPath:     "/auth/logout",
+		AfterLoginURL:  "/",
+		AfterLogoutURL: "/",
+		Leeway:         time.Minute,
+		Client:         &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// CurrentIdentity returns the logged in identity of the request, or nil.
+// the session of a request without session cookie isn't started.
+func CurrentIdentity(ctx *context.Context) *Identity {
+	if id, ok := ctx.User().(*Identity); ok {
+		return id
+	}
+	if ctx.Input.CruSession == nil {
+		if _, err := ctx.Request.Cookie(beego.SessionName); err != nil {
+			return nil
+		}
+	}
+	sess, _ := ctx.Input.StartSession()
+	if sess == nil {
+		return nil
+	}
+	if id, ok := sess.Get(sessionIdentity).(Identity); ok {
+		return &id
+	}
+	return nil
+}
+
+// Filter serves the login, callback and logout paths and
+// sets ctx.User() to the *Identity of logged in users.
+// the other requests reach the session store only with a session cookie.
+func (p *Provider) Filter() beego.FilterFunc {
+	return func(ctx *context.Context) {
+		switch ctx.Input.Url() {
+		case p.LoginPath:
+			p.Login(ctx)
+		case p.CallbackPath:
+			p.Callback(ctx)
+		case p.LogoutPath:
+			p.Logout(ctx)
+		default:
+			if id := CurrentIdentity(ctx); id != nil {
+				ctx.SetUser(id)
+			}
+		}
+	}
+}
+
+// Require returns a filter sending anonymous GET requests to the login page
+// and answering other anonymous requests with 401.
+func (p *Provider) Require() beego.FilterFunc {
+	return func(ctx *context.Context) {
+		if CurrentIdentity(ctx) != nil {
+			return
+		}
+		if ctx.Input.Method() != "GET" || !beego.SessionOn {
+			fail(ctx, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
+			return
+		}
+		ctx.Redirect(http.StatusFound, p.LoginPath+"?next="+url.QueryEscape(ctx.Request.URL.RequestURI()))
+	}
+}
+
+// Login redirects to the authorization endpoint of the identity provider.
+// state, nonce and PKCE verifier are kept in the session for the callback.
+func (p *Provider) Login(ctx *context.Context) {
+	d, err := p.metadata()
+	if err != nil {
+		fail(ctx, http.StatusBadGateway, err)
+		return
+	}
+	sess, ok := startSession(ctx)
+	if !ok {
+		return
+	}
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	sess.Set(sessionState, state)
+	sess.Set(sessionNonce, nonce)
+	sess.Set(sessionVerifier, verifier)
+	if next := ctx.Input.Query("next"); isLocalURL(next) {
+		sess.Set(sessionReturn, next)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	ctx.Redirect(http.StatusFound, d.AuthorizationEndpoint+separator(d.AuthorizationEndpoint)+q.Encode())
+}
+
+// Callback exchanges the authorization code, validates the ID token
+// and stores the identity in a regenerated session.
+func (p *Provider) Callback(ctx *context.Context) {
+	sess, ok := startSession(ctx)
+	if !ok {
+		return
+	}
+	state, _ := sess.Get(sessionState).(string)
+	nonce, _ := sess.Get(sessionNonce).(string)
+	verifier, _ := sess.Get(sessionVerifier).(string)
+	next, _ := sess.Get(sessionReturn).(string)
+	for _, k := range []string{sessionState, sessionNonce, sessionVerifier, sessionReturn} {
+		sess.Delete(k)
+	}
+
+	if e := ctx.Input.Query("error"); e != "" {
+		fail(ctx, http.StatusUnauthorized, fmt.Errorf("oidc: %s %s", e, ctx.Input.Query("error_description")))
+		return
+	}
+	got := ctx.Input.Query("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(got)) != 1 {
+		fail(ctx, http.StatusBadRequest, ErrState)
+		return
+	}
+	idToken, err := p.exchange(ctx.Input.Query("code"), verifier)
+	if err != nil {
+		fail(ctx, http.StatusBadGateway, err)
+		return
+	}
+	claims, err := p.Verify(idToken, nonce)
+	if err != nil {
+		fail(ctx, http.StatusUnauthorized, err)
+		return
+	}
+
+	// a new session id after login prevents session fixation
+	if beego.GlobalSessions != nil {
+		if s := beego.GlobalSessions.SessionRegenerateId(ctx.ResponseWriter, ctx.Request); s != nil {
+			ctx.Input.CruSession = s
+		}
+	}
+	id := Identity{
+		Subject: claims.Subject(),
+		Email:   claims.String("email"),
+		Name:    claims.String("name"),
+		IDToken: idToken,
+		Claims:  claims,
+	}
+	ctx.Input.CruSession.Set(sessionIdentity, id)
+	ctx.SetUser(&id)
+
+	if next == "" {
+		next = p.AfterLoginURL
+	}
+	ctx.Redirect(http.StatusFound, next)
+}
+
+// Logout removes the identity from the session and redirects to the
+// end session endpoint of the identity provider when it has one.
+func (p *Provider) Logout(ctx *context.Context) {
+	var hint string
+	if id := CurrentIdentity(ctx); id != nil {
+		hint = id.IDToken
+	}
+	sess, ok := startSession(ctx)
+	if !ok {
+		return
+	}
+	sess.Delete(sessionIdentity)
+
+	target := p.AfterLogoutURL
+	if d, err := p.metadata(); err == nil && d.EndSessionEndpoint != "" && hint != "" {
+		q := url.Values{}
+		q.Set("id_token_hint", hint)
+		if strings.HasPrefix(target, "http") {
+			q.Set("post_logout_redirect_uri", target)
+		}
+		target = d.EndSessionEndpoint + separator(d.EndSessionEndpoint) + q.Encode()
+	}
+	ctx.Redirect(http.StatusFound, target)
+}
+
+// Verify validates the ID token signature, issuer, audience, expiry and nonce.
+func (p *Provider) Verify(idToken, nonce string) (jwt.Claims, error) {
+	if _, err := p.metadata(); err != nil {
+		return nil, err
+	}
+	claims, err := p.verifier.Parse(idToken)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, jwt.ErrTokenExpired
+	}
+	if azp := claims.String("azp"); azp != "" && azp != p.ClientID {
+		return nil, jwt.ErrAudience
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.String("nonce")), []byte(nonce)) != 1 {
+		return nil, ErrNonce
+	}
+	return claims, nil
+}
+
+// metadata returns the provider metadata, discovered once. the discovery
+// runs outside the lock, the concurrent callers wait for it rather than
+// discovering too, and it's not retried before the backoff of its failures.
+func (p *Provider) metadata() (*discovery, error) {
+	p.lock.Lock()
+	if done := p.fetching; done != nil {
+		p.lock.Unlock()
+		<-done
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		return p.result()
+	}
+	if p.discovery != nil || (p.failures > 0 && time.Now().Before(p.retry)) {
+		defer p.lock.Unlock()
+		return p.result()
+	}
+	done := make(chan struct{})
+	p.fetching = done
+	p.lock.Unlock()
+
+	d, verifier, err := p.discover()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err == nil {
+		p.discovery, p.verifier, p.failures = d, verifier, 0
+	} else {
+		p.failures++
+		backoff := discoveryMaxRetry
+		if p.failures < 7 {
+			backoff = discoveryRetry << uint(p.failures-1)
+		}
+		if backoff > discoveryMaxRetry {
+			backoff = discoveryMaxRetry
+		}
+		p.retry = time.Now().Add(backoff)
+	}
+	p.err = err
+	p.fetching = nil
+	close(done)
+	return p.result()
+}
+
+// result returns the metadata, or the error of the last discovery when
+// there is none, with the lock held.
+func (p *Provider) result() (*discovery, error) {
+	if p.discovery == nil {
+		return nil, p.err
+	}
+	return p.discovery, nil
+}
+
+// discover fetches the provider metadata and makes the verifier of its keys.
+func (p *Provider) discover() (*discovery, *jwt.Options, error) {
+	u := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := p.Client.Get(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("oidc: discovery %s: %s", u, resp.Status)
+	}
+	d := new(discovery)
+	if err := json.NewDecoder(resp.Body).Decode(d); err != nil {
+		return nil, nil, err
+	}
+	if d.Issuer != p.Issuer {
+		return nil, nil, fmt.Errorf("oidc: issuer %q does not match %q", d.Issuer, p.Issuer)
+	}
+	jwks := jwt.NewJWKS(d.JWKSURI, 0)
+	jwks.Client = p.Client
+	verifier := &jwt.Options{
+		KeyFunc:  jwks.KeyFunc,
+		Issuer:   p.Issuer,
+		Audience: p.ClientID,
+		Leeway:   p.Leeway,
+	}
+	return d, verifier, nil
+}
+
+// exchange redeems the authorization code at the token endpoint and returns the ID token.
+func (p *Provider) exchange(code, verifier string) (string, error) {
+	d, err := p.metadata()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("oidc: token response: %v", err)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("oidc: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc: token endpoint: %s", resp.Status)
+	}
+	if token.IDToken == "" {
+		return "", ErrNoIDToken
+	}
+	return token.IDToken, nil
+}
+
+// startSession returns the session of the request, answering 500 without one.
+func startSession(ctx *context.Context) (session.SessionStore, bool) {
+	sess, err := ctx.Input.StartSession()
+	if sess == nil {
+		if err == nil {
+			err = ErrNoSession
+		}
+		fail(ctx, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return sess, true
+}
+
+func fail(ctx *context.Context, status int, err error) {
+	beego.Warn(err)
+	ctx.Output.SetStatus(status)
+	ctx.Output.Body([]byte(err.Error()))
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// isLocalURL reports whether u is a path on this site, so that the
+// login can't be used as an open redirect.
+func isLocalURL(u string) bool {
+	return strings.HasPrefix(u, "/") && !strings.HasPrefix(u, "//") && !strings.HasPrefix(u, "/\\")
+}
+
+func separator(u string) string {
+	if strings.Contains(u, "?") {
+		return "&"
+	}
+	return "?"
+}
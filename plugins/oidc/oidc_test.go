@@ -0,0 +1,242 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/session"
+)
+
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newIdP starts a fake identity provider issuing ID tokens for the nonce of the last login.
+func newIdP(t *testing.T, clientID string, nonce *string) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ts.URL,
+			"authorization_endpoint": ts.URL + "/authorize",
+			"token_endpoint":         ts.URL + "/token",
+			"jwks_uri":               ts.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, _, _ := r.BasicAuth(); id != clientID || r.FormValue("code") != "good" || r.FormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		input := encode(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + encode(map[string]interface{}{
+			"iss":   ts.URL,
+			"aud":   clientID,
+			"sub":   "42",
+			"email": "astaxie@example.com",
+			"nonce": *nonce,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+		h := sha256.Sum256([]byte(input))
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "at",
+			"id_token":     input + "." + base64.RawURLEncoding.EncodeToString(sig),
+		})
+	})
+	ts = httptest.NewServer(mux)
+	return ts
+}
+
+type client struct {
+	handler *beego.ControllerRegistor
+	cookies map[string]*http.Cookie
+}
+
+func (c *client) get(u string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", u, nil)
+	for _, cookie := range c.cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+	for _, cookie := range readCookies(w) {
+		c.cookies[cookie.Name] = cookie
+	}
+	return w
+}
+
+func readCookies(w *httptest.ResponseRecorder) []*http.Cookie {
+	return (&http.Response{Header: w.Header()}).Cookies()
+}
+
+func TestLoginFlow(t *testing.T) {
+	var nonce string
+	idp := newIdP(t, "client", &nonce)
+	defer idp.Close()
+
+	sessionOn, globalSessions := beego.SessionOn, beego.GlobalSessions
+	defer func() { beego.SessionOn, beego.GlobalSessions = sessionOn, globalSessions }()
+	beego.SessionOn = true
+	beego.GlobalSessions, _ = session.NewManager("memory", `{"cookieName":"`+beego.SessionName+`","enableSetCookie":true,"gclifetime":3600}`)
+
+	p := New(idp.URL, "client", "secret", "http://localhost/auth/callback")
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, p.Filter())
+	handler.InsertFilter("/private", beego.BeforeRouter, p.Require())
+	handler.Get("/private", func(ctx *context.Context) {
+		ctx.WriteString("hello " + ctx.User().(*Identity).Email)
+	})
+	handler.Get("/public", func(ctx *context.Context) {
+		ctx.WriteString("public")
+	})
+	handler.Get("/signin", p.Login)
+	c := &client{handler: handler, cookies: map[string]*http.Cookie{}}
+
+	w := c.get("/public")
+	if w.Body.String() != "public" || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("anonymous requests should not start a session, got %q %q", w.Body.String(), w.Header().Get("Set-Cookie"))
+	}
+	w = (&client{handler: handler, cookies: map[string]*http.Cookie{}}).get("/signin")
+	if w.Code != http.StatusFound || w.Header().Get("Set-Cookie") == "" {
+		t.Errorf("Login outside the filter should start the session, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = c.get("/private")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/auth/login?next=%2Fprivate" {
+		t.Fatalf("anonymous user should be sent to login, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+
+	w = c.get("/auth/login?next=/private")
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || loc.Path != "/authorize" {
+		t.Fatalf("login should redirect to the identity provider, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("client_id") != "client" || q.Get("code_challenge_method") != "S256" || q.Get("state") == "" {
+		t.Errorf("unexpected authorization request %s", loc)
+	}
+	nonce = q.Get("nonce")
+
+	w = c.get("/auth/callback?code=good&state=forged")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("forged state should be rejected, got %d", w.Code)
+	}
+
+	c.get("/auth/login?next=/private")
+	w = c.get("/auth/login?next=/private")
+	loc, _ = url.Parse(w.Header().Get("Location"))
+	nonce = loc.Query().Get("nonce")
+	w = c.get("/auth/callback?code=good&state=" + url.QueryEscape(loc.Query().Get("state")))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/private" {
+		t.Fatalf("callback should log in and return to the page, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = c.get("/private")
+	if w.Body.String() != "hello astaxie@example.com" {
+		t.Errorf("logged in user should be available with ctx.User(), got %d %s", w.Code, w.Body.String())
+	}
+
+	c.get("/auth/logout")
+	w = c.get("/private")
+	if w.Code != http.StatusFound {
+		t.Errorf("logout should forget the user, got %d", w.Code)
+	}
+}
+
+func TestVerifyNonce(t *testing.T) {
+	nonce := "expected"
+	idp := newIdP(t, "client", &nonce)
+	defer idp.Close()
+	p := New(idp.URL, "client", "secret", "http://localhost/auth/callback")
+	idToken, err := p.exchange("good", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Verify(idToken, "expected"); err != nil {
+		t.Errorf("token should verify, got %v", err)
+	}
+	if _, err := p.Verify(idToken, "other"); err != ErrNonce {
+		t.Errorf("nonce mismatch should fail, got %v", err)
+	}
+	if isLocalURL("//evil.com") || !isLocalURL("/private") {
+		t.Error("only local urls can be used after login")
+	}
+}
+
+func TestDiscoveryBackoff(t *testing.T) {
+	var lock sync.Mutex
+	hits := 0
+	release := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits++
+		lock.Unlock()
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	p := New(ts.URL, "app", "secret", "http://app/auth/callback")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.metadata(); err == nil {
+				t.Error("a failed discovery should be an error")
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if _, err := p.metadata(); err == nil {
+		t.Error("the error should be kept during the backoff")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if hits != 1 {
+		t.Errorf("the concurrent and backed off calls should share one discovery, got %d", hits)
+	}
+}
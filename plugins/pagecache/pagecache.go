@@ -0,0 +1,294 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pagecache provides a full page cache filter for anonymous traffic.
+// responses are stored in any cache adapter keyed by url and the Vary headers,
+// and served with an ETag so clients revalidate with If-None-Match.
+// a response varying on a header missing from Options.Vary, such as the
+// Vary: Origin of the cors filter, is not stored. neither is a page embedding
+// the CSP nonce of SecurityHeadersFilter, the nonce must differ per request.
+// Usage
+//	import(
+//		"github.com/aamsur/beego"
+//		"github.com/aamsur/beego/cache"
+//		"github.com/aamsur/beego/plugins/pagecache"
+//	)
+//
+//	var pages *pagecache.PageCache
+//
+//	func main(){
+//		bm, _ := cache.NewCache("memory", `{"interval":60}`)
+//		pages = pagecache.New(&pagecache.Options{Cache: bm, TTL: 5 * time.Minute})
+//		beego.InsertFilter("/blog/*", beego.BeforeRouter, pages.Filter())
+//		beego.Run()
+//	}
+//
+//	// in the controller showing a post
+//	pagecache.Tag(this.Ctx, "post:"+id)
+//
+//	// after the post was edited
+//	pages.Invalidate("post:" + id)
+package pagecache
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/cache"
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/utils"
+)
+
+// tagsKey is the ctx.Input.Data key of the tags added by Tag.
+const tagsKey = "_pagecache_tags"
+
+// Options represents the page cache options.
+type Options struct {
+	// Cache stores the pages, it's required.
+	Cache cache.Cache
+	// TTL of a cached page. default is one minute.
+	TTL time.Duration
+	// Vary lists the request headers which are part of the key. default is Accept-Encoding.
+	// responses with a Vary header not listed here aren't cached.
+	Vary []string
+	// MaxSize is the largest body cached in bytes. default is 1MB.
+	MaxSize int
+	// Skip returns true for requests not using the cache.
+	// default skips requests with an Authorization header or a session cookie.
+	Skip func(ctx *context.Context) bool
+	// Tags returns the tags of the page, in addition to the ones added by Tag.
+	Tags func(ctx *context.Context) []string
+}
+
+// PageCache caches full pages.
+type PageCache struct {
+	opts *Options
+	ttl  int64
+}
+
+// entry is a stored page.
+type entry struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	Tags     []string    `json:"tags,omitempty"`
+	StoredAt int64       `json:"storedAt"`
+}
+
+// New returns a page cache using opts.
+func New(opts *Options) *PageCache {
+	if opts.Cache == nil {
+		panic("pagecache: Options.Cache is required")
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.Vary == nil {
+		opts.Vary = []string{"Accept-Encoding"}
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 1 << 20
+	}
+	if opts.Skip == nil {
+		opts.Skip = anonymousOnly
+	}
+	return &PageCache{opts: opts, ttl: int64(opts.TTL / time.Second)}
+}
+
+// anonymousOnly skips requests of logged in users.
+func anonymousOnly(ctx *context.Context) bool {
+	if ctx.Input.Header("Authorization") != "" {
+		return true
+	}
+	_, err := ctx.Request.Cookie(beego.SessionName)
+	return err == nil
+}
+
+// Tag adds tags to the page being rendered, so Invalidate can drop it.
+func Tag(ctx *context.Context, tags ...string) {
+	old, _ := ctx.Input.GetData(tagsKey).([]string)
+	ctx.Input.SetData(tagsKey, append(old, tags...))
+}
+
+// Invalidate drops all the cached pages having one of the tags.
+func (p *PageCache) Invalidate(tags ...string) {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	for _, tag := range tags {
+		if err := p.opts.Cache.Put(tagKey(tag), now, p.ttl); err != nil {
+			beego.Error("pagecache: invalidate", tag, err)
+		}
+	}
+}
+
+// Filter returns the filter serving and storing the pages.
+// it should run before the router, only GET and HEAD requests are cached.
+func (p *PageCache) Filter() beego.FilterFunc {
+	return func(ctx *context.Context) {
+		method := ctx.Input.Method()
+		if (method != "GET" && method != "HEAD") || p.opts.Skip(ctx) {
+			return
+		}
+		key := p.key(ctx)
+		if e, ok := p.load(key); ok {
+			serve(ctx, e)
+			return
+		}
+		if method == "HEAD" {
+			return
+		}
+		rec := utils.NewResponseRecorder(ctx.ResponseWriter, p.opts.MaxSize)
+		rec.Header().Set("X-Cache", "MISS")
+		ctx.ResponseWriter = rec
+		ctx.Defer(func() {
+			if rec.Status != http.StatusOK || rec.Overflow || !cacheable(rec.Header()) || !p.varies(rec.Header()) {
+				return
+			}
+			nonce, _ := ctx.Input.GetData(beego.CSPNonceKey).(string)
+			if nonce != "" && bytes.Contains(rec.Body.Bytes(), []byte(nonce)) {
+				return
+			}
+			header := make(http.Header)
+			for k, v := range rec.Header() {
+				header[k] = v
+			}
+			header.Del("X-Cache")
+			if nonce != "" {
+				// a hit keeps the policy with its own nonce
+				header.Del("Content-Security-Policy")
+				header.Del("Content-Security-Policy-Report-Only")
+			}
+			if header.Get("ETag") == "" {
+				header.Set("ETag", context.BodyETag(rec.Body.Bytes()))
+			}
+			tags, _ := ctx.Input.GetData(tagsKey).([]string)
+			if p.opts.Tags != nil {
+				tags = append(tags, p.opts.Tags(ctx)...)
+			}
+			p.store(key, &entry{
+				Status:   rec.Status,
+				Header:   header,
+				Body:     rec.Body.Bytes(),
+				Tags:     tags,
+				StoredAt: time.Now().UnixNano(),
+			})
+		})
+	}
+}
+
+func (p *PageCache) key(ctx *context.Context) string {
+	key := "pagecache:" + ctx.Input.Host() + ctx.Request.URL.RequestURI()
+	for _, h := range p.opts.Vary {
+		key += "|" + ctx.Input.Header(h)
+	}
+	return key
+}
+
+// varies reports whether all the Vary headers of the response are in the key.
+func (p *PageCache) varies(h http.Header) bool {
+	for _, v := range h["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			found := false
+			for _, k := range p.opts.Vary {
+				if strings.EqualFold(k, name) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *PageCache) load(key string) (*entry, bool) {
+	b, ok := bytesOf(p.opts.Cache.Get(key))
+	if !ok {
+		return nil, false
+	}
+	e := new(entry)
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, false
+	}
+	for _, tag := range e.Tags {
+		if b, ok := bytesOf(p.opts.Cache.Get(tagKey(tag))); ok {
+			if at, _ := strconv.ParseInt(string(b), 10, 64); at >= e.StoredAt {
+				p.opts.Cache.Delete(key)
+				return nil, false
+			}
+		}
+	}
+	return e, true
+}
+
+func (p *PageCache) store(key string, e *entry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := p.opts.Cache.Put(key, string(b), p.ttl); err != nil {
+		beego.Error("pagecache: store", key, err)
+	}
+}
+
+func tagKey(tag string) string {
+	return "pagecache:tag:" + tag
+}
+
+func bytesOf(v interface{}) ([]byte, bool) {
+	switch v := v.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	}
+	return nil, false
+}
+
+// cacheable reports whether the response may be shared between clients.
+func cacheable(h http.Header) bool {
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	return !strings.Contains(cc, "private") && !strings.Contains(cc, "no-store")
+}
+
+// serve writes a cached page, or 304 when the client has it already.
+func serve(ctx *context.Context, e *entry) {
+	dst := ctx.ResponseWriter.Header()
+	for k, v := range e.Header {
+		dst[k] = v
+	}
+	dst.Set("X-Cache", "HIT")
+	if context.ETagMatch(ctx.Input.Header("If-None-Match"), e.Header.Get("ETag")) {
+		dst.Del("Content-Length")
+		ctx.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	ctx.ResponseWriter.WriteHeader(e.Status)
+	if ctx.Input.Method() != "HEAD" {
+		ctx.ResponseWriter.Write(e.Body)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pagecache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aamsur/beego"
+	"github.com/aamsur/beego/cache"
+	"github.com/aamsur/beego/context"
+)
+
+func TestPageCache(t *testing.T) {
+	bm, err := cache.NewCache("memory", `{"interval":60}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := New(&Options{Cache: bm})
+	renders := 0
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, pages.Filter())
+	handler.Get("/post", func(ctx *context.Context) {
+		renders++
+		Tag(ctx, "post:1")
+		ctx.WriteString("render " + strconv.Itoa(renders))
+	})
+
+	get := func(header map[string]string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/post", nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get(nil); w.Body.String() != "render 1" || w.HeaderMap.Get("X-Cache") != "MISS" {
+		t.Fatalf("first request should render, got %q", w.Body.String())
+	}
+	w := get(nil)
+	if w.Body.String() != "render 1" || w.HeaderMap.Get("X-Cache") != "HIT" {
+		t.Errorf("second request should be served from cache, got %q", w.Body.String())
+	}
+	etag := w.HeaderMap.Get("ETag")
+	if etag == "" {
+		t.Fatal("cached page should have an ETag")
+	}
+	if w := get(map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match should be 304, got %d", w.Code)
+	}
+	if w := get(map[string]string{"Authorization": "Bearer x"}); w.Body.String() != "render 2" {
+		t.Errorf("authorized request should skip the cache, got %q", w.Body.String())
+	}
+
+	pages.Invalidate("post:1")
+	if w := get(nil); w.Body.String() != "render 3" {
+		t.Errorf("invalidated page should render again, got %q", w.Body.String())
+	}
+	if w := get(nil); w.Body.String() != "render 3" {
+		t.Errorf("page should be cached again, got %q", w.Body.String())
+	}
+}
+
+func TestPageCacheVary(t *testing.T) {
+	bm, err := cache.NewCache("memory", `{"interval":60}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := New(&Options{Cache: bm})
+	renders := 0
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeRouter, pages.Filter())
+	handler.Get("/cors", func(ctx *context.Context) {
+		renders++
+		ctx.Output.Header("Access-Control-Allow-Origin", ctx.Input.Header("Origin"))
+		ctx.Output.Header("Vary", "Origin")
+		ctx.WriteString("render " + strconv.Itoa(renders))
+	})
+
+	for _, origin := range []string{"http://a.com", "http://b.com"} {
+		r, _ := http.NewRequest("GET", "/cors", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.HeaderMap.Get("Access-Control-Allow-Origin") != origin {
+			t.Errorf("%s should get its own CORS answer, got %q", origin, w.HeaderMap.Get("Access-Control-Allow-Origin"))
+		}
+	}
+	if renders != 2 {
+		t.Errorf("a response varying on a header outside the key should not be cached, rendered %d times", renders)
+	}
+}
+
+func TestPageCacheNonce(t *testing.T) {
+	bm, err := cache.NewCache("memory", `{"interval":60}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := New(&Options{Cache: bm})
+	renders := 0
+	handler := beego.NewControllerRegister()
+	handler.InsertFilter("*", beego.BeforeStatic, beego.SecurityHeadersFilter(beego.NewSecurityHeaders()))
+	handler.InsertFilter("*", beego.BeforeRouter, pages.Filter())
+	handler.Get("/script", func(ctx *context.Context) {
+		renders++
+		nonce, _ := ctx.Input.GetData(beego.CSPNonceKey).(string)
+		ctx.WriteString(`<script nonce="` + nonce + `"></script>`)
+	})
+	handler.Get("/plain", func(ctx *context.Context) {
+		renders++
+		ctx.WriteString("plain")
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	if get("/script").Body.String() == get("/script").Body.String() || renders != 2 {
+		t.Error("pages embedding the CSP nonce should not be cached")
+	}
+
+	first, second := get("/plain"), get("/plain")
+	if second.HeaderMap.Get("X-Cache") != "HIT" {
+		t.Fatal("pages without the nonce should be cached")
+	}
+	if first.HeaderMap.Get("Content-Security-Policy") == second.HeaderMap.Get("Content-Security-Policy") {
+		t.Error("a cached page should keep the policy with the nonce of its own request")
+	}
+}
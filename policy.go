@@ -0,0 +1,115 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"strings"
+
+	beecontext "github.com/aamsur/beego/context"
+)
+
+// the policies of the router for the trailing slash and the case of
+// the request paths differing from the patterns of the routes.
+const (
+	PolicyRewrite  = "rewrite"  // serve the route
+	PolicyStrict   = "strict"   // don't match the route
+	PolicyRedirect = "redirect" // redirect to the path of the route, 301 for GET and HEAD, else 308
+)
+
+func validPolicy(policy string) bool {
+	return policy == PolicyRewrite || policy == PolicyStrict || policy == PolicyRedirect
+}
+
+// slashPolicy returns the trailing slash policy of the route.
+func (c *controllerInfo) slashPolicy() string {
+	if c.trailingSlash != "" {
+		return c.trailingSlash
+	}
+	return RouterTrailingSlash
+}
+
+// casePolicy returns the path case policy of the route, strict unless
+// RouterPathCase is set when RouterCaseSensitive, else rewrite.
+func (c *controllerInfo) casePolicy() string {
+	if c.pathCase != "" {
+		return c.pathCase
+	}
+	if RouterPathCase != "" {
+		return RouterPathCase
+	}
+	if RouterCaseSensitive {
+		return PolicyStrict
+	}
+	return PolicyRewrite
+}
+
+// matchRoute finds the route of urlPath for method and applies its
+// policies: a request path differing only by the case from a route, which
+// is in lower case, or by the trailing slash matches it unless the policy
+// is strict, with the path to redirect to if the policy is redirect.
+// the routes of handlers have no policies. the params of the route are
+// added to params.
+func (p *ControllerRegistor) matchRoute(ctx *beecontext.Context, method, urlPath string, params *Params) (route *controllerInfo, redirect string) {
+	reqPath := ctx.Request.URL.Path
+	n := len(*params)
+	defer func() {
+		if route == nil {
+			*params = (*params)[:n]
+		}
+	}()
+	route = p.findRoute(ctx, method, urlPath, params)
+	folded := !RouterCaseSensitive && route != nil && reqPath != urlPath
+	if route == nil && RouterCaseSensitive {
+		if lower := strings.ToLower(urlPath); lower != urlPath {
+			route = p.findRoute(ctx, method, lower, params)
+			folded = true
+		}
+	}
+	if route == nil || route.routerType == routerTypeHandler {
+		return
+	}
+	if folded {
+		switch route.casePolicy() {
+		case PolicyStrict:
+			return nil, ""
+		case PolicyRedirect:
+			redirect = strings.ToLower(reqPath)
+		}
+	}
+	if policy := route.slashPolicy(); policy != PolicyRewrite && reqPath != "/" && !looseEnd(route.pattern) {
+		want := strings.HasSuffix(route.pattern, "/")
+		if strings.HasSuffix(reqPath, "/") != want {
+			if policy == PolicyStrict {
+				return nil, ""
+			}
+			if redirect == "" {
+				redirect = reqPath
+			}
+			if want {
+				redirect += "/"
+			} else {
+				redirect = strings.TrimRight(redirect, "/")
+			}
+		}
+	}
+	return
+}
+
+// looseEnd reports whether the last segment of pattern is a splat or
+// optional, matching the paths with or without trailing slash.
+func looseEnd(pattern string) bool {
+	last := pattern[strings.LastIndex(strings.TrimSuffix(pattern, "/"), "/")+1:]
+	return strings.Contains(last, "*") || strings.HasPrefix(last, "?")
+}
@@ -0,0 +1,130 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+type policyCase struct {
+	method, url string
+	code        int
+	location    string
+}
+
+func testPolicies(t *testing.T, handler http.Handler, cases []policyCase) {
+	for _, c := range cases {
+		r, _ := http.NewRequest(c.method, c.url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s %s: code is %d, want %d", c.method, c.url, w.Code, c.code)
+		}
+		if loc := w.HeaderMap.Get("Location"); loc != c.location {
+			t.Errorf("%s %s: Location is %q, want %q", c.method, c.url, loc, c.location)
+		}
+	}
+}
+
+func TestTrailingSlashPolicies(t *testing.T) {
+	defer func(p string) { RouterTrailingSlash = p }(RouterTrailingSlash)
+	ok := func(ctx *context.Context) { ctx.Output.Body([]byte("ok")) }
+	handler := NewControllerRegister()
+	handler.Get("/item", ok)
+	handler.Post("/item", ok)
+	handler.Get("/dir/", ok)
+	handler.Get("/files/*", ok)
+
+	RouterTrailingSlash = PolicyRewrite
+	testPolicies(t, handler, []policyCase{
+		{"GET", "/item/", 200, ""},
+		{"GET", "/dir", 200, ""},
+	})
+
+	RouterTrailingSlash = PolicyStrict
+	testPolicies(t, handler, []policyCase{
+		{"GET", "/item", 200, ""},
+		{"GET", "/item/", 404, ""},
+		{"GET", "/dir/", 200, ""},
+		{"GET", "/dir", 404, ""},
+		{"GET", "/files/a/", 200, ""},
+	})
+
+	RouterTrailingSlash = PolicyRedirect
+	testPolicies(t, handler, []policyCase{
+		{"GET", "/item/?a=1", 301, "/item?a=1"},
+		{"POST", "/item/", 308, "/item"},
+		{"GET", "/dir", 301, "/dir/"},
+		{"GET", "/", 404, ""},
+	})
+}
+
+func TestPathCasePolicies(t *testing.T) {
+	defer func(p string) { RouterPathCase = p }(RouterPathCase)
+	handler := NewControllerRegister()
+	handler.Get("/user/:name", func(ctx *context.Context) {
+		ctx.Output.Body([]byte(ctx.Input.Param(":name")))
+	})
+
+	RouterPathCase = ""
+	testPolicies(t, handler, []policyCase{
+		{"GET", "/user/bob", 200, ""},
+		{"GET", "/User/bob", 404, ""},
+	})
+
+	RouterPathCase = PolicyRewrite
+	testPolicies(t, handler, []policyCase{
+		{"GET", "/User/Bob", 200, ""},
+	})
+
+	RouterPathCase = PolicyRedirect
+	testPolicies(t, handler, []policyCase{
+		{"GET", "/USER/bob", 301, "/user/bob"},
+		{"GET", "/user/Bob", 200, ""},
+	})
+}
+
+func TestNamespacePolicies(t *testing.T) {
+	ok := func(ctx *context.Context) { ctx.Output.Body([]byte("ok")) }
+	ns := NewNamespace("/seo",
+		NSTrailingSlash(PolicyRedirect),
+		NSPathCase(PolicyRedirect),
+		NSGet("/page", ok),
+		NSNamespace("/api",
+			NSTrailingSlash(PolicyStrict),
+			NSGet("/item", ok),
+		),
+	)
+	AddNamespace(ns)
+	testPolicies(t, BeeApp.Handlers, []policyCase{
+		{"GET", "/seo/page", 200, ""},
+		{"GET", "/seo/page/", 301, "/seo/page"},
+		{"GET", "/SEO/Page/", 301, "/seo/page"},
+		{"GET", "/seo/api/item", 200, ""},
+		{"GET", "/seo/api/item/", 404, ""},
+		{"GET", "/SEO/api/item", 301, "/seo/api/item"},
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("an invalid policy should panic")
+		}
+	}()
+	NewNamespace("/", NSTrailingSlash("loose"))
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/aamsur/beego/grace"
+	"github.com/aamsur/beego/toolbox"
+)
+
+// the probes of EnableProbes, in the kubernetes style:
+//
+//	/livez     the process serves, it checks nothing else.
+//	/readyz    the readiness checks pass, see toolbox.AddReadinessCheck, and
+//	           the app isn't starting, in maintenance or draining on shutdown.
+//	/startupz  the startup hooks are done, see OnStartup.
+//
+// they answer 200 "ok" or 503 with the failed checks, ?verbose lists all the
+// checks. with ProbesOnAdmin they're on the admin server, out of the app
+// filters and running during the startup hooks.
+var probes = map[string]http.HandlerFunc{
+	"/livez":    livez,
+	"/readyz":   readyz,
+	"/startupz": startupz,
+}
+
+func init() {
+	grace.OnShutdown(func() { toolbox.SetDraining(true) })
+}
+
+// mountProbes serves the probes on the app or the admin server.
+func mountProbes() {
+	for path, f := range probes {
+		if ProbesOnAdmin {
+			beeAdminApp.Route(path, f)
+		} else {
+			Handler(path, f)
+		}
+	}
+}
+
+func livez(rw http.ResponseWriter, req *http.Request) {
+	writeProbe(rw, req, true, nil)
+}
+
+func readyz(rw http.ResponseWriter, req *http.Request) {
+	ready, result := toolbox.CheckReadiness()
+	writeProbe(rw, req, ready, result)
+}
+
+func startupz(rw http.ResponseWriter, req *http.Request) {
+	result := map[string]string{"startup": "OK"}
+	if !toolbox.IsStarted() {
+		result["startup"] = "running"
+	}
+	writeProbe(rw, req, toolbox.IsStarted(), result)
+}
+
+// writeProbe writes the probe result, the checks are listed like
+// "[+]db ok" and "[-]cache failed: reason" when ok is false or the request
+// has the verbose query.
+func writeProbe(rw http.ResponseWriter, req *http.Request, ok bool, result map[string]string) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	_, verbose := req.URL.Query()["verbose"]
+	if ok {
+		rw.WriteHeader(http.StatusOK)
+	} else {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if !ok || verbose {
+		names := make([]string, 0, len(result))
+		for name := range result {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if result[name] == "OK" {
+				rw.Write([]byte("[+]" + name + " ok\n"))
+			} else {
+				rw.Write([]byte("[-]" + name + " failed: " + result[name] + "\n"))
+			}
+		}
+	}
+	if ok {
+		rw.Write([]byte("ok\n"))
+	} else {
+		rw.Write([]byte(req.URL.Path[1:] + " check failed\n"))
+	}
+}
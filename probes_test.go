@@ -0,0 +1,66 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aamsur/beego/toolbox"
+)
+
+func TestProbes(t *testing.T) {
+	probe := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		probes[r.URL.Path](w, r)
+		return w.Code, w.Body.String()
+	}
+
+	if code, body := probe("/livez"); code != 200 || body != "ok\n" {
+		t.Fatal("livez:", code, body)
+	}
+
+	toolbox.SetStarted(false)
+	if code, body := probe("/startupz"); code != 503 || body != "[-]startup failed: running\nstartupz check failed\n" {
+		t.Fatal("startupz while starting:", code, body)
+	}
+	toolbox.SetStarted(true)
+	if code, _ := probe("/startupz"); code != 200 {
+		t.Fatal("startupz once started:", code)
+	}
+
+	toolbox.AddReadinessCheck("db", toolbox.HealthCheckFunc(func() error { return nil }))
+	defer delete(toolbox.ReadinessCheckList, "db")
+	if code, body := probe("/readyz?verbose"); code != 200 || body != "[+]db ok\nok\n" {
+		t.Fatal("readyz:", code, body)
+	}
+	toolbox.SetDraining(true)
+	if code, body := probe("/readyz"); code != 503 || body != "[+]db ok\n[-]shutdown failed: draining\nreadyz check failed\n" {
+		t.Fatal("readyz while draining:", code, body)
+	}
+	toolbox.SetDraining(false)
+
+	toolbox.AddReadinessCheck("cache", toolbox.HealthCheckFunc(func() error { return errors.New("timeout") }))
+	defer delete(toolbox.ReadinessCheckList, "cache")
+	if code, _ := probe("/readyz"); code != 503 {
+		t.Fatal("readyz with a failed check:", code)
+	}
+	if code, _ := probe("/livez"); code != 200 {
+		t.Fatal("livez checks the dependencies:", code)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	beecontext "github.com/aamsur/beego/context"
+)
+
+// ProxyOptions are the settings of a proxy route.
+type ProxyOptions struct {
+	// StripPrefix is removed from the request path before it's joined to
+	// the path of the target.
+	StripPrefix string
+	// PreserveHost keeps the Host of the request instead of the target one.
+	PreserveHost bool
+	// RequestHeaders are set on the proxied requests and ResponseHeaders on
+	// the responses of the target, the empty ones are removed.
+	RequestHeaders  map[string]string
+	ResponseHeaders map[string]string
+	// DialTimeout limits the connection to the target, ResponseTimeout the
+	// wait for its response headers and Timeout the whole request, but for
+	// the websockets. 0 is no limit.
+	DialTimeout     time.Duration
+	ResponseTimeout time.Duration
+	Timeout         time.Duration
+	// Transport is used instead of the one made with the timeouts.
+	Transport http.RoundTripper
+}
+
+// Proxy adds a route of BeeApp forwarding the requests of pattern to the
+// target url, the websockets included. The X-Forwarded-For, -Host and
+// -Proto headers are set, a target failing answers 502, or 504 on timeout.
+// usage:
+//	beego.Proxy("/legacy/*", "http://10.0.0.2:8080/app", &beego.ProxyOptions{
+//		StripPrefix: "/legacy",
+//		Timeout:     30 * time.Second,
+//	})
+func Proxy(pattern, target string, opts *ProxyOptions, options ...RouteOption) *App {
+	BeeApp.Handlers.AddProxy(pattern, target, opts, options...)
+	return BeeApp
+}
+
+// AddProxy adds a proxy route, see Proxy. it panics if target is not a valid url.
+func (p *ControllerRegistor) AddProxy(pattern, target string, opts *ProxyOptions, options ...RouteOption) {
+	h, err := NewProxy(target, opts)
+	if err != nil {
+		panic("proxy " + pattern + ": " + err.Error())
+	}
+	args := make([]interface{}, len(options))
+	for i, o := range options {
+		args[i] = o
+	}
+	p.Handler(pattern, h, args...)
+}
+
+// NewProxy returns the handler of a proxy route to the target url, opts may be nil.
+func NewProxy(target string, opts *ProxyOptions) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, &url.Error{Op: "parse", URL: target, Err: errProxyTarget}
+	}
+	if opts == nil {
+		opts = &ProxyOptions{}
+	}
+	transport := opts.Transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if opts.DialTimeout > 0 {
+			t.DialContext = (&net.Dialer{Timeout: opts.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+		}
+		t.ResponseHeaderTimeout = opts.ResponseTimeout
+		transport = t
+	}
+	rp := &httputil.ReverseProxy{
+		Director:  proxyDirector(u, opts),
+		Transport: transport,
+		ErrorHandler: func(rw http.ResponseWriter, r *http.Request, err error) {
+			status := http.StatusBadGateway
+			if r.Context().Err() == context.DeadlineExceeded {
+				status = http.StatusGatewayTimeout
+			} else if e, ok := err.(net.Error); ok && e.Timeout() {
+				status = http.StatusGatewayTimeout
+			}
+			Warn("proxy:", r.URL.String(), err)
+			rw.WriteHeader(status)
+		},
+	}
+	if len(opts.ResponseHeaders) > 0 {
+		rp.ModifyResponse = func(resp *http.Response) error {
+			setHeaders(resp.Header, opts.ResponseHeaders)
+			return nil
+		}
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// the target tells its own server name
+		rw.Header().Del("Server")
+		if opts.Timeout > 0 && !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			ctx, cancel := context.WithTimeout(r.Context(), opts.Timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		rp.ServeHTTP(rw, r)
+	}), nil
+}
+
+var errProxyTarget = errors.New("the target must be an absolute http or https url")
+
+// proxyDirector rewrites the requests for the target u.
+func proxyDirector(u *url.URL, opts *ProxyOptions) func(*http.Request) {
+	return func(r *http.Request) {
+		escaped := r.URL.EscapedPath()
+		if opts.StripPrefix != "" {
+			escaped = strings.TrimPrefix(escaped, strings.TrimSuffix(opts.StripPrefix, "/"))
+		}
+		escaped = strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.TrimPrefix(escaped, "/")
+		if p, err := url.PathUnescape(escaped); err == nil {
+			r.URL.Path = p
+			r.URL.RawPath = escaped
+		}
+		r.URL.Scheme = u.Scheme
+		r.URL.Host = u.Host
+		if u.RawQuery != "" && r.URL.RawQuery != "" {
+			r.URL.RawQuery = u.RawQuery + "&" + r.URL.RawQuery
+		} else {
+			r.URL.RawQuery = u.RawQuery + r.URL.RawQuery
+		}
+		// the headers of the clients are kept only from a trusted proxy,
+		// the others could spoof them
+		trusted := beecontext.TrustedProxy(r.RemoteAddr)
+		if !trusted {
+			// the client address is appended by the reverse proxy
+			r.Header.Del("X-Forwarded-For")
+		}
+		if !trusted || r.Header.Get("X-Forwarded-Host") == "" {
+			r.Header.Set("X-Forwarded-Host", r.Host)
+		}
+		if !trusted || r.Header.Get("X-Forwarded-Proto") == "" {
+			proto := "http"
+			if r.TLS != nil {
+				proto = "https"
+			}
+			r.Header.Set("X-Forwarded-Proto", proto)
+		}
+		if !opts.PreserveHost {
+			r.Host = u.Host
+		}
+		setHeaders(r.Header, opts.RequestHeaders)
+		if _, ok := r.Header["User-Agent"]; !ok {
+			// not to get the default user agent of net/http
+			r.Header.Set("User-Agent", "")
+		}
+	}
+}
+
+// setHeaders sets the headers of h, removing the empty ones.
+func setHeaders(h http.Header, headers map[string]string) {
+	for k, v := range headers {
+		if v == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, v)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	beecontext "github.com/aamsur/beego/context"
+)
+
+func TestProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "legacy")
+		w.Header().Set("X-Powered-By", "legacy")
+		w.Write([]byte(r.Host + " " + r.URL.EscapedPath() + "?" + r.URL.Query().Encode() + " " + r.Header.Get("X-Forwarded-Host") +
+			" " + r.Header.Get("X-App") + " " + r.Header.Get("Cookie")))
+	}))
+	defer backend.Close()
+
+	handler := NewControllerRegister()
+	handler.AddProxy("/legacy/*", backend.URL+"/app?v=1", &ProxyOptions{
+		StripPrefix:     "/legacy",
+		RequestHeaders:  map[string]string{"X-App": "beego", "Cookie": ""},
+		ResponseHeaders: map[string]string{"X-Powered-By": ""},
+	})
+	r, _ := http.NewRequest("POST", "/legacy/users/a%2Fb?q=2", nil)
+	r.Host = "example.com"
+	r.Header.Set("Cookie", "session=1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	host := strings.TrimPrefix(backend.URL, "http://")
+	if want := host + " /app/users/a%2Fb?q=2&v=1 example.com beego "; w.Body.String() != want {
+		t.Errorf("got %q, want %q", w.Body.String(), want)
+	}
+	if w.HeaderMap.Get("Server") != "legacy" || w.HeaderMap.Get("X-Powered-By") != "" {
+		t.Errorf("wrong response headers: %v", w.HeaderMap)
+	}
+
+	// X-Forwarded-Host is kept only from a trusted proxy
+	r.Header.Set("X-Forwarded-Host", "spoofed.com")
+	r.RemoteAddr = "192.0.2.1:1234"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if want := host + " /app/users/a%2Fb?q=2&v=1 example.com beego "; w.Body.String() != want {
+		t.Errorf("spoofed header: got %q, want %q", w.Body.String(), want)
+	}
+	beecontext.SetTrustedProxies([]string{"192.0.2.1"})
+	defer beecontext.SetTrustedProxies(nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if want := host + " /app/users/a%2Fb?q=2&v=1 spoofed.com beego "; w.Body.String() != want {
+		t.Errorf("trusted proxy: got %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestProxyForwardedFor(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-For")))
+	}))
+	defer backend.Close()
+
+	handler := NewControllerRegister()
+	handler.AddProxy("/api/*", backend.URL, nil)
+	r, _ := http.NewRequest("GET", "/api/users", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "192.0.2.1" {
+		t.Errorf("a forged X-Forwarded-For should be dropped, got %q", w.Body.String())
+	}
+
+	beecontext.SetTrustedProxies([]string{"192.0.2.1"})
+	defer beecontext.SetTrustedProxies(nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "10.0.0.1, 192.0.2.1" {
+		t.Errorf("the chain of a trusted proxy should be kept, got %q", w.Body.String())
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer backend.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	handler := NewControllerRegister()
+	handler.AddProxy("/slow/*", backend.URL, &ProxyOptions{Timeout: 20 * time.Millisecond})
+	handler.AddProxy("/down/*", closed.URL, nil)
+	if code, _ := serveStatus(handler, "GET", "/slow/a"); code != http.StatusGatewayTimeout {
+		t.Errorf("slow target: got %d, want 504", code)
+	}
+	if code, _ := serveStatus(handler, "GET", "/down/a"); code != http.StatusBadGateway {
+		t.Errorf("down target: got %d, want 502", code)
+	}
+	if _, err := NewProxy("/relative", nil); err == nil {
+		t.Error("a relative target should fail")
+	}
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+		line, _ := buf.ReadString('\n')
+		buf.WriteString("echo " + line)
+		buf.Flush()
+	}))
+	defer backend.Close()
+
+	handler := NewControllerRegister()
+	handler.AddProxy("/ws", backend.URL, &ProxyOptions{Timeout: time.Second})
+	front := httptest.NewServer(handler)
+	defer front.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(front.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(resp.Body)
+		t.Fatalf("got %d %s, want 101", resp.StatusCode, body)
+	}
+	conn.Write([]byte("hello\n"))
+	if line, _ := br.ReadString('\n'); line != "echo hello\n" {
+		t.Errorf("got %q through the websocket", line)
+	}
+}
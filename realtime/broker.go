@@ -0,0 +1,291 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventStore keeps the recent events of the topics, for the clients
+// reconnecting with a Last-Event-ID to get the events they missed.
+type EventStore interface {
+	// Append stores e in topic and sets its id. the ids increase across the
+	// topics, compared as numbers or as the ms-seq ids of redis streams.
+	Append(topic string, e *Event) error
+	// Since returns the events of topic after the id, oldest first.
+	Since(topic, id string) ([]*Event, error)
+}
+
+// MemoryEventStore keeps the last Size events of every topic, for a single
+// instance.
+type MemoryEventStore struct {
+	Size int
+
+	lock   sync.Mutex
+	seq    uint64
+	topics map[string][]*Event
+}
+
+// NewMemoryEventStore creates a store keeping the last size events of the topics.
+func NewMemoryEventStore(size int) *MemoryEventStore {
+	return &MemoryEventStore{Size: size, topics: make(map[string][]*Event)}
+}
+
+// Append stores e and sets its id to the next number.
+func (m *MemoryEventStore) Append(topic string, e *Event) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.seq++
+	e.ID = strconv.FormatUint(m.seq, 10)
+	events := append(m.topics[topic], e)
+	if len(events) > m.Size {
+		events = append([]*Event(nil), events[len(events)-m.Size:]...)
+	}
+	m.topics[topic] = events
+	return nil
+}
+
+// Since returns the events of topic kept after the id.
+func (m *MemoryEventStore) Since(topic, id string) ([]*Event, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var events []*Event
+	for _, e := range m.topics[topic] {
+		if compareEventIDs(e.ID, id) > 0 {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// compareEventIDs compares the ids made of numbers separated by dashes.
+func compareEventIDs(a, b string) int {
+	as, bs := strings.Split(a, "-"), strings.Split(b, "-")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y uint64
+		if i < len(as) {
+			x, _ = strconv.ParseUint(as[i], 10, 64)
+		}
+		if i < len(bs) {
+			y, _ = strconv.ParseUint(bs[i], 10, 64)
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Broker streams the events published on topics to the clients subscribed,
+// with server-sent events. the events go through its backend to reach the
+// clients of every instance, a broker and a hub need their own backend.
+//
+//	broker, err := realtime.NewBroker(backend, realtime.NewMemoryEventStore(100))
+//	beego.Handler("/events", broker)
+//
+//	// the clients of /events?topic=news get it
+//	broker.Publish("news", &realtime.Event{Event: "article", Data: `{"id":1}`})
+type Broker struct {
+	// Topics returns the topics a request subscribes to, by default its
+	// topic query values. no topic refuses the request.
+	Topics func(r *http.Request) []string
+	// KeepAlive is the period of the comments keeping the connections open.
+	KeepAlive time.Duration
+	// Retry is the reconnection delay sent to the clients, in milliseconds.
+	Retry int
+	// Buffer is the number of events queued for a client, a client too slow
+	// to read them is disconnected and replays them when it reconnects.
+	Buffer int
+
+	backend Backend
+	store   EventStore
+
+	lock    sync.RWMutex
+	clients map[string]map[*sseClient]bool // by topic
+}
+
+type sseClient struct {
+	events  chan *Event
+	slow    chan struct{}
+	slowOne sync.Once
+}
+
+// published is an event published to the brokers.
+type published struct {
+	Topic string `json:"topic"`
+	Event *Event `json:"event"`
+}
+
+// NewBroker creates a broker subscribed to backend. the store may be nil,
+// the clients then don't get the events they missed.
+func NewBroker(backend Backend, store EventStore) (*Broker, error) {
+	b := &Broker{
+		KeepAlive: 15 * time.Second,
+		Buffer:    64,
+		backend:   backend,
+		store:     store,
+		clients:   make(map[string]map[*sseClient]bool),
+	}
+	if err := backend.Subscribe(b.deliver); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Publish stores the event, setting its id, and sends it to the clients
+// subscribed to topic on every instance. it may be called from anywhere.
+func (b *Broker) Publish(topic string, e *Event) error {
+	if b.store != nil {
+		if err := b.store.Append(topic, e); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(&published{Topic: topic, Event: e})
+	if err != nil {
+		return err
+	}
+	return b.backend.Publish(data)
+}
+
+func (b *Broker) deliver(data []byte) {
+	var p published
+	if json.Unmarshal(data, &p) != nil || p.Event == nil {
+		return
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for c := range b.clients[p.Topic] {
+		select {
+		case c.events <- p.Event:
+		default:
+			c.slowOne.Do(func() { close(c.slow) })
+		}
+	}
+}
+
+// ServeHTTP streams the events of the topics of the request, after the ones
+// missed since its Last-Event-ID.
+func (b *Broker) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var topics []string
+	if b.Topics != nil {
+		topics = b.Topics(r)
+	} else {
+		topics = r.URL.Query()["topic"]
+	}
+	if len(topics) == 0 {
+		http.Error(rw, "no topic", http.StatusBadRequest)
+		return
+	}
+	w, err := NewSSEWriter(rw)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// subscribed before the replay, so no event is lost in between
+	c := &sseClient{events: make(chan *Event, b.Buffer), slow: make(chan struct{})}
+	b.lock.Lock()
+	for _, topic := range topics {
+		if b.clients[topic] == nil {
+			b.clients[topic] = make(map[*sseClient]bool)
+		}
+		b.clients[topic][c] = true
+	}
+	b.lock.Unlock()
+	defer func() {
+		b.lock.Lock()
+		for _, topic := range topics {
+			delete(b.clients[topic], c)
+			if len(b.clients[topic]) == 0 {
+				delete(b.clients, topic)
+			}
+		}
+		b.lock.Unlock()
+	}()
+
+	if b.Retry > 0 {
+		if w.WriteEvent(&Event{Retry: b.Retry}) != nil {
+			return
+		}
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	if lastID != "" && b.store != nil {
+		missed := b.replay(topics, lastID)
+		for _, e := range missed {
+			if w.WriteEvent(e) != nil {
+				return
+			}
+		}
+		if len(missed) > 0 {
+			lastID = missed[len(missed)-1].ID
+		}
+	} else {
+		lastID = ""
+	}
+
+	var keepAlive <-chan time.Time
+	if b.KeepAlive > 0 {
+		ticker := time.NewTicker(b.KeepAlive)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+	closed := r.Context().Done()
+	for {
+		select {
+		case e := <-c.events:
+			// the events replayed may be delivered again
+			if lastID != "" && e.ID != "" && compareEventIDs(e.ID, lastID) <= 0 {
+				continue
+			}
+			err = w.WriteEvent(e)
+		case <-keepAlive:
+			err = w.WriteComment("keep-alive")
+		case <-c.slow:
+			return
+		case <-closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// replay returns the events of the topics after lastID, ordered by id.
+func (b *Broker) replay(topics []string, lastID string) []*Event {
+	var missed []*Event
+	for _, topic := range topics {
+		events, err := b.store.Since(topic, lastID)
+		if err == nil {
+			missed = append(missed, events...)
+		}
+	}
+	sort.SliceStable(missed, func(i, j int) bool {
+		return compareEventIDs(missed[i].ID, missed[j].ID) < 0
+	})
+	return missed
+}
@@ -0,0 +1,108 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEWriter(t *testing.T) {
+	rw := httptest.NewRecorder()
+	w, err := NewSSEWriter(rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteEvent(&Event{ID: "1", Event: "news\nid: 9", Data: "a\nb"})
+	w.WriteEvent(&Event{Retry: 3000})
+	w.WriteComment("ping")
+	want := "id: 1\nevent: newsid: 9\ndata: a\ndata: b\n\nretry: 3000\n\n: ping\n\n"
+	if rw.Body.String() != want {
+		t.Errorf("got %q, want %q", rw.Body.String(), want)
+	}
+	if ct := rw.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("the content type should be text/event-stream, got %s", ct)
+	}
+}
+
+// readEvents reads the data of n events of the stream.
+func readEvents(t *testing.T, r *bufio.Reader, n int) []string {
+	var events []string
+	for len(events) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			events = append(events, strings.TrimSpace(line[6:]))
+		}
+	}
+	return events
+}
+
+func TestBroker(t *testing.T) {
+	backend, _ := NewBackend("memory", "")
+	broker, err := NewBroker(backend, NewMemoryEventStore(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(broker)
+	defer s.Close()
+
+	for _, data := range []string{"one", "two", "three"} {
+		broker.Publish("news", &Event{Data: data})
+	}
+	broker.Publish("sport", &Event{Data: "goal"})
+
+	// the store kept the last 2 events of news, the client missed the ones after 1
+	req, _ := http.NewRequest("GET", s.URL+"?topic=news", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	if got := readEvents(t, r, 2); strings.Join(got, ",") != "two,three" {
+		t.Errorf("the missed events should be replayed, got %v", got)
+	}
+
+	done := make(chan []string)
+	go func() { done <- readEvents(t, r, 1) }()
+	// the client is subscribed once the replay is read
+	broker.Publish("sport", &Event{Data: "not news"})
+	broker.Publish("news", &Event{Data: "four"})
+	select {
+	case got := <-done:
+		if got[0] != "four" {
+			t.Errorf("the client should get the events of its topic, got %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the published event was not received")
+	}
+
+	resp, err = http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("a request without topic should get 400, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Hub tracks the connections of an instance and the rooms they joined.
+// the broadcasts go through the backend to reach the members of the other
+// instances.
+type Hub struct {
+	backend Backend
+
+	lock   sync.RWMutex
+	conns  map[string]Conn
+	rooms  map[string]map[string]Conn // the local members of the rooms
+	joined map[string]map[string]bool // the rooms of the local connections
+}
+
+// envelope is a message published to the hubs.
+type envelope struct {
+	Room   string   `json:"room,omitempty"`
+	To     string   `json:"to,omitempty"`
+	Except []string `json:"except,omitempty"`
+	Type   int      `json:"type"`
+	Data   []byte   `json:"data"`
+}
+
+// NewHub creates a hub subscribed to backend.
+func NewHub(backend Backend) (*Hub, error) {
+	h := &Hub{
+		backend: backend,
+		conns:   make(map[string]Conn),
+		rooms:   make(map[string]map[string]Conn),
+		joined:  make(map[string]map[string]bool),
+	}
+	if err := backend.Subscribe(h.deliver); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Register adds a connection to the hub, so it gets the broadcasts to every
+// connection and its direct messages.
+func (h *Hub) Register(c Conn) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.conns[c.ID()] = c
+}
+
+// Unregister removes a connection from the hub and the rooms it joined.
+func (h *Hub) Unregister(c Conn) error {
+	h.lock.Lock()
+	id := c.ID()
+	rooms := h.joined[id]
+	for room := range rooms {
+		h.removeMember(room, id)
+	}
+	delete(h.joined, id)
+	delete(h.conns, id)
+	h.lock.Unlock()
+
+	var err error
+	for room := range rooms {
+		if e := h.backend.Leave(room, id); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Join registers the connection and adds it to the members of room.
+func (h *Hub) Join(room string, c Conn) error {
+	h.lock.Lock()
+	id := c.ID()
+	h.conns[id] = c
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[string]Conn)
+	}
+	h.rooms[room][id] = c
+	if h.joined[id] == nil {
+		h.joined[id] = make(map[string]bool)
+	}
+	h.joined[id][room] = true
+	h.lock.Unlock()
+	return h.backend.Join(room, id)
+}
+
+// Leave removes the connection from the members of room.
+func (h *Hub) Leave(room string, c Conn) error {
+	h.lock.Lock()
+	id := c.ID()
+	h.removeMember(room, id)
+	delete(h.joined[id], room)
+	h.lock.Unlock()
+	return h.backend.Leave(room, id)
+}
+
+func (h *Hub) removeMember(room, id string) {
+	delete(h.rooms[room], id)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// Members returns the ids of the members of room, on every instance.
+func (h *Hub) Members(room string) ([]string, error) {
+	return h.backend.Members(room)
+}
+
+// Rooms returns the rooms the connection joined, sorted.
+func (h *Hub) Rooms(c Conn) []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	rooms := make([]string, 0, len(h.joined[c.ID()]))
+	for room := range h.joined[c.ID()] {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return rooms
+}
+
+// Broadcast sends a message to the members of room on every instance, but the
+// connections of the except ids. the empty room is every connection.
+func (h *Hub) Broadcast(room string, messageType int, data []byte, except ...string) error {
+	return h.publish(&envelope{Room: room, Except: except, Type: messageType, Data: data})
+}
+
+// Send sends a message to the connection of id, on any instance.
+func (h *Hub) Send(id string, messageType int, data []byte) error {
+	h.lock.RLock()
+	c, ok := h.conns[id]
+	h.lock.RUnlock()
+	if ok {
+		return c.Send(messageType, data)
+	}
+	return h.publish(&envelope{To: id, Type: messageType, Data: data})
+}
+
+func (h *Hub) publish(e *envelope) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return h.backend.Publish(data)
+}
+
+// deliver sends a message published to its local recipients. a connection
+// failing to queue it, like a slow client, misses it.
+func (h *Hub) deliver(data []byte) {
+	var e envelope
+	if json.Unmarshal(data, &e) != nil {
+		return
+	}
+	var recipients []Conn
+	h.lock.RLock()
+	switch {
+	case e.To != "":
+		if c, ok := h.conns[e.To]; ok {
+			recipients = append(recipients, c)
+		}
+	case e.Room != "":
+		for _, c := range h.rooms[e.Room] {
+			recipients = append(recipients, c)
+		}
+	default:
+		for _, c := range h.conns {
+			recipients = append(recipients, c)
+		}
+	}
+	h.lock.RUnlock()
+
+	for _, c := range recipients {
+		if !excepted(e.Except, c.ID()) {
+			c.Send(e.Type, e.Data)
+		}
+	}
+}
+
+func excepted(except []string, id string) bool {
+	for _, e := range except {
+		if e == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Close unsubscribes the hub by closing its backend.
+func (h *Hub) Close() error {
+	return h.backend.Close()
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type testConn struct {
+	id       string
+	lock     sync.Mutex
+	messages []string
+}
+
+func (c *testConn) ID() string {
+	return c.id
+}
+
+func (c *testConn) Send(messageType int, data []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.messages = append(c.messages, string(data))
+	return nil
+}
+
+func (c *testConn) received() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.messages
+}
+
+func TestHub(t *testing.T) {
+	backend, err := NewBackend("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two hubs on a backend are two instances of an app
+	first, _ := NewHub(backend)
+	second, _ := NewHub(backend)
+	a, b, c := &testConn{id: "a"}, &testConn{id: "b"}, &testConn{id: "c"}
+	first.Join("lobby", a)
+	second.Join("lobby", b)
+	second.Register(c)
+
+	if members, _ := first.Members("lobby"); !reflect.DeepEqual(members, []string{"a", "b"}) {
+		t.Errorf("the members should be on both instances, got %v", members)
+	}
+	first.Broadcast("lobby", 1, []byte("hello"))
+	first.Broadcast("lobby", 1, []byte("not to a"), "a")
+	first.Broadcast("", 1, []byte("everyone"))
+	first.Send("c", 1, []byte("direct"))
+
+	if got := a.received(); !reflect.DeepEqual(got, []string{"hello", "everyone"}) {
+		t.Errorf("a got %v", got)
+	}
+	if got := b.received(); !reflect.DeepEqual(got, []string{"hello", "not to a", "everyone"}) {
+		t.Errorf("b got %v", got)
+	}
+	if got := c.received(); !reflect.DeepEqual(got, []string{"everyone", "direct"}) {
+		t.Errorf("c got %v", got)
+	}
+
+	if rooms := second.Rooms(b); !reflect.DeepEqual(rooms, []string{"lobby"}) {
+		t.Errorf("b should be in the lobby, got %v", rooms)
+	}
+	second.Unregister(b)
+	if members, _ := first.Members("lobby"); !reflect.DeepEqual(members, []string{"a"}) {
+		t.Errorf("b should leave the lobby when unregistered, got %v", members)
+	}
+	first.Broadcast("lobby", 1, []byte("after"))
+	if got := b.received(); len(got) != 3 {
+		t.Errorf("b should not get the messages once unregistered, got %v", got)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryBackend is the backend of the hubs of a single instance.
+type MemoryBackend struct {
+	lock        sync.RWMutex
+	subscribers []func(data []byte)
+	rooms       map[string]map[string]bool
+}
+
+// NewMemoryBackend creates a memory backend.
+func NewMemoryBackend() Backend {
+	return &MemoryBackend{rooms: make(map[string]map[string]bool)}
+}
+
+// Start does nothing, the memory backend has no config.
+func (m *MemoryBackend) Start(config string) error {
+	return nil
+}
+
+// Publish calls the subscribers with data.
+func (m *MemoryBackend) Publish(data []byte) error {
+	m.lock.RLock()
+	subscribers := m.subscribers
+	m.lock.RUnlock()
+	for _, fn := range subscribers {
+		fn(data)
+	}
+	return nil
+}
+
+// Subscribe adds fn to the subscribers.
+func (m *MemoryBackend) Subscribe(fn func(data []byte)) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.subscribers = append(m.subscribers[:len(m.subscribers):len(m.subscribers)], fn)
+	return nil
+}
+
+// Join adds id to the members of room.
+func (m *MemoryBackend) Join(room, id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.rooms[room] == nil {
+		m.rooms[room] = make(map[string]bool)
+	}
+	m.rooms[room][id] = true
+	return nil
+}
+
+// Leave removes id from the members of room.
+func (m *MemoryBackend) Leave(room, id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.rooms[room], id)
+	if len(m.rooms[room]) == 0 {
+		delete(m.rooms, room)
+	}
+	return nil
+}
+
+// Members returns the ids of the members of room, sorted.
+func (m *MemoryBackend) Members(room string) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ids := make([]string, 0, len(m.rooms[room]))
+	for id := range m.rooms[room] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Close removes the subscribers.
+func (m *MemoryBackend) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.subscribers = nil
+	return nil
+}
+
+func init() {
+	Register("memory", NewMemoryBackend)
+}
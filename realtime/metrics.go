@@ -0,0 +1,81 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Metrics counts the messages of the send queues.
+type Metrics struct {
+	queued       int64
+	dropped      int64
+	disconnected int64
+
+	lock   sync.Mutex
+	queues map[*SendQueue]bool
+}
+
+// DefaultMetrics are the metrics of the send queues of the app.
+var DefaultMetrics = &Metrics{queues: make(map[*SendQueue]bool)}
+
+// MetricsSnapshot are the metrics at a time.
+type MetricsSnapshot struct {
+	// Connections is the number of the send queues open.
+	Connections int `json:"connections"`
+	// Queued is the number of messages queued since the start.
+	Queued int64 `json:"queued"`
+	// Dropped is the number of messages dropped by the overflow policies.
+	Dropped int64 `json:"dropped"`
+	// Disconnected is the number of clients disconnected for being too slow.
+	Disconnected int64 `json:"disconnected"`
+	// Pending is the number of messages waiting in the queues.
+	Pending int `json:"pending"`
+	// MaxDepth is the number of messages of the fullest queue.
+	MaxDepth int `json:"max_depth"`
+}
+
+func (m *Metrics) add(q *SendQueue) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.queues[q] = true
+}
+
+func (m *Metrics) remove(q *SendQueue) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.queues, q)
+}
+
+// Snapshot returns the current metrics.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Queued:       atomic.LoadInt64(&m.queued),
+		Dropped:      atomic.LoadInt64(&m.dropped),
+		Disconnected: atomic.LoadInt64(&m.disconnected),
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s.Connections = len(m.queues)
+	for q := range m.queues {
+		depth := q.Len()
+		s.Pending += depth
+		if depth > s.MaxDepth {
+			s.MaxDepth = depth
+		}
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Overflow is what a send queue does with a message when it's full.
+type Overflow int
+
+const (
+	// OverflowDrop drops the message, Send returns ErrQueueFull.
+	OverflowDrop Overflow = iota
+	// OverflowDropOldest drops the oldest message queued to make room.
+	OverflowDropOldest
+	// OverflowDisconnect drops the message and disconnects the client.
+	OverflowDisconnect
+)
+
+// ParseOverflow returns the policy of a name: drop, dropoldest or disconnect.
+func ParseOverflow(name string) (Overflow, bool) {
+	switch name {
+	case "drop":
+		return OverflowDrop, true
+	case "dropoldest":
+		return OverflowDropOldest, true
+	case "disconnect":
+		return OverflowDisconnect, true
+	}
+	return OverflowDrop, false
+}
+
+// Message is a text or binary message of a connection.
+type Message struct {
+	Type int
+	Data []byte
+}
+
+// SendQueue is the bounded queue of the messages of a connection, emptied by
+// its writer. a slow client fills its queue without blocking the senders.
+type SendQueue struct {
+	overflow   Overflow
+	c          chan Message
+	overflowed chan struct{}
+	once       sync.Once
+}
+
+// NewSendQueue creates a queue of size messages, counted in DefaultMetrics
+// until it's closed.
+func NewSendQueue(size int, overflow Overflow) *SendQueue {
+	q := &SendQueue{
+		overflow:   overflow,
+		c:          make(chan Message, size),
+		overflowed: make(chan struct{}),
+	}
+	DefaultMetrics.add(q)
+	return q
+}
+
+// Push queues m, or applies the overflow policy when the queue is full.
+func (q *SendQueue) Push(m Message) error {
+	for {
+		select {
+		case q.c <- m:
+			atomic.AddInt64(&DefaultMetrics.queued, 1)
+			return nil
+		default:
+		}
+		if q.overflow == OverflowDropOldest {
+			// the writer may take it first, m is queued at the next turn
+			select {
+			case <-q.c:
+				atomic.AddInt64(&DefaultMetrics.dropped, 1)
+			default:
+			}
+			continue
+		}
+		atomic.AddInt64(&DefaultMetrics.dropped, 1)
+		if q.overflow == OverflowDisconnect {
+			q.once.Do(func() {
+				atomic.AddInt64(&DefaultMetrics.disconnected, 1)
+				close(q.overflowed)
+			})
+		}
+		return ErrQueueFull
+	}
+}
+
+// C returns the channel of the messages queued.
+func (q *SendQueue) C() <-chan Message {
+	return q.c
+}
+
+// Len returns the number of messages queued.
+func (q *SendQueue) Len() int {
+	return len(q.c)
+}
+
+// Overflowed returns a channel closed when the queue overflowed with the
+// OverflowDisconnect policy, the writer then disconnects the client.
+func (q *SendQueue) Overflowed() <-chan struct{} {
+	return q.overflowed
+}
+
+// Close stops counting the queue in the metrics.
+func (q *SendQueue) Close() {
+	DefaultMetrics.remove(q)
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"testing"
+)
+
+func TestSendQueue(t *testing.T) {
+	before := DefaultMetrics.Snapshot()
+
+	drop := NewSendQueue(2, OverflowDrop)
+	defer drop.Close()
+	for _, data := range []string{"a", "b", "c"} {
+		drop.Push(Message{Type: 1, Data: []byte(data)})
+	}
+	if m := <-drop.C(); string(m.Data) != "a" || drop.Len() != 1 {
+		t.Errorf("drop should keep the first messages, got %s and %d queued", m.Data, drop.Len())
+	}
+
+	oldest := NewSendQueue(2, OverflowDropOldest)
+	defer oldest.Close()
+	for _, data := range []string{"a", "b", "c"} {
+		if err := oldest.Push(Message{Type: 1, Data: []byte(data)}); err != nil {
+			t.Errorf("dropoldest should queue every message, got %v", err)
+		}
+	}
+	if m := <-oldest.C(); string(m.Data) != "b" {
+		t.Errorf("dropoldest should drop the oldest message, got %s", m.Data)
+	}
+
+	disconnect := NewSendQueue(1, OverflowDisconnect)
+	disconnect.Push(Message{Type: 1, Data: []byte("a")})
+	if err := disconnect.Push(Message{Type: 1, Data: []byte("b")}); err != ErrQueueFull {
+		t.Errorf("a full queue should return ErrQueueFull, got %v", err)
+	}
+	select {
+	case <-disconnect.Overflowed():
+	default:
+		t.Error("disconnect should signal the overflow")
+	}
+
+	after := DefaultMetrics.Snapshot()
+	if after.Connections-before.Connections != 3 || after.Dropped-before.Dropped != 3 ||
+		after.Disconnected-before.Disconnected != 1 || after.MaxDepth < 1 {
+		t.Errorf("the metrics should count the queues and the drops, got %+v", after)
+	}
+	disconnect.Close()
+	if n := DefaultMetrics.Snapshot().Connections; n != after.Connections-1 {
+		t.Errorf("a closed queue should not be counted, got %d", n)
+	}
+}
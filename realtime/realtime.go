@@ -0,0 +1,103 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package realtime sends messages to the connections of rooms, across the
+// instances of an app sharing a pub/sub backend.
+//
+// usage:
+//
+//	backend, err := realtime.NewBackend("memory", "")
+//	hub, err := realtime.NewHub(backend)
+//
+//	type ChatController struct {
+//		beego.WebSocketController
+//	}
+//
+//	func (c *ChatController) OnOpen() {
+//		hub.Join("lobby", c)
+//	}
+//
+//	func (c *ChatController) OnMessage(messageType int, data []byte) {
+//		hub.Broadcast("lobby", messageType, data, c.ID())
+//	}
+//
+//	func (c *ChatController) OnClose(code int, text string) {
+//		hub.Unregister(c)
+//	}
+//
+// the redis backend, imported with _ "github.com/aamsur/beego/realtime/redis",
+// shares the broadcasts and the presence between the instances:
+//
+//	backend, err := realtime.NewBackend("redis", `{"conn":"127.0.0.1:6379"}`)
+package realtime
+
+import (
+	"fmt"
+)
+
+// Conn is a connection of a hub, like a beego.WebSocketController.
+type Conn interface {
+	// ID returns the id of the connection, unique across the instances.
+	ID() string
+	// Send queues a message for the client.
+	Send(messageType int, data []byte) error
+}
+
+// Backend carries the messages and the presence of the hubs of every instance.
+type Backend interface {
+	// Start starts the backend with its json config.
+	Start(config string) error
+	// Publish sends data to the subscribers of every instance.
+	Publish(data []byte) error
+	// Subscribe calls fn with the data published.
+	Subscribe(fn func(data []byte)) error
+	// Join adds id to the members of room.
+	Join(room, id string) error
+	// Leave removes id from the members of room.
+	Leave(room, id string) error
+	// Members returns the ids of the members of room.
+	Members(room string) ([]string, error)
+	// Close stops the backend.
+	Close() error
+}
+
+type backendType func() Backend
+
+var adapters = make(map[string]backendType)
+
+// Register makes a backend available by its name.
+// If Register is called twice with the same name or if backend is nil,
+// it panics.
+func Register(name string, backend func() Backend) {
+	if backend == nil {
+		panic("realtime: Register backend is nil")
+	}
+	if _, dup := adapters[name]; dup {
+		panic("realtime: Register called twice for backend " + name)
+	}
+	adapters[name] = backend
+}
+
+// NewBackend creates and starts a backend by its name and json config.
+func NewBackend(name, config string) (Backend, error) {
+	newBackend, ok := adapters[name]
+	if !ok {
+		return nil, fmt.Errorf("realtime: unknown backend %q (forgot to import?)", name)
+	}
+	backend := newBackend()
+	if err := backend.Start(config); err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
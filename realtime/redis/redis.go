@@ -0,0 +1,216 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis is the redis backend of the realtime hubs, so the broadcasts
+// and the presence are shared by the instances of an app.
+//
+// depend on github.com/garyburd/redigo/redis
+//
+// Usage:
+// import(
+//
+//	_ "github.com/aamsur/beego/realtime/redis"
+//	"github.com/aamsur/beego/realtime"
+//
+// )
+//
+//	backend, err := realtime.NewBackend("redis", `{"conn":"127.0.0.1:6379","channel":"chat"}`)
+//	hub, err := realtime.NewHub(backend)
+//
+// the members of a room are kept in a redis set, the members of an instance
+// stopped without leaving stay until it is cleared.
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/aamsur/beego/realtime"
+)
+
+var (
+	// the channel the messages are published on, and the prefix of the
+	// presence keys.
+	DefaultChannel string = "beegoRealtime"
+)
+
+// Backend is the redis realtime backend.
+type Backend struct {
+	p        *redis.Pool
+	conninfo string
+	password string
+	dbNum    int
+	channel  string
+
+	lock   sync.Mutex
+	psc    *redis.PubSubConn
+	closed bool
+}
+
+// NewBackend creates a redis backend.
+func NewBackend() realtime.Backend {
+	return &Backend{channel: DefaultChannel}
+}
+
+// Start connects to redis.
+// config is like {"conn":"127.0.0.1:6379","password":"","dbNum":"0","channel":"beegoRealtime"}
+func (b *Backend) Start(config string) error {
+	var cf map[string]string
+	if err := json.Unmarshal([]byte(config), &cf); err != nil {
+		return err
+	}
+	if _, ok := cf["conn"]; !ok {
+		return errors.New("config has no conn key")
+	}
+	if cf["channel"] != "" {
+		b.channel = cf["channel"]
+	}
+	b.conninfo = cf["conn"]
+	b.password = cf["password"]
+	b.dbNum, _ = strconv.Atoi(cf["dbNum"])
+	b.p = &redis.Pool{
+		MaxIdle:     3,
+		IdleTimeout: 180 * time.Second,
+		Dial:        b.dial,
+	}
+	c := b.p.Get()
+	defer c.Close()
+	return c.Err()
+}
+
+func (b *Backend) dial() (redis.Conn, error) {
+	c, err := redis.Dial("tcp", b.conninfo)
+	if err != nil {
+		return nil, err
+	}
+	if b.password != "" {
+		if _, err := c.Do("AUTH", b.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if _, err := c.Do("SELECT", b.dbNum); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (b *Backend) do(commandName string, args ...interface{}) (interface{}, error) {
+	c := b.p.Get()
+	defer c.Close()
+	return c.Do(commandName, args...)
+}
+
+// Publish publishes data on the channel.
+func (b *Backend) Publish(data []byte) error {
+	_, err := b.do("PUBLISH", b.channel, data)
+	return err
+}
+
+// Subscribe calls fn with the messages of the channel, from a goroutine
+// reconnecting when the connection is lost.
+func (b *Backend) Subscribe(fn func(data []byte)) error {
+	psc, err := b.subscribe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				fn(v.Data)
+			case error:
+				psc.Close()
+				for {
+					b.lock.Lock()
+					closed := b.closed
+					b.lock.Unlock()
+					if closed {
+						return
+					}
+					if psc, err = b.subscribe(); err == nil {
+						break
+					}
+					time.Sleep(time.Second)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *Backend) subscribe() (*redis.PubSubConn, error) {
+	c, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	psc := &redis.PubSubConn{Conn: c}
+	if err := psc.Subscribe(b.channel); err != nil {
+		c.Close()
+		return nil, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		c.Close()
+		return nil, errors.New("realtime: backend closed")
+	}
+	b.psc = psc
+	return psc, nil
+}
+
+func (b *Backend) roomKey(room string) string {
+	return b.channel + ":room:" + room
+}
+
+// Join adds id to the set of the members of room.
+func (b *Backend) Join(room, id string) error {
+	_, err := b.do("SADD", b.roomKey(room), id)
+	return err
+}
+
+// Leave removes id from the set of the members of room.
+func (b *Backend) Leave(room, id string) error {
+	_, err := b.do("SREM", b.roomKey(room), id)
+	return err
+}
+
+// Members returns the members of room, sorted.
+func (b *Backend) Members(room string) ([]string, error) {
+	ids, err := redis.Strings(b.do("SMEMBERS", b.roomKey(room)))
+	sort.Strings(ids)
+	return ids, err
+}
+
+// Close stops the subscription and closes the connections.
+func (b *Backend) Close() error {
+	b.lock.Lock()
+	b.closed = true
+	if b.psc != nil {
+		b.psc.Close()
+	}
+	b.lock.Unlock()
+	return b.p.Close()
+}
+
+func init() {
+	realtime.Register("redis", NewBackend)
+}
@@ -0,0 +1,103 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+
+	"github.com/aamsur/beego/realtime"
+)
+
+// EventStore keeps the events of the topics in redis streams, so the clients
+// replay the events they missed on any instance.
+type EventStore struct {
+	b      *Backend
+	maxLen int
+}
+
+// NewEventStore creates a store of the last events of the topics.
+// config is the one of the backend, its maxLen is the number of events kept
+// by topic, 1000 by default.
+//
+//	store, err := redis.NewEventStore(`{"conn":"127.0.0.1:6379","maxLen":"100"}`)
+//	broker, err := realtime.NewBroker(backend, store)
+func NewEventStore(config string) (*EventStore, error) {
+	b := &Backend{channel: DefaultChannel}
+	if err := b.Start(config); err != nil {
+		return nil, err
+	}
+	var cf map[string]string
+	json.Unmarshal([]byte(config), &cf)
+	s := &EventStore{b: b, maxLen: 1000}
+	if n, err := strconv.Atoi(cf["maxLen"]); err == nil && n > 0 {
+		s.maxLen = n
+	}
+	return s, nil
+}
+
+func (s *EventStore) streamKey(topic string) string {
+	return s.b.channel + ":events:" + topic
+}
+
+// Append adds e to the stream of topic and sets its id to the stream id.
+func (s *EventStore) Append(topic string, e *realtime.Event) error {
+	id, err := redis.String(s.b.do("XADD", s.streamKey(topic), "MAXLEN", "~", s.maxLen, "*",
+		"event", e.Event, "data", e.Data))
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return nil
+}
+
+// Since reads the events of the stream of topic after the id.
+func (s *EventStore) Since(topic, id string) ([]*realtime.Event, error) {
+	streams, err := redis.Values(s.b.do("XREAD", "COUNT", s.maxLen, "STREAMS", s.streamKey(topic), id))
+	if err != nil || len(streams) == 0 {
+		// nil when there is no event after id
+		if err == redis.ErrNil {
+			err = nil
+		}
+		return nil, err
+	}
+	// [[key, [[id, [field, value, ...]], ...]]]
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, err
+	}
+	entries, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*realtime.Event, 0, len(entries))
+	for _, entry := range entries {
+		fields, err := redis.Values(entry, nil)
+		if err != nil || len(fields) != 2 {
+			continue
+		}
+		id, _ := redis.String(fields[0], nil)
+		values, _ := redis.StringMap(fields[1], nil)
+		events = append(events, &realtime.Event{ID: id, Event: values["event"], Data: values["data"]})
+	}
+	return events, nil
+}
+
+// Close closes the connections.
+func (s *EventStore) Close() error {
+	return s.b.p.Close()
+}
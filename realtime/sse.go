@@ -0,0 +1,95 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Event is a server-sent event.
+type Event struct {
+	// ID is sent back by the client reconnecting in its Last-Event-ID header.
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event,omitempty"`
+	Data  string `json:"data"`
+	// Retry is the reconnection delay of the client, in milliseconds.
+	Retry int `json:"retry,omitempty"`
+}
+
+// SSEWriter writes server-sent events to a response.
+type SSEWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+// NewSSEWriter sends the headers of an event stream and returns its writer.
+// it fails when the response can't be flushed.
+func NewSSEWriter(w http.ResponseWriter) (*SSEWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("realtime: the response doesn't support flushing")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx buffers the responses otherwise
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &SSEWriter{w: w, flusher: flusher}, nil
+}
+
+// fieldReplacer keeps the id and the event name on their line.
+var fieldReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+// WriteEvent sends e, its data split in lines. an event without data and
+// name only sets the id or the retry of the client.
+func (s *SSEWriter) WriteEvent(e *Event) error {
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + fieldReplacer.Replace(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + fieldReplacer.Replace(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.Itoa(e.Retry) + "\n")
+	}
+	if e.Data != "" || e.Event != "" {
+		data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(e.Data)
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// WriteComment sends a comment, ignored by the clients. it keeps the
+// connection open through the proxies.
+func (s *SSEWriter) WriteComment(text string) error {
+	return s.write(": " + fieldReplacer.Replace(text) + "\n\n")
+}
+
+func (s *SSEWriter) write(text string) error {
+	if _, err := io.WriteString(s.w, text); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
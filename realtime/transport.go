@@ -0,0 +1,511 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aamsur/beego/utils"
+	"github.com/aamsur/beego/websocket"
+)
+
+var (
+	// ErrClosed is returned by Send when the connection is closed.
+	ErrClosed = errors.New("realtime: connection closed")
+	// ErrQueueFull is returned by Send when the client doesn't read the
+	// messages fast enough.
+	ErrQueueFull = errors.New("realtime: send queue full")
+)
+
+// Server connects the clients to a hub over a websocket, or over long polling
+// for the clients and the proxies not supporting websockets. the connection
+// keeps its id and its rooms whatever its transport.
+//
+// the client starts with a handshake:
+//
+//	GET /realtime                          {"sid":"...","transports":["websocket","polling"],"pollTimeout":25000}
+//
+// then upgrades to a websocket, or falls back to polling when it fails:
+//
+//	GET /realtime?transport=websocket&sid=...
+//	GET /realtime?sid=...                  the messages, [{"type":1,"data":"hello"}], after pollTimeout at most
+//	POST /realtime?sid=...                 sends the messages of the body, in the same format
+//	DELETE /realtime?sid=...               closes the connection
+//
+// the data of the binary messages, type 2, is base64 encoded. usage:
+//
+//	server := realtime.NewServer(hub)
+//	server.OnConnect = func(c realtime.Conn, r *http.Request) {
+//		hub.Join("lobby", c)
+//	}
+//	server.OnMessage = func(c realtime.Conn, messageType int, data []byte) {
+//		hub.Broadcast("lobby", messageType, data)
+//	}
+//	beego.Handler("/realtime", server)
+type Server struct {
+	Hub *Hub
+	// Authenticate authenticates the handshakes and returns their identity,
+	// its errors refuse them with 401. the session id then authenticates the
+	// requests of the connection.
+	Authenticate func(r *http.Request) (interface{}, error)
+	// OnConnect is called once the connection is registered in the hub.
+	OnConnect func(c Conn, r *http.Request)
+	// OnMessage is called with the messages of the client.
+	OnMessage func(c Conn, messageType int, data []byte)
+	// OnDisconnect is called once the connection is closed, before it's
+	// unregistered from the hub.
+	OnDisconnect func(c Conn)
+
+	// Upgrader upgrades the websocket requests, the requests of other hosts
+	// are refused by default.
+	Upgrader websocket.Upgrader
+	// PollTimeout is how long a poll waits for messages.
+	PollTimeout time.Duration
+	// SessionTimeout closes the polling connections not polling for this long.
+	SessionTimeout time.Duration
+	// PingPeriod is the period of the pings of the websockets.
+	PingPeriod time.Duration
+	// WriteTimeout is the timeout of a websocket write.
+	WriteTimeout time.Duration
+	// MaxMessageSize is the size limit of the websocket messages and of the
+	// bodies of the polling requests.
+	MaxMessageSize int64
+	// QueueSize is the number of messages queued for a client.
+	QueueSize int
+	// Overflow is what happens to the messages of a client with a full queue.
+	Overflow Overflow
+
+	lock     sync.Mutex
+	sessions map[string]*session
+	janitor  sync.Once
+	stop     chan struct{}
+}
+
+// NewServer creates a server connecting the clients to hub.
+func NewServer(hub *Hub) *Server {
+	return &Server{
+		Hub:            hub,
+		PollTimeout:    25 * time.Second,
+		SessionTimeout: time.Minute,
+		PingPeriod:     30 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxMessageSize: 64 * 1024,
+		QueueSize:      256,
+		sessions:       make(map[string]*session),
+		stop:           make(chan struct{}),
+	}
+}
+
+// pollMessage is a message of the polling transport.
+type pollMessage struct {
+	Type int    `json:"type"`
+	Data string `json:"data"`
+}
+
+// session is a connection of the server, over polling until it's upgraded
+// to a websocket.
+type session struct {
+	id       string
+	server   *Server
+	identity interface{}
+
+	queue *SendQueue
+
+	lock     sync.Mutex
+	cancel   chan struct{} // closed when another poll replaces the one waiting
+	polling  bool
+	upgraded bool // the queue is emptied by a websocket writer
+	lastSeen time.Time
+	closed   bool
+	done     chan struct{}
+}
+
+// ID returns the id of the connection.
+func (s *session) ID() string {
+	return s.id
+}
+
+// Send queues a text or binary message for the client. a client too slow to
+// empty its queue is handled by the Overflow of the server.
+func (s *session) Send(messageType int, data []byte) error {
+	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+		return errors.New("realtime: Send of a control message")
+	}
+	s.lock.Lock()
+	closed := s.closed
+	s.lock.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	err := s.queue.Push(Message{messageType, data})
+	if err == ErrQueueFull && s.server.Overflow == OverflowDisconnect {
+		// Send may be called by a broadcast holding locks
+		go s.server.close(s)
+	}
+	return err
+}
+
+// ServeHTTP serves the handshakes, the websockets and the polls.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sid := r.URL.Query().Get("sid")
+	if r.URL.Query().Get("transport") == "websocket" {
+		srv.serveWebSocket(w, r, sid)
+		return
+	}
+	if sid == "" {
+		if r.Method != "GET" {
+			http.Error(w, "no session", http.StatusBadRequest)
+			return
+		}
+		identity, ok := srv.authenticate(w, r)
+		if !ok {
+			return
+		}
+		s := srv.open(r, identity)
+		writeJSON(w, map[string]interface{}{
+			"sid":         s.id,
+			"transports":  []string{"websocket", "polling"},
+			"pollTimeout": int64(srv.PollTimeout / time.Millisecond),
+		})
+		return
+	}
+	s := srv.session(sid)
+	if s == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		writeJSON(w, s.poll(r))
+	case "POST":
+		srv.receive(w, r, s)
+	case "DELETE":
+		srv.close(s)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authenticate runs Authenticate and answers the requests it refuses.
+func (srv *Server) authenticate(w http.ResponseWriter, r *http.Request) (interface{}, bool) {
+	if srv.Authenticate == nil {
+		return nil, true
+	}
+	identity, err := srv.Authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return identity, true
+}
+
+// open creates a session and registers it in the hub.
+func (srv *Server) open(r *http.Request, identity interface{}) *session {
+	srv.janitor.Do(func() { go srv.expire() })
+	s := &session{
+		id:       string(utils.RandomCreateBytes(20)),
+		server:   srv,
+		identity: identity,
+		queue:    NewSendQueue(srv.QueueSize, srv.Overflow),
+		lastSeen: time.Now(),
+		done:     make(chan struct{}),
+	}
+	srv.lock.Lock()
+	srv.sessions[s.id] = s
+	srv.lock.Unlock()
+	srv.Hub.Register(s)
+	if srv.OnConnect != nil {
+		srv.OnConnect(s, r)
+	}
+	return s
+}
+
+// Identity returns the identity of a connection of the server, returned by
+// Authenticate.
+func (srv *Server) Identity(c Conn) interface{} {
+	if s, ok := c.(*session); ok {
+		return s.identity
+	}
+	return nil
+}
+
+func (srv *Server) session(sid string) *session {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.sessions[sid]
+}
+
+// close closes the session once and unregisters it.
+func (srv *Server) close(s *session) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.lock.Unlock()
+	s.queue.Close()
+
+	srv.lock.Lock()
+	delete(srv.sessions, s.id)
+	srv.lock.Unlock()
+	if srv.OnDisconnect != nil {
+		srv.OnDisconnect(s)
+	}
+	srv.Hub.Unregister(s)
+}
+
+// expire closes the polling sessions not seen for SessionTimeout.
+func (srv *Server) expire() {
+	ticker := time.NewTicker(srv.SessionTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-srv.stop:
+			return
+		}
+		var expired []*session
+		srv.lock.Lock()
+		for _, s := range srv.sessions {
+			s.lock.Lock()
+			if !s.upgraded && !s.polling && time.Since(s.lastSeen) > srv.SessionTimeout {
+				expired = append(expired, s)
+			}
+			s.lock.Unlock()
+		}
+		srv.lock.Unlock()
+		for _, s := range expired {
+			srv.close(s)
+		}
+	}
+}
+
+// Close closes the connections of the server.
+func (srv *Server) Close() {
+	srv.lock.Lock()
+	sessions := make([]*session, 0, len(srv.sessions))
+	for _, s := range srv.sessions {
+		sessions = append(sessions, s)
+	}
+	srv.lock.Unlock()
+	for _, s := range sessions {
+		srv.close(s)
+	}
+	select {
+	case <-srv.stop:
+	default:
+		close(srv.stop)
+	}
+}
+
+// poll waits for the messages of the session, until PollTimeout. a new poll
+// of the session ends the one waiting.
+func (s *session) poll(r *http.Request) []pollMessage {
+	s.lock.Lock()
+	if s.cancel != nil {
+		close(s.cancel)
+	}
+	cancel := make(chan struct{})
+	s.cancel = cancel
+	s.polling = true
+	s.lastSeen = time.Now()
+	s.lock.Unlock()
+
+	timer := time.NewTimer(s.server.PollTimeout)
+	defer timer.Stop()
+	var messages []Message
+	select {
+	case m := <-s.queue.C():
+		messages = append(messages, m)
+	case <-timer.C:
+	case <-s.done:
+	case <-r.Context().Done():
+	case <-cancel:
+	}
+	// the messages queued meanwhile go in the same response
+drain:
+	for len(messages) > 0 {
+		select {
+		case m := <-s.queue.C():
+			messages = append(messages, m)
+		default:
+			break drain
+		}
+	}
+
+	s.lock.Lock()
+	if s.cancel == cancel {
+		s.cancel = nil
+		s.polling = false
+	}
+	s.lastSeen = time.Now()
+	s.lock.Unlock()
+	polled := make([]pollMessage, len(messages))
+	for i, m := range messages {
+		polled[i] = pollMessage{Type: m.Type, Data: string(m.Data)}
+		if m.Type == websocket.BinaryMessage {
+			polled[i].Data = base64.StdEncoding.EncodeToString(m.Data)
+		}
+	}
+	return polled
+}
+
+// receive calls OnMessage with the messages of the body.
+func (srv *Server) receive(w http.ResponseWriter, r *http.Request, s *session) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, srv.MaxMessageSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > srv.MaxMessageSize {
+		http.Error(w, "message too big", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var messages []pollMessage
+	if err := json.Unmarshal(body, &messages); err != nil {
+		http.Error(w, "invalid messages: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.lock.Lock()
+	s.lastSeen = time.Now()
+	s.lock.Unlock()
+	for _, m := range messages {
+		data := []byte(m.Data)
+		switch m.Type {
+		case websocket.TextMessage:
+		case websocket.BinaryMessage:
+			if data, err = base64.StdEncoding.DecodeString(m.Data); err != nil {
+				http.Error(w, "invalid binary message", http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "invalid message type", http.StatusBadRequest)
+			return
+		}
+		if srv.OnMessage != nil {
+			srv.OnMessage(s, m.Type, data)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveWebSocket upgrades the request and moves the session of sid, or a new
+// one, to the websocket.
+func (srv *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, sid string) {
+	var s *session
+	var identity interface{}
+	if sid != "" {
+		if s = srv.session(sid); s == nil {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	} else {
+		// authenticated before the upgrade, to answer with a status
+		var ok bool
+		if identity, ok = srv.authenticate(w, r); !ok {
+			return
+		}
+	}
+	conn, err := srv.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader answered the request
+		return
+	}
+	defer conn.Close()
+	if s == nil {
+		s = srv.open(r, identity)
+	}
+
+	// the websocket writer takes the queue over, the poll waiting ends
+	s.lock.Lock()
+	if s.closed || s.upgraded {
+		s.lock.Unlock()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session in use"), time.Now().Add(time.Second))
+		return
+	}
+	s.upgraded = true
+	if s.cancel != nil {
+		close(s.cancel)
+		s.cancel = nil
+	}
+	s.polling = false
+	s.lock.Unlock()
+
+	wait := srv.PingPeriod + srv.WriteTimeout
+	conn.SetReadLimit(srv.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+	written := make(chan struct{})
+	go srv.writePump(s, conn, written)
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if srv.OnMessage != nil {
+			srv.OnMessage(s, messageType, data)
+		}
+	}
+	srv.close(s)
+	<-written
+}
+
+func (srv *Server) writePump(s *session, conn *websocket.Conn, written chan struct{}) {
+	defer close(written)
+	ticker := time.NewTicker(srv.PingPeriod)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case m := <-s.queue.C():
+			conn.SetWriteDeadline(time.Now().Add(srv.WriteTimeout))
+			err = conn.WriteMessage(m.Type, m.Data)
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(srv.WriteTimeout))
+		case <-s.done:
+			frame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			select {
+			case <-s.queue.Overflowed():
+				frame = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "send queue full")
+			default:
+			}
+			conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(srv.WriteTimeout))
+			return
+		}
+		if err != nil {
+			// the read fails once the connection is closed
+			conn.Close()
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(v)
+}
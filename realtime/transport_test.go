@@ -0,0 +1,158 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego/websocket"
+)
+
+func TestServerPolling(t *testing.T) {
+	backend, _ := NewBackend("memory", "")
+	hub, _ := NewHub(backend)
+	srv := NewServer(hub)
+	srv.PollTimeout = 100 * time.Millisecond
+	srv.OnConnect = func(c Conn, r *http.Request) {
+		hub.Join("lobby", c)
+	}
+	srv.OnMessage = func(c Conn, messageType int, data []byte) {
+		hub.Broadcast("lobby", messageType, data)
+	}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+	defer srv.Close()
+
+	var handshake struct {
+		Sid        string
+		Transports []string
+	}
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewDecoder(resp.Body).Decode(&handshake)
+	resp.Body.Close()
+	if handshake.Sid == "" || len(handshake.Transports) != 2 {
+		t.Fatalf("the handshake should return a sid and the transports, got %+v", handshake)
+	}
+	if members, _ := hub.Members("lobby"); len(members) != 1 || members[0] != handshake.Sid {
+		t.Errorf("the connection should be in the hub with its sid, got %v", members)
+	}
+
+	poll := func() []pollMessage {
+		resp, err := http.Get(s.URL + "?sid=" + handshake.Sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var messages []pollMessage
+		json.NewDecoder(resp.Body).Decode(&messages)
+		return messages
+	}
+	if messages := poll(); len(messages) != 0 {
+		t.Errorf("a poll without message should time out empty, got %v", messages)
+	}
+
+	resp, err = http.Post(s.URL+"?sid="+handshake.Sid, "application/json",
+		strings.NewReader(`[{"type":1,"data":"hello"},{"type":2,"data":"AQI="}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("the messages should be accepted, got %d", resp.StatusCode)
+	}
+	messages := poll()
+	if len(messages) != 2 || messages[0].Data != "hello" || messages[1].Type != 2 || messages[1].Data != "AQI=" {
+		t.Errorf("the poll should get the messages broadcast, got %v", messages)
+	}
+
+	// the session upgrades to a websocket, keeping its id
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "?transport=websocket&sid=" + handshake.Sid
+	conn, _, err := websocket.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.WriteMessage(websocket.TextMessage, []byte("over websocket"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "over websocket" {
+		t.Errorf("the websocket should get the broadcast, got %q %v", data, err)
+	}
+
+	req, _ := http.NewRequest("DELETE", s.URL+"?sid="+handshake.Sid, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("closing the session should close the websocket, got %v", err)
+	}
+	if members, _ := hub.Members("lobby"); len(members) != 0 {
+		t.Errorf("the closed connection should leave the hub, got %v", members)
+	}
+	if messages := poll(); messages != nil {
+		t.Errorf("a closed session should be unknown, got %v", messages)
+	}
+}
+
+func TestServerAuthenticate(t *testing.T) {
+	backend, _ := NewBackend("memory", "")
+	hub, _ := NewHub(backend)
+	srv := NewServer(hub)
+	srv.Authenticate = func(r *http.Request) (interface{}, error) {
+		if r.URL.Query().Get("access_token") != "secret" {
+			return nil, errors.New("invalid token")
+		}
+		return "astaxie", nil
+	}
+	identities := make(chan interface{}, 1)
+	srv.OnConnect = func(c Conn, r *http.Request) {
+		identities <- srv.Identity(c)
+	}
+	s := httptest.NewServer(srv)
+	defer s.Close()
+	defer srv.Close()
+
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("a handshake without token should get 401, got %d", resp.StatusCode)
+	}
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "?transport=websocket"
+	if _, resp, err := websocket.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("an upgrade without token should get 401, got %v", err)
+	}
+
+	resp, err = http.Get(s.URL + "?access_token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || <-identities != "astaxie" {
+		t.Errorf("the identity should be attached to the connection, got %d", resp.StatusCode)
+	}
+}
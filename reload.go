@@ -0,0 +1,80 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/aamsur/beego/i18n"
+	"github.com/aamsur/beego/utils"
+)
+
+var (
+	reloadLock  sync.Mutex
+	reloadHooks []func() error
+)
+
+// OnReload adds a hook run by Reload after the app reloaded its own settings.
+//	beego.OnReload(func() error {
+//		return loadFeatureFlags()
+//	})
+func OnReload(fn func() error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// Reload reads app.conf again, evaluates the conditions of RouterIf, rebuilds the templates and
+// the locales, reopens the log files and loads the tls certificates again, then runs the OnReload hooks.
+// it's done on SIGHUP when EnableReload is true.
+// the new app.conf replaces AppConfig as a whole once it parsed, the values read
+// from it at start such as RunMode or the settings of the servers need a restart.
+// a failing step doesn't stop the others, their errors are returned together.
+func Reload() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	var errs []string
+	if config, err := newAppConfig(AppConfigProvider, AppConfigPath); err != nil {
+		errs = append(errs, "config: "+err.Error())
+	} else {
+		AppConfig.swap(config)
+	}
+	BeeApp.Handlers.updateCondRoutes()
+	if err := BuildTemplate(ViewsPath); err != nil {
+		errs = append(errs, "templates: "+err.Error())
+	}
+	if utils.FileExists(I18nPath) {
+		if err := i18n.LoadDir(I18nPath); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if err := BeeLogger.Reopen(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if err := reloadCertificates(); err != nil {
+		errs = append(errs, "certificates: "+err.Error())
+	}
+	for _, hook := range reloadHooks {
+		if err := hook(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	writeCert := func(cn string) {
+		cert, key, _ := newTestCert(t, cn, 1, nil, nil)
+		der, _ := x509.MarshalECPrivateKey(key)
+		ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600)
+		ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	}
+
+	writeCert("before")
+	cfg, err := certConfig(nil, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonName := func() string {
+		c, _ := cfg.GetCertificate(nil)
+		leaf, _ := x509.ParseCertificate(c.Certificate[0])
+		return leaf.Subject.CommonName
+	}
+	if cn := commonName(); cn != "before" {
+		t.Fatalf("certificate should be loaded, got %q", cn)
+	}
+
+	hooked := false
+	OnReload(func() error {
+		hooked = true
+		return nil
+	})
+	defer func() { reloadHooks = nil }()
+
+	writeCert("after")
+	if err := Reload(); err != nil && strings.Contains(err.Error(), "certificates") {
+		t.Fatal(err)
+	}
+	if cn := commonName(); cn != "after" {
+		t.Errorf("certificate should be reloaded, got %q", cn)
+	}
+	if !hooked {
+		t.Error("reload hooks should run")
+	}
+
+	os.Remove(keyFile)
+	if err := Reload(); err == nil || !strings.Contains(err.Error(), "certificates") {
+		t.Errorf("a missing key should be reported, got %v", err)
+	}
+	if cn := commonName(); cn != "after" {
+		t.Errorf("certificate failing to load should be kept, got %q", cn)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { AppConfigPath = path }(AppConfigPath)
+	defer AppConfig.swap(&beegoAppConfig{innerConfig: AppConfig.inner()})
+	AppConfigPath = filepath.Join(dir, "app.conf")
+	ioutil.WriteFile(AppConfigPath, []byte("RunMode = test\nfeature = on\n"), 0600)
+
+	if err := Reload(); err != nil && strings.Contains(err.Error(), "config") {
+		t.Fatal(err)
+	}
+	if v := AppConfig.String("feature"); v != "on" {
+		t.Errorf("AppConfig should be reloaded, got %q", v)
+	}
+	if RunMode == "test" || AppConfig.String("RunMode") != "test" {
+		t.Error("the settings read at start should need a restart")
+	}
+
+	ioutil.WriteFile(AppConfigPath, []byte("[broken\n"), 0600)
+	if err := Reload(); err == nil || !strings.Contains(err.Error(), "config") {
+		t.Errorf("a broken app.conf should be reported, got %v", err)
+	}
+	if v := AppConfig.String("feature"); v != "on" {
+		t.Errorf("a broken app.conf should keep the former config, got %q", v)
+	}
+}
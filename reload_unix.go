@@ -0,0 +1,39 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package beego
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchReload calls Reload on SIGHUP.
+func watchReload() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := Reload(); err != nil {
+				BeeLogger.Error("reload: %v", err)
+				continue
+			}
+			BeeLogger.Info("reloaded")
+		}
+	}()
+}
@@ -0,0 +1,92 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/aamsur/beego/utils"
+)
+
+// RenderTemplateToString renders a template of the views outside of a request,
+// e.g. in a task, with the same template cache and funcs as the controllers.
+// the views are built by Run, a process not serving http calls BuildTemplate before.
+//	body, err := beego.RenderTemplateToString("reports/daily.tpl", map[string]interface{}{"Day": day})
+func RenderTemplateToString(name string, data interface{}) (string, error) {
+	b, err := renderTemplate(name, name, data)
+	return string(b), err
+}
+
+// RenderEmail renders a template of the views into the html body of email.
+// the blocks "subject" and "text" of the template, when it defines them,
+// set the subject and the plain text body.
+//	{{define "subject"}}Welcome {{.Name}}{{end}}
+//	{{define "text"}}Hello {{.Name}}, ...{{end}}
+//
+//	mail := utils.NewEMail(`{"host":"smtp.gmail.com","port":587}`)
+//	mail.To = []string{user.Email}
+//	err := beego.RenderEmail(mail, "mail/welcome.tpl", user)
+func RenderEmail(email *utils.Email, name string, data interface{}) error {
+	subject, text, body, err := RenderMailTemplate(name, data)
+	if err != nil {
+		return err
+	}
+	email.HTML = body
+	if subject != "" {
+		email.Subject = subject
+	}
+	if text != "" {
+		email.Text = text
+	}
+	return nil
+}
+
+// RenderMailTemplate renders a template of the views into the html body of
+// a mail, and its "subject" and "text" blocks when it defines them, see
+// RenderEmail.
+func RenderMailTemplate(name string, data interface{}) (subject, text, body string, err error) {
+	b, err := renderTemplate(name, name, data)
+	if err != nil {
+		return "", "", "", err
+	}
+	for block, field := range map[string]*string{"subject": &subject, "text": &text} {
+		if t, _ := lookupTemplate(name); t.Lookup(block) == nil {
+			continue
+		}
+		b, err := renderTemplate(name, block, data)
+		if err != nil {
+			return "", "", "", err
+		}
+		// the blocks are escaped for html, they're sent as plain text
+		*field = strings.TrimSpace(html.UnescapeString(string(b)))
+	}
+	return subject, text, string(b), nil
+}
+
+// renderTemplate executes the block of the template file.
+func renderTemplate(file, block string, data interface{}) ([]byte, error) {
+	t, ok := lookupTemplate(file)
+	if !ok {
+		return nil, errors.New("can't find templatefile in the path:" + file)
+	}
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, block, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
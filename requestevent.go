@@ -0,0 +1,213 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	beecontext "github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/tracing"
+)
+
+// RequestEvent is the canonical log line of a request: one event at its end
+// with what it did and where its time went. it's emitted when RequestEvents
+// is set, by RequestEventHandler.
+//
+// the queries and cache lookups are counted when the orm and the cache get
+// the context of the request:
+//
+//	o := orm.NewOrmWithContext(c.Context())
+//	bm := cache.WithContext(c.Context(), bm)
+//
+// the handlers add their own fields:
+//
+//	beego.CurrentRequestEvent(c.Ctx).Set("cart_items", len(cart.Items))
+type RequestEvent struct {
+	Time   time.Time
+	Method string
+	Path   string
+	Route  string // the route pattern, empty when no route matched
+	Status int
+	Bytes  int64 // size of the response body
+	User   string
+	// TraceID is the trace of the request when tracing is on.
+	TraceID string
+
+	Duration time.Duration
+	Filters  time.Duration // time spent in the filters
+	Handler  time.Duration // time spent in the controller or the handler, without Render
+	Render   time.Duration // time spent rendering the template
+
+	DBQueries   int64
+	DBTime      time.Duration
+	CacheHits   int64
+	CacheMisses int64
+
+	Fields map[string]interface{}
+
+	stats    tracing.RequestStats
+	dispatch time.Time
+}
+
+// RequestEventHandler emits the request events, it logs them as logfmt lines
+// by default.
+var RequestEventHandler = func(ev *RequestEvent) {
+	BeeLogger.Info("%s", ev)
+}
+
+const requestEventKey = "_beego_request_event"
+
+// CurrentRequestEvent returns the event of the request of ctx, nil when
+// RequestEvents is off. Set can be called on nil.
+func CurrentRequestEvent(ctx *beecontext.Context) *RequestEvent {
+	ev, _ := ctx.Input.GetData(requestEventKey).(*RequestEvent)
+	return ev
+}
+
+// Set adds a field to the event.
+func (ev *RequestEvent) Set(key string, value interface{}) {
+	if ev == nil {
+		return
+	}
+	if ev.Fields == nil {
+		ev.Fields = make(map[string]interface{})
+	}
+	ev.Fields[key] = value
+}
+
+// String formats the event as a logfmt line.
+func (ev *RequestEvent) String() string {
+	var b strings.Builder
+	b.WriteString("canonical-log-line")
+	field := func(key string, value interface{}) {
+		s := fmt.Sprint(value)
+		if s == "" || strings.ContainsAny(s, " \"=\t\n") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(" " + key + "=" + s)
+	}
+	ms := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	}
+	field("method", ev.Method)
+	field("path", ev.Path)
+	if ev.Route != "" {
+		field("route", ev.Route)
+	}
+	field("status", ev.Status)
+	field("duration_ms", ms(ev.Duration))
+	field("filters_ms", ms(ev.Filters))
+	field("handler_ms", ms(ev.Handler))
+	field("render_ms", ms(ev.Render))
+	field("db_queries", ev.DBQueries)
+	field("db_ms", ms(ev.DBTime))
+	field("cache_hits", ev.CacheHits)
+	field("cache_misses", ev.CacheMisses)
+	field("bytes", ev.Bytes)
+	if ev.User != "" {
+		field("user", ev.User)
+	}
+	if ev.TraceID != "" {
+		field("trace_id", ev.TraceID)
+	}
+	keys := make([]string, 0, len(ev.Fields))
+	for k := range ev.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field(k, ev.Fields[k])
+	}
+	return b.String()
+}
+
+// addFilters adds d to the time of the filters.
+func (ev *RequestEvent) addFilters(d time.Duration) {
+	if ev != nil {
+		ev.Filters += d
+	}
+}
+
+// startDispatch marks the start of the handler.
+func (ev *RequestEvent) startDispatch() {
+	if ev != nil {
+		ev.dispatch = time.Now()
+	}
+}
+
+// endDispatch sets the time of the handler, without the render.
+func (ev *RequestEvent) endDispatch() {
+	if ev != nil && !ev.dispatch.IsZero() {
+		ev.Handler = time.Since(ev.dispatch) - ev.Render
+		ev.dispatch = time.Time{}
+	}
+}
+
+// timeRender adds the time since start to the time of the render.
+func (ev *RequestEvent) timeRender(start time.Time) {
+	if ev != nil {
+		ev.Render += time.Since(start)
+	}
+}
+
+// endRequestEvent completes the event of the request of ctx and emits it.
+func endRequestEvent(ev *RequestEvent, ctx *beecontext.Context, w *responseWriter, route *controllerInfo) {
+	ev.endDispatch()
+	ev.Duration = time.Since(ev.Time)
+	ev.Method = ctx.Request.Method
+	ev.Path = ctx.Request.URL.Path
+	if route != nil {
+		ev.Route = route.pattern
+	}
+	ev.Status = responseStatus(ctx, w)
+	ev.Bytes = w.size
+	ev.User = userName(ctx.User())
+	if sc := tracing.SpanContextFromContext(ctx.Request.Context()); sc.IsValid() {
+		ev.TraceID = sc.TraceID.String()
+	}
+	ev.DBQueries, ev.DBTime = ev.stats.DBQueries()
+	ev.CacheHits, ev.CacheMisses = ev.stats.CacheLookups()
+	RequestEventHandler(ev)
+}
+
+// userName returns the name of the user set by an auth filter: a string, a
+// number, the subject of the jwt claims or of the oidc identity, or a
+// fmt.Stringer.
+func userName(user interface{}) string {
+	switch u := user.(type) {
+	case nil:
+		return ""
+	case string:
+		return u
+	case int, int64, uint, uint64:
+		return fmt.Sprint(u)
+	case interface{ Subject() string }:
+		return u.Subject()
+	case fmt.Stringer:
+		return u.String()
+	}
+	v := reflect.Indirect(reflect.ValueOf(user))
+	if v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Subject"); f.Kind() == reflect.String {
+			return f.String()
+		}
+	}
+	return ""
+}
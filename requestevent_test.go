@@ -0,0 +1,86 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/tracing"
+)
+
+func TestRequestEvent(t *testing.T) {
+	RequestEvents = true
+	defer func() { RequestEvents = false }()
+	var events []*RequestEvent
+	defer func(f func(*RequestEvent)) { RequestEventHandler = f }(RequestEventHandler)
+	RequestEventHandler = func(ev *RequestEvent) { events = append(events, ev) }
+
+	handler := NewControllerRegister()
+	handler.InsertFilter("/cart/*", BeforeRouter, func(ctx *context.Context) {
+		time.Sleep(2 * time.Millisecond)
+		ctx.SetUser("ann")
+	})
+	handler.Get("/cart/:id", func(ctx *context.Context) {
+		stats := tracing.StatsFromContext(ctx.Request.Context())
+		stats.AddQuery(3 * time.Millisecond)
+		stats.AddQuery(time.Millisecond)
+		stats.AddCacheLookup(true)
+		stats.AddCacheLookup(false)
+		CurrentRequestEvent(ctx).Set("items", 3)
+		ctx.Output.Body([]byte("cart"))
+	})
+	r, _ := http.NewRequest("GET", "/cart/7", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(events) != 1 {
+		t.Fatal("events:", len(events))
+	}
+	ev := events[0]
+	if ev.Route != "/cart/:id" || ev.Status != 200 || ev.Bytes != 4 || ev.User != "ann" {
+		t.Fatalf("event: %+v", ev)
+	}
+	if ev.DBQueries != 2 || ev.DBTime != 4*time.Millisecond || ev.CacheHits != 1 || ev.CacheMisses != 1 {
+		t.Fatalf("stats: %+v", ev)
+	}
+	if ev.Filters < 2*time.Millisecond || ev.Duration < ev.Filters+ev.Handler {
+		t.Fatalf("latencies: %+v", ev)
+	}
+	line := ev.String()
+	for _, s := range []string{"canonical-log-line method=GET path=/cart/7 route=/cart/:id status=200 ", " db_queries=2 db_ms=4.000 cache_hits=1 cache_misses=1 bytes=4 user=ann items=3"} {
+		if !strings.Contains(line, s) {
+			t.Errorf("no %q in %s", s, line)
+		}
+	}
+
+	RequestEvents = false
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if len(events) != 1 {
+		t.Fatal("event emitted with RequestEvents off")
+	}
+}
+
+func TestRequestEventQuoting(t *testing.T) {
+	ev := &RequestEvent{Method: "GET", Path: "/a b"}
+	ev.Set("note", `say "hi"`)
+	line := ev.String()
+	if !strings.Contains(line, `path="/a b"`) || !strings.Contains(line, `note="say \"hi\""`) {
+		t.Fatal(line)
+	}
+}
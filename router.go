@@ -20,17 +20,22 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	beecontext "github.com/aamsur/beego/context"
 	"github.com/aamsur/beego/toolbox"
+	"github.com/aamsur/beego/tracing"
 	"github.com/aamsur/beego/utils"
 )
 
@@ -65,9 +70,10 @@ var (
 	// these beego.Controller's methods shouldn't reflect to AutoRouter
 	exceptMethod = []string{"Init", "Prepare", "Finish", "Render", "RenderString",
 		"RenderBytes", "Redirect", "Abort", "StopRun", "UrlFor", "ServeJson", "ServeJsonp",
-		"ServeXml", "Input", "ParseForm", "GetString", "GetStrings", "GetInt", "GetBool",
-		"GetFloat", "GetFile", "SaveToFile", "StartSession", "SetSession", "GetSession",
-		"DelSession", "SessionRegenerateID", "DestroySession", "IsAjax", "GetSecureCookie",
+		"ServeXml", "Input", "ParseForm", "BindForm", "BindJSON", "BindXML", "GetString",
+		"GetStrings", "GetInt", "GetBool", "GetFloat", "GetFile", "GetFiles", "SaveToFile",
+		"SaveToWriter", "SaveToDir", "StartSession", "SetSession", "GetSession", "DelSession",
+		"SessionRegenerateID", "DestroySession", "Context", "IsAjax", "GetSecureCookie",
 		"SetSecureCookie", "XsrfToken", "CheckXsrfCookie", "XsrfFormHtml",
 		"GetControllerAndAction"}
 
@@ -108,24 +114,189 @@ type controllerInfo struct {
 	handler        http.Handler
 	runfunction    FilterFunc
 	routerType     int
+	middlewares    []FilterFunc // of the namespaces of the route
+	trailingSlash  string       // policy of the namespace of the route
+	pathCase       string       // policy of the namespace of the route
+	meta           map[string]interface{}
+	bodyLimit      int64 // of WithBodyLimit
+	copyBody       int   // of WithCopyBody, 1 or -1, 0 follows CopyRequestBody
+	streamUpload   bool  // of WithStreamedUpload
+}
+
+// copiesBody reports whether the body of the requests of c is copied to
+// Ctx.Input.RequestBody, c may be nil.
+func (c *controllerInfo) copiesBody() bool {
+	if c == nil || c.copyBody == 0 {
+		return CopyRequestBody
+	}
+	return c.copyBody > 0
+}
+
+// runMiddlewares runs the middlewares of the namespaces of a route, it
+// reports whether one of them wrote the response.
+func runMiddlewares(middlewares []FilterFunc, ctx *beecontext.Context, w *responseWriter) bool {
+	for _, m := range middlewares {
+		m(ctx)
+		if w.started {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteOption configures a route when it's registered.
+type RouteOption func(*controllerInfo)
+
+// WithFilters adds filters running for the route only, after the BeforeExec
+// filters and the middlewares of its namespaces, in their order.
+// a filter writing the response ends the request.
+// usage:
+//	beego.Router("/admin", &AdminController{}, beego.WithFilters(auth))
+//	beego.Get("/health", health, beego.WithFilters(internalOnly))
+func WithFilters(filters ...FilterFunc) RouteOption {
+	return func(c *controllerInfo) {
+		c.middlewares = append(c.middlewares, filters...)
+	}
+}
+
+// WithMeta attaches the metadata key to the route, like the scopes it
+// requires or its rate limit class. once the route is matched, the filters
+// and the controller find it in Ctx.Input.RouteMeta.
+// usage:
+//	beego.Router("/admin/users", &UserController{}, beego.WithMeta("scopes", []string{"users:write"}))
+func WithMeta(key string, value interface{}) RouteOption {
+	return func(c *controllerInfo) {
+		if c.meta == nil {
+			c.meta = make(map[string]interface{})
+		}
+		c.meta[key] = value
+	}
+}
+
+// WithTags adds tags to the route, kept in its metadata as the []string of
+// context.TagsMeta, see Ctx.Input.HasTag.
+func WithTags(tags ...string) RouteOption {
+	return func(c *controllerInfo) {
+		old, _ := c.meta[beecontext.TagsMeta].([]string)
+		WithMeta(beecontext.TagsMeta, append(old[:len(old):len(old)], tags...))(c)
+	}
+}
+
+// WithBodyLimit limits the request body of the route to size bytes,
+// overriding MaxRequestBodySize and the BodyLimit filters. the limit is
+// enforced before the body is copied or parsed, a larger body is answered
+// with 413.
+//	beego.Router("/upload", &UploadController{}, beego.WithBodyLimit(1<<30))
+func WithBodyLimit(size int64) RouteOption {
+	return func(c *controllerInfo) {
+		c.bodyLimit = size
+	}
+}
+
+// WithCopyBody sets whether the request body of the route is copied to
+// Ctx.Input.RequestBody, overriding CopyRequestBody: copying a large upload
+// streamed by the route can be avoided, or a json body of an app not
+// copying the bodies can be.
+func WithCopyBody(enabled bool) RouteOption {
+	return func(c *controllerInfo) {
+		c.copyBody = -1
+		if enabled {
+			c.copyBody = 1
+		}
+	}
+}
+
+// WithStreamedUpload leaves the multipart bodies of the route unparsed, to
+// be streamed by Ctx.Input.EachFile: the uploads go to their storage without
+// being buffered in memory or in temporary files. the form values of the
+// body are only known once EachFile has read them.
+//	beego.Router("/videos", &VideoController{}, "post:Upload",
+//		beego.WithStreamedUpload(), beego.WithBodyLimit(10<<30))
+func WithStreamedUpload() RouteOption {
+	return func(c *controllerInfo) {
+		c.streamUpload = true
+	}
+}
+
+// splitRouteOptions returns the method mappings and the RouteOptions of
+// options, panicking on the other ones.
+func splitRouteOptions(options []interface{}) (mappings []string, opts []RouteOption) {
+	for _, o := range options {
+		switch o := o.(type) {
+		case string:
+			mappings = append(mappings, o)
+		case RouteOption:
+			opts = append(opts, o)
+		default:
+			panic(fmt.Sprintf("invalid router option: %v", o))
+		}
+	}
+	return
+}
+
+// controllerMethods caches the method indexes of the controller types by
+// name, reflect's MethodByName is slow.
+var controllerMethods = struct {
+	sync.RWMutex
+	m map[reflect.Type]map[string]int
+}{m: make(map[reflect.Type]map[string]int)}
+
+// controllerMethod returns the method name of the controller vc.
+func controllerMethod(vc reflect.Value, name string) reflect.Value {
+	t := vc.Type()
+	controllerMethods.RLock()
+	i, ok := controllerMethods.m[t][name]
+	controllerMethods.RUnlock()
+	if ok {
+		return vc.Method(i)
+	}
+	m, found := t.MethodByName(name)
+	if !found {
+		// the call panics like the ones of MethodByName
+		return reflect.Value{}
+	}
+	controllerMethods.Lock()
+	if controllerMethods.m[t] == nil {
+		controllerMethods.m[t] = make(map[string]int)
+	}
+	controllerMethods.m[t][name] = m.Index
+	controllerMethods.Unlock()
+	return vc.Method(m.Index)
 }
 
 // ControllerRegistor containers registered router rules, controller handlers and filters.
 type ControllerRegistor struct {
-	routers      map[string]*Tree
-	enableFilter bool
-	filters      map[int][]*FilterRouter
+	routes     atomic.Value // *routingTable, replaced by Update
+	updateLock sync.Mutex
+	condRoutes []*condRoute // of AddIf
+	condLock   sync.Mutex
+	filters    map[int][]*FilterRouter
+	filterLock sync.RWMutex
+	pool       sync.Pool // of the *requestState of the requests
+}
+
+// requestState is the route matching state of a request, pooled to be reused
+// by the next requests. the context and the response writer are not pooled,
+// the handlers may keep them past the request.
+type requestState struct {
+	params Params
+	match  routeMatch
 }
 
 // NewControllerRegister returns a new ControllerRegistor.
 func NewControllerRegister() *ControllerRegistor {
-	return &ControllerRegistor{
-		routers: make(map[string]*Tree),
+	cr := &ControllerRegistor{
 		filters: make(map[int][]*FilterRouter),
 	}
+	cr.routes.Store(&routingTable{routers: make(map[string]*Tree)})
+	cr.pool.New = func() interface{} {
+		return new(requestState)
+	}
+	return cr
 }
 
 // Add controller handler and pattern rules to ControllerRegistor.
+// options are the method mapping and RouteOptions.
 // usage:
 //	default methods is the same name as method
 //	Add("/user",&UserController{})
@@ -135,7 +306,9 @@ func NewControllerRegister() *ControllerRegistor {
 //	Add("/api/delete",&RestController{},"delete:DeleteFood")
 //	Add("/api",&RestController{},"get,post:ApiFunc")
 //	Add("/simple",&SimpleController{},"get:GetFunc;post:PostFunc")
-func (p *ControllerRegistor) Add(pattern string, c ControllerInterface, mappingMethods ...string) {
+//	Add("/admin",&AdminController{},WithFilters(auth))
+func (p *ControllerRegistor) Add(pattern string, c ControllerInterface, options ...interface{}) {
+	mappingMethods, routeOptions := splitRouteOptions(options)
 	reflectVal := reflect.ValueOf(c)
 	t := reflect.Indirect(reflectVal).Type()
 	methods := make(map[string]string)
@@ -166,6 +339,9 @@ func (p *ControllerRegistor) Add(pattern string, c ControllerInterface, mappingM
 	route.methods = methods
 	route.routerType = routerTypeBeego
 	route.controllerType = t
+	for _, o := range routeOptions {
+		o(route)
+	}
 	if len(methods) == 0 {
 		for _, m := range HTTPMETHOD {
 			p.addToRouter(m, pattern, route)
@@ -187,19 +363,21 @@ func (p *ControllerRegistor) addToRouter(method, pattern string, r *controllerIn
 	if !RouterCaseSensitive {
 		pattern = strings.ToLower(pattern)
 	}
-	if t, ok := p.routers[method]; ok {
+	routers := p.table().routers
+	if t, ok := routers[method]; ok {
 		t.AddRouter(pattern, r)
 	} else {
 		t := NewTree()
 		t.AddRouter(pattern, r)
-		p.routers[method] = t
+		routers[method] = t
 	}
 }
 
-// only when the Runmode is dev will generate router file in the router/auto.go from the controller
+// only when the Runmode is dev and RouterScan is set will generate router file in the routers directory from the controller,
+// else the commentsRouter files generated by cmd/beerouter are used
 // Include(&BankAccount{}, &OrderController{},&RefundController{},&ReceiptController{})
 func (p *ControllerRegistor) Include(cList ...ControllerInterface) {
-	if RunMode == "dev" {
+	if RunMode == "dev" && RouterScan {
 		skip := make(map[string]bool, 10)
 		for _, c := range cList {
 			reflectVal := reflect.ValueOf(c)
@@ -221,7 +399,9 @@ func (p *ControllerRegistor) Include(cList ...ControllerInterface) {
 			if pkgpath != "" {
 				if _, ok := skip[pkgpath]; !ok {
 					skip[pkgpath] = true
-					parserPkg(pkgpath, t.PkgPath())
+					if err := parserPkg(pkgpath, t.PkgPath()); err != nil {
+						Error("generate the routers of", t.PkgPath(), "error:", err)
+					}
 				}
 			}
 		}
@@ -243,8 +423,8 @@ func (p *ControllerRegistor) Include(cList ...ControllerInterface) {
 //    Get("/", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Get(pattern string, f FilterFunc) {
-	p.AddMethod("get", pattern, f)
+func (p *ControllerRegistor) Get(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("get", pattern, f, options...)
 }
 
 // add post method
@@ -252,8 +432,8 @@ func (p *ControllerRegistor) Get(pattern string, f FilterFunc) {
 //    Post("/api", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Post(pattern string, f FilterFunc) {
-	p.AddMethod("post", pattern, f)
+func (p *ControllerRegistor) Post(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("post", pattern, f, options...)
 }
 
 // add put method
@@ -261,8 +441,8 @@ func (p *ControllerRegistor) Post(pattern string, f FilterFunc) {
 //    Put("/api/:id", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Put(pattern string, f FilterFunc) {
-	p.AddMethod("put", pattern, f)
+func (p *ControllerRegistor) Put(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("put", pattern, f, options...)
 }
 
 // add delete method
@@ -270,8 +450,8 @@ func (p *ControllerRegistor) Put(pattern string, f FilterFunc) {
 //    Delete("/api/:id", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Delete(pattern string, f FilterFunc) {
-	p.AddMethod("delete", pattern, f)
+func (p *ControllerRegistor) Delete(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("delete", pattern, f, options...)
 }
 
 // add head method
@@ -279,8 +459,8 @@ func (p *ControllerRegistor) Delete(pattern string, f FilterFunc) {
 //    Head("/api/:id", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Head(pattern string, f FilterFunc) {
-	p.AddMethod("head", pattern, f)
+func (p *ControllerRegistor) Head(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("head", pattern, f, options...)
 }
 
 // add patch method
@@ -288,8 +468,8 @@ func (p *ControllerRegistor) Head(pattern string, f FilterFunc) {
 //    Patch("/api/:id", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Patch(pattern string, f FilterFunc) {
-	p.AddMethod("patch", pattern, f)
+func (p *ControllerRegistor) Patch(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("patch", pattern, f, options...)
 }
 
 // add options method
@@ -297,8 +477,8 @@ func (p *ControllerRegistor) Patch(pattern string, f FilterFunc) {
 //    Options("/api/:id", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Options(pattern string, f FilterFunc) {
-	p.AddMethod("options", pattern, f)
+func (p *ControllerRegistor) Options(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("options", pattern, f, options...)
 }
 
 // add all method
@@ -306,8 +486,8 @@ func (p *ControllerRegistor) Options(pattern string, f FilterFunc) {
 //    Any("/api/:id", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) Any(pattern string, f FilterFunc) {
-	p.AddMethod("*", pattern, f)
+func (p *ControllerRegistor) Any(pattern string, f FilterFunc, options ...RouteOption) {
+	p.AddMethod("*", pattern, f, options...)
 }
 
 // add http method router
@@ -315,7 +495,7 @@ func (p *ControllerRegistor) Any(pattern string, f FilterFunc) {
 //    AddMethod("get","/api/:id", func(ctx *context.Context){
 //          ctx.Output.Body("hello world")
 //    })
-func (p *ControllerRegistor) AddMethod(method, pattern string, f FilterFunc) {
+func (p *ControllerRegistor) AddMethod(method, pattern string, f FilterFunc, options ...RouteOption) {
 	if _, ok := HTTPMETHOD[strings.ToUpper(method)]; method != "*" && !ok {
 		panic("not support http method: " + method)
 	}
@@ -323,6 +503,9 @@ func (p *ControllerRegistor) AddMethod(method, pattern string, f FilterFunc) {
 	route.pattern = pattern
 	route.routerType = routerTypeRESTFul
 	route.runfunction = f
+	for _, o := range options {
+		o(route)
+	}
 	methods := make(map[string]string)
 	if method == "*" {
 		for _, val := range HTTPMETHOD {
@@ -344,16 +527,24 @@ func (p *ControllerRegistor) AddMethod(method, pattern string, f FilterFunc) {
 }
 
 // add user defined Handler
+// options are a bool to match the sub paths of pattern too, and RouteOptions.
 func (p *ControllerRegistor) Handler(pattern string, h http.Handler, options ...interface{}) {
 	route := &controllerInfo{}
 	route.pattern = pattern
 	route.routerType = routerTypeHandler
 	route.handler = h
-	if len(options) > 0 {
-		if _, ok := options[0].(bool); ok {
-			pattern = path.Join(pattern, "?:all")
+	prefix := false
+	for _, o := range options {
+		switch o := o.(type) {
+		case bool:
+			prefix = true
+		case RouteOption:
+			o(route)
 		}
 	}
+	if prefix {
+		pattern = path.Join(pattern, "?:all")
+	}
 	for _, m := range HTTPMETHOD {
 		p.addToRouter(m, pattern, route)
 	}
@@ -402,11 +593,30 @@ func (p *ControllerRegistor) AddAutoPrefix(prefix string, c ControllerInterface)
 // Add a FilterFunc with pattern rule and action constant.
 // The bool params is for setting the returnOnOutput value (false allows multiple filters to execute)
 func (p *ControllerRegistor) InsertFilter(pattern string, pos int, filter FilterFunc, params ...bool) error {
+	return p.insertFilterRouter(pos, newFilterRouter(pattern, filter, params...))
+}
 
+// InsertNamedFilter adds a filter with an unique name and a priority.
+// filters with lower priority run first, equal priorities run in insertion order.
+// filters added by InsertFilter have DefaultFilterPriority.
+// usage:
+//	InsertNamedFilter("auth", -10, "/admin/*", BeforeRouter, authFilter)
+func (p *ControllerRegistor) InsertNamedFilter(name string, priority int, pattern string, pos int, filter FilterFunc, params ...bool) error {
+	if name == "" {
+		return errors.New("filter name is empty")
+	}
+	mr := newFilterRouter(pattern, filter, params...)
+	mr.name = name
+	mr.priority = priority
+	return p.insertFilterRouter(pos, mr)
+}
+
+func newFilterRouter(pattern string, filter FilterFunc, params ...bool) *FilterRouter {
 	mr := new(FilterRouter)
 	mr.tree = NewTree()
 	mr.pattern = pattern
 	mr.filterFunc = filter
+	mr.priority = DefaultFilterPriority
 	if !RouterCaseSensitive {
 		pattern = strings.ToLower(pattern)
 	}
@@ -416,16 +626,158 @@ func (p *ControllerRegistor) InsertFilter(pattern string, pos int, filter Filter
 		mr.returnOnOutput = params[0]
 	}
 	mr.tree.AddRouter(pattern, true)
-	return p.insertFilterRouter(pos, mr)
+	return mr
 }
 
 // add Filter into
+// the list is copied, so requests being served keep a consistent chain.
 func (p *ControllerRegistor) insertFilterRouter(pos int, mr *FilterRouter) error {
-	p.filters[pos] = append(p.filters[pos], mr)
-	p.enableFilter = true
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	if mr.name != "" {
+		if _, _, ok := p.findFilter(mr.name); ok {
+			return errors.New("filter " + mr.name + " is already registered")
+		}
+	}
+	p.addFilterRouter(pos, mr)
+	return nil
+}
+
+// insertFilterRouters adds the filters of another registry, either all of
+// them or none when one of their names is already registered.
+func (p *ControllerRegistor) insertFilterRouters(filters map[int][]*FilterRouter) error {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	for _, l := range filters {
+		for _, mr := range l {
+			if mr.name == "" {
+				continue
+			}
+			if _, _, ok := p.findFilter(mr.name); ok {
+				return errors.New("filter " + mr.name + " is already registered")
+			}
+		}
+	}
+	for pos := BeforeStatic; pos <= FinishRouter; pos++ {
+		for _, mr := range filters[pos] {
+			p.addFilterRouter(pos, mr)
+		}
+	}
+	return nil
+}
+
+// addFilterRouter inserts mr after the filters of its priority, filterLock must be held.
+func (p *ControllerRegistor) addFilterRouter(pos int, mr *FilterRouter) {
+	old := p.filters[pos]
+	i := len(old)
+	for i > 0 && old[i-1].priority > mr.priority {
+		i--
+	}
+	l := make([]*FilterRouter, 0, len(old)+1)
+	l = append(l, old[:i]...)
+	l = append(l, mr)
+	l = append(l, old[i:]...)
+	p.filters[pos] = l
+}
+
+// RemoveFilter removes the named filter, it reports whether the filter existed.
+func (p *ControllerRegistor) RemoveFilter(name string) bool {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	pos, i, ok := p.findFilter(name)
+	if !ok {
+		return false
+	}
+	old := p.filters[pos]
+	l := make([]*FilterRouter, 0, len(old)-1)
+	l = append(l, old[:i]...)
+	p.filters[pos] = append(l, old[i+1:]...)
+	return true
+}
+
+// ReplaceFilter replaces the function of the named filter, keeping its pattern, position and priority.
+func (p *ControllerRegistor) ReplaceFilter(name string, filter FilterFunc) error {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	pos, i, ok := p.findFilter(name)
+	if !ok {
+		return errors.New("filter " + name + " is not registered")
+	}
+	mr := *p.filters[pos][i]
+	mr.filterFunc = filter
+	l := make([]*FilterRouter, len(p.filters[pos]))
+	copy(l, p.filters[pos])
+	l[i] = &mr
+	p.filters[pos] = l
 	return nil
 }
 
+// SkipFilter registers exemptions for the named filter, it is skipped
+// for requests where one of conds holds.
+// usage:
+//	SkipFilter("auth", SkipPaths("/healthz"), SkipMethods("OPTIONS"))
+func (p *ControllerRegistor) SkipFilter(name string, conds ...FilterCondition) error {
+	p.filterLock.Lock()
+	defer p.filterLock.Unlock()
+	pos, i, ok := p.findFilter(name)
+	if !ok {
+		return errors.New("filter " + name + " is not registered")
+	}
+	mr := *p.filters[pos][i]
+	mr.skips = append(append([]FilterCondition{}, mr.skips...), conds...)
+	l := make([]*FilterRouter, len(p.filters[pos]))
+	copy(l, p.filters[pos])
+	l[i] = &mr
+	p.filters[pos] = l
+	return nil
+}
+
+// findFilter returns the position and index of the named filter, filterLock must be held.
+func (p *ControllerRegistor) findFilter(name string) (int, int, bool) {
+	for pos, l := range p.filters {
+		for i, mr := range l {
+			if mr.name == name {
+				return pos, i, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// getFilters returns the filters of position pos in execution order.
+func (p *ControllerRegistor) getFilters(pos int) []*FilterRouter {
+	p.filterLock.RLock()
+	defer p.filterLock.RUnlock()
+	return p.filters[pos]
+}
+
+// Filters lists all filters in execution order.
+func (p *ControllerRegistor) Filters() []FilterInfo {
+	var infos []FilterInfo
+	for _, pos := range filterPositions {
+		for _, mr := range p.getFilters(pos) {
+			infos = append(infos, mr.info(pos))
+		}
+	}
+	return infos
+}
+
+// FilterChain lists the filters which run for a request of url, in execution order.
+func (p *ControllerRegistor) FilterChain(url string) []FilterInfo {
+	if !RouterCaseSensitive {
+		url = strings.ToLower(url)
+	}
+	var infos []FilterInfo
+	for _, pos := range filterPositions {
+		for _, mr := range p.getFilters(pos) {
+			if ok, _ := mr.ValidRouter(url); ok {
+				infos = append(infos, mr.info(pos))
+			}
+		}
+	}
+	return infos
+}
+
 // UrlFor does another controller handler in this request function.
 // it can access any controller method.
 func (p *ControllerRegistor) UrlFor(endpoint string, values ...interface{}) string {
@@ -449,12 +801,25 @@ func (p *ControllerRegistor) UrlFor(endpoint string, values ...interface{}) stri
 			}
 		}
 	}
+	// the "#" param is the fragment
+	fragment, hasFragment := params["#"]
+	delete(params, "#")
 	controllName := strings.Join(paths[:len(paths)-1], "/")
 	methodName := paths[len(paths)-1]
-	for m, t := range p.routers {
-		ok, url := p.geturl(t, "/", controllName, methodName, params, m)
-		if ok {
-			return url
+	table := p.table()
+	routers := []map[string]*Tree{table.routers}
+	for _, h := range table.hosts {
+		routers = append(routers, h.routers)
+	}
+	for _, r := range routers {
+		for m, t := range r {
+			ok, url := p.geturl(t, "/", controllName, methodName, params, m)
+			if ok {
+				if hasFragment {
+					url += "#" + (&neturl.URL{Fragment: fragment}).EscapedFragment()
+				}
+				return url
+			}
 		}
 	}
 	return ""
@@ -497,6 +862,10 @@ func (p *ControllerRegistor) geturl(t *Tree, url, controllName, methodName strin
 					}
 				}
 				if find {
+					if v, ok := params[l.splatName]; ok && l.splatName != "" {
+						delete(params, l.splatName)
+						params[":splat"] = v
+					}
 					if l.regexps == nil {
 						if len(l.wildcards) == 0 {
 							return true, strings.Replace(url, "/"+url_placeholder, "", 1) + tourl(params)
@@ -504,7 +873,7 @@ func (p *ControllerRegistor) geturl(t *Tree, url, controllName, methodName strin
 						if len(l.wildcards) == 1 {
 							if v, ok := params[l.wildcards[0]]; ok {
 								delete(params, l.wildcards[0])
-								return true, strings.Replace(url, url_placeholder, v, 1) + tourl(params)
+								return true, strings.Replace(url, url_placeholder, pathParam(l.wildcards[0], v), 1) + tourl(params)
 							} else {
 								return false, ""
 							}
@@ -526,7 +895,7 @@ func (p *ControllerRegistor) geturl(t *Tree, url, controllName, methodName strin
 							}
 							if u, ok := params[v]; ok {
 								delete(params, v)
-								url = strings.Replace(url, url_placeholder, u, 1)
+								url = strings.Replace(url, url_placeholder, pathParam(v, u), 1)
 							} else {
 								if canskip {
 									canskip = false
@@ -581,24 +950,46 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	var findrouter bool
 	var runMethod string
 	var routerInfo *controllerInfo
+	var body *limitedBody // the limited request body, checked again once it's read
 
+	state := p.pool.Get().(*requestState)
+	defer p.pool.Put(state)
 	w := &responseWriter{writer: rw}
+	state.match.done = false
 
 	if RunMode == "dev" {
 		w.Header().Set("Server", BeegoServerName)
 	}
 
-	// init context
-	context := &beecontext.Context{
-		ResponseWriter: w,
-		Request:        r,
-		Input:          beecontext.NewInput(r),
-		Output:         beecontext.NewOutput(),
+	var span *tracing.Span
+	if tracing.Enabled() {
+		r, span = startRequestSpan(r)
+	}
+	var event *RequestEvent
+	if RequestEvents {
+		event = &RequestEvent{Time: starttime}
+		r = r.WithContext(tracing.ContextWithStats(r.Context(), &event.stats))
 	}
-	context.Output.Context = context
+
+	// init context
+	context := beecontext.NewContext()
+	context.Reset(w, r)
 	context.Output.EnableGzip = EnableGzip
 
+	if span != nil {
+		defer func() { endRequestSpan(span, context, w, routerInfo) }()
+	}
+	if EnableMetrics {
+		httpInFlight.Inc()
+		defer func() { recordRequestMetrics(context, w, routerInfo, findrouter, starttime) }()
+	}
+	if event != nil {
+		context.Input.SetData(requestEventKey, event)
+		defer func() { endRequestEvent(event, context, w, routerInfo) }()
+	}
 	defer p.recoverPanic(context)
+	defer stopTimeout(context)
+	defer context.RunDefers()
 
 	var urlPath string
 	if !RouterCaseSensitive {
@@ -608,15 +999,21 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	}
 	// defined filter function
 	do_filter := func(pos int) (started bool) {
-		if p.enableFilter {
-			if l, ok := p.filters[pos]; ok {
-				for _, filterR := range l {
-					if ok, p := filterR.ValidRouter(urlPath); ok {
-						context.Input.Params = p
-						filterR.filterFunc(context)
-						if filterR.returnOnOutput && w.started {
-							return true
-						}
+		if l := p.getFilters(pos); len(l) > 0 {
+			defer timeFilters(event, pos, time.Now())
+			for _, filterR := range l {
+				state.params = state.params[:0]
+				if filterR.matchParams(urlPath, &state.params) {
+					context.Input.ResetParams()
+					for _, p := range state.params {
+						context.Input.SetParam(p.Key, p.Value)
+					}
+					if anyCondition(context, filterR.skips) {
+						continue
+					}
+					filterR.filterFunc(context)
+					if filterR.returnOnOutput && w.started {
+						return true
 					}
 				}
 			}
@@ -642,25 +1039,42 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		goto Admin
 	}
 
-	// session init
+	// session init, started when it's first used
 	if SessionOn {
-		var err error
-		context.Input.CruSession, err = GlobalSessions.SessionStart(w, r)
-		if err != nil {
-			Error(err)
-			exception("503", context)
-			return
-		}
+		context.Input.SetSessionManager(GlobalSessions, w)
 		defer func() {
-			context.Input.CruSession.SessionRelease(w)
+			if context.Input.CruSession != nil {
+				context.Input.CruSession.SessionRelease(w)
+			}
 		}()
 	}
 
 	if r.Method != "GET" && r.Method != "HEAD" {
-		if CopyRequestBody && !context.Input.IsUpload() {
-			context.Input.CopyBody()
+		route := p.bodyRoute(context, urlPath, &state.match)
+		if route != nil && route.bodyLimit > 0 {
+			limitRequestBody(context, route.bodyLimit)
+		} else if _, ok := r.Body.(*limitedBody); !ok && MaxRequestBodySize > 0 {
+			limitRequestBody(context, MaxRequestBodySize)
+		}
+		if w.started {
+			goto Admin
+		}
+		body, _ = r.Body.(*limitedBody)
+		if route.copiesBody() && !context.Input.IsUpload() {
+			// the body is copied before it's needed, by a handler or before
+			// the form is parsed from it
+			context.Input.LazyCopyBody = true
+			if strings.Contains(context.Input.Header("Content-Type"), "application/x-www-form-urlencoded") {
+				context.Input.CopyBody()
+			}
+		}
+		if route == nil || !route.streamUpload || !context.Input.IsUpload() {
+			context.Input.ParseFormOrMulitForm(MaxMemory)
+		}
+		if body != nil && body.exceeded {
+			bodyTooLarge(context, body.limit)
+			goto Admin
 		}
-		context.Input.ParseFormOrMulitForm(MaxMemory)
 	}
 
 	if do_filter(BeforeRouter) {
@@ -684,26 +1098,50 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 			http_method = "DELETE"
 		}
 
-		if t, ok := p.routers[http_method]; ok {
-			runObject, p := t.Match(urlPath)
-			if r, ok := runObject.(*controllerInfo); ok {
-				routerInfo = r
-				findrouter = true
-				if splat, ok := p[":splat"]; ok {
-					splatlist := strings.Split(splat, "/")
-					for k, v := range splatlist {
-						p[strconv.Itoa(k)] = v
-					}
+		route, redirect := p.matchOnce(context, &state.match, http_method, urlPath)
+		if redirect != "" {
+			code := http.StatusPermanentRedirect
+			if r.Method == "GET" || r.Method == "HEAD" {
+				code = http.StatusMovedPermanently
+			}
+			if context.Request.URL.RawQuery != "" {
+				redirect += "?" + context.Request.URL.RawQuery
+			}
+			// through the timeout of the BeforeRouter filters if any
+			http.Redirect(context.ResponseWriter, context.Request, redirect, code)
+			goto Admin
+		}
+		if route != nil {
+			routerInfo = route
+			findrouter = true
+			context.Input.RoutePattern = route.pattern
+			context.Input.RouteMeta = route.meta
+			context.Input.ResetParams()
+			for _, p := range state.match.params {
+				context.Input.SetParam(p.Key, p.Value)
+			}
+			if splat, ok := state.match.params.Get(":splat"); ok {
+				for k, v := range splatSegments(r.URL, splat) {
+					context.Input.SetParam(strconv.Itoa(k), v)
 				}
-				context.Input.Params = p
 			}
 		}
 
 	}
 
-	//if no matches to url, throw a not found exception
+	//if no matches to url, throw a not found exception,
+	//if it matches for other methods, answer OPTIONS or throw a not allowed exception
 	if !findrouter {
-		exception("404", context)
+		if allow := p.allowedMethods(context, urlPath); len(allow) > 0 {
+			context.ResponseWriter.Header().Set("Allow", strings.Join(allow, ", "))
+			if r.Method == "OPTIONS" {
+				context.ResponseWriter.WriteHeader(http.StatusOK)
+			} else {
+				p.exception("405", context, urlPath)
+			}
+			goto Admin
+		}
+		p.exception("404", context, urlPath)
 		goto Admin
 	}
 
@@ -712,6 +1150,22 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		if do_filter(BeforeExec) {
 			goto Admin
 		}
+		if routerInfo != nil && runMiddlewares(routerInfo.middlewares, context, w) {
+			goto Admin
+		}
+		if context.Input.LazyCopyBody && context.Input.RequestBody == nil {
+			context.Input.CopyBody()
+		}
+		// also when the body was copied by a filter
+		if body != nil && body.exceeded {
+			bodyTooLarge(context, body.limit)
+			goto Admin
+		}
+		// the client went away or the request timed out
+		if context.Context().Err() != nil {
+			goto Admin
+		}
+		event.startDispatch()
 		isRunable := false
 		if routerInfo != nil {
 			if routerInfo.routerType == routerTypeRESTFul {
@@ -719,12 +1173,13 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 					isRunable = true
 					routerInfo.runfunction(context)
 				} else {
-					exception("405", context)
+					context.ResponseWriter.Header().Set("Allow", strings.Join(p.allowedMethods(context, urlPath), ", "))
+					p.exception("405", context, urlPath)
 					goto Admin
 				}
 			} else if routerInfo.routerType == routerTypeHandler {
 				isRunable = true
-				routerInfo.handler.ServeHTTP(rw, r)
+				routerInfo.handler.ServeHTTP(context.ResponseWriter, context.Request)
 			} else {
 				runrouter = routerInfo.controllerType
 				method := r.Method
@@ -748,13 +1203,14 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 		if !isRunable {
 			//Invoke the request handler
 			vc := reflect.New(runrouter)
-			execController, ok := vc.Interface().(ControllerInterface)
+			app := vc.Interface()
+			execController, ok := app.(ControllerInterface)
 			if !ok {
 				panic("controller is not ControllerInterface")
 			}
 
 			//call the controller init function
-			execController.Init(context, runrouter.Name(), runMethod, vc.Interface())
+			execController.Init(context, runrouter.Name(), runMethod, app)
 
 			//call prepare function
 			execController.Prepare()
@@ -789,18 +1245,21 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 					execController.Options()
 				default:
 					if !execController.HandlerFunc(runMethod) {
-						in := make([]reflect.Value, 0)
-						method := vc.MethodByName(runMethod)
-						method.Call(in)
+						method := controllerMethod(vc, runMethod)
+						if err := returnedError(method.Call(nil)); err != nil {
+							RenderError(context, err)
+						}
 					}
 				}
 
 				//render template
 				if !w.started && context.Output.Status == 0 {
 					if AutoRender {
+						renderstart := time.Now()
 						if err := execController.Render(); err != nil {
 							panic(err)
 						}
+						event.timeRender(renderstart)
 					}
 				}
 			}
@@ -808,6 +1267,7 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 			// finish all runrouter. release resource
 			execController.Finish()
 		}
+		event.endDispatch()
 
 		//execute middleware filters
 		if do_filter(AfterExec) {
@@ -818,14 +1278,21 @@ func (p *ControllerRegistor) ServeHTTP(rw http.ResponseWriter, r *http.Request)
 	do_filter(FinishRouter)
 
 Admin:
+	stopTimeout(context)
 	timeend := time.Since(starttime)
 	//admin module record QPS
 	if EnableAdmin {
+		toolbox.TimeSeriesData.AddRequest(timeend)
 		if FilterMonitorFunc(r.Method, r.URL.Path, timeend) {
+			// by route, the urls of a route with params would fill the map
+			statPath := r.URL.Path
+			if context.Input.RoutePattern != "" {
+				statPath = context.Input.RoutePattern
+			}
 			if runrouter != nil {
-				go toolbox.StatisticsMap.AddStatistics(r.Method, r.URL.Path, runrouter.Name(), timeend)
+				go toolbox.StatisticsMap.AddStatistics(r.Method, statPath, runrouter.Name(), timeend)
 			} else {
-				go toolbox.StatisticsMap.AddStatistics(r.Method, r.URL.Path, "", timeend)
+				go toolbox.StatisticsMap.AddStatistics(r.Method, statPath, "", timeend)
 			}
 		}
 	}
@@ -852,61 +1319,101 @@ Admin:
 	}
 }
 
+// splatSegments splits the :splat param in the segments of the request
+// path, the escaped slashes staying in their segment.
+func splatSegments(u *neturl.URL, splat string) []string {
+	if u.RawPath == "" {
+		return strings.Split(splat, "/")
+	}
+	segments := strings.Split(u.RawPath, "/")
+	for i, s := range segments {
+		if v, err := neturl.PathUnescape(s); err == nil {
+			segments[i] = v
+		}
+		if !RouterCaseSensitive {
+			segments[i] = strings.ToLower(segments[i])
+		}
+	}
+	for i := range segments {
+		joined := segments[i]
+		for j := i; len(joined) <= len(splat); j++ {
+			if joined == splat {
+				return segments[i : j+1]
+			}
+			if j+1 == len(segments) {
+				break
+			}
+			joined += "/" + segments[j+1]
+		}
+	}
+	return strings.Split(splat, "/")
+}
+
+// allowedMethods returns the sorted http methods having a route for urlPath,
+// with OPTIONS when there's one.
+func (p *ControllerRegistor) allowedMethods(ctx *beecontext.Context, urlPath string) []string {
+	var allow []string
+	var params Params
+	for m := range HTTPMETHOD {
+		params = params[:0]
+		if r, _ := p.matchRoute(ctx, m, urlPath, &params); r != nil && m != "OPTIONS" {
+			allow = append(allow, m)
+		}
+	}
+	if len(allow) == 0 {
+		return nil
+	}
+	allow = append(allow, "OPTIONS")
+	sort.Strings(allow)
+	return allow
+}
+
 func (p *ControllerRegistor) recoverPanic(context *beecontext.Context) {
 	if err := recover(); err != nil {
 		if err == USERSTOPRUN {
 			return
 		}
+		// the mapped errors are responses, not crashes.
+		if e, ok := err.(error); ok && AsHTTPError(e) != nil {
+			RenderError(context, e)
+			return
+		}
+		if !RecoverPanic {
+			panic(err)
+		}
+		if ErrorsShow {
+			if handler, ok := ErrorMaps[fmt.Sprint(err)]; ok {
+				executeError(handler, context)
+				return
+			}
+		}
+		var stack string
+		Critical("the request url is ", context.Input.Url())
+		Critical("Handler crashed with error", err)
+		for i := 1; ; i++ {
+			_, file, line, ok := runtime.Caller(i)
+			if !ok {
+				break
+			}
+			Critical(fmt.Sprintf("%s:%d", file, line))
+			stack = stack + fmt.Sprintln(fmt.Sprintf("%s:%d", file, line))
+		}
+		runPanicHooks(context, err, stack)
+		if render := panicRendererFor(context); render != nil {
+			render(context, err, stack)
+			return
+		}
 		if RunMode == "dev" {
-			if !RecoverPanic {
-				panic(err)
+			showErr(err, context, stack)
+		} else if ErrorsShow {
+			// in production model show all infomation
+			if handler, ok := ErrorMaps["503"]; ok {
+				executeError(handler, context)
 			} else {
-				if ErrorsShow {
-					if handler, ok := ErrorMaps[fmt.Sprint(err)]; ok {
-						executeError(handler, context)
-						return
-					}
-				}
-				var stack string
-				Critical("the request url is ", context.Input.Url())
-				Critical("Handler crashed with error", err)
-				for i := 1; ; i++ {
-					_, file, line, ok := runtime.Caller(i)
-					if !ok {
-						break
-					}
-					Critical(fmt.Sprintf("%s:%d", file, line))
-					stack = stack + fmt.Sprintln(fmt.Sprintf("%s:%d", file, line))
-				}
-				showErr(err, context, stack)
+				context.WriteString(fmt.Sprint(err))
 			}
 		} else {
-			if !RecoverPanic {
-				panic(err)
-			} else {
-				// in production model show all infomation
-				if ErrorsShow {
-					if handler, ok := ErrorMaps[fmt.Sprint(err)]; ok {
-						executeError(handler, context)
-						return
-					} else if handler, ok := ErrorMaps["503"]; ok {
-						executeError(handler, context)
-						return
-					} else {
-						context.WriteString(fmt.Sprint(err))
-					}
-				} else {
-					Critical("the request url is ", context.Input.Url())
-					Critical("Handler crashed with error", err)
-					for i := 1; ; i++ {
-						_, file, line, ok := runtime.Caller(i)
-						if !ok {
-							break
-						}
-						Critical(fmt.Sprintf("%s:%d", file, line))
-					}
-				}
-			}
+			context.ResponseWriter.WriteHeader(http.StatusInternalServerError)
 		}
 	}
 }
@@ -917,6 +1424,19 @@ type responseWriter struct {
 	writer  http.ResponseWriter
 	started bool
 	status  int
+	size    int64
+}
+
+// responseStatus returns the status of the response of ctx, 200 when none
+// was set.
+func responseStatus(ctx *beecontext.Context, w *responseWriter) int {
+	if w.status != 0 {
+		return w.status
+	}
+	if ctx.Output.Status != 0 {
+		return ctx.Output.Status
+	}
+	return http.StatusOK
 }
 
 // Header returns the header map that will be sent by WriteHeader.
@@ -929,7 +1449,9 @@ func (w *responseWriter) Header() http.Header {
 // started means the response has sent out.
 func (w *responseWriter) Write(p []byte) (int, error) {
 	w.started = true
-	return w.writer.Write(p)
+	n, err := w.writer.Write(p)
+	w.size += int64(n)
+	return n, err
 }
 
 // WriteHeader sends an HTTP response header with status code,
@@ -940,22 +1462,45 @@ func (w *responseWriter) WriteHeader(code int) {
 	w.writer.WriteHeader(code)
 }
 
+// Flush sends the data written to the client, for the streamed responses.
+func (w *responseWriter) Flush() {
+	if f, ok := w.writer.(http.Flusher); ok {
+		w.started = true
+		f.Flush()
+	}
+}
+
 // hijacker for http
 func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	hj, ok := w.writer.(http.Hijacker)
 	if !ok {
 		return nil, nil, errors.New("webserver doesn't support hijacking")
 	}
-	return hj.Hijack()
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		// the connection is answered by its new owner
+		w.started = true
+	}
+	return conn, rw, err
 }
 
+// tourl returns the query string of the params not in the path, sorted by key.
 func tourl(params map[string]string) string {
 	if len(params) == 0 {
 		return ""
 	}
-	u := "?"
+	q := make(neturl.Values, len(params))
 	for k, v := range params {
-		u += k + "=" + v + "&"
+		q.Set(k, v)
+	}
+	return "?" + q.Encode()
+}
+
+// pathParam escapes the value of a param of the path, but the ones of
+// several segments.
+func pathParam(name, value string) string {
+	if name == ":splat" || name == ":path" {
+		return value
 	}
-	return strings.TrimRight(u, "&")
+	return neturl.PathEscape(value)
 }
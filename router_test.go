@@ -15,12 +15,17 @@
 package beego
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/session"
+	"github.com/aamsur/beego/toolbox"
 )
 
 type TestController struct {
@@ -97,6 +102,46 @@ func TestUrlFor(t *testing.T) {
 	}
 }
 
+type urlForController struct {
+	Controller
+}
+
+func (c *urlForController) Show() {
+	c.Ctx.WriteString(c.AbsUrlFor(".Show", ":name", c.Ctx.Input.Param(":name"), "#", "top"))
+}
+
+func TestUrlForQueryAndHost(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Add("/person/:last/:first", &TestController{}, "*:Param")
+	handler.Add("/files/*", &TestController{}, "*:List")
+	if a := handler.UrlFor("TestController.Param", ":last", "xie", ":first", "a b", "page", 2, "q", "x&y=z"); a != "/person/xie/a%20b?page=2&q=x%26y%3Dz" {
+		t.Error("query params must be escaped and sorted, got " + a)
+	}
+	if a := handler.UrlFor("TestController.Param", ":last", "xie", ":first", "asta", "#", "bio note"); a != "/person/xie/asta#bio%20note" {
+		t.Error("the # param must be the fragment, got " + a)
+	}
+	if a := handler.UrlFor("TestController.List", ":splat", "a/b.txt"); a != "/files/a/b.txt" {
+		t.Error("the splat must keep its slashes, got " + a)
+	}
+
+	BeeApp.Handlers.Add("/urlfor/:name", &urlForController{}, "get:Show")
+	rw, r := testRequest("GET", "http://example.com:8080/urlfor/tom")
+	BeeApp.Handlers.ServeHTTP(rw, r)
+	if rw.Body.String() != "http://example.com:8080/urlfor/tom#top" {
+		t.Error("AbsUrlFor must use the host of the request, got " + rw.Body.String())
+	}
+	defer func() { BaseURL = "" }()
+	BaseURL = "https://shop.example.com"
+	rw, r = testRequest("GET", "http://example.com:8080/urlfor/tom")
+	BeeApp.Handlers.ServeHTTP(rw, r)
+	if rw.Body.String() != "https://shop.example.com/urlfor/tom#top" {
+		t.Error("AbsUrlFor must use BaseURL, got " + rw.Body.String())
+	}
+	if a := AbsUrlFor("urlForController.Show", ":name", "jerry"); a != "https://shop.example.com/urlfor/jerry" {
+		t.Error("AbsUrlFor must use BaseURL, got " + a)
+	}
+}
+
 func TestUrlFor3(t *testing.T) {
 	handler := NewControllerRegister()
 	handler.AddAuto(&TestController{})
@@ -251,6 +296,228 @@ func TestNotFound(t *testing.T) {
 	}
 }
 
+type nestedController struct {
+	Controller
+}
+
+func (c *nestedController) Get() {
+	c.Ctx.WriteString("get " + c.Ctx.Input.Param(":userId") + " " + c.Ctx.Input.Param(":parentId") + " " + c.Ctx.Input.Param(":objectId"))
+}
+
+func (c *nestedController) Delete() {
+	c.Ctx.WriteString("delete " + c.Ctx.Input.Param(":parentId") + " " + c.Ctx.Input.Param(":objectId"))
+}
+
+func TestRESTNestedRouter(t *testing.T) {
+	RESTNestedRouter("/nested/user", "post", &nestedController{})
+	RESTNestedRouter("/nested/user/:userId/post", "comment", &nestedController{})
+	for _, c := range []struct{ method, url, body string }{
+		{"GET", "/nested/user/1/post", "get  1 "},
+		{"GET", "/nested/user/1/post/2", "get  1 2"},
+		{"DELETE", "/nested/user/1/post/2", "delete 1 2"},
+		{"GET", "/nested/user/1/post/2/comment/3", "get 1 2 3"},
+	} {
+		r, _ := http.NewRequest(c.method, c.url, nil)
+		w := httptest.NewRecorder()
+		BeeApp.Handlers.ServeHTTP(w, r)
+		if w.Body.String() != c.body {
+			t.Errorf("%s %s: got %q, want %q", c.method, c.url, w.Body.String(), c.body)
+		}
+	}
+	r, _ := http.NewRequest("GET", "/nested/user/1", nil)
+	w := httptest.NewRecorder()
+	BeeApp.Handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("the parent resource should not be routed, got %d", w.Code)
+	}
+}
+
+func TestConstraintNotFound(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Get("/user/:id:int", func(ctx *context.Context) {
+		ctx.Output.Body([]byte(ctx.Input.Param(":id")))
+	})
+	for url, code := range map[string]int{"/user/42": http.StatusOK, "/user/abc": http.StatusNotFound} {
+		r, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Errorf("%s: code set to [%v]; want [%v]", url, w.Code, code)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Get("/item/:id", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("get"))
+	})
+	handler.Delete("/item/:id", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("delete"))
+	})
+	handler.Add("/mapped", &TestController{}, "post:List")
+
+	for _, c := range []struct {
+		method, url string
+		code        int
+		allow       string
+	}{
+		{"GET", "/item/1", http.StatusOK, ""},
+		{"PUT", "/item/1", http.StatusMethodNotAllowed, "DELETE, GET, OPTIONS"},
+		{"OPTIONS", "/item/1", http.StatusOK, "DELETE, GET, OPTIONS"},
+		{"GET", "/mapped", http.StatusMethodNotAllowed, "OPTIONS, POST"},
+		{"OPTIONS", "/mapped", http.StatusOK, "OPTIONS, POST"},
+		{"PUT", "/missing", http.StatusNotFound, ""},
+		{"OPTIONS", "/missing", http.StatusNotFound, ""},
+	} {
+		r, _ := http.NewRequest(c.method, c.url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s %s: code is %d, want %d", c.method, c.url, w.Code, c.code)
+		}
+		if allow := w.HeaderMap.Get("Allow"); allow != c.allow {
+			t.Errorf("%s %s: Allow is %q, want %q", c.method, c.url, allow, c.allow)
+		}
+	}
+}
+
+func TestOptionsRoute(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Get("/item", func(ctx *context.Context) {})
+	handler.Options("/item", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("custom"))
+	})
+	r, _ := http.NewRequest("OPTIONS", "/item", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "custom" || w.HeaderMap.Get("Allow") != "" {
+		t.Errorf("the OPTIONS route should answer, got %q", w.Body.String())
+	}
+}
+
+func TestWithMeta(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.InsertFilter("/*", BeforeExec, func(ctx *context.Context) {
+		scopes, _ := ctx.Input.Meta("scopes").([]string)
+		if len(scopes) > 0 && ctx.Input.Query("scope") != scopes[0] {
+			ctx.Output.SetStatus(http.StatusForbidden)
+			ctx.Output.Body([]byte("forbidden " + ctx.Input.RoutePattern))
+		}
+	})
+	handler.Get("/users/:id", func(ctx *context.Context) {
+		ctx.Output.Body([]byte(fmt.Sprint("user ", ctx.Input.HasTag("users"), ctx.Input.HasTag("admin"))))
+	}, WithMeta("scopes", []string{"users:read"}), WithTags("users"), WithTags("public"))
+	handler.Get("/health", func(ctx *context.Context) {
+		ctx.Output.Body([]byte(fmt.Sprint("health ", ctx.Input.RouteMeta == nil)))
+	})
+
+	for _, c := range []struct{ url, body string }{
+		{"/users/1", "forbidden /users/:id"},
+		{"/users/1?scope=users:read", "user true false"},
+		{"/health", "health true"},
+	} {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != c.body {
+			t.Errorf("%s: got %q, want %q", c.url, w.Body.String(), c.body)
+		}
+	}
+	for _, info := range handler.Routes() {
+		if info.Pattern == "/users/:id" && fmt.Sprint(info.Meta) != "map[scopes:[users:read] tags:[users public]]" {
+			t.Errorf("Routes should list the metadata, got %v", info.Meta)
+		}
+	}
+}
+
+func TestWithFilters(t *testing.T) {
+	var order []string
+	mark := func(name string) FilterFunc {
+		return func(ctx *context.Context) {
+			order = append(order, name)
+		}
+	}
+	deny := func(ctx *context.Context) {
+		if ctx.Input.Query("token") == "" {
+			ctx.Output.SetStatus(http.StatusUnauthorized)
+			ctx.Output.Body([]byte("denied"))
+		}
+	}
+	handler := NewControllerRegister()
+	handler.Add("/admin", &TestController{}, "get:List", WithFilters(deny))
+	handler.Get("/fn", func(ctx *context.Context) {
+		order = append(order, "fn")
+	}, WithFilters(mark("a"), mark("b")))
+	handler.Handler("/h", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("h"))
+	}), WithFilters(deny))
+	handler.Get("/open", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("open"))
+	})
+
+	for _, c := range []struct{ url, body string }{
+		{"/admin", "denied"},
+		{"/admin?token=1", "i am list"},
+		{"/h", "denied"},
+		{"/h?token=1", "h"},
+		{"/open", "open"},
+	} {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != c.body {
+			t.Errorf("%s: got %q, want %q", c.url, w.Body.String(), c.body)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/fn", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if strings.Join(order, ",") != "a,b,fn" {
+		t.Errorf("the route filters should run in order before the route, got %v", order)
+	}
+
+	order = nil
+	ns := NewNamespace("/routefilters",
+		NSUse(mark("ns")),
+		NSGet("/fn", func(ctx *context.Context) {
+			order = append(order, "fn")
+		}, WithFilters(mark("route"))),
+	)
+	AddNamespace(ns)
+	r, _ = http.NewRequest("GET", "/routefilters/fn", nil)
+	BeeApp.Handlers.ServeHTTP(httptest.NewRecorder(), r)
+	if strings.Join(order, ",") != "ns,route,fn" {
+		t.Errorf("the namespace middlewares should run before the route filters, got %v", order)
+	}
+}
+
+func (tc *TestController) Files() {
+	tc.Ctx.WriteString(tc.Ctx.Input.Param(":filepath") + "|" + tc.Ctx.Input.Param("0") + "|" + tc.Ctx.Input.Param("1"))
+}
+
+func TestCatchAllParam(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Add("/files/*filepath", &TestController{}, "get:Files")
+
+	for u, body := range map[string]string{
+		"/files/a/b.txt":     "a/b.txt|a|b.txt",
+		"/files/a%2Fb/c":     "a/b/c|a/b|c",
+		"/files/x%20y/a%2Fb": "x y/a/b|x y|a/b",
+	} {
+		r, _ := http.NewRequest("GET", u, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != body {
+			t.Errorf("%s: got %q, want %q", u, w.Body.String(), body)
+		}
+	}
+
+	if u := handler.UrlFor("TestController.Files", ":filepath", "a/b.txt"); u != "/files/a/b.txt" {
+		t.Errorf("UrlFor should fill the catch-all param, got %q", u)
+	}
+}
+
 // TestStatic tests the ability to serve static
 // content from the filesystem
 func TestStatic(t *testing.T) {
@@ -380,6 +647,79 @@ func BenchmarkController(b *testing.B) {
 	}
 }
 
+func TestContextsKept(t *testing.T) {
+	mux := NewControllerRegister()
+	var kept []*context.Context
+	var params []map[string]string
+	mux.Get("/user/:name", func(ctx *context.Context) {
+		kept = append(kept, ctx)
+		params = append(params, ctx.Input.Params)
+	})
+	for _, path := range []string{"/user/astaxie", "/user/slene"} {
+		rw, r := testRequest("GET", path)
+		mux.ServeHTTP(rw, r)
+	}
+	if kept[0] == kept[1] || kept[0].Input.Param(":name") != "astaxie" || params[0][":name"] != "astaxie" {
+		t.Error("a context kept by a handler should not be reused by the next request")
+	}
+}
+
+func TestRequestContexts(t *testing.T) {
+	mux := NewControllerRegister()
+	mux.Get("/user/:name", func(ctx *context.Context) {
+		if ctx.Input.GetData("seen") != nil {
+			t.Error("the data of the last request leaked")
+		}
+		ctx.Input.SetData("seen", true)
+		ctx.WriteString(ctx.Input.Param(":name"))
+	})
+	mux.Get("/about", func(ctx *context.Context) {
+		ctx.WriteString(ctx.Input.Param(":name") + strconv.Itoa(ctx.Input.ParamsLen()))
+	})
+	for _, test := range [][2]string{{"/user/astaxie", "astaxie"}, {"/about", "0"}, {"/user/slene", "slene"}, {"/about", "0"}} {
+		rw, r := testRequest("GET", test[0])
+		mux.ServeHTTP(rw, r)
+		if rw.Body.String() != test[1] {
+			t.Errorf("%s should answer %q, got %q", test[0], test[1], rw.Body.String())
+		}
+	}
+}
+
+// discardWriter is a response writer for the benchmarks, dropping the body.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// BenchmarkServeHTTP measures the allocations of the router, without the
+// dev logs.
+func BenchmarkServeHTTP(b *testing.B) {
+	defer func(mode string) { RunMode = mode }(RunMode)
+	RunMode = "prod"
+	mux := NewControllerRegister()
+	mux.Get("/func", beegoFilterFunc)
+	mux.Add("/controller", &TestController{}, "get:List")
+	mux.Add("/user/:name/:id:int", &TestController{}, "get:Param")
+
+	for _, path := range []string{"/func", "/controller", "/user/astaxie/42"} {
+		b.Run(path, func(b *testing.B) {
+			r, _ := http.NewRequest("GET", path, nil)
+			w := &discardWriter{header: make(http.Header)}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				mux.ServeHTTP(w, r)
+				for k := range w.header {
+					delete(w.header, k)
+				}
+			}
+		})
+	}
+}
+
 func testRequest(method, path string) (*httptest.ResponseRecorder, *http.Request) {
 	request, _ := http.NewRequest(method, path, nil)
 	recorder := httptest.NewRecorder()
@@ -579,3 +919,110 @@ func beegoFinishRouter1(ctx *context.Context) {
 func beegoFinishRouter2(ctx *context.Context) {
 	ctx.WriteString("|FinishRouter2")
 }
+
+func TestPanicHooks(t *testing.T) {
+	defer func() { panicHooks, panicRenderers = nil, nil }()
+	var reported interface{}
+	AddPanicHook(func(ctx *context.Context, err interface{}, stack string) {
+		reported = err
+	})
+	SetPanicRenderer("application/json", func(ctx *context.Context, err interface{}, stack string) {
+		ctx.Output.SetStatus(500)
+		ctx.Output.Json(map[string]string{"error": "internal"}, false, false)
+	})
+
+	handler := NewControllerRegister()
+	handler.Get("/crash", func(ctx *context.Context) {
+		panic("boom")
+	})
+	r, _ := http.NewRequest("GET", "/crash", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if reported != "boom" {
+		t.Errorf("panic hook should receive the panic value, got %v", reported)
+	}
+	if w.Code != 500 || w.Body.String() != `{"error":"internal"}` {
+		t.Errorf("json renderer should write the response, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+type sessionController struct {
+	Controller
+}
+
+func (c *sessionController) Login() {
+	c.SetSession("uid", "astaxie")
+	c.Ctx.Output.Body([]byte("ok"))
+}
+
+func (c *sessionController) Whoami() {
+	c.Ctx.Output.Body([]byte(fmt.Sprint(c.GetSession("uid"))))
+}
+
+func TestLazySession(t *testing.T) {
+	defer func(on bool, sessions *session.Manager) {
+		SessionOn, GlobalSessions = on, sessions
+	}(SessionOn, GlobalSessions)
+	SessionOn = true
+	GlobalSessions, _ = session.NewManager("memory", `{"cookieName":"gosessionid","enableSetCookie":true,"gclifetime":3600}`)
+
+	handler := NewControllerRegister()
+	handler.Add("/login", &sessionController{}, "get:Login")
+	handler.Add("/whoami", &sessionController{}, "get:Whoami")
+	handler.Get("/api", func(ctx *context.Context) {
+		ctx.Output.Body([]byte("api"))
+	})
+
+	r, _ := http.NewRequest("GET", "/api", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if cookie := w.Header().Get("Set-Cookie"); cookie != "" || GlobalSessions.GetActiveSession() != 0 {
+		t.Errorf("a request not using the session should not start one, got the cookie %q", cookie)
+	}
+
+	r, _ = http.NewRequest("GET", "/login", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	cookie := w.Header().Get("Set-Cookie")
+	if !strings.HasPrefix(cookie, "gosessionid=") {
+		t.Fatalf("got the cookie %q, want the session one", cookie)
+	}
+
+	r, _ = http.NewRequest("GET", "/whoami", nil)
+	r.Header.Set("Cookie", strings.SplitN(cookie, ";", 2)[0])
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "astaxie" {
+		t.Errorf("got %q, want the value of the session", w.Body.String())
+	}
+}
+
+func TestRouteStatistics(t *testing.T) {
+	defer func(enable bool) { EnableAdmin = enable }(EnableAdmin)
+	EnableAdmin = true
+
+	handler := NewControllerRegister()
+	handler.Get("/stats/:id", func(ctx *context.Context) {
+		ctx.Output.Body([]byte(ctx.Input.RoutePattern))
+	})
+	for _, url := range []string{"/stats/1", "/stats/2"} {
+		r, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != "/stats/:id" {
+			t.Errorf("got the pattern %q", w.Body.String())
+		}
+	}
+
+	// the statistics are added asynchronously
+	for i := 0; i < 100; i++ {
+		for _, row := range toolbox.StatisticsMap.GetMap()["Data"].([][]string) {
+			if strings.TrimSpace(row[0]) == "/stats/:id" && strings.TrimSpace(row[2]) == "2" {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("the requests should be counted by route, got %v", toolbox.StatisticsMap.GetMap()["Data"])
+}
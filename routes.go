@@ -0,0 +1,102 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aamsur/beego/utils"
+)
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method      string                 `json:"method"`
+	Pattern     string                 `json:"pattern"`
+	Host        string                 `json:"host,omitempty"`
+	Type        string                 `json:"type"`                  // controller, func or handler
+	Controller  string                 `json:"controller,omitempty"`  // package path and name of the controller type
+	Action      string                 `json:"action"`                // controller method, func or handler type
+	Middlewares []string               `json:"middlewares,omitempty"` // of the namespaces and the route filters, in execution order
+	Filters     []FilterInfo           `json:"filters,omitempty"`     // whose pattern matches the route pattern, in execution order
+	Meta        map[string]interface{} `json:"meta,omitempty"`        // of WithMeta and WithTags
+}
+
+// Routes returns the routes of BeeApp.
+func Routes() []RouteInfo {
+	return BeeApp.Handlers.Routes()
+}
+
+// Routes returns the registered routes sorted by host, pattern and method.
+func (p *ControllerRegistor) Routes() []RouteInfo {
+	var infos []RouteInfo
+	add := func(host string, routers map[string]*Tree) {
+		for method, t := range routers {
+			seen := make(map[*controllerInfo]bool)
+			walkRoutes(t, func(c *controllerInfo) {
+				if !seen[c] {
+					seen[c] = true
+					infos = append(infos, p.routeInfo(host, method, c))
+				}
+			})
+		}
+	}
+	table := p.table()
+	add("", table.routers)
+	for _, h := range table.hosts {
+		add(h.host, h.routers)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		return a.Method < b.Method
+	})
+	return infos
+}
+
+func (p *ControllerRegistor) routeInfo(host, method string, c *controllerInfo) RouteInfo {
+	info := RouteInfo{Method: method, Pattern: c.pattern, Host: host}
+	switch c.routerType {
+	case routerTypeBeego:
+		info.Type = "controller"
+		info.Controller = path.Join(c.controllerType.PkgPath(), c.controllerType.Name())
+		if m, ok := c.methods[method]; ok {
+			info.Action = m
+		} else if m, ok := c.methods["*"]; ok {
+			info.Action = m
+		} else {
+			info.Action = method[:1] + strings.ToLower(method[1:])
+		}
+	case routerTypeRESTFul:
+		info.Type = "func"
+		info.Action = utils.GetFuncName(c.runfunction)
+	case routerTypeHandler:
+		info.Type = "handler"
+		info.Action = fmt.Sprintf("%T", c.handler)
+	}
+	for _, m := range c.middlewares {
+		info.Middlewares = append(info.Middlewares, utils.GetFuncName(m))
+	}
+	info.Filters = p.FilterChain(c.pattern)
+	info.Meta = c.meta
+	return info
+}
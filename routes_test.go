@@ -0,0 +1,93 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+func listUsers(ctx *context.Context) {}
+
+func auditRoute(ctx *context.Context) {}
+
+func TestRoutes(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Add("/user", &TestController{}, "get:List;post:Post", WithFilters(auditRoute))
+	handler.Get("/users", listUsers)
+	handler.Handler("/h", http.NotFoundHandler())
+	handler.AddHost("api.example.com", "/", &TestController{})
+	handler.InsertFilter("/user", BeforeExec, auditRoute)
+
+	routes := handler.Routes()
+	byKey := make(map[string]RouteInfo)
+	for _, r := range routes {
+		byKey[r.Host+" "+r.Method+" "+r.Pattern] = r
+	}
+	if len(routes) != 3+len(HTTPMETHOD)*2 {
+		t.Fatalf("unexpected routes count %d", len(routes))
+	}
+	get := byKey[" GET /user"]
+	if get.Type != "controller" || get.Action != "List" || !strings.HasSuffix(get.Controller, "beego/TestController") {
+		t.Errorf("unexpected GET /user route %+v", get)
+	}
+	if len(get.Middlewares) != 1 || !strings.HasSuffix(get.Middlewares[0], ".auditRoute") {
+		t.Errorf("the route filters should be listed, got %v", get.Middlewares)
+	}
+	if len(get.Filters) != 1 || get.Filters[0].Position != "BeforeExec" {
+		t.Errorf("the filters of the pattern should be listed, got %v", get.Filters)
+	}
+	if post := byKey[" POST /user"]; post.Action != "Post" {
+		t.Errorf("unexpected POST /user route %+v", post)
+	}
+	if r := byKey[" GET /users"]; r.Type != "func" || !strings.HasSuffix(r.Action, ".listUsers") {
+		t.Errorf("unexpected GET /users route %+v", r)
+	}
+	if r := byKey[" DELETE /h"]; r.Type != "handler" || r.Action != "http.HandlerFunc" {
+		t.Errorf("unexpected DELETE /h route %+v", r)
+	}
+	if r := byKey["api.example.com PUT /"]; r.Action != "Put" {
+		t.Errorf("unexpected host route %+v", r)
+	}
+	if routes[0].Host != "" || routes[len(routes)-1].Host != "api.example.com" {
+		t.Error("the routes should be sorted by host")
+	}
+}
+
+func TestAdminRouteTable(t *testing.T) {
+	Get("/admin-routes-test", listUsers)
+	r, _ := http.NewRequest("GET", "/routes?method=get", nil)
+	w := httptest.NewRecorder()
+	routeTable(w, r)
+	var routes []RouteInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &routes); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, route := range routes {
+		if route.Method != "GET" {
+			t.Errorf("only the GET routes should be listed, got %+v", route)
+		}
+		found = found || route.Pattern == "/admin-routes-test"
+	}
+	if !found {
+		t.Error("the routes of BeeApp should be listed")
+	}
+}
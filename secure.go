@@ -0,0 +1,151 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/aamsur/beego/context"
+)
+
+// CSPNonceKey is the ctx.Input.Data key of the per-request CSP nonce.
+// in templates it is available as {{.CSPNonce}}:
+//	<script nonce="{{.CSPNonce}}">...</script>
+const CSPNonceKey = "CSPNonce"
+
+// cspNoncePlaceholder is replaced by the request nonce in ContentSecurityPolicy.
+const cspNoncePlaceholder = "{{nonce}}"
+
+// SecurityHeaders holds the security related response headers.
+// empty values are not sent.
+type SecurityHeaders struct {
+	HSTSMaxAge            int64 // Strict-Transport-Security max-age in seconds, only sent over https. 0 disables it.
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentTypeNosniff    bool   // X-Content-Type-Options: nosniff
+	FrameOptions          string // X-Frame-Options, DENY or SAMEORIGIN
+	ReferrerPolicy        string
+	// ContentSecurityPolicy may contain {{nonce}}, replaced by a random value for every request.
+	ContentSecurityPolicy string
+	CSPReportOnly         bool // send Content-Security-Policy-Report-Only instead
+}
+
+// NewSecurityHeaders returns the default security headers.
+func NewSecurityHeaders() *SecurityHeaders {
+	return &SecurityHeaders{
+		HSTSMaxAge:            31536000,
+		HSTSIncludeSubdomains: true,
+		ContentTypeNosniff:    true,
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{{nonce}}'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+	}
+}
+
+// securityHeadersFromConfig reads the security headers from app.conf.
+// a value of "off" drops the header.
+//	EnableSecureHeaders = true
+//	HSTSMaxAge = 31536000
+//	FrameOptions = DENY
+//	ContentSecurityPolicy = default-src 'self'; script-src 'nonce-{{nonce}}'
+func securityHeadersFromConfig() *SecurityHeaders {
+	h := NewSecurityHeaders()
+	h.HSTSMaxAge = AppConfig.DefaultInt64("HSTSMaxAge", h.HSTSMaxAge)
+	h.HSTSIncludeSubdomains = AppConfig.DefaultBool("HSTSIncludeSubdomains", h.HSTSIncludeSubdomains)
+	h.HSTSPreload = AppConfig.DefaultBool("HSTSPreload", h.HSTSPreload)
+	h.ContentTypeNosniff = AppConfig.DefaultBool("ContentTypeNosniff", h.ContentTypeNosniff)
+	h.CSPReportOnly = AppConfig.DefaultBool("CSPReportOnly", h.CSPReportOnly)
+	for key, value := range map[string]*string{
+		"FrameOptions":          &h.FrameOptions,
+		"ReferrerPolicy":        &h.ReferrerPolicy,
+		"ContentSecurityPolicy": &h.ContentSecurityPolicy,
+	} {
+		if v := AppConfig.String(key); v == "off" {
+			*value = ""
+		} else if v != "" {
+			*value = v
+		}
+	}
+	return h
+}
+
+// SecurityHeadersFilter returns a filter sending the given security headers.
+// when the policy uses {{nonce}}, the nonce is stored under CSPNonceKey.
+func SecurityHeadersFilter(h *SecurityHeaders) FilterFunc {
+	var hsts string
+	if h.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(h.HSTSMaxAge, 10)
+		if h.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if h.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if h.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(h.ContentSecurityPolicy, cspNoncePlaceholder)
+
+	return func(ctx *context.Context) {
+		if hsts != "" && ctx.Input.IsSecure() {
+			ctx.Output.Header("Strict-Transport-Security", hsts)
+		}
+		if h.ContentTypeNosniff {
+			ctx.Output.Header("X-Content-Type-Options", "nosniff")
+		}
+		if h.FrameOptions != "" {
+			ctx.Output.Header("X-Frame-Options", h.FrameOptions)
+		}
+		if h.ReferrerPolicy != "" {
+			ctx.Output.Header("Referrer-Policy", h.ReferrerPolicy)
+		}
+		if h.ContentSecurityPolicy != "" {
+			csp := h.ContentSecurityPolicy
+			if useNonce {
+				nonce := cspNonce()
+				ctx.Input.SetData(CSPNonceKey, nonce)
+				csp = strings.Replace(csp, cspNoncePlaceholder, nonce, -1)
+			}
+			ctx.Output.Header(cspHeader, csp)
+		}
+	}
+}
+
+func cspNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// DoubleSubmitXSRF returns a filter protecting routes with the double submit cookie,
+// for function routes and handlers which don't run the controller XSRF check.
+// every response gets the XSRFCookieName cookie, unsafe methods must echo it in XSRFHeaderName.
+//	beego.InsertFilter("/api/*", beego.BeforeRouter, beego.DoubleSubmitXSRF())
+func DoubleSubmitXSRF() FilterFunc {
+	return func(ctx *context.Context) {
+		ctx.DoubleSubmitToken(XSRFKEY, XSRFCookieName, int64(XSRFExpire))
+		switch ctx.Input.Method() {
+		case "POST", "PUT", "PATCH", "DELETE":
+			ctx.CheckDoubleSubmit(XSRFKEY, XSRFCookieName, XSRFHeaderName)
+		}
+	}
+}
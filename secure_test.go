@@ -0,0 +1,90 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	var nonce string
+	handler := NewControllerRegister()
+	handler.InsertFilter("*", BeforeStatic, SecurityHeadersFilter(NewSecurityHeaders()))
+	handler.Get("/page", func(ctx *context.Context) {
+		nonce, _ = ctx.Input.GetData(CSPNonceKey).(string)
+		ctx.WriteString("ok")
+	})
+
+	r, _ := http.NewRequest("GET", "/page", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" || w.Header().Get("X-Frame-Options") != "SAMEORIGIN" {
+		t.Errorf("default headers should be sent, got %v", w.Header())
+	}
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("HSTS should only be sent over https")
+	}
+	if nonce == "" || !strings.Contains(w.Header().Get("Content-Security-Policy"), "'nonce-"+nonce+"'") {
+		t.Errorf("CSP should carry the request nonce %q, got %s", nonce, w.Header().Get("Content-Security-Policy"))
+	}
+
+	r, _ = http.NewRequest("GET", "/page", nil)
+	r.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Strict-Transport-Security") != "max-age=31536000; includeSubDomains" {
+		t.Errorf("HSTS should be sent over https, got %q", w.Header().Get("Strict-Transport-Security"))
+	}
+}
+
+func TestDoubleSubmitXSRF(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.InsertFilter("*", BeforeRouter, DoubleSubmitXSRF())
+	handler.Get("/form", func(ctx *context.Context) { ctx.WriteString("form") })
+	handler.Post("/save", func(ctx *context.Context) { ctx.WriteString("saved") })
+
+	r, _ := http.NewRequest("GET", "/form", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if len(cookies) != 1 || cookies[0].Name != XSRFCookieName || cookies[0].HttpOnly {
+		t.Fatalf("token cookie readable by scripts should be set, got %v", w.Header())
+	}
+	token := cookies[0].Value
+
+	post := func(cookie, header string) string {
+		r, _ := http.NewRequest("POST", "/save", nil)
+		r.AddCookie(&http.Cookie{Name: XSRFCookieName, Value: cookie})
+		r.Header.Set(XSRFHeaderName, header)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	if body := post(token, token); body != "saved" {
+		t.Errorf("matching header should pass, got %s", body)
+	}
+	if body := post(token, "forged"); body == "saved" {
+		t.Errorf("header not matching the cookie should be rejected")
+	}
+	if body := post("forged.00", "forged.00"); body == "saved" {
+		t.Errorf("unsigned cookie should be rejected")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// configureServer sets the timeouts and limits of the config on srv.
+// unset values keep the net/http defaults.
+//	HttpServerTimeOut = 60
+//	HttpReadHeaderTimeout = 10
+//	HttpIdleTimeout = 120
+//	HttpMaxHeaderBytes = 65536
+//	HttpMaxConns = 10000
+//	HttpMaxConnsPerIP = 100
+func configureServer(srv *http.Server) {
+	if HttpServerTimeOut > 0 {
+		srv.ReadTimeout = time.Duration(HttpServerTimeOut) * time.Second
+		srv.WriteTimeout = time.Duration(HttpServerTimeOut) * time.Second
+	}
+	if HttpReadHeaderTimeout > 0 {
+		srv.ReadHeaderTimeout = time.Duration(HttpReadHeaderTimeout) * time.Second
+	}
+	if HttpIdleTimeout > 0 {
+		srv.IdleTimeout = time.Duration(HttpIdleTimeout) * time.Second
+	}
+	if HttpMaxHeaderBytes > 0 {
+		srv.MaxHeaderBytes = HttpMaxHeaderBytes
+	}
+	if HttpMaxConns > 0 || HttpMaxConnsPerIP > 0 {
+		l := &connLimiter{
+			max:      HttpMaxConns,
+			maxPerIP: HttpMaxConnsPerIP,
+			next:     srv.ConnState,
+			conns:    make(map[net.Conn]string),
+			perIP:    make(map[string]int),
+		}
+		srv.ConnState = l.connState
+	}
+}
+
+// connLimiter closes the new connections over the total or per ip limit.
+// hijacked connections, e.g. websockets, don't count anymore.
+type connLimiter struct {
+	lock     sync.Mutex
+	max      int
+	maxPerIP int
+	next     func(net.Conn, http.ConnState)
+	conns    map[net.Conn]string // the counted connections and their ip
+	perIP    map[string]int
+}
+
+func (l *connLimiter) connState(c net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		if !l.acquire(c) {
+			c.Close()
+			return
+		}
+	case http.StateClosed, http.StateHijacked:
+		if !l.release(c) {
+			// rejected in StateNew
+			return
+		}
+	}
+	if l.next != nil {
+		l.next(c, state)
+	}
+}
+
+func (l *connLimiter) acquire(c net.Conn) bool {
+	ip, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err != nil {
+		// unix sockets have no remote ip
+		ip = ""
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.max > 0 && len(l.conns) >= l.max {
+		return false
+	}
+	if l.maxPerIP > 0 && ip != "" && l.perIP[ip] >= l.maxPerIP {
+		return false
+	}
+	l.conns[c] = ip
+	l.perIP[ip]++
+	return true
+}
+
+func (l *connLimiter) release(c net.Conn) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	ip, ok := l.conns[c]
+	if !ok {
+		return false
+	}
+	delete(l.conns, c)
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+	return true
+}
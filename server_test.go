@@ -0,0 +1,75 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxConnsPerIP(t *testing.T) {
+	defer func(v int) { HttpMaxConnsPerIP = v }(HttpMaxConnsPerIP)
+	HttpMaxConnsPerIP = 1
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("ok"))
+	}))
+	configureServer(ts.Config)
+	ts.Start()
+	defer ts.Close()
+
+	get := func() (net.Conn, error) {
+		c, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			return nil, err
+		}
+		c.SetDeadline(time.Now().Add(time.Second))
+		c.Write([]byte("GET / HTTP/1.1\r\nHost: beego\r\n\r\n"))
+		resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+		return c, nil
+	}
+
+	first, err := get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := get(); err == nil {
+		c.Close()
+		t.Fatal("a second connection of the ip should be closed")
+	}
+	first.Close()
+
+	// the slot is released once the server sees the close
+	for i := 0; i < 100; i++ {
+		var c net.Conn
+		if c, err = get(); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Error("the connection should be accepted after the first one is closed:", err)
+	}
+}
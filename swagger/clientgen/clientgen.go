@@ -0,0 +1,247 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clientgen generates the api clients of an OpenAPI document, a Go
+// package and a TypeScript module with a method per operation and the types
+// of the schemas of the components.
+//
+//	doc := beego.OpenAPI()
+//	src, err := clientgen.Go(doc, "userapi")
+//	ts, err := clientgen.TypeScript(doc)
+//
+// beego.GenerateClient writes them for the routes of the app.
+package clientgen
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aamsur/beego/swagger/openapi"
+)
+
+// operation is an operation of the document with its client method name.
+type operation struct {
+	*openapi.Operation
+	name   string
+	method string
+	path   string
+	params []*openapi.Parameter // the path, query and header parameters
+}
+
+var methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE"}
+
+var paramRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+// operations returns the operations of doc sorted by path and method, named
+// by their operation id or their method and path.
+func operations(doc *openapi.Document) []*operation {
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ops []*operation
+	used := make(map[string]int)
+	for _, path := range paths {
+		item := doc.Paths[path]
+		for _, method := range methods {
+			op := item.Operation(method)
+			if op == nil {
+				continue
+			}
+			o := &operation{Operation: op, method: method, path: path, name: operationName(op, method, path)}
+			if used[o.name]++; used[o.name] > 1 {
+				o.name += strings.Repeat("_", used[o.name]-1)
+			}
+			o.params = parameters(item, op, path)
+			ops = append(ops, o)
+		}
+	}
+	return ops
+}
+
+// parameters returns the parameters of op overriding the ones of item, the
+// path parameters first in their order in the path. the cookies are skipped.
+func parameters(item *openapi.PathItem, op *openapi.Operation, path string) []*openapi.Parameter {
+	byKey := make(map[string]*openapi.Parameter)
+	var keys []string
+	for _, p := range append(append([]*openapi.Parameter{}, item.Parameters...), op.Parameters...) {
+		if p.In == "cookie" {
+			continue
+		}
+		key := p.In + ":" + p.Name
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = p
+	}
+	var params []*openapi.Parameter
+	for _, m := range paramRegexp.FindAllStringSubmatch(path, -1) {
+		p, ok := byKey["path:"+m[1]]
+		if !ok {
+			p = &openapi.Parameter{Name: m[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+		}
+		params = append(params, p)
+	}
+	for _, key := range keys {
+		if p := byKey[key]; p.In != "path" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// operationName returns the method name of an operation: its id without the
+// Controller suffixes, like UserGet for UserController.Get, or its method
+// and path, like GetUsersByID for GET /users/{id}.
+func operationName(op *openapi.Operation, method, path string) string {
+	if op.OperationID != "" {
+		return camel(strings.Replace(op.OperationID, "Controller.", ".", -1), true)
+	}
+	name := camel(strings.ToLower(method), true)
+	for _, part := range strings.Split(path, "/") {
+		if m := paramRegexp.FindStringSubmatch(part); m != nil {
+			name += "By" + camel(m[1], true)
+		} else if part != "" {
+			name += camel(part, true)
+		}
+	}
+	return name
+}
+
+// camel joins the words of s in camel case, like user_id to UserID.
+func camel(s string, exported bool) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for i, w := range words {
+		upper := strings.ToUpper(w)
+		if i == 0 && !exported {
+			if initialisms[upper] {
+				b.WriteString(strings.ToLower(w))
+			} else {
+				b.WriteString(strings.ToLower(w[:1]) + w[1:])
+			}
+			continue
+		}
+		if initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
+
+var initialisms = map[string]bool{"ID": true, "URL": true, "URI": true, "API": true, "HTTP": true, "IP": true, "JSON": true, "UUID": true}
+
+// typeNames names the types of the schemas of the components by their type
+// name, like User for models.User, or with their package when names collide.
+func typeNames(doc *openapi.Document) map[string]string {
+	names := make(map[string]string)
+	if doc.Components == nil {
+		return names
+	}
+	count := make(map[string]int)
+	for schema := range doc.Components.Schemas {
+		count[camel(schema[strings.LastIndex(schema, ".")+1:], true)]++
+	}
+	for schema := range doc.Components.Schemas {
+		name := camel(schema[strings.LastIndex(schema, ".")+1:], true)
+		if count[name] > 1 {
+			name = camel(schema, true)
+		}
+		names[schema] = name
+	}
+	return names
+}
+
+// refName returns the component schema name of a $ref.
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+// requestMedia returns the content type and the media of the request body of op.
+func requestMedia(op *openapi.Operation) (string, *openapi.MediaType) {
+	if op.RequestBody == nil {
+		return "", nil
+	}
+	for _, t := range []string{"application/json", "application/x-www-form-urlencoded", "multipart/form-data"} {
+		if m, ok := op.RequestBody.Content[t]; ok {
+			return t, m
+		}
+	}
+	types := make([]string, 0, len(op.RequestBody.Content))
+	for t := range op.RequestBody.Content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	if len(types) == 0 {
+		return "", nil
+	}
+	return types[0], op.RequestBody.Content[types[0]]
+}
+
+// responseSchema returns the json schema of the first success response of op.
+func responseSchema(op *openapi.Operation) *openapi.Schema {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if m, ok := op.Responses[code].Content["application/json"]; ok && m.Schema != nil {
+			return m.Schema
+		}
+	}
+	return nil
+}
+
+// headerName returns the canonical name of a header parameter.
+func headerName(p *openapi.Parameter) string {
+	if p.In == "header" {
+		return http.CanonicalHeaderKey(p.Name)
+	}
+	return p.Name
+}
+
+// sortedKeys returns the keys of the properties of s, sorted.
+func sortedKeys(s *openapi.Schema) []string {
+	keys := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isRequired(s *openapi.Schema, name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientgen
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/swagger/openapi"
+)
+
+func testDocument() *openapi.Document {
+	doc := openapi.NewDocument("users", "1.0")
+	doc.Components.Schemas["models.User"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"id":      {Type: "integer", Format: "int64"},
+			"login":   {Type: "string"},
+			"created": {Type: "string", Format: "date-time"},
+			"tags":    {Type: "array", Items: &openapi.Schema{Type: "string"}},
+		},
+		Required: []string{"login"},
+	}
+	user := &openapi.Schema{Ref: "#/components/schemas/models.User"}
+	json := func(s *openapi.Schema) map[string]*openapi.MediaType {
+		return map[string]*openapi.MediaType{"application/json": {Schema: s}}
+	}
+	doc.Paths["/users"] = &openapi.PathItem{
+		Get: &openapi.Operation{
+			OperationID: "UserController.List",
+			Summary:     "lists the users",
+			Parameters: []*openapi.Parameter{
+				{Name: "page", In: "query", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+				{Name: "x-tenant", In: "header", Schema: &openapi.Schema{Type: "string"}},
+			},
+			Responses: map[string]*openapi.Response{"200": {Content: json(&openapi.Schema{Type: "array", Items: user})}},
+		},
+		Post: &openapi.Operation{
+			OperationID: "UserController.Post",
+			RequestBody: &openapi.RequestBody{Required: true, Content: json(user)},
+			Responses:   map[string]*openapi.Response{"201": {Content: json(user)}},
+		},
+	}
+	doc.Paths["/users/{id}"] = &openapi.PathItem{
+		Delete: &openapi.Operation{
+			Parameters: []*openapi.Parameter{{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Format: "int64"}}},
+			Deprecated: true,
+			Responses:  map[string]*openapi.Response{"204": {Description: "No Content"}},
+		},
+	}
+	doc.Paths["/files/{splat}"] = &openapi.PathItem{
+		Put: &openapi.Operation{
+			RequestBody: &openapi.RequestBody{Content: map[string]*openapi.MediaType{"multipart/form-data": {}}},
+			Responses:   map[string]*openapi.Response{"200": {Description: "OK"}},
+		},
+	}
+	return doc
+}
+
+func TestGo(t *testing.T) {
+	src, err := Go(testDocument(), "userapi")
+	if err != nil {
+		t.Fatal(err, string(src))
+	}
+	code := string(src)
+	for _, want := range []string{
+		"type User struct {\n\tCreated time.Time `json:\"created,omitempty\"`",
+		"\tLogin   string    `json:\"login\"`",
+		"func (c *Client) UserList(ctx context.Context, params *UserListParams) ([]User, error) {",
+		"\tPage    int64  // query page\n\tXTenant string // header x-tenant",
+		`addParam(header, "X-Tenant", params.XTenant)`,
+		"func (c *Client) UserPost(ctx context.Context, body User) (User, error) {",
+		"// Deprecated: DeleteUsersByID is deprecated by the api.\nfunc (c *Client) DeleteUsersByID(ctx context.Context, id int64) error {",
+		`path := "/users/" + url.PathEscape(fmt.Sprint(id))`,
+		"func (c *Client) PutFilesBySplat(ctx context.Context, splat string, body io.Reader, contentType string) error {",
+		`path := "/files/" + fmt.Sprint(splat)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("the client should contain\n%s\ngot\n%s", want, code)
+		}
+	}
+
+	// the generated package compiles
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "client.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("userapi", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("the client should compile: %v\n%s", err, code)
+	}
+}
+
+func TestTypeScript(t *testing.T) {
+	src, err := TypeScript(testDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"export interface User {\n  created?: string;\n  id?: number;\n  login: string;\n  tags?: string[];\n}",
+		`  userList(params: { page?: number; "x-tenant"?: string } = {}): Promise<User[]> {`,
+		`    return this.request<User[]>("GET", ` + "`/users`" + `, { "page": params["page"] }, { "X-Tenant": params["x-tenant"] }, undefined, undefined);`,
+		`  userPost(body: User): Promise<User> {`,
+		`JSON.stringify(body), "application/json");`,
+		"  /** calls DELETE /users/{id} @deprecated */\n  deleteUsersByID(id: number): Promise<void> {",
+		"`/users/${encodeURIComponent(String(id))}`",
+		"  putFilesBySplat(splat: string, body: BodyInit, contentType?: string): Promise<void> {",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("the client should contain\n%s\ngot\n%s", want, code)
+		}
+	}
+}
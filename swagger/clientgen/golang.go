@@ -0,0 +1,344 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aamsur/beego/swagger/openapi"
+)
+
+// Go returns the source of a Go package named pkg with a Client calling the
+// operations of doc and the types of its schemas.
+func Go(doc *openapi.Document, pkg string) ([]byte, error) {
+	g := &goGen{doc: doc, names: typeNames(doc)}
+	var body bytes.Buffer
+	g.types(&body)
+	for _, op := range operations(doc) {
+		g.operation(&body, op)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by beego from the docs of %s. DO NOT EDIT.\n\n", doc.Info.Title)
+	fmt.Fprintf(&b, "// Package %s is the client of %s %s.\npackage %s\n\n", pkg, doc.Info.Title, doc.Info.Version, pkg)
+	b.WriteString("import (\n\t\"bytes\"\n\t\"context\"\n\t\"encoding/json\"\n\t\"fmt\"\n\t\"io\"\n\t\"io/ioutil\"\n\t\"net/http\"\n\t\"net/url\"\n\t\"reflect\"\n\t\"strings\"\n")
+	if g.usesTime {
+		b.WriteString("\t\"time\"\n")
+	}
+	b.WriteString(")\n")
+	b.WriteString(goRuntime)
+	b.Write(body.Bytes())
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return b.Bytes(), fmt.Errorf("clientgen: %v", err)
+	}
+	return src, nil
+}
+
+type goGen struct {
+	doc      *openapi.Document
+	names    map[string]string
+	usesTime bool
+}
+
+// types writes the structs of the schemas of the components.
+func (g *goGen) types(b *bytes.Buffer) {
+	if g.doc.Components == nil {
+		return
+	}
+	schemas := make([]string, 0, len(g.doc.Components.Schemas))
+	for name := range g.doc.Components.Schemas {
+		schemas = append(schemas, name)
+	}
+	sort.Strings(schemas)
+	for _, name := range schemas {
+		s := g.doc.Components.Schemas[name]
+		fmt.Fprintf(b, "\n// %s is the %s schema.\ntype %s %s\n", g.names[name], name, g.names[name], g.typeOf(s))
+	}
+}
+
+// typeOf returns the Go type of the values of s.
+func (g *goGen) typeOf(s *openapi.Schema) string {
+	if s == nil {
+		return "json.RawMessage"
+	}
+	if s.Ref != "" {
+		if name, ok := g.names[refName(s.Ref)]; ok {
+			return name
+		}
+		return "json.RawMessage"
+	}
+	if len(s.AllOf) == 1 {
+		return g.typeOf(s.AllOf[0])
+	}
+	switch s.Type {
+	case "boolean":
+		return "bool"
+	case "integer":
+		if s.Format == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if s.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.usesTime = true
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "array":
+		return "[]" + g.typeOf(s.Items)
+	case "object":
+		if len(s.Properties) == 0 {
+			if s.AdditionalProperties != nil {
+				return "map[string]" + g.typeOf(s.AdditionalProperties)
+			}
+			return "map[string]interface{}"
+		}
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for _, name := range sortedKeys(s) {
+			tag := name
+			if !isRequired(s, name) {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:%s`\n", camel(name, true), g.typeOf(s.Properties[name]), strconv.Quote(tag))
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+	return "json.RawMessage"
+}
+
+// operation writes the params struct and the method of op.
+func (g *goGen) operation(b *bytes.Buffer, op *operation) {
+	var args []string
+	used := map[string]bool{"c": true, "ctx": true, "params": true, "body": true, "contentType": true, "query": true, "header": true, "out": true, "err": true, "path": true}
+	var pathArgs = make(map[string]string)
+	var others []*openapi.Parameter
+	for _, p := range op.params {
+		if p.In != "path" {
+			others = append(others, p)
+			continue
+		}
+		arg := camel(p.Name, false)
+		if used[arg] || token.IsKeyword(arg) {
+			arg += "Param"
+		}
+		used[arg] = true
+		pathArgs[p.Name] = arg
+		args = append(args, arg+" "+g.typeOf(p.Schema))
+	}
+
+	paramsType := op.name + "Params"
+	if len(others) > 0 {
+		fmt.Fprintf(b, "\n// %s are the query and header parameters of %s, the zero values are not sent.\ntype %s struct {\n", paramsType, op.name, paramsType)
+		for _, p := range others {
+			if p.Description != "" {
+				fmt.Fprintf(b, "\t// %s\n", strings.Replace(p.Description, "\n", " ", -1))
+			}
+			fmt.Fprintf(b, "\t%s %s // %s %s\n", camel(p.Name, true), g.typeOf(p.Schema), p.In, p.Name)
+		}
+		b.WriteString("}\n")
+		args = append(args, "params *"+paramsType)
+	}
+
+	contentType, media := requestMedia(op.Operation)
+	switch {
+	case contentType == "application/json":
+		var s *openapi.Schema
+		if media != nil {
+			s = media.Schema
+		}
+		args = append(args, "body "+g.typeOf(s))
+	case contentType == "application/x-www-form-urlencoded":
+		args = append(args, "body url.Values")
+	case contentType != "":
+		args = append(args, "body io.Reader", "contentType string")
+	}
+
+	result := responseSchema(op.Operation)
+	out := "error"
+	if result != nil {
+		out = "(" + g.typeOf(result) + ", error)"
+	}
+
+	b.WriteString("\n")
+	doc := op.Summary
+	if doc == "" {
+		doc = "calls " + op.method + " " + op.path
+	}
+	fmt.Fprintf(b, "// %s %s.\n", op.name, strings.Replace(doc, "\n", " ", -1))
+	if op.Deprecated {
+		fmt.Fprintf(b, "//\n// Deprecated: %s is deprecated by the api.\n", op.name)
+	}
+	fmt.Fprintf(b, "func (c *Client) %s(%s) %s {\n", op.name, strings.Join(append([]string{"ctx context.Context"}, args...), ", "), out)
+
+	// the path with its parameters escaped, but the splats
+	path := strconv.Quote(op.path)
+	for name, arg := range pathArgs {
+		value := "url.PathEscape(fmt.Sprint(" + arg + "))"
+		if name == "splat" || name == "path" {
+			value = "fmt.Sprint(" + arg + ")"
+		}
+		path = strings.Replace(path, "{"+name+"}", `" + `+value+` + "`, -1)
+	}
+	path = strings.Replace(strings.TrimSuffix(path, ` + ""`), `"" + `, "", -1)
+	fmt.Fprintf(b, "\tpath := %s\n", path)
+
+	b.WriteString("\tquery, header := url.Values{}, http.Header{}\n")
+	if len(others) > 0 {
+		b.WriteString("\tif params != nil {\n")
+		for _, p := range others {
+			values := "query"
+			if p.In == "header" {
+				values = "header"
+			}
+			fmt.Fprintf(b, "\t\taddParam(%s, %q, params.%s)\n", values, headerName(p), camel(p.Name, true))
+		}
+		b.WriteString("\t}\n")
+	}
+
+	zero := ""
+	if result != nil {
+		fmt.Fprintf(b, "\tvar out %s\n", g.typeOf(result))
+		zero = "out, "
+	}
+	switch contentType {
+	case "application/json":
+		b.WriteString("\treader, err := jsonBody(body)\n\tif err != nil {\n\t\treturn " + zero + "err\n\t}\n")
+		fmt.Fprintf(b, "\terr = c.do(ctx, %q, path, query, header, reader, %q, %s)\n", op.method, contentType, outArg(result))
+	case "application/x-www-form-urlencoded":
+		fmt.Fprintf(b, "\terr := c.do(ctx, %q, path, query, header, strings.NewReader(body.Encode()), %q, %s)\n", op.method, contentType, outArg(result))
+	case "":
+		fmt.Fprintf(b, "\terr := c.do(ctx, %q, path, query, header, nil, \"\", %s)\n", op.method, outArg(result))
+	default:
+		fmt.Fprintf(b, "\terr := c.do(ctx, %q, path, query, header, body, contentType, %s)\n", op.method, outArg(result))
+	}
+	b.WriteString("\treturn " + zero + "err\n}\n")
+}
+
+func outArg(result *openapi.Schema) string {
+	if result == nil {
+		return "nil"
+	}
+	return "&out"
+}
+
+// goRuntime is the client of the generated packages.
+const goRuntime = `
+// Client calls the api at BaseURL.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client // http.DefaultClient when nil
+	Header     http.Header  // added to every request, like an Authorization header
+}
+
+// NewClient returns a client of the api at baseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: baseURL, Header: make(http.Header)}
+}
+
+// Error is a response with an error status.
+type Error struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader, contentType string, out interface{}) error {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return &Error{StatusCode: resp.StatusCode, Body: data}
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func jsonBody(v interface{}) (io.Reader, error) {
+	b, err := json.Marshal(v)
+	return bytes.NewReader(b), err
+}
+
+// addParam adds the value of a parameter unless it's zero, the items of a slice one by one.
+func addParam(values map[string][]string, name string, v interface{}) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || reflect.DeepEqual(v, reflect.Zero(rv.Type()).Interface()) {
+		return
+	}
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < rv.Len(); i++ {
+			addParam(values, name, rv.Index(i).Interface())
+		}
+		return
+	}
+	if t, ok := v.(interface{ MarshalText() ([]byte, error) }); ok {
+		if text, err := t.MarshalText(); err == nil {
+			values[name] = append(values[name], string(text))
+			return
+		}
+	}
+	values[name] = append(values[name], fmt.Sprint(v))
+}
+`
@@ -0,0 +1,268 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aamsur/beego/swagger/openapi"
+)
+
+// TypeScript returns the source of a TypeScript module with a Client calling
+// the operations of doc with fetch and the interfaces of its schemas.
+func TypeScript(doc *openapi.Document) ([]byte, error) {
+	names := typeNames(doc)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by beego from the docs of %s. DO NOT EDIT.\n", doc.Info.Title)
+
+	if doc.Components != nil {
+		schemas := make([]string, 0, len(doc.Components.Schemas))
+		for name := range doc.Components.Schemas {
+			schemas = append(schemas, name)
+		}
+		sort.Strings(schemas)
+		for _, name := range schemas {
+			s := doc.Components.Schemas[name]
+			if s.Type == "object" && len(s.Properties) > 0 {
+				fmt.Fprintf(&b, "\nexport interface %s %s\n", names[name], tsType(names, s, ""))
+			} else {
+				fmt.Fprintf(&b, "\nexport type %s = %s;\n", names[name], tsType(names, s, ""))
+			}
+		}
+	}
+
+	b.WriteString(tsRuntime)
+	for _, op := range operations(doc) {
+		tsOperation(&b, names, op)
+	}
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+// tsType returns the TypeScript type of the values of s, indented by indent.
+func tsType(names map[string]string, s *openapi.Schema, indent string) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref != "" {
+		if name, ok := names[refName(s.Ref)]; ok {
+			return name
+		}
+		return "unknown"
+	}
+	union := func(schemas []*openapi.Schema, sep string) string {
+		types := make([]string, len(schemas))
+		for i, sub := range schemas {
+			types[i] = tsType(names, sub, indent)
+		}
+		return strings.Join(types, sep)
+	}
+	switch {
+	case len(s.OneOf) > 0:
+		return union(s.OneOf, " | ")
+	case len(s.AnyOf) > 0:
+		return union(s.AnyOf, " | ")
+	case len(s.AllOf) > 0:
+		return union(s.AllOf, " & ")
+	case len(s.Enum) > 0:
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			if text, ok := v.(string); ok {
+				values[i] = strconv.Quote(text)
+			} else {
+				values[i] = fmt.Sprint(v)
+			}
+		}
+		return strings.Join(values, " | ")
+	}
+	nullable := ""
+	if s.Nullable {
+		nullable = " | null"
+	}
+	switch s.Type {
+	case "boolean":
+		return "boolean" + nullable
+	case "integer", "number":
+		return "number" + nullable
+	case "string":
+		return "string" + nullable
+	case "array":
+		item := tsType(names, s.Items, indent)
+		if strings.ContainsAny(item, "|&") {
+			item = "(" + item + ")"
+		}
+		return item + "[]" + nullable
+	case "object":
+		if len(s.Properties) == 0 {
+			if s.AdditionalProperties != nil {
+				return "Record<string, " + tsType(names, s.AdditionalProperties, indent) + ">" + nullable
+			}
+			return "Record<string, unknown>" + nullable
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, name := range sortedKeys(s) {
+			optional := "?"
+			if isRequired(s, name) {
+				optional = ""
+			}
+			fmt.Fprintf(&b, "%s  %s%s: %s;\n", indent, tsName(name), optional, tsType(names, s.Properties[name], indent+"  "))
+		}
+		b.WriteString(indent + "}" + nullable)
+		return b.String()
+	}
+	return "unknown"
+}
+
+// tsName quotes the property names which are not identifiers.
+func tsName(name string) string {
+	for i, r := range name {
+		if !(r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+func tsOperation(b *bytes.Buffer, names map[string]string, op *operation) {
+	var args []string
+	used := map[string]bool{"params": true, "body": true, "contentType": true, "path": true}
+	path := "`" + op.path + "`"
+	var others []*openapi.Parameter
+	for _, p := range op.params {
+		if p.In != "path" {
+			others = append(others, p)
+			continue
+		}
+		arg := camel(p.Name, false)
+		if used[arg] {
+			arg += "Param"
+		}
+		used[arg] = true
+		args = append(args, arg+": "+tsType(names, p.Schema, "  "))
+		value := "encodeURIComponent(String(" + arg + "))"
+		if p.Name == "splat" || p.Name == "path" {
+			value = "String(" + arg + ")"
+		}
+		path = strings.Replace(path, "{"+p.Name+"}", "${"+value+"}", -1)
+	}
+
+	contentType, media := requestMedia(op.Operation)
+	body, ct := "undefined", "undefined"
+	switch contentType {
+	case "":
+	case "application/json":
+		var s *openapi.Schema
+		if media != nil {
+			s = media.Schema
+		}
+		args = append(args, "body: "+tsType(names, s, "  "))
+		body, ct = "JSON.stringify(body)", strconv.Quote(contentType)
+	case "application/x-www-form-urlencoded":
+		args = append(args, "body: URLSearchParams")
+		body, ct = "body", strconv.Quote(contentType)
+	default:
+		// a multipart body sets its content type with its boundary
+		args = append(args, "body: BodyInit", "contentType?: string")
+		body, ct = "body", "contentType"
+	}
+	// the optional params are last
+	if len(others) > 0 {
+		var fields []string
+		for _, p := range others {
+			fields = append(fields, fmt.Sprintf("%s?: %s", tsName(p.Name), tsType(names, p.Schema, "    ")))
+		}
+		args = append(args, "params: { "+strings.Join(fields, "; ")+" } = {}")
+	}
+
+	result := "void"
+	if s := responseSchema(op.Operation); s != nil {
+		result = tsType(names, s, "  ")
+	}
+
+	query, header := "{}", "{}"
+	var q, h []string
+	for _, p := range others {
+		entry := fmt.Sprintf("%s: params[%s]", strconv.Quote(headerName(p)), strconv.Quote(p.Name))
+		if p.In == "header" {
+			h = append(h, entry)
+		} else {
+			q = append(q, entry)
+		}
+	}
+	if len(q) > 0 {
+		query = "{ " + strings.Join(q, ", ") + " }"
+	}
+	if len(h) > 0 {
+		header = "{ " + strings.Join(h, ", ") + " }"
+	}
+
+	doc := op.Summary
+	if doc == "" {
+		doc = "calls " + op.method + " " + op.path
+	}
+	fmt.Fprintf(b, "\n  /** %s", strings.Replace(doc, "*/", "* /", -1))
+	if op.Deprecated {
+		b.WriteString(" @deprecated")
+	}
+	b.WriteString(" */\n")
+	name := strings.ToLower(op.name[:1]) + op.name[1:]
+	fmt.Fprintf(b, "  %s(%s): Promise<%s> {\n", name, strings.Join(args, ", "), result)
+	fmt.Fprintf(b, "    return this.request<%s>(%q, %s, %s, %s, %s, %s);\n  }\n", result, op.method, path, query, header, body, ct)
+}
+
+// tsRuntime is the start of the Client class of the generated modules.
+const tsRuntime = `
+/** ApiError is a response with an error status. */
+export class ApiError extends Error {
+  constructor(public status: number, public body: string) {
+    super(status + ": " + body);
+  }
+}
+
+/** Client calls the api at baseURL, headers are added to every request. */
+export class Client {
+  constructor(public baseURL: string, public headers: Record<string, string> = {}) {}
+
+  private async request<T>(method: string, path: string, query: Record<string, unknown>, header: Record<string, unknown>, body?: BodyInit, contentType?: string): Promise<T> {
+    const url = new URL(this.baseURL.replace(/\/$/, "") + path);
+    for (const [name, value] of Object.entries(query)) {
+      for (const item of Array.isArray(value) ? value : [value]) {
+        if (item !== undefined && item !== null) {
+          url.searchParams.append(name, String(item));
+        }
+      }
+    }
+    const headers: Record<string, string> = { Accept: "application/json", ...this.headers };
+    for (const [name, value] of Object.entries(header)) {
+      if (value !== undefined && value !== null) {
+        headers[name] = String(value);
+      }
+    }
+    if (contentType) {
+      headers["Content-Type"] = contentType;
+    }
+    const resp = await fetch(url.toString(), { method, headers, body });
+    const text = await resp.text();
+    if (!resp.ok) {
+      throw new ApiError(resp.status, text);
+    }
+    return (text ? JSON.parse(text) : undefined) as T;
+  }
+`
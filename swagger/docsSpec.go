@@ -106,10 +106,19 @@ type Model struct {
 }
 
 type ModelProperty struct {
-	Type        string            `json:"type"`
+	Type        string            `json:"type,omitempty"`
+	Ref         string            `json:"$ref,omitempty"` // the id of the model of a struct
 	Description string            `json:"description"`
 	Items       map[string]string `json:"items,omitempty"`
 	Format      string            `json:"format"`
+	// the constraints of the valid tag
+	Minimum   *int   `json:"minimum,omitempty"`
+	Maximum   *int   `json:"maximum,omitempty"`
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	MinItems  *int   `json:"minItems,omitempty"`
+	MaxItems  *int   `json:"maxItems,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
 }
 
 // https://github.com/wordnik/swagger-core/wiki/authorizations
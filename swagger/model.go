@@ -0,0 +1,135 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swagger
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aamsur/beego/validation"
+)
+
+// ModelOf returns the model of the struct type t, with the constraints of
+// the valid tags of its fields. the properties are named by the json tags.
+//
+//	type User struct {
+//		Name string `json:"name" valid:"Required;MaxSize(40)"`
+//		Age  int    `json:"age" valid:"Range(1, 140)"`
+//	}
+//
+//	model, err := swagger.ModelOf(reflect.TypeOf(User{}))
+//	// name is required with maxLength 40, age has minimum 1 and maximum 140
+func ModelOf(t reflect.Type) (Model, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return Model{}, fmt.Errorf("swagger: %s is not a struct", t)
+	}
+	m := Model{Id: t.Name(), Properties: make(map[string]ModelProperty)}
+	if err := addProperties(&m, t); err != nil {
+		return Model{}, err
+	}
+	return m, nil
+}
+
+func addProperties(m *Model, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		// the fields of embedded structs are promoted
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := addProperties(m, ft); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		p := property(f.Type)
+		c, err := validation.FieldConstraints(f)
+		if err != nil {
+			return fmt.Errorf("swagger: %s.%s: %v", t.Name(), f.Name, err)
+		}
+		if c.Required {
+			m.Required = append(m.Required, name)
+		}
+		p.Minimum, p.Maximum, p.Pattern = c.Minimum, c.Maximum, c.Pattern
+		if c.Format != "" {
+			p.Format = c.Format
+		}
+		if p.Type == "array" {
+			p.MinItems, p.MaxItems = c.MinLength, c.MaxLength
+		} else {
+			p.MinLength, p.MaxLength = c.MinLength, c.MaxLength
+		}
+		m.Properties[name] = p
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// property returns the property of a field of type t, without constraints.
+func property(t reflect.Type) ModelProperty {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return ModelProperty{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return ModelProperty{Type: "string", Format: "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		items := property(t.Elem())
+		if items.Ref != "" {
+			return ModelProperty{Type: "array", Items: map[string]string{"$ref": items.Ref}}
+		}
+		return ModelProperty{Type: "array", Items: map[string]string{"type": items.Type}}
+	case t.Kind() == reflect.Struct:
+		return ModelProperty{Ref: t.Name()}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return ModelProperty{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return ModelProperty{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return ModelProperty{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return ModelProperty{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return ModelProperty{Type: "number", Format: "double"}
+	case reflect.String:
+		return ModelProperty{Type: "string"}
+	}
+	// maps and interfaces have no schema in swagger 1.2
+	return ModelProperty{Type: "object"}
+}
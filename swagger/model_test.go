@@ -0,0 +1,65 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swagger
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type address struct {
+	City string `json:"city" valid:"Required"`
+}
+
+type audit struct {
+	Created time.Time `json:"created"`
+}
+
+type user struct {
+	audit
+	Id       int64     `json:"id"`
+	Name     string    `json:"name" valid:"Required;MaxSize(40)"`
+	Age      int       `json:"age" valid:"Range(1, 140)"`
+	Email    string    `json:"email" valid:"Email"`
+	Code     string    `json:"code" valid:"Match(/^[A-Z]{3}$/)"`
+	Tags     []string  `json:"tags" valid:"MinSize(1)"`
+	Address  *address  `json:"address"`
+	Previous []address `json:"previous,omitempty"`
+	Password string    `json:"-"`
+	secret   string
+}
+
+func TestModelOf(t *testing.T) {
+	m, err := ModelOf(reflect.TypeOf(&user{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(m)
+	want := `{"id":"user","required":["name"],"properties":{` +
+		`"address":{"$ref":"address","description":"","format":""},` +
+		`"age":{"type":"integer","description":"","format":"int64","minimum":1,"maximum":140},` +
+		`"code":{"type":"string","description":"","format":"","pattern":"^[A-Z]{3}$"},` +
+		`"created":{"type":"string","description":"","format":"date-time"},` +
+		`"email":{"type":"string","description":"","format":"email"},` +
+		`"id":{"type":"integer","description":"","format":"int64"},` +
+		`"name":{"type":"string","description":"","format":"","maxLength":40},` +
+		`"previous":{"type":"array","description":"","items":{"$ref":"address"},"format":""},` +
+		`"tags":{"type":"array","description":"","items":{"type":"string"},"format":"","minItems":1}}}`
+	if string(b) != want {
+		t.Errorf("unexpected model\n%s\nwant\n%s", b, want)
+	}
+}
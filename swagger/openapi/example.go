@@ -0,0 +1,132 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EXAMPLETAG is the struct tag of the examples of the fields:
+//
+//	type User struct {
+//		Login string   `json:"login" example:"gopher"`
+//		Age   int      `json:"age" example:"12"`
+//		Tags  []string `json:"tags" example:"admin,dev"`
+//	}
+const EXAMPLETAG = "example"
+
+// Exampler is implemented by the models providing the example of their schema.
+type Exampler interface {
+	Example() interface{}
+}
+
+var (
+	examplesLock sync.RWMutex
+	examples     = make(map[reflect.Type]func() interface{})
+)
+
+// SetExample sets the provider of the example of the schema of the type of
+// model, for the types not implementing Exampler:
+//
+//	openapi.SetExample(time.Time{}, func() interface{} { return "2015-01-02T15:04:05Z" })
+func SetExample(model interface{}, fn func() interface{}) {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	examplesLock.Lock()
+	defer examplesLock.Unlock()
+	examples[t] = fn
+}
+
+// ExampleOf returns the example of the values of type t from its provider,
+// or nil when it has none.
+func ExampleOf(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	examplesLock.RLock()
+	fn := examples[t]
+	examplesLock.RUnlock()
+	if fn != nil {
+		return fn()
+	}
+	if t.Implements(reflect.TypeOf((*Exampler)(nil)).Elem()) {
+		return reflect.Zero(t).Interface().(Exampler).Example()
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*Exampler)(nil)).Elem()) {
+		return reflect.New(t).Interface().(Exampler).Example()
+	}
+	return nil
+}
+
+// ParseExample converts the example tag of a field of type t to a value of
+// the type: the numbers and bools are parsed, the slices are comma separated
+// and the json objects or arrays are decoded. a text not matching the type is
+// kept as is.
+func ParseExample(t reflect.Type, text string) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return text
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(text); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return text
+		}
+		if strings.HasPrefix(strings.TrimSpace(text), "[") {
+			return decodeExample(text)
+		}
+		var items []interface{}
+		for _, item := range strings.Split(text, ",") {
+			items = append(items, ParseExample(t.Elem(), strings.TrimSpace(item)))
+		}
+		return items
+	case reflect.Map, reflect.Struct, reflect.Interface:
+		return decodeExample(text)
+	}
+	return text
+}
+
+// decodeExample decodes a json example, or returns it as is.
+func decodeExample(text string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return text
+	}
+	return v
+}
@@ -0,0 +1,168 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aamsur/beego/validation"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaName returns the name of the schema of a struct type in the components,
+// its package and type name like models.User.
+func SchemaName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.String()
+}
+
+// SchemaOf returns the schema of the values of type t. the structs are added
+// to the schemas of c and referenced, with the constraints of the valid tags
+// of their fields. the properties are named by the json tags.
+func (c *Components) SchemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Type: "string", Format: "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: c.SchemaOf(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: c.SchemaOf(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		name := SchemaName(t)
+		if t.Name() == "" {
+			return c.structSchema(t)
+		}
+		if c.Schemas == nil {
+			c.Schemas = make(map[string]*Schema)
+		}
+		if _, ok := c.Schemas[name]; !ok {
+			// registered before its fields, for the recursive types
+			c.Schemas[name] = &Schema{Type: "object"}
+			*c.Schemas[name] = *c.structSchema(t)
+			c.Schemas[name].Example = ExampleOf(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return TypeSchema(t.Kind())
+}
+
+// TypeSchema returns the schema of the values of a basic kind.
+func TypeSchema(kind reflect.Kind) *Schema {
+	switch kind {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	}
+	return &Schema{}
+}
+
+func (c *Components) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	c.addProperties(s, t)
+	return s
+}
+
+func (c *Components) addProperties(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		// the fields of embedded structs are promoted
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				c.addProperties(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		p, required := c.FieldSchema(f)
+		if required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+}
+
+// FieldSchema returns the schema of a struct field with the constraints of
+// its valid tag and its example tag, and whether it's required.
+func (c *Components) FieldSchema(f reflect.StructField) (*Schema, bool) {
+	s := c.SchemaOf(f.Type)
+	// the constraints of the fields are skipped when their tag is invalid,
+	// the validation reports it
+	constraints, _ := validation.FieldConstraints(f)
+	if s.Ref == "" {
+		applyConstraints(s, constraints)
+	}
+	if example, ok := f.Tag.Lookup(EXAMPLETAG); ok {
+		if s.Ref != "" {
+			// the siblings of a $ref are ignored
+			s = &Schema{AllOf: []*Schema{s}}
+		}
+		s.Example = ParseExample(f.Type, example)
+	}
+	return s, constraints.Required
+}
+
+func applyConstraints(s *Schema, c validation.Constraints) {
+	if c.Minimum != nil {
+		min := float64(*c.Minimum)
+		s.Minimum = &min
+	}
+	if c.Maximum != nil {
+		max := float64(*c.Maximum)
+		s.Maximum = &max
+	}
+	if s.Type == "array" {
+		s.MinItems, s.MaxItems = c.MinLength, c.MaxLength
+	} else {
+		s.MinLength, s.MaxLength = c.MinLength, c.MaxLength
+	}
+	if c.Pattern != "" {
+		s.Pattern = c.Pattern
+	}
+	if c.Format != "" {
+		s.Format = c.Format
+	}
+}
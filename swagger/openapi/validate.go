@@ -0,0 +1,216 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationError is a value not matching its schema.
+type ValidationError struct {
+	Path    string // the path of the value, like body.items[2].price
+	Rule    string // the schema keyword, like type or minimum
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Resolve returns the schema referenced by s, or s.
+func (d *Document) Resolve(s *Schema) *Schema {
+	// a ref to a ref is followed, a cycle of refs is not
+	for i := 0; s != nil && s.Ref != "" && i < 32; i++ {
+		if d.Components == nil {
+			return nil
+		}
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// ValidateValue validates v, a value decoded by encoding/json, against s and
+// returns its mismatches. path names v in the errors.
+func (d *Document) ValidateValue(s *Schema, v interface{}, path string) []*ValidationError {
+	var errs []*ValidationError
+	d.validate(s, v, path, &errs)
+	return errs
+}
+
+func (d *Document) validate(s *Schema, v interface{}, path string, errs *[]*ValidationError) {
+	fail := func(rule, format string, args ...interface{}) {
+		*errs = append(*errs, &ValidationError{Path: path, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	if s = d.Resolve(s); s == nil {
+		return
+	}
+	if v == nil {
+		if !s.Nullable && s.Type != "" {
+			fail("nullable", "must not be null")
+		}
+		return
+	}
+	for _, sub := range s.AllOf {
+		d.validate(sub, v, path, errs)
+	}
+	if len(s.AnyOf) > 0 && d.matches(s.AnyOf, v) == 0 {
+		fail("anyOf", "must match a schema of anyOf")
+	}
+	if len(s.OneOf) > 0 {
+		if n := d.matches(s.OneOf, v); n != 1 {
+			fail("oneOf", "must match exactly one schema of oneOf, matches %d", n)
+		}
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) || fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+			}
+		}
+		if !found {
+			fail("enum", "must be one of %v", s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			fail("type", "must be a %s", s.Type)
+			return
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			fail("type", "must be an integer")
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			fail("minimum", "must be %v or more", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fail("maximum", "must be %v or less", *s.Maximum)
+		}
+	case "string":
+		text, ok := v.(string)
+		if !ok {
+			fail("type", "must be a string")
+			return
+		}
+		length := utf8.RuneCountInString(text)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("minLength", "must be %d characters or more", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("maxLength", "must be %d characters or less", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(text) {
+				fail("pattern", "must match %s", s.Pattern)
+			}
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, text); err != nil {
+				fail("format", "must be a RFC 3339 date-time")
+			}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("type", "must be a boolean")
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			fail("type", "must be an array")
+			return
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			fail("minItems", "must have %d items or more", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			fail("maxItems", "must have %d items or less", *s.MaxItems)
+		}
+		for i, item := range items {
+			d.validate(s.Items, item, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case "object":
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			fail("type", "must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				*errs = append(*errs, &ValidationError{Path: path + "." + name, Rule: "required", Message: "is required"})
+			}
+		}
+		for name, value := range object {
+			if p, ok := s.Properties[name]; ok {
+				d.validate(p, value, path+"."+name, errs)
+			} else if s.AdditionalProperties != nil {
+				d.validate(s.AdditionalProperties, value, path+"."+name, errs)
+			}
+		}
+	}
+}
+
+// matches returns the number of schemas v matches.
+func (d *Document) matches(schemas []*Schema, v interface{}) int {
+	n := 0
+	for _, s := range schemas {
+		if len(d.ValidateValue(s, v, "")) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// ParamValue converts the values of a parameter to the json value of its
+// schema, to validate them: the numbers and bools are parsed and the arrays
+// are the repeated values or a comma separated value.
+func (d *Document) ParamValue(s *Schema, values []string) interface{} {
+	s = d.Resolve(s)
+	if s == nil || len(values) == 0 {
+		return nil
+	}
+	if s.Type == "array" {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = d.ParamValue(s.Items, []string{value})
+		}
+		return items
+	}
+	value := values[0]
+	switch s.Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	// a value not parsed fails the type of its schema
+	return value
+}
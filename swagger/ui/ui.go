@@ -0,0 +1,44 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ui embeds the Swagger UI assets in the binary, so the docs ui is
+// served without a cdn. without it the page loads them from DocsUIAssets,
+// unpkg by default. see README.md for their version and license.
+//
+// Usage
+//	import(
+//		"github.com/aamsur/beego"
+//		_ "github.com/aamsur/beego/swagger/ui"
+//	)
+//
+// and in app.conf
+//	EnableDocs = true
+//	DocsUI = swagger
+package ui
+
+import (
+	"embed"
+
+	"github.com/aamsur/beego"
+)
+
+// Version is the swagger-ui-dist release of the embedded assets.
+const Version = "5.18.2"
+
+//go:embed swagger-ui-bundle.js swagger-ui.css
+var files embed.FS
+
+func init() {
+	beego.RegisterDocsUIAssets("swagger", Version, files)
+}
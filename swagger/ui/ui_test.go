@@ -0,0 +1,33 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	for _, name := range []string{"swagger-ui-bundle.js", "swagger-ui.css"} {
+		b, err := files.ReadFile(name)
+		if err != nil || len(b) == 0 {
+			t.Errorf("%s should be embedded, got %v", name, err)
+		}
+	}
+	b, _ := files.ReadFile("swagger-ui-bundle.js")
+	if !strings.Contains(string(b), Version) {
+		t.Error("the bundle should be the one of Version")
+	}
+}
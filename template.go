@@ -17,21 +17,32 @@ package beego
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"html/template"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/aamsur/beego/i18n"
 	"github.com/aamsur/beego/utils"
 )
 
 var (
 	beegoTplFuncMap template.FuncMap
 	// beego template caching map and supported template file extensions.
+	// BuildTemplate replaces the map, it's never changed once built.
 	BeeTemplates   map[string]*template.Template
 	BeeTemplateExt []string
+
+	templatesLock sync.RWMutex
+
+	// how often the views are checked for changes in dev runmode.
+	templateWatchInterval = time.Second
 )
 
 func init() {
@@ -65,6 +76,12 @@ func init() {
 	beegoTplFuncMap["ne"] = ne // !=
 
 	beegoTplFuncMap["urlfor"] = UrlFor // !=
+	beegoTplFuncMap["absurlfor"] = AbsUrlFor
+	beegoTplFuncMap["i18n"] = i18n.Tr
+	beegoTplFuncMap["markdown"] = Markdown
+
+	// {{extends "layout.tpl"}} is resolved when the templates are built
+	beegoTplFuncMap["extends"] = func(string) string { return "" }
 }
 
 // AddFuncMap let user to register a func in the template.
@@ -125,8 +142,35 @@ func AddTemplateExt(ext string) {
 	BeeTemplateExt = append(BeeTemplateExt, ext)
 }
 
+// lookupTemplate returns the compiled template of a file in the views.
+func lookupTemplate(name string) (*template.Template, bool) {
+	templatesLock.RLock()
+	defer templatesLock.RUnlock()
+	t, ok := BeeTemplates[name]
+	return t, ok
+}
+
+// TemplateErrors are the parse errors of the broken templates keyed by file,
+// returned by BuildTemplate.
+type TemplateErrors map[string]error
+
+func (e TemplateErrors) Error() string {
+	files := make([]string, 0, len(e))
+	for file := range e {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	lines := []string{fmt.Sprintf("%d broken templates:", len(e))}
+	for _, file := range files {
+		lines = append(lines, "\t"+file+": "+e[file].Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
 // build all template files in a directory.
 // it makes beego can render any template file in view directory.
+// all templates are parsed, the broken ones are returned as TemplateErrors
+// and the others are still added.
 func BuildTemplate(dir string) error {
 	if _, err := os.Stat(dir); err != nil {
 		if os.IsNotExist(err) {
@@ -146,19 +190,77 @@ func BuildTemplate(dir string) error {
 		fmt.Printf("filepath.Walk() returned %v\n", err)
 		return err
 	}
+	built := make(map[string]*template.Template)
+	broken := make(TemplateErrors)
 	for _, v := range self.files {
 		for _, file := range v {
 			t, err := getTemplate(self.root, file, v...)
 			if err != nil {
-				Trace("parse template err:", file, err)
+				broken[file] = err
 			} else {
-				BeeTemplates[file] = t
+				built[file] = t
 			}
 		}
 	}
+
+	auditBuiltTemplates(built, broken)
+
+	// a fresh map is swapped in, so the requests rendering meanwhile see a
+	// complete map and deleted files are dropped. a file that fails to parse
+	// keeps its last good build.
+	templatesLock.Lock()
+	templates := make(map[string]*template.Template, len(built))
+	for file, t := range built {
+		templates[file] = t
+	}
+	for file, err := range broken {
+		if _, ok := err.(*templateAuditError); ok {
+			continue
+		}
+		if t, ok := BeeTemplates[file]; ok {
+			templates[file] = t
+		}
+	}
+	BeeTemplates = templates
+	templatesLock.Unlock()
+
+	if len(broken) > 0 {
+		return broken
+	}
 	return nil
 }
 
+// watchTemplates builds the templates of dir again whenever a file changes,
+// it's started in dev runmode.
+func watchTemplates(dir string) {
+	last := templatesStamp(dir)
+	for range time.Tick(templateWatchInterval) {
+		stamp := templatesStamp(dir)
+		if stamp == last {
+			continue
+		}
+		last = stamp
+		if err := BuildTemplate(dir); err != nil {
+			Warn(err)
+		} else {
+			Info("templates rebuilt")
+		}
+	}
+}
+
+// templatesStamp hashes the names, sizes and modification times of the templates in dir.
+func templatesStamp(dir string) uint64 {
+	h := fnv.New64a()
+	filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
+		if err != nil || f.IsDir() || !HasTemplateExt(path) {
+			return nil
+		}
+		fmt.Fprintf(h, "%s:%d:%d;", path, f.Size(), f.ModTime().UnixNano())
+		return nil
+	})
+	return h.Sum64()
+}
+
 func getTplDeep(root, file, parent string, t *template.Template) (*template.Template, [][]string, error) {
 	var fileabspath string
 	if filepath.HasPrefix(file, "../") {
@@ -167,7 +269,7 @@ func getTplDeep(root, file, parent string, t *template.Template) (*template.Temp
 		fileabspath = filepath.Join(root, file)
 	}
 	if e := utils.FileExists(fileabspath); !e {
-		panic("can't find template file:" + file)
+		return nil, [][]string{}, errors.New("can't find template file:" + file)
 	}
 	data, err := ioutil.ReadFile(fileabspath)
 	if err != nil {
@@ -198,6 +300,13 @@ func getTplDeep(root, file, parent string, t *template.Template) (*template.Temp
 }
 
 func getTemplate(root, file string, others ...string) (t *template.Template, err error) {
+	chain, err := extendsChain(root, file)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) > 1 {
+		return getExtendedTemplate(root, chain, others...)
+	}
 	t = template.New(file).Delims(TemplateLeft, TemplateRight).Funcs(beegoTplFuncMap)
 	var submods [][]string
 	t, submods, err = getTplDeep(root, file, "", t)
@@ -260,3 +369,61 @@ func _getTemplate(t0 *template.Template, root string, submods [][]string, others
 	}
 	return
 }
+
+// extendsChain returns file followed by the templates it extends, in order:
+//	{{extends "layouts/base.tpl"}}
+//	{{define "content"}}...{{end}}
+func extendsChain(root, file string) ([]string, error) {
+	reg := regexp.MustCompile(TemplateLeft + "[ ]*extends[ ]+\"([^\"]+)\"")
+	chain := []string{file}
+	for {
+		data, err := ioutil.ReadFile(filepath.Join(root, chain[len(chain)-1]))
+		if err != nil {
+			return nil, err
+		}
+		m := reg.FindStringSubmatch(string(data))
+		if m == nil {
+			return chain, nil
+		}
+		for _, f := range chain {
+			if f == m[1] {
+				return nil, errors.New("template " + file + " extends itself through " + m[1])
+			}
+		}
+		chain = append(chain, m[1])
+	}
+}
+
+// getExtendedTemplate parses the chain from the base layout down to its first template,
+// so the blocks defined by a template override the ones of the templates it extends.
+// the body of the base layout is parsed under the name of the first template,
+// their own bodies are not rendered.
+func getExtendedTemplate(root string, chain []string, others ...string) (*template.Template, error) {
+	file := chain[0]
+	t := template.New(file).Delims(TemplateLeft, TemplateRight).Funcs(beegoTplFuncMap)
+	reg := regexp.MustCompile(TemplateLeft + "[ ]*template[ ]+\"([^\"]+)\"")
+	var submods [][]string
+	for i := len(chain) - 1; i >= 0; i-- {
+		data, err := ioutil.ReadFile(filepath.Join(root, chain[i]))
+		if err != nil {
+			return nil, err
+		}
+		name := file
+		if i < len(chain)-1 {
+			name = "extends:" + chain[i]
+		}
+		if t, err = t.New(name).Parse(string(data)); err != nil {
+			return nil, err
+		}
+		submods = append(submods, reg.FindAllStringSubmatch(string(data), -1)...)
+	}
+	for _, m := range submods {
+		if len(m) == 2 && t.Lookup(m[1]) == nil && HasTemplateExt(m[1]) {
+			var err error
+			if t, _, err = getTplDeep(root, m[1], file, t); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return _getTemplate(t, root, submods, others...)
+}
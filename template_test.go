@@ -15,9 +15,15 @@
 package beego
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/aamsur/beego/utils"
 )
 
 var header string = `{{define "header"}}
@@ -134,3 +140,259 @@ func TestRelativeTemplate(t *testing.T) {
 	}
 	os.RemoveAll(dir)
 }
+
+func TestBrokenTemplates(t *testing.T) {
+	dir := "_beeTmpBroken"
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "good.tpl"), []byte(`good`), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "unclosed.tpl"), []byte(`{{if .}}`), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "missing.tpl"), []byte(`{{template "nothere.tpl"}}`), 0666)
+
+	stamp := templatesStamp(dir)
+	err := BuildTemplate(dir)
+	broken, ok := err.(TemplateErrors)
+	if !ok || len(broken) != 2 || broken["unclosed.tpl"] == nil || broken["missing.tpl"] == nil {
+		t.Fatalf("all broken templates should be listed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "2 broken templates") {
+		t.Errorf("unexpected error message %q", err)
+	}
+	if _, ok := lookupTemplate("good.tpl"); !ok {
+		t.Error("templates that parse should still be built")
+	}
+
+	if templatesStamp(dir) != stamp {
+		t.Error("stamp should not change without changes")
+	}
+	ioutil.WriteFile(filepath.Join(dir, "unclosed.tpl"), []byte(`{{if .}}fixed{{end}}`), 0666)
+	if templatesStamp(dir) == stamp {
+		t.Error("stamp should change when a template is edited")
+	}
+}
+
+func TestRebuildTemplates(t *testing.T) {
+	dir := "_beeTmpRebuild"
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "kept.tpl"), []byte(`kept`), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "edited.tpl"), []byte(`good`), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "deleted.tpl"), []byte(`deleted`), 0666)
+	if err := BuildTemplate(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(dir, "deleted.tpl"))
+	ioutil.WriteFile(filepath.Join(dir, "edited.tpl"), []byte(`{{if .}}`), 0666)
+	if err := BuildTemplate(dir); err == nil {
+		t.Fatal("the broken edit should be reported")
+	}
+	if _, ok := lookupTemplate("deleted.tpl"); ok {
+		t.Error("deleted templates should not be served after a rebuild")
+	}
+	if _, ok := lookupTemplate("kept.tpl"); !ok {
+		t.Error("unchanged templates should still be served")
+	}
+	tpl, ok := lookupTemplate("edited.tpl")
+	if !ok {
+		t.Fatal("a template that fails to parse should keep its last good build")
+	}
+	var buf strings.Builder
+	if err := tpl.ExecuteTemplate(&buf, "edited.tpl", nil); err != nil || buf.String() != "good" {
+		t.Errorf("the last good build should be rendered, got %q %v", buf.String(), err)
+	}
+}
+
+type fragmentController struct {
+	Controller
+}
+
+func (c *fragmentController) Get() {
+	c.Data["Name"] = "beego"
+	c.TplNames = "fragment/list.tpl"
+	c.Layout = "fragment/layout.tpl"
+	c.Fragment = "rows"
+}
+
+func TestRenderFragment(t *testing.T) {
+	dir := "_beeTmpFragment"
+	if err := os.MkdirAll(filepath.Join(dir, "fragment"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "fragment/layout.tpl"), []byte(`<html>{{.LayoutContent}}</html>`), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "fragment/list.tpl"), []byte(`<table>{{define "rows"}}<tr>{{.Name}}</tr>{{end}}{{template "rows" .}}</table>`), 0666)
+	if err := BuildTemplate(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewControllerRegister()
+	handler.Add("/list", &fragmentController{})
+	get := func(htmx bool) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/list", nil)
+		if htmx {
+			r.Header.Set("HX-Request", "true")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get(false); w.Body.String() != "<html><table><tr>beego</tr></table></html>" {
+		t.Errorf("the full page should be rendered, got %q", w.Body.String())
+	}
+	w := get(true)
+	if w.Body.String() != "<tr>beego</tr>" {
+		t.Errorf("htmx requests should get the fragment, got %q", w.Body.String())
+	}
+	if w.Header().Get("Vary") != "HX-Request" {
+		t.Errorf("responses should vary on HX-Request, got %q", w.Header().Get("Vary"))
+	}
+}
+
+func TestExtendsTemplate(t *testing.T) {
+	dir := "_beeTmpExtends"
+	if err := os.MkdirAll(filepath.Join(dir, "layouts"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"layouts/base.tpl":    `<html>{{block "title" .}}Default{{end}}|{{block "content" .}}{{end}}{{template "footer.tpl"}}</html>`,
+		"layouts/section.tpl": `{{extends "layouts/base.tpl"}}{{define "content"}}<nav>{{block "main" .}}none{{end}}</nav>{{end}}`,
+		"page.tpl":            `{{extends "layouts/section.tpl"}}{{define "main"}}page {{.}}{{end}}`,
+		"footer.tpl":          `<footer>`,
+		"loop.tpl":            `{{extends "loop2.tpl"}}`,
+		"loop2.tpl":           `{{extends "loop.tpl"}}`,
+	}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666)
+	}
+	err := BuildTemplate(dir)
+	if broken, ok := err.(TemplateErrors); !ok || len(broken) != 2 || broken["loop.tpl"] == nil {
+		t.Errorf("extends loops should be reported, got %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"page.tpl":            "<html>Default|<nav>page x</nav><footer></html>",
+		"layouts/section.tpl": "<html>Default|<nav>none</nav><footer></html>",
+	} {
+		tpl, ok := lookupTemplate(name)
+		if !ok {
+			t.Fatal(name, "should be built")
+		}
+		var b strings.Builder
+		if err := tpl.ExecuteTemplate(&b, name, "x"); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != want {
+			t.Errorf("%s should render %q, got %q", name, want, b.String())
+		}
+	}
+}
+
+func TestRenderEmail(t *testing.T) {
+	dir := "_beeTmpMail"
+	if err := os.MkdirAll(filepath.Join(dir, "mail"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "mail/welcome.tpl"), []byte(
+		`{{define "subject"}}Welcome {{.Name}}{{end}}{{define "text"}} Hello {{.Name}} {{end}}<p>Hello {{.Name}}</p>`), 0666)
+	if err := BuildTemplate(dir); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]string{"Name": "Tom & Jerry"}
+
+	if s, err := RenderTemplateToString("mail/welcome.tpl", data); err != nil || s != "<p>Hello Tom &amp; Jerry</p>" {
+		t.Errorf("template should render outside a request, got %q %v", s, err)
+	}
+	if _, err := RenderTemplateToString("mail/missing.tpl", data); err == nil {
+		t.Error("a missing template should be an error")
+	}
+
+	mail := &utils.Email{}
+	if err := RenderEmail(mail, "mail/welcome.tpl", data); err != nil {
+		t.Fatal(err)
+	}
+	if mail.Subject != "Welcome Tom & Jerry" || mail.Text != "Hello Tom & Jerry" || mail.HTML != "<p>Hello Tom &amp; Jerry</p>" {
+		t.Errorf("unexpected email %q %q %q", mail.Subject, mail.Text, mail.HTML)
+	}
+}
+
+func TestAuditTemplates(t *testing.T) {
+	dir := "_beeTmpAudit"
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "safe.tpl"), []byte(`<a href="/users/{{.ID}}">{{.Name}}</a>`), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "unsafe.tpl"), []byte(
+		"<p>{{str2html .Bio}}</p>\n{{if .Next}}<a href=\"{{printf \"/users/%s\" .Next}}\">next</a>{{end}}"), 0666)
+	if err := BuildTemplate(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	issues := AuditTemplates()
+	if len(issues["safe.tpl"]) != 0 {
+		t.Errorf("safe template should not be flagged, got %v", issues["safe.tpl"])
+	}
+	want := []string{
+		"unsafe.tpl:1:5: str2html returns template.HTML, output without escaping",
+		"unsafe.tpl:2:23: url built with printf, the parts are not escaped as url components",
+	}
+	if strings.Join(issues["unsafe.tpl"], "|") != strings.Join(want, "|") {
+		t.Errorf("unexpected issues %q", issues["unsafe.tpl"])
+	}
+
+	TemplateStrict = true
+	err := BuildTemplate(dir)
+	TemplateStrict = false
+	if broken, ok := err.(TemplateErrors); !ok || len(broken) != 1 || broken["unsafe.tpl"] == nil {
+		t.Errorf("strict mode should refuse the flagged templates, got %v", err)
+	}
+	if _, ok := lookupTemplate("unsafe.tpl"); ok {
+		t.Error("refused templates should not be rendered")
+	}
+
+	TrustTemplateFunc("str2html")
+	defer delete(trustedTemplateFuncs, "str2html")
+	BuildTemplate(dir)
+	if issues := AuditTemplates(); len(issues["unsafe.tpl"]) != 1 {
+		t.Errorf("trusted funcs should not be flagged, got %v", issues["unsafe.tpl"])
+	}
+}
+
+type markdownController struct {
+	Controller
+}
+
+func (c *markdownController) Get() {
+	c.Layout = "markdown/layout.tpl"
+	c.RenderMarkdownData([]byte("# Changelog\n\n- **fixed** <b>"))
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	dir := "_beeTmpMarkdown"
+	if err := os.MkdirAll(filepath.Join(dir, "markdown"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "markdown/layout.tpl"), []byte(`<main>{{.LayoutContent}}</main>{{markdown "*by beego*"}}`), 0666)
+	if err := BuildTemplate(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewControllerRegister()
+	handler.Add("/changelog", &markdownController{})
+	r, _ := http.NewRequest("GET", "/changelog", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	want := "<main><h1 id=\"changelog\">Changelog</h1>\n<ul>\n<li><strong>fixed</strong> &lt;b&gt;</li>\n</ul>\n</main><p><em>by beego</em></p>\n"
+	if w.Body.String() != want {
+		t.Errorf("markdown should be rendered in the layout, got %q", w.Body.String())
+	}
+}
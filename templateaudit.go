@@ -0,0 +1,196 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"html/template"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// the types html/template outputs without escaping.
+var unescapedTypes = map[reflect.Type]bool{
+	reflect.TypeOf(template.HTML("")):     true,
+	reflect.TypeOf(template.HTMLAttr("")): true,
+	reflect.TypeOf(template.JS("")):       true,
+	reflect.TypeOf(template.JSStr("")):    true,
+	reflect.TypeOf(template.CSS("")):      true,
+	reflect.TypeOf(template.URL("")):      true,
+	reflect.TypeOf(template.Srcset("")):   true,
+}
+
+// trustedTemplateFuncs are the funcs returning unescaped content that were reviewed.
+var trustedTemplateFuncs = map[string]bool{"markdown": true}
+
+// urlAttrRegexp matches a text ending inside an url attribute value.
+var urlAttrRegexp = regexp.MustCompile(`(?i)\b(href|src|action|formaction|poster|cite|data)\s*=\s*["']?[^"'\s>]*$`)
+
+// TrustTemplateFunc stops the audit from flagging the funcs of the funcmap,
+// once their unescaped output was reviewed.
+func TrustTemplateFunc(names ...string) {
+	for _, name := range names {
+		trustedTemplateFuncs[name] = true
+	}
+}
+
+// AuditTemplates returns the constructs bypassing the html auto-escaping,
+// keyed by template file:
+//	- funcs returning template.HTML or another type output as is, like str2html
+//	- urls built with printf in url attributes
+// they're logged in dev runmode, and the templates having some are refused
+// when TemplateStrict is true.
+func AuditTemplates() map[string][]string {
+	templatesLock.RLock()
+	defer templatesLock.RUnlock()
+	issues := make(map[string][]string)
+	for file, t := range BeeTemplates {
+		if found := auditTemplate(t); len(found) > 0 {
+			issues[file] = found
+		}
+	}
+	return issues
+}
+
+// auditTemplate returns the issues of t and its associated templates, sorted.
+func auditTemplate(t *template.Template) []string {
+	unsafe := make(map[string]string)
+	for name, fn := range beegoTplFuncMap {
+		typ := reflect.TypeOf(fn)
+		if trustedTemplateFuncs[name] || typ == nil || typ.Kind() != reflect.Func || typ.NumOut() == 0 {
+			continue
+		}
+		if unescapedTypes[typ.Out(0)] {
+			unsafe[name] = typ.Out(0).String()
+		}
+	}
+
+	seen := make(map[string]bool)
+	var issues []string
+	for _, tpl := range t.Templates() {
+		if tpl.Tree == nil || tpl.Tree.Root == nil {
+			continue
+		}
+		a := &templateAuditor{tree: tpl.Tree, unsafe: unsafe}
+		a.walk(tpl.Tree.Root)
+		for _, issue := range a.issues {
+			if !seen[issue] {
+				seen[issue] = true
+				issues = append(issues, issue)
+			}
+		}
+	}
+	sort.Strings(issues)
+	return issues
+}
+
+type templateAuditor struct {
+	tree   *parse.Tree
+	unsafe map[string]string // func name to the type it returns
+	text   string            // the text before the node walked
+	issues []string
+}
+
+func (a *templateAuditor) flag(n parse.Node, msg string) {
+	location, _ := a.tree.ErrorContext(n)
+	a.issues = append(a.issues, location+": "+msg)
+}
+
+func (a *templateAuditor) walk(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			a.walk(child)
+		}
+	case *parse.TextNode:
+		a.text = string(n.Text)
+	case *parse.ActionNode:
+		if urlAttrRegexp.MatchString(a.text) && len(n.Pipe.Cmds) > 0 && len(n.Pipe.Cmds[0].Args) > 0 {
+			if id, ok := n.Pipe.Cmds[0].Args[0].(*parse.IdentifierNode); ok && id.Ident == "printf" {
+				a.flag(n, "url built with printf, the parts are not escaped as url components")
+			}
+		}
+		a.walk(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				a.walk(arg)
+			}
+		}
+	case *parse.IdentifierNode:
+		if typ, ok := a.unsafe[n.Ident]; ok {
+			a.flag(n, n.Ident+" returns "+typ+", output without escaping")
+		}
+	case *parse.ChainNode:
+		a.walk(n.Node)
+	case *parse.IfNode:
+		a.walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		a.walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		a.walkBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		a.walk(n.Pipe)
+	}
+}
+
+func (a *templateAuditor) walkBranch(n *parse.BranchNode) {
+	a.walk(n.Pipe)
+	a.walk(n.List)
+	a.walk(n.ElseList)
+}
+
+// auditBuiltTemplates logs the issues of the templates in dev runmode and
+// refuses the templates having some when TemplateStrict is true.
+func auditBuiltTemplates(built map[string]*template.Template, broken TemplateErrors) {
+	if RunMode != "dev" && !TemplateStrict {
+		return
+	}
+	logged := make(map[string]bool)
+	for file, t := range built {
+		issues := auditTemplate(t)
+		if len(issues) == 0 {
+			continue
+		}
+		if TemplateStrict {
+			delete(built, file)
+			broken[file] = &templateAuditError{issues}
+		}
+		if RunMode == "dev" {
+			for _, issue := range issues {
+				if !logged[issue] {
+					logged[issue] = true
+					Warn("template audit:", issue)
+				}
+			}
+		}
+	}
+}
+
+type templateAuditError struct {
+	issues []string
+}
+
+func (e *templateAuditError) Error() string {
+	return "bypasses the html escaping: " + strings.Join(e.issues, ", ")
+}
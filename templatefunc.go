@@ -24,6 +24,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/aamsur/beego/utils/markdown"
 )
 
 // Substr returns the substr from start to length.
@@ -189,6 +191,11 @@ func Str2html(raw string) template.HTML {
 	return template.HTML(raw)
 }
 
+// Markdown renders markdown to sanitized html, see the markdown module.
+func Markdown(src string) template.HTML {
+	return template.HTML(markdown.Render([]byte(src)))
+}
+
 // Htmlquote returns quoted html string.
 func Htmlquote(src string) string {
 	//HTML编码为实体符号
@@ -246,18 +253,31 @@ func Htmlunquote(src string) string {
 //	print UrlFor("login")
 //	print UrlFor("login", "next","/"")
 //  router /profile/:username
-//	print UrlFor("profile", ":username","John Doe")
+//	print UrlFor("profile", ":username","John Doe", "#", "posts")
 //	result:
 //	/
 //	/login
-//	/login?next=/
-//	/user/John%20Doe
+//	/login?next=%2F
+//	/user/John%20Doe#posts
 //
 //  more detail http://beego.me/docs/mvc/controller/urlbuilding.md
 func UrlFor(endpoint string, values ...interface{}) string {
 	return BeeApp.Handlers.UrlFor(endpoint, values...)
 }
 
+// AbsUrlFor returns the UrlFor url prefixed by BaseURL, for the links of the
+// mails or the redirects to another host. in a request, Controller.AbsUrlFor
+// defaults to the host of the request.
+//	{{absurlfor "UserController.Verify" ":token" .Token}}
+//	https://example.com/verify/e8d1a2
+func AbsUrlFor(endpoint string, values ...interface{}) string {
+	u := UrlFor(endpoint, values...)
+	if u == "" {
+		return ""
+	}
+	return BaseURL + u
+}
+
 // returns script tag with src string.
 func AssetsJs(src string) template.HTML {
 	text := string(src)
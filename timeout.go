@@ -0,0 +1,229 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"bufio"
+	gocontext "context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aamsur/beego/context"
+)
+
+// timeoutKey is the ctx.Input.Data key of the running request deadline.
+const timeoutKey = "_beego_timeout"
+
+// requestTimeout guards the response writer of a request running under a deadline.
+// after the deadline the 503 response is written and later writes of the handler are dropped.
+type requestTimeout struct {
+	w      http.ResponseWriter
+	header http.Header
+	body   string
+	timer  *time.Timer
+	cancel gocontext.CancelFunc
+
+	lock        sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+	stopped     bool
+}
+
+func (t *requestTimeout) Header() http.Header {
+	return t.header
+}
+
+func (t *requestTimeout) Write(b []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	t.writeHeader(0)
+	return t.w.Write(b)
+}
+
+func (t *requestTimeout) WriteHeader(code int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timedOut {
+		return
+	}
+	t.writeHeader(code)
+}
+
+// Flush sends the response written so far. a flushed response is streamed,
+// it isn't cut by the deadline anymore.
+func (t *requestTimeout) Flush() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timedOut {
+		return
+	}
+	t.stopped = true
+	t.timer.Stop()
+	t.writeHeader(0)
+	if f, ok := t.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack ends the deadline and hands the connection to the caller,
+// for the websocket upgrades.
+func (t *requestTimeout) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	hj, ok := t.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("webserver doesn't support hijacking")
+	}
+	t.stopped = true
+	t.timer.Stop()
+	return hj.Hijack()
+}
+
+// writeHeader copies the headers set by the handler, code 0 leaves the status to the first Write.
+func (t *requestTimeout) writeHeader(code int) {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+	dst := t.w.Header()
+	for k, v := range t.header {
+		dst[k] = v
+	}
+	if code != 0 {
+		t.w.WriteHeader(code)
+	}
+}
+
+// expire answers 503 unless the handler already started the response,
+// and cancels the request context in any case.
+// the 503 is complete and flushed, so the client gets it while the handler
+// is still running. the context is canceled only after it was written,
+// a handler woken by ctx.Done() cannot answer first.
+func (t *requestTimeout) expire() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.stopped {
+		return
+	}
+	defer t.cancel()
+	if t.wroteHeader {
+		return
+	}
+	t.timedOut = true
+	t.wroteHeader = true
+	body := t.body
+	contentType := "text/html; charset=utf-8"
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		contentType = "application/json; charset=utf-8"
+	}
+	// the router owns the state of its responseWriter,
+	// it is updated by stop in the request goroutine.
+	w := t.w
+	if rw, ok := w.(*responseWriter); ok {
+		w = rw.writer
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(body))
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stop ends the deadline when the request is done.
+func (t *requestTimeout) stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.stopped = true
+	t.timer.Stop()
+	t.cancel()
+	if t.timedOut {
+		if rw, ok := t.w.(*responseWriter); ok {
+			rw.started = true
+			rw.status = http.StatusServiceUnavailable
+			rw.size = int64(len(t.body))
+		}
+		return
+	}
+	t.writeHeader(0)
+}
+
+// Timeout returns a filter running the rest of the request under a deadline.
+// when it is exceeded the client gets a 503 with body right away, then the
+// request context is canceled and later writes fail with http.ErrHandlerTimeout.
+// the handler keeps its goroutine until it returns, so handlers should watch
+// ctx.Context() to stop working for abandoned clients.
+// websocket upgrades and flushed, streamed responses aren't cut by the deadline.
+// a timeout set later in the chain replaces the former one:
+//	beego.InsertFilter("/report/*", beego.BeforeRouter, beego.Timeout(time.Minute, ""))
+func Timeout(d time.Duration, body string) FilterFunc {
+	if body == "" {
+		body = "<html><head><title>Timeout</title></head><body><h1>Timeout</h1></body></html>"
+	}
+	return func(ctx *context.Context) {
+		if ctx.Input.IsWebsocket() {
+			return
+		}
+		header := make(http.Header)
+		if prev, ok := ctx.Input.GetData(timeoutKey).(*requestTimeout); ok {
+			prev.lock.Lock()
+			prev.stopped = true
+			prev.timer.Stop()
+			prev.lock.Unlock()
+			ctx.ResponseWriter = prev.w
+			header = prev.header
+		}
+		// canceled by expire only, the timer is the single source of the deadline.
+		// a replaced timeout never expires, so it doesn't cut the new one short.
+		reqctx, cancel := gocontext.WithCancel(ctx.Request.Context())
+		t := &requestTimeout{
+			w:      ctx.ResponseWriter,
+			header: header,
+			body:   body,
+			cancel: cancel,
+		}
+		t.timer = time.AfterFunc(d, t.expire)
+		ctx.Input.SetData(timeoutKey, t)
+		ctx.SetContext(reqctx)
+		ctx.ResponseWriter = t
+	}
+}
+
+// WithTimeout runs the requests of the route under a deadline of d like the
+// Timeout filter, with RequestTimeoutBody. it replaces RequestTimeout and the
+// timeouts of the namespaces of the route, also when it is longer:
+//	beego.Router("/report", &ReportController{}, beego.WithTimeout(2*time.Minute))
+func WithTimeout(d time.Duration) RouteOption {
+	return WithFilters(Timeout(d, RequestTimeoutBody))
+}
+
+// stopTimeout ends the deadline of the request if it has one.
+func stopTimeout(ctx *context.Context) {
+	if t, ok := ctx.Input.GetData(timeoutKey).(*requestTimeout); ok {
+		t.stop()
+	}
+}
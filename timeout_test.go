@@ -0,0 +1,201 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego/context"
+)
+
+func TestTimeout(t *testing.T) {
+	canceled := make(chan bool, 1)
+	w := httptest.NewRecorder()
+	handler := NewControllerRegister()
+	handler.InsertFilter("*", BeforeRouter, Timeout(20*time.Millisecond, `{"error":"timeout"}`))
+	handler.Get("/slow", func(ctx *context.Context) {
+		select {
+		case <-ctx.Request.Context().Done():
+			// the 503 must reach the client before the handler gives up
+			canceled <- w.Flushed && w.Code == http.StatusServiceUnavailable
+		case <-time.After(time.Second):
+			canceled <- false
+		}
+		ctx.WriteString("too late")
+	})
+	handler.Get("/fast", func(ctx *context.Context) {
+		ctx.Output.Header("X-Fast", "1")
+		ctx.WriteString("ok")
+	})
+
+	r, _ := http.NewRequest("GET", "/slow", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"error":"timeout"}` {
+		t.Errorf("slow request should get 503, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("json body should be sent as json, got %s", w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Content-Length") != "19" {
+		t.Errorf("the 503 should be complete, got Content-Length %q", w.Header().Get("Content-Length"))
+	}
+	if !<-canceled {
+		t.Errorf("request context should be canceled after the 503 was flushed")
+	}
+
+	r, _ = http.NewRequest("GET", "/fast", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	time.Sleep(40 * time.Millisecond)
+	if w.Code != http.StatusOK || w.Body.String() != "ok" || w.Header().Get("X-Fast") != "1" {
+		t.Errorf("fast request should not be affected, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	slow := func(ctx *context.Context) {
+		select {
+		case <-ctx.Request.Context().Done():
+			ctx.WriteString("canceled")
+		case <-time.After(100 * time.Millisecond):
+			ctx.WriteString("done")
+		}
+	}
+	handler := NewControllerRegister()
+	handler.InsertFilter("*", BeforeRouter, Timeout(time.Second, ""))
+	handler.Get("/short", slow, WithTimeout(20*time.Millisecond))
+	handler.Get("/long", slow)
+
+	r, _ := http.NewRequest("GET", "/short", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || !w.Flushed {
+		t.Errorf("the route timeout should replace the global one, got %d %s", w.Code, w.Body.String())
+	}
+
+	r, _ = http.NewRequest("GET", "/long", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("the other routes should keep the global timeout, got %d %s", w.Code, w.Body.String())
+	}
+
+	ns := NewNamespace("/timeouts",
+		NSTimeout(20*time.Millisecond),
+		NSGet("/slow", slow),
+		NSGet("/report", slow, WithTimeout(time.Second)),
+	)
+	AddNamespace(ns)
+	r, _ = http.NewRequest("GET", "/timeouts/slow", nil)
+	w = httptest.NewRecorder()
+	BeeApp.Handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || !w.Flushed {
+		t.Errorf("the namespace timeout should apply, got %d %s", w.Code, w.Body.String())
+	}
+	r, _ = http.NewRequest("GET", "/timeouts/report", nil)
+	w = httptest.NewRecorder()
+	BeeApp.Handlers.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Errorf("the route timeout should replace the namespace one, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutHandlerRoute(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.InsertFilter("*", BeforeRouter, Timeout(20*time.Millisecond, ""))
+	handler.Handler("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Header().Set("X-Late", "1")
+		w.Write([]byte("too late"))
+	}))
+
+	r, _ := http.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Late") != "" {
+		t.Errorf("the slow handler should get 503 without its late writes, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestTimeoutRouterWrites(t *testing.T) {
+	defer func(policy string) { RouterTrailingSlash = policy }(RouterTrailingSlash)
+	RouterTrailingSlash = PolicyRedirect
+	handler := NewControllerRegister()
+	handler.InsertFilter("*", BeforeRouter, Timeout(10*time.Millisecond, ""))
+	handler.InsertFilter("*", BeforeRouter, func(ctx *context.Context) {
+		<-ctx.Request.Context().Done()
+	})
+	handler.Post("/users", func(ctx *context.Context) {})
+
+	for _, method := range []string{"OPTIONS", "GET"} {
+		for _, path := range []string{"/users", "/users/"} {
+			r, _ := http.NewRequest(method, path, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusServiceUnavailable || w.Header().Get("Allow") != "" || w.Header().Get("Location") != "" {
+				t.Errorf("%s %s: the router should not write after the 503, got %d %v", method, path, w.Code, w.Header())
+			}
+		}
+	}
+}
+
+func TestTimeoutStream(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.InsertFilter("*", BeforeRouter, Timeout(20*time.Millisecond, ""))
+	handler.Get("/stream", func(ctx *context.Context) {
+		ctx.WriteString("a")
+		ctx.ResponseWriter.(http.Flusher).Flush()
+		select {
+		case <-ctx.Request.Context().Done():
+			ctx.WriteString("canceled")
+		case <-time.After(60 * time.Millisecond):
+			ctx.WriteString("b")
+		}
+	})
+	handler.Get("/hijack", func(ctx *context.Context) {
+		conn, brw, err := ctx.ResponseWriter.(http.Hijacker).Hijack()
+		if err != nil {
+			ctx.WriteString(err.Error())
+			return
+		}
+		defer conn.Close()
+		time.Sleep(60 * time.Millisecond)
+		brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+		brw.Flush()
+	})
+
+	r, _ := http.NewRequest("GET", "/stream", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "ab" {
+		t.Errorf("a flushed stream should outlive the deadline, got %d %s", w.Code, w.Body.String())
+	}
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/hijack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("a hijacked connection should outlive the deadline, got %d %s", resp.StatusCode, body)
+	}
+}
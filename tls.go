@@ -0,0 +1,287 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/aamsur/beego/context"
+)
+
+// ClientCertCheck verifies a client certificate after the standard chain
+// verification, e.g. with OCSP. an error rejects the tls handshake.
+type ClientCertCheck func(cert *x509.Certificate, chains [][]*x509.Certificate) error
+
+var clientCertChecks []ClientCertCheck
+
+// AddClientCertCheck adds a check run for every verified client certificate.
+func AddClientCertCheck(check ClientCertCheck) {
+	clientCertChecks = append(clientCertChecks, check)
+}
+
+// clientAuthTypes are the values of HttpClientAuth.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify":             tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// configureTLS adds the certificate files and the client certificate settings
+// to the tls config of the app.
+//	HttpClientCAFile = conf/clients-ca.pem
+//	HttpClientAuth = require_and_verify
+//	HttpClientCRLFile = conf/clients.crl
+func (app *App) configureTLS() error {
+	cfg := app.Server.TLSConfig
+	if EnableHttpTLS {
+		var err error
+		if cfg, err = certConfig(cfg, HttpCertFile, HttpKeyFile); err != nil {
+			return err
+		}
+	}
+	cfg, err := clientAuthConfig(cfg, HttpClientCAFile, HttpClientAuth, HttpClientCRLFile)
+	if err != nil {
+		return err
+	}
+	app.Server.TLSConfig = cfg
+	return nil
+}
+
+// certReloader serves the certificate of its files, loaded again by Reload.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+}
+
+var (
+	certReloadersLock sync.Mutex
+	certReloaders     []*certReloader
+)
+
+// certConfig returns a copy of base serving the certificate files, which are
+// reloaded on SIGHUP. without files base is returned as is.
+// the servers are started with empty file names then.
+func certConfig(base *tls.Config, certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return base, nil
+	}
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	cfg.Certificates = nil
+	cfg.GetCertificate = r.getCertificate
+	certReloadersLock.Lock()
+	certReloaders = append(certReloaders, r)
+	certReloadersLock.Unlock()
+	return cfg, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	r.cert = &cert
+	r.lock.Unlock()
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.cert, nil
+}
+
+// reloadCertificates loads the certificate files of the servers again,
+// a certificate failing to load is kept.
+func reloadCertificates() error {
+	certReloadersLock.Lock()
+	defer certReloadersLock.Unlock()
+	var errs []string
+	for _, r := range certReloaders {
+		if err := r.reload(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// clientAuthConfig returns a copy of base verifying the client certificates.
+// without settings base is returned as is.
+func clientAuthConfig(base *tls.Config, caFile, mode, crlFile string) (*tls.Config, error) {
+	if caFile == "" && mode == "" {
+		return base, nil
+	}
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+
+	if mode == "" {
+		mode = "require_and_verify"
+	}
+	authType, ok := clientAuthTypes[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown client auth mode %q", mode)
+	}
+	cfg.ClientAuth = authType
+
+	var cas []*x509.Certificate
+	if caFile != "" {
+		var err error
+		if cas, err = loadCertificates(caFile); err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		for _, ca := range cas {
+			cfg.ClientCAs.AddCert(ca)
+		}
+	}
+
+	checks := append([]ClientCertCheck(nil), clientCertChecks...)
+	if crlFile != "" {
+		check, err := crlCheck(crlFile, cas)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	if len(checks) > 0 {
+		// VerifyConnection runs on resumed sessions too, so revocations apply to them
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.VerifiedChains) == 0 {
+				return nil
+			}
+			for _, check := range checks {
+				if err := check(cs.VerifiedChains[0][0], cs.VerifiedChains); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return cfg, nil
+}
+
+func loadCertificates(file string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found in " + file)
+	}
+	return certs, nil
+}
+
+// crlCheck rejects the certificates revoked in the CRL file, pem or der,
+// whose signature is verified with one of the cas.
+func crlCheck(file string, cas []*x509.Certificate) (ClientCertCheck, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, err
+	}
+	signed := false
+	for _, ca := range cas {
+		if crl.CheckSignatureFrom(ca) == nil {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		return nil, errors.New("CRL " + file + " is not signed by a client CA")
+	}
+	revoked := make(map[string]bool, len(crl.RevokedCertificateEntries))
+	for _, entry := range crl.RevokedCertificateEntries {
+		revoked[entry.SerialNumber.String()] = true
+	}
+	return func(cert *x509.Certificate, chains [][]*x509.Certificate) error {
+		if string(cert.RawIssuer) == string(crl.RawIssuer) && revoked[cert.SerialNumber.String()] {
+			return errors.New("client certificate " + cert.SerialNumber.String() + " is revoked")
+		}
+		return nil
+	}, nil
+}
+
+// RequireClientCert returns a filter answering 403 to requests without a verified
+// client certificate. if names are given, the certificate common name or one of its
+// dns and uri SANs must be one of them.
+//	beego.InsertFilter("/internal/*", beego.BeforeRouter, beego.RequireClientCert("billing.svc"))
+func RequireClientCert(names ...string) FilterFunc {
+	return func(ctx *context.Context) {
+		cert := ctx.Input.ClientCert()
+		if cert == nil || (len(names) > 0 && !certHasName(cert, names)) {
+			ctx.Abort(403, "403")
+		}
+	}
+}
+
+func certHasName(cert *x509.Certificate, names []string) bool {
+	ids := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+	for _, id := range ids {
+		for _, name := range names {
+			if strings.EqualFold(id, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego/context"
+)
+
+func newTestCert(t *testing.T, cn string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "beego-mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey, _ := newTestCert(t, "clients ca", 1, nil, nil)
+	_, _, good := newTestCert(t, "billing", 2, ca, caKey)
+	_, _, revoked := newTestCert(t, "stolen", 3, ca, caKey)
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: big.NewInt(3), RevocationTime: time.Now()}},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(dir, "ca.pem")
+	crlFile := filepath.Join(dir, "ca.crl")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+	ioutil.WriteFile(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0600)
+
+	oldCA, oldCRL := HttpClientCAFile, HttpClientCRLFile
+	HttpClientCAFile, HttpClientCRLFile = caFile, crlFile
+	defer func() { HttpClientCAFile, HttpClientCRLFile = oldCA, oldCRL }()
+
+	app := NewApp()
+	if err := app.configureTLS(); err != nil {
+		t.Fatal(err)
+	}
+	app.Handlers.InsertFilter("/internal/*", BeforeRouter, RequireClientCert("billing"))
+	app.Handlers.Get("/internal/whoami", func(ctx *context.Context) {
+		ctx.WriteString(ctx.Input.ClientCert().Subject.CommonName)
+	})
+	server := httptest.NewUnstartedServer(app.Handlers)
+	server.TLS = app.Server.TLSConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(cert *tls.Certificate) (string, error) {
+		// a new transport for every client, so connections are not reused
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		client := &http.Client{Transport: transport}
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+		}
+		resp, err := client.Get(server.URL + "/internal/whoami")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b), nil
+	}
+
+	if body, err := get(&good); err != nil || body != "billing" {
+		t.Errorf("client with valid certificate should be identified, got %q %v", body, err)
+	}
+	if _, err := get(nil); err == nil {
+		t.Error("client without certificate should be rejected")
+	}
+	if _, err := get(&revoked); err == nil {
+		t.Error("client with revoked certificate should be rejected")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolbox
+
+import (
+	"database/sql"
+	"errors"
+	"sync/atomic"
+)
+
+// readiness checker map, checked together with AdminCheckList.
+var ReadinessCheckList map[string]HealthChecker
+
+var maintenance, starting, started, draining int32
+
+// the keys of the flags in the result of CheckReadiness, the checkers with
+// these names are reported as "check:" + name.
+var readinessFlags = map[string]bool{"startup": true, "maintenance": true, "shutdown": true}
+
+// add readiness checker with name string, startup, maintenance and shutdown
+// are reserved for the flags, see CheckReadiness.
+// usage:
+//	toolbox.AddReadinessCheck("db", &toolbox.DBCheck{DB: db})
+//	toolbox.AddReadinessCheck("cache", &toolbox.CacheCheck{Cache: bm})
+func AddReadinessCheck(name string, hc HealthChecker) {
+	ReadinessCheckList[name] = hc
+}
+
+// HealthCheckFunc adapts an ordinary function to the HealthChecker interface.
+type HealthCheckFunc func() error
+
+// Check calls f().
+func (f HealthCheckFunc) Check() error {
+	return f()
+}
+
+// DBCheck pings a database connection pool.
+type DBCheck struct {
+	DB *sql.DB
+}
+
+// Check pings the database.
+func (dc *DBCheck) Check() error {
+	if dc.DB == nil {
+		return errors.New("database is not initialized")
+	}
+	return dc.DB.Ping()
+}
+
+// CachePinger is the part of cache.Cache needed by CacheCheck.
+type CachePinger interface {
+	Get(key string) interface{}
+	Put(key string, val interface{}, timeout int64) error
+	Delete(key string) error
+}
+
+// CacheCheck writes, reads back and deletes a probe key in the cache.
+type CacheCheck struct {
+	Cache CachePinger
+}
+
+const cacheCheckKey = "beego:readiness:probe"
+
+// Check does a put/get/delete round trip on the cache.
+func (cc *CacheCheck) Check() error {
+	if cc.Cache == nil {
+		return errors.New("cache is not initialized")
+	}
+	if err := cc.Cache.Put(cacheCheckKey, "1", 10); err != nil {
+		return err
+	}
+	if cc.Cache.Get(cacheCheckKey) == nil {
+		return errors.New("cache probe key can't be read back")
+	}
+	return cc.Cache.Delete(cacheCheckKey)
+}
+
+// SetMaintenance switches the manual maintenance flag.
+// while it is on, the application reports itself as not ready.
+func SetMaintenance(on bool) {
+	if on {
+		atomic.StoreInt32(&maintenance, 1)
+	} else {
+		atomic.StoreInt32(&maintenance, 0)
+	}
+}
+
+// InMaintenance returns whether the maintenance flag is on.
+func InMaintenance() bool {
+	return atomic.LoadInt32(&maintenance) == 1
+}
+
+// SetStarting switches the startup flag, beego.Run sets it while the startup hooks run.
+// while it is on, the application reports itself as not ready.
+func SetStarting(on bool) {
+	if on {
+		atomic.StoreInt32(&starting, 1)
+	} else {
+		atomic.StoreInt32(&starting, 0)
+	}
+}
+
+// IsStarting returns whether the startup flag is on.
+func IsStarting() bool {
+	return atomic.LoadInt32(&starting) == 1
+}
+
+// SetStarted switches the started flag, beego.Run sets it once the startup hooks are done.
+// until it is on, the startup probe fails.
+func SetStarted(on bool) {
+	if on {
+		atomic.StoreInt32(&started, 1)
+	} else {
+		atomic.StoreInt32(&started, 0)
+	}
+}
+
+// IsStarted returns whether the started flag is on.
+func IsStarted() bool {
+	return atomic.LoadInt32(&started) == 1
+}
+
+// SetDraining switches the shutdown flag, it's set when the servers start draining.
+// while it is on, the application reports itself as not ready.
+func SetDraining(on bool) {
+	if on {
+		atomic.StoreInt32(&draining, 1)
+	} else {
+		atomic.StoreInt32(&draining, 0)
+	}
+}
+
+// IsDraining returns whether the shutdown flag is on.
+func IsDraining() bool {
+	return atomic.LoadInt32(&draining) == 1
+}
+
+// CheckReadiness runs all health and readiness checkers.
+// it returns false if the startup, maintenance or shutdown flag is on or any checker fails,
+// and the result of every checker keyed by name. the flags which are on are
+// keyed by their name, a checker named like a flag is keyed "check:" + name.
+func CheckReadiness() (bool, map[string]string) {
+	ready := true
+	result := make(map[string]string)
+	if IsStarting() {
+		ready = false
+		result["startup"] = "running"
+	}
+	if InMaintenance() {
+		ready = false
+		result["maintenance"] = "on"
+	}
+	if IsDraining() {
+		ready = false
+		result["shutdown"] = "draining"
+	}
+	for _, list := range []map[string]HealthChecker{AdminCheckList, ReadinessCheckList} {
+		for name, hc := range list {
+			if readinessFlags[name] {
+				name = "check:" + name
+			}
+			if err := hc.Check(); err != nil {
+				ready = false
+				result[name] = err.Error()
+			} else {
+				result[name] = "OK"
+			}
+		}
+	}
+	return ready, result
+}
+
+func init() {
+	ReadinessCheckList = make(map[string]HealthChecker)
+}
@@ -0,0 +1,70 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolbox
+
+import (
+	"errors"
+	"testing"
+)
+
+type memCache map[string]interface{}
+
+func (m memCache) Get(key string) interface{} { return m[key] }
+func (m memCache) Put(key string, val interface{}, timeout int64) error {
+	m[key] = val
+	return nil
+}
+func (m memCache) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestReadiness(t *testing.T) {
+	defer delete(ReadinessCheckList, "cache")
+	defer delete(ReadinessCheckList, "broken")
+
+	AddReadinessCheck("cache", &CacheCheck{Cache: memCache{}})
+	if ok, result := CheckReadiness(); !ok {
+		t.Errorf("should be ready, got %v", result)
+	}
+
+	SetMaintenance(true)
+	if ok, result := CheckReadiness(); ok || result["maintenance"] != "on" {
+		t.Errorf("maintenance should make the app not ready, got %v", result)
+	}
+	AddHealthCheck("maintenance", HealthCheckFunc(func() error { return nil }))
+	if _, result := CheckReadiness(); result["maintenance"] != "on" || result["check:maintenance"] != "OK" {
+		t.Errorf("a check named like a flag should not hide it, got %v", result)
+	}
+	delete(AdminCheckList, "maintenance")
+	SetMaintenance(false)
+
+	SetStarting(true)
+	if ok, result := CheckReadiness(); ok || result["startup"] != "running" {
+		t.Errorf("the app should not be ready while starting, got %v", result)
+	}
+	SetStarting(false)
+
+	SetDraining(true)
+	if ok, result := CheckReadiness(); ok || result["shutdown"] != "draining" {
+		t.Errorf("the app should not be ready while draining, got %v", result)
+	}
+	SetDraining(false)
+
+	AddReadinessCheck("broken", HealthCheckFunc(func() error { return errors.New("down") }))
+	if ok, result := CheckReadiness(); ok || result["broken"] != "down" {
+		t.Errorf("failed check should make the app not ready, got %v", result)
+	}
+}
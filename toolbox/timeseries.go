@@ -0,0 +1,131 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolbox
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Sample is one point of the time series kept for the admin dashboard.
+type Sample struct {
+	Time       time.Time
+	Requests   int64
+	QPS        float64
+	AvgLatency time.Duration
+	MemAlloc   uint64
+}
+
+// TimeSeries keeps the latest samples of request rate, latency and memory
+// in a fixed size ring buffer. Nothing is persisted or sent out.
+type TimeSeries struct {
+	lock      sync.Mutex
+	interval  time.Duration
+	samples   []Sample
+	head      int
+	count     int
+	requests  int64
+	totalTime time.Duration
+	last      time.Time
+	started   bool
+}
+
+// NewTimeSeries returns a TimeSeries sampled every interval holding at most size samples.
+func NewTimeSeries(interval time.Duration, size int) *TimeSeries {
+	return &TimeSeries{
+		interval: interval,
+		samples:  make([]Sample, size),
+		last:     time.Now(),
+	}
+}
+
+// AddRequest records one finished request and its duration in the current sample.
+func (ts *TimeSeries) AddRequest(requesttime time.Duration) {
+	ts.lock.Lock()
+	ts.requests++
+	ts.totalTime += requesttime
+	ts.lock.Unlock()
+}
+
+// Sample closes the current sample and stores it in the ring buffer.
+func (ts *TimeSeries) Sample() Sample {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	now := time.Now()
+	s := Sample{
+		Time:     now,
+		Requests: ts.requests,
+		MemAlloc: mem.Alloc,
+	}
+	if elapsed := now.Sub(ts.last).Seconds(); elapsed > 0 {
+		s.QPS = float64(ts.requests) / elapsed
+	}
+	if ts.requests > 0 {
+		s.AvgLatency = time.Duration(int64(ts.totalTime) / ts.requests)
+	}
+	ts.samples[ts.head] = s
+	ts.head = (ts.head + 1) % len(ts.samples)
+	if ts.count < len(ts.samples) {
+		ts.count++
+	}
+	ts.requests = 0
+	ts.totalTime = 0
+	ts.last = now
+	return s
+}
+
+// Samples returns the stored samples, oldest first.
+func (ts *TimeSeries) Samples() []Sample {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	result := make([]Sample, 0, ts.count)
+	start := ts.head - ts.count
+	if start < 0 {
+		start += len(ts.samples)
+	}
+	for i := 0; i < ts.count; i++ {
+		result = append(result, ts.samples[(start+i)%len(ts.samples)])
+	}
+	return result
+}
+
+// Start samples the series every interval in a background goroutine.
+// calling it more than once is a no-op.
+func (ts *TimeSeries) Start() {
+	ts.lock.Lock()
+	if ts.started {
+		ts.lock.Unlock()
+		return
+	}
+	ts.started = true
+	ts.last = time.Now()
+	ts.lock.Unlock()
+	go func() {
+		for range time.Tick(ts.interval) {
+			ts.Sample()
+		}
+	}()
+}
+
+// global time series for the admin dashboard, 10s samples covering the last 30 minutes.
+var TimeSeriesData *TimeSeries
+
+func init() {
+	TimeSeriesData = NewTimeSeries(10*time.Second, 180)
+}
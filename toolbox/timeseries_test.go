@@ -0,0 +1,46 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toolbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeSeries(t *testing.T) {
+	ts := NewTimeSeries(time.Second, 3)
+	ts.AddRequest(2 * time.Millisecond)
+	ts.AddRequest(4 * time.Millisecond)
+	s := ts.Sample()
+	if s.Requests != 2 {
+		t.Errorf("expected 2 requests, got %d", s.Requests)
+	}
+	if s.AvgLatency != 3*time.Millisecond {
+		t.Errorf("expected 3ms average latency, got %s", s.AvgLatency)
+	}
+	for i := 0; i < 4; i++ {
+		ts.AddRequest(time.Millisecond)
+		ts.Sample()
+	}
+	samples := ts.Samples()
+	if len(samples) != 3 {
+		t.Fatalf("ring buffer should keep 3 samples, got %d", len(samples))
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Time.Before(samples[i-1].Time) {
+			t.Errorf("samples should be ordered oldest first")
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/tracing"
+)
+
+// initTracing starts the tracing of TracingExporter, it's off when the
+// exporter is empty.
+func initTracing() error {
+	if TracingExporter == "" {
+		return nil
+	}
+	exporter, err := tracing.NewExporter(TracingExporter, TracingEndpoint)
+	if err != nil {
+		return err
+	}
+	name := TracingServiceName
+	if name == "" {
+		name = AppName
+	}
+	return tracing.Configure(tracing.Config{
+		ServiceName: name,
+		Exporter:    exporter,
+		SampleRatio: TracingSampleRatio,
+	})
+}
+
+// startRequestSpan starts the server span of r, continuing the trace of its
+// traceparent header, and returns r carrying it.
+func startRequestSpan(r *http.Request) (*http.Request, *tracing.Span) {
+	ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method, tracing.SpanKindServer)
+	if span == nil {
+		return r, nil
+	}
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	if ua := r.UserAgent(); ua != "" {
+		span.SetAttribute("user_agent.original", ua)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		span.SetAttribute("client.address", host)
+	}
+	return r.WithContext(ctx), span
+}
+
+// endRequestSpan names the span by the route of the request and ends it with
+// the status of the response, the 5xx are errors.
+func endRequestSpan(span *tracing.Span, ctx *context.Context, w *responseWriter, route *controllerInfo) {
+	if route != nil {
+		span.SetName(ctx.Request.Method + " " + route.pattern)
+		span.SetAttribute("http.route", route.pattern)
+	}
+	status := responseStatus(ctx, w)
+	span.SetAttribute("http.response.status_code", status)
+	if status >= 500 {
+		span.SetStatus(tracing.StatusError, http.StatusText(status))
+	}
+	span.End()
+}
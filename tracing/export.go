@@ -0,0 +1,408 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter sends the ended spans of the service to a backend.
+type Exporter interface {
+	Export(service string, spans []*SpanData) error
+}
+
+// Config configures the tracing of the app.
+type Config struct {
+	ServiceName string
+	Exporter    Exporter
+	// SampleRatio is the ratio of the traces started here that are recorded,
+	// the traces of a request follow the sampled flag of its traceparent.
+	SampleRatio float64
+	// BatchSize and FlushInterval bound the spans kept before an export.
+	BatchSize     int
+	FlushInterval time.Duration
+	// QueueSize is the number of spans waiting for the exporter, the spans
+	// beyond it are dropped.
+	QueueSize int
+}
+
+var (
+	lock    sync.RWMutex
+	current *provider
+)
+
+type provider struct {
+	config      Config
+	sampleBound uint64
+	queue       chan *SpanData
+	flush       chan chan struct{}
+	done        chan struct{}
+	stopped     chan struct{}
+	once        sync.Once
+}
+
+// Configure starts tracing with config, replacing the previous config.
+func Configure(config Config) error {
+	if config.Exporter == nil {
+		return errors.New("tracing: no exporter")
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "beego"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 2048
+	}
+	p := &provider{
+		config:      config,
+		sampleBound: sampleBound(config.SampleRatio),
+		queue:       make(chan *SpanData, config.QueueSize),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go p.run()
+	lock.Lock()
+	prev := current
+	current = p
+	lock.Unlock()
+	if prev != nil {
+		prev.shutdown()
+	}
+	return nil
+}
+
+// Enabled reports whether tracing is configured.
+func Enabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current != nil
+}
+
+// Flush exports the ended spans now.
+func Flush() {
+	lock.RLock()
+	p := current
+	lock.RUnlock()
+	if p == nil {
+		return
+	}
+	ch := make(chan struct{})
+	select {
+	case p.flush <- ch:
+		<-ch
+	case <-p.stopped:
+	}
+}
+
+// Shutdown exports the ended spans and stops tracing.
+func Shutdown() {
+	lock.Lock()
+	p := current
+	current = nil
+	lock.Unlock()
+	if p != nil {
+		p.shutdown()
+	}
+}
+
+func (p *provider) enqueue(span *SpanData) {
+	select {
+	case p.queue <- span:
+	default:
+	}
+}
+
+func (p *provider) shutdown() {
+	p.once.Do(func() { close(p.done) })
+	<-p.stopped
+}
+
+func (p *provider) run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*SpanData, 0, p.config.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := p.config.Exporter.Export(p.config.ServiceName, batch); err != nil {
+			fmt.Fprintln(os.Stderr, "tracing: export:", err)
+		}
+		batch = make([]*SpanData, 0, p.config.BatchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-p.queue:
+				batch = append(batch, span)
+				if len(batch) >= p.config.BatchSize {
+					export()
+				}
+			default:
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= p.config.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ch := <-p.flush:
+			drain()
+			export()
+			close(ch)
+		case <-p.done:
+			drain()
+			export()
+			return
+		}
+	}
+}
+
+// NewExporter returns the exporter named name: "otlp" sends the spans to
+// the OTLP/HTTP endpoint, "stdout" writes them to the standard output.
+func NewExporter(name, endpoint string) (Exporter, error) {
+	switch name {
+	case "otlp":
+		return NewOTLPExporter(endpoint), nil
+	case "stdout":
+		return NewWriterExporter(os.Stdout), nil
+	}
+	return nil, fmt.Errorf("tracing: unknown exporter %q", name)
+}
+
+// OTLPExporter posts the spans as OTLP/HTTP JSON to Endpoint + "/v1/traces".
+type OTLPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+// NewOTLPExporter returns an OTLP exporter for the collector at endpoint,
+// like http://localhost:4318.
+func NewOTLPExporter(endpoint string) *OTLPExporter {
+	return &OTLPExporter{
+		Endpoint: endpoint,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export posts spans to the collector.
+func (e *OTLPExporter) Export(service string, spans []*SpanData) error {
+	body, err := json.Marshal(encodeTraces(service, spans))
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(e.Endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("tracing: collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// WriterExporter writes the spans as OTLP JSON lines to W.
+type WriterExporter struct {
+	lock sync.Mutex
+	W    io.Writer
+}
+
+// NewWriterExporter returns an exporter writing to w.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{W: w}
+}
+
+// Export writes spans to the writer.
+func (e *WriterExporter) Export(service string, spans []*SpanData) error {
+	body, err := json.Marshal(encodeTraces(service, spans))
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	_, err = e.W.Write(append(body, '\n'))
+	return err
+}
+
+// the OTLP JSON encoding, with hex ids and nanoseconds as strings.
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	Name         string         `json:"name"`
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func encodeTraces(service string, spans []*SpanData) otlpTraces {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			Name:              s.Name,
+			Kind:              int(s.Kind),
+			StartTimeUnixNano: unixNano(s.Start),
+			EndTimeUnixNano:   unixNano(s.End),
+			Attributes:        encodeAttributes(s.Attributes),
+			Status:            otlpStatus{Code: int(s.Status), Message: s.StatusMessage},
+		}
+		if s.Parent.IsValid() {
+			span.ParentSpanID = s.Parent.String()
+		}
+		for _, e := range s.Events {
+			span.Events = append(span.Events, otlpEvent{
+				Name:         e.Name,
+				TimeUnixNano: unixNano(e.Time),
+				Attributes:   encodeAttributes(e.Attributes),
+			})
+		}
+		out = append(out, span)
+	}
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttributes(map[string]interface{}{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/aamsur/beego"},
+			Spans: out,
+		}},
+	}}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func encodeAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: encodeValue(attrs[k])})
+	}
+	return kvs
+}
+
+func encodeValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": v}
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+	case int32:
+		return map[string]interface{}{"intValue": strconv.FormatInt(int64(v), 10)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case uint:
+		return map[string]interface{}{"intValue": strconv.FormatUint(uint64(v), 10)}
+	case uint64:
+		return map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+	case float32:
+		return map[string]interface{}{"doubleValue": float64(v)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// RequestStats counts the queries and cache lookups of a request, for its
+// canonical log line. the orm and the cache add to the stats of the context
+// they're given, whether tracing is on or not.
+type RequestStats struct {
+	dbQueries   int64
+	dbTime      int64
+	cacheHits   int64
+	cacheMisses int64
+}
+
+type statsKey struct{}
+
+// ContextWithStats returns a copy of ctx carrying stats.
+func ContextWithStats(ctx context.Context, stats *RequestStats) context.Context {
+	return context.WithValue(ctx, statsKey{}, stats)
+}
+
+// StatsFromContext returns the stats of ctx, or nil.
+func StatsFromContext(ctx context.Context) *RequestStats {
+	if ctx == nil {
+		return nil
+	}
+	stats, _ := ctx.Value(statsKey{}).(*RequestStats)
+	return stats
+}
+
+// AddQuery counts a query which took d.
+func (s *RequestStats) AddQuery(d time.Duration) {
+	if s == nil {
+		return
+	}
+	atomic.AddInt64(&s.dbQueries, 1)
+	atomic.AddInt64(&s.dbTime, int64(d))
+}
+
+// AddCacheLookup counts a cache lookup, a hit or a miss.
+func (s *RequestStats) AddCacheLookup(hit bool) {
+	if s == nil {
+		return
+	}
+	if hit {
+		atomic.AddInt64(&s.cacheHits, 1)
+	} else {
+		atomic.AddInt64(&s.cacheMisses, 1)
+	}
+}
+
+// DBQueries returns the number of queries and their total time.
+func (s *RequestStats) DBQueries() (int64, time.Duration) {
+	if s == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&s.dbQueries), time.Duration(atomic.LoadInt64(&s.dbTime))
+}
+
+// CacheLookups returns the number of cache hits and misses.
+func (s *RequestStats) CacheLookups() (hits, misses int64) {
+	if s == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&s.cacheHits), atomic.LoadInt64(&s.cacheMisses)
+}
@@ -0,0 +1,322 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records the spans of the requests, the queries and the
+// calls of an app and exports them with the OpenTelemetry protocol. it also
+// carries the query and cache stats of a request, see RequestStats.
+//
+// beego starts a server span per request, named by its route pattern, and
+// continues the trace of the traceparent header of the request. the orm, the
+// cache and httplib add child spans when they're given the context of the
+// request:
+//
+//	ctx := c.Context()
+//	o := orm.NewOrmWithContext(ctx)
+//	bm := cache.WithContext(ctx, bm)
+//	httplib.Get(url).WithContext(ctx).String()
+//
+// the spans of the app code:
+//
+//	ctx, span := tracing.Start(ctx, "compute", tracing.SpanKindInternal)
+//	defer span.End()
+//	span.SetAttribute("items", len(items))
+//
+// app.conf:
+//
+//	TracingExporter = otlp
+//	TracingEndpoint = http://localhost:4318
+//	TracingSampleRatio = 0.1
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the hex of the id.
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid reports whether the id is not zero.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies a span of a trace.
+type SpanID [8]byte
+
+// String returns the hex of the id.
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid reports whether the id is not zero.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext identifies a span across the processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// Remote is true for the span context of a request.
+	Remote bool
+}
+
+// IsValid reports whether the trace and span ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind is the role of a span, with the values of the OpenTelemetry protocol.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode is the status of a span.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Event is something that happened during a span, like an error.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]interface{}
+}
+
+// SpanData is an ended span, given to the exporters.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{}
+	Events        []Event
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span is a timed operation of a trace. the methods of a nil span, returned
+// when tracing is off, do nothing.
+type Span struct {
+	lock      sync.Mutex
+	data      SpanData
+	recording bool
+	ended     bool
+}
+
+// SpanContext returns the span context of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// IsRecording reports whether the span is sampled, its attributes are
+// recorded.
+func (s *Span) IsRecording() bool {
+	return s != nil && s.recording
+}
+
+// SetName changes the name of the span.
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Name = name
+}
+
+// SetAttribute sets an attribute of the span: a string, a bool, an integer
+// or a float.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetStatus sets the status of the span, description is for StatusError.
+func (s *Span) SetStatus(code StatusCode, description string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Status = code
+	if code == StatusError {
+		s.data.StatusMessage = description
+	}
+}
+
+// RecordError adds an exception event of err and sets the error status.
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	s.data.Events = append(s.data.Events, Event{
+		Name:       "exception",
+		Time:       time.Now(),
+		Attributes: map[string]interface{}{"exception.message": err.Error()},
+	})
+	s.lock.Unlock()
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End ends the span and queues it for the exporter.
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.lock.Unlock()
+	lock.RLock()
+	p := current
+	lock.RUnlock()
+	if p != nil {
+		p.enqueue(&data)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span, the parent of the
+// spans started with it.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span of ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the span context of the span of ctx, or the
+// remote span context extracted from a request.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	if ctx != nil {
+		if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+			return sc
+		}
+	}
+	return SpanContext{}
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx with the span context of
+// a request, the parent of the spans started with it.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Start starts a span, child of the span of ctx, and returns a copy of ctx
+// carrying it. the span is nil when tracing is off.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return StartAt(ctx, name, kind, time.Now())
+}
+
+// StartAt starts a span at the time start, for the operations timed before.
+func StartAt(ctx context.Context, name string, kind SpanKind, start time.Time) (context.Context, *Span) {
+	lock.RLock()
+	p := current
+	lock.RUnlock()
+	if p == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &Span{data: SpanData{Name: name, Kind: kind, Start: start}}
+	if parent.IsValid() {
+		span.data.Parent = parent.SpanID
+		span.data.SpanContext = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+	} else {
+		span.data.SpanContext.TraceID = newTraceID()
+		span.data.SpanContext.Sampled = p.sample(span.data.SpanContext.TraceID)
+	}
+	span.data.SpanContext.SpanID = newSpanID()
+	span.recording = span.data.SpanContext.Sampled
+	return ContextWithSpan(ctx, span), span
+}
+
+func newTraceID() (id TraceID) {
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// sampleBound returns the bound of the trace ids sampled with ratio.
+func sampleBound(ratio float64) uint64 {
+	if ratio >= 1 {
+		return 1 << 63
+	}
+	if ratio <= 0 {
+		return 0
+	}
+	return uint64(ratio * (1 << 63))
+}
+
+func (p *provider) sample(id TraceID) bool {
+	return binary.BigEndian.Uint64(id[8:])>>1 < p.sampleBound
+}
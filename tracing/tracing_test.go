@@ -0,0 +1,181 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recorder struct {
+	lock  sync.Mutex
+	spans []*SpanData
+}
+
+func (r *recorder) Export(service string, spans []*SpanData) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func TestTraceParent(t *testing.T) {
+	sc, ok := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || !sc.Sampled || !sc.Remote {
+		t.Fatalf("parse: %+v %v", sc, ok)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("ids: %s %s", sc.TraceID, sc.SpanID)
+	}
+	if v := FormatTraceParent(sc); v != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatal("format:", v)
+	}
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x",
+	} {
+		if _, ok := ParseTraceParent(v); ok {
+			t.Error("parsed", v)
+		}
+	}
+	if _, ok := ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-x"); !ok {
+		t.Error("later versions may add fields")
+	}
+}
+
+func TestSpans(t *testing.T) {
+	if ctx, span := Start(context.Background(), "off", SpanKindInternal); span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("span started with tracing off")
+	}
+	rec := &recorder{}
+	Configure(Config{ServiceName: "test", Exporter: rec, SampleRatio: 1})
+	defer Shutdown()
+
+	header := http.Header{}
+	header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), header)
+	ctx, server := Start(ctx, "GET /users/:id", SpanKindServer)
+	_, child := Start(ctx, "SELECT", SpanKindClient)
+	child.SetAttribute("db.system", "mysql")
+	child.RecordError(errors.New("no rows"))
+	child.End()
+	child.End()
+	out := http.Header{}
+	Inject(ctx, out)
+	server.End()
+	Flush()
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("exported %d spans", len(rec.spans))
+	}
+	c, s := rec.spans[0], rec.spans[1]
+	if s.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || s.Parent.String() != "00f067aa0ba902b7" {
+		t.Fatalf("server span not continuing the trace: %+v", s)
+	}
+	if c.SpanContext.TraceID != s.SpanContext.TraceID || c.Parent != s.SpanContext.SpanID {
+		t.Fatal("child span not under the server span")
+	}
+	if c.Status != StatusError || c.StatusMessage != "no rows" || len(c.Events) != 1 || c.Attributes["db.system"] != "mysql" {
+		t.Fatalf("child span: %+v", c)
+	}
+	if out.Get(TraceParentHeader) != FormatTraceParent(server.SpanContext()) {
+		t.Fatal("inject:", out.Get(TraceParentHeader))
+	}
+
+	// the unsampled traces aren't recorded but propagate.
+	header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := Start(Extract(context.Background(), header), "GET", SpanKindServer)
+	if span.IsRecording() {
+		t.Fatal("unsampled span recording")
+	}
+	span.End()
+	Inject(ctx, out)
+	if v := out.Get(TraceParentHeader); v[len(v)-2:] != "00" {
+		t.Fatal("inject unsampled:", v)
+	}
+	Flush()
+	if len(rec.spans) != 2 {
+		t.Fatal("unsampled span exported")
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	p := &provider{sampleBound: sampleBound(0)}
+	if p.sample(newTraceID()) {
+		t.Fatal("ratio 0 sampled")
+	}
+	p.sampleBound = sampleBound(1)
+	for i := 0; i < 100; i++ {
+		if !p.sample(newTraceID()) {
+			t.Fatal("ratio 1 not sampled")
+		}
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]interface{}
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+	}))
+	defer ts.Close()
+
+	Configure(Config{ServiceName: "shop", Exporter: NewOTLPExporter(ts.URL), SampleRatio: 1})
+	_, span := Start(context.Background(), "job", SpanKindInternal)
+	span.SetAttribute("items", 3)
+	span.End()
+	Shutdown()
+
+	if path != "/v1/traces" {
+		t.Fatal("path:", path)
+	}
+	rs := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	attr := rs["resource"].(map[string]interface{})["attributes"].([]interface{})[0].(map[string]interface{})
+	if attr["key"] != "service.name" || attr["value"].(map[string]interface{})["stringValue"] != "shop" {
+		t.Fatal("resource:", attr)
+	}
+	s := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	if s["name"] != "job" || s["traceId"] != span.SpanContext().TraceID.String() || s["kind"].(float64) != 1 {
+		t.Fatal("span:", s)
+	}
+	items := s["attributes"].([]interface{})[0].(map[string]interface{})["value"].(map[string]interface{})
+	if items["intValue"] != "3" {
+		t.Fatal("attribute:", items)
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewWriterExporter(&buf).Export("svc", []*SpanData{{Name: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"name":"a"`)) || buf.Bytes()[buf.Len()-1] != '\n' {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/tracing"
+)
+
+func TestRequestSpan(t *testing.T) {
+	var buf bytes.Buffer
+	tracing.Configure(tracing.Config{Exporter: tracing.NewWriterExporter(&buf), SampleRatio: 1})
+	defer tracing.Shutdown()
+
+	var inner tracing.SpanContext
+	handler := NewControllerRegister()
+	handler.Get("/user/:id", func(ctx *context.Context) {
+		inner = tracing.SpanContextFromContext(ctx.Request.Context())
+		ctx.Output.SetStatus(503)
+		ctx.Output.Body([]byte("down"))
+	})
+	r, _ := http.NewRequest("GET", "/user/12", nil)
+	r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	tracing.Flush()
+
+	if inner.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || inner.Remote {
+		t.Fatalf("handler context without the server span: %+v", inner)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		`"name":"GET /user/:id"`,
+		`"kind":2`,
+		`"parentSpanId":"00f067aa0ba902b7"`,
+		`"spanId":"` + inner.SpanID.String() + `"`,
+		`{"key":"http.route","value":{"stringValue":"/user/:id"}}`,
+		`{"key":"http.response.status_code","value":{"intValue":"503"}}`,
+		`"status":{"code":2`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("no %s in %s", s, out)
+		}
+	}
+}
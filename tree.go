@@ -18,6 +18,7 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/aamsur/beego/utils"
 )
@@ -183,13 +184,26 @@ func filterTreeWithPrefix(t *Tree, wildcards []string, reg string) {
 }
 
 // call addseg function
+// a "*name" segment is a "*" whose value is the :name param too.
 func (t *Tree) AddRouter(pattern string, runObject interface{}) {
-	t.addseg(splitPath(pattern), runObject, nil, "")
+	segments := splitPath(pattern)
+	var splatName string
+	for i, seg := range segments {
+		if catchAllSegment.MatchString(seg) {
+			splatName = ":" + seg[1:]
+			segments[i] = "*"
+		}
+	}
+	leaf := t.addseg(segments, runObject, nil, "")
+	leaf.splatName = splatName
 }
 
+var catchAllSegment = regexp.MustCompile(`^\*[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 // "/"
 // "admin" ->
-func (t *Tree) addseg(segments []string, route interface{}, wildcards []string, reg string) {
+// it returns the leaf added.
+func (t *Tree) addseg(segments []string, route interface{}, wildcards []string, reg string) *leafInfo {
 	if len(segments) == 0 {
 		if reg != "" {
 			filterCards := []string{}
@@ -199,10 +213,13 @@ func (t *Tree) addseg(segments []string, route interface{}, wildcards []string,
 				}
 				filterCards = append(filterCards, v)
 			}
-			t.leaves = append(t.leaves, &leafInfo{runObject: route, wildcards: filterCards, regexps: regexp.MustCompile("^" + reg + "$")})
-		} else {
-			t.leaves = append(t.leaves, &leafInfo{runObject: route, wildcards: wildcards})
+			leaf := &leafInfo{runObject: route, wildcards: filterCards, regexps: regexp.MustCompile("^" + reg + "$")}
+			t.leaves = append(t.leaves, leaf)
+			return leaf
 		}
+		leaf := &leafInfo{runObject: route, wildcards: wildcards}
+		t.leaves = append(t.leaves, leaf)
+		return leaf
 	} else {
 		seg := segments[0]
 		iswild, params, regexpStr := splitSegment(seg)
@@ -250,77 +267,149 @@ func (t *Tree) addseg(segments []string, route interface{}, wildcards []string,
 				}
 
 			}
-			t.wildcard.addseg(segments[1:], route, append(wildcards, params...), reg+regexpStr)
+			return t.wildcard.addseg(segments[1:], route, append(wildcards, params...), reg+regexpStr)
 		} else {
 			subTree, ok := t.fixrouters[seg]
 			if !ok {
 				subTree = NewTree()
 				t.fixrouters[seg] = subTree
 			}
-			subTree.addseg(segments[1:], route, wildcards, reg)
+			return subTree.addseg(segments[1:], route, wildcards, reg)
 		}
 	}
 }
 
 // match router to runObject & params
 func (t *Tree) Match(pattern string) (runObject interface{}, params map[string]string) {
+	var ps Params
+	runObject = t.MatchParams(pattern, &ps)
+	return runObject, ps.Map()
+}
+
+// MatchParams matches pattern like Match, appending the params of the
+// route to params, which is left as it is when nothing matches. Unlike
+// Match it doesn't allocate for the routes without regexp or splat params.
+func (t *Tree) MatchParams(pattern string, params *Params) (runObject interface{}) {
 	if len(pattern) == 0 || pattern[0] != '/' {
-		return nil, nil
+		return nil
 	}
+	b := matchPool.Get().(*matchBuffers)
+	b.segments = splitPathTo(b.segments[:0], pattern)
+	runObject = t.match(b.segments, b.values[:0], params)
+	for i := range b.segments {
+		b.segments[i] = ""
+	}
+	matchPool.Put(b)
+	return runObject
+}
 
-	return t.match(splitPath(pattern), nil)
+// matchBuffers are the path segments and wildcard values of a match,
+// pooled so that matching doesn't allocate.
+type matchBuffers struct {
+	segments []string
+	values   []string
 }
 
-func (t *Tree) match(segments []string, wildcardValues []string) (runObject interface{}, params map[string]string) {
+var matchPool = sync.Pool{
+	New: func() interface{} {
+		return &matchBuffers{
+			segments: make([]string, 0, 16),
+			values:   make([]string, 0, 16),
+		}
+	},
+}
+
+// match walks the segments, wildcardValues may share its backing array
+// with the ones of the callers as they only append past their length.
+func (t *Tree) match(segments []string, wildcardValues []string, params *Params) (runObject interface{}) {
 	// Handle leaf nodes:
 	if len(segments) == 0 {
 		for _, l := range t.leaves {
-			if ok, pa := l.match(wildcardValues); ok {
-				return l.runObject, pa
+			if l.match(wildcardValues, params) {
+				return l.runObject
 			}
 		}
 		if t.wildcard != nil {
 			for _, l := range t.wildcard.leaves {
-				if ok, pa := l.match(wildcardValues); ok {
-					return l.runObject, pa
+				if l.match(wildcardValues, params) {
+					return l.runObject
 				}
 			}
 
 		}
-		return nil, nil
+		return nil
 	}
 
 	seg, segs := segments[0], segments[1:]
 
 	subTree, ok := t.fixrouters[seg]
 	if ok {
-		runObject, params = subTree.match(segs, wildcardValues)
+		runObject = subTree.match(segs, wildcardValues, params)
 	} else if len(segs) == 0 { //.json .xml
 		if subindex := strings.LastIndex(seg, "."); subindex != -1 {
 			subTree, ok = t.fixrouters[seg[:subindex]]
 			if ok {
-				runObject, params = subTree.match(segs, wildcardValues)
+				runObject = subTree.match(segs, wildcardValues, params)
 				if runObject != nil {
-					if params == nil {
-						params = make(map[string]string)
-					}
-					params[":ext"] = seg[subindex+1:]
-					return runObject, params
+					params.Set(":ext", seg[subindex+1:])
+					return runObject
 				}
 			}
 		}
 	}
 	if runObject == nil && t.wildcard != nil {
-		runObject, params = t.wildcard.match(segs, append(wildcardValues, seg))
+		runObject = t.wildcard.match(segs, append(wildcardValues, seg), params)
 	}
 	if runObject == nil {
 		for _, l := range t.leaves {
-			if ok, pa := l.match(append(wildcardValues, segments...)); ok {
-				return l.runObject, pa
+			if l.match(append(wildcardValues, segments...), params) {
+				return l.runObject
 			}
 		}
 	}
-	return runObject, params
+	return runObject
+}
+
+// Param is a router param of a matched route.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params are the router params of a matched route.
+type Params []Param
+
+// Get returns the value of the param key.
+func (ps Params) Get(key string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the param key, replacing its value if it's set.
+func (ps *Params) Set(key, value string) {
+	for i := range *ps {
+		if (*ps)[i].Key == key {
+			(*ps)[i].Value = value
+			return
+		}
+	}
+	*ps = append(*ps, Param{key, value})
+}
+
+// Map returns the params as a map, nil if there are none.
+func (ps Params) Map() map[string]string {
+	if len(ps) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(ps))
+	for _, p := range ps {
+		m[p.Key] = p.Value
+	}
+	return m
 }
 
 type leafInfo struct {
@@ -331,49 +420,63 @@ type leafInfo struct {
 	regexps *regexp.Regexp
 
 	runObject interface{}
+
+	// name of the "*name" catch-all param, also given as :splat
+	splatName string
 }
 
-func (leaf *leafInfo) match(wildcardValues []string) (ok bool, params map[string]string) {
+// match adds the params of the leaf for wildcardValues to params if it matches.
+func (leaf *leafInfo) match(wildcardValues []string, params *Params) bool {
+	n := len(*params)
+	if !leaf.matchWildcards(wildcardValues, params) {
+		*params = (*params)[:n]
+		return false
+	}
+	if leaf.splatName != "" {
+		if v, found := params.Get(":splat"); found {
+			params.Set(leaf.splatName, v)
+		}
+	}
+	return true
+}
+
+func (leaf *leafInfo) matchWildcards(wildcardValues []string, params *Params) bool {
 	if leaf.regexps == nil {
 		// has error
 		if len(wildcardValues) == 0 && len(leaf.wildcards) > 0 {
 			if utils.InSlice(":", leaf.wildcards) {
-				params = make(map[string]string)
 				j := 0
 				for _, v := range leaf.wildcards {
 					if v == ":" {
 						continue
 					}
-					params[v] = ""
+					params.Set(v, "")
 					j += 1
 				}
-				return true, params
+				return true
 			}
-			return false, nil
+			return false
 		} else if len(wildcardValues) == 0 { // static path
-			return true, nil
+			return true
 		}
 		// match *
 		if len(leaf.wildcards) == 1 && leaf.wildcards[0] == ":splat" {
-			params = make(map[string]string)
-			params[":splat"] = path.Join(wildcardValues...)
-			return true, params
+			params.Set(":splat", path.Join(wildcardValues...))
+			return true
 		}
 		// match *.*
 		if len(leaf.wildcards) == 3 && leaf.wildcards[0] == "." {
-			params = make(map[string]string)
 			lastone := wildcardValues[len(wildcardValues)-1]
 			strs := strings.SplitN(lastone, ".", 2)
 			if len(strs) == 2 {
-				params[":ext"] = strs[1]
+				params.Set(":ext", strs[1])
 			} else {
-				params[":ext"] = ""
+				params.Set(":ext", "")
 			}
-			params[":path"] = path.Join(wildcardValues[:len(wildcardValues)-1]...) + "/" + strs[0]
-			return true, params
+			params.Set(":path", path.Join(wildcardValues[:len(wildcardValues)-1]...)+"/"+strs[0])
+			return true
 		}
 		// match :id
-		params = make(map[string]string)
 		j := 0
 		for _, v := range leaf.wildcards {
 			if v == ":" {
@@ -383,38 +486,37 @@ func (leaf *leafInfo) match(wildcardValues []string) (ok bool, params map[string
 				lastone := wildcardValues[len(wildcardValues)-1]
 				strs := strings.SplitN(lastone, ".", 2)
 				if len(strs) == 2 {
-					params[":ext"] = strs[1]
+					params.Set(":ext", strs[1])
 				} else {
-					params[":ext"] = ""
+					params.Set(":ext", "")
 				}
 				if len(wildcardValues[j:]) == 1 {
-					params[":path"] = strs[0]
+					params.Set(":path", strs[0])
 				} else {
-					params[":path"] = path.Join(wildcardValues[j:]...) + "/" + strs[0]
+					params.Set(":path", path.Join(wildcardValues[j:]...)+"/"+strs[0])
 				}
-				return true, params
+				return true
 			}
 			if len(wildcardValues) <= j {
-				return false, nil
+				return false
 			}
-			params[v] = wildcardValues[j]
+			params.Set(v, wildcardValues[j])
 			j += 1
 		}
-		if len(params) != len(wildcardValues) {
-			return false, nil
+		if j != len(wildcardValues) {
+			return false
 		}
-		return true, params
+		return true
 	}
 
-	if !leaf.regexps.MatchString(path.Join(wildcardValues...)) {
-		return false, nil
-	}
-	params = make(map[string]string)
 	matches := leaf.regexps.FindStringSubmatch(path.Join(wildcardValues...))
+	if matches == nil {
+		return false
+	}
 	for i, match := range matches[1:] {
-		params[leaf.wildcards[i]] = match
+		params.Set(leaf.wildcards[i], match)
 	}
-	return true, params
+	return true
 }
 
 // "/" -> []
@@ -435,11 +537,73 @@ func splitPath(key string) []string {
 	return elements
 }
 
+// splitPathTo appends the segments of key, as split by splitPath, to segments.
+func splitPathTo(segments []string, key string) []string {
+	if key == "" {
+		return segments
+	}
+	if key[0] == '/' {
+		if key = key[1:]; key == "" {
+			return segments
+		}
+	}
+	key = strings.TrimSuffix(key, "/")
+	for {
+		i := strings.IndexByte(key, '/')
+		if i == -1 {
+			return append(segments, key)
+		}
+		segments = append(segments, key[:i])
+		key = key[i+1:]
+	}
+}
+
+// paramTypes maps the type names usable as ":param:type" to their expressions.
+// every expression must contain exactly one capturing group.
+var paramTypes = map[string]string{
+	"int":    `([0-9]+)`,
+	"string": `([\w]+)`,
+	"float":  `([0-9]+(?:\.[0-9]+)?)`,
+	"hex":    `([0-9a-fA-F]+)`,
+	"bool":   `(true|false|1|0)`,
+	"slug":   `([a-z0-9]+(?:-[a-z0-9]+)*)`,
+	"uuid":   `([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})`,
+}
+
+// AddParamType registers a type for route params, e.g.
+// AddParamType("year", "[0-9]{4}") enables "/archive/:y:year".
+// it must be called before the routes using it are added.
+func AddParamType(name, expr string) {
+	if name == "" || !regexp.MustCompile(`^[a-zA-Z]+$`).MatchString(name) {
+		panic("invalid param type name: " + name)
+	}
+	reg := regexp.MustCompile(expr)
+	switch reg.NumSubexp() {
+	case 0:
+		expr = "(" + expr + ")"
+	case 1:
+	default:
+		panic("param type " + name + " must have at most one capturing group")
+	}
+	paramTypes[name] = expr
+}
+
+// lookupParamType returns the longest registered type name s starts with.
+func lookupParamType(s string) (name, expr string) {
+	for k, v := range paramTypes {
+		if len(k) > len(name) && strings.HasPrefix(s, k) {
+			name, expr = k, v
+		}
+	}
+	return
+}
+
 // "admin" -> false, nil, ""
 // ":id" -> true, [:id], ""
 // "?:id" -> true, [: :id], ""        : meaning can empty
 // ":id:int" -> true, [:id], ([0-9]+)
 // ":name:string" -> true, [:name], ([\w]+)
+// ":id:uuid" -> true, [:id], the expression registered as uuid
 // ":id([0-9]+)" -> true, [:id], ([0-9]+)
 // ":id([0-9]+)_:name" -> true, [:id :name], ([0-9]+)_(.+)
 // "cms_:id_:page.html" -> true, [:id :page], cms_(.+)_(.+).html
@@ -469,31 +633,17 @@ func splitSegment(key string) (bool, []string, string) {
 				continue
 			}
 			if start {
-				//:id:int and :name:string
+				//:id:int, :name:string and the other registered types
 				if v == ':' {
-					if len(key) >= i+4 {
-						if key[i+1:i+4] == "int" {
-							out = append(out, []rune("([0-9]+)")...)
-							params = append(params, ":"+string(param))
-							start = false
-							startexp = false
-							skipnum = 3
-							param = make([]rune, 0)
-							paramsNum += 1
-							continue
-						}
-					}
-					if len(key) >= i+7 {
-						if key[i+1:i+7] == "string" {
-							out = append(out, []rune(`([\w]+)`)...)
-							params = append(params, ":"+string(param))
-							paramsNum += 1
-							start = false
-							startexp = false
-							skipnum = 6
-							param = make([]rune, 0)
-							continue
-						}
+					if name, expr := lookupParamType(key[i+1:]); name != "" {
+						out = append(out, []rune(expr)...)
+						params = append(params, ":"+string(param))
+						paramsNum += 1
+						start = false
+						startexp = false
+						skipnum = len(name)
+						param = make([]rune, 0)
+						continue
 					}
 				}
 				// params only support a-zA-Z0-9
@@ -57,6 +57,71 @@ func init() {
 	routers = append(routers, testinfo{"/v1/:v/cms/aaa_:id(.+)_:page(.+).html", "/v1/2/cms/aaa_123_1.html", map[string]string{":v": "2", ":id": "123", ":page": "1"}})
 	routers = append(routers, testinfo{"/v1/:v/cms_:id(.+)_:page(.+).html", "/v1/2/cms_123_1.html", map[string]string{":v": "2", ":id": "123", ":page": "1"}})
 	routers = append(routers, testinfo{"/v1/:v(.+)_cms/ttt_:id(.+)_:page(.+).html", "/v1/2_cms/ttt_123_1.html", map[string]string{":v": "2", ":id": "123", ":page": "1"}})
+	routers = append(routers, testinfo{"/files/*filepath", "/files/css/site/main.css", map[string]string{":filepath": "css/site/main.css", ":splat": "css/site/main.css"}})
+	routers = append(routers, testinfo{"/repo/:name/*file_path", "/repo/beego/docs/a.md", map[string]string{":name": "beego", ":file_path": "docs/a.md"}})
+	routers = append(routers, testinfo{"/raw/*filepath/edit", "/raw/a/b/edit", map[string]string{":filepath": "a/b"}})
+	routers = append(routers, testinfo{"/price/:amount:float", "/price/12.50", map[string]string{":amount": "12.50"}})
+	routers = append(routers, testinfo{"/post/:slug:slug", "/post/hello-world-2", map[string]string{":slug": "hello-world-2"}})
+	routers = append(routers, testinfo{"/order/:id:uuid/items", "/order/123e4567-e89b-12d3-a456-426614174000/items", map[string]string{":id": "123e4567-e89b-12d3-a456-426614174000"}})
+	routers = append(routers, testinfo{"/color/:c:hex.json", "/color/ff00aa.json", map[string]string{":c": "ff00aa"}})
+	routers = append(routers, testinfo{"/flag/:on:bool", "/flag/true", map[string]string{":on": "true"}})
+}
+
+var mismatches = []testinfo{
+	{"/user/:id:int", "/user/abc", nil},
+	{"/user/:id:int", "/user/12a", nil},
+	{"/user/:id:int/posts", "/user/x/posts", nil},
+	{"/user/:id([0-9]+)", "/user/12a", nil},
+	{"/user/:name:string", "/user/a-b", nil},
+	{"/u/:id:int_:name:string", "/u/x_ab", nil},
+	{"/price/:amount:float", "/price/1.2.3", nil},
+	{"/post/:slug:slug", "/post/Hello--World", nil},
+	{"/order/:id:uuid", "/order/123e4567", nil},
+	{"/color/:c:hex", "/color/ff00zz", nil},
+	{"/flag/:on:bool", "/flag/yes", nil},
+}
+
+func TestTreeConstraintMismatch(t *testing.T) {
+	for _, r := range mismatches {
+		tr := NewTree()
+		tr.AddRouter(r.url, "astaxie")
+		if obj, _ := tr.Match(r.requesturl); obj != nil {
+			t.Errorf("%s should not match %s", r.url, r.requesturl)
+		}
+	}
+}
+
+func TestTreeConstraintFallthrough(t *testing.T) {
+	tr := NewTree()
+	tr.AddRouter("/user/:id:int", "id")
+	tr.AddRouter("/user/:name", "name")
+	if obj, param := tr.Match("/user/12"); obj != "id" || param[":id"] != "12" {
+		t.Fatal("/user/12 should match /user/:id:int")
+	}
+	if obj, param := tr.Match("/user/ab"); obj != "name" || param[":name"] != "ab" {
+		t.Fatal("/user/ab should match /user/:name")
+	}
+}
+
+func TestAddParamType(t *testing.T) {
+	AddParamType("year", "[0-9]{4}")
+	defer delete(paramTypes, "year")
+	tr := NewTree()
+	tr.AddRouter("/archive/:y:year/:m:int", "astaxie")
+	if obj, param := tr.Match("/archive/2014/05"); obj == nil || param[":y"] != "2014" || param[":m"] != "05" {
+		t.Fatal("/archive/2014/05 should match with :y 2014 and :m 05")
+	}
+	if obj, _ := tr.Match("/archive/14/05"); obj != nil {
+		t.Fatal("/archive/14/05 should not match")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("param type with two groups should panic")
+			}
+		}()
+		AddParamType("pair", "([0-9]+)-([0-9]+)")
+	}()
 }
 
 func TestTreeRouters(t *testing.T) {
@@ -175,6 +240,82 @@ func TestSplitPath(t *testing.T) {
 	}
 }
 
+func TestSplitPathTo(t *testing.T) {
+	for _, p := range []string{"", "/", "//", "/admin", "/admin/", "/admin//", "admin/users", "/admin//users/"} {
+		want := splitPath(p)
+		got := splitPathTo(nil, p)
+		if len(got) != len(want) {
+			t.Errorf("%q: got %q, want %q", p, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%q: got %q, want %q", p, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestMatchParams(t *testing.T) {
+	for _, r := range routers {
+		tr := NewTree()
+		tr.AddRouter(r.url, "astaxie")
+		params := Params{{":host", "example.com"}}
+		if obj := tr.MatchParams(r.requesturl, &params); obj == nil || obj.(string) != "astaxie" {
+			t.Fatal(r.url + " can't get obj ")
+		}
+		for k, v := range r.params {
+			if vv, _ := params.Get(k); vv != v {
+				t.Fatal(r.url + "     " + r.requesturl + " should be:" + v + " get param:" + vv)
+			}
+		}
+		if params[0] != (Param{":host", "example.com"}) {
+			t.Fatal(r.url + " should keep the params set before")
+		}
+		n := len(params)
+		if tr.MatchParams("/notmatch/with/many/segments/x/y", &params) == nil && len(params) != n {
+			t.Fatal(r.url + " should not change the params when nothing matches")
+		}
+	}
+}
+
+func TestMatchParamsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	tr := NewTree()
+	tr.AddRouter("/", "root")
+	tr.AddRouter("/api/users", "users")
+	tr.AddRouter("/api/users/:id", "user")
+	tr.AddRouter("/api/users/:id/posts/:post", "post")
+	tr.AddRouter("/api/:group/feed", "feed")
+	params := make(Params, 0, 8)
+	for _, u := range []string{"/", "/api/users", "/api/users/42", "/api/users/42/posts/7", "/api/users/42/posts/7.json", "/api/news/feed"} {
+		tr.MatchParams(u, &params)
+		allocs := testing.AllocsPerRun(100, func() {
+			params = params[:0]
+			if tr.MatchParams(u, &params) == nil {
+				t.Fatal(u + " should match")
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%s: %v allocs per match, want 0", u, allocs)
+		}
+	}
+}
+
+func BenchmarkMatchParams(b *testing.B) {
+	tr := NewTree()
+	tr.AddRouter("/api/users/:id/posts/:post", "post")
+	params := make(Params, 0, 8)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		params = params[:0]
+		tr.MatchParams("/api/users/42/posts/7", &params)
+	}
+}
+
 func TestSplitSegment(t *testing.T) {
 	b, w, r := splitSegment("admin")
 	if b || len(w) != 0 || r != "" {
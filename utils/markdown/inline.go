@@ -0,0 +1,249 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+const punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// inline renders the spans of text: escapes, code, links, images, autolinks and emphasis.
+func inline(b *bytes.Buffer, text string) {
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte(punctuation, text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if n := codeSpan(b, text[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '!' && strings.HasPrefix(text[i:], "!["):
+			if alt, url, title, n := link(text[i+1:]); n > 0 {
+				b.WriteString(`<img src="` + html.EscapeString(safeURL(url)) + `" alt="` + html.EscapeString(plain(alt)) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">")
+				i += n + 1
+				continue
+			}
+
+		case c == '[':
+			if label, url, title, n := link(text[i:]); n > 0 {
+				b.WriteString(`<a href="` + html.EscapeString(safeURL(url)) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">")
+				inline(b, label)
+				b.WriteString("</a>")
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				url := text[i+1 : i+end]
+				if !strings.ContainsAny(url, " <") && (isAbsolute(url) || strings.HasPrefix(url, "mailto:")) {
+					b.WriteString(`<a href="` + html.EscapeString(safeURL(url)) + `">` + html.EscapeString(url) + "</a>")
+					i += end + 1
+					continue
+				}
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if n := emphasis(b, text, i); n > 0 {
+				i += n
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+}
+
+// codeSpan renders the code span at the start of text and returns its length, or 0.
+func codeSpan(b *bytes.Buffer, text string) int {
+	ticks := len(text) - len(strings.TrimLeft(text, "`"))
+	fence := text[:ticks]
+	for j := ticks; j < len(text); {
+		k := strings.Index(text[j:], fence)
+		if k < 0 {
+			return 0
+		}
+		k += j
+		// the closing run has the same length
+		if k+ticks < len(text) && text[k+ticks] == '`' {
+			j = k + ticks + 1
+			for j < len(text) && text[j] == '`' {
+				j++
+			}
+			continue
+		}
+		code := text[ticks:k]
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+			code = code[1 : len(code)-1]
+		}
+		b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		return k + ticks
+	}
+	return 0
+}
+
+// link parses [label](url "title") at the start of text and returns its length, or 0.
+func link(text string) (label, url, title string, n int) {
+	depth := 0
+	end := -1
+	for j := 0; j < len(text) && end < 0; j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				end = j
+			}
+		}
+	}
+	if end < 0 || end+1 >= len(text) || text[end+1] != '(' {
+		return "", "", "", 0
+	}
+	// the destination may hold balanced parentheses, like wikipedia urls
+	closing := -1
+	depth = 0
+	for j := end + 2; j < len(text) && closing < 0; j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				closing = j - end - 2
+			}
+			depth--
+		}
+	}
+	if closing < 0 {
+		return "", "", "", 0
+	}
+	dest := strings.TrimSpace(text[end+2 : end+2+closing])
+	if k := strings.IndexAny(dest, " \t"); k > 0 {
+		t := strings.TrimSpace(dest[k:])
+		if len(t) >= 2 && (t[0] == '"' || t[0] == '\'') && t[len(t)-1] == t[0] {
+			title = t[1 : len(t)-1]
+		}
+		dest = dest[:k]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return text[1:end], dest, title, end + 3 + closing
+}
+
+// emphasis renders the *em*, **strong** or ~~del~~ span starting at text[i]
+// and returns its length, or 0.
+func emphasis(b *bytes.Buffer, text string, i int) int {
+	c := text[i]
+	run := 1
+	if i+1 < len(text) && text[i+1] == c {
+		run = 2
+	}
+	if c == '~' && run != 2 {
+		return 0
+	}
+	// _ inside words, like snake_case, is not emphasis
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return 0
+	}
+	delim := text[i : i+run]
+	start := i + run
+	if start >= len(text) || text[start] == ' ' {
+		return 0
+	}
+	for j := start + 1; j <= len(text)-run; j++ {
+		if text[j:j+run] != delim || text[j-1] == ' ' || text[j-1] == '\\' {
+			continue
+		}
+		// a single delimiter does not close inside a double one
+		if run == 1 && j+1 < len(text) && text[j+1] == c {
+			j++
+			continue
+		}
+		if c == '_' && j+run < len(text) && isWordByte(text[j+run]) {
+			continue
+		}
+		tag := "em"
+		if c == '~' {
+			tag = "del"
+		} else if run == 2 {
+			tag = "strong"
+		}
+		b.WriteString("<" + tag + ">")
+		inline(b, text[start:j])
+		b.WriteString("</" + tag + ">")
+		return j + run - i
+	}
+	return 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// plain returns the text of inline markdown, for the alt of images.
+func plain(text string) string {
+	var b bytes.Buffer
+	inline(&b, text)
+	s := b.String()
+	var out strings.Builder
+	in := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			in = true
+		case r == '>':
+			in = false
+		case !in:
+			out.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(out.String())
+}
+
+// isAbsolute reports whether url has an http or https scheme.
+func isAbsolute(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// safeURL returns url if it's http, https, mailto or relative, else "#".
+func safeURL(url string) string {
+	if i := strings.IndexAny(url, ":/?#"); i >= 0 && url[i] == ':' {
+		scheme := strings.ToLower(url[:i])
+		if scheme != "http" && scheme != "https" && scheme != "mailto" {
+			return "#"
+		}
+	}
+	return url
+}
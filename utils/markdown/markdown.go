@@ -0,0 +1,301 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package markdown renders markdown to sanitized html.
+//
+// it supports headings, paragraphs, emphasis, strikethrough, code spans,
+// fenced and indented code blocks, block quotes, lists, links, images,
+// autolinks and horizontal rules. raw html is escaped and links are limited
+// to http, https, mailto and relative urls, so user content can be rendered.
+//
+// usage:
+//
+//	html := markdown.Render(src)
+//
+//	r := &markdown.Renderer{Highlight: func(code, lang string) (string, bool) {
+//		return highlighter.Format(code, lang)
+//	}}
+//	html := r.Render(src)
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Renderer renders markdown with its options.
+type Renderer struct {
+	// Highlight returns the html of a fenced code block of lang, or false
+	// to escape it as is. its result is not sanitized.
+	Highlight func(code, lang string) (string, bool)
+	// HeadingIDs adds ids made from their text to the headings.
+	HeadingIDs bool
+}
+
+// DefaultRenderer is used by Render.
+var DefaultRenderer = &Renderer{HeadingIDs: true}
+
+// Render renders src to html with DefaultRenderer.
+func Render(src []byte) []byte {
+	return DefaultRenderer.Render(src)
+}
+
+var (
+	headingRegexp = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	hrRegexp      = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	itemRegexp    = regexp.MustCompile(`^([ ]{0,3})([-*+]|(\d{1,9})[.)])(?:[ \t]+(.*))?$`)
+	fenceRegexp   = regexp.MustCompile("^[ ]{0,3}(```+|~~~+)[ \t]*([^`\\s]*)")
+)
+
+// Render renders src to html.
+func (r *Renderer) Render(src []byte) []byte {
+	text := strings.Replace(string(src), "\r\n", "\n", -1)
+	text = strings.Replace(text, "\t", "    ", -1)
+	var b bytes.Buffer
+	r.blocks(&b, strings.Split(text, "\n"))
+	return b.Bytes()
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// startsBlock reports whether line interrupts a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return headingRegexp.MatchString(trimmed) || hrRegexp.MatchString(trimmed) ||
+		fenceRegexp.MatchString(line) || strings.HasPrefix(trimmed, ">") || itemRegexp.MatchString(line)
+}
+
+func (r *Renderer) blocks(b *bytes.Buffer, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+
+		case fenceRegexp.MatchString(line):
+			m := fenceRegexp.FindStringSubmatch(line)
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]); i++ {
+				code = append(code, lines[i])
+			}
+			i++
+			r.code(b, strings.Join(code, "\n"), m[2])
+
+		case indent(line) >= 4:
+			var code []string
+			for ; i < len(lines) && (indent(lines[i]) >= 4 || isBlank(lines[i])); i++ {
+				if len(lines[i]) >= 4 {
+					code = append(code, lines[i][4:])
+				} else {
+					code = append(code, "")
+				}
+			}
+			for len(code) > 0 && code[len(code)-1] == "" {
+				code = code[:len(code)-1]
+			}
+			r.code(b, strings.Join(code, "\n"), "")
+
+		case headingRegexp.MatchString(trimmed):
+			m := headingRegexp.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(m[1]))
+			b.WriteString("<h" + level)
+			if r.HeadingIDs && m[2] != "" {
+				b.WriteString(` id="` + slug(m[2]) + `"`)
+			}
+			b.WriteString(">")
+			inline(b, m[2])
+			b.WriteString("</h" + level + ">\n")
+			i++
+
+		case hrRegexp.MatchString(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				l := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, strings.TrimPrefix(l, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			r.blocks(b, quote)
+			b.WriteString("</blockquote>\n")
+
+		case itemRegexp.MatchString(line):
+			i = r.list(b, lines, i)
+
+		default:
+			var para []string
+			for ; i < len(lines) && !isBlank(lines[i]) && (len(para) == 0 || !startsBlock(lines[i])); i++ {
+				para = append(para, lines[i])
+			}
+			b.WriteString("<p>")
+			for j, l := range para {
+				if j > 0 {
+					b.WriteString("\n")
+				}
+				if j < len(para)-1 && strings.HasSuffix(l, "  ") {
+					inline(b, strings.TrimSpace(l))
+					b.WriteString("<br>")
+				} else {
+					inline(b, strings.TrimSpace(l))
+				}
+			}
+			b.WriteString("</p>\n")
+		}
+	}
+}
+
+// list renders the list starting at lines[i] and returns the index after it.
+// the lines indented under an item are its content, nested lists included.
+func (r *Renderer) list(b *bytes.Buffer, lines []string, i int) int {
+	first := itemRegexp.FindStringSubmatch(lines[i])
+	ordered := first[3] != ""
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered && first[3] != "1" {
+		n, _ := strconv.Atoi(first[3])
+		b.WriteString(` start="` + strconv.Itoa(n) + `"`)
+	}
+	b.WriteString(">\n")
+
+	base := len(first[1])
+	for i < len(lines) {
+		m := itemRegexp.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != base || (m[3] != "") != ordered {
+			break
+		}
+		content := []string{m[4]}
+		// the content of the item is indented past its marker
+		width := base + len(m[2]) + 1
+		loose := false
+		for i++; i < len(lines); i++ {
+			l := lines[i]
+			if isBlank(l) {
+				if i+1 < len(lines) && indent(lines[i+1]) >= width {
+					loose = true
+					content = append(content, "")
+					continue
+				}
+				break
+			}
+			if indent(l) >= width {
+				content = append(content, l[width:])
+			} else if indent(l) > base && itemRegexp.MatchString(l) {
+				content = append(content, strings.TrimLeft(l, " "))
+			} else if !startsBlock(l) && !isBlank(content[len(content)-1]) {
+				// a lazy continuation of the paragraph
+				content = append(content, strings.TrimSpace(l))
+			} else {
+				break
+			}
+		}
+		b.WriteString("<li>")
+		if !loose && !hasBlock(content) {
+			inline(b, strings.Join(trimAll(content), "\n"))
+		} else if !loose {
+			// a tight item: its first paragraph is not wrapped in <p>
+			j := 0
+			for j < len(content) && !startsBlock(content[j]) {
+				j++
+			}
+			inline(b, strings.Join(trimAll(content[:j]), "\n"))
+			b.WriteString("\n")
+			r.blocks(b, content[j:])
+		} else {
+			b.WriteString("\n")
+			r.blocks(b, content)
+		}
+		b.WriteString("</li>\n")
+
+		// a blank line between items keeps the list going
+		if i < len(lines) && isBlank(lines[i]) && i+1 < len(lines) {
+			if m := itemRegexp.FindStringSubmatch(lines[i+1]); m != nil && len(m[1]) == base {
+				i++
+			}
+		}
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func hasBlock(lines []string) bool {
+	for _, l := range lines[1:] {
+		if startsBlock(l) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimAll(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, l := range lines {
+		trimmed[i] = strings.TrimSpace(l)
+	}
+	return trimmed
+}
+
+func (r *Renderer) code(b *bytes.Buffer, code, lang string) {
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+	b.WriteString(">")
+	if highlighted, ok := r.highlight(code, lang); ok {
+		b.WriteString(highlighted)
+	} else {
+		b.WriteString(html.EscapeString(code))
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+func (r *Renderer) highlight(code, lang string) (string, bool) {
+	if r.Highlight == nil {
+		return "", false
+	}
+	return r.Highlight(code, lang)
+}
+
+// slug makes a heading id: lower case letters and digits joined by dashes.
+func slug(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(text) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"# Hello *world*", `<h1 id="hello-world">Hello <em>world</em></h1>`},
+		{"## Install ##", `<h2 id="install">Install</h2>`},
+		{"a **b** _c_ ~~d~~ `e<f>`", "<p>a <strong>b</strong> <em>c</em> <del>d</del> <code>e&lt;f&gt;</code></p>"},
+		{"snake_case_name and 2*3*4", "<p>snake_case_name and 2<em>3</em>4</p>"},
+		{"line one  \nline two", "<p>line one<br>\nline two</p>"},
+		{"[beego](http://beego.me \"site\") ![logo *x*](/logo.png)", `<p><a href="http://beego.me" title="site">beego</a> <img src="/logo.png" alt="logo x"></p>`},
+		{"[Go](https://en.wikipedia.org/wiki/Go_(language)) (see)", `<p><a href="https://en.wikipedia.org/wiki/Go_(language)">Go</a> (see)</p>`},
+		{"<https://beego.me/docs>", `<p><a href="https://beego.me/docs">https://beego.me/docs</a></p>`},
+		{"```go\nfmt.Println(\"<hi>\")\n```", `<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>`},
+		{"    indented\n    code", "<pre><code>indented\ncode</code></pre>"},
+		{"> quoted\n> **text**", "<blockquote>\n<p>quoted\n<strong>text</strong></p>\n</blockquote>"},
+		{"- one\n- two\n  - nested\n- three", "<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul>\n</li>\n<li>three</li>\n</ul>"},
+		{"3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>"},
+		{"para\n***\nnext", "<p>para</p>\n<hr>\n<p>next</p>"},
+		{`\*not em\*`, "<p>*not em*</p>"},
+	}
+	for _, test := range tests {
+		if got := strings.TrimSpace(string(Render([]byte(test.src)))); got != test.want {
+			t.Errorf("Render(%q)\n got %q\nwant %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{`<script>alert(1)</script>`, "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>"},
+		{`[x](javascript:alert(1))`, `<p><a href="#">x</a></p>`},
+		{`[x](JavaScript:alert&#40;1&#41;)`, `<p><a href="#">x</a></p>`},
+		{`![x](data:image/svg+xml;base64,PHN2Zz4=)`, `<p><img src="#" alt="x"></p>`},
+		{`[x](/a"onclick="alert(1))`, `<p><a href="/a&#34;onclick=&#34;alert(1)">x</a></p>`},
+		{"```\"><script>\n<b>\n```", `<pre><code class="language-&#34;&gt;&lt;script&gt;">&lt;b&gt;</code></pre>`},
+	}
+	for _, test := range tests {
+		if got := strings.TrimSpace(string(Render([]byte(test.src)))); got != test.want {
+			t.Errorf("Render(%q)\n got %q\nwant %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	r := &Renderer{Highlight: func(code, lang string) (string, bool) {
+		if lang != "go" {
+			return "", false
+		}
+		return `<span class="kw">` + code + `</span>`, true
+	}}
+	if got := string(r.Render([]byte("```go\nfunc\n```\n```\n<x>\n```"))); got != "<pre><code class=\"language-go\"><span class=\"kw\">func</span></code></pre>\n<pre><code>&lt;x&gt;</code></pre>\n" {
+		t.Errorf("unexpected highlight %q", got)
+	}
+}
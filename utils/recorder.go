@@ -0,0 +1,86 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ResponseRecorder writes the response through and keeps a copy of it,
+// for the filters caching or checking the responses of the handlers.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	// Status is the status written, 200 once the body is written without one.
+	Status int
+	// Body is the copy of the body, empty when it was over Max.
+	Body bytes.Buffer
+	// Max is the largest body copied in bytes, 0 is no limit.
+	Max int
+	// Overflow is set when the body was over Max or the connection was
+	// hijacked, the copy isn't the response then.
+	Overflow bool
+}
+
+// NewResponseRecorder returns a recorder of the response written to w,
+// copying up to max bytes of the body.
+func NewResponseRecorder(w http.ResponseWriter, max int) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w, Max: max}
+}
+
+func (r *ResponseRecorder) WriteHeader(code int) {
+	if r.Status == 0 {
+		r.Status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *ResponseRecorder) Write(b []byte) (int, error) {
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	if !r.Overflow {
+		if r.Max > 0 && r.Body.Len()+n > r.Max {
+			r.Overflow = true
+			r.Body.Reset()
+		} else {
+			r.Body.Write(b[:n])
+		}
+	}
+	return n, err
+}
+
+// Flush sends the response written so far, for the streamed responses.
+func (r *ResponseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection to the caller, for the websocket upgrades.
+// the response isn't recorded anymore.
+func (r *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("webserver doesn't support hijacking")
+	}
+	r.Overflow = true
+	r.Body.Reset()
+	return hj.Hijack()
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewResponseRecorder(w, 8)
+	rec.Write([]byte("hello"))
+	if rec.Status != 200 || rec.Body.String() != "hello" || rec.Overflow {
+		t.Errorf("the body should be copied, got %d %q", rec.Status, rec.Body.String())
+	}
+	rec.Write([]byte(" world"))
+	if !rec.Overflow || rec.Body.Len() != 0 || w.Body.String() != "hello world" {
+		t.Errorf("a body over max should overflow and still be written, got %q", w.Body.String())
+	}
+
+	rec = NewResponseRecorder(httptest.NewRecorder(), 0)
+	rec.WriteHeader(201)
+	rec.Write([]byte("created"))
+	if rec.Status != 201 || rec.Body.String() != "created" {
+		t.Errorf("0 should copy the whole body, got %d %q", rec.Status, rec.Body.String())
+	}
+}
+
+func TestResponseRecorderStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewResponseRecorder(w, 0)
+	rec.Write([]byte("data: 1\n\n"))
+	rec.Flush()
+	if !w.Flushed {
+		t.Error("Flush should reach the response writer")
+	}
+
+	hijacked := make(chan bool, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := NewResponseRecorder(w, 0)
+		rec.Write([]byte("partial"))
+		conn, _, err := rec.Hijack()
+		if err != nil {
+			hijacked <- false
+			return
+		}
+		conn.Close()
+		hijacked <- rec.Overflow && rec.Body.Len() == 0
+	}))
+	defer ts.Close()
+	http.Get(ts.URL)
+	if !<-hijacked {
+		t.Error("a hijacked response should not be kept")
+	}
+}
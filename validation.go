@@ -0,0 +1,68 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/validation"
+)
+
+// ValidationErrorHandler writes the response of a request failing Controller.Validate,
+// a 422 application/problem+json document by default. replace it for another json shape:
+//
+//	beego.ValidationErrorHandler = func(ctx *context.Context, errs []*validation.ValidationError) {
+//		ctx.Output.SetStatus(400)
+//		ctx.Output.Json(map[string]interface{}{"errors": validation.FieldErrors(errs)}, false, false)
+//	}
+var ValidationErrorHandler = func(ctx *context.Context, errs []*validation.ValidationError) {
+	problem := validation.NewProblem(errs)
+	problem.Instance = ctx.Request.URL.Path
+	body, err := json.Marshal(problem)
+	if err != nil {
+		http.Error(ctx.ResponseWriter, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx.Output.Header("Content-Type", "application/problem+json")
+	ctx.Output.SetStatus(problem.Status)
+	ctx.Output.Body(body)
+}
+
+// Validate validates obj, a struct with valid tags, and stops the request
+// with the response of ValidationErrorHandler when it's invalid.
+// a struct pointer is sanitized before, see validation.Sanitize.
+//
+//	var u User
+//	c.ParseForm(&u)
+//	c.Validate(&u)
+func (c *Controller) Validate(obj interface{}) {
+	if t := reflect.TypeOf(obj); t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		if err := validation.Sanitize(obj); err != nil {
+			panic(err)
+		}
+	}
+	valid := validation.Validation{}
+	ok, err := valid.ValidContext(c.Context(), obj)
+	if err != nil {
+		panic(err)
+	}
+	if !ok {
+		ValidationErrorHandler(c.Ctx, valid.Errors)
+		c.StopRun()
+	}
+}
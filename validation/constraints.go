@@ -0,0 +1,93 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"reflect"
+	"regexp"
+)
+
+// Constraints are the limits a valid tag enforces on a field,
+// for the schemas of the API docs.
+type Constraints struct {
+	Required  bool
+	Minimum   *int
+	Maximum   *int
+	MinLength *int // of strings, or the items of slices
+	MaxLength *int
+	Pattern   string
+	Format    string // email, ipv4 or byte
+}
+
+// the patterns of the functions checking characters, like the json schema patterns.
+var constraintPatterns = map[string]string{
+	"Alpha":        "^[a-zA-Z]*$",
+	"Numeric":      "^[0-9]*$",
+	"AlphaNumeric": "^[0-9a-zA-Z]*$",
+	"AlphaDash":    "^[0-9a-zA-Z_-]*$",
+	"Mobile":       mobilePattern.String(),
+	"Tel":          telPattern.String(),
+	"ZipCode":      zipCodePattern.String(),
+}
+
+var constraintFormats = map[string]string{
+	"Email":  "email",
+	"IP":     "ipv4",
+	"Base64": "byte",
+}
+
+// FieldConstraints returns the constraints of the valid tag of f.
+// the functions without a schema equivalent, like the custom ones, are skipped.
+func FieldConstraints(f reflect.StructField) (Constraints, error) {
+	var c Constraints
+	vfs, err := getValidFuncs(f)
+	if err != nil {
+		return c, err
+	}
+	for _, vf := range vfs {
+		switch vf.Name {
+		case "Required":
+			c.Required = true
+		case "Min":
+			c.Minimum = intParam(vf, 0)
+		case "Max":
+			c.Maximum = intParam(vf, 0)
+		case "Range":
+			c.Minimum, c.Maximum = intParam(vf, 0), intParam(vf, 1)
+		case "MinSize":
+			c.MinLength = intParam(vf, 0)
+		case "MaxSize":
+			c.MaxLength = intParam(vf, 0)
+		case "Length":
+			c.MinLength, c.MaxLength = intParam(vf, 0), intParam(vf, 0)
+		case "Match":
+			if re, ok := vf.Params[0].(*regexp.Regexp); ok {
+				c.Pattern = re.String()
+			}
+		default:
+			if pattern, ok := constraintPatterns[vf.Name]; ok {
+				c.Pattern = pattern
+			} else if format, ok := constraintFormats[vf.Name]; ok {
+				c.Format = format
+			}
+		}
+	}
+	return c, nil
+}
+
+func intParam(vf ValidFunc, i int) *int {
+	n, _ := vf.Params[i].(int)
+	return &n
+}
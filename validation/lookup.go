@@ -0,0 +1,121 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Lookup finds values in the database for the Unique and Exists functions,
+// orm.ValidationLookup adapts an orm.Ormer.
+type Lookup interface {
+	// Existing returns the values found in column of table, formatted by fmt.Sprint.
+	Existing(ctx context.Context, table, column string, values []interface{}) (map[string]bool, error)
+}
+
+var (
+	// DefaultLookup is used by the validations without Lookup.
+	DefaultLookup Lookup
+	// LookupTimeout limits the lookups of a validation, 0 to rely on its context only.
+	LookupTimeout = 5 * time.Second
+)
+
+var identPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// lookupCheck is a Unique or Exists function waiting for its lookup.
+type lookupCheck struct {
+	unique        bool
+	table, column string
+	value         interface{}
+	key           string
+}
+
+func (v *Validation) lookup(unique bool, obj interface{}, column string, key string) *ValidationResult {
+	parts := strings.Split(column, ".")
+	if len(parts) != 2 || !identPattern.MatchString(parts[0]) || !identPattern.MatchString(parts[1]) {
+		panic("validation: " + column + " is not a table.column")
+	}
+	check := lookupCheck{unique, parts[0], parts[1], obj, key}
+	// empty values are left to Required
+	if !(Required{}).IsSatisfied(obj) {
+		return &ValidationResult{Ok: true}
+	}
+	// Valid batches the lookups
+	if v.checks != nil {
+		*v.checks = append(*v.checks, check)
+		return &ValidationResult{Ok: true}
+	}
+	before := len(v.Errors)
+	if err := v.runLookups(context.Background(), []lookupCheck{check}); err != nil {
+		return v.Error("%v", err)
+	}
+	if len(v.Errors) > before {
+		return &ValidationResult{Error: v.Errors[len(v.Errors)-1]}
+	}
+	return &ValidationResult{Ok: true}
+}
+
+// runLookups runs the checks with one lookup per table column.
+func (v *Validation) runLookups(ctx context.Context, checks []lookupCheck) error {
+	lookup := v.Lookup
+	if lookup == nil {
+		lookup = DefaultLookup
+	}
+	if lookup == nil {
+		return fmt.Errorf("validation: Unique and Exists need a Lookup")
+	}
+	if LookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, LookupTimeout)
+		defer cancel()
+	}
+
+	var columns []string
+	values := make(map[string][]interface{})
+	seen := make(map[string]bool)
+	for _, c := range checks {
+		column := c.table + "." + c.column
+		if _, ok := values[column]; !ok {
+			columns = append(columns, column)
+		}
+		if s := column + "\x00" + fmt.Sprint(c.value); !seen[s] {
+			seen[s] = true
+			values[column] = append(values[column], c.value)
+		}
+	}
+	found := make(map[string]map[string]bool)
+	for _, column := range columns {
+		parts := strings.Split(column, ".")
+		existing, err := lookup.Existing(ctx, parts[0], parts[1], values[column])
+		if err != nil {
+			return fmt.Errorf("validation: lookup of %s: %v", column, err)
+		}
+		found[column] = existing
+	}
+	for _, c := range checks {
+		column := c.table + "." + c.column
+		exists := found[column][fmt.Sprint(c.value)]
+		if c.unique {
+			v.apply(Unique{column, exists, c.key}, c.value)
+		} else {
+			v.apply(Exists{column, exists, c.key}, c.value)
+		}
+	}
+	return nil
+}
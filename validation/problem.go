@@ -0,0 +1,97 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// Problem is an RFC 7807 problem details document of validation errors,
+// served as application/problem+json.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors"`
+}
+
+// FieldError is a validation error of Problem.
+type FieldError struct {
+	Field   string      `json:"field"`          // the field path, like Items[2].Price
+	Rule    string      `json:"rule,omitempty"` // the validation function, like Min
+	Message string      `json:"message"`
+	Param   interface{} `json:"param,omitempty"` // the limit of the rule, like 1 for Min(1)
+}
+
+// NewProblem returns the 422 Problem of errs.
+func NewProblem(errs []*ValidationError) *Problem {
+	p := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusUnprocessableEntity),
+		Status: http.StatusUnprocessableEntity,
+		Errors: FieldErrors(errs),
+	}
+	if len(errs) == 1 {
+		p.Detail = "1 validation error"
+	} else {
+		p.Detail = fmt.Sprintf("%d validation errors", len(errs))
+	}
+	return p
+}
+
+// FieldErrors converts errs to FieldErrors, for the json shapes other than Problem.
+func FieldErrors(errs []*ValidationError) []FieldError {
+	fields := make([]FieldError, len(errs))
+	for i, e := range errs {
+		fields[i] = FieldError{Field: e.Field, Rule: e.Name, Message: e.Message, Param: problemParam(e.LimitValue)}
+		// the errors added with SetError or an unqualified key
+		if e.Field == "" {
+			fields[i].Field, fields[i].Rule = e.Key, ""
+		}
+	}
+	return fields
+}
+
+// problemParam returns the limit v of a rule as a param, the scalars and the
+// slices of scalars as they are, the Stringers like *regexp.Regexp as their
+// string, and nil for the other values, they may hold some data not meant
+// for the clients.
+func problemParam(v interface{}) interface{} {
+	if v == nil || isScalar(reflect.TypeOf(v)) {
+		return v
+	}
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	if t := reflect.TypeOf(v); (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && isScalar(t.Elem()) {
+		return v
+	}
+	return nil
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	SANITIZETAG = "sanitize"
+)
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// the sanitizers by name
+var sanitizers = map[string]func(string) string{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"strip_tags": func(s string) string {
+		return tagPattern.ReplaceAllString(s, "")
+	},
+	"normalize_email": func(s string) string {
+		return strings.ToLower(strings.TrimSpace(s))
+	},
+}
+
+// AddSanitizer registers a sanitizer usable in the sanitize tags by its name.
+// the sanitizers are registered before sanitizing, usually in init.
+func AddSanitizer(name string, fn func(string) string) {
+	sanitizers[name] = fn
+}
+
+// Sanitize rewrites the string fields of obj, a struct pointer, with the sanitizers
+// of their sanitize tag, in order, usually before validating obj:
+//
+//	type User struct {
+//		Name  string `sanitize:"trim;strip_tags" valid:"Required"`
+//		Email string `sanitize:"normalize_email" valid:"Email"`
+//	}
+//
+// the builtin sanitizers are trim, lower, upper, strip_tags and normalize_email.
+// the tags apply to string, *string and []string fields, and the nested structs
+// are sanitized like Valid validates them.
+func Sanitize(obj interface{}) error {
+	objV := reflect.ValueOf(obj)
+	if !isStructPtr(objV.Type()) || objV.IsNil() {
+		return fmt.Errorf("%v must be a struct pointer", obj)
+	}
+	return sanitizeStruct(objV.Elem(), make(map[uintptr]bool))
+}
+
+func sanitizeStruct(objV reflect.Value, seen map[uintptr]bool) error {
+	objT := objV.Type()
+	for i := 0; i < objT.NumField(); i++ {
+		f := objT.Field(i)
+		field := objV.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		if tag := f.Tag.Get(SANITIZETAG); tag != "" {
+			fns, err := sanitizersOf(tag)
+			if err != nil {
+				return fmt.Errorf("%s: %v", f.Name, err)
+			}
+			sanitizeValue(field, fns)
+			continue
+		}
+		if err := sanitizeNested(field, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sanitizersOf(tag string) ([]func(string) string, error) {
+	var fns []func(string) string
+	for _, name := range strings.Split(tag, ";") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		fn, ok := sanitizers[name]
+		if !ok {
+			return nil, fmt.Errorf("doesn't exsits %s sanitizer", name)
+		}
+		fns = append(fns, fn)
+	}
+	return fns, nil
+}
+
+// sanitizeValue applies fns to a string, a pointer to one or their slice.
+func sanitizeValue(value reflect.Value, fns []func(string) string) {
+	switch value.Kind() {
+	case reflect.String:
+		if value.CanSet() {
+			s := value.String()
+			for _, fn := range fns {
+				s = fn(s)
+			}
+			value.SetString(s)
+		}
+	case reflect.Ptr:
+		if !value.IsNil() {
+			sanitizeValue(value.Elem(), fns)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			sanitizeValue(value.Index(i), fns)
+		}
+	}
+}
+
+// sanitizeNested sanitizes the structs in value: a struct, a pointer to one,
+// or a slice, array or map of them.
+func sanitizeNested(value reflect.Value, seen map[uintptr]bool) error {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() || seen[value.Pointer()] {
+			return nil
+		}
+		seen[value.Pointer()] = true
+		return sanitizeNested(value.Elem(), seen)
+	case reflect.Struct:
+		if value.CanAddr() {
+			return sanitizeStruct(value, seen)
+		}
+	case reflect.Slice, reflect.Array:
+		if !hasStructs(value.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := sanitizeNested(value.Index(i), seen); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !hasStructs(value.Type().Elem()) {
+			return nil
+		}
+		for _, k := range value.MapKeys() {
+			// the map values are copied to be sanitized
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(value.MapIndex(k))
+			if err := sanitizeNested(elem, seen); err != nil {
+				return err
+			}
+			value.SetMapIndex(k, elem)
+		}
+	}
+	return nil
+}
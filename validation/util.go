@@ -33,14 +33,16 @@ var (
 
 	// doesn't belong to validation functions
 	unFuncs = map[string]bool{
-		"Clear":     true,
-		"HasErrors": true,
-		"ErrorMap":  true,
-		"Error":     true,
-		"apply":     true,
-		"Check":     true,
-		"Valid":     true,
-		"NoMatch":   true,
+		"Clear":        true,
+		"HasErrors":    true,
+		"ErrorMap":     true,
+		"Error":        true,
+		"AddError":     true,
+		"apply":        true,
+		"Check":        true,
+		"Valid":        true,
+		"ValidContext": true,
+		"NoMatch":      true,
 	}
 )
 
@@ -55,6 +57,59 @@ func init() {
 	}
 }
 
+// CustomFunc is a custom validation function without parameters,
+// it reports the failures with v.AddError(key, message).
+type CustomFunc func(v *Validation, obj interface{}, key string)
+
+// AddCustomFunc registers a validation function usable in the valid tags by its name.
+// fn is a CustomFunc, or a func taking parameters between obj and key,
+// of the types int, float64, bool, string or *regexp.Regexp:
+//
+//	validation.AddCustomFunc("iban", func(v *validation.Validation, obj interface{}, key string) {
+//		if s, _ := obj.(string); !ibanPattern.MatchString(s) {
+//			v.AddError(key, "Must be a valid IBAN")
+//		}
+//	})
+//	validation.AddCustomFunc("Divisible", func(v *validation.Validation, obj interface{}, n int, key string) {
+//		...
+//	})
+//
+//	type Payment struct {
+//		Account string `valid:"Required;iban"`
+//		Amount  int    `valid:"Divisible(100)"`
+//	}
+//
+// the functions are registered before validating, usually in init.
+func AddCustomFunc(name string, fn interface{}) error {
+	if _, ok := reflect.TypeOf(&Validation{}).MethodByName(name); ok || name == "" {
+		return fmt.Errorf("validation: %q can not be used as a custom function name", name)
+	}
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() < 3 || t.NumOut() != 0 ||
+		t.In(0) != reflect.TypeOf(&Validation{}) || t.In(1).Kind() != reflect.Interface ||
+		t.In(t.NumIn()-1).Kind() != reflect.String {
+		return fmt.Errorf("validation: %s must be a func(*Validation, interface{}, [params...,] key string)", name)
+	}
+	for i := 2; i < t.NumIn()-1; i++ {
+		if _, err := magic(t.In(i), zeroParam(t.In(i))); err != nil {
+			return fmt.Errorf("validation: %s parameter %d %v", name, i-1, err)
+		}
+	}
+	funcs[name] = reflect.ValueOf(fn)
+	return nil
+}
+
+// zeroParam returns a tag parameter parsable as a t.
+func zeroParam(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Float64:
+		return "0"
+	case reflect.Bool:
+		return "false"
+	}
+	return ""
+}
+
 // Valid function type
 type ValidFunc struct {
 	Name   string
@@ -227,6 +282,10 @@ func magic(t reflect.Type, s string) (i interface{}, err error) {
 	switch t.Kind() {
 	case reflect.Int:
 		i, err = strconv.Atoi(s)
+	case reflect.Float64:
+		i, err = strconv.ParseFloat(s, 64)
+	case reflect.Bool:
+		i, err = strconv.ParseBool(s)
 	case reflect.String:
 		i = s
 	case reflect.Ptr:
@@ -241,6 +300,19 @@ func magic(t reflect.Type, s string) (i interface{}, err error) {
 	return
 }
 
+// prefixKey returns the params of a validation function with the key,
+// their last param, prefixed by the path of the nested struct.
+func prefixKey(params []interface{}, prefix string) []interface{} {
+	if prefix == "" || len(params) == 0 {
+		return params
+	}
+	prefixed := append([]interface{}{}, params...)
+	if key, ok := prefixed[len(prefixed)-1].(string); ok {
+		prefixed[len(prefixed)-1] = prefix + key
+	}
+	return prefixed
+}
+
 func mergeParam(v *Validation, obj interface{}, params []interface{}) []interface{} {
 	return append([]interface{}{v, obj}, params...)
 }
@@ -47,9 +47,11 @@
 package validation
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -102,6 +104,14 @@ func (r *ValidationResult) Message(message string, args ...interface{}) *Validat
 type Validation struct {
 	Errors    []*ValidationError
 	ErrorsMap map[string]*ValidationError
+
+	// Lookup finds the values of the Unique and Exists functions, DefaultLookup if nil
+	Lookup Lookup
+
+	// the struct whose fields are validated, for the cross-field functions
+	parent reflect.Value
+	// the lookups batched by Valid
+	checks *[]lookupCheck
 }
 
 // Clean all ValidationError.
@@ -232,6 +242,86 @@ func (v *Validation) ZipCode(obj interface{}, key string) *ValidationResult {
 	return v.apply(ZipCode{Match{Regexp: zipCodePattern}, key}, obj)
 }
 
+// Test that the obj is equal to the field of the struct validated, for valid tags like EqField(Password)
+func (v *Validation) EqField(obj interface{}, field string, key string) *ValidationResult {
+	return v.apply(FieldCompare{"EqField", field, v.field(field), key}, obj)
+}
+
+// Test that the obj is not equal to the field of the struct validated
+func (v *Validation) NeField(obj interface{}, field string, key string) *ValidationResult {
+	return v.apply(FieldCompare{"NeField", field, v.field(field), key}, obj)
+}
+
+// Test that the obj is greater than the field of the struct validated, numbers, strings or times
+func (v *Validation) GtField(obj interface{}, field string, key string) *ValidationResult {
+	return v.apply(FieldCompare{"GtField", field, v.field(field), key}, obj)
+}
+
+// Test that the obj is greater than or equal to the field of the struct validated
+func (v *Validation) GteField(obj interface{}, field string, key string) *ValidationResult {
+	return v.apply(FieldCompare{"GteField", field, v.field(field), key}, obj)
+}
+
+// Test that the obj is less than the field of the struct validated
+func (v *Validation) LtField(obj interface{}, field string, key string) *ValidationResult {
+	return v.apply(FieldCompare{"LtField", field, v.field(field), key}, obj)
+}
+
+// Test that the obj is less than or equal to the field of the struct validated
+func (v *Validation) LteField(obj interface{}, field string, key string) *ValidationResult {
+	return v.apply(FieldCompare{"LteField", field, v.field(field), key}, obj)
+}
+
+// Test that the obj is non-empty when the condition on a field of the struct validated holds:
+// RequiredIf(Type=card) when Type is card, RequiredIf(Coupon) when Coupon is non-empty
+func (v *Validation) RequiredIf(obj interface{}, cond string, key string) *ValidationResult {
+	return v.apply(RequiredIf{cond, v.holds(cond), key}, obj)
+}
+
+// Test that the obj is non-empty unless the condition on a field of the struct validated holds
+func (v *Validation) RequiredUnless(obj interface{}, cond string, key string) *ValidationResult {
+	return v.apply(RequiredUnless{cond, v.holds(cond), key}, obj)
+}
+
+// field returns the value of a field of the struct validated.
+func (v *Validation) field(name string) interface{} {
+	if !v.parent.IsValid() {
+		panic("validation: " + name + " can only be compared in a struct validated by Valid")
+	}
+	f := v.parent.FieldByName(name)
+	if !f.IsValid() {
+		panic(fmt.Sprintf("validation: %s has no field %s", v.parent.Type(), name))
+	}
+	return f.Interface()
+}
+
+// holds returns whether cond, Field=value or Field for a non-empty field, holds.
+// the value may list alternatives separated by |, like Type=card|paypal.
+func (v *Validation) holds(cond string) bool {
+	i := strings.Index(cond, "=")
+	if i < 0 {
+		return Required{}.IsSatisfied(v.field(strings.TrimSpace(cond)))
+	}
+	value := fmt.Sprint(v.field(strings.TrimSpace(cond[:i])))
+	for _, want := range strings.Split(cond[i+1:], "|") {
+		if value == strings.TrimSpace(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// Test that no row has the obj in a table column, for valid tags like Unique(users.email).
+// the lookups of Valid are batched, one per column, see Lookup.
+func (v *Validation) Unique(obj interface{}, column string, key string) *ValidationResult {
+	return v.lookup(true, obj, column, key)
+}
+
+// Test that a row has the obj in a table column, for valid tags like Exists(countries.code)
+func (v *Validation) Exists(obj interface{}, column string, key string) *ValidationResult {
+	return v.lookup(false, obj, column, key)
+}
+
 func (v *Validation) apply(chk Validator, obj interface{}) *ValidationResult {
 	if chk.IsSatisfied(obj) {
 		return &ValidationResult{Ok: true}
@@ -239,14 +329,7 @@ func (v *Validation) apply(chk Validator, obj interface{}) *ValidationResult {
 
 	// Add the error to the validation context.
 	key := chk.GetKey()
-	Name := key
-	Field := ""
-
-	parts := strings.Split(key, ".")
-	if len(parts) == 2 {
-		Field = parts[0]
-		Name = parts[1]
-	}
+	Field, Name := splitKey(key)
 
 	err := &ValidationError{
 		Message:    chk.DefaultMessage(),
@@ -266,6 +349,15 @@ func (v *Validation) apply(chk Validator, obj interface{}) *ValidationResult {
 	}
 }
 
+// splitKey splits the key of a validation function, like Items[2].Price.Min,
+// into the field path and the function name.
+func splitKey(key string) (field, name string) {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
 func (v *Validation) setError(err *ValidationError) {
 	v.Errors = append(v.Errors, err)
 	if v.ErrorsMap == nil {
@@ -276,6 +368,15 @@ func (v *Validation) setError(err *ValidationError) {
 	}
 }
 
+// AddError adds an error with the key of a validation function, like Name.iban,
+// for the custom functions.
+func (v *Validation) AddError(key, message string) *ValidationError {
+	Field, Name := splitKey(key)
+	err := &ValidationError{Message: message, Key: key, Name: Name, Field: Field, Tmpl: message}
+	v.setError(err)
+	return err
+}
+
 // Set error message for one field in ValidationError
 func (v *Validation) SetError(fieldName string, errMsg string) *ValidationError {
 	err := &ValidationError{Key: fieldName, Field: fieldName, Tmpl: errMsg, Message: errMsg}
@@ -298,8 +399,17 @@ func (v *Validation) Check(obj interface{}, checks ...Validator) *ValidationResu
 }
 
 // Validate a struct.
-// the obj parameter must be a struct or a struct pointer
+// the obj parameter must be a struct or a struct pointer.
+// the nested structs, and the structs in slices, arrays and map values are
+// validated too, their error keys are paths like Items[2].Price.Min.
+// a field tagged valid:"-" is skipped.
 func (v *Validation) Valid(obj interface{}) (b bool, err error) {
+	return v.ValidContext(context.Background(), obj)
+}
+
+// ValidContext is Valid with the context of the database lookups of the Unique
+// and Exists functions, they're run after the other functions.
+func (v *Validation) ValidContext(ctx context.Context, obj interface{}) (b bool, err error) {
 	objT := reflect.TypeOf(obj)
 	objV := reflect.ValueOf(obj)
 	switch {
@@ -312,17 +422,17 @@ func (v *Validation) Valid(obj interface{}) (b bool, err error) {
 		return
 	}
 
-	for i := 0; i < objT.NumField(); i++ {
-		var vfs []ValidFunc
-		if vfs, err = getValidFuncs(objT.Field(i)); err != nil {
+	var checks []lookupCheck
+	v.checks = &checks
+	err = v.validStruct(objV, "", make(map[uintptr]bool))
+	v.checks = nil
+	if err != nil {
+		return
+	}
+	if len(checks) > 0 {
+		if err = v.runLookups(ctx, checks); err != nil {
 			return
 		}
-		for _, vf := range vfs {
-			if _, err = funcs.Call(vf.Name,
-				mergeParam(v, objV.Field(i).Interface(), vf.Params)...); err != nil {
-				return
-			}
-		}
 	}
 
 	if !v.HasErrors() {
@@ -333,3 +443,99 @@ func (v *Validation) Valid(obj interface{}) (b bool, err error) {
 
 	return !v.HasErrors(), nil
 }
+
+// validStruct validates the fields of the struct objV with their keys
+// prefixed by prefix, and the structs nested in them.
+func (v *Validation) validStruct(objV reflect.Value, prefix string, seen map[uintptr]bool) error {
+	defer func(parent reflect.Value) { v.parent = parent }(v.parent)
+	v.parent = objV
+	objT := objV.Type()
+	for i := 0; i < objT.NumField(); i++ {
+		f := objT.Field(i)
+		if f.Tag.Get(VALIDTAG) == "-" {
+			continue
+		}
+		vfs, err := getValidFuncs(f)
+		if err != nil {
+			return err
+		}
+		for _, vf := range vfs {
+			if _, err = funcs.Call(vf.Name,
+				mergeParam(v, objV.Field(i).Interface(), prefixKey(vf.Params, prefix))...); err != nil {
+				return err
+			}
+		}
+		field := objV.Field(i)
+		// the fields of embedded structs are promoted
+		if f.Anonymous {
+			for field.Kind() == reflect.Ptr && !field.IsNil() {
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Struct {
+				if err = v.validStruct(field, prefix, seen); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		// unexported fields can't be read
+		if f.PkgPath != "" {
+			continue
+		}
+		if err = v.validNested(field, prefix+f.Name, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validNested validates the structs in value: a struct, a pointer to one,
+// or a slice, array or map of them, as path.
+func (v *Validation) validNested(value reflect.Value, path string, seen map[uintptr]bool) error {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() || seen[value.Pointer()] {
+			return nil
+		}
+		seen[value.Pointer()] = true
+		return v.validNested(value.Elem(), path, seen)
+	case reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return v.validNested(value.Elem(), path, seen)
+	case reflect.Struct:
+		return v.validStruct(value, path+".", seen)
+	case reflect.Slice, reflect.Array:
+		if !hasStructs(value.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := v.validNested(value.Index(i), fmt.Sprintf("%s[%d]", path, i), seen); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !hasStructs(value.Type().Elem()) {
+			return nil
+		}
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			if err := v.validNested(value.MapIndex(k), fmt.Sprintf("%s[%v]", path, k.Interface()), seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasStructs returns whether the values of type t may hold structs.
+func hasStructs(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Interface
+}
@@ -15,7 +15,10 @@
 package validation
 
 import (
+	"context"
+	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -343,3 +346,324 @@ func TestValid(t *testing.T) {
 		t.Errorf("Message key should be `Name.Match` but got %s", valid.Errors[0].Key)
 	}
 }
+
+func TestAddCustomFunc(t *testing.T) {
+	err := AddCustomFunc("iban", CustomFunc(func(v *Validation, obj interface{}, key string) {
+		if s, _ := obj.(string); !strings.HasPrefix(s, "DE") || len(s) != 22 {
+			v.AddError(key, "Must be a valid IBAN")
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddCustomFunc("Divisible", func(v *Validation, obj interface{}, n int, key string) {
+		if i, _ := obj.(int); i%n != 0 {
+			v.AddError(key, fmt.Sprintf("Must be divisible by %d", n))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if AddCustomFunc("Required", CustomFunc(func(*Validation, interface{}, string) {})) == nil {
+		t.Error("a builtin function should not be replaced")
+	}
+	if AddCustomFunc("bad", func(v *Validation, obj interface{}) {}) == nil {
+		t.Error("a func without key should be refused")
+	}
+	if AddCustomFunc("bad", func(v *Validation, obj interface{}, m map[string]int, key string) {}) == nil {
+		t.Error("a func with an unsupported parameter should be refused")
+	}
+
+	type payment struct {
+		Account string `valid:"Required;iban"`
+		Amount  int    `valid:"Divisible(100)"`
+	}
+	valid := Validation{}
+	b, err := valid.Valid(payment{Account: "DE89370400440532013000", Amount: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b {
+		t.Error("validation should be passed")
+	}
+
+	valid.Clear()
+	if b, err = valid.Valid(payment{Account: "FR76", Amount: 250}); err != nil {
+		t.Fatal(err)
+	}
+	if b || len(valid.Errors) != 2 {
+		t.Fatalf("validation should have 2 errors but got %d", len(valid.Errors))
+	}
+	if e := valid.Errors[0]; e.Key != "Account.iban" || e.Field != "Account" || e.Message != "Must be a valid IBAN" {
+		t.Errorf("unexpected iban error %+v", e)
+	}
+	if e := valid.Errors[1]; e.Key != "Amount.Divisible" || e.Message != "Must be divisible by 100" {
+		t.Errorf("unexpected divisible error %+v", e)
+	}
+}
+
+func TestValidNested(t *testing.T) {
+	type item struct {
+		Name  string `valid:"Required"`
+		Price int    `valid:"Min(1)"`
+	}
+	type address struct {
+		City string `valid:"Required"`
+	}
+	type base struct {
+		Id int `valid:"Min(1)"`
+	}
+	type order struct {
+		base
+		Address  address
+		Billing  *address
+		Items    []item
+		Refs     []*item
+		Gifts    map[string]item
+		Skipped  item `valid:"-"`
+		Contacts []string
+	}
+
+	o := order{
+		base:    base{Id: 1},
+		Address: address{City: "Paris"},
+		Items:   []item{{"pen", 2}, {"ink", 3}, {"", 0}},
+		Refs:    []*item{nil, {"cap", 1}},
+		Gifts:   map[string]item{"card": {"card", 0}},
+	}
+	valid := Validation{}
+	b, err := valid.Valid(&o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b {
+		t.Error("validation should not be passed")
+	}
+	var keys []string
+	for _, e := range valid.Errors {
+		keys = append(keys, e.Key)
+	}
+	want := "Items[2].Name.Required Items[2].Price.Min Gifts[card].Price.Min"
+	if strings.Join(keys, " ") != want {
+		t.Errorf("error keys should be %s but got %s", want, strings.Join(keys, " "))
+	}
+	if e := valid.ErrorsMap["Items[2].Price"]; e == nil || e.Name != "Min" {
+		t.Errorf("errors should be mapped by field path, got %v", valid.ErrorsMap)
+	}
+
+	valid.Clear()
+	o = order{Billing: &address{}}
+	if b, err = valid.Valid(o); err != nil {
+		t.Fatal(err)
+	}
+	if b || len(valid.Errors) != 3 || valid.Errors[0].Key != "Id.Min" || valid.Errors[2].Key != "Billing.City.Required" {
+		t.Errorf("embedded and pointed structs should be validated, got %d errors", len(valid.Errors))
+	}
+}
+
+func TestCrossField(t *testing.T) {
+	type signup struct {
+		Password string
+		Confirm  string `valid:"EqField(Password)"`
+		Login    string `valid:"NeField(Password)"`
+		Start    time.Time
+		End      time.Time `valid:"GtField(Start)"`
+		Min      int
+		Max      int     `valid:"GteField(Min)"`
+		Discount float64 `valid:"LtField(Max)"`
+		Type     string
+		Card     string `valid:"RequiredIf(Type=card|debit)"`
+		Coupon   string
+		Code     string `valid:"RequiredIf(Coupon)"`
+		Email    string `valid:"RequiredUnless(Type=invoice)"`
+	}
+	now := time.Now()
+	s := signup{Password: "secret", Confirm: "secret", Login: "bee", Start: now, End: now.Add(time.Hour),
+		Min: 2, Max: 2, Discount: 1.5, Type: "invoice"}
+	valid := Validation{}
+	b, err := valid.Valid(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b {
+		t.Errorf("validation should be passed, got %v", valid.Errors[0].Key)
+	}
+
+	s = signup{Password: "secret", Confirm: "secrets", Login: "secret", Start: now, End: now,
+		Min: 3, Max: 2, Discount: 2, Type: "debit", Coupon: "BEE"}
+	valid.Clear()
+	if b, err = valid.Valid(&s); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, e := range valid.Errors {
+		keys = append(keys, e.Key)
+	}
+	want := "Confirm.EqField Login.NeField End.GtField Max.GteField Discount.LtField Card.RequiredIf Code.RequiredIf Email.RequiredUnless"
+	if b || strings.Join(keys, " ") != want {
+		t.Errorf("error keys should be %s but got %s", want, strings.Join(keys, " "))
+	}
+	if msg := valid.Errors[0].Message; msg != "Must be equal to Password" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if limit := valid.Errors[0].LimitValue; limit != "Password" {
+		t.Errorf("the limit should be the other field, got %v", limit)
+	}
+
+	type typo struct {
+		Confirm string `valid:"EqField(Pasword)"`
+	}
+	valid.Clear()
+	if _, err = valid.Valid(typo{}); err == nil {
+		t.Error("an unknown field should be an error")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	AddSanitizer("squeeze", func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	})
+	type address struct {
+		City string `sanitize:"trim;upper"`
+	}
+	type profile struct {
+		Name    string   `sanitize:"strip_tags;squeeze"`
+		Email   *string  `sanitize:"normalize_email"`
+		Tags    []string `sanitize:"trim;lower"`
+		Address address
+		Others  []*address
+		ByName  map[string]address
+		Raw     string
+	}
+	email := " Bee@Beego.ME "
+	p := &profile{
+		Name:    " <b>bee</b>  keeper ",
+		Email:   &email,
+		Tags:    []string{" Go ", "WEB"},
+		Address: address{" paris "},
+		Others:  []*address{{" lyon"}, nil},
+		ByName:  map[string]address{"home": {"nice "}},
+		Raw:     " raw ",
+	}
+	if err := Sanitize(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "bee keeper" || email != "bee@beego.me" || strings.Join(p.Tags, ",") != "go,web" {
+		t.Errorf("unexpected sanitized fields %q %q %q", p.Name, email, p.Tags)
+	}
+	if p.Address.City != "PARIS" || p.Others[0].City != "LYON" || p.ByName["home"].City != "NICE" {
+		t.Errorf("nested structs should be sanitized, got %q %q %q", p.Address.City, p.Others[0].City, p.ByName["home"].City)
+	}
+	if p.Raw != " raw " {
+		t.Error("a field without sanitize tag should be kept")
+	}
+
+	type typo struct {
+		Name string `sanitize:"trimm"`
+	}
+	if Sanitize(&typo{}) == nil {
+		t.Error("an unknown sanitizer should be an error")
+	}
+	if Sanitize(profile{}) == nil {
+		t.Error("a struct should be refused, it can't be changed")
+	}
+}
+
+type testLookup struct {
+	rows  map[string][]string
+	calls int
+	delay time.Duration
+}
+
+func (l *testLookup) Existing(ctx context.Context, table, column string, values []interface{}) (map[string]bool, error) {
+	l.calls++
+	select {
+	case <-time.After(l.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	found := make(map[string]bool)
+	for _, row := range l.rows[table+"."+column] {
+		for _, v := range values {
+			if fmt.Sprint(v) == row {
+				found[row] = true
+			}
+		}
+	}
+	return found, nil
+}
+
+func TestLookup(t *testing.T) {
+	type address struct {
+		Country string `valid:"Exists(countries.code)"`
+	}
+	type signup struct {
+		Email     string `valid:"Required;Email;Unique(users.email)"`
+		Login     string `valid:"Unique(users.login)"`
+		Addresses []address
+	}
+	lookup := &testLookup{rows: map[string][]string{
+		"users.email":    {"bee@beego.me"},
+		"countries.code": {"FR", "DE"},
+	}}
+	valid := Validation{Lookup: lookup}
+	b, err := valid.Valid(&signup{Email: "new@beego.me", Login: "bee", Addresses: []address{{"FR"}, {"DE"}, {"FR"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b {
+		t.Errorf("validation should be passed, got %v", valid.Errors[0].Key)
+	}
+	if lookup.calls != 3 {
+		t.Errorf("the lookups should be batched per column, got %d calls", lookup.calls)
+	}
+
+	valid.Clear()
+	b, err = valid.Valid(&signup{Email: "bee@beego.me", Addresses: []address{{"FR"}, {"XX"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b || len(valid.Errors) != 2 || valid.Errors[0].Key != "Email.Unique" || valid.Errors[1].Key != "Addresses[1].Country.Exists" {
+		t.Errorf("unexpected errors %v", valid.Errors)
+	}
+	if valid.Errors[1].Message != "Does not exist" {
+		t.Errorf("unexpected message %q", valid.Errors[1].Message)
+	}
+
+	// the direct calls look up at once
+	valid.Clear()
+	if r := valid.Unique("bee@beego.me", "users.email", "email"); r.Ok {
+		t.Error("a taken email should not be unique")
+	}
+
+	defer func(d time.Duration) { LookupTimeout = d }(LookupTimeout)
+	LookupTimeout = 10 * time.Millisecond
+	lookup.delay = time.Second
+	valid.Clear()
+	if _, err = valid.Valid(&signup{Email: "new@beego.me"}); err == nil {
+		t.Error("a lookup should time out")
+	}
+
+	valid = Validation{}
+	if _, err = valid.Valid(&signup{Email: "new@beego.me"}); err == nil {
+		t.Error("a validation without lookup should fail")
+	}
+}
+
+func TestFieldErrorsParam(t *testing.T) {
+	errs := []*ValidationError{
+		{Key: "Code.Match", Field: "Code", Name: "Match", LimitValue: regexp.MustCompile(`^\d+$`)},
+		{Key: "Age.Range", Field: "Age", Name: "Range", LimitValue: []int{18, 140}},
+		{Key: "Name.custom", Field: "Name", Name: "custom", LimitValue: struct{ Secret string }{"s3cret"}},
+	}
+	fields := FieldErrors(errs)
+	if fields[0].Param != `^\d+$` {
+		t.Errorf("a regexp limit should be its string, got %#v", fields[0].Param)
+	}
+	if r, ok := fields[1].Param.([]int); !ok || len(r) != 2 {
+		t.Errorf("a slice of numbers should be kept, got %#v", fields[1].Param)
+	}
+	if fields[2].Param != nil {
+		t.Errorf("a struct limit should be dropped, got %#v", fields[2].Param)
+	}
+}
@@ -18,31 +18,42 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 	"time"
 	"unicode/utf8"
 )
 
 var MessageTmpls = map[string]string{
-	"Required":     "Can not be empty",
-	"Min":          "Minimum is %d",
-	"Max":          "Maximum is %d",
-	"Range":        "Range is %d to %d",
-	"MinSize":      "Minimum size is %d",
-	"MaxSize":      "Maximum size is %d",
-	"Length":       "Required length is %d",
-	"Alpha":        "Must be valid alpha characters",
-	"Numeric":      "Must be valid numeric characters",
-	"AlphaNumeric": "Must be valid alpha or numeric characters",
-	"Match":        "Must match %s",
-	"NoMatch":      "Must not match %s",
-	"AlphaDash":    "Must be valid alpha or numeric or dash(-_) characters",
-	"Email":        "Must be a valid email address",
-	"IP":           "Must be a valid ip address",
-	"Base64":       "Must be valid base64 characters",
-	"Mobile":       "Must be valid mobile number",
-	"Tel":          "Must be valid telephone number",
-	"Phone":        "Must be valid telephone or mobile phone number",
-	"ZipCode":      "Must be valid zipcode",
+	"Required":       "Can not be empty",
+	"Min":            "Minimum is %d",
+	"Max":            "Maximum is %d",
+	"Range":          "Range is %d to %d",
+	"MinSize":        "Minimum size is %d",
+	"MaxSize":        "Maximum size is %d",
+	"Length":         "Required length is %d",
+	"Alpha":          "Must be valid alpha characters",
+	"Numeric":        "Must be valid numeric characters",
+	"AlphaNumeric":   "Must be valid alpha or numeric characters",
+	"Match":          "Must match %s",
+	"NoMatch":        "Must not match %s",
+	"AlphaDash":      "Must be valid alpha or numeric or dash(-_) characters",
+	"Email":          "Must be a valid email address",
+	"IP":             "Must be a valid ip address",
+	"Base64":         "Must be valid base64 characters",
+	"Mobile":         "Must be valid mobile number",
+	"Tel":            "Must be valid telephone number",
+	"Phone":          "Must be valid telephone or mobile phone number",
+	"ZipCode":        "Must be valid zipcode",
+	"EqField":        "Must be equal to %s",
+	"NeField":        "Must not be equal to %s",
+	"GtField":        "Must be greater than %s",
+	"GteField":       "Must be greater than or equal to %s",
+	"LtField":        "Must be less than %s",
+	"LteField":       "Must be less than or equal to %s",
+	"RequiredIf":     "Can not be empty when %s",
+	"RequiredUnless": "Can not be empty unless %s",
+	"Unique":         "Is already taken",
+	"Exists":         "Does not exist",
 }
 
 type Validator interface {
@@ -538,3 +549,206 @@ func (z ZipCode) GetKey() string {
 func (z ZipCode) GetLimitValue() interface{} {
 	return nil
 }
+
+// compares the obj with the value of another field of the struct,
+// Name is EqField, NeField, GtField, GteField, LtField or LteField
+type FieldCompare struct {
+	Name  string
+	Field string
+	Other interface{}
+	Key   string
+}
+
+func (f FieldCompare) IsSatisfied(obj interface{}) bool {
+	if f.Name == "EqField" || f.Name == "NeField" {
+		equal := reflect.DeepEqual(obj, f.Other)
+		if c, ok := compare(obj, f.Other); ok {
+			equal = c == 0
+		}
+		return equal == (f.Name == "EqField")
+	}
+	c, ok := compare(obj, f.Other)
+	if !ok {
+		return false
+	}
+	switch f.Name {
+	case "GtField":
+		return c > 0
+	case "GteField":
+		return c >= 0
+	case "LtField":
+		return c < 0
+	case "LteField":
+		return c <= 0
+	}
+	return false
+}
+
+func (f FieldCompare) DefaultMessage() string {
+	return fmt.Sprintf(MessageTmpls[f.Name], f.Field)
+}
+
+func (f FieldCompare) GetKey() string {
+	return f.Key
+}
+
+// GetLimitValue returns the name of the other field, its value may be a
+// secret like a password.
+func (f FieldCompare) GetLimitValue() interface{} {
+	return f.Field
+}
+
+// compare returns -1, 0 or 1 comparing the numbers, strings or times a and b,
+// and false when they can't be compared.
+func compare(a, b interface{}) (int, bool) {
+	if t, ok := a.(time.Time); ok {
+		u, ok := b.(time.Time)
+		switch {
+		case !ok:
+			return 0, false
+		case t.Before(u):
+			return -1, true
+		case t.After(u):
+			return 1, true
+		}
+		return 0, true
+	}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isInt(av) && isInt(bv):
+		return sign(av.Int() > bv.Int(), av.Int() < bv.Int()), true
+	case isNumber(av) && isNumber(bv):
+		return sign(toFloat(av) > toFloat(bv), toFloat(av) < toFloat(bv)), true
+	case av.Kind() == reflect.String && bv.Kind() == reflect.String:
+		return strings.Compare(av.String(), bv.String()), true
+	}
+	return 0, false
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return isInt(v)
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return float64(v.Int())
+}
+
+func sign(greater, less bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// requires the obj when Active, Cond describes the condition
+type RequiredIf struct {
+	Cond   string
+	Active bool
+	Key    string
+}
+
+func (r RequiredIf) IsSatisfied(obj interface{}) bool {
+	return !r.Active || Required{}.IsSatisfied(obj)
+}
+
+func (r RequiredIf) DefaultMessage() string {
+	return fmt.Sprintf(MessageTmpls["RequiredIf"], r.Cond)
+}
+
+func (r RequiredIf) GetKey() string {
+	return r.Key
+}
+
+func (r RequiredIf) GetLimitValue() interface{} {
+	return r.Cond
+}
+
+// requires the obj when not Active, Cond describes the condition
+type RequiredUnless struct {
+	Cond   string
+	Active bool
+	Key    string
+}
+
+func (r RequiredUnless) IsSatisfied(obj interface{}) bool {
+	return r.Active || Required{}.IsSatisfied(obj)
+}
+
+func (r RequiredUnless) DefaultMessage() string {
+	return fmt.Sprintf(MessageTmpls["RequiredUnless"], r.Cond)
+}
+
+func (r RequiredUnless) GetKey() string {
+	return r.Key
+}
+
+func (r RequiredUnless) GetLimitValue() interface{} {
+	return r.Cond
+}
+
+// the obj is not in Column, a table.column, Found is the result of its lookup
+type Unique struct {
+	Column string
+	Found  bool
+	Key    string
+}
+
+func (u Unique) IsSatisfied(obj interface{}) bool {
+	return !u.Found
+}
+
+func (u Unique) DefaultMessage() string {
+	return fmt.Sprint(MessageTmpls["Unique"])
+}
+
+func (u Unique) GetKey() string {
+	return u.Key
+}
+
+func (u Unique) GetLimitValue() interface{} {
+	return u.Column
+}
+
+// the obj is in Column, a table.column, Found is the result of its lookup
+type Exists struct {
+	Column string
+	Found  bool
+	Key    string
+}
+
+func (e Exists) IsSatisfied(obj interface{}) bool {
+	return e.Found
+}
+
+func (e Exists) DefaultMessage() string {
+	return fmt.Sprint(MessageTmpls["Exists"])
+}
+
+func (e Exists) GetKey() string {
+	return e.Key
+}
+
+func (e Exists) GetLimitValue() interface{} {
+	return e.Column
+}
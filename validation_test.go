@@ -0,0 +1,65 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type signupForm struct {
+	Email string `form:"email" sanitize:"normalize_email" valid:"Required;Email"`
+	Age   int    `form:"age" valid:"Range(18, 140)"`
+}
+
+type signupController struct {
+	Controller
+}
+
+func (c *signupController) Post() {
+	var f signupForm
+	c.ParseForm(&f)
+	c.Validate(&f)
+	c.Ctx.WriteString("welcome")
+}
+
+func TestValidate(t *testing.T) {
+	handler := NewControllerRegister()
+	handler.Add("/signup", &signupController{})
+
+	r, _ := http.NewRequest("POST", "/signup", strings.NewReader("email=bee&age=12"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 422 || w.HeaderMap.Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("an invalid form should get a 422 problem, got %d %s", w.Code, w.HeaderMap.Get("Content-Type"))
+	}
+	want := `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"2 validation errors","instance":"/signup",` +
+		`"errors":[{"field":"Email","rule":"Email","message":"Must be a valid email address"},` +
+		`{"field":"Age","rule":"Range","message":"Range is 18 to 140","param":[18,140]}]}`
+	if w.Body.String() != want {
+		t.Errorf("unexpected problem %s", w.Body.String())
+	}
+
+	r, _ = http.NewRequest("POST", "/signup", strings.NewReader("email=+Bee@Beego.me+&age=20"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != 200 || w.Body.String() != "welcome" {
+		t.Errorf("a valid form should pass, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"regexp"
+	"strings"
+
+	beecontext "github.com/aamsur/beego/context"
+)
+
+// Versions are the versions of an API routed under a prefix, each being a
+// Namespace named by its prefix, e.g. /v1. the requests:
+//   - /prefix/v2/users runs the route of v2, or of the latest older version
+//     having one when v2 doesn't override it
+//   - /prefix/users runs the route of the version asked by the Accept-Version
+//     header, the version param or the vendor type of the Accept header, e.g.
+//     application/vnd.example.v2+json, else the Default version, with the same
+//     fallback
+//
+// the version asked is the :version param of the route.
+type Versions struct {
+	// version of the unversioned requests asking none, the latest by default.
+	Default string
+
+	prefix string
+	names  []string // oldest first
+}
+
+var (
+	acceptVendorVersion = regexp.MustCompile(`\.(v[0-9][0-9.]*)\+`)
+	acceptParamVersion  = regexp.MustCompile(`version=([^;,\s]+)`)
+)
+
+// AddVersions adds the versions of an API under prefix, oldest first.
+// usage:
+//	beego.AddVersions("/api",
+//		beego.NewNamespace("/v1", beego.NSRouter("/users", &v1.UserController{})),
+//		beego.NewNamespace("/v2", beego.NSRouter("/users/:id", &v2.UserController{})),
+//	)
+func AddVersions(prefix string, versions ...*Namespace) *Versions {
+	return BeeApp.Handlers.AddVersions(prefix, versions...)
+}
+
+// AddVersions adds the versions of an API under prefix, oldest first.
+func (p *ControllerRegistor) AddVersions(prefix string, versions ...*Namespace) *Versions {
+	v := &Versions{prefix: strings.TrimSuffix(prefix, "/")}
+	for _, ns := range versions {
+		name := strings.Trim(ns.prefix, "/")
+		if name == "" || strings.ContainsAny(name, "/:*") {
+			panic("invalid api version namespace: " + ns.prefix)
+		}
+		v.names = append(v.names, name)
+	}
+	if v.prefix == "" {
+		for _, ns := range versions {
+			mergeNamespace(p, ns)
+		}
+	} else {
+		mergeNamespace(p, NewNamespace(v.prefix).Namespace(versions...))
+	}
+	t := p.table()
+	t.versions = append(t.versions, v)
+	return v
+}
+
+// index returns the index of the version name, or -1.
+func (v *Versions) index(name string) int {
+	for i, n := range v.names {
+		if strings.EqualFold(n, name) || strings.EqualFold(n, "v"+name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolve returns the index of the version asked by the request of urlPath
+// and the path in the version, ok is false if urlPath isn't under the prefix
+// of v.
+func (v *Versions) resolve(ctx *beecontext.Context, urlPath string) (i int, rest string, ok bool) {
+	if urlPath != v.prefix && !strings.HasPrefix(urlPath, v.prefix+"/") {
+		return 0, "", false
+	}
+	rest = urlPath[len(v.prefix):]
+	seg := strings.TrimPrefix(rest, "/")
+	if n := strings.Index(seg, "/"); n != -1 {
+		seg = seg[:n]
+	}
+	for i, name := range v.names {
+		if strings.EqualFold(name, seg) {
+			return i, rest[len(seg)+1:], true
+		}
+	}
+	return v.asked(ctx), rest, true
+}
+
+// asked returns the index of the version asked by the headers of the
+// request, or of the default version.
+func (v *Versions) asked(ctx *beecontext.Context) int {
+	if name := ctx.Input.Header("Accept-Version"); name != "" {
+		if i := v.index(strings.TrimSpace(name)); i != -1 {
+			return i
+		}
+	}
+	accept := ctx.Input.Header("Accept")
+	for _, re := range []*regexp.Regexp{acceptVendorVersion, acceptParamVersion} {
+		if m := re.FindStringSubmatch(accept); m != nil {
+			if i := v.index(m[1]); i != -1 {
+				return i
+			}
+		}
+	}
+	if i := v.index(v.Default); i != -1 && v.Default != "" {
+		return i
+	}
+	return len(v.names) - 1
+}
+
+// findRoute finds the route of urlPath for method, falling back to the
+// older versions for the paths of the Versions.
+func (p *ControllerRegistor) findRoute(ctx *beecontext.Context, method, urlPath string, params *Params) *controllerInfo {
+	for _, v := range p.table().versions {
+		asked, rest, ok := v.resolve(ctx, urlPath)
+		if !ok || len(v.names) == 0 {
+			continue
+		}
+		for i := asked; i >= 0; i-- {
+			if route := p.lookupRoute(ctx, method, v.prefix+"/"+v.names[i]+rest, params); route != nil {
+				params.Set(":version", v.names[asked])
+				return route
+			}
+		}
+	}
+	return p.lookupRoute(ctx, method, urlPath, params)
+}
@@ -0,0 +1,81 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aamsur/beego/context"
+)
+
+func TestVersions(t *testing.T) {
+	reply := func(body string) FilterFunc {
+		return func(ctx *context.Context) {
+			ctx.WriteString(body + " " + ctx.Input.Param(":version") + " " + ctx.Input.Param(":id"))
+		}
+	}
+	handler := NewControllerRegister()
+	versions := handler.AddVersions("/api",
+		NewNamespace("/v1",
+			NSGet("/users/:id", reply("v1 user")),
+			NSGet("/orders", reply("v1 orders")),
+		),
+		NewNamespace("/v2",
+			NSGet("/users/:id", reply("v2 user")),
+		),
+		NewNamespace("/v3"),
+	)
+	handler.Get("/api/health", reply("health"))
+
+	for _, c := range []struct {
+		url, header, value, body string
+	}{
+		{"/api/v1/users/1", "", "", "v1 user v1 1"},
+		{"/api/v2/users/1", "", "", "v2 user v2 1"},
+		{"/api/v2/orders", "", "", "v1 orders v2 "},
+		{"/api/v3/users/2", "", "", "v2 user v3 2"},
+		{"/api/users/1", "", "", "v2 user v3 1"},
+		{"/api/users/1", "Accept-Version", "v1", "v1 user v1 1"},
+		{"/api/users/1", "Accept-Version", "1", "v1 user v1 1"},
+		{"/api/users/1", "Accept", "application/vnd.example.v1+json", "v1 user v1 1"},
+		{"/api/users/1", "Accept", "application/json; version=v2", "v2 user v2 1"},
+		{"/api/health", "", "", "health  "},
+		{"/api/v2/missing", "", "", ""},
+	} {
+		r, _ := http.NewRequest("GET", c.url, nil)
+		if c.header != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if c.body == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s: code is %d, want 404", c.url, w.Code)
+			}
+		} else if w.Body.String() != c.body {
+			t.Errorf("%s %s: got %q, want %q", c.url, c.value, w.Body.String(), c.body)
+		}
+	}
+
+	versions.Default = "v1"
+	r, _ := http.NewRequest("GET", "/api/users/3", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "v1 user v1 3" {
+		t.Errorf("the unversioned requests should get the default version, got %q", w.Body.String())
+	}
+}
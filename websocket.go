@@ -0,0 +1,332 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/realtime"
+	"github.com/aamsur/beego/utils"
+	"github.com/aamsur/beego/websocket"
+)
+
+var (
+	// ErrWebSocketClosed is returned by Send when the connection is closed.
+	ErrWebSocketClosed = errors.New("websocket: connection closed")
+	// ErrWebSocketQueueFull is returned by Send when the client doesn't read
+	// the messages fast enough.
+	ErrWebSocketQueueFull = errors.New("websocket: send queue full")
+)
+
+// WebSocketHandler are the lifecycle methods of a WebSocketController, the
+// controllers embedding it override the ones they need. they're called on the
+// goroutine of the request, one at a time.
+type WebSocketHandler interface {
+	// OnOpen is called once the connection is upgraded.
+	OnOpen()
+	// OnMessage is called for every text or binary message received.
+	OnMessage(messageType int, data []byte)
+	// OnError is called for the errors closing the connection, but its close.
+	OnError(err error)
+	// OnClose is called once the connection is closed, with the code and the
+	// text of the close frame, CloseAbnormalClosure when there was none.
+	OnClose(code int, text string)
+}
+
+// WebSocketController is the base of the websocket endpoints. GET upgrades the
+// request and runs the connection: the reads call OnMessage, Send queues the
+// messages for a writer goroutine which also pings the client.
+//
+//	type ChatController struct {
+//		beego.WebSocketController
+//	}
+//
+//	func (c *ChatController) OnMessage(messageType int, data []byte) {
+//		c.Send(messageType, data)
+//	}
+//
+//	beego.Router("/ws", &ChatController{})
+type WebSocketController struct {
+	Controller
+	Conn *websocket.Conn
+
+	// the settings of the connection, from the config. Prepare may change them.
+	MaxMessageSize int64
+	PingPeriod     time.Duration
+	WriteTimeout   time.Duration
+	SendQueueSize  int
+	// Overflow is what Send does when the queue is full, from WebSocketOverflow.
+	Overflow realtime.Overflow
+	// CheckOrigin returns whether the Origin of the request is accepted, by
+	// default the requests of other hosts are refused.
+	CheckOrigin func(r *http.Request) bool
+	// Authenticate authenticates the upgrade, WebSocketAuth by default.
+	Authenticate WebSocketAuthFunc
+	// Identity is the identity returned by Authenticate, or the user set by
+	// an auth filter like plugins/jwt.
+	Identity interface{}
+
+	id          string
+	send        *realtime.SendQueue
+	done        chan struct{}
+	writeFailed chan struct{} // closed when a write failed with writeErr
+	writeErr    error
+}
+
+// WebSocketEndpoint is a controller embedding WebSocketController.
+type WebSocketEndpoint interface {
+	ControllerInterface
+	WebSocketHandler
+	webSocket() *WebSocketController
+}
+
+func (c *WebSocketController) webSocket() *WebSocketController {
+	return c
+}
+
+// WebSocketRouter adds the websocket endpoint c to BeeApp at pattern.
+// only the GET requests are routed to it, the filters, the route options and
+// the session run before the upgrade as for the other routes.
+// usage:
+//	beego.WebSocketRouter("/chat/:room", &ChatController{}, beego.WithFilters(auth))
+func WebSocketRouter(pattern string, c WebSocketEndpoint, options ...RouteOption) *App {
+	BeeApp.Handlers.AddWebSocket(pattern, c, options...)
+	return BeeApp
+}
+
+// AddWebSocket adds the websocket endpoint c at pattern, see WebSocketRouter.
+func (p *ControllerRegistor) AddWebSocket(pattern string, c WebSocketEndpoint, options ...RouteOption) {
+	args := []interface{}{"get:Get"}
+	for _, o := range options {
+		args = append(args, o)
+	}
+	p.Add(pattern, c, args...)
+}
+
+// Init sets the settings of the connection from the config.
+func (c *WebSocketController) Init(ctx *context.Context, controllerName, actionName string, app interface{}) {
+	c.Controller.Init(ctx, controllerName, actionName, app)
+	c.MaxMessageSize = WebSocketMaxMessage
+	c.PingPeriod = time.Duration(WebSocketPingPeriod) * time.Second
+	c.WriteTimeout = time.Duration(WebSocketWriteTimeout) * time.Second
+	c.SendQueueSize = WebSocketSendQueue
+	c.Overflow, _ = realtime.ParseOverflow(WebSocketOverflow)
+	c.Authenticate = WebSocketAuth
+}
+
+// Get upgrades the request and runs the connection until it's closed.
+func (c *WebSocketController) Get() {
+	c.EnableRender = false
+	handler, ok := c.AppController.(WebSocketHandler)
+	if !ok {
+		handler = c
+	}
+	if !c.authenticate() {
+		return
+	}
+	upgrader := &websocket.Upgrader{CheckOrigin: c.CheckOrigin}
+	conn, err := upgrader.Upgrade(c.Ctx.ResponseWriter, c.Ctx.Request, nil)
+	if err != nil {
+		// the upgrader answered the request
+		return
+	}
+	c.Conn = conn
+	c.id = string(utils.RandomCreateBytes(20))
+	c.send = realtime.NewSendQueue(c.SendQueueSize, c.Overflow)
+	c.done = make(chan struct{})
+	c.writeFailed = make(chan struct{})
+
+	// a client has a ping period and the write timeout to answer a ping
+	wait := c.PingPeriod + c.WriteTimeout
+	conn.SetReadLimit(c.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+
+	written := make(chan struct{})
+	go c.writePump(written)
+	handler.OnOpen()
+	code, text := c.readPump(handler)
+	close(c.done)
+	<-written
+	c.send.Close()
+	conn.Close()
+	handler.OnClose(code, text)
+}
+
+// authenticate runs Authenticate and answers the requests it refuses.
+func (c *WebSocketController) authenticate() bool {
+	if c.Authenticate == nil {
+		c.Identity = c.Ctx.User()
+		return true
+	}
+	identity, err := c.Authenticate(c.Ctx)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if e, ok := err.(*WebSocketAuthError); ok && e.Status != 0 {
+			status = e.Status
+		}
+		http.Error(c.Ctx.ResponseWriter, err.Error(), status)
+		return false
+	}
+	c.Identity = identity
+	c.Ctx.SetUser(identity)
+	return true
+}
+
+func (c *WebSocketController) readPump(handler WebSocketHandler) (int, string) {
+	for {
+		messageType, data, err := c.Conn.ReadMessage()
+		if err == nil {
+			handler.OnMessage(messageType, data)
+			continue
+		}
+		if e, ok := err.(*websocket.CloseError); ok {
+			return e.Code, e.Text
+		}
+		// a write failing closes the connection, failing the read
+		select {
+		case <-c.writeFailed:
+			err = c.writeErr
+		default:
+		}
+		// a client gone without a close frame is not an error of the app
+		if err != io.EOF {
+			handler.OnError(err)
+		}
+		return websocket.CloseAbnormalClosure, ""
+	}
+}
+
+func (c *WebSocketController) writePump(written chan struct{}) {
+	defer close(written)
+	var ticker *time.Ticker
+	var pings <-chan time.Time
+	if c.PingPeriod > 0 {
+		ticker = time.NewTicker(c.PingPeriod)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+	overflowed := c.send.Overflowed()
+	for {
+		var err error
+		select {
+		case m := <-c.send.C():
+			if m.Type == websocket.CloseMessage {
+				err = c.closeWrite(m.Data)
+			} else {
+				c.Conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+				err = c.Conn.WriteMessage(m.Type, m.Data)
+			}
+		case <-overflowed:
+			// the client too slow is disconnected, with OverflowDisconnect
+			overflowed = nil
+			err = c.closeWrite(websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "send queue full"))
+		case <-pings:
+			err = c.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.WriteTimeout))
+		case <-c.done:
+			return
+		}
+		if err != nil && err != websocket.ErrCloseSent {
+			c.writeErr = err
+			close(c.writeFailed)
+			c.Conn.Close()
+			<-c.done
+			return
+		}
+	}
+}
+
+// closeWrite sends a close frame, the client has the write timeout to answer.
+func (c *WebSocketController) closeWrite(frame []byte) error {
+	err := c.Conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(c.WriteTimeout))
+	c.Conn.SetReadDeadline(time.Now().Add(c.WriteTimeout))
+	return err
+}
+
+// Send queues a text or binary message, or a close frame, for the client.
+// it fails when the connection is closed or the client doesn't read fast
+// enough to empty the queue of SendQueueSize messages, then Overflow drops a
+// message or disconnects the client. it may be called from any goroutine.
+func (c *WebSocketController) Send(messageType int, data []byte) error {
+	if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage && messageType != websocket.CloseMessage {
+		return errors.New("websocket: Send of a ping or pong")
+	}
+	if c.done == nil {
+		return ErrWebSocketClosed
+	}
+	select {
+	case <-c.done:
+		return ErrWebSocketClosed
+	default:
+	}
+	if err := c.send.Push(realtime.Message{Type: messageType, Data: data}); err != nil {
+		return ErrWebSocketQueueFull
+	}
+	return nil
+}
+
+// QueueLength returns the number of messages waiting to be written.
+func (c *WebSocketController) QueueLength() int {
+	if c.send == nil {
+		return 0
+	}
+	return c.send.Len()
+}
+
+// ID returns the random id of the connection, to address it in a realtime.Hub.
+func (c *WebSocketController) ID() string {
+	return c.id
+}
+
+// SendText queues a text message.
+func (c *WebSocketController) SendText(text string) error {
+	return c.Send(websocket.TextMessage, []byte(text))
+}
+
+// SendJSON queues the json of v as a text message.
+func (c *WebSocketController) SendJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Send(websocket.TextMessage, data)
+}
+
+// CloseWith closes the connection with a close frame of code and text, after
+// the messages queued before. OnClose is called once the client answers.
+func (c *WebSocketController) CloseWith(code int, text string) error {
+	return c.Send(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+}
+
+// OnOpen does nothing.
+func (c *WebSocketController) OnOpen() {}
+
+// OnMessage does nothing.
+func (c *WebSocketController) OnMessage(messageType int, data []byte) {}
+
+// OnError logs the error.
+func (c *WebSocketController) OnError(err error) {
+	Warn("websocket:", c.Ctx.Request.URL.Path, err)
+}
+
+// OnClose does nothing.
+func (c *WebSocketController) OnClose(code int, text string) {}
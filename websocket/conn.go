@@ -0,0 +1,441 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package websocket implements the RFC 6455 websocket protocol, the server
+// upgrade of http requests and a client.
+//
+//	var upgrader = websocket.Upgrader{}
+//
+//	func echo(w http.ResponseWriter, r *http.Request) {
+//		conn, err := upgrader.Upgrade(w, r, nil)
+//		if err != nil {
+//			return // the upgrader answered the request with an error
+//		}
+//		defer conn.Close()
+//		for {
+//			t, data, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			conn.WriteMessage(t, data)
+//		}
+//	}
+//
+// beego.WebSocketController runs the connections of controllers.
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// the message types of the frames.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// the close codes of RFC 6455 section 7.4.1 and of the IANA registry.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+	CloseServiceRestart          = 1012
+	CloseTryAgainLater           = 1013
+)
+
+const maxControlPayload = 125
+
+// DefaultReadLimit is the largest message read by a new connection,
+// SetReadLimit changes it.
+const DefaultReadLimit = 32 << 20
+
+// payloadChunk is the most allocated for a payload before its data is read,
+// the length in a frame header is only a claim of the peer.
+const payloadChunk = 64 << 10
+
+// ErrCloseSent is returned when writing after the close frame was sent.
+var ErrCloseSent = errors.New("websocket: close sent")
+
+// CloseError is the close of a connection: the close frame received, or the
+// protocol error the connection was closed for.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	s := "websocket: close " + strconv.Itoa(e.Code)
+	if e.Text != "" {
+		s += " " + e.Text
+	}
+	return s
+}
+
+// IsCloseError reports whether err is a CloseError with one of the codes.
+func IsCloseError(err error, codes ...int) bool {
+	if e, ok := err.(*CloseError); ok {
+		for _, code := range codes {
+			if e.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FormatCloseMessage returns the payload of a close frame.
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	b := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	copy(b[2:], text)
+	return b
+}
+
+// Conn is a websocket connection. one goroutine may read while others write,
+// the writes are serialized.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+
+	writeMu       sync.Mutex
+	writeDeadline time.Time
+	closeSent     bool
+
+	readLimit   int64
+	readErr     error
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool) *Conn {
+	c := &Conn{conn: conn, br: br, isServer: isServer, readLimit: DefaultReadLimit}
+	c.pingHandler = func(data string) error {
+		err := c.WriteControl(PongMessage, []byte(data), time.Now().Add(time.Second))
+		if err == ErrCloseSent {
+			return nil
+		}
+		return err
+	}
+	c.pongHandler = func(string) error { return nil }
+	return c
+}
+
+// Subprotocol returns the subprotocol negotiated by the handshake.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// RemoteAddr returns the address of the peer.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// LocalAddr returns the local address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Close closes the connection without sending a close frame.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// SetReadLimit sets the largest message read in bytes, a larger message
+// closes the connection with CloseMessageTooBig. 0 is no limit, the default
+// is DefaultReadLimit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetReadDeadline sets the deadline of the reads, a read timing out fails
+// the connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of the writes.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.writeDeadline = t
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler sets the handler of the pings read by ReadMessage, the
+// default one answers with a pong.
+func (c *Conn) SetPingHandler(h func(data string) error) {
+	c.pingHandler = h
+}
+
+// SetPongHandler sets the handler of the pongs read by ReadMessage.
+func (c *Conn) SetPongHandler(h func(data string) error) {
+	c.pongHandler = h
+}
+
+// ReadMessage reads the next text or binary message, answering the control
+// frames on the way. the errors are permanent, a close frame received is
+// returned as a CloseError.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	defer func() {
+		if err != nil {
+			c.readErr = err
+		}
+	}()
+	for {
+		fin, opcode, payload, err := c.readFrame(int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case PingMessage:
+			if err := c.pingHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if err := c.pongHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.readClose(payload)
+		case 0:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation frame without a message")
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "message started inside a fragmented message")
+			}
+			messageType = opcode
+		}
+		data = append(data, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(data) {
+				return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf-8 text")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// readClose answers a close frame and returns its CloseError.
+func (c *Conn) readClose(payload []byte) error {
+	code, text := CloseNoStatusReceived, ""
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		code, text = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+		if !validCloseCode(code) || !utf8.ValidString(text) {
+			return c.fail(CloseProtocolError, "invalid close payload")
+		}
+	}
+	c.WriteControl(CloseMessage, FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+	return &CloseError{Code: code, Text: text}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014, code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail sends a close frame with code and returns its CloseError.
+func (c *Conn) fail(code int, text string) error {
+	c.WriteControl(CloseMessage, FormatCloseMessage(code, text), time.Now().Add(time.Second))
+	return &CloseError{Code: code, Text: text}
+}
+
+// readFrame reads a frame, read is the length of the message read before it.
+func (c *Conn) readFrame(read int64) (fin bool, opcode int, payload []byte, err error) {
+	var h [8]byte
+	if _, err = io.ReadFull(c.br, h[:2]); err != nil {
+		return
+	}
+	fin = h[0]&0x80 != 0
+	opcode = int(h[0] & 0x0f)
+	reserved := h[0] & 0x70
+	masked := h[1]&0x80 != 0
+	length := int64(h[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(c.br, h[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(h[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.br, h[:8]); err != nil {
+			return
+		}
+		if h[0]&0x80 != 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, "invalid frame length")
+		}
+		length = int64(binary.BigEndian.Uint64(h[:8]))
+	}
+
+	switch {
+	case reserved != 0:
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	case masked != c.isServer:
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid frame masking")
+	case opcode >= CloseMessage && opcode <= PongMessage:
+		if !fin || length > maxControlPayload {
+			return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	case opcode > BinaryMessage:
+		return false, 0, nil, c.fail(CloseProtocolError, "unknown opcode "+strconv.Itoa(opcode))
+	case c.readLimit > 0 && read+length > c.readLimit:
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+	if payload, err = readPayload(c.br, length); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+	return fin, opcode, payload, nil
+}
+
+// readPayload reads n bytes, the buffer grows with the data received.
+func readPayload(r io.Reader, n int64) ([]byte, error) {
+	if n <= payloadChunk {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(payloadChunk)
+	if _, err := io.CopyN(&buf, r, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+// WriteMessage writes a text or binary message in one frame.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("websocket: WriteMessage of a control frame, use WriteControl")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrame(messageType, data)
+}
+
+// WriteControl writes a close, ping or pong frame before the deadline.
+// the close frame is the last frame written.
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType < CloseMessage || messageType > PongMessage {
+		return errors.New("websocket: WriteControl of a data frame, use WriteMessage")
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame payload too long")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if !deadline.IsZero() {
+		c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(c.writeDeadline)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// writeFrame writes a final frame, with c.writeMu held.
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	frame := make([]byte, 0, len(data)+14)
+	frame = append(frame, 0x80|byte(opcode))
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+	switch n := len(data); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskBit|127)
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(n))
+		frame = append(frame, l[:]...)
+	}
+	start := len(frame)
+	if !c.isServer {
+		// the client frames are masked with a random key
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start += 4
+		frame = append(frame, data...)
+		maskBytes(key, frame[start:])
+	} else {
+		frame = append(frame, data...)
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
@@ -0,0 +1,197 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func echoServer(t *testing.T, limit int64) *httptest.Server {
+	u := &Upgrader{Subprotocols: []string{"chat"}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := u.Upgrade(w, r, http.Header{"X-Echo": {"1"}})
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.SetReadLimit(limit)
+		for {
+			messageType, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := c.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func wsURL(s *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func TestEcho(t *testing.T) {
+	s := echoServer(t, 1<<20)
+	defer s.Close()
+	c, resp, err := Dial(wsURL(s), http.Header{"Sec-WebSocket-Protocol": {"v2, chat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Subprotocol() != "chat" || resp.Header.Get("X-Echo") != "1" {
+		t.Errorf("the handshake should negotiate chat and send the header, got %q %v", c.Subprotocol(), resp.Header)
+	}
+
+	big := bytes.Repeat([]byte("x"), 70000)
+	for _, m := range []struct {
+		t    int
+		data []byte
+	}{{TextMessage, []byte("hello")}, {BinaryMessage, []byte{0, 1, 2}}, {TextMessage, big}, {TextMessage, []byte{}}} {
+		if err := c.WriteMessage(m.t, m.data); err != nil {
+			t.Fatal(err)
+		}
+		messageType, data, err := c.ReadMessage()
+		if err != nil || messageType != m.t || !bytes.Equal(data, m.data) {
+			t.Errorf("the echo of %d bytes should be the same, got %d %d bytes %v", len(m.data), messageType, len(data), err)
+		}
+	}
+
+	pong := make(chan string, 1)
+	c.SetPongHandler(func(data string) error {
+		pong <- data
+		return nil
+	})
+	c.WriteControl(PingMessage, []byte("beat"), time.Now().Add(time.Second))
+	c.WriteMessage(TextMessage, []byte("after"))
+	if _, data, err := c.ReadMessage(); err != nil || string(data) != "after" {
+		t.Errorf("the message after the ping should be read, got %q %v", data, err)
+	}
+	if data := <-pong; data != "beat" {
+		t.Errorf("the ping should be answered with its data, got %q", data)
+	}
+
+	c.WriteControl(CloseMessage, FormatCloseMessage(CloseNormalClosure, "bye"), time.Now().Add(time.Second))
+	if _, _, err := c.ReadMessage(); !IsCloseError(err, CloseNormalClosure) {
+		t.Errorf("the close should be echoed, got %v", err)
+	}
+	if err := c.WriteMessage(TextMessage, []byte("late")); err != ErrCloseSent {
+		t.Errorf("writing after the close should fail, got %v", err)
+	}
+}
+
+func TestFragmentsAndErrors(t *testing.T) {
+	s := echoServer(t, 10)
+	defer s.Close()
+
+	c, _, err := Dial(wsURL(s), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// raw returns a masked client frame
+	raw := func(b0 byte, payload string) []byte {
+		key := [4]byte{1, 2, 3, 4}
+		p := []byte(payload)
+		maskBytes(key, p)
+		return append(append([]byte{b0, 0x80 | byte(len(payload))}, key[:]...), p...)
+	}
+	// a text message in two fragments with a ping between them
+	var b []byte
+	b = append(b, raw(0x01, "hel")...)
+	b = append(b, raw(0x89, "")...)
+	b = append(b, raw(0x80, "lo")...)
+	c.conn.Write(b)
+	if messageType, data, err := c.ReadMessage(); err != nil || messageType != TextMessage || string(data) != "hello" {
+		t.Errorf("the fragments should be joined, got %d %q %v", messageType, data, err)
+	}
+
+	c.WriteMessage(TextMessage, []byte("a message over the limit"))
+	if _, _, err := c.ReadMessage(); !IsCloseError(err, CloseMessageTooBig) {
+		t.Errorf("a message over the limit should close the connection, got %v", err)
+	}
+	c.Close()
+
+	c, _, err = Dial(wsURL(s), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.conn.Write(raw(0x81, "\xff"))
+	if _, _, err := c.ReadMessage(); !IsCloseError(err, CloseInvalidFramePayloadData) {
+		t.Errorf("invalid utf-8 should close the connection, got %v", err)
+	}
+	c.Close()
+
+	for header, status := range map[string]int{
+		"Origin":                http.StatusForbidden,
+		"Sec-WebSocket-Version": http.StatusUpgradeRequired,
+	} {
+		h := http.Header{}
+		if header == "Origin" {
+			h.Set("Origin", "http://evil.example")
+		}
+		r, _ := http.NewRequest("GET", s.URL, nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		for k, v := range h {
+			r.Header[k] = v
+		}
+		if header == "Sec-WebSocket-Version" {
+			r.Header.Set("Sec-WebSocket-Version", "8")
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("%s: the upgrade should be refused with %d, got %d", header, status, resp.StatusCode)
+		}
+	}
+}
+
+func TestFrameLength(t *testing.T) {
+	server, client := net.Pipe()
+	c := newConn(server, bufio.NewReader(server), true)
+	if c.readLimit != DefaultReadLimit {
+		t.Errorf("a new connection should have the default read limit, got %d", c.readLimit)
+	}
+	c.SetReadLimit(0)
+	go func() {
+		// a binary frame claiming 2^62 bytes, then the peer goes away
+		client.Write([]byte{0x82, 0x80 | 127, 0x40, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4})
+		client.Write([]byte("some data"))
+		client.Close()
+	}()
+	if _, _, err := c.ReadMessage(); err != io.ErrUnexpectedEOF {
+		t.Errorf("the payload should be read as it comes, got %v", err)
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// the example of RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %s", got)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aamsur/beego/context"
+	"github.com/aamsur/beego/websocket"
+)
+
+// the controllers are created by the router, their events are sent here
+var wsEvents = make(chan string, 10)
+
+type wsEchoController struct {
+	WebSocketController
+}
+
+func (c *wsEchoController) Prepare() {
+	c.MaxMessageSize = 16
+}
+
+func (c *wsEchoController) OnOpen() {
+	c.SendText("welcome")
+}
+
+func (c *wsEchoController) OnMessage(messageType int, data []byte) {
+	if string(data) == "bye" {
+		c.CloseWith(4000, "bye")
+		return
+	}
+	c.Send(messageType, data)
+}
+
+func (c *wsEchoController) OnError(err error) {
+	wsEvents <- "error " + err.Error()
+}
+
+func (c *wsEchoController) OnClose(code int, text string) {
+	wsEvents <- fmt.Sprintf("close %d %s", code, text)
+}
+
+func TestWebSocketController(t *testing.T) {
+	handlers := NewControllerRegister()
+	handlers.Add("/ws", &wsEchoController{})
+	s := httptest.NewServer(handlers)
+	defer s.Close()
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+
+	conn, _, err := websocket.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "welcome" {
+		t.Fatalf("OnOpen should send welcome, got %q %v", data, err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Errorf("OnMessage should echo hello, got %q %v", data, err)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("bye"))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, 4000) {
+		t.Errorf("CloseWith should close the connection with 4000, got %v", err)
+	}
+	if e := <-wsEvents; e != "close 4000 " {
+		t.Errorf("OnClose should get the close code echoed, got %s", e)
+	}
+
+	// the messages over MaxMessageSize close the connection
+	conn, _, err = websocket.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	conn.ReadMessage()
+	conn.WriteMessage(websocket.TextMessage, []byte("a message over the limit"))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("a message too big should close the connection, got %v", err)
+	}
+	if e := <-wsEvents; e != "close 1009 message too big" {
+		t.Errorf("OnClose should get the close, got %s", e)
+	}
+
+	// a plain GET is refused
+	resp, err := http.Get(s.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("a request without upgrade should get 400, got %d", resp.StatusCode)
+	}
+}
+
+type wsIdentityController struct {
+	WebSocketController
+}
+
+func (c *wsIdentityController) Prepare() {
+	c.Authenticate = WebSocketTokenAuth(func(token string) (interface{}, error) {
+		if token != "secret" {
+			return nil, errors.New("unknown token")
+		}
+		return "astaxie", nil
+	})
+}
+
+func (c *wsIdentityController) OnOpen() {
+	c.SendText(fmt.Sprint(c.Identity))
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	handlers := NewControllerRegister()
+	handlers.Add("/ws", &wsIdentityController{})
+	s := httptest.NewServer(handlers)
+	defer s.Close()
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+
+	if _, resp, err := websocket.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != 401 {
+		t.Errorf("an upgrade without token should get 401, got %v", err)
+	}
+	if _, resp, err := websocket.Dial(url+"?access_token=wrong", nil); err == nil || resp == nil || resp.StatusCode != 401 {
+		t.Errorf("an upgrade with an invalid token should get 401, got %v", err)
+	}
+	conn, _, err := websocket.Dial(url, http.Header{"Authorization": {"Bearer secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "astaxie" {
+		t.Errorf("the identity should be attached to the connection, got %q %v", data, err)
+	}
+}
+
+type wsRoomController struct {
+	WebSocketController
+}
+
+func (c *wsRoomController) OnOpen() {
+	c.SendText(c.Ctx.Input.Param(":room") + " " + c.Ctx.Input.GetData("filtered").(string))
+}
+
+func TestWebSocketRouter(t *testing.T) {
+	handlers := NewControllerRegister()
+	mergeNamespace(handlers, NewNamespace("/live",
+		NSWebSocket("/:room", &wsRoomController{}, WithFilters(func(ctx *context.Context) {
+			ctx.Input.SetData("filtered", "yes")
+		})),
+	))
+	s := httptest.NewServer(handlers)
+	defer s.Close()
+
+	conn, _, err := websocket.Dial("ws"+strings.TrimPrefix(s.URL, "http")+"/live/lobby", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "lobby yes" {
+		t.Errorf("the endpoint should get the params and run the filters, got %q %v", data, err)
+	}
+
+	resp, err := http.Post(s.URL+"/live/lobby", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("only GET should be routed, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2014 beego Author. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beego
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aamsur/beego/context"
+)
+
+// WebSocketAuthFunc authenticates the upgrade request of a websocket and
+// returns its identity. its errors refuse the upgrade, with 401 or the status
+// of a WebSocketAuthError.
+type WebSocketAuthFunc func(ctx *context.Context) (interface{}, error)
+
+// WebSocketAuth is the Authenticate of the WebSocketControllers, the upgrades
+// aren't authenticated when nil.
+//
+//	beego.WebSocketAuth = beego.WebSocketSessionAuth("uid")
+var WebSocketAuth WebSocketAuthFunc
+
+// WebSocketAuthError refuses an upgrade with its status.
+type WebSocketAuthError struct {
+	Status  int
+	Message string
+}
+
+func (e *WebSocketAuthError) Error() string {
+	return e.Message
+}
+
+var (
+	errWebSocketNoCredentials = &WebSocketAuthError{http.StatusUnauthorized, "no credentials"}
+	errWebSocketInvalidToken  = &WebSocketAuthError{http.StatusUnauthorized, "invalid token"}
+)
+
+// WebSocketSessionAuth authenticates the upgrades with the value of key in
+// the session, the browsers send the session cookie with the upgrade.
+// it needs SessionOn.
+func WebSocketSessionAuth(key string) WebSocketAuthFunc {
+	return func(ctx *context.Context) (interface{}, error) {
+		sess, err := ctx.Input.StartSession()
+		if err != nil {
+			return nil, &WebSocketAuthError{http.StatusServiceUnavailable, err.Error()}
+		}
+		if sess == nil {
+			return nil, errWebSocketNoCredentials
+		}
+		identity := sess.Get(key)
+		if identity == nil {
+			return nil, errWebSocketNoCredentials
+		}
+		return identity, nil
+	}
+}
+
+// WebSocketTokenAuth authenticates the upgrades with the token of their
+// Authorization header, "Bearer <token>", or of their access_token query
+// parameter for the browsers, which can't set the headers of a websocket.
+// verify returns the identity of a token, or an error when it's invalid.
+//
+//	beego.WebSocketAuth = beego.WebSocketTokenAuth(func(token string) (interface{}, error) {
+//		return models.UserOfToken(token)
+//	})
+func WebSocketTokenAuth(verify func(token string) (interface{}, error)) WebSocketAuthFunc {
+	return func(ctx *context.Context) (interface{}, error) {
+		token := WebSocketToken(ctx.Request)
+		if token == "" {
+			return nil, errWebSocketNoCredentials
+		}
+		identity, err := verify(token)
+		if err != nil {
+			if _, ok := err.(*WebSocketAuthError); ok {
+				return nil, err
+			}
+			return nil, errWebSocketInvalidToken
+		}
+		return identity, nil
+	}
+}
+
+// WebSocketToken returns the bearer token of an upgrade request, from its
+// Authorization header or its access_token query parameter.
+func WebSocketToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		s := strings.SplitN(auth, " ", 2)
+		if len(s) == 2 && strings.EqualFold(s[0], "Bearer") {
+			return strings.TrimSpace(s[1])
+		}
+		return ""
+	}
+	return r.URL.Query().Get("access_token")
+}